@@ -20,19 +20,65 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// OperationCancellationRequestedAnnotation is set (to "true") on an Operation CR by the backend, when it has
+// requested cancellation of the Operation in the database: the update of the CR causes the cluster-agent to process
+// the Operation again, which then moves it to the Cancelled state. An Operation that is already being processed is
+// instead cancelled once the cluster-agent sees the cancellation request in the database.
+const OperationCancellationRequestedAnnotation = "managed-gitops.redhat.com/cancellation-requested"
+
 // OperationSpec defines the desired state of Operation
 type OperationSpec struct {
 	OperationID string `json:"operationID,omitempty"`
 }
 
+// OperationState is the state of an Operation: it mirrors the 'state' field of the Operation database row.
+type OperationState string
+
+const (
+	OperationState_Waiting     OperationState = "Waiting"
+	OperationState_In_Progress OperationState = "In_Progress"
+	OperationState_Completed   OperationState = "Completed"
+	OperationState_Failed      OperationState = "Failed"
+	OperationState_Timeout     OperationState = "Timeout"
+	OperationState_Cancelled   OperationState = "Cancelled"
+)
+
 // OperationStatus defines the observed state of Operation
+//
+// The database is the source of truth for the state of an Operation: the cluster-agent mirrors the database row into
+// this status whenever it updates the row, so that the status may be used to observe the Operation.
 type OperationStatus struct {
-	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
+	// State is the state of the Operation: Waiting, In_Progress, Completed, Failed, Timeout, or Cancelled
+	// +kubebuilder:validation:Enum=Waiting;In_Progress;Completed;Failed;Timeout;Cancelled
+	State OperationState `json:"state,omitempty"`
+
+	// Message is a human-readable description of the state of the Operation (for example, the error that caused it to fail)
+	Message string `json:"message,omitempty"`
+
+	// CreatedAt is when the Operation was created in the database
+	CreatedAt *metav1.Time `json:"createdAt,omitempty"`
+
+	// LastStateUpdate is when the state of the Operation last changed
+	LastStateUpdate *metav1.Time `json:"lastStateUpdate,omitempty"`
+
+	// ObservedGeneration is the generation of the Operation CR that was most recently processed by the cluster-agent
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+}
+
+// IsComplete returns true if the Operation will not be processed further: it has completed (either successfully or
+// unsuccessfully), timed out, or been cancelled.
+func (status OperationStatus) IsComplete() bool {
+	return status.State == OperationState_Completed || status.State == OperationState_Failed ||
+		status.State == OperationState_Timeout || status.State == OperationState_Cancelled
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Operation ID",type=string,JSONPath=`.spec.operationID`
+//+kubebuilder:printcolumn:name="State",type=string,JSONPath=`.status.state`
+//+kubebuilder:printcolumn:name="Message",type=string,priority=1,JSONPath=`.status.message`
+//+kubebuilder:printcolumn:name="Last Update",type=date,JSONPath=`.status.lastStateUpdate`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // Operation is the Schema for the operations API
 type Operation struct {
@@ -26,7 +26,7 @@ func (dbq *PostgreSQLDatabaseQueries) DeleteAPICRToDatabaseMapping(ctx context.C
 		Where("atdbm.db_relation_type = ?", obj.DBRelationType).
 		Context(ctx).Delete()
 	if err != nil {
-		return 0, fmt.Errorf("error on deleting APICRToDatabaseMapping: %v", err)
+		return 0, fmt.Errorf("error on deleting APICRToDatabaseMapping: %w", mapDBError(err))
 	}
 
 	return deleteResult.RowsAffected(), nil
@@ -52,7 +52,7 @@ func (dbq *PostgreSQLDatabaseQueries) CreateAPICRToDatabaseMapping(ctx context.C
 
 	result, err := dbq.dbConnection.Model(obj).Context(ctx).Insert()
 	if err != nil {
-		return fmt.Errorf("error on inserting APICRToDatabaseMapping %v", err)
+		return fmt.Errorf("error on inserting APICRToDatabaseMapping %w", mapDBError(err))
 	}
 
 	if result.RowsAffected() != 1 {
@@ -86,7 +86,7 @@ func (dbq *PostgreSQLDatabaseQueries) GetDatabaseMappingForAPICR(ctx context.Con
 		Context(ctx).
 		Select(); err != nil {
 
-		return fmt.Errorf("error on retrieving database mapping for APICRToDatabase: %v", err)
+		return fmt.Errorf("error on retrieving database mapping for APICRToDatabase: %w", mapDBError(err))
 	}
 
 	if len(result) == 0 {
@@ -132,7 +132,7 @@ func (dbq *PostgreSQLDatabaseQueries) ListAPICRToDatabaseMappingByAPINamespaceAn
 		Context(ctx).
 		Select(); err != nil {
 
-		return fmt.Errorf("error on retrieving ListAPICRToDatabaseMappingByAPINamespaceAndName: %v", err)
+		return fmt.Errorf("error on retrieving ListAPICRToDatabaseMappingByAPINamespaceAndName: %w", mapDBError(err))
 	}
 
 	*apiCRToDBMappingParam = dbResults
@@ -151,3 +151,30 @@ func (dbMapping *APICRToDatabaseMapping) DisposeAppScoped(ctx context.Context, d
 
 	return err
 }
+
+// ListAPICRToDatabaseMappingsByWorkspaceUID returns the APICRToDatabaseMappings of the API CRs in the workspace.
+func (dbq *PostgreSQLDatabaseQueries) ListAPICRToDatabaseMappingsByWorkspaceUID(ctx context.Context, workspaceUID string, apiCRToDBMappings *[]APICRToDatabaseMapping) error {
+
+	if err := validateQueryParamsEntity(apiCRToDBMappings, dbq); err != nil {
+		return err
+	}
+
+	if err := isEmptyValues("ListAPICRToDatabaseMappingsByWorkspaceUID", "workspaceUID", workspaceUID); err != nil {
+		return err
+	}
+
+	var dbResults []APICRToDatabaseMapping
+
+	if err := dbq.dbConnection.Model(&dbResults).
+		Where("atdbm.api_resource_workspace_uid = ?", workspaceUID).
+		Order("seq_id ASC").
+		Context(ctx).
+		Select(); err != nil {
+
+		return fmt.Errorf("error on retrieving ListAPICRToDatabaseMappingsByWorkspaceUID: %w", mapDBError(err))
+	}
+
+	*apiCRToDBMappings = dbResults
+
+	return nil
+}
@@ -3,6 +3,9 @@ package db
 import (
 	"context"
 	"fmt"
+	"time"
+
+	"github.com/go-pg/pg/v10"
 )
 
 func (dbq *PostgreSQLDatabaseQueries) CheckedGetApplicationById(ctx context.Context, application *Application, ownerId string) error {
@@ -24,7 +27,7 @@ func (dbq *PostgreSQLDatabaseQueries) CheckedGetApplicationById(ctx context.Cont
 			Context(ctx).
 			Select(); err != nil {
 
-			return fmt.Errorf("error on retrieving Application: %v", err)
+			return fmt.Errorf("error on retrieving Application: %w", mapDBError(err))
 		}
 
 		if len(results) == 0 {
@@ -72,7 +75,7 @@ func (dbq *PostgreSQLDatabaseQueries) GetApplicationById(ctx context.Context, ap
 		Context(ctx).
 		Select(); err != nil {
 
-		return fmt.Errorf("error on retrieving Application: %v", err)
+		return fmt.Errorf("error on retrieving Application: %w", mapDBError(err))
 	}
 
 	if len(results) == 0 {
@@ -88,6 +91,44 @@ func (dbq *PostgreSQLDatabaseQueries) GetApplicationById(ctx context.Context, ap
 	return nil
 }
 
+func (dbq *PostgreSQLDatabaseQueries) LockApplicationById(ctx context.Context, application *Application) error {
+
+	if err := validateQueryParamsEntity(application, dbq); err != nil {
+		return err
+	}
+
+	if isEmpty(application.Application_id) {
+		return fmt.Errorf("application_Id is nil")
+	}
+
+	if _, ok := dbq.dbConnection.(*pg.Tx); !ok {
+		return fmt.Errorf("LockApplicationById must be called within a transaction")
+	}
+
+	var results []Application
+
+	if err := dbq.dbConnection.Model(&results).
+		Where("application_id = ?", application.Application_id).
+		For("UPDATE").
+		Context(ctx).
+		Select(); err != nil {
+
+		return fmt.Errorf("error on locking Application: %w", mapDBError(err))
+	}
+
+	if len(results) == 0 {
+		return NewResultNotFoundError(fmt.Sprintf("Application '%s'", application.Application_id))
+	}
+
+	if len(results) > 1 {
+		return fmt.Errorf("multiple results found on locking Application: %v", application.Application_id)
+	}
+
+	*application = results[0]
+
+	return nil
+}
+
 func (dbq *PostgreSQLDatabaseQueries) CheckedCreateApplication(ctx context.Context, obj *Application, ownerId string) error {
 
 	if err := validateQueryParamsEntity(obj, dbq); err != nil {
@@ -117,12 +158,15 @@ func (dbq *PostgreSQLDatabaseQueries) CheckedCreateApplication(ctx context.Conte
 	// Verify the user can access the managed environment
 	managedEnv := ManagedEnvironment{Managedenvironment_id: obj.Managed_environment_id}
 	if err := dbq.CheckedGetManagedEnvironmentById(ctx, &managedEnv, ownerId); err != nil {
-		return fmt.Errorf("on creating Application, unable to retrieve managed environment %s for user %s: %v", obj.Managed_environment_id, ownerId, err)
+		return fmt.Errorf("on creating Application, unable to retrieve managed environment %s for user %s: %w", obj.Managed_environment_id, ownerId, mapDBError(err))
 	}
 
+	obj.Version = 1
+	obj.Created_on = time.Now()
+
 	result, err := dbq.dbConnection.Model(obj).Context(ctx).Insert()
 	if err != nil {
-		return fmt.Errorf("error on inserting application: %v", err)
+		return fmt.Errorf("error on inserting application: %w", mapDBError(err))
 	}
 
 	if result.RowsAffected() != 1 {
@@ -142,7 +186,7 @@ func (dbq *PostgreSQLDatabaseQueries) UnsafeListAllApplications(ctx context.Cont
 	err := dbq.dbConnection.Model(applications).Context(ctx).Select()
 
 	if err != nil {
-		return err
+		return mapDBError(err)
 	}
 
 	return nil
@@ -168,7 +212,7 @@ func (dbq *PostgreSQLDatabaseQueries) CheckedDeleteApplicationById(ctx context.C
 
 	deleteResult, err := dbq.dbConnection.Model(result).WherePK().Context(ctx).Delete()
 	if err != nil {
-		return 0, fmt.Errorf("error on deleting application: %v", err)
+		return 0, fmt.Errorf("error on deleting application: %w", mapDBError(err))
 	}
 
 	return deleteResult.RowsAffected(), nil
@@ -186,7 +230,7 @@ func (dbq *PostgreSQLDatabaseQueries) DeleteApplicationById(ctx context.Context,
 
 	deleteResult, err := dbq.dbConnection.Model(result).WherePK().Context(ctx).Delete()
 	if err != nil {
-		return 0, fmt.Errorf("error on deleting application: %v", err)
+		return 0, fmt.Errorf("error on deleting application: %w", mapDBError(err))
 	}
 
 	return deleteResult.RowsAffected(), nil
@@ -217,9 +261,12 @@ func (dbq *PostgreSQLDatabaseQueries) CreateApplication(ctx context.Context, obj
 		return err
 	}
 
+	obj.Version = 1
+	obj.Created_on = time.Now()
+
 	result, err := dbq.dbConnection.Model(obj).Context(ctx).Insert()
 	if err != nil {
-		return fmt.Errorf("error on inserting application %v", err)
+		return fmt.Errorf("error on inserting application %w", mapDBError(err))
 	}
 	if result.RowsAffected() != 1 {
 		return fmt.Errorf("unexpected number of rows affected: %d", result.RowsAffected())
@@ -242,9 +289,20 @@ func (dbq *PostgreSQLDatabaseQueries) UpdateApplication(ctx context.Context, obj
 		return err
 	}
 
-	result, err := dbq.dbConnection.Model(obj).WherePK().Context(ctx).Update()
+	// The row is only updated if it has not been updated since it was read: the version is incremented on success.
+	readVersion := obj.Version
+	obj.Version = readVersion + 1
+
+	// created_on is only set on creation
+	result, err := dbq.dbConnection.Model(obj).WherePK().Where("version = ?", readVersion).ExcludeColumn("created_on").Context(ctx).Update()
 	if err != nil {
-		return fmt.Errorf("error on updating application %v", err)
+		obj.Version = readVersion
+		return fmt.Errorf("error on updating application %w", mapDBError(err))
+	}
+
+	if result.RowsAffected() == 0 {
+		obj.Version = readVersion
+		return NewVersionConflictError(fmt.Sprintf("application '%s' was updated or deleted since version %d was read", obj.Application_id, readVersion))
 	}
 
 	if result.RowsAffected() != 1 {
@@ -254,3 +312,30 @@ func (dbq *PostgreSQLDatabaseQueries) UpdateApplication(ctx context.Context, obj
 	return nil
 
 }
+
+// ListApplicationsByManagedEnvironmentId returns the Applications that deploy to the ManagedEnvironment.
+func (dbq *PostgreSQLDatabaseQueries) ListApplicationsByManagedEnvironmentId(ctx context.Context, managedEnvironmentId string, applications *[]Application) error {
+
+	if err := validateQueryParamsEntity(applications, dbq); err != nil {
+		return err
+	}
+
+	if err := isEmptyValues("ListApplicationsByManagedEnvironmentId", "managedEnvironmentId", managedEnvironmentId); err != nil {
+		return err
+	}
+
+	var dbResults []Application
+
+	if err := dbq.dbConnection.Model(&dbResults).
+		Where("application.managed_environment_id = ?", managedEnvironmentId).
+		Order("seq_id ASC").
+		Context(ctx).
+		Select(); err != nil {
+
+		return fmt.Errorf("error on retrieving ListApplicationsByManagedEnvironmentId: %w", mapDBError(err))
+	}
+
+	*applications = dbResults
+
+	return nil
+}
@@ -2,7 +2,10 @@ package db
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"strings"
+	"unicode/utf8"
 )
 
 func (dbq *PostgreSQLDatabaseQueries) UnsafeListAllApplicationStates(ctx context.Context, applicationStates *[]ApplicationState) error {
@@ -12,7 +15,7 @@ func (dbq *PostgreSQLDatabaseQueries) UnsafeListAllApplicationStates(ctx context
 	}
 
 	if err := dbq.dbConnection.Model(applicationStates).Context(ctx).Select(); err != nil {
-		return err
+		return mapDBError(err)
 	}
 
 	return nil
@@ -30,7 +33,7 @@ func (dbq *PostgreSQLDatabaseQueries) DeleteApplicationStateById(ctx context.Con
 
 	deleteResult, err := dbq.dbConnection.Model(result).WherePK().Context(ctx).Delete()
 	if err != nil {
-		return 0, fmt.Errorf("error on deleting application state: %v", err)
+		return 0, fmt.Errorf("error on deleting application state: %w", mapDBError(err))
 	}
 
 	return deleteResult.RowsAffected(), nil
@@ -49,10 +52,12 @@ func (dbq *PostgreSQLDatabaseQueries) CreateApplicationState(ctx context.Context
 		return err
 	}
 
+	obj.Version = 1
+
 	// Inserting ApplicationState object
 	result, err := dbq.dbConnection.Model(obj).Context(ctx).Insert()
 	if err != nil {
-		return fmt.Errorf("error on inserting application %v", err)
+		return fmt.Errorf("error on inserting application %w", mapDBError(err))
 	}
 
 	if result.RowsAffected() != 1 {
@@ -74,10 +79,22 @@ func (dbq *PostgreSQLDatabaseQueries) UpdateApplicationState(ctx context.Context
 		return err
 	}
 
+	// The row is only updated if it has not been updated since it was read: the version is incremented on success.
+	readVersion := obj.Version
+	obj.Version = readVersion + 1
+
 	result, err := dbq.dbConnection.Model(obj).Context(ctx).
-		Where("Applicationstate_application_id = ?", obj.Applicationstate_application_id).Update()
+		Where("Applicationstate_application_id = ?", obj.Applicationstate_application_id).
+		Where("version = ?", readVersion).Update()
 	if err != nil {
-		return fmt.Errorf("error on updating application %v", err)
+		obj.Version = readVersion
+		return fmt.Errorf("error on updating application %w", mapDBError(err))
+	}
+
+	if result.RowsAffected() == 0 {
+		obj.Version = readVersion
+		return NewVersionConflictError(fmt.Sprintf("application state '%s' was updated or deleted since version %d was read",
+			obj.Applicationstate_application_id, readVersion))
 	}
 
 	if result.RowsAffected() != 1 {
@@ -104,7 +121,7 @@ func (dbq *PostgreSQLDatabaseQueries) GetApplicationStateById(ctx context.Contex
 		Context(ctx).
 		Select(); err != nil {
 
-		return fmt.Errorf("error on retrieving ApplicationState row: %v", err)
+		return fmt.Errorf("error on retrieving ApplicationState row: %w", mapDBError(err))
 	}
 
 	if len(results) == 0 {
@@ -119,3 +136,68 @@ func (dbq *PostgreSQLDatabaseQueries) GetApplicationStateById(ctx context.Contex
 
 	return nil
 }
+
+// ApplicationStateCondition is a single condition of an Argo CD Application (for example, ComparisonError, InvalidSpecError,
+// SyncError), as stored in the 'conditions' field of ApplicationState.
+type ApplicationStateCondition struct {
+	Type    string `json:"type"`
+	Message string `json:"message,omitempty"`
+}
+
+const (
+	// applicationStateConditionMessageLength is the maximum length of the message of an individual condition: this ensures
+	// that a single verbose condition cannot crowd out the others.
+	applicationStateConditionMessageLength = 1024
+)
+
+// EncodeApplicationStateConditions converts the conditions into the JSON form stored in the 'conditions' field of ApplicationState.
+// Condition messages are truncated, and conditions which would exceed the length of the field are dropped, so that the
+// result is always valid JSON that fits in the field.
+func EncodeApplicationStateConditions(conditions []ApplicationStateCondition) (string, error) {
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+
+	truncatedConditions := []ApplicationStateCondition{}
+
+	res := ""
+
+	for _, condition := range conditions {
+
+		truncatedConditions = append(truncatedConditions, ApplicationStateCondition{
+			Type:    TruncateVarchar(condition.Type, applicationStateConditionMessageLength),
+			Message: TruncateVarchar(condition.Message, applicationStateConditionMessageLength),
+		})
+
+		jsonBytes, err := json.Marshal(truncatedConditions)
+		if err != nil {
+			return "", fmt.Errorf("unable to marshal application state conditions: %v", err)
+		}
+
+		if utf8.RuneCount(jsonBytes) > ApplicationstateConditionsLength {
+			// The remaining conditions do not fit in the field
+			break
+		}
+
+		res = string(jsonBytes)
+	}
+
+	return res, nil
+}
+
+// DecodeApplicationStateConditions converts the 'conditions' field of ApplicationState back into a list of conditions.
+func DecodeApplicationStateConditions(conditions string) ([]ApplicationStateCondition, error) {
+
+	res := []ApplicationStateCondition{}
+
+	if strings.TrimSpace(conditions) == "" {
+		return res, nil
+	}
+
+	if err := json.Unmarshal([]byte(conditions), &res); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal application state conditions: %v", err)
+	}
+
+	return res, nil
+}
@@ -12,7 +12,7 @@ func (dbq *PostgreSQLDatabaseQueries) UnsafeListAllClusterAccess(ctx context.Con
 	}
 
 	if err := dbq.dbConnection.Model(clusterAccess).Context(ctx).Select(); err != nil {
-		return err
+		return mapDBError(err)
 	}
 
 	return nil
@@ -40,7 +40,7 @@ func (dbq *PostgreSQLDatabaseQueries) GetClusterAccessByPrimaryKey(ctx context.C
 		Context(ctx).Select()
 
 	if err != nil {
-		return fmt.Errorf("unable to retrieve ClusterAccess in GetClusterAccessByPrimaryKey: %v", err)
+		return fmt.Errorf("unable to retrieve ClusterAccess in GetClusterAccessByPrimaryKey: %w", mapDBError(err))
 	}
 
 	if len(dbResults) == 0 {
@@ -56,6 +56,32 @@ func (dbq *PostgreSQLDatabaseQueries) GetClusterAccessByPrimaryKey(ctx context.C
 	return nil
 }
 
+// ListClusterAccessByClusterUserId returns the ClusterAccess rows of the ClusterUser.
+func (dbq *PostgreSQLDatabaseQueries) ListClusterAccessByClusterUserId(ctx context.Context, clusterUserId string, clusterAccess *[]ClusterAccess) error {
+
+	if err := validateQueryParamsEntity(clusterAccess, dbq); err != nil {
+		return err
+	}
+
+	if err := isEmptyValues("ListClusterAccessByClusterUserId", "clusterUserId", clusterUserId); err != nil {
+		return err
+	}
+
+	var dbResults []ClusterAccess
+
+	if err := dbq.dbConnection.Model(&dbResults).
+		Where("clusteraccess_user_id = ?", clusterUserId).
+		Order("seq_id ASC").
+		Context(ctx).Select(); err != nil {
+
+		return fmt.Errorf("unable to retrieve ClusterAccess in ListClusterAccessByClusterUserId: %w", mapDBError(err))
+	}
+
+	*clusterAccess = dbResults
+
+	return nil
+}
+
 func (dbq *PostgreSQLDatabaseQueries) CreateClusterAccess(ctx context.Context, obj *ClusterAccess) error {
 
 	if err := validateQueryParams(obj.Clusteraccess_gitops_engine_instance_id, dbq); err != nil {
@@ -72,7 +98,7 @@ func (dbq *PostgreSQLDatabaseQueries) CreateClusterAccess(ctx context.Context, o
 
 	result, err := dbq.dbConnection.Model(obj).Context(ctx).Insert()
 	if err != nil {
-		return fmt.Errorf("error on inserting cluster access: %v", err)
+		return fmt.Errorf("error on inserting cluster access: %w", mapDBError(err))
 	}
 
 	if result.RowsAffected() != 1 {
@@ -105,7 +131,7 @@ func (dbq *PostgreSQLDatabaseQueries) DeleteClusterAccessById(ctx context.Contex
 		Context(ctx).
 		Delete()
 	if err != nil {
-		return 0, fmt.Errorf("error on deleting operation: %v", err)
+		return 0, fmt.Errorf("error on deleting operation: %w", mapDBError(err))
 	}
 
 	return deleteResult.RowsAffected(), nil
@@ -15,7 +15,7 @@ func (dbq *PostgreSQLDatabaseQueries) UnsafeListAllClusterCredentials(ctx contex
 	}
 
 	if err := dbq.dbConnection.Model(clusterCredentials).Context(ctx).Select(); err != nil {
-		return err
+		return mapDBError(err)
 	}
 
 	return nil
@@ -42,9 +42,14 @@ func (dbq *PostgreSQLDatabaseQueries) CreateClusterCredentials(ctx context.Conte
 		obj.Clustercredentials_cred_id = generateUuid()
 	}
 
+	// The primary key is part of the encrypted value, so encryption occurs after it is generated
+	if err := dbq.clusterCredentialsKeyring.encryptClusterCredentials(obj); err != nil {
+		return err
+	}
+
 	result, err := dbq.dbConnection.Model(obj).Context(ctx).Insert()
 	if err != nil {
-		return fmt.Errorf("error on inserting cluster credentials: %v", err)
+		return fmt.Errorf("error on inserting cluster credentials: %w", mapDBError(err))
 	}
 
 	if result.RowsAffected() != 1 {
@@ -65,7 +70,7 @@ func (dbq *PostgreSQLDatabaseQueries) GetClusterCredentialsById(ctx context.Cont
 		Where("clustercredentials_cred_id = ?", clusterCreds.Clustercredentials_cred_id).Context(ctx).
 		Select(); err != nil {
 
-		return fmt.Errorf("error on retrieving ClusterCredentials: %v", err)
+		return fmt.Errorf("error on retrieving ClusterCredentials: %w", mapDBError(err))
 	}
 
 	if len(dbResults) == 0 {
@@ -110,7 +115,7 @@ func (dbq *PostgreSQLDatabaseQueries) CheckedGetClusterCredentialsById(ctx conte
 		Where("cc.clustercredentials_cred_id = ?", clusterCredentials.Clustercredentials_cred_id).
 		Context(ctx).
 		Select(); err != nil {
-		return err
+		return mapDBError(err)
 	}
 
 	if len(dbResults) >= 2 {
@@ -141,7 +146,7 @@ func (dbq *PostgreSQLDatabaseQueries) CheckedListClusterCredentialsByHost(ctx co
 		Context(ctx).
 		Select(); err != nil {
 
-		return err
+		return mapDBError(err)
 	}
 
 	if len(dbResultCredsWithHostnameResults) == 0 {
@@ -187,7 +192,7 @@ func (dbq *PostgreSQLDatabaseQueries) isAccessibleByUser(ctx context.Context, cl
 		Where("me.clustercredentials_id = ?", clusterCredsId).
 		Context(ctx).
 		Select(); err != nil {
-		return false, fmt.Errorf("unable to retrieve managedenvironments: %v", err)
+		return false, fmt.Errorf("unable to retrieve managedenvironments: %w", mapDBError(err))
 	}
 
 	// Determine if any of those manageEnvironments are accessible by the user
@@ -218,7 +223,7 @@ func (dbq *PostgreSQLDatabaseQueries) isAccessibleByUser(ctx context.Context, cl
 		err := dbq.dbConnection.Model(&engineClustersUsingCredential).
 			Where("gitops_engine_cluster.clustercredentials_id = ?", clusterCredsId).Context(ctx).Select()
 		if err != nil {
-			return false, fmt.Errorf("unable to retrieve GitopsEngineClusters that reference credential: %v", err)
+			return false, fmt.Errorf("unable to retrieve GitopsEngineClusters that reference credential: %w", mapDBError(err))
 		}
 
 		// For each engine cluster using this credential, locate an engine instance that is accessible by the owner
@@ -257,8 +262,66 @@ func (dbq *PostgreSQLDatabaseQueries) DeleteClusterCredentialsById(ctx context.C
 
 	deleteResult, err := dbq.dbConnection.Model(result).WherePK().Context(ctx).Delete()
 	if err != nil {
-		return 0, fmt.Errorf("error on deleting operation: %v", err)
+		return 0, fmt.Errorf("error on deleting operation: %w", mapDBError(err))
 	}
 
 	return deleteResult.RowsAffected(), nil
 }
+
+func (dbq *PostgreSQLDatabaseQueries) DecryptClusterCredentials(clusterCreds *ClusterCredentials) error {
+
+	if clusterCreds == nil {
+		return fmt.Errorf("cluster credentials are nil")
+	}
+
+	return dbq.clusterCredentialsKeyring.decryptClusterCredentials(clusterCreds)
+}
+
+func (dbq *PostgreSQLDatabaseQueries) ReencryptAllClusterCredentials(ctx context.Context) (int, error) {
+
+	if err := validateQueryParamsNoPK(dbq); err != nil {
+		return 0, err
+	}
+
+	if dbq.clusterCredentialsKeyring == nil {
+		return 0, fmt.Errorf("unable to re-encrypt cluster credentials: %s is not configured", EnvClusterCredentialsEncryptionKeysDir)
+	}
+
+	var clusterCredentials []ClusterCredentials
+	if err := dbq.dbConnection.Model(&clusterCredentials).Order("seq_id ASC").Context(ctx).Select(); err != nil {
+		return 0, fmt.Errorf("error on retrieving cluster credentials: %w", mapDBError(err))
+	}
+
+	updated := 0
+
+	for idx := range clusterCredentials {
+
+		clusterCreds := clusterCredentials[idx]
+
+		if !dbq.clusterCredentialsKeyring.needsReencryption(&clusterCreds) {
+			continue
+		}
+
+		if err := dbq.clusterCredentialsKeyring.decryptClusterCredentials(&clusterCreds); err != nil {
+			return updated, err
+		}
+
+		if err := dbq.clusterCredentialsKeyring.encryptClusterCredentials(&clusterCreds); err != nil {
+			return updated, err
+		}
+
+		result, err := dbq.dbConnection.Model(&clusterCreds).
+			Column(clusterCredentialsKubeConfigColumn, clusterCredentialsServiceAccountTokenColumn).
+			WherePK().Context(ctx).Update()
+		if err != nil {
+			return updated, fmt.Errorf("error on updating cluster credentials '%s': %w", clusterCreds.Clustercredentials_cred_id, mapDBError(err))
+		}
+
+		// The row may have been deleted since it was read
+		if result.RowsAffected() == 1 {
+			updated++
+		}
+	}
+
+	return updated, nil
+}
@@ -0,0 +1,394 @@
+package db
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// The secret fields of ClusterCredentials (kube_config and serviceaccount_bearer_token) are stored using envelope
+// encryption:
+// - Each value is encrypted (AES-256-GCM) with a random data key, which is generated for that value.
+// - The data key is then encrypted (wrapped) with a key encryption key from the ClusterCredentialsKeyring.
+// - The ID of the key encryption key, the wrapped data key, and the ciphertext are stored together in the column, as:
+//   'enc:v1:(key id):(base64 wrapped data key):(base64 ciphertext)'
+//
+// The key encryption keys are read from a directory (usually a mounted Secret), see 'LoadClusterCredentialsKeyring'.
+//
+// Values are encrypted by CreateClusterCredentials, and are only decrypted by an explicit call to
+// DecryptClusterCredentials: code that does not need to connect to the cluster never sees the plaintext credentials.
+//
+// Key rotation:
+// 1) Add the new key to the Secret, and restart the components, so that the new key can be used for decryption.
+// 2) Update 'active-key-id' to the new key, and restart the components: new values are encrypted with the new key.
+// 3) Run 'gitops-credentials-reencrypt' to re-encrypt the existing values with the new key.
+// 4) Remove the old key from the Secret.
+
+const (
+	// EnvClusterCredentialsEncryptionKeysDir is the environment variable containing the path of the directory that
+	// contains the key encryption keys. If it is not set, ClusterCredentials are stored unencrypted.
+	EnvClusterCredentialsEncryptionKeysDir = "CLUSTER_CREDENTIALS_ENCRYPTION_KEYS_DIR"
+
+	// EnvClusterCredentialsEncryptionDisabled is the environment variable which, when set to 'true', allows
+	// ClusterCredentials to be stored unencrypted when EnvClusterCredentialsEncryptionKeysDir is set, but the directory
+	// is missing or contains no keys. Otherwise, this is an error.
+	EnvClusterCredentialsEncryptionDisabled = "CLUSTER_CREDENTIALS_ENCRYPTION_DISABLED"
+
+	// ClusterCredentialsActiveKeyIDFile is the name of the file (within the keys directory) containing the ID of the
+	// key that is used to encrypt new values.
+	ClusterCredentialsActiveKeyIDFile = "active-key-id"
+
+	clusterCredentialsCiphertextPrefix = "enc:v1:"
+
+	// AES-256
+	clusterCredentialsKeyLength = 32
+
+	clusterCredentialsKubeConfigColumn          = "kube_config"
+	clusterCredentialsServiceAccountTokenColumn = "serviceaccount_bearer_token"
+)
+
+// Key IDs are file names within a mounted Secret, and are stored in the column, so they may not contain ':'.
+var clusterCredentialsKeyIDRegex = regexp.MustCompile(`^[-._a-zA-Z0-9]{1,64}$`)
+
+// ClusterCredentialsKeyring contains the key encryption keys of ClusterCredentials: values are encrypted with the
+// active key, and may be decrypted with any key of the keyring.
+type ClusterCredentialsKeyring struct {
+	activeKeyID string
+	keys        map[string][]byte
+}
+
+// NewClusterCredentialsKeyring returns a keyring containing the given keys (key ID -> 32 byte key), which encrypts
+// new values with the key 'activeKeyID'.
+func NewClusterCredentialsKeyring(activeKeyID string, keys map[string][]byte) (*ClusterCredentialsKeyring, error) {
+
+	keyring := &ClusterCredentialsKeyring{
+		activeKeyID: activeKeyID,
+		keys:        map[string][]byte{},
+	}
+
+	for keyID, key := range keys {
+
+		if !clusterCredentialsKeyIDRegex.MatchString(keyID) {
+			return nil, fmt.Errorf("invalid cluster credentials encryption key id: '%s'", keyID)
+		}
+
+		if len(key) != clusterCredentialsKeyLength {
+			return nil, fmt.Errorf("cluster credentials encryption key '%s' must be %d bytes, but was %d bytes",
+				keyID, clusterCredentialsKeyLength, len(key))
+		}
+
+		keyring.keys[keyID] = append([]byte{}, key...)
+	}
+
+	if _, exists := keyring.keys[activeKeyID]; !exists {
+		return nil, fmt.Errorf("active cluster credentials encryption key '%s' was not found", activeKeyID)
+	}
+
+	return keyring, nil
+}
+
+// LoadClusterCredentialsKeyring reads a keyring from a directory, usually a mounted Secret:
+// - The 'active-key-id' file contains the ID of the key used to encrypt new values.
+// - Every other file is a key: the file name is the key ID, and the contents are the base64-encoded 32 byte key.
+func LoadClusterCredentialsKeyring(dir string) (*ClusterCredentialsKeyring, error) {
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read cluster credentials encryption keys directory '%s': %v", dir, err)
+	}
+
+	activeKeyID := ""
+	keys := map[string][]byte{}
+
+	for _, entry := range entries {
+
+		// Mounted Secrets contain hidden directories (such as '..data') which the key files link to
+		if strings.HasPrefix(entry.Name(), ".") || entry.IsDir() {
+			continue
+		}
+
+		contents, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("unable to read cluster credentials encryption key file '%s': %v", entry.Name(), err)
+		}
+
+		if entry.Name() == ClusterCredentialsActiveKeyIDFile {
+			activeKeyID = strings.TrimSpace(string(contents))
+			continue
+		}
+
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(contents)))
+		if err != nil {
+			return nil, fmt.Errorf("cluster credentials encryption key '%s' is not valid base64: %v", entry.Name(), err)
+		}
+		keys[entry.Name()] = key
+	}
+
+	if activeKeyID == "" {
+		return nil, fmt.Errorf("cluster credentials encryption keys directory '%s' does not contain an '%s' file",
+			dir, ClusterCredentialsActiveKeyIDFile)
+	}
+
+	return NewClusterCredentialsKeyring(activeKeyID, keys)
+}
+
+// loadClusterCredentialsKeyringFromEnv returns the keyring from the directory configured by
+// EnvClusterCredentialsEncryptionKeysDir, or nil if it is not configured.
+//
+// If the directory is configured, but does not exist or contains no files (for example, because the Secret has not
+// been created), an error is returned, rather than silently storing credentials unencrypted: this is only allowed
+// if EnvClusterCredentialsEncryptionDisabled is 'true'.
+func loadClusterCredentialsKeyringFromEnv() (*ClusterCredentialsKeyring, error) {
+
+	dir := strings.TrimSpace(os.Getenv(EnvClusterCredentialsEncryptionKeysDir))
+	if dir == "" {
+		return nil, nil
+	}
+
+	encryptionDisabled := strings.EqualFold(strings.TrimSpace(os.Getenv(EnvClusterCredentialsEncryptionDisabled)), "true")
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			if encryptionDisabled {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("cluster credentials encryption keys directory '%s' does not exist: set %s to 'true' "+
+				"to store cluster credentials unencrypted", dir, EnvClusterCredentialsEncryptionDisabled)
+		}
+		return nil, fmt.Errorf("unable to read cluster credentials encryption keys directory '%s': %v", dir, err)
+	}
+
+	hasFiles := false
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name(), ".") && !entry.IsDir() {
+			hasFiles = true
+			break
+		}
+	}
+	if !hasFiles {
+		if encryptionDisabled {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("cluster credentials encryption keys directory '%s' contains no keys: set %s to 'true' "+
+			"to store cluster credentials unencrypted", dir, EnvClusterCredentialsEncryptionDisabled)
+	}
+
+	return LoadClusterCredentialsKeyring(dir)
+}
+
+// ActiveKeyID returns the ID of the key that is used to encrypt new values.
+func (keyring *ClusterCredentialsKeyring) ActiveKeyID() string {
+	return keyring.activeKeyID
+}
+
+// encryptClusterCredentials encrypts the unencrypted secret fields of 'obj' with the active key. The keyring may be
+// nil, in which case encryption is disabled and the fields are unchanged.
+func (keyring *ClusterCredentialsKeyring) encryptClusterCredentials(obj *ClusterCredentials) error {
+
+	if keyring == nil {
+		return nil
+	}
+
+	for column, field := range clusterCredentialsSecretFields(obj) {
+
+		if *field == "" || isClusterCredentialsValueEncrypted(*field) {
+			continue
+		}
+
+		ciphertext, err := keyring.encryptValue(*field, clusterCredentialsAdditionalData(obj.Clustercredentials_cred_id, column))
+		if err != nil {
+			return fmt.Errorf("unable to encrypt %s of cluster credentials '%s': %v", column, obj.Clustercredentials_cred_id, err)
+		}
+		*field = ciphertext
+	}
+
+	return nil
+}
+
+// decryptClusterCredentials decrypts the encrypted secret fields of 'obj'. Unencrypted fields (for example, from before
+// encryption was enabled) are unchanged.
+func (keyring *ClusterCredentialsKeyring) decryptClusterCredentials(obj *ClusterCredentials) error {
+
+	for column, field := range clusterCredentialsSecretFields(obj) {
+
+		if !isClusterCredentialsValueEncrypted(*field) {
+			continue
+		}
+
+		if keyring == nil {
+			return fmt.Errorf("%s of cluster credentials '%s' is encrypted, but %s is not configured",
+				column, obj.Clustercredentials_cred_id, EnvClusterCredentialsEncryptionKeysDir)
+		}
+
+		plaintext, err := keyring.decryptValue(*field, clusterCredentialsAdditionalData(obj.Clustercredentials_cred_id, column))
+		if err != nil {
+			return fmt.Errorf("unable to decrypt %s of cluster credentials '%s': %v", column, obj.Clustercredentials_cred_id, err)
+		}
+		*field = plaintext
+	}
+
+	return nil
+}
+
+// needsReencryption returns true if a secret field of 'obj' is not encrypted with the active key.
+func (keyring *ClusterCredentialsKeyring) needsReencryption(obj *ClusterCredentials) bool {
+
+	if keyring == nil {
+		return false
+	}
+
+	for _, field := range clusterCredentialsSecretFields(obj) {
+
+		if *field == "" {
+			continue
+		}
+
+		if keyID, _, _, err := parseClusterCredentialsValue(*field); err != nil || keyID != keyring.activeKeyID {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (keyring *ClusterCredentialsKeyring) encryptValue(plaintext string, additionalData []byte) (string, error) {
+
+	dataKey := make([]byte, clusterCredentialsKeyLength)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", fmt.Errorf("unable to generate data key: %v", err)
+	}
+
+	ciphertext, err := sealAESGCM(dataKey, []byte(plaintext), additionalData)
+	if err != nil {
+		return "", err
+	}
+
+	// The data key is bound to the key ID, so that a wrapped data key can't be used with a different key
+	wrappedDataKey, err := sealAESGCM(keyring.keys[keyring.activeKeyID], dataKey, []byte(keyring.activeKeyID))
+	if err != nil {
+		return "", err
+	}
+
+	return clusterCredentialsCiphertextPrefix + keyring.activeKeyID + ":" +
+		base64.StdEncoding.EncodeToString(wrappedDataKey) + ":" + base64.StdEncoding.EncodeToString(ciphertext), nil
+}
+
+func (keyring *ClusterCredentialsKeyring) decryptValue(value string, additionalData []byte) (string, error) {
+
+	keyID, wrappedDataKey, ciphertext, err := parseClusterCredentialsValue(value)
+	if err != nil {
+		return "", err
+	}
+
+	key, exists := keyring.keys[keyID]
+	if !exists {
+		return "", fmt.Errorf("encryption key '%s' was not found", keyID)
+	}
+
+	dataKey, err := openAESGCM(key, wrappedDataKey, []byte(keyID))
+	if err != nil {
+		return "", fmt.Errorf("unable to unwrap data key: %v", err)
+	}
+
+	plaintext, err := openAESGCM(dataKey, ciphertext, additionalData)
+	if err != nil {
+		return "", err
+	}
+
+	return string(plaintext), nil
+}
+
+// clusterCredentialsSecretFields returns the encrypted fields of 'obj', by column name.
+func clusterCredentialsSecretFields(obj *ClusterCredentials) map[string]*string {
+	return map[string]*string{
+		clusterCredentialsKubeConfigColumn:          &obj.Kube_config,
+		clusterCredentialsServiceAccountTokenColumn: &obj.Serviceaccount_bearer_token,
+	}
+}
+
+// clusterCredentialsAdditionalData binds a ciphertext to its row and column, so that it can't be copied to another row.
+func clusterCredentialsAdditionalData(clusterCredentialsID string, column string) []byte {
+	return []byte(clusterCredentialsID + "/" + column)
+}
+
+func isClusterCredentialsValueEncrypted(value string) bool {
+	return strings.HasPrefix(value, clusterCredentialsCiphertextPrefix)
+}
+
+// parseClusterCredentialsValue returns the key ID, wrapped data key, and ciphertext of an encrypted value.
+func parseClusterCredentialsValue(value string) (string, []byte, []byte, error) {
+
+	if !isClusterCredentialsValueEncrypted(value) {
+		return "", nil, nil, fmt.Errorf("value is not encrypted")
+	}
+
+	parts := strings.Split(strings.TrimPrefix(value, clusterCredentialsCiphertextPrefix), ":")
+	if len(parts) != 3 {
+		return "", nil, nil, fmt.Errorf("encrypted value has an unexpected format")
+	}
+
+	wrappedDataKey, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("wrapped data key is not valid base64: %v", err)
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("ciphertext is not valid base64: %v", err)
+	}
+
+	return parts[0], wrappedDataKey, ciphertext, nil
+}
+
+// sealAESGCM encrypts 'plaintext' with AES-GCM, and returns the nonce followed by the ciphertext.
+func sealAESGCM(key []byte, plaintext []byte, additionalData []byte) ([]byte, error) {
+
+	aead, err := newAESGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("unable to generate nonce: %v", err)
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// openAESGCM decrypts a value returned by sealAESGCM.
+func openAESGCM(key []byte, sealed []byte, additionalData []byte) ([]byte, error) {
+
+	aead, err := newAESGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("ciphertext is too short")
+	}
+
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], additionalData)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt: %v", err)
+	}
+
+	return plaintext, nil
+}
+
+func newAESGCM(key []byte) (cipher.AEAD, error) {
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create cipher: %v", err)
+	}
+
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,258 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestKeyring(t *testing.T, activeKeyID string, keyIDs ...string) *ClusterCredentialsKeyring {
+
+	keys := map[string][]byte{}
+	for idx, keyID := range keyIDs {
+		keys[keyID] = bytes.Repeat([]byte{byte(idx + 1)}, clusterCredentialsKeyLength)
+	}
+
+	keyring, err := NewClusterCredentialsKeyring(activeKeyID, keys)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	return keyring
+}
+
+// setClusterCredentialsKeyring replaces the keyring of the queries, which is otherwise read from the environment.
+func setClusterCredentialsKeyring(t *testing.T, dbq AllDatabaseQueries, keyring *ClusterCredentialsKeyring) {
+
+	switch queries := dbq.(type) {
+	case *PostgreSQLDatabaseQueries:
+		queries.clusterCredentialsKeyring = keyring
+	case *InMemoryDatabaseQueries:
+		queries.clusterCredentialsKeyring = keyring
+	default:
+		t.Fatalf("unexpected database queries type: %T", dbq)
+	}
+}
+
+func TestLoadClusterCredentialsKeyring(t *testing.T) {
+
+	writeFiles := func(t *testing.T, files map[string]string) string {
+		dir := t.TempDir()
+		for name, contents := range files {
+			assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(contents), 0600))
+		}
+		return dir
+	}
+
+	validKey := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, clusterCredentialsKeyLength))
+
+	t.Run("Keys are loaded from the directory", func(t *testing.T) {
+		dir := writeFiles(t, map[string]string{
+			"key-1":                           validKey + "\n",
+			"key-2":                           validKey,
+			ClusterCredentialsActiveKeyIDFile: "key-2\n",
+		})
+		// Mounted Secrets contain hidden files, which should be ignored
+		assert.NoError(t, os.Mkdir(filepath.Join(dir, "..data"), 0700))
+
+		keyring, err := LoadClusterCredentialsKeyring(dir)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "key-2", keyring.ActiveKeyID())
+			assert.Len(t, keyring.keys, 2)
+		}
+	})
+
+	t.Run("The active key must exist", func(t *testing.T) {
+		_, err := LoadClusterCredentialsKeyring(writeFiles(t, map[string]string{
+			"key-1":                           validKey,
+			ClusterCredentialsActiveKeyIDFile: "key-2",
+		}))
+		assert.Error(t, err)
+
+		_, err = LoadClusterCredentialsKeyring(writeFiles(t, map[string]string{"key-1": validKey}))
+		assert.Error(t, err)
+	})
+
+	t.Run("Keys must be 32 bytes", func(t *testing.T) {
+		_, err := LoadClusterCredentialsKeyring(writeFiles(t, map[string]string{
+			"key-1":                           base64.StdEncoding.EncodeToString([]byte("too-short")),
+			ClusterCredentialsActiveKeyIDFile: "key-1",
+		}))
+		assert.Error(t, err)
+	})
+
+	t.Run("Keys are not read if the environment variable is not set", func(t *testing.T) {
+		t.Setenv(EnvClusterCredentialsEncryptionKeysDir, "")
+		keyring, err := loadClusterCredentialsKeyringFromEnv()
+		assert.NoError(t, err)
+		assert.Nil(t, keyring)
+	})
+
+	t.Run("A missing or empty directory is an error, unless encryption is explicitly disabled", func(t *testing.T) {
+		missingDir := filepath.Join(t.TempDir(), "does-not-exist")
+		emptyDir := writeFiles(t, map[string]string{})
+		assert.NoError(t, os.Mkdir(filepath.Join(emptyDir, "..data"), 0700))
+
+		for _, dir := range []string{missingDir, emptyDir} {
+			t.Setenv(EnvClusterCredentialsEncryptionKeysDir, dir)
+
+			t.Setenv(EnvClusterCredentialsEncryptionDisabled, "")
+			keyring, err := loadClusterCredentialsKeyringFromEnv()
+			assert.Error(t, err)
+			assert.Nil(t, keyring)
+
+			t.Setenv(EnvClusterCredentialsEncryptionDisabled, "true")
+			keyring, err = loadClusterCredentialsKeyringFromEnv()
+			assert.NoError(t, err)
+			assert.Nil(t, keyring)
+		}
+
+		// A directory with keys, but without the active key ID, is an error even if encryption is disabled
+		t.Setenv(EnvClusterCredentialsEncryptionKeysDir, writeFiles(t, map[string]string{"key-1": validKey}))
+		_, err := loadClusterCredentialsKeyringFromEnv()
+		assert.Error(t, err)
+	})
+}
+
+func TestClusterCredentialsEncryption(t *testing.T) {
+
+	newClusterCredentials := func() ClusterCredentials {
+		return ClusterCredentials{
+			Clustercredentials_cred_id:  "test-encrypted-creds",
+			Host:                        "https://api.example.com:6443",
+			Kube_config:                 "apiVersion: v1\nkind: Config\n",
+			Kube_config_context:         "my-context",
+			Serviceaccount_bearer_token: "my-token",
+			Serviceaccount_ns:           "my-namespace",
+		}
+	}
+
+	t.Run("Encrypted values can be decrypted by any key of the keyring", func(t *testing.T) {
+
+		oldKeyring := newTestKeyring(t, "key-1", "key-1")
+		newKeyring := newTestKeyring(t, "key-2", "key-1", "key-2")
+
+		clusterCreds := newClusterCredentials()
+		assert.NoError(t, oldKeyring.encryptClusterCredentials(&clusterCreds))
+
+		assert.True(t, strings.HasPrefix(clusterCreds.Kube_config, "enc:v1:key-1:"))
+		assert.True(t, strings.HasPrefix(clusterCreds.Serviceaccount_bearer_token, "enc:v1:key-1:"))
+		assert.NotContains(t, clusterCreds.Kube_config, newClusterCredentials().Kube_config)
+		assert.Equal(t, newClusterCredentials().Host, clusterCreds.Host)
+
+		// Encrypting again does not change the values
+		encrypted := clusterCreds
+		assert.NoError(t, newKeyring.encryptClusterCredentials(&encrypted))
+		assert.Equal(t, clusterCreds, encrypted)
+
+		assert.False(t, oldKeyring.needsReencryption(&clusterCreds))
+		assert.True(t, newKeyring.needsReencryption(&clusterCreds))
+
+		assert.NoError(t, newKeyring.decryptClusterCredentials(&clusterCreds))
+		assert.Equal(t, newClusterCredentials(), clusterCreds)
+	})
+
+	t.Run("Values can't be decrypted without their key", func(t *testing.T) {
+
+		clusterCreds := newClusterCredentials()
+		assert.NoError(t, newTestKeyring(t, "key-1", "key-1").encryptClusterCredentials(&clusterCreds))
+
+		assert.Error(t, newTestKeyring(t, "key-2", "key-2").decryptClusterCredentials(&clusterCreds))
+
+		var nilKeyring *ClusterCredentialsKeyring
+		assert.Error(t, nilKeyring.decryptClusterCredentials(&clusterCreds))
+	})
+
+	t.Run("Values can't be moved to another row", func(t *testing.T) {
+
+		keyring := newTestKeyring(t, "key-1", "key-1")
+
+		clusterCreds := newClusterCredentials()
+		assert.NoError(t, keyring.encryptClusterCredentials(&clusterCreds))
+
+		clusterCreds.Clustercredentials_cred_id = "test-other-creds"
+		assert.Error(t, keyring.decryptClusterCredentials(&clusterCreds))
+	})
+
+	t.Run("Unencrypted values are unchanged", func(t *testing.T) {
+
+		clusterCreds := newClusterCredentials()
+
+		var nilKeyring *ClusterCredentialsKeyring
+		assert.NoError(t, nilKeyring.encryptClusterCredentials(&clusterCreds))
+		assert.NoError(t, nilKeyring.decryptClusterCredentials(&clusterCreds))
+		assert.NoError(t, newTestKeyring(t, "key-1", "key-1").decryptClusterCredentials(&clusterCreds))
+		assert.Equal(t, newClusterCredentials(), clusterCreds)
+	})
+}
+
+func testConformanceClusterCredentialsEncryption(t *testing.T, dbq AllDatabaseQueries) {
+
+	ctx := context.Background()
+
+	unencryptedCreds := ClusterCredentials{
+		Clustercredentials_cred_id:  "test-unencrypted-creds",
+		Host:                        "host",
+		Kube_config:                 "kube_config",
+		Serviceaccount_bearer_token: "serviceaccount_bearer_token",
+	}
+	if !assert.NoError(t, dbq.CreateClusterCredentials(ctx, &unencryptedCreds)) {
+		return
+	}
+	defer func() {
+		_, err := dbq.DeleteClusterCredentialsById(ctx, unencryptedCreds.Clustercredentials_cred_id)
+		assert.NoError(t, err)
+	}()
+
+	setClusterCredentialsKeyring(t, dbq, newTestKeyring(t, "key-1", "key-1"))
+
+	encryptedCreds := unencryptedCreds
+	encryptedCreds.Clustercredentials_cred_id = "test-encrypted-creds"
+	encryptedCreds.SeqID = 0
+	if !assert.NoError(t, dbq.CreateClusterCredentials(ctx, &encryptedCreds)) {
+		return
+	}
+	defer func() {
+		_, err := dbq.DeleteClusterCredentialsById(ctx, encryptedCreds.Clustercredentials_cred_id)
+		assert.NoError(t, err)
+	}()
+
+	// The secret fields are stored encrypted, and are only decrypted on request
+	result := ClusterCredentials{Clustercredentials_cred_id: encryptedCreds.Clustercredentials_cred_id}
+	assert.NoError(t, dbq.GetClusterCredentialsById(ctx, &result))
+	assert.Equal(t, encryptedCreds, result)
+	assert.True(t, strings.HasPrefix(result.Kube_config, "enc:v1:key-1:"))
+	assert.True(t, strings.HasPrefix(result.Serviceaccount_bearer_token, "enc:v1:key-1:"))
+
+	assert.NoError(t, dbq.DecryptClusterCredentials(&result))
+	assert.Equal(t, unencryptedCreds.Kube_config, result.Kube_config)
+	assert.Equal(t, unencryptedCreds.Serviceaccount_bearer_token, result.Serviceaccount_bearer_token)
+
+	// After the key is rotated, both the unencrypted and the old values are re-encrypted with the new key
+	setClusterCredentialsKeyring(t, dbq, newTestKeyring(t, "key-2", "key-1", "key-2"))
+
+	updated, err := dbq.ReencryptAllClusterCredentials(ctx)
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, updated, 2)
+
+	updated, err = dbq.ReencryptAllClusterCredentials(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, updated)
+
+	for _, id := range []string{unencryptedCreds.Clustercredentials_cred_id, encryptedCreds.Clustercredentials_cred_id} {
+
+		result := ClusterCredentials{Clustercredentials_cred_id: id}
+		assert.NoError(t, dbq.GetClusterCredentialsById(ctx, &result))
+		assert.True(t, strings.HasPrefix(result.Kube_config, "enc:v1:key-2:"))
+
+		assert.NoError(t, dbq.DecryptClusterCredentials(&result))
+		assert.Equal(t, unencryptedCreds.Kube_config, result.Kube_config)
+		assert.Equal(t, unencryptedCreds.Serviceaccount_bearer_token, result.Serviceaccount_bearer_token)
+	}
+}
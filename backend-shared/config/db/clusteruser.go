@@ -12,7 +12,7 @@ func (dbq *PostgreSQLDatabaseQueries) UnsafeListAllClusterUsers(ctx context.Cont
 	}
 
 	if err := dbq.dbConnection.Model(clusterUsers).Context(ctx).Select(); err != nil {
-		return err
+		return mapDBError(err)
 	}
 
 	return nil
@@ -32,7 +32,7 @@ func (dbq *PostgreSQLDatabaseQueries) DeleteClusterUserById(ctx context.Context,
 		Delete()
 
 	if err != nil {
-		return 0, fmt.Errorf("error on deleting cluster_user: %v", err)
+		return 0, fmt.Errorf("error on deleting cluster_user: %w", mapDBError(err))
 	}
 
 	return deleteResult.RowsAffected(), nil
@@ -64,7 +64,7 @@ func (dbq *PostgreSQLDatabaseQueries) CreateClusterUser(ctx context.Context, obj
 
 	result, err := dbq.dbConnection.Model(obj).Context(ctx).Insert()
 	if err != nil {
-		return fmt.Errorf("error on inserting cluster user: %v", err)
+		return fmt.Errorf("error on inserting cluster user: %w", mapDBError(err))
 	}
 
 	if result.RowsAffected() != 1 {
@@ -93,7 +93,7 @@ func (dbq *PostgreSQLDatabaseQueries) GetClusterUserByUsername(ctx context.Conte
 		Context(ctx).
 		Select(); err != nil {
 
-		return fmt.Errorf("error on retrieving GetClusterUserByUsername: %v", err)
+		return fmt.Errorf("error on retrieving GetClusterUserByUsername: %w", mapDBError(err))
 	}
 
 	if len(dbResults) >= 2 {
@@ -126,7 +126,7 @@ func (dbq *PostgreSQLDatabaseQueries) GetClusterUserById(ctx context.Context, cl
 		Context(ctx).
 		Select(); err != nil {
 
-		return fmt.Errorf("error on retrieving GetClusterUserById: %v", err)
+		return fmt.Errorf("error on retrieving GetClusterUserById: %w", mapDBError(err))
 	}
 
 	if len(dbResults) >= 2 {
@@ -0,0 +1,636 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// The conformance tests verify that InMemoryDatabaseQueries behaves as PostgreSQLDatabaseQueries: each test is run
+// against both implementations.
+//
+// As with the other database tests, rows created by a conformance test should have a primary key starting with 'test-',
+// and rows which are not removed by 'testSetup' should be deleted by the test.
+
+var conformanceTests = []struct {
+	name string
+	test func(t *testing.T, dbq AllDatabaseQueries)
+}{
+	{name: "Checked queries enforce ownership", test: testConformanceOwnership},
+	{name: "Get queries return not found errors", test: testConformanceNotFound},
+	{name: "Constraints are enforced", test: testConformanceConstraints},
+	{name: "Updates are rejected on version conflict", test: testConformanceOptimisticConcurrency},
+	{name: "Transactions are rolled back on error", test: testConformanceTransactions},
+	{name: "Operations lifecycle", test: testConformanceOperations},
+	{name: "Cluster credentials are encrypted", test: testConformanceClusterCredentialsEncryption},
+	{name: "Workspace teardown queries", test: testConformanceWorkspaceTeardownQueries},
+}
+
+func TestPostgreSQLConformance(t *testing.T) {
+
+	for _, c := range conformanceTests {
+		t.Run(c.name, func(t *testing.T) {
+			testSetup(t)
+			defer testTeardown(t)
+
+			dbq, err := NewUnsafePostgresDBQueries(true, true)
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer dbq.CloseDatabase()
+
+			c.test(t, dbq)
+		})
+	}
+}
+
+func TestInMemoryConformance(t *testing.T) {
+
+	for _, c := range conformanceTests {
+		t.Run(c.name, func(t *testing.T) {
+
+			dbq := NewUnsafeInMemoryDBQueries(true)
+			defer dbq.CloseDatabase()
+
+			// As with 'testSetup', the test user exists before the test is run
+			clusterUser := *testClusterUser
+			if !assert.NoError(t, dbq.CreateClusterUser(context.Background(), &clusterUser)) {
+				return
+			}
+
+			c.test(t, dbq)
+		})
+	}
+}
+
+func TestInMemoryDBQueriesAreSafeByDefault(t *testing.T) {
+
+	ctx := context.Background()
+
+	dbq := NewInMemoryDBQueries()
+
+	// Primary keys are generated, and may not be specified by the caller
+	err := dbq.CreateClusterUser(ctx, &ClusterUser{Clusteruser_id: "test-user", User_name: "test-user"})
+	assert.EqualError(t, err, "primary key should be empty")
+
+	clusterUser := ClusterUser{User_name: "test-user"}
+	assert.NoError(t, dbq.CreateClusterUser(ctx, &clusterUser))
+	assert.NotEmpty(t, clusterUser.Clusteruser_id)
+
+	// Unsafe queries are not allowed
+	_, err = dbq.DeleteManagedEnvironmentById(ctx, "test-managed-env")
+	assert.EqualError(t, err, "unsafe operation is not allowed in this context")
+}
+
+func testConformanceOwnership(t *testing.T, dbq AllDatabaseQueries) {
+
+	ctx := context.Background()
+
+	clusterCredentials, managedEnvironment, engineCluster, engineInstance, clusterAccess, err := createSampleData(t, dbq)
+	if !assert.NoError(t, err) {
+		return
+	}
+	owner := clusterAccess.Clusteraccess_user_id
+
+	otherUser := &ClusterUser{Clusteruser_id: "test-other-user", User_name: "test-other-user"}
+	if !assert.NoError(t, dbq.CreateClusterUser(ctx, otherUser)) {
+		return
+	}
+
+	// Only a user with access to the managed environment may create an Application that targets it
+	application := &Application{
+		Application_id:          "test-ownership-application",
+		Name:                    "my-application",
+		Spec_field:              "{}",
+		Engine_instance_inst_id: engineInstance.Gitopsengineinstance_id,
+		Managed_environment_id:  managedEnvironment.Managedenvironment_id,
+	}
+	err = dbq.CheckedCreateApplication(ctx, application, otherUser.Clusteruser_id)
+	assert.True(t, IsResultNotFoundError(err), "%v", err)
+
+	if !assert.NoError(t, dbq.CheckedCreateApplication(ctx, application, owner)) {
+		return
+	}
+
+	assert.NoError(t, dbq.CheckedGetApplicationById(ctx, &Application{Application_id: application.Application_id}, owner))
+	err = dbq.CheckedGetApplicationById(ctx, &Application{Application_id: application.Application_id}, otherUser.Clusteruser_id)
+	assert.True(t, IsAccessDeniedError(err), "%v", err)
+
+	assert.NoError(t, dbq.CheckedGetManagedEnvironmentById(ctx, &ManagedEnvironment{Managedenvironment_id: managedEnvironment.Managedenvironment_id}, owner))
+	err = dbq.CheckedGetManagedEnvironmentById(ctx, &ManagedEnvironment{Managedenvironment_id: managedEnvironment.Managedenvironment_id}, otherUser.Clusteruser_id)
+	assert.True(t, IsResultNotFoundError(err), "%v", err)
+
+	assert.NoError(t, dbq.CheckedGetGitopsEngineInstanceById(ctx, &GitopsEngineInstance{Gitopsengineinstance_id: engineInstance.Gitopsengineinstance_id}, owner))
+	err = dbq.CheckedGetGitopsEngineInstanceById(ctx, &GitopsEngineInstance{Gitopsengineinstance_id: engineInstance.Gitopsengineinstance_id}, otherUser.Clusteruser_id)
+	assert.True(t, IsResultNotFoundError(err), "%v", err)
+
+	assert.NoError(t, dbq.CheckedGetGitopsEngineClusterById(ctx, &GitopsEngineCluster{Gitopsenginecluster_id: engineCluster.Gitopsenginecluster_id}, owner))
+	err = dbq.CheckedGetGitopsEngineClusterById(ctx, &GitopsEngineCluster{Gitopsenginecluster_id: engineCluster.Gitopsenginecluster_id}, otherUser.Clusteruser_id)
+	assert.True(t, IsResultNotFoundError(err), "%v", err)
+
+	retrievedCredentials := ClusterCredentials{Clustercredentials_cred_id: clusterCredentials.Clustercredentials_cred_id}
+	assert.NoError(t, dbq.CheckedGetClusterCredentialsById(ctx, &retrievedCredentials, owner))
+	assert.Equal(t, clusterCredentials.Host, retrievedCredentials.Host)
+	err = dbq.CheckedGetClusterCredentialsById(ctx, &ClusterCredentials{Clustercredentials_cred_id: clusterCredentials.Clustercredentials_cred_id}, otherUser.Clusteruser_id)
+	assert.True(t, IsResultNotFoundError(err), "%v", err)
+
+	var credentialsByHost []ClusterCredentials
+	assert.NoError(t, dbq.CheckedListClusterCredentialsByHost(ctx, clusterCredentials.Host, &credentialsByHost, otherUser.Clusteruser_id))
+	assert.Len(t, credentialsByHost, 0)
+
+	var engineInstances []GitopsEngineInstance
+	assert.NoError(t, dbq.CheckedListAllGitopsEngineInstancesForGitopsEngineClusterIdAndOwnerId(ctx, engineCluster.Gitopsenginecluster_id, owner, &engineInstances))
+	if assert.Len(t, engineInstances, 1) {
+		assert.Equal(t, engineInstance.Gitopsengineinstance_id, engineInstances[0].Gitopsengineinstance_id)
+	}
+	assert.NoError(t, dbq.CheckedListAllGitopsEngineInstancesForGitopsEngineClusterIdAndOwnerId(ctx, engineCluster.Gitopsenginecluster_id, otherUser.Clusteruser_id, &engineInstances))
+	assert.Len(t, engineInstances, 0)
+
+	// A user without access is not able to delete the rows
+	_, err = dbq.CheckedDeleteApplicationById(ctx, application.Application_id, otherUser.Clusteruser_id)
+	assert.True(t, IsAccessDeniedError(err), "%v", err)
+
+	_, err = dbq.CheckedDeleteManagedEnvironmentById(ctx, managedEnvironment.Managedenvironment_id, otherUser.Clusteruser_id)
+	assert.True(t, IsResultNotFoundError(err), "%v", err)
+
+	rowsAffected, err := dbq.CheckedDeleteApplicationById(ctx, application.Application_id, owner)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, rowsAffected)
+}
+
+func testConformanceNotFound(t *testing.T, dbq AllDatabaseQueries) {
+
+	ctx := context.Background()
+
+	_, _, _, engineInstance, _, err := createSampleData(t, dbq)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	for _, getFn := range []func() error{
+		func() error { return dbq.GetApplicationById(ctx, &Application{Application_id: "test-missing"}) },
+		func() error {
+			return dbq.GetApplicationStateById(ctx, &ApplicationState{Applicationstate_application_id: "test-missing"})
+		},
+		func() error { return dbq.GetClusterUserById(ctx, &ClusterUser{Clusteruser_id: "test-missing"}) },
+		func() error { return dbq.GetClusterUserByUsername(ctx, &ClusterUser{User_name: "test-missing"}) },
+		func() error {
+			return dbq.GetGitopsEngineClusterById(ctx, &GitopsEngineCluster{Gitopsenginecluster_id: "test-missing"})
+		},
+		func() error {
+			return dbq.GetGitopsEngineInstanceById(ctx, &GitopsEngineInstance{Gitopsengineinstance_id: "test-missing"})
+		},
+		func() error {
+			return dbq.GetManagedEnvironmentById(ctx, &ManagedEnvironment{Managedenvironment_id: "test-missing"})
+		},
+		func() error { return dbq.GetOperationById(ctx, &Operation{Operation_id: "test-missing"}) },
+		func() error { return dbq.GetSyncOperationById(ctx, &SyncOperation{SyncOperation_id: "test-missing"}) },
+		func() error {
+			return dbq.GetDeploymentToApplicationMappingByDeplId(ctx, &DeploymentToApplicationMapping{Deploymenttoapplicationmapping_uid_id: "test-missing"})
+		},
+		func() error {
+			return dbq.GetClusterCredentialsById(ctx, &ClusterCredentials{Clustercredentials_cred_id: "test-missing"})
+		},
+		func() error {
+			return dbq.GetClusterAccessByPrimaryKey(ctx, &ClusterAccess{Clusteraccess_user_id: testClusterUser.Clusteruser_id,
+				Clusteraccess_managed_environment_id: "test-missing", Clusteraccess_gitops_engine_instance_id: engineInstance.Gitopsengineinstance_id})
+		},
+		func() error {
+			return dbq.UpdateGitopsEngineClusterHeartbeat(ctx, &GitopsEngineCluster{Gitopsenginecluster_id: "test-missing", Heartbeat_last_seen: time.Now()})
+		},
+		func() error { return dbq.RequestOperationCancellation(ctx, &Operation{Operation_id: "test-missing"}) },
+	} {
+		err := getFn()
+		assert.True(t, IsResultNotFoundError(err), "%v", err)
+	}
+
+	// Deleting a row that does not exist is not an error
+	for _, deleteFn := range []func() (int, error){
+		func() (int, error) { return dbq.DeleteApplicationById(ctx, "test-missing") },
+		func() (int, error) { return dbq.DeleteOperationById(ctx, "test-missing") },
+		func() (int, error) { return dbq.DeleteSyncOperationById(ctx, "test-missing") },
+		func() (int, error) { return dbq.DeleteDeploymentToApplicationMappingByDeplId(ctx, "test-missing") },
+		func() (int, error) {
+			return dbq.CheckedDeleteApplicationById(ctx, "test-missing", testClusterUser.Clusteruser_id)
+		},
+	} {
+		rowsAffected, err := deleteFn()
+		assert.NoError(t, err)
+		assert.Equal(t, 0, rowsAffected)
+	}
+
+	// Updating a row that does not exist is an error
+	err = dbq.UpdateOperation(ctx, &Operation{Operation_id: "test-missing", Instance_id: engineInstance.Gitopsengineinstance_id,
+		Operation_owner_user_id: testClusterUser.Clusteruser_id, Resource_id: "test-resource", Resource_type: OperationResourceType_Application,
+		State: OperationState_Waiting})
+	assert.Error(t, err)
+}
+
+func testConformanceConstraints(t *testing.T, dbq AllDatabaseQueries) {
+
+	ctx := context.Background()
+
+	clusterCredentials, managedEnvironment, _, engineInstance, _, err := createSampleData(t, dbq)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// Primary keys are unique
+	duplicateCredentials := *clusterCredentials
+	duplicateCredentials.SeqID = 0
+	err = dbq.CreateClusterCredentials(ctx, &duplicateCredentials)
+	assert.True(t, IsUniqueViolationError(err), "%v", err)
+
+	// User names are unique
+	err = dbq.CreateClusterUser(ctx, &ClusterUser{Clusteruser_id: "test-duplicate-user", User_name: testClusterUser.User_name})
+	assert.True(t, IsUniqueViolationError(err), "%v", err)
+
+	// Rows must reference rows which exist
+	err = dbq.CreateGitopsEngineInstance(ctx, &GitopsEngineInstance{Gitopsengineinstance_id: "test-missing-cluster-instance",
+		Namespace_name: "test-namespace", Namespace_uid: "test-namespace-uid", EngineCluster_id: "test-missing"})
+	assert.True(t, IsForeignKeyViolationError(err), "%v", err)
+
+	newApplication := func(id string) *Application {
+		return &Application{
+			Application_id:          id,
+			Name:                    id,
+			Spec_field:              "{}",
+			Engine_instance_inst_id: engineInstance.Gitopsengineinstance_id,
+			Managed_environment_id:  managedEnvironment.Managedenvironment_id,
+		}
+	}
+
+	missingEnvApplication := newApplication("test-missing-env-application")
+	missingEnvApplication.Managed_environment_id = "test-missing"
+	err = dbq.CreateApplication(ctx, missingEnvApplication)
+	assert.True(t, IsForeignKeyViolationError(err), "%v", err)
+
+	// Values must fit in their column
+	longNameApplication := newApplication("test-long-name-application")
+	longNameApplication.Name = strings.Repeat("a", 257)
+	err = dbq.CreateApplication(ctx, longNameApplication)
+	assert.True(t, IsFieldTooLongError(err), "%v", err)
+
+	// An Application may only be referenced by a single DeploymentToApplicationMapping
+	application := newApplication("test-constraints-application")
+	if !assert.NoError(t, dbq.CreateApplication(ctx, application)) {
+		return
+	}
+
+	newDTAM := func(id string) *DeploymentToApplicationMapping {
+		return &DeploymentToApplicationMapping{
+			Deploymenttoapplicationmapping_uid_id: id,
+			DeploymentName:                        id,
+			DeploymentNamespace:                   "test-namespace",
+			WorkspaceUID:                          "test-workspace",
+			Application_id:                        application.Application_id,
+		}
+	}
+
+	dtam := newDTAM("test-constraints-dtam")
+	if !assert.NoError(t, dbq.CreateDeploymentToApplicationMapping(ctx, dtam)) {
+		return
+	}
+
+	err = dbq.CreateDeploymentToApplicationMapping(ctx, newDTAM("test-constraints-dtam-2"))
+	assert.True(t, IsUniqueViolationError(err), "%v", err)
+
+	// Rows may not be deleted while they are referenced
+	_, err = dbq.DeleteApplicationById(ctx, application.Application_id)
+	assert.True(t, IsForeignKeyViolationError(err), "%v", err)
+
+	_, err = dbq.DeleteManagedEnvironmentById(ctx, managedEnvironment.Managedenvironment_id)
+	assert.True(t, IsForeignKeyViolationError(err), "%v", err)
+
+	rowsAffected, err := dbq.DeleteDeploymentToApplicationMappingByDeplId(ctx, dtam.Deploymenttoapplicationmapping_uid_id)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, rowsAffected)
+
+	rowsAffected, err = dbq.DeleteApplicationById(ctx, application.Application_id)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, rowsAffected)
+}
+
+func testConformanceOptimisticConcurrency(t *testing.T, dbq AllDatabaseQueries) {
+
+	ctx := context.Background()
+
+	_, managedEnvironment, _, engineInstance, _, err := createSampleData(t, dbq)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	application := &Application{
+		Application_id:          "test-conflict-application",
+		Name:                    "test-conflict-application",
+		Spec_field:              "{}",
+		Engine_instance_inst_id: engineInstance.Gitopsengineinstance_id,
+		Managed_environment_id:  managedEnvironment.Managedenvironment_id,
+	}
+	if !assert.NoError(t, dbq.CreateApplication(ctx, application)) {
+		return
+	}
+	assert.Equal(t, 1, application.Version)
+
+	firstRead := Application{Application_id: application.Application_id}
+	secondRead := Application{Application_id: application.Application_id}
+	assert.NoError(t, dbq.GetApplicationById(ctx, &firstRead))
+	assert.NoError(t, dbq.GetApplicationById(ctx, &secondRead))
+
+	firstRead.Spec_field = "{\"first\": true}"
+	assert.NoError(t, dbq.UpdateApplication(ctx, &firstRead))
+	assert.Equal(t, 2, firstRead.Version)
+
+	// The second update is based on a stale version of the row
+	secondRead.Spec_field = "{\"second\": true}"
+	err = dbq.UpdateApplication(ctx, &secondRead)
+	assert.True(t, IsVersionConflictError(err), "%v", err)
+	assert.Equal(t, 1, secondRead.Version)
+
+	result := Application{Application_id: application.Application_id}
+	assert.NoError(t, dbq.GetApplicationById(ctx, &result))
+	assert.Equal(t, firstRead.Spec_field, result.Spec_field)
+	assert.Equal(t, 2, result.Version)
+
+	rowsAffected, err := dbq.DeleteApplicationById(ctx, application.Application_id)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, rowsAffected)
+}
+
+func testConformanceTransactions(t *testing.T, dbq AllDatabaseQueries) {
+
+	ctx := context.Background()
+
+	_, managedEnvironment, _, engineInstance, _, err := createSampleData(t, dbq)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	application := &Application{
+		Application_id:          "test-transaction-application",
+		Name:                    "test-transaction-application",
+		Spec_field:              "{}",
+		Engine_instance_inst_id: engineInstance.Gitopsengineinstance_id,
+		Managed_environment_id:  managedEnvironment.Managedenvironment_id,
+	}
+
+	err = dbq.RunInTransaction(ctx, func(tx ApplicationScopedQueries) error {
+
+		if err := tx.CreateApplication(ctx, application); err != nil {
+			return err
+		}
+
+		return tx.RunInTransaction(ctx, func(nestedTx ApplicationScopedQueries) error {
+			if err := nestedTx.GetApplicationById(ctx, &Application{Application_id: application.Application_id}); err != nil {
+				return err
+			}
+			return fmt.Errorf("expected error")
+		})
+	})
+	assert.EqualError(t, err, "expected error")
+
+	err = dbq.GetApplicationById(ctx, &Application{Application_id: application.Application_id})
+	assert.True(t, IsResultNotFoundError(err), "%v", err)
+
+	// An Application row may only be locked within a transaction
+	if !assert.NoError(t, dbq.CreateApplication(ctx, application)) {
+		return
+	}
+	defer func() {
+		_, err := dbq.DeleteApplicationById(ctx, application.Application_id)
+		assert.NoError(t, err)
+	}()
+	assert.False(t, application.Created_on.IsZero())
+
+	err = dbq.LockApplicationById(ctx, &Application{Application_id: application.Application_id})
+	assert.Error(t, err)
+
+	err = dbq.RunInTransaction(ctx, func(tx ApplicationScopedQueries) error {
+		lockedApplication := &Application{Application_id: application.Application_id}
+		if err := tx.LockApplicationById(ctx, lockedApplication); err != nil {
+			return err
+		}
+		assert.Equal(t, application.Name, lockedApplication.Name)
+		return nil
+	})
+	assert.NoError(t, err)
+
+	// An error within the transaction, such as a constraint violation, is returned by RunInTransaction
+	err = dbq.RunInTransaction(ctx, func(tx ApplicationScopedQueries) error {
+		return tx.CreateApplicationState(ctx, &ApplicationState{
+			Applicationstate_application_id: "test-missing",
+			Health:                          "Healthy",
+			Sync_Status:                     "Synced",
+		})
+	})
+	assert.True(t, IsForeignKeyViolationError(err), "%v", err)
+}
+
+func testConformanceOperations(t *testing.T, dbq AllDatabaseQueries) {
+
+	ctx := context.Background()
+
+	_, _, _, engineInstance, _, err := createSampleData(t, dbq)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	operation := &Operation{
+		Operation_id:            "test-conformance-operation",
+		Instance_id:             engineInstance.Gitopsengineinstance_id,
+		Resource_id:             "test-resource",
+		Resource_type:           OperationResourceType_Application,
+		State:                   OperationState_Completed,
+		Operation_owner_user_id: testClusterUser.Clusteruser_id,
+	}
+	if !assert.NoError(t, dbq.CreateOperation(ctx, operation, operation.Operation_owner_user_id)) {
+		return
+	}
+
+	// The initial state is always waiting
+	result := Operation{Operation_id: operation.Operation_id}
+	assert.NoError(t, dbq.GetOperationById(ctx, &result))
+	assert.Equal(t, OperationState_Waiting, result.State)
+	assert.WithinDuration(t, time.Now(), result.Created_on, time.Minute)
+	assert.WithinDuration(t, result.Created_on.Add(GetOperationTimeout(operation.Resource_type)), result.Deadline, time.Second)
+
+	var operations []Operation
+	assert.NoError(t, dbq.ListOperationsByResourceIdAndTypeAndOwnerId(ctx, operation.Resource_id, operation.Resource_type,
+		&operations, operation.Operation_owner_user_id))
+	assert.Len(t, operations, 1)
+
+	// A cancellation request is not overwritten by an update
+	assert.NoError(t, dbq.RequestOperationCancellation(ctx, &Operation{Operation_id: operation.Operation_id}))
+	result.State = OperationState_In_Progress
+	assert.NoError(t, dbq.UpdateOperation(ctx, &result))
+
+	result = Operation{Operation_id: operation.Operation_id}
+	assert.NoError(t, dbq.GetOperationById(ctx, &result))
+	assert.True(t, result.Cancel_requested)
+	assert.Equal(t, OperationState_In_Progress, result.State)
+
+	// Incomplete operations are listed, until they are timed out
+	var incompleteOperations []Operation
+	assert.NoError(t, dbq.ListIncompleteOperations(ctx, &incompleteOperations))
+	assert.True(t, containsOperation(incompleteOperations, operation.Operation_id))
+
+	// The operation is timed out once its deadline has passed
+	var timedOutOperations []Operation
+	err = dbq.TimeoutExpiredOperations(ctx, result.Deadline.Add(time.Minute), &timedOutOperations)
+	assert.NoError(t, err)
+	assert.True(t, containsOperation(timedOutOperations, operation.Operation_id))
+
+	assert.NoError(t, dbq.ListIncompleteOperations(ctx, &incompleteOperations))
+	assert.False(t, containsOperation(incompleteOperations, operation.Operation_id))
+
+	result = Operation{Operation_id: operation.Operation_id}
+	assert.NoError(t, dbq.GetOperationById(ctx, &result))
+	assert.Equal(t, OperationState_Timeout, result.State)
+
+	rowsAffected, err := dbq.CheckedDeleteOperationById(ctx, operation.Operation_id, "test-other-user")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, rowsAffected)
+
+	rowsAffected, err = dbq.CheckedDeleteOperationById(ctx, operation.Operation_id, operation.Operation_owner_user_id)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, rowsAffected)
+}
+
+// containsOperation returns true if 'operations' contains the operation with the given id.
+func containsOperation(operations []Operation, operationID string) bool {
+	for _, operation := range operations {
+		if operation.Operation_id == operationID {
+			return true
+		}
+	}
+	return false
+}
+
+func testConformanceWorkspaceTeardownQueries(t *testing.T, dbq AllDatabaseQueries) {
+
+	ctx := context.Background()
+
+	_, managedEnvironment, _, engineInstance, clusterAccess, err := createSampleData(t, dbq)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var clusterAccessList []ClusterAccess
+	assert.NoError(t, dbq.ListClusterAccessByClusterUserId(ctx, testClusterUser.Clusteruser_id, &clusterAccessList))
+	if assert.Len(t, clusterAccessList, 1) {
+		assert.Equal(t, clusterAccess.Clusteraccess_managed_environment_id, clusterAccessList[0].Clusteraccess_managed_environment_id)
+	}
+	assert.NoError(t, dbq.ListClusterAccessByClusterUserId(ctx, "test-other-user", &clusterAccessList))
+	assert.Len(t, clusterAccessList, 0)
+
+	operation := &Operation{
+		Operation_id:            "test-conformance-teardown-operation",
+		Instance_id:             engineInstance.Gitopsengineinstance_id,
+		Resource_id:             "test-resource",
+		Resource_type:           OperationResourceType_Application,
+		Operation_owner_user_id: testClusterUser.Clusteruser_id,
+		State:                   OperationState_Completed,
+	}
+	if !assert.NoError(t, dbq.CreateOperation(ctx, operation, operation.Operation_owner_user_id)) {
+		return
+	}
+
+	var operations []Operation
+	assert.NoError(t, dbq.ListOperationsByOwnerId(ctx, testClusterUser.Clusteruser_id, &operations))
+	if assert.Len(t, operations, 1) {
+		assert.Equal(t, operation.Operation_id, operations[0].Operation_id)
+	}
+	assert.NoError(t, dbq.ListOperationsByOwnerId(ctx, "test-other-user", &operations))
+	assert.Len(t, operations, 0)
+
+	mapping := &KubernetesToDBResourceMapping{
+		KubernetesResourceType: K8sToDBMapping_Namespace,
+		KubernetesResourceUID:  "test-conformance-workspace-uid",
+		DBRelationType:         K8sToDBMapping_ManagedEnvironment,
+		DBRelationKey:          managedEnvironment.Managedenvironment_id,
+	}
+	if !assert.NoError(t, dbq.CreateKubernetesResourceToDBResourceMapping(ctx, mapping)) {
+		return
+	}
+	defer func() {
+		_, err := dbq.DeleteKubernetesResourceToDBResourceMapping(ctx, mapping)
+		assert.NoError(t, err)
+	}()
+
+	var mappings []KubernetesToDBResourceMapping
+	assert.NoError(t, dbq.ListKubernetesToDBResourceMappingsByType(ctx, K8sToDBMapping_Namespace, K8sToDBMapping_ManagedEnvironment, &mappings))
+	found := false
+	for _, result := range mappings {
+		assert.Equal(t, K8sToDBMapping_ManagedEnvironment, result.DBRelationType)
+		found = found || result.KubernetesResourceUID == mapping.KubernetesResourceUID
+	}
+	assert.True(t, found)
+
+	assert.Error(t, dbq.ListKubernetesToDBResourceMappingsByType(ctx, K8sToDBMapping_Namespace, "", &mappings))
+
+	application := &Application{
+		Application_id:          "test-conformance-teardown-application",
+		Name:                    "my-application",
+		Spec_field:              "{}",
+		Engine_instance_inst_id: engineInstance.Gitopsengineinstance_id,
+		Managed_environment_id:  managedEnvironment.Managedenvironment_id,
+	}
+	if !assert.NoError(t, dbq.CreateApplication(ctx, application)) {
+		return
+	}
+
+	var applications []Application
+	assert.NoError(t, dbq.ListApplicationsByManagedEnvironmentId(ctx, managedEnvironment.Managedenvironment_id, &applications))
+	if assert.Len(t, applications, 1) {
+		assert.Equal(t, application.Application_id, applications[0].Application_id)
+	}
+	assert.NoError(t, dbq.ListApplicationsByManagedEnvironmentId(ctx, "test-other-managed-environment", &applications))
+	assert.Len(t, applications, 0)
+
+	_, err = dbq.DeleteApplicationById(ctx, application.Application_id)
+	assert.NoError(t, err)
+
+	apiCRToDBMapping := &APICRToDatabaseMapping{
+		APIResourceType:      APICRToDatabaseMapping_ResourceType_GitOpsDeploymentSyncRun,
+		APIResourceUID:       "test-conformance-teardown-sync-run-uid",
+		APIResourceName:      "my-sync-run",
+		APIResourceNamespace: "my-namespace",
+		WorkspaceUID:         mapping.KubernetesResourceUID,
+		DBRelationType:       APICRToDatabaseMapping_DBRelationType_SyncOperation,
+		DBRelationKey:        "test-sync-operation",
+	}
+	if !assert.NoError(t, dbq.CreateAPICRToDatabaseMapping(ctx, apiCRToDBMapping)) {
+		return
+	}
+
+	var apiCRToDBMappings []APICRToDatabaseMapping
+	assert.NoError(t, dbq.ListAPICRToDatabaseMappingsByWorkspaceUID(ctx, mapping.KubernetesResourceUID, &apiCRToDBMappings))
+	if assert.Len(t, apiCRToDBMappings, 1) {
+		assert.Equal(t, apiCRToDBMapping.APIResourceUID, apiCRToDBMappings[0].APIResourceUID)
+	}
+	assert.NoError(t, dbq.ListAPICRToDatabaseMappingsByWorkspaceUID(ctx, "test-other-workspace-uid", &apiCRToDBMappings))
+	assert.Len(t, apiCRToDBMappings, 0)
+
+	_, err = dbq.DeleteAPICRToDatabaseMapping(ctx, apiCRToDBMapping)
+	assert.NoError(t, err)
+
+	// The managed environment is only deleted once no user has access to it
+	rowsAffected, err := dbq.DeleteManagedEnvironmentWithoutClusterAccessById(ctx, managedEnvironment.Managedenvironment_id)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, rowsAffected)
+	assert.NoError(t, dbq.GetManagedEnvironmentById(ctx, &ManagedEnvironment{Managedenvironment_id: managedEnvironment.Managedenvironment_id}))
+
+	_, err = dbq.DeleteClusterAccessById(ctx, clusterAccess.Clusteraccess_user_id, clusterAccess.Clusteraccess_managed_environment_id,
+		clusterAccess.Clusteraccess_gitops_engine_instance_id)
+	assert.NoError(t, err)
+
+	rowsAffected, err = dbq.DeleteManagedEnvironmentWithoutClusterAccessById(ctx, managedEnvironment.Managedenvironment_id)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, rowsAffected)
+	err = dbq.GetManagedEnvironmentById(ctx, &ManagedEnvironment{Managedenvironment_id: managedEnvironment.Managedenvironment_id})
+	assert.True(t, IsResultNotFoundError(err))
+}
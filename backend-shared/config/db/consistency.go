@@ -0,0 +1,144 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// The queries in this file are used to detect rows that reference other rows which no longer exist, and which are not
+// prevented by foreign key constraints (for example, because the row references a resource in one of several tables).
+// They act on all rows of the table, and thus should only be used by consistency checks (see 'gitops-db-check').
+
+// ListApplicationsWithoutDeploymentToApplicationMapping returns the Applications that are not referenced by any
+// DeploymentToApplicationMapping: each Application should correspond to a GitOpsDeployment.
+func (dbq *PostgreSQLDatabaseQueries) ListApplicationsWithoutDeploymentToApplicationMapping(ctx context.Context, applications *[]Application) error {
+
+	if err := validateQueryParamsEntity(applications, dbq); err != nil {
+		return err
+	}
+
+	var dbResults []Application
+
+	if err := dbq.dbConnection.Model(&dbResults).
+		Where("NOT EXISTS (SELECT 1 FROM deploymenttoapplicationmapping AS dta WHERE dta.application_id = application.application_id)").
+		Order("seq_id ASC").Context(ctx).Select(); err != nil {
+		return fmt.Errorf("error on retrieving ListApplicationsWithoutDeploymentToApplicationMapping: %w", mapDBError(err))
+	}
+
+	*applications = dbResults
+
+	return nil
+}
+
+// ListApplicationsForGitopsEngineInstance returns the Applications that are hosted on the given GitOps engine instance.
+func (dbq *PostgreSQLDatabaseQueries) ListApplicationsForGitopsEngineInstance(ctx context.Context, gitopsEngineInstanceId string, applications *[]Application) error {
+
+	if err := validateQueryParamsEntity(applications, dbq); err != nil {
+		return err
+	}
+
+	if isEmpty(gitopsEngineInstanceId) {
+		return fmt.Errorf("gitops engine instance id is empty")
+	}
+
+	var dbResults []Application
+
+	if err := dbq.dbConnection.Model(&dbResults).
+		Where("application.engine_instance_inst_id = ?", gitopsEngineInstanceId).
+		Order("seq_id ASC").Context(ctx).Select(); err != nil {
+		return fmt.Errorf("error on retrieving ListApplicationsForGitopsEngineInstance: %w", mapDBError(err))
+	}
+
+	*applications = dbResults
+
+	return nil
+}
+
+// ListDeploymentToApplicationMappings returns all DeploymentToApplicationMappings.
+func (dbq *PostgreSQLDatabaseQueries) ListDeploymentToApplicationMappings(ctx context.Context, deplToAppMappings *[]DeploymentToApplicationMapping) error {
+
+	if err := validateQueryParamsEntity(deplToAppMappings, dbq); err != nil {
+		return err
+	}
+
+	var dbResults []DeploymentToApplicationMapping
+
+	if err := dbq.dbConnection.Model(&dbResults).Order("seq_id ASC").Context(ctx).Select(); err != nil {
+		return fmt.Errorf("error on retrieving ListDeploymentToApplicationMappings: %w", mapDBError(err))
+	}
+
+	*deplToAppMappings = dbResults
+
+	return nil
+}
+
+// ListAPICRToDatabaseMappings returns all APICRToDatabaseMappings.
+func (dbq *PostgreSQLDatabaseQueries) ListAPICRToDatabaseMappings(ctx context.Context, apiCRToDBMappings *[]APICRToDatabaseMapping) error {
+
+	if err := validateQueryParamsEntity(apiCRToDBMappings, dbq); err != nil {
+		return err
+	}
+
+	var dbResults []APICRToDatabaseMapping
+
+	if err := dbq.dbConnection.Model(&dbResults).Order("seq_id ASC").Context(ctx).Select(); err != nil {
+		return fmt.Errorf("error on retrieving ListAPICRToDatabaseMappings: %w", mapDBError(err))
+	}
+
+	*apiCRToDBMappings = dbResults
+
+	return nil
+}
+
+// ListAPICRToDatabaseMappingsWithMissingDBRelation returns the APICRToDatabaseMappings whose database row (for
+// example, a SyncOperation) no longer exists.
+func (dbq *PostgreSQLDatabaseQueries) ListAPICRToDatabaseMappingsWithMissingDBRelation(ctx context.Context, apiCRToDBMappings *[]APICRToDatabaseMapping) error {
+
+	if err := validateQueryParamsEntity(apiCRToDBMappings, dbq); err != nil {
+		return err
+	}
+
+	var dbResults []APICRToDatabaseMapping
+
+	if err := dbq.dbConnection.Model(&dbResults).
+		Where("atdbm.db_relation_type = ?", APICRToDatabaseMapping_DBRelationType_SyncOperation).
+		Where("NOT EXISTS (SELECT 1 FROM syncoperation AS so WHERE so.syncoperation_id = atdbm.db_relation_key)").
+		Order("seq_id ASC").Context(ctx).Select(); err != nil {
+		return fmt.Errorf("error on retrieving ListAPICRToDatabaseMappingsWithMissingDBRelation: %w", mapDBError(err))
+	}
+
+	*apiCRToDBMappings = dbResults
+
+	return nil
+}
+
+// ListKubernetesToDBResourceMappingsWithMissingDBRelation returns the KubernetesToDBResourceMappings whose database
+// row (a ManagedEnvironment, GitopsEngineCluster, or GitopsEngineInstance) no longer exists.
+func (dbq *PostgreSQLDatabaseQueries) ListKubernetesToDBResourceMappingsWithMissingDBRelation(ctx context.Context, kubernetesToDBMappings *[]KubernetesToDBResourceMapping) error {
+
+	if err := validateQueryParamsEntity(kubernetesToDBMappings, dbq); err != nil {
+		return err
+	}
+
+	var dbResults []KubernetesToDBResourceMapping
+
+	if err := dbq.dbConnection.Model(&dbResults).
+		WhereOrGroup(func(q *pg.Query) (*pg.Query, error) {
+			q = q.WhereOr("ktdbrm.db_relation_type = ? AND NOT EXISTS (SELECT 1 FROM managedenvironment AS me WHERE me.managedenvironment_id = ktdbrm.db_relation_key)",
+				K8sToDBMapping_ManagedEnvironment).
+				WhereOr("ktdbrm.db_relation_type = ? AND NOT EXISTS (SELECT 1 FROM gitopsenginecluster AS gec WHERE gec.gitopsenginecluster_id = ktdbrm.db_relation_key)",
+					K8sToDBMapping_GitopsEngineCluster).
+				WhereOr("ktdbrm.db_relation_type = ? AND NOT EXISTS (SELECT 1 FROM gitopsengineinstance AS gei WHERE gei.gitopsengineinstance_id = ktdbrm.db_relation_key)",
+					K8sToDBMapping_GitopsEngineInstance)
+			return q, nil
+		}).
+		Order("seq_id ASC").Context(ctx).Select(); err != nil {
+		return fmt.Errorf("error on retrieving ListKubernetesToDBResourceMappingsWithMissingDBRelation: %w", mapDBError(err))
+	}
+
+	*kubernetesToDBMappings = dbResults
+
+	return nil
+}
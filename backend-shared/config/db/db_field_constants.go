@@ -11,6 +11,19 @@ const (
 	ApplicationstateMessageLength    = 1024
 	ApplicationstateRevisionLength   = 1024
 	ApplicationstateSyncstatusLength = 30
+
+	ApplicationstateConditionsLength       = 4096
+	ApplicationstateOperationPhaseLength   = 30
+	ApplicationstateOperationMessageLength = 1024
+
+	GitopsEngineClusterHeartbeatAgentVersionLength  = 256
+	GitopsEngineClusterHeartbeatArgoCDVersionLength = 256
+
+	OperationHumanReadableStateLength = 1024
+
+	SyncOperationSyncResultPhaseLength     = 30
+	SyncOperationSyncResultMessageLength   = 1024
+	SyncOperationSyncResultResourcesLength = 8192
 )
 
 // TruncateVarchar converts string to "str..." if chars is > maxLength
@@ -2,6 +2,7 @@ package db
 
 import (
 	"github.com/stretchr/testify/assert"
+	"strings"
 	"testing"
 )
 
@@ -134,3 +135,49 @@ func TestTruncateVarchar(t *testing.T) {
 		})
 	}
 }
+
+func TestEncodeApplicationStateConditions(t *testing.T) {
+
+	t.Run("empty conditions are encoded as empty string", func(t *testing.T) {
+		res, err := EncodeApplicationStateConditions(nil)
+		assert.NoError(t, err)
+		assert.Equal(t, "", res)
+
+		decoded, err := DecodeApplicationStateConditions(res)
+		assert.NoError(t, err)
+		assert.Empty(t, decoded)
+	})
+
+	t.Run("conditions are round-tripped", func(t *testing.T) {
+		conditions := []ApplicationStateCondition{
+			{Type: "ComparisonError", Message: "rpc error: repository not found"},
+			{Type: "SyncError", Message: "failed to sync"},
+		}
+
+		res, err := EncodeApplicationStateConditions(conditions)
+		assert.NoError(t, err)
+
+		decoded, err := DecodeApplicationStateConditions(res)
+		assert.NoError(t, err)
+		assert.Equal(t, conditions, decoded)
+	})
+
+	t.Run("long messages are truncated, and conditions that do not fit are dropped", func(t *testing.T) {
+		conditions := []ApplicationStateCondition{}
+		for x := 0; x < 10; x++ {
+			conditions = append(conditions, ApplicationStateCondition{Type: "ComparisonError", Message: strings.Repeat("a", 2000)})
+		}
+
+		res, err := EncodeApplicationStateConditions(conditions)
+		assert.NoError(t, err)
+		assert.LessOrEqual(t, len(res), ApplicationstateConditionsLength)
+
+		decoded, err := DecodeApplicationStateConditions(res)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, decoded)
+		assert.Less(t, len(decoded), len(conditions))
+		for _, condition := range decoded {
+			assert.Equal(t, applicationStateConditionMessageLength, len(condition.Message))
+		}
+	})
+}
@@ -29,7 +29,7 @@ func (dbq *PostgreSQLDatabaseQueries) ListDeploymentToApplicationMappingByWorksp
 		Context(ctx).
 		Select(); err != nil {
 
-		return fmt.Errorf("error on retrieving ListDeploymentToApplicationMappingByWorkspaceUID: %v", err)
+		return fmt.Errorf("error on retrieving ListDeploymentToApplicationMappingByWorkspaceUID: %w", mapDBError(err))
 	}
 
 	*deplToAppMappingParam = dbResults
@@ -65,7 +65,7 @@ func (dbq *PostgreSQLDatabaseQueries) ListDeploymentToApplicationMappingByNamesp
 		Context(ctx).
 		Select(); err != nil {
 
-		return fmt.Errorf("error on retrieving ListDeploymentToApplicationMappingByNamespaceAndName: %v", err)
+		return fmt.Errorf("error on retrieving ListDeploymentToApplicationMappingByNamespaceAndName: %w", mapDBError(err))
 	}
 
 	*deplToAppMappingParam = dbResults
@@ -94,7 +94,7 @@ func (dbq *PostgreSQLDatabaseQueries) DeleteDeploymentToApplicationMappingByName
 		Where("dta.namespace = ?", deploymentNamespace).
 		Where("dta.workspace_uid = ?", workspaceUID).Context(ctx).Delete()
 	if err != nil {
-		return 0, fmt.Errorf("error on deleting application: %v", err)
+		return 0, fmt.Errorf("error on deleting application: %w", mapDBError(err))
 	}
 
 	return deleteResult.RowsAffected(), nil
@@ -121,7 +121,7 @@ func (dbq *PostgreSQLDatabaseQueries) GetDeploymentToApplicationMappingByDeplId(
 		Context(ctx).
 		Select(); err != nil {
 
-		return fmt.Errorf("error on retrieving GetDeploymentToApplicationMappingById: %v", err)
+		return fmt.Errorf("error on retrieving GetDeploymentToApplicationMappingById: %w", mapDBError(err))
 	}
 
 	if len(dbResults) >= 2 {
@@ -154,7 +154,7 @@ func (dbq *PostgreSQLDatabaseQueries) GetDeploymentToApplicationMappingByApplica
 		Context(ctx).
 		Select(); err != nil {
 
-		return fmt.Errorf("error on retrieving GetDeploymentToApplicationMappingByApplicationId: %v", err)
+		return fmt.Errorf("error on retrieving GetDeploymentToApplicationMappingByApplicationId: %w", mapDBError(err))
 	}
 
 	if len(dbResults) > 1 {
@@ -193,7 +193,7 @@ func (dbq *PostgreSQLDatabaseQueries) CheckedGetDeploymentToApplicationMappingBy
 		Context(ctx).
 		Select(); err != nil {
 
-		return fmt.Errorf("error on retrieving GetDeploymentToApplicationMappingById: %v", err)
+		return fmt.Errorf("error on retrieving GetDeploymentToApplicationMappingById: %w", mapDBError(err))
 	}
 
 	if len(dbResults) >= 2 {
@@ -212,7 +212,7 @@ func (dbq *PostgreSQLDatabaseQueries) CheckedGetDeploymentToApplicationMappingBy
 			return NewResultNotFoundError(fmt.Sprintf("unable to retrieve deployment mapping for Application: %v", err))
 		}
 
-		return fmt.Errorf("unable to retrieve application of deployment mapping: %v", err)
+		return fmt.Errorf("unable to retrieve application of deployment mapping: %w", mapDBError(err))
 	}
 
 	*deplToAppMappingParam = dbResults[0]
@@ -242,7 +242,7 @@ func (dbq *PostgreSQLDatabaseQueries) CheckedDeleteDeploymentToApplicationMappin
 
 	deleteResult, err := dbq.dbConnection.Model(entity).WherePK().Context(ctx).Delete()
 	if err != nil {
-		return 0, fmt.Errorf("error on deleting application: %v", err)
+		return 0, fmt.Errorf("error on deleting application: %w", mapDBError(err))
 	}
 
 	return deleteResult.RowsAffected(), nil
@@ -260,7 +260,7 @@ func (dbq *PostgreSQLDatabaseQueries) DeleteDeploymentToApplicationMappingByDepl
 
 	deleteResult, err := dbq.dbConnection.Model(entity).WherePK().Context(ctx).Delete()
 	if err != nil {
-		return 0, fmt.Errorf("error on deleting application: %v", err)
+		return 0, fmt.Errorf("error on deleting application: %w", mapDBError(err))
 	}
 
 	return deleteResult.RowsAffected(), nil
@@ -284,7 +284,7 @@ func (dbq *PostgreSQLDatabaseQueries) CreateDeploymentToApplicationMapping(ctx c
 
 	result, err := dbq.dbConnection.Model(obj).Context(ctx).Insert()
 	if err != nil {
-		return fmt.Errorf("error on inserting DeploymentToApplicationMapping %v", err)
+		return fmt.Errorf("error on inserting DeploymentToApplicationMapping %w", mapDBError(err))
 	}
 
 	if result.RowsAffected() != 1 {
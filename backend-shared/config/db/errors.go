@@ -0,0 +1,179 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// Errors returned by the database queries wrap one of the following sentinel errors, where applicable. These can be
+// matched via 'errors.Is' (or the Is*Error functions below): errors should NOT be matched based on their message text.
+//
+// Errors returned by go-pg/PostgreSQL are mapped onto these sentinel errors by 'mapDBError': the original error is
+// available via 'errors.As' with a '*DBError'.
+var (
+	// ErrNotFound is returned when the requested row(s) do not exist.
+	ErrNotFound = errors.New("no rows in result set")
+
+	// ErrAccessDenied is returned when the requested row(s) exist, but the user does not have access to them.
+	ErrAccessDenied = errors.New("results found, but access denied")
+
+	// ErrConflict is returned when a row could not be written because it conflicts with another row, or with a concurrent
+	// transaction. It is wrapped by each of the more specific conflict errors below, which should be preferred: only some
+	// conflicts may be resolved by retrying.
+	ErrConflict = errors.New("conflict with existing database state")
+
+	// ErrUniqueViolation is returned when a row could not be written, as it violates a primary key or unique constraint.
+	ErrUniqueViolation = fmt.Errorf("unique constraint violation: %w", ErrConflict)
+
+	// ErrForeignKeyViolation is returned when a row could not be written or deleted, as it violates a foreign key constraint.
+	ErrForeignKeyViolation = fmt.Errorf("foreign key constraint violation: %w", ErrConflict)
+
+	// ErrVersionConflict is returned when a row could not be updated, as it was updated (or deleted) since it was read:
+	// see 'UpdateApplication'. The update may be retried, after re-reading the row.
+	ErrVersionConflict = fmt.Errorf("row was updated since it was read: %w", ErrConflict)
+
+	// ErrSerializationFailure is returned when a transaction could not be committed, as it conflicts with a concurrent
+	// transaction. The transaction may be retried.
+	ErrSerializationFailure = fmt.Errorf("could not serialize access due to concurrent update: %w", ErrConflict)
+
+	// ErrFieldTooLong is returned when a value is longer than the maximum length of its column.
+	ErrFieldTooLong = errors.New("value too long for database field")
+)
+
+// PostgreSQL error codes that are mapped onto the sentinel errors: see https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	pgErrorCode_StringDataRightTruncation = "22001"
+	pgErrorCode_ForeignKeyViolation       = "23503"
+	pgErrorCode_UniqueViolation           = "23505"
+	pgErrorCode_SerializationFailure      = "40001"
+)
+
+// DBError is an error returned by go-pg/PostgreSQL, which has been mapped onto one of the sentinel errors above.
+type DBError struct {
+	// Err is the sentinel error (for example, ErrNotFound)
+	Err error
+
+	// Cause is the original error returned by go-pg/PostgreSQL
+	Cause error
+}
+
+func (e *DBError) Error() string {
+	return e.Cause.Error()
+}
+
+// Unwrap returns the sentinel error, so that a DBError is matched by errors.Is(err, ErrNotFound), etc.
+func (e *DBError) Unwrap() error {
+	return e.Err
+}
+
+// Code returns the PostgreSQL error code of the original error, or "" if the original error was not returned by PostgreSQL.
+func (e *DBError) Code() string {
+	var pgErr pg.Error
+	if errors.As(e.Cause, &pgErr) {
+		return pgErr.Field('C')
+	}
+	return ""
+}
+
+// mapDBError maps an error returned by go-pg onto the corresponding sentinel error. Errors that do not correspond to a
+// sentinel error (and errors that have already been mapped) are returned unchanged.
+func mapDBError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var dbErr *DBError
+	if errors.As(err, &dbErr) {
+		return err
+	}
+
+	if errors.Is(err, pg.ErrNoRows) {
+		return &DBError{Err: ErrNotFound, Cause: err}
+	}
+
+	var pgErr pg.Error
+	if errors.As(err, &pgErr) {
+		switch pgErr.Field('C') {
+		case pgErrorCode_UniqueViolation:
+			return &DBError{Err: ErrUniqueViolation, Cause: err}
+		case pgErrorCode_ForeignKeyViolation:
+			return &DBError{Err: ErrForeignKeyViolation, Cause: err}
+		case pgErrorCode_SerializationFailure:
+			return &DBError{Err: ErrSerializationFailure, Cause: err}
+		case pgErrorCode_StringDataRightTruncation:
+			return &DBError{Err: ErrFieldTooLong, Cause: err}
+		}
+	}
+
+	return err
+}
+
+// NewAccessDeniedError returns an error that will be matched by IsAccessDeniedError
+func NewAccessDeniedError(errString string) error {
+	return fmt.Errorf("%s: %w", errString, ErrAccessDenied)
+}
+
+func IsAccessDeniedError(errorParam error) bool {
+	return errors.Is(errorParam, ErrAccessDenied)
+}
+
+// NewResultNotFoundError returns an error that will be matched by IsResultNotFoundError
+func NewResultNotFoundError(errString string) error {
+	return fmt.Errorf("%s: %w", errString, ErrNotFound)
+}
+
+func IsResultNotFoundError(errorParam error) bool {
+	return errors.Is(errorParam, ErrNotFound)
+}
+
+// NewConflictError returns an error that will be matched by IsConflictError
+func NewConflictError(errString string) error {
+	return fmt.Errorf("%s: %w", errString, ErrConflict)
+}
+
+// IsConflictError returns true for any conflict error, including the more specific conflict errors below.
+func IsConflictError(errorParam error) bool {
+	return errors.Is(errorParam, ErrConflict)
+}
+
+// NewUniqueViolationError returns an error that will be matched by IsUniqueViolationError (and IsConflictError)
+func NewUniqueViolationError(errString string) error {
+	return fmt.Errorf("%s: %w", errString, ErrUniqueViolation)
+}
+
+func IsUniqueViolationError(errorParam error) bool {
+	return errors.Is(errorParam, ErrUniqueViolation)
+}
+
+// NewForeignKeyViolationError returns an error that will be matched by IsForeignKeyViolationError (and IsConflictError)
+func NewForeignKeyViolationError(errString string) error {
+	return fmt.Errorf("%s: %w", errString, ErrForeignKeyViolation)
+}
+
+func IsForeignKeyViolationError(errorParam error) bool {
+	return errors.Is(errorParam, ErrForeignKeyViolation)
+}
+
+// NewVersionConflictError returns an error that will be matched by IsVersionConflictError (and IsConflictError)
+func NewVersionConflictError(errString string) error {
+	return fmt.Errorf("%s: %w", errString, ErrVersionConflict)
+}
+
+func IsVersionConflictError(errorParam error) bool {
+	return errors.Is(errorParam, ErrVersionConflict)
+}
+
+func IsSerializationFailureError(errorParam error) bool {
+	return errors.Is(errorParam, ErrSerializationFailure)
+}
+
+// NewFieldTooLongError returns an error that will be matched by IsFieldTooLongError
+func NewFieldTooLongError(errString string) error {
+	return fmt.Errorf("%s: %w", errString, ErrFieldTooLong)
+}
+
+func IsFieldTooLongError(errorParam error) bool {
+	return errors.Is(errorParam, ErrFieldTooLong)
+}
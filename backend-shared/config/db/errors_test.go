@@ -0,0 +1,133 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakePGError implements the pg.Error interface, for errors returned by PostgreSQL.
+type fakePGError struct {
+	code string
+}
+
+func (e fakePGError) Error() string {
+	return "ERROR #" + e.code + " fake error"
+}
+
+func (e fakePGError) Field(field byte) string {
+	if field == 'C' {
+		return e.code
+	}
+	return ""
+}
+
+func (e fakePGError) IntegrityViolation() bool {
+	return e.code[0:2] == "23"
+}
+
+func TestMapDBError(t *testing.T) {
+
+	for _, c := range []struct {
+		// name is human-readable test name
+		name             string
+		err              error
+		expectedSentinel error
+	}{
+		{
+			name:             "no rows is mapped to ErrNotFound",
+			err:              pg.ErrNoRows,
+			expectedSentinel: ErrNotFound,
+		},
+		{
+			name:             "unique violation is mapped to ErrUniqueViolation",
+			err:              fakePGError{code: pgErrorCode_UniqueViolation},
+			expectedSentinel: ErrUniqueViolation,
+		},
+		{
+			name:             "foreign key violation is mapped to ErrForeignKeyViolation",
+			err:              fakePGError{code: pgErrorCode_ForeignKeyViolation},
+			expectedSentinel: ErrForeignKeyViolation,
+		},
+		{
+			name:             "serialization failure is mapped to ErrSerializationFailure",
+			err:              fakePGError{code: pgErrorCode_SerializationFailure},
+			expectedSentinel: ErrSerializationFailure,
+		},
+		{
+			name:             "string data right truncation is mapped to ErrFieldTooLong",
+			err:              fakePGError{code: pgErrorCode_StringDataRightTruncation},
+			expectedSentinel: ErrFieldTooLong,
+		},
+		{
+			name:             "other PostgreSQL errors are not mapped",
+			err:              fakePGError{code: "42P01"},
+			expectedSentinel: nil,
+		},
+		{
+			name:             "other errors are not mapped",
+			err:              fmt.Errorf("some other error"),
+			expectedSentinel: nil,
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+
+			mappedErr := mapDBError(c.err)
+
+			// The original error message is preserved
+			assert.Equal(t, c.err.Error(), mappedErr.Error())
+
+			if c.expectedSentinel == nil {
+				assert.Equal(t, c.err, mappedErr)
+				return
+			}
+
+			assert.True(t, errors.Is(mappedErr, c.expectedSentinel))
+
+			var dbErr *DBError
+			assert.True(t, errors.As(mappedErr, &dbErr))
+			assert.Equal(t, c.err, dbErr.Cause)
+
+			// Mapped errors are still matched when wrapped, and are not mapped twice
+			wrappedErr := fmt.Errorf("error on retrieving: %w", mappedErr)
+			assert.True(t, errors.Is(wrappedErr, c.expectedSentinel))
+			assert.Equal(t, wrappedErr, mapDBError(wrappedErr))
+		})
+	}
+
+	assert.Nil(t, mapDBError(nil))
+	assert.Equal(t, pgErrorCode_UniqueViolation, mapDBError(fakePGError{code: pgErrorCode_UniqueViolation}).(*DBError).Code())
+}
+
+func TestIsErrorFunctions(t *testing.T) {
+
+	assert.True(t, IsResultNotFoundError(NewResultNotFoundError("operation")))
+	assert.True(t, IsResultNotFoundError(fmt.Errorf("wrapped: %w", mapDBError(pg.ErrNoRows))))
+	assert.False(t, IsResultNotFoundError(NewAccessDeniedError("operation")))
+
+	assert.True(t, IsAccessDeniedError(NewAccessDeniedError("operation")))
+	assert.False(t, IsAccessDeniedError(NewResultNotFoundError("operation")))
+
+	assert.True(t, IsConflictError(NewConflictError("operation")))
+
+	// The specific conflict errors are also conflict errors, but are not matched by each other
+	assert.True(t, IsUniqueViolationError(NewUniqueViolationError("operation")))
+	assert.True(t, IsForeignKeyViolationError(NewForeignKeyViolationError("operation")))
+	assert.True(t, IsVersionConflictError(NewVersionConflictError("operation")))
+	assert.True(t, IsSerializationFailureError(mapDBError(fakePGError{code: pgErrorCode_SerializationFailure})))
+	assert.True(t, IsConflictError(NewUniqueViolationError("operation")))
+	assert.True(t, IsConflictError(NewVersionConflictError("operation")))
+	assert.False(t, IsVersionConflictError(NewUniqueViolationError("operation")))
+	assert.False(t, IsUniqueViolationError(NewForeignKeyViolationError("operation")))
+	assert.False(t, IsVersionConflictError(NewConflictError("operation")))
+	assert.True(t, IsFieldTooLongError(NewFieldTooLongError("operation")))
+
+	// Errors are matched by type, not by message text
+	assert.False(t, IsResultNotFoundError(fmt.Errorf("operation: no rows in result set")))
+	assert.False(t, IsResultNotFoundError(nil))
+
+	assert.Equal(t, "operation: no rows in result set", NewResultNotFoundError("operation").Error())
+}
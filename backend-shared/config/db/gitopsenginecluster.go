@@ -20,7 +20,7 @@ func (dbq *PostgreSQLDatabaseQueries) GetGitopsEngineClusterById(ctx context.Con
 		Where("gitopsenginecluster_id = ?", gitopsEngineCluster.Gitopsenginecluster_id).
 		Context(ctx).
 		Select(); err != nil {
-		return fmt.Errorf("error on retrieving GitopsEngineCluster '%s': %v", gitopsEngineCluster.Gitopsenginecluster_id, err)
+		return fmt.Errorf("error on retrieving GitopsEngineCluster '%s': %w", gitopsEngineCluster.Gitopsenginecluster_id, mapDBError(err))
 	}
 
 	if len(dbResultEngineClusters) == 0 {
@@ -71,7 +71,7 @@ func (dbq *PostgreSQLDatabaseQueries) CheckedGetGitopsEngineClusterById(ctx cont
 		Where("gitopsenginecluster_id = ?", gitopsEngineCluster.Gitopsenginecluster_id).
 		Context(ctx).
 		Select(); err != nil {
-		return fmt.Errorf("error on retrieving GitopsEngineCluster '%s': %v", gitopsEngineCluster.Gitopsenginecluster_id, err)
+		return fmt.Errorf("error on retrieving GitopsEngineCluster '%s': %w", gitopsEngineCluster.Gitopsenginecluster_id, mapDBError(err))
 	}
 
 	if len(dbResultEngineClusters) == 0 {
@@ -106,7 +106,7 @@ func (dbq *PostgreSQLDatabaseQueries) CheckedListGitopsEngineClusterByCredential
 		Select(); err != nil {
 		// TODO: GITOPS-1702 - PERF -  Add an index for this function, if it's actually used for anything
 
-		return fmt.Errorf("error on retrieving GetGitopsEngineClusterByCredentialId: %v", err)
+		return fmt.Errorf("error on retrieving GetGitopsEngineClusterByCredentialId: %w", mapDBError(err))
 	}
 
 	if len(dbGitopsEngineClustersWithCreds) == 0 {
@@ -121,7 +121,7 @@ func (dbq *PostgreSQLDatabaseQueries) CheckedListGitopsEngineClusterByCredential
 		// Return engine instances that are owned by 'ownerid', and are running on cluster 'id'
 		var dbEngineInstances []GitopsEngineInstance
 		if err := dbq.CheckedListAllGitopsEngineInstancesForGitopsEngineClusterIdAndOwnerId(ctx, gitopsEngineCluster.Gitopsenginecluster_id, ownerId, &dbEngineInstances); err != nil {
-			return fmt.Errorf("unable to list engine instance for '%s', owner '%s', error: %v", gitopsEngineCluster.Gitopsenginecluster_id, ownerId, err)
+			return fmt.Errorf("unable to list engine instance for '%s', owner '%s', error: %w", gitopsEngineCluster.Gitopsenginecluster_id, ownerId, mapDBError(err))
 		}
 
 		// For security reasons, there should be at least one gitops engine instance that is running on the cluster, that
@@ -161,7 +161,7 @@ func (dbq *PostgreSQLDatabaseQueries) CreateGitopsEngineCluster(ctx context.Cont
 
 	result, err := dbq.dbConnection.Model(obj).Context(ctx).Insert()
 	if err != nil {
-		return fmt.Errorf("error on inserting engine cluster: %v", err)
+		return fmt.Errorf("error on inserting engine cluster: %w", mapDBError(err))
 	}
 
 	if result.RowsAffected() != 1 {
@@ -180,7 +180,7 @@ func (dbq *PostgreSQLDatabaseQueries) UnsafeListAllGitopsEngineClusters(ctx cont
 
 	err := dbq.dbConnection.Model(gitopsEngineClusters).Context(ctx).Select()
 	if err != nil {
-		return err
+		return mapDBError(err)
 	}
 
 	return nil
@@ -198,8 +198,66 @@ func (dbq *PostgreSQLDatabaseQueries) DeleteGitopsEngineClusterById(ctx context.
 
 	deleteResult, err := dbq.dbConnection.Model(result).WherePK().Context(ctx).Delete()
 	if err != nil {
-		return 0, fmt.Errorf("error on deleting gitops engine: %v", err)
+		return 0, fmt.Errorf("error on deleting gitops engine: %w", mapDBError(err))
 	}
 
 	return deleteResult.RowsAffected(), nil
 }
+
+// UpdateGitopsEngineClusterHeartbeat updates only the heartbeat fields of the GitopsEngineCluster: this is called
+// periodically by the cluster-agent running on the cluster.
+func (dbq *PostgreSQLDatabaseQueries) UpdateGitopsEngineClusterHeartbeat(ctx context.Context, obj *GitopsEngineCluster) error {
+
+	if err := validateQueryParamsEntity(obj, dbq); err != nil {
+		return err
+	}
+
+	if err := isEmptyValues("UpdateGitopsEngineClusterHeartbeat",
+		"Gitopsenginecluster_id", obj.Gitopsenginecluster_id); err != nil {
+		return err
+	}
+
+	if obj.Heartbeat_last_seen.IsZero() {
+		return fmt.Errorf("heartbeat time should not be empty")
+	}
+
+	result, err := dbq.dbConnection.Model(obj).
+		Column("heartbeat_last_seen", "heartbeat_agent_version", "heartbeat_argocd_version", "heartbeat_capacity").
+		WherePK().Context(ctx).Update()
+	if err != nil {
+		return fmt.Errorf("error on updating gitops engine cluster heartbeat: %w", mapDBError(err))
+	}
+
+	if result.RowsAffected() != 1 {
+		return NewResultNotFoundError(fmt.Sprintf("unexpected number of rows affected on updating heartbeat of '%s': %d",
+			obj.Gitopsenginecluster_id, result.RowsAffected()))
+	}
+
+	return nil
+}
+
+// ListGitopsEngineClusterHeartbeats returns the id, and heartbeat fields, of every GitopsEngineCluster. Only these fields
+// are returned (for example, the cluster credentials are not), which allows this function to be used to report on
+// the availability of the service.
+func (dbq *PostgreSQLDatabaseQueries) ListGitopsEngineClusterHeartbeats(ctx context.Context, gitopsEngineClusters *[]GitopsEngineCluster) error {
+
+	if err := validateQueryParamsNoPK(dbq); err != nil {
+		return err
+	}
+
+	var res []GitopsEngineCluster
+	if err := dbq.dbConnection.Model(&res).
+		Column("gitopsenginecluster_id", "heartbeat_last_seen", "heartbeat_agent_version", "heartbeat_argocd_version", "heartbeat_capacity").
+		Order("seq_id ASC").
+		Context(ctx).Select(); err != nil {
+		return fmt.Errorf("error on listing gitops engine cluster heartbeats: %w", mapDBError(err))
+	}
+
+	if res == nil {
+		res = []GitopsEngineCluster{}
+	}
+
+	*gitopsEngineClusters = res
+
+	return nil
+}
@@ -12,9 +12,28 @@ func (dbq *PostgreSQLDatabaseQueries) UnsafeListAllGitopsEngineInstances(ctx con
 	}
 
 	if err := dbq.dbConnection.Model(gitopsEngineInstances).Context(ctx).Select(); err != nil {
+		return mapDBError(err)
+	}
+
+	return nil
+}
+
+// ListGitopsEngineInstances returns all GitOps engine instances, for use by background processes that act on every
+// instance (for example, garbage collection of Operation CRs).
+func (dbq *PostgreSQLDatabaseQueries) ListGitopsEngineInstances(ctx context.Context, gitopsEngineInstances *[]GitopsEngineInstance) error {
+
+	if err := validateQueryParamsEntity(gitopsEngineInstances, dbq); err != nil {
 		return err
 	}
 
+	var dbResults []GitopsEngineInstance
+
+	if err := dbq.dbConnection.Model(&dbResults).Order("seq_id ASC").Context(ctx).Select(); err != nil {
+		return fmt.Errorf("error on retrieving ListGitopsEngineInstances: %w", mapDBError(err))
+	}
+
+	*gitopsEngineInstances = dbResults
+
 	return nil
 }
 
@@ -39,7 +58,7 @@ func (dbq *PostgreSQLDatabaseQueries) CheckedListAllGitopsEngineInstancesForGito
 		Join("JOIN ClusterAccess as ca ON ca.clusteraccess_gitops_engine_instance_id = gei.gitopsengineinstance_id").
 		Context(ctx).
 		Select(); err != nil {
-		return err
+		return mapDBError(err)
 	}
 
 	*gitopsEngineInstancesParam = dbGitopsEngineInstances
@@ -65,7 +84,7 @@ func (dbq *PostgreSQLDatabaseQueries) GetGitopsEngineInstanceById(ctx context.Co
 		Context(ctx).
 		Select(); err != nil {
 
-		return fmt.Errorf("error on retrieving GetGitopsEngineInstanceById: %v", err)
+		return fmt.Errorf("error on retrieving GetGitopsEngineInstanceById: %w", mapDBError(err))
 	}
 
 	if len(res) >= 2 {
@@ -104,7 +123,7 @@ func (dbq *PostgreSQLDatabaseQueries) CheckedGetGitopsEngineInstanceById(ctx con
 		Context(ctx).
 		Select(); err != nil {
 
-		return fmt.Errorf("error on retrieving GetGitopsEngineInstanceById: %v", err)
+		return fmt.Errorf("error on retrieving GetGitopsEngineInstanceById: %w", mapDBError(err))
 	}
 
 	if len(res) >= 2 {
@@ -151,7 +170,7 @@ func (dbq *PostgreSQLDatabaseQueries) CreateGitopsEngineInstance(ctx context.Con
 
 	result, err := dbq.dbConnection.Model(obj).Context(ctx).Insert()
 	if err != nil {
-		return fmt.Errorf("error on inserting gitops engine instance: %v", err)
+		return fmt.Errorf("error on inserting gitops engine instance: %w", mapDBError(err))
 	}
 
 	if result.RowsAffected() != 1 {
@@ -191,7 +210,7 @@ func (dbq *PostgreSQLDatabaseQueries) internalDeleteGitopsEngineInstanceById(ctx
 		existingValue := GitopsEngineInstance{Gitopsengineinstance_id: id}
 		err := dbq.CheckedGetGitopsEngineInstanceById(ctx, &existingValue, ownerId)
 		if err != nil || existingValue.Gitopsengineinstance_id != id {
-			return 0, fmt.Errorf("unable to locate gitops engine instance id, or access denied: '%s', %v", id, err)
+			return 0, fmt.Errorf("unable to locate gitops engine instance id, or access denied: '%s', %w", id, mapDBError(err))
 		}
 	}
 
@@ -201,7 +220,7 @@ func (dbq *PostgreSQLDatabaseQueries) internalDeleteGitopsEngineInstanceById(ctx
 
 	deleteResult, err := dbq.dbConnection.Model(result).WherePK().Context(ctx).Delete()
 	if err != nil {
-		return 0, fmt.Errorf("error on deleting operation: %v", err)
+		return 0, fmt.Errorf("error on deleting operation: %w", mapDBError(err))
 	}
 
 	return deleteResult.RowsAffected(), nil
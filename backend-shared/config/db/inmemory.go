@@ -0,0 +1,423 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// InMemoryDatabaseQueries is an in-memory implementation of the database queries, for use by unit tests that should not
+// require a PostgreSQL database. It is not intended for use outside of tests.
+//
+// The queries behave as those of PostgreSQLDatabaseQueries, which is verified by the conformance tests in this package:
+// the same parameters are validated, and the same ownership checks are performed by the Checked* functions. The
+// primary key, unique, and foreign key constraints of 'db-schema.sql' are enforced (violations return a conflict error,
+// see 'IsUniqueViolationError' and 'IsForeignKeyViolationError'), as are the lengths of VARCHAR columns (see 'IsFieldTooLongError'). Get functions return a
+// not found error (see 'IsResultNotFoundError') when no rows match.
+//
+// All rows are copied on read and write, so callers cannot modify the stored rows, and the queries are safe for
+// concurrent use. RunInTransaction holds an exclusive lock on the database for the duration of the transaction.
+type InMemoryDatabaseQueries struct {
+	store *inMemoryStore
+
+	// locked is true if the store lock is already held by the caller: for example, within a transaction, or within
+	// a query that calls other queries.
+	locked bool
+
+	// allowTestUuids, if true, will allow callers to pass an id value into the db create methods.
+	allowTestUuids bool
+
+	// allowUnsafe, if true, allows queries that act on the entire database: see PostgreSQLDatabaseQueries.
+	allowUnsafe bool
+
+	// clusterCredentialsKeyring is used to encrypt the secret fields of ClusterCredentials: unlike
+	// PostgreSQLDatabaseQueries, it is not read from the environment, and is nil (no encryption) unless set by a test.
+	clusterCredentialsKeyring *ClusterCredentialsKeyring
+}
+
+var _ AllDatabaseQueries = &InMemoryDatabaseQueries{}
+
+// NewInMemoryDBQueries returns in-memory database queries that behave as those returned by NewProductionPostgresDBQueries.
+func NewInMemoryDBQueries() DatabaseQueries {
+	return newInMemoryDBQueries(false, false)
+}
+
+// NewUnsafeInMemoryDBQueries returns in-memory database queries that behave as those returned by NewUnsafePostgresDBQueries.
+func NewUnsafeInMemoryDBQueries(allowTestUuids bool) AllDatabaseQueries {
+	return newInMemoryDBQueries(allowTestUuids, true)
+}
+
+func newInMemoryDBQueries(allowTestUuids bool, allowUnsafe bool) *InMemoryDatabaseQueries {
+	return &InMemoryDatabaseQueries{
+		store:          &inMemoryStore{tables: newInMemoryTables()},
+		allowTestUuids: allowTestUuids,
+		allowUnsafe:    allowUnsafe,
+	}
+}
+
+type inMemoryStore struct {
+	mutex sync.Mutex
+
+	tables inMemoryTables
+
+	// lastSeqID is the last value of the 'seq_id' column that was assigned: as with a PostgreSQL sequence, it is not
+	// rolled back when a transaction is rolled back.
+	lastSeqID int64
+}
+
+// inMemoryTables contains the rows of each table, by primary key.
+type inMemoryTables struct {
+	clusterCredentials             map[string]ClusterCredentials
+	gitopsEngineClusters           map[string]GitopsEngineCluster
+	gitopsEngineInstances          map[string]GitopsEngineInstance
+	managedEnvironments            map[string]ManagedEnvironment
+	clusterUsers                   map[string]ClusterUser
+	clusterAccess                  map[clusterAccessKey]ClusterAccess
+	operations                     map[string]Operation
+	applications                   map[string]Application
+	applicationStates              map[string]ApplicationState
+	deploymentToApplicationMapping map[string]DeploymentToApplicationMapping
+	kubernetesToDBResourceMappings map[resourceMappingKey]KubernetesToDBResourceMapping
+	apiCRToDatabaseMappings        map[resourceMappingKey]APICRToDatabaseMapping
+	syncOperations                 map[string]SyncOperation
+}
+
+type clusterAccessKey struct {
+	userID                 string
+	managedEnvironmentID   string
+	gitopsEngineInstanceID string
+}
+
+// resourceMappingKey is the primary key of both KubernetesToDBResourceMapping and APICRToDatabaseMapping
+type resourceMappingKey struct {
+	resourceType   string
+	resourceUID    string
+	dbRelationType string
+	dbRelationKey  string
+}
+
+func newInMemoryTables() inMemoryTables {
+	return inMemoryTables{
+		clusterCredentials:             map[string]ClusterCredentials{},
+		gitopsEngineClusters:           map[string]GitopsEngineCluster{},
+		gitopsEngineInstances:          map[string]GitopsEngineInstance{},
+		managedEnvironments:            map[string]ManagedEnvironment{},
+		clusterUsers:                   map[string]ClusterUser{},
+		clusterAccess:                  map[clusterAccessKey]ClusterAccess{},
+		operations:                     map[string]Operation{},
+		applications:                   map[string]Application{},
+		applicationStates:              map[string]ApplicationState{},
+		deploymentToApplicationMapping: map[string]DeploymentToApplicationMapping{},
+		kubernetesToDBResourceMappings: map[resourceMappingKey]KubernetesToDBResourceMapping{},
+		apiCRToDatabaseMappings:        map[resourceMappingKey]APICRToDatabaseMapping{},
+		syncOperations:                 map[string]SyncOperation{},
+	}
+}
+
+// clone returns a copy of the tables: rows are stored by value, so copying the maps is sufficient.
+func (tables inMemoryTables) clone() inMemoryTables {
+	res := newInMemoryTables()
+	for k, v := range tables.clusterCredentials {
+		res.clusterCredentials[k] = v
+	}
+	for k, v := range tables.gitopsEngineClusters {
+		res.gitopsEngineClusters[k] = v
+	}
+	for k, v := range tables.gitopsEngineInstances {
+		res.gitopsEngineInstances[k] = v
+	}
+	for k, v := range tables.managedEnvironments {
+		res.managedEnvironments[k] = v
+	}
+	for k, v := range tables.clusterUsers {
+		res.clusterUsers[k] = v
+	}
+	for k, v := range tables.clusterAccess {
+		res.clusterAccess[k] = v
+	}
+	for k, v := range tables.operations {
+		res.operations[k] = v
+	}
+	for k, v := range tables.applications {
+		res.applications[k] = v
+	}
+	for k, v := range tables.applicationStates {
+		res.applicationStates[k] = v
+	}
+	for k, v := range tables.deploymentToApplicationMapping {
+		res.deploymentToApplicationMapping[k] = v
+	}
+	for k, v := range tables.kubernetesToDBResourceMappings {
+		res.kubernetesToDBResourceMappings[k] = v
+	}
+	for k, v := range tables.apiCRToDatabaseMappings {
+		res.apiCRToDatabaseMappings[k] = v
+	}
+	for k, v := range tables.syncOperations {
+		res.syncOperations[k] = v
+	}
+	return res
+}
+
+// lock acquires the store lock (unless it is already held by the caller), and returns queries that may be used while
+// the lock is held, plus the function which releases the lock.
+func (dbq *InMemoryDatabaseQueries) lock() (*InMemoryDatabaseQueries, func()) {
+
+	if dbq.locked {
+		return dbq, func() {}
+	}
+
+	dbq.store.mutex.Lock()
+
+	lockedQueries := *dbq
+	lockedQueries.locked = true
+
+	return &lockedQueries, dbq.store.mutex.Unlock
+}
+
+func (dbq *InMemoryDatabaseQueries) tables() *inMemoryTables {
+	return &dbq.store.tables
+}
+
+func (dbq *InMemoryDatabaseQueries) nextSeqID() int64 {
+	dbq.store.lastSeqID++
+	return dbq.store.lastSeqID
+}
+
+// CloseDatabase does nothing: the in-memory database has no resources to release, and may be shared between tests.
+func (dbq *InMemoryDatabaseQueries) CloseDatabase() {
+}
+
+func (dbq *InMemoryDatabaseQueries) RunInTransaction(ctx context.Context, fn func(tx ApplicationScopedQueries) error) error {
+
+	// Already within a transaction (or a query)
+	if dbq.locked {
+		return fn(dbq)
+	}
+
+	txQueries, unlock := dbq.lock()
+
+	// The lock is held for the duration of the transaction, so no other writes can occur: the transaction is rolled
+	// back by restoring the tables as they were before the transaction.
+	snapshot := txQueries.tables().clone()
+	committed := false
+
+	defer func() {
+		if !committed {
+			txQueries.store.tables = snapshot
+		}
+		unlock()
+	}()
+
+	if err := fn(txQueries); err != nil {
+		return err
+	}
+
+	committed = true
+
+	return nil
+}
+
+// validateInMemoryQueryParamsEntity is the equivalent of 'validateQueryParamsEntity': in addition, a nil pointer
+// is reported as an error.
+func validateInMemoryQueryParamsEntity(entity interface{}) error {
+
+	if entity == nil {
+		return fmt.Errorf("query parameter value is nil")
+	}
+
+	if value := reflect.ValueOf(entity); value.Kind() == reflect.Ptr && value.IsNil() {
+		return fmt.Errorf("query parameter value is nil")
+	}
+
+	return nil
+}
+
+// validateInMemoryQueryParams is the equivalent of 'validateQueryParams'
+func validateInMemoryQueryParams(entityId string) error {
+
+	if isEmpty(entityId) {
+		return fmt.Errorf("primary key is empty")
+	}
+
+	return nil
+}
+
+// validateUnsafe returns an error if unsafe queries are not allowed: see 'validateUnsafeQueryParamsNoPK'
+func (dbq *InMemoryDatabaseQueries) validateUnsafe() error {
+
+	if !dbq.allowUnsafe {
+		return fmt.Errorf("unsafe operation is not allowed in this context")
+	}
+
+	return nil
+}
+
+// generatePrimaryKey sets the primary key of a new row, as is done by the Create functions of PostgreSQLDatabaseQueries.
+func (dbq *InMemoryDatabaseQueries) generatePrimaryKey(primaryKey *string) error {
+
+	if dbq.allowTestUuids {
+		if isEmpty(*primaryKey) {
+			*primaryKey = generateUuid()
+		}
+	} else {
+		if !isEmpty(*primaryKey) {
+			return fmt.Errorf("primary key should be empty")
+		}
+		*primaryKey = generateUuid()
+	}
+
+	return nil
+}
+
+// newDuplicateKeyError returns the equivalent of a unique constraint violation
+func newDuplicateKeyError(table string, key interface{}) error {
+	return NewUniqueViolationError(fmt.Sprintf("duplicate key value violates unique constraint of table '%s': %v", table, key))
+}
+
+// newMissingReferenceError returns the equivalent of a foreign key constraint violation, on insert or update
+func newMissingReferenceError(table string, column string, key string) error {
+	return NewForeignKeyViolationError(fmt.Sprintf("insert or update on table '%s' violates foreign key constraint: '%s' references '%s', which does not exist",
+		table, column, key))
+}
+
+// newReferencedRowError returns the equivalent of a foreign key constraint violation, on delete
+func newReferencedRowError(table string, key string, referencingTable string) error {
+	return NewForeignKeyViolationError(fmt.Sprintf("delete on table '%s' violates foreign key constraint: '%s' is still referenced from table '%s'",
+		table, key, referencingTable))
+}
+
+// inMemoryColumnLengths is the maximum length of the VARCHAR columns of each table: these values should be equivalent
+// to those of 'db-schema.sql'.
+var inMemoryColumnLengths = map[string]map[string]int{
+	"clustercredentials": {
+		"clustercredentials_cred_id":  48,
+		"host":                        512,
+		"kube_config":                 100000,
+		"kube_config_context":         64,
+		"serviceaccount_bearer_token": 4096,
+		"serviceaccount_ns":           128,
+	},
+	"gitopsenginecluster": {
+		"gitopsenginecluster_id":   48,
+		"clustercredentials_id":    48,
+		"heartbeat_agent_version":  GitopsEngineClusterHeartbeatAgentVersionLength,
+		"heartbeat_argocd_version": GitopsEngineClusterHeartbeatArgoCDVersionLength,
+	},
+	"gitopsengineinstance": {
+		"gitopsengineinstance_id": 48,
+		"namespace_name":          48,
+		"namespace_uid":           48,
+		"enginecluster_id":        48,
+	},
+	"managedenvironment": {
+		"managedenvironment_id": 48,
+		"name":                  256,
+		"clustercredentials_id": 48,
+	},
+	"clusteruser": {
+		"clusteruser_id": 48,
+		"user_name":      256,
+	},
+	"clusteraccess": {
+		"clusteraccess_user_id":                   48,
+		"clusteraccess_managed_environment_id":    48,
+		"clusteraccess_gitops_engine_instance_id": 48,
+	},
+	"operation": {
+		"operation_id":            48,
+		"instance_id":             48,
+		"resource_id":             48,
+		"operation_owner_user_id": 48,
+		"resource_type":           32,
+		"state":                   30,
+		"human_readable_state":    OperationHumanReadableStateLength,
+	},
+	"application": {
+		"application_id":          48,
+		"name":                    256,
+		"spec_field":              16384,
+		"engine_instance_inst_id": 48,
+		"managed_environment_id":  48,
+	},
+	"applicationstate": {
+		"applicationstate_application_id": 48,
+		"health":                          ApplicationstateHealthLength,
+		"message":                         ApplicationstateMessageLength,
+		"revision":                        ApplicationstateRevisionLength,
+		"sync_status":                     ApplicationstateSyncstatusLength,
+		"conditions":                      ApplicationstateConditionsLength,
+		"operation_phase":                 ApplicationstateOperationPhaseLength,
+		"operation_message":               ApplicationstateOperationMessageLength,
+	},
+	"deploymenttoapplicationmapping": {
+		"deploymenttoapplicationmapping_uid_id": 48,
+		"name":                                  256,
+		"namespace":                             96,
+		"workspace_uid":                         48,
+		"application_id":                        48,
+	},
+	"kubernetestodbresourcemapping": {
+		"kubernetes_resource_type": 64,
+		"kubernetes_resource_uid":  64,
+		"db_relation_type":         64,
+		"db_relation_key":          64,
+	},
+	"apicrtodatabasemapping": {
+		"api_resource_type":          64,
+		"api_resource_uid":           64,
+		"api_resource_name":          256,
+		"api_resource_namespace":     256,
+		"api_resource_workspace_uid": 64,
+		"db_relation_type":           32,
+		"db_relation_key":            64,
+	},
+	"syncoperation": {
+		"syncoperation_id":      48,
+		"application_id":        48,
+		"operation_id":          48,
+		"deployment_name":       256,
+		"revision":              256,
+		"desired_state":         16,
+		"sync_result_phase":     SyncOperationSyncResultPhaseLength,
+		"sync_result_message":   SyncOperationSyncResultMessageLength,
+		"sync_result_resources": SyncOperationSyncResultResourcesLength,
+	},
+}
+
+// checkColumnLengths returns an error if a string field of 'row' (a pointer to a table struct) is longer than its
+// VARCHAR column: the column of each field is determined by its 'pg' tag.
+func checkColumnLengths(table string, row interface{}) error {
+
+	columnLengths, exists := inMemoryColumnLengths[table]
+	if !exists {
+		return fmt.Errorf("SEVERE: unknown table '%s'", table)
+	}
+
+	value := reflect.ValueOf(row).Elem()
+
+	for i := 0; i < value.NumField(); i++ {
+
+		field := value.Type().Field(i)
+		if field.Type.Kind() != reflect.String {
+			continue
+		}
+
+		column := strings.Split(field.Tag.Get("pg"), ",")[0]
+
+		maxLength, exists := columnLengths[column]
+		if !exists {
+			continue
+		}
+
+		if length := utf8.RuneCountInString(value.Field(i).String()); length > maxLength {
+			return NewFieldTooLongError(fmt.Sprintf("value of length %d is too long for column '%s' of table '%s' (maximum %d)",
+				length, column, table, maxLength))
+		}
+	}
+
+	return nil
+}
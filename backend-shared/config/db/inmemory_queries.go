@@ -0,0 +1,3248 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// The queries of InMemoryDatabaseQueries: each query mirrors the validation, and the results, of the equivalent
+// PostgreSQLDatabaseQueries query. See 'inmemory.go'.
+
+// Application
+
+func (dbq *InMemoryDatabaseQueries) CheckedGetApplicationById(ctx context.Context, application *Application, ownerId string) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParamsEntity(application); err != nil {
+		return err
+	}
+
+	if isEmpty(application.Application_id) {
+		return fmt.Errorf("application_Id is nil in GetApplicationById")
+	}
+
+	applicationResult, exists := dbq.tables().applications[application.Application_id]
+	if !exists {
+		return NewResultNotFoundError(fmt.Sprintf("Application '%s'", application.Application_id))
+	}
+
+	// Ensure there is a cluster access for this user, and the application's managed env and engine instance
+	if err := dbq.GetClusterAccessByPrimaryKey(ctx,
+		&ClusterAccess{Clusteraccess_user_id: ownerId,
+			Clusteraccess_managed_environment_id:    applicationResult.Managed_environment_id,
+			Clusteraccess_gitops_engine_instance_id: applicationResult.Engine_instance_inst_id}); err != nil {
+
+		if IsResultNotFoundError(err) {
+			return NewAccessDeniedError(fmt.Sprintf("No cluster access exists for application '%s'", application.Application_id))
+		}
+		return err
+	}
+
+	*application = applicationResult
+
+	return nil
+}
+
+func (dbq *InMemoryDatabaseQueries) GetApplicationById(ctx context.Context, application *Application) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParamsEntity(application); err != nil {
+		return err
+	}
+
+	if isEmpty(application.Application_id) {
+		return fmt.Errorf("application_Id is nil")
+	}
+
+	result, exists := dbq.tables().applications[application.Application_id]
+	if !exists {
+		return NewResultNotFoundError(fmt.Sprintf("Application '%s'", application.Application_id))
+	}
+
+	*application = result
+
+	return nil
+}
+
+// LockApplicationById retrieves the Application: the transaction holds an exclusive lock on the in-memory database,
+// so there is no need to lock the row itself.
+func (dbq *InMemoryDatabaseQueries) LockApplicationById(ctx context.Context, application *Application) error {
+
+	if !dbq.locked {
+		return fmt.Errorf("LockApplicationById must be called within a transaction")
+	}
+
+	return dbq.GetApplicationById(ctx, application)
+}
+
+func (dbq *InMemoryDatabaseQueries) CheckedCreateApplication(ctx context.Context, obj *Application, ownerId string) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParamsEntity(obj); err != nil {
+		return err
+	}
+
+	if err := dbq.generatePrimaryKey(&obj.Application_id); err != nil {
+		return err
+	}
+
+	if err := isEmptyValues("CreateApplication",
+		"Engine_instance_inst_id", obj.Engine_instance_inst_id,
+		"Managed_environment_id", obj.Managed_environment_id,
+		"Spec_field", obj.Spec_field,
+		"Name", obj.Name); err != nil {
+		return err
+	}
+
+	// Verify the user can access the managed environment
+	managedEnv := ManagedEnvironment{Managedenvironment_id: obj.Managed_environment_id}
+	if err := dbq.CheckedGetManagedEnvironmentById(ctx, &managedEnv, ownerId); err != nil {
+		return fmt.Errorf("on creating Application, unable to retrieve managed environment %s for user %s: %w", obj.Managed_environment_id, ownerId, err)
+	}
+
+	obj.Version = 1
+	obj.Created_on = time.Now()
+
+	if err := dbq.insertApplication(obj); err != nil {
+		return fmt.Errorf("error on inserting application: %w", err)
+	}
+
+	return nil
+}
+
+func (dbq *InMemoryDatabaseQueries) UnsafeListAllApplications(ctx context.Context, applications *[]Application) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := dbq.validateUnsafe(); err != nil {
+		return err
+	}
+
+	*applications = dbq.listApplications(func(Application) bool { return true })
+
+	return nil
+}
+
+func (dbq *InMemoryDatabaseQueries) CheckedDeleteApplicationById(ctx context.Context, id string, ownerId string) (int, error) {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParams(id); err != nil {
+		return 0, err
+	}
+
+	result := &Application{
+		Application_id: id,
+	}
+
+	if err := dbq.CheckedGetApplicationById(ctx, result, ownerId); err != nil {
+		if IsResultNotFoundError(err) {
+			return 0, nil
+		}
+
+		return 0, err
+	}
+
+	rowsAffected, err := dbq.deleteApplication(id)
+	if err != nil {
+		return 0, fmt.Errorf("error on deleting application: %w", err)
+	}
+
+	return rowsAffected, nil
+}
+
+func (dbq *InMemoryDatabaseQueries) DeleteApplicationById(ctx context.Context, id string) (int, error) {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParams(id); err != nil {
+		return 0, err
+	}
+
+	rowsAffected, err := dbq.deleteApplication(id)
+	if err != nil {
+		return 0, fmt.Errorf("error on deleting application: %w", err)
+	}
+
+	return rowsAffected, nil
+}
+
+func (dbq *InMemoryDatabaseQueries) CreateApplication(ctx context.Context, obj *Application) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParamsEntity(obj); err != nil {
+		return err
+	}
+
+	if err := dbq.generatePrimaryKey(&obj.Application_id); err != nil {
+		return err
+	}
+
+	if err := isEmptyValues("CreateApplication",
+		"Engine_instance_inst_id", obj.Engine_instance_inst_id,
+		"Managed_environment_id", obj.Managed_environment_id,
+		"Spec_field", obj.Spec_field,
+		"Name", obj.Name); err != nil {
+		return err
+	}
+
+	obj.Version = 1
+	obj.Created_on = time.Now()
+
+	if err := dbq.insertApplication(obj); err != nil {
+		return fmt.Errorf("error on inserting application %w", err)
+	}
+
+	return nil
+}
+
+func (dbq *InMemoryDatabaseQueries) UpdateApplication(ctx context.Context, obj *Application) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParamsEntity(obj); err != nil {
+		return err
+	}
+
+	if err := isEmptyValues("UpdateApplication",
+		"Application_id", obj.Application_id,
+		"Engine_instance_inst_id", obj.Engine_instance_inst_id,
+		"Managed_environment_id", obj.Managed_environment_id,
+		"Spec_field", obj.Spec_field,
+		"Name", obj.Name); err != nil {
+		return err
+	}
+
+	// The row is only updated if it has not been updated since it was read: the version is incremented on success.
+	readVersion := obj.Version
+
+	existing, exists := dbq.tables().applications[obj.Application_id]
+	if !exists || existing.Version != readVersion {
+		return NewVersionConflictError(fmt.Sprintf("application '%s' was updated or deleted since version %d was read", obj.Application_id, readVersion))
+	}
+
+	row := *obj
+	row.Version = readVersion + 1
+	row.SeqID = existing.SeqID
+	row.Created_on = existing.Created_on
+
+	if err := dbq.checkApplication(&row); err != nil {
+		return fmt.Errorf("error on updating application %w", err)
+	}
+
+	dbq.tables().applications[row.Application_id] = row
+	obj.Version = row.Version
+
+	return nil
+}
+
+func (dbq *InMemoryDatabaseQueries) insertApplication(obj *Application) error {
+
+	if err := dbq.checkApplication(obj); err != nil {
+		return err
+	}
+
+	if _, exists := dbq.tables().applications[obj.Application_id]; exists {
+		return newDuplicateKeyError("application", obj.Application_id)
+	}
+
+	if obj.SeqID == 0 {
+		obj.SeqID = dbq.nextSeqID()
+	}
+
+	dbq.tables().applications[obj.Application_id] = *obj
+
+	return nil
+}
+
+// checkApplication verifies the column lengths and foreign keys of an inserted or updated row
+func (dbq *InMemoryDatabaseQueries) checkApplication(obj *Application) error {
+
+	if err := checkColumnLengths("application", obj); err != nil {
+		return err
+	}
+
+	if _, exists := dbq.tables().gitopsEngineInstances[obj.Engine_instance_inst_id]; !exists {
+		return newMissingReferenceError("application", "engine_instance_inst_id", obj.Engine_instance_inst_id)
+	}
+
+	if _, exists := dbq.tables().managedEnvironments[obj.Managed_environment_id]; !exists {
+		return newMissingReferenceError("application", "managed_environment_id", obj.Managed_environment_id)
+	}
+
+	return nil
+}
+
+func (dbq *InMemoryDatabaseQueries) deleteApplication(id string) (int, error) {
+
+	tables := dbq.tables()
+
+	if _, exists := tables.applications[id]; !exists {
+		return 0, nil
+	}
+
+	if _, exists := tables.applicationStates[id]; exists {
+		return 0, newReferencedRowError("application", id, "applicationstate")
+	}
+
+	for _, dtam := range tables.deploymentToApplicationMapping {
+		if dtam.Application_id == id {
+			return 0, newReferencedRowError("application", id, "deploymenttoapplicationmapping")
+		}
+	}
+
+	for _, syncOperation := range tables.syncOperations {
+		if syncOperation.Application_id == id {
+			return 0, newReferencedRowError("application", id, "syncoperation")
+		}
+	}
+
+	delete(tables.applications, id)
+
+	return 1, nil
+}
+
+// listApplications returns the Applications that match 'filter', ordered by seq_id
+func (dbq *InMemoryDatabaseQueries) listApplications(filter func(Application) bool) []Application {
+
+	res := []Application{}
+
+	for _, application := range dbq.tables().applications {
+		if filter(application) {
+			res = append(res, application)
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool { return res[i].SeqID < res[j].SeqID })
+
+	return res
+}
+
+// ApplicationState
+
+func (dbq *InMemoryDatabaseQueries) UnsafeListAllApplicationStates(ctx context.Context, applicationStates *[]ApplicationState) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := dbq.validateUnsafe(); err != nil {
+		return err
+	}
+
+	res := []ApplicationState{}
+	for _, applicationState := range dbq.tables().applicationStates {
+		res = append(res, applicationState)
+	}
+
+	// ApplicationState has no seq_id column
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Applicationstate_application_id < res[j].Applicationstate_application_id
+	})
+
+	*applicationStates = res
+
+	return nil
+}
+
+func (dbq *InMemoryDatabaseQueries) DeleteApplicationStateById(ctx context.Context, id string) (int, error) {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParams(id); err != nil {
+		return 0, err
+	}
+
+	if _, exists := dbq.tables().applicationStates[id]; !exists {
+		return 0, nil
+	}
+
+	delete(dbq.tables().applicationStates, id)
+
+	return 1, nil
+}
+
+func (dbq *InMemoryDatabaseQueries) CreateApplicationState(ctx context.Context, obj *ApplicationState) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParamsEntity(obj); err != nil {
+		return err
+	}
+
+	if err := isEmptyValues("CreateApplicationState",
+		"Applicationstate_application_id", obj.Applicationstate_application_id,
+		"Health", obj.Health,
+		"Sync_Status", obj.Sync_Status); err != nil {
+		return err
+	}
+
+	obj.Version = 1
+
+	if err := dbq.checkApplicationState(obj); err != nil {
+		return fmt.Errorf("error on inserting application %w", err)
+	}
+
+	if _, exists := dbq.tables().applicationStates[obj.Applicationstate_application_id]; exists {
+		return fmt.Errorf("error on inserting application %w",
+			newDuplicateKeyError("applicationstate", obj.Applicationstate_application_id))
+	}
+
+	dbq.tables().applicationStates[obj.Applicationstate_application_id] = *obj
+
+	return nil
+}
+
+func (dbq *InMemoryDatabaseQueries) UpdateApplicationState(ctx context.Context, obj *ApplicationState) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParamsEntity(obj); err != nil {
+		return err
+	}
+
+	if err := isEmptyValues("UpdateApplicationState",
+		"Applicationstate_application_id", obj.Applicationstate_application_id,
+		"Health", obj.Health,
+		"Sync_Status", obj.Sync_Status); err != nil {
+		return err
+	}
+
+	// The row is only updated if it has not been updated since it was read: the version is incremented on success.
+	readVersion := obj.Version
+
+	existing, exists := dbq.tables().applicationStates[obj.Applicationstate_application_id]
+	if !exists || existing.Version != readVersion {
+		return NewVersionConflictError(fmt.Sprintf("application state '%s' was updated or deleted since version %d was read",
+			obj.Applicationstate_application_id, readVersion))
+	}
+
+	row := *obj
+	row.Version = readVersion + 1
+
+	if err := dbq.checkApplicationState(&row); err != nil {
+		return fmt.Errorf("error on updating application %w", err)
+	}
+
+	dbq.tables().applicationStates[row.Applicationstate_application_id] = row
+	obj.Version = row.Version
+
+	return nil
+}
+
+func (dbq *InMemoryDatabaseQueries) GetApplicationStateById(ctx context.Context, obj *ApplicationState) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParamsEntity(obj); err != nil {
+		return err
+	}
+
+	if isEmpty(obj.Applicationstate_application_id) {
+		return fmt.Errorf("applicationstate_application_id is nil")
+	}
+
+	result, exists := dbq.tables().applicationStates[obj.Applicationstate_application_id]
+	if !exists {
+		return NewResultNotFoundError(fmt.Sprintf("ApplicationState row '%s'", obj.Applicationstate_application_id))
+	}
+
+	*obj = result
+
+	return nil
+}
+
+// checkApplicationState verifies the column lengths and foreign keys of an inserted or updated row
+func (dbq *InMemoryDatabaseQueries) checkApplicationState(obj *ApplicationState) error {
+
+	if err := checkColumnLengths("applicationstate", obj); err != nil {
+		return err
+	}
+
+	if _, exists := dbq.tables().applications[obj.Applicationstate_application_id]; !exists {
+		return newMissingReferenceError("applicationstate", "applicationstate_application_id", obj.Applicationstate_application_id)
+	}
+
+	return nil
+}
+
+// ClusterAccess
+
+func (dbq *InMemoryDatabaseQueries) UnsafeListAllClusterAccess(ctx context.Context, clusterAccess *[]ClusterAccess) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := dbq.validateUnsafe(); err != nil {
+		return err
+	}
+
+	*clusterAccess = dbq.listClusterAccess()
+
+	return nil
+}
+
+func (dbq *InMemoryDatabaseQueries) GetClusterAccessByPrimaryKey(ctx context.Context, obj *ClusterAccess) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParamsEntity(obj); err != nil {
+		return err
+	}
+
+	if err := isEmptyValues("GetClusterAccessByPrimaryKey",
+		"Clusteraccess_gitops_engine_instance_id", obj.Clusteraccess_gitops_engine_instance_id,
+		"Clusteraccess_managed_environment_id", obj.Clusteraccess_managed_environment_id,
+		"Clusteraccess_user_id", obj.Clusteraccess_user_id); err != nil {
+		return err
+	}
+
+	result, exists := dbq.tables().clusterAccess[newClusterAccessKey(obj)]
+	if !exists {
+		return NewResultNotFoundError("No results for ClusterAccess")
+	}
+
+	*obj = result
+
+	return nil
+}
+
+func (dbq *InMemoryDatabaseQueries) ListClusterAccessByClusterUserId(ctx context.Context, clusterUserId string, clusterAccess *[]ClusterAccess) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParamsEntity(clusterAccess); err != nil {
+		return err
+	}
+
+	if err := isEmptyValues("ListClusterAccessByClusterUserId", "clusterUserId", clusterUserId); err != nil {
+		return err
+	}
+
+	res := []ClusterAccess{}
+	for _, ca := range dbq.listClusterAccess() {
+		if ca.Clusteraccess_user_id == clusterUserId {
+			res = append(res, ca)
+		}
+	}
+
+	*clusterAccess = res
+
+	return nil
+}
+
+func (dbq *InMemoryDatabaseQueries) CreateClusterAccess(ctx context.Context, obj *ClusterAccess) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParamsEntity(obj); err != nil {
+		return err
+	}
+
+	if err := validateInMemoryQueryParams(obj.Clusteraccess_gitops_engine_instance_id); err != nil {
+		return err
+	}
+
+	if isEmpty(obj.Clusteraccess_managed_environment_id) {
+		return fmt.Errorf("primary key environment id should not be empty")
+	}
+
+	if isEmpty(obj.Clusteraccess_user_id) {
+		return fmt.Errorf("primary key user_id should not be empty")
+	}
+
+	if err := dbq.insertClusterAccess(obj); err != nil {
+		return fmt.Errorf("error on inserting cluster access: %w", err)
+	}
+
+	return nil
+}
+
+func (dbq *InMemoryDatabaseQueries) DeleteClusterAccessById(ctx context.Context, userId string, managedEnvironmentId string, gitopsEngineInstanceId string) (int, error) {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParams(userId); err != nil {
+		return 0, err
+	}
+
+	if isEmpty(managedEnvironmentId) {
+		return 0, fmt.Errorf("primary key is empty")
+	}
+
+	if isEmpty(gitopsEngineInstanceId) {
+		return 0, fmt.Errorf("primary key is empty")
+	}
+
+	key := clusterAccessKey{
+		userID:                 userId,
+		managedEnvironmentID:   managedEnvironmentId,
+		gitopsEngineInstanceID: gitopsEngineInstanceId,
+	}
+
+	if _, exists := dbq.tables().clusterAccess[key]; !exists {
+		return 0, nil
+	}
+
+	delete(dbq.tables().clusterAccess, key)
+
+	return 1, nil
+}
+
+func newClusterAccessKey(obj *ClusterAccess) clusterAccessKey {
+	return clusterAccessKey{
+		userID:                 obj.Clusteraccess_user_id,
+		managedEnvironmentID:   obj.Clusteraccess_managed_environment_id,
+		gitopsEngineInstanceID: obj.Clusteraccess_gitops_engine_instance_id,
+	}
+}
+
+func (dbq *InMemoryDatabaseQueries) insertClusterAccess(obj *ClusterAccess) error {
+
+	tables := dbq.tables()
+
+	if err := checkColumnLengths("clusteraccess", obj); err != nil {
+		return err
+	}
+
+	key := newClusterAccessKey(obj)
+	if _, exists := tables.clusterAccess[key]; exists {
+		return newDuplicateKeyError("clusteraccess", key)
+	}
+
+	if _, exists := tables.clusterUsers[obj.Clusteraccess_user_id]; !exists {
+		return newMissingReferenceError("clusteraccess", "clusteraccess_user_id", obj.Clusteraccess_user_id)
+	}
+
+	if _, exists := tables.managedEnvironments[obj.Clusteraccess_managed_environment_id]; !exists {
+		return newMissingReferenceError("clusteraccess", "clusteraccess_managed_environment_id", obj.Clusteraccess_managed_environment_id)
+	}
+
+	if _, exists := tables.gitopsEngineInstances[obj.Clusteraccess_gitops_engine_instance_id]; !exists {
+		return newMissingReferenceError("clusteraccess", "clusteraccess_gitops_engine_instance_id", obj.Clusteraccess_gitops_engine_instance_id)
+	}
+
+	if obj.SeqID == 0 {
+		obj.SeqID = dbq.nextSeqID()
+	}
+
+	tables.clusterAccess[key] = *obj
+
+	return nil
+}
+
+// listClusterAccess returns all ClusterAccess rows, ordered by seq_id
+func (dbq *InMemoryDatabaseQueries) listClusterAccess() []ClusterAccess {
+
+	res := []ClusterAccess{}
+
+	for _, clusterAccess := range dbq.tables().clusterAccess {
+		res = append(res, clusterAccess)
+	}
+
+	sort.Slice(res, func(i, j int) bool { return res[i].SeqID < res[j].SeqID })
+
+	return res
+}
+
+// ClusterCredentials
+
+func (dbq *InMemoryDatabaseQueries) UnsafeListAllClusterCredentials(ctx context.Context, clusterCredentials *[]ClusterCredentials) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if !dbq.allowUnsafe {
+		return fmt.Errorf("unsafe call to ListAllClusterCredentials")
+	}
+
+	*clusterCredentials = dbq.listClusterCredentials(func(ClusterCredentials) bool { return true })
+
+	return nil
+}
+
+func (dbq *InMemoryDatabaseQueries) CreateClusterCredentials(ctx context.Context, obj *ClusterCredentials) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParamsEntity(obj); err != nil {
+		return err
+	}
+
+	if err := dbq.generatePrimaryKey(&obj.Clustercredentials_cred_id); err != nil {
+		return err
+	}
+
+	if err := dbq.clusterCredentialsKeyring.encryptClusterCredentials(obj); err != nil {
+		return err
+	}
+
+	if err := checkColumnLengths("clustercredentials", obj); err != nil {
+		return fmt.Errorf("error on inserting cluster credentials: %w", err)
+	}
+
+	if _, exists := dbq.tables().clusterCredentials[obj.Clustercredentials_cred_id]; exists {
+		return fmt.Errorf("error on inserting cluster credentials: %w",
+			newDuplicateKeyError("clustercredentials", obj.Clustercredentials_cred_id))
+	}
+
+	if obj.SeqID == 0 {
+		obj.SeqID = dbq.nextSeqID()
+	}
+
+	dbq.tables().clusterCredentials[obj.Clustercredentials_cred_id] = *obj
+
+	return nil
+}
+
+func (dbq *InMemoryDatabaseQueries) GetClusterCredentialsById(ctx context.Context, clusterCreds *ClusterCredentials) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParamsEntity(clusterCreds); err != nil {
+		return err
+	}
+
+	if err := dbq.validateUnsafe(); err != nil {
+		return err
+	}
+
+	result, exists := dbq.tables().clusterCredentials[clusterCreds.Clustercredentials_cred_id]
+	if !exists {
+		return NewResultNotFoundError("UnsafeGetClusterCredentialsById")
+	}
+
+	*clusterCreds = result
+
+	return nil
+}
+
+func (dbq *InMemoryDatabaseQueries) CheckedGetClusterCredentialsById(ctx context.Context, clusterCredentials *ClusterCredentials, ownerId string) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParamsEntity(clusterCredentials); err != nil {
+		return err
+	}
+
+	accessibleByUser, err := dbq.isAccessibleByUser(ctx, clusterCredentials.Clustercredentials_cred_id, ownerId)
+	if err != nil {
+		return err
+	}
+
+	// No results
+	if !accessibleByUser {
+		return NewResultNotFoundError("no accessible results")
+	}
+
+	result, exists := dbq.tables().clusterCredentials[clusterCredentials.Clustercredentials_cred_id]
+	if !exists {
+		return NewResultNotFoundError("no results found for GetClusterCredentialsById")
+	}
+
+	*clusterCredentials = result
+
+	return nil
+}
+
+func (dbq *InMemoryDatabaseQueries) CheckedListClusterCredentialsByHost(ctx context.Context, hostName string, clusterCredentials *[]ClusterCredentials, ownerId string) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParams(hostName); err != nil {
+		return err
+	}
+
+	credsWithHostName := dbq.listClusterCredentials(func(clusterCreds ClusterCredentials) bool {
+		return clusterCreds.Host == hostName
+	})
+
+	if len(credsWithHostName) == 0 {
+		*clusterCredentials = []ClusterCredentials{}
+		return nil
+	}
+
+	var matchingClusterCreds []ClusterCredentials
+
+	for idx := range credsWithHostName {
+
+		accessibleByUser, err := dbq.isAccessibleByUser(ctx, credsWithHostName[idx].Clustercredentials_cred_id, ownerId)
+		if err != nil {
+			return err
+		}
+
+		if accessibleByUser {
+			matchingClusterCreds = append(matchingClusterCreds, credsWithHostName[idx])
+		}
+	}
+
+	*clusterCredentials = matchingClusterCreds
+
+	return nil
+}
+
+// isAccessibleByUser is the equivalent of PostgreSQLDatabaseQueries' 'isAccessibleByUser': a user should only be
+// able to get cluster credentials if they have access to a managed environment using those credentials, or to a
+// gitops engine instance on that cluster.
+func (dbq *InMemoryDatabaseQueries) isAccessibleByUser(ctx context.Context, clusterCredsId string, ownerId string) (bool, error) {
+
+	managedEnvironments := dbq.listManagedEnvironments(func(managedEnv ManagedEnvironment) bool {
+		return managedEnv.Clustercredentials_id == clusterCredsId
+	})
+
+	for _, managedEnvironment := range managedEnvironments {
+
+		dbManagedEnv := ManagedEnvironment{Managedenvironment_id: managedEnvironment.Managedenvironment_id}
+		if err := dbq.CheckedGetManagedEnvironmentById(ctx, &dbManagedEnv, ownerId); err != nil {
+
+			if IsResultNotFoundError(err) {
+				continue
+			}
+
+			return false, err
+		}
+
+		return true, nil
+	}
+
+	engineClustersUsingCredential := dbq.listGitopsEngineClusters(func(engineCluster GitopsEngineCluster) bool {
+		return engineCluster.Clustercredentials_id == clusterCredsId
+	})
+
+	for _, engineCluster := range engineClustersUsingCredential {
+
+		var gitopsEngineInstances []GitopsEngineInstance
+		if err := dbq.CheckedListAllGitopsEngineInstancesForGitopsEngineClusterIdAndOwnerId(ctx, engineCluster.Gitopsenginecluster_id, ownerId, &gitopsEngineInstances); err != nil {
+			return false, err
+		}
+
+		if len(gitopsEngineInstances) > 0 {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (dbq *InMemoryDatabaseQueries) DeleteClusterCredentialsById(ctx context.Context, id string) (int, error) {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if isEmpty(id) {
+		return 0, fmt.Errorf("primary key is empty")
+	}
+
+	tables := dbq.tables()
+
+	if _, exists := tables.clusterCredentials[id]; !exists {
+		return 0, nil
+	}
+
+	for _, engineCluster := range tables.gitopsEngineClusters {
+		if engineCluster.Clustercredentials_id == id {
+			return 0, fmt.Errorf("error on deleting operation: %w", newReferencedRowError("clustercredentials", id, "gitopsenginecluster"))
+		}
+	}
+
+	for _, managedEnv := range tables.managedEnvironments {
+		if managedEnv.Clustercredentials_id == id {
+			return 0, fmt.Errorf("error on deleting operation: %w", newReferencedRowError("clustercredentials", id, "managedenvironment"))
+		}
+	}
+
+	delete(tables.clusterCredentials, id)
+
+	return 1, nil
+}
+
+func (dbq *InMemoryDatabaseQueries) DecryptClusterCredentials(clusterCreds *ClusterCredentials) error {
+
+	if clusterCreds == nil {
+		return fmt.Errorf("cluster credentials are nil")
+	}
+
+	return dbq.clusterCredentialsKeyring.decryptClusterCredentials(clusterCreds)
+}
+
+func (dbq *InMemoryDatabaseQueries) ReencryptAllClusterCredentials(ctx context.Context) (int, error) {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if dbq.clusterCredentialsKeyring == nil {
+		return 0, fmt.Errorf("unable to re-encrypt cluster credentials: %s is not configured", EnvClusterCredentialsEncryptionKeysDir)
+	}
+
+	updated := 0
+
+	for _, clusterCreds := range dbq.listClusterCredentials(func(ClusterCredentials) bool { return true }) {
+
+		if !dbq.clusterCredentialsKeyring.needsReencryption(&clusterCreds) {
+			continue
+		}
+
+		if err := dbq.clusterCredentialsKeyring.decryptClusterCredentials(&clusterCreds); err != nil {
+			return updated, err
+		}
+
+		if err := dbq.clusterCredentialsKeyring.encryptClusterCredentials(&clusterCreds); err != nil {
+			return updated, err
+		}
+
+		if err := checkColumnLengths("clustercredentials", &clusterCreds); err != nil {
+			return updated, fmt.Errorf("error on updating cluster credentials '%s': %w", clusterCreds.Clustercredentials_cred_id, err)
+		}
+
+		dbq.tables().clusterCredentials[clusterCreds.Clustercredentials_cred_id] = clusterCreds
+		updated++
+	}
+
+	return updated, nil
+}
+
+// listClusterCredentials returns the ClusterCredentials that match 'filter', ordered by seq_id
+func (dbq *InMemoryDatabaseQueries) listClusterCredentials(filter func(ClusterCredentials) bool) []ClusterCredentials {
+
+	res := []ClusterCredentials{}
+
+	for _, clusterCreds := range dbq.tables().clusterCredentials {
+		if filter(clusterCreds) {
+			res = append(res, clusterCreds)
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool { return res[i].SeqID < res[j].SeqID })
+
+	return res
+}
+
+// ClusterUser
+
+func (dbq *InMemoryDatabaseQueries) UnsafeListAllClusterUsers(ctx context.Context, clusterUsers *[]ClusterUser) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := dbq.validateUnsafe(); err != nil {
+		return err
+	}
+
+	res := []ClusterUser{}
+	for _, clusterUser := range dbq.tables().clusterUsers {
+		res = append(res, clusterUser)
+	}
+
+	sort.Slice(res, func(i, j int) bool { return res[i].SeqID < res[j].SeqID })
+
+	*clusterUsers = res
+
+	return nil
+}
+
+func (dbq *InMemoryDatabaseQueries) DeleteClusterUserById(ctx context.Context, id string) (int, error) {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParams(id); err != nil {
+		return 0, err
+	}
+
+	tables := dbq.tables()
+
+	if _, exists := tables.clusterUsers[id]; !exists {
+		return 0, nil
+	}
+
+	for key := range tables.clusterAccess {
+		if key.userID == id {
+			return 0, fmt.Errorf("error on deleting cluster_user: %w", newReferencedRowError("clusteruser", id, "clusteraccess"))
+		}
+	}
+
+	for _, operation := range tables.operations {
+		if operation.Operation_owner_user_id == id {
+			return 0, fmt.Errorf("error on deleting cluster_user: %w", newReferencedRowError("clusteruser", id, "operation"))
+		}
+	}
+
+	delete(tables.clusterUsers, id)
+
+	return 1, nil
+}
+
+func (dbq *InMemoryDatabaseQueries) CreateClusterUser(ctx context.Context, obj *ClusterUser) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParamsEntity(obj); err != nil {
+		return err
+	}
+
+	if err := dbq.generatePrimaryKey(&obj.Clusteruser_id); err != nil {
+		return err
+	}
+
+	if isEmpty(obj.User_name) {
+		return fmt.Errorf("user name should not be empty")
+	}
+
+	if err := checkColumnLengths("clusteruser", obj); err != nil {
+		return fmt.Errorf("error on inserting cluster user: %w", err)
+	}
+
+	if _, exists := dbq.tables().clusterUsers[obj.Clusteruser_id]; exists {
+		return fmt.Errorf("error on inserting cluster user: %w", newDuplicateKeyError("clusteruser", obj.Clusteruser_id))
+	}
+
+	for _, clusterUser := range dbq.tables().clusterUsers {
+		if clusterUser.User_name == obj.User_name {
+			return fmt.Errorf("error on inserting cluster user: %w", newDuplicateKeyError("clusteruser", obj.User_name))
+		}
+	}
+
+	if obj.SeqID == 0 {
+		obj.SeqID = dbq.nextSeqID()
+	}
+
+	dbq.tables().clusterUsers[obj.Clusteruser_id] = *obj
+
+	return nil
+}
+
+func (dbq *InMemoryDatabaseQueries) GetClusterUserByUsername(ctx context.Context, clusterUser *ClusterUser) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParamsEntity(clusterUser); err != nil {
+		return err
+	}
+
+	if isEmpty(clusterUser.User_name) {
+		return fmt.Errorf("username is nil for GetClusterUserByUsername")
+	}
+
+	for _, result := range dbq.tables().clusterUsers {
+		if result.User_name == clusterUser.User_name {
+			*clusterUser = result
+			return nil
+		}
+	}
+
+	return NewResultNotFoundError("no results found for GetClusterUserByUsername")
+}
+
+func (dbq *InMemoryDatabaseQueries) GetClusterUserById(ctx context.Context, clusterUser *ClusterUser) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParamsEntity(clusterUser); err != nil {
+		return err
+	}
+
+	if isEmpty(clusterUser.Clusteruser_id) {
+		return fmt.Errorf("cluster user id is empty")
+	}
+
+	result, exists := dbq.tables().clusterUsers[clusterUser.Clusteruser_id]
+	if !exists {
+		return NewResultNotFoundError("no results found for GetClusterUserById")
+	}
+
+	*clusterUser = result
+
+	return nil
+}
+
+// GitopsEngineCluster
+
+func (dbq *InMemoryDatabaseQueries) GetGitopsEngineClusterById(ctx context.Context, gitopsEngineCluster *GitopsEngineCluster) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParamsEntity(gitopsEngineCluster); err != nil {
+		return err
+	}
+
+	if err := isEmptyValues("GetGitopsEngineClusterById", "Gitopsenginecluster_id", gitopsEngineCluster.Gitopsenginecluster_id); err != nil {
+		return err
+	}
+
+	result, exists := dbq.tables().gitopsEngineClusters[gitopsEngineCluster.Gitopsenginecluster_id]
+	if !exists {
+		return NewResultNotFoundError(
+			fmt.Sprintf("no engine clusters was found with id '%s'", gitopsEngineCluster.Gitopsenginecluster_id))
+	}
+
+	*gitopsEngineCluster = result
+
+	return nil
+}
+
+func (dbq *InMemoryDatabaseQueries) CheckedGetGitopsEngineClusterById(ctx context.Context, gitopsEngineCluster *GitopsEngineCluster, ownerId string) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParamsEntity(gitopsEngineCluster); err != nil {
+		return err
+	}
+
+	if isEmpty(gitopsEngineCluster.Gitopsenginecluster_id) {
+		return fmt.Errorf("invalid pk in GetGitopsEngineClusterById")
+	}
+
+	if isEmpty(ownerId) {
+		return fmt.Errorf("invalid owner in GetGitopsEngineClusterById")
+	}
+
+	// There should be at least one gitops engine instance that is running on the cluster, that this user has access to.
+	var dbResultGitopsEngineInstances []GitopsEngineInstance
+	if err := dbq.CheckedListAllGitopsEngineInstancesForGitopsEngineClusterIdAndOwnerId(ctx, gitopsEngineCluster.Gitopsenginecluster_id, ownerId, &dbResultGitopsEngineInstances); err != nil {
+		return NewResultNotFoundError(
+			fmt.Sprintf("unable to list engine instances for engine cluster '%s' %v", gitopsEngineCluster.Gitopsenginecluster_id, err))
+	}
+
+	if len(dbResultGitopsEngineInstances) == 0 {
+		return NewResultNotFoundError(
+			fmt.Sprintf("no gitops engine clusters were found that had an engine instance owned by '%s'", ownerId))
+	}
+
+	result, exists := dbq.tables().gitopsEngineClusters[gitopsEngineCluster.Gitopsenginecluster_id]
+	if !exists {
+		return NewResultNotFoundError(
+			fmt.Sprintf("no engine clusters was found with id '%s'", gitopsEngineCluster.Gitopsenginecluster_id))
+	}
+
+	*gitopsEngineCluster = result
+
+	return nil
+}
+
+func (dbq *InMemoryDatabaseQueries) CheckedListGitopsEngineClusterByCredentialId(ctx context.Context, credentialId string, engineClustersParam *[]GitopsEngineCluster, ownerId string) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParams(credentialId); err != nil {
+		return err
+	}
+
+	if isEmpty(ownerId) {
+		return fmt.Errorf("invalid owner in GetGitopsEngineClusterByCredentialId")
+	}
+
+	engineClustersWithCreds := dbq.listGitopsEngineClusters(func(engineCluster GitopsEngineCluster) bool {
+		return engineCluster.Clustercredentials_id == credentialId
+	})
+
+	if len(engineClustersWithCreds) == 0 {
+		*engineClustersParam = engineClustersWithCreds
+		return nil
+	}
+
+	// Next, filter the clusters based on whether the user has access to an engine instance on them
+	var res []GitopsEngineCluster
+	for _, gitopsEngineCluster := range engineClustersWithCreds {
+
+		var dbEngineInstances []GitopsEngineInstance
+		if err := dbq.CheckedListAllGitopsEngineInstancesForGitopsEngineClusterIdAndOwnerId(ctx, gitopsEngineCluster.Gitopsenginecluster_id, ownerId, &dbEngineInstances); err != nil {
+			return fmt.Errorf("unable to list engine instance for '%s', owner '%s', error: %w", gitopsEngineCluster.Gitopsenginecluster_id, ownerId, err)
+		}
+
+		if len(dbEngineInstances) > 0 {
+			res = append(res, gitopsEngineCluster)
+		}
+	}
+
+	*engineClustersParam = res
+
+	return nil
+}
+
+func (dbq *InMemoryDatabaseQueries) CreateGitopsEngineCluster(ctx context.Context, obj *GitopsEngineCluster) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParamsEntity(obj); err != nil {
+		return err
+	}
+
+	if err := dbq.generatePrimaryKey(&obj.Gitopsenginecluster_id); err != nil {
+		return err
+	}
+
+	if isEmpty(obj.Clustercredentials_id) {
+		return fmt.Errorf("cluster credentials field should not be empty")
+	}
+
+	tables := dbq.tables()
+
+	if err := checkColumnLengths("gitopsenginecluster", obj); err != nil {
+		return fmt.Errorf("error on inserting engine cluster: %w", err)
+	}
+
+	if _, exists := tables.gitopsEngineClusters[obj.Gitopsenginecluster_id]; exists {
+		return fmt.Errorf("error on inserting engine cluster: %w",
+			newDuplicateKeyError("gitopsenginecluster", obj.Gitopsenginecluster_id))
+	}
+
+	if _, exists := tables.clusterCredentials[obj.Clustercredentials_id]; !exists {
+		return fmt.Errorf("error on inserting engine cluster: %w",
+			newMissingReferenceError("gitopsenginecluster", "clustercredentials_id", obj.Clustercredentials_id))
+	}
+
+	if obj.SeqID == 0 {
+		obj.SeqID = dbq.nextSeqID()
+	}
+
+	tables.gitopsEngineClusters[obj.Gitopsenginecluster_id] = *obj
+
+	return nil
+}
+
+func (dbq *InMemoryDatabaseQueries) UnsafeListAllGitopsEngineClusters(ctx context.Context, gitopsEngineClusters *[]GitopsEngineCluster) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := dbq.validateUnsafe(); err != nil {
+		return err
+	}
+
+	*gitopsEngineClusters = dbq.listGitopsEngineClusters(func(GitopsEngineCluster) bool { return true })
+
+	return nil
+}
+
+func (dbq *InMemoryDatabaseQueries) DeleteGitopsEngineClusterById(ctx context.Context, id string) (int, error) {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParams(id); err != nil {
+		return 0, err
+	}
+
+	if err := dbq.validateUnsafe(); err != nil {
+		return 0, err
+	}
+
+	tables := dbq.tables()
+
+	if _, exists := tables.gitopsEngineClusters[id]; !exists {
+		return 0, nil
+	}
+
+	for _, engineInstance := range tables.gitopsEngineInstances {
+		if engineInstance.EngineCluster_id == id {
+			return 0, fmt.Errorf("error on deleting gitops engine: %w",
+				newReferencedRowError("gitopsenginecluster", id, "gitopsengineinstance"))
+		}
+	}
+
+	delete(tables.gitopsEngineClusters, id)
+
+	return 1, nil
+}
+
+func (dbq *InMemoryDatabaseQueries) UpdateGitopsEngineClusterHeartbeat(ctx context.Context, obj *GitopsEngineCluster) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParamsEntity(obj); err != nil {
+		return err
+	}
+
+	if err := isEmptyValues("UpdateGitopsEngineClusterHeartbeat",
+		"Gitopsenginecluster_id", obj.Gitopsenginecluster_id); err != nil {
+		return err
+	}
+
+	if obj.Heartbeat_last_seen.IsZero() {
+		return fmt.Errorf("heartbeat time should not be empty")
+	}
+
+	existing, exists := dbq.tables().gitopsEngineClusters[obj.Gitopsenginecluster_id]
+	if !exists {
+		return NewResultNotFoundError(fmt.Sprintf("unexpected number of rows affected on updating heartbeat of '%s': %d",
+			obj.Gitopsenginecluster_id, 0))
+	}
+
+	// Only the heartbeat fields are updated
+	existing.Heartbeat_last_seen = obj.Heartbeat_last_seen
+	existing.Heartbeat_agent_version = obj.Heartbeat_agent_version
+	existing.Heartbeat_argocd_version = obj.Heartbeat_argocd_version
+	existing.Heartbeat_capacity = obj.Heartbeat_capacity
+
+	if err := checkColumnLengths("gitopsenginecluster", &existing); err != nil {
+		return fmt.Errorf("error on updating gitops engine cluster heartbeat: %w", err)
+	}
+
+	dbq.tables().gitopsEngineClusters[obj.Gitopsenginecluster_id] = existing
+
+	return nil
+}
+
+func (dbq *InMemoryDatabaseQueries) ListGitopsEngineClusterHeartbeats(ctx context.Context, gitopsEngineClusters *[]GitopsEngineCluster) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	res := []GitopsEngineCluster{}
+
+	// Only the id, and heartbeat fields, are returned
+	for _, engineCluster := range dbq.listGitopsEngineClusters(func(GitopsEngineCluster) bool { return true }) {
+		res = append(res, GitopsEngineCluster{
+			Gitopsenginecluster_id:   engineCluster.Gitopsenginecluster_id,
+			Heartbeat_last_seen:      engineCluster.Heartbeat_last_seen,
+			Heartbeat_agent_version:  engineCluster.Heartbeat_agent_version,
+			Heartbeat_argocd_version: engineCluster.Heartbeat_argocd_version,
+			Heartbeat_capacity:       engineCluster.Heartbeat_capacity,
+		})
+	}
+
+	*gitopsEngineClusters = res
+
+	return nil
+}
+
+// listGitopsEngineClusters returns the GitopsEngineClusters that match 'filter', ordered by seq_id
+func (dbq *InMemoryDatabaseQueries) listGitopsEngineClusters(filter func(GitopsEngineCluster) bool) []GitopsEngineCluster {
+
+	res := []GitopsEngineCluster{}
+
+	for _, engineCluster := range dbq.tables().gitopsEngineClusters {
+		if filter(engineCluster) {
+			res = append(res, engineCluster)
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool { return res[i].SeqID < res[j].SeqID })
+
+	return res
+}
+
+// GitopsEngineInstance
+
+func (dbq *InMemoryDatabaseQueries) UnsafeListAllGitopsEngineInstances(ctx context.Context, gitopsEngineInstances *[]GitopsEngineInstance) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := dbq.validateUnsafe(); err != nil {
+		return err
+	}
+
+	*gitopsEngineInstances = dbq.listGitopsEngineInstances()
+
+	return nil
+}
+
+func (dbq *InMemoryDatabaseQueries) ListGitopsEngineInstances(ctx context.Context, gitopsEngineInstances *[]GitopsEngineInstance) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParamsEntity(gitopsEngineInstances); err != nil {
+		return err
+	}
+
+	*gitopsEngineInstances = dbq.listGitopsEngineInstances()
+
+	return nil
+}
+
+func (dbq *InMemoryDatabaseQueries) CheckedListAllGitopsEngineInstancesForGitopsEngineClusterIdAndOwnerId(ctx context.Context, engineClusterId string, ownerId string, gitopsEngineInstancesParam *[]GitopsEngineInstance) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParams(engineClusterId); err != nil {
+		return err
+	}
+
+	if isEmpty(ownerId) {
+		return fmt.Errorf("engine instance owner id is nil")
+	}
+
+	*gitopsEngineInstancesParam = dbq.joinGitopsEngineInstancesWithClusterAccess(ownerId, func(engineInstance GitopsEngineInstance) bool {
+		return engineInstance.EngineCluster_id == engineClusterId
+	})
+
+	return nil
+}
+
+func (dbq *InMemoryDatabaseQueries) GetGitopsEngineInstanceById(ctx context.Context, engineInstanceParam *GitopsEngineInstance) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParamsEntity(engineInstanceParam); err != nil {
+		return err
+	}
+
+	if err := isEmptyValues("GetGitopsEngineInstanceById",
+		"Gitopsengineinstance_id", engineInstanceParam.Gitopsengineinstance_id); err != nil {
+		return err
+	}
+
+	result, exists := dbq.tables().gitopsEngineInstances[engineInstanceParam.Gitopsengineinstance_id]
+	if !exists {
+		return NewResultNotFoundError("no results found for GetGitopsEngineInstanceById")
+	}
+
+	*engineInstanceParam = result
+
+	return nil
+}
+
+func (dbq *InMemoryDatabaseQueries) CheckedGetGitopsEngineInstanceById(ctx context.Context, engineInstanceParam *GitopsEngineInstance, ownerId string) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParamsEntity(engineInstanceParam); err != nil {
+		return err
+	}
+
+	if isEmpty(engineInstanceParam.Gitopsengineinstance_id) {
+		return fmt.Errorf("invalid pk")
+	}
+
+	if isEmpty(ownerId) {
+		return fmt.Errorf("invalid ownerId")
+	}
+
+	res := dbq.joinGitopsEngineInstancesWithClusterAccess(ownerId, func(engineInstance GitopsEngineInstance) bool {
+		return engineInstance.Gitopsengineinstance_id == engineInstanceParam.Gitopsengineinstance_id
+	})
+
+	if len(res) >= 2 {
+		return fmt.Errorf("multiple results returned from GetGitopsEngineInstanceById")
+	}
+
+	if len(res) == 0 {
+		return NewResultNotFoundError("no results found for GetGitopsEngineInstanceById")
+	}
+
+	*engineInstanceParam = res[0]
+
+	return nil
+}
+
+func (dbq *InMemoryDatabaseQueries) CreateGitopsEngineInstance(ctx context.Context, obj *GitopsEngineInstance) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParamsEntity(obj); err != nil {
+		return err
+	}
+
+	if err := dbq.generatePrimaryKey(&obj.Gitopsengineinstance_id); err != nil {
+		return err
+	}
+
+	if isEmpty(obj.EngineCluster_id) {
+		return fmt.Errorf("engine cluster id should not be empty")
+	}
+
+	if isEmpty(obj.Namespace_name) {
+		return fmt.Errorf("namespace name should not be empty")
+	}
+
+	if isEmpty(obj.Namespace_uid) {
+		return fmt.Errorf("namespace uid should not be empty")
+	}
+
+	tables := dbq.tables()
+
+	if err := checkColumnLengths("gitopsengineinstance", obj); err != nil {
+		return fmt.Errorf("error on inserting gitops engine instance: %w", err)
+	}
+
+	if _, exists := tables.gitopsEngineInstances[obj.Gitopsengineinstance_id]; exists {
+		return fmt.Errorf("error on inserting gitops engine instance: %w",
+			newDuplicateKeyError("gitopsengineinstance", obj.Gitopsengineinstance_id))
+	}
+
+	if _, exists := tables.gitopsEngineClusters[obj.EngineCluster_id]; !exists {
+		return fmt.Errorf("error on inserting gitops engine instance: %w",
+			newMissingReferenceError("gitopsengineinstance", "enginecluster_id", obj.EngineCluster_id))
+	}
+
+	if obj.SeqID == 0 {
+		obj.SeqID = dbq.nextSeqID()
+	}
+
+	tables.gitopsEngineInstances[obj.Gitopsengineinstance_id] = *obj
+
+	return nil
+}
+
+func (dbq *InMemoryDatabaseQueries) CheckedDeleteGitopsEngineInstanceById(ctx context.Context, id string, ownerId string) (int, error) {
+
+	return dbq.internalDeleteGitopsEngineInstanceById(ctx, id, ownerId, false)
+}
+
+func (dbq *InMemoryDatabaseQueries) DeleteGitopsEngineInstanceById(ctx context.Context, id string) (int, error) {
+
+	return dbq.internalDeleteGitopsEngineInstanceById(ctx, id, "", true)
+}
+
+func (dbq *InMemoryDatabaseQueries) internalDeleteGitopsEngineInstanceById(ctx context.Context, id string, ownerId string, allowUnsafe bool) (int, error) {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParams(id); err != nil {
+		return 0, err
+	}
+
+	if !allowUnsafe {
+
+		if isEmpty(ownerId) {
+			return 0, fmt.Errorf("owner id is empty")
+		}
+
+		existingValue := GitopsEngineInstance{Gitopsengineinstance_id: id}
+		err := dbq.CheckedGetGitopsEngineInstanceById(ctx, &existingValue, ownerId)
+		if err != nil || existingValue.Gitopsengineinstance_id != id {
+			return 0, fmt.Errorf("unable to locate gitops engine instance id, or access denied: '%s', %w", id, err)
+		}
+	}
+
+	tables := dbq.tables()
+
+	if _, exists := tables.gitopsEngineInstances[id]; !exists {
+		return 0, nil
+	}
+
+	for key := range tables.clusterAccess {
+		if key.gitopsEngineInstanceID == id {
+			return 0, fmt.Errorf("error on deleting operation: %w", newReferencedRowError("gitopsengineinstance", id, "clusteraccess"))
+		}
+	}
+
+	for _, operation := range tables.operations {
+		if operation.Instance_id == id {
+			return 0, fmt.Errorf("error on deleting operation: %w", newReferencedRowError("gitopsengineinstance", id, "operation"))
+		}
+	}
+
+	for _, application := range tables.applications {
+		if application.Engine_instance_inst_id == id {
+			return 0, fmt.Errorf("error on deleting operation: %w", newReferencedRowError("gitopsengineinstance", id, "application"))
+		}
+	}
+
+	delete(tables.gitopsEngineInstances, id)
+
+	return 1, nil
+}
+
+// listGitopsEngineInstances returns all GitopsEngineInstances, ordered by seq_id
+func (dbq *InMemoryDatabaseQueries) listGitopsEngineInstances() []GitopsEngineInstance {
+
+	res := []GitopsEngineInstance{}
+
+	for _, engineInstance := range dbq.tables().gitopsEngineInstances {
+		res = append(res, engineInstance)
+	}
+
+	sort.Slice(res, func(i, j int) bool { return res[i].SeqID < res[j].SeqID })
+
+	return res
+}
+
+// joinGitopsEngineInstancesWithClusterAccess is the equivalent of joining GitopsEngineInstance with the ClusterAccess rows
+// of 'ownerId': as with the SQL join, an instance is returned once for each ClusterAccess row that references it.
+func (dbq *InMemoryDatabaseQueries) joinGitopsEngineInstancesWithClusterAccess(ownerId string, filter func(GitopsEngineInstance) bool) []GitopsEngineInstance {
+
+	res := []GitopsEngineInstance{}
+
+	for _, clusterAccess := range dbq.listClusterAccess() {
+
+		if clusterAccess.Clusteraccess_user_id != ownerId {
+			continue
+		}
+
+		engineInstance, exists := dbq.tables().gitopsEngineInstances[clusterAccess.Clusteraccess_gitops_engine_instance_id]
+		if exists && filter(engineInstance) {
+			res = append(res, engineInstance)
+		}
+	}
+
+	sort.SliceStable(res, func(i, j int) bool { return res[i].SeqID < res[j].SeqID })
+
+	return res
+}
+
+// ManagedEnvironment
+
+func (dbq *InMemoryDatabaseQueries) CreateManagedEnvironment(ctx context.Context, obj *ManagedEnvironment) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParamsEntity(obj); err != nil {
+		return err
+	}
+
+	if err := validateInMemoryQueryParams(obj.Clustercredentials_id); err != nil {
+		return err
+	}
+
+	if err := dbq.generatePrimaryKey(&obj.Managedenvironment_id); err != nil {
+		return err
+	}
+
+	if isEmpty(obj.Name) {
+		return fmt.Errorf("managed environment name field should not be empty")
+	}
+
+	tables := dbq.tables()
+
+	if err := checkColumnLengths("managedenvironment", obj); err != nil {
+		return fmt.Errorf("error on inserting managed environment: %w", err)
+	}
+
+	if _, exists := tables.managedEnvironments[obj.Managedenvironment_id]; exists {
+		return fmt.Errorf("error on inserting managed environment: %w",
+			newDuplicateKeyError("managedenvironment", obj.Managedenvironment_id))
+	}
+
+	if _, exists := tables.clusterCredentials[obj.Clustercredentials_id]; !exists {
+		return fmt.Errorf("error on inserting managed environment: %w",
+			newMissingReferenceError("managedenvironment", "clustercredentials_id", obj.Clustercredentials_id))
+	}
+
+	if obj.SeqID == 0 {
+		obj.SeqID = dbq.nextSeqID()
+	}
+
+	tables.managedEnvironments[obj.Managedenvironment_id] = *obj
+
+	return nil
+}
+
+func (dbq *InMemoryDatabaseQueries) UnsafeListAllManagedEnvironments(ctx context.Context, managedEnvironments *[]ManagedEnvironment) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := dbq.validateUnsafe(); err != nil {
+		return err
+	}
+
+	*managedEnvironments = dbq.listManagedEnvironments(func(ManagedEnvironment) bool { return true })
+
+	return nil
+}
+
+func (dbq *InMemoryDatabaseQueries) ListManagedEnvironmentForClusterCredentialsAndOwnerId(ctx context.Context, clusterCredentialId string, ownerId string, managedEnvironments *[]ManagedEnvironment) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParams(clusterCredentialId); err != nil {
+		return err
+	}
+
+	if isEmpty(ownerId) {
+		return fmt.Errorf("owner id for ListManagedEnvironmentByClusterCredentialsAndOwnerId is empty")
+	}
+
+	*managedEnvironments = dbq.joinManagedEnvironmentsWithClusterAccess(ownerId, func(managedEnv ManagedEnvironment) bool {
+		return managedEnv.Clustercredentials_id == clusterCredentialId
+	})
+
+	return nil
+}
+
+func (dbq *InMemoryDatabaseQueries) GetManagedEnvironmentById(ctx context.Context, managedEnvironment *ManagedEnvironment) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParamsEntity(managedEnvironment); err != nil {
+		return err
+	}
+
+	if isEmpty(managedEnvironment.Managedenvironment_id) {
+		return fmt.Errorf("managedenvironment_id is empty in GetManagedEnvironmentById")
+	}
+
+	result, exists := dbq.tables().managedEnvironments[managedEnvironment.Managedenvironment_id]
+	if !exists {
+		return NewResultNotFoundError("error on retrieving GetGitopsEngineInstanceById")
+	}
+
+	*managedEnvironment = result
+
+	return nil
+}
+
+func (dbq *InMemoryDatabaseQueries) CheckedGetManagedEnvironmentById(ctx context.Context, managedEnvironment *ManagedEnvironment, ownerId string) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParamsEntity(managedEnvironment); err != nil {
+		return err
+	}
+
+	if isEmpty(managedEnvironment.Managedenvironment_id) {
+		return fmt.Errorf("managedenvironment_id is empty in GetManagedEnvironmentById")
+	}
+
+	if isEmpty(ownerId) {
+		return fmt.Errorf("ownerId is empty in GetManagedEnvironmentById")
+	}
+
+	res := dbq.joinManagedEnvironmentsWithClusterAccess(ownerId, func(managedEnv ManagedEnvironment) bool {
+		return managedEnv.Managedenvironment_id == managedEnvironment.Managedenvironment_id
+	})
+
+	if len(res) >= 2 {
+		return fmt.Errorf("multiple results returned from GetManagedEnvironmentById")
+	}
+
+	if len(res) == 0 {
+		return NewResultNotFoundError("error on retrieving GetGitopsEngineInstanceById")
+	}
+
+	*managedEnvironment = res[0]
+
+	return nil
+}
+
+func (dbq *InMemoryDatabaseQueries) CheckedDeleteManagedEnvironmentById(ctx context.Context, id string, ownerId string) (int, error) {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParams(id); err != nil {
+		return 0, err
+	}
+
+	if isEmpty(ownerId) {
+		return 0, fmt.Errorf("owner id is empty")
+	}
+
+	existingValue := ManagedEnvironment{Managedenvironment_id: id}
+	err := dbq.CheckedGetManagedEnvironmentById(ctx, &existingValue, ownerId)
+	if err != nil || existingValue.Managedenvironment_id != id {
+		return 0, fmt.Errorf("unable to locate managed environment id, or access denied: '%s', %w", id, err)
+	}
+
+	return dbq.deleteManagedEnvironment(id)
+}
+
+func (dbq *InMemoryDatabaseQueries) DeleteManagedEnvironmentById(ctx context.Context, id string) (int, error) {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParams(id); err != nil {
+		return 0, err
+	}
+
+	if err := dbq.validateUnsafe(); err != nil {
+		return 0, err
+	}
+
+	return dbq.deleteManagedEnvironment(id)
+}
+
+func (dbq *InMemoryDatabaseQueries) DeleteManagedEnvironmentWithoutClusterAccessById(ctx context.Context, id string) (int, error) {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParams(id); err != nil {
+		return 0, err
+	}
+
+	for key := range dbq.tables().clusterAccess {
+		if key.managedEnvironmentID == id {
+			return 0, nil
+		}
+	}
+
+	return dbq.deleteManagedEnvironment(id)
+}
+
+func (dbq *InMemoryDatabaseQueries) deleteManagedEnvironment(id string) (int, error) {
+
+	tables := dbq.tables()
+
+	if _, exists := tables.managedEnvironments[id]; !exists {
+		return 0, nil
+	}
+
+	for key := range tables.clusterAccess {
+		if key.managedEnvironmentID == id {
+			return 0, fmt.Errorf("error on deleting operation: %w", newReferencedRowError("managedenvironment", id, "clusteraccess"))
+		}
+	}
+
+	for _, application := range tables.applications {
+		if application.Managed_environment_id == id {
+			return 0, fmt.Errorf("error on deleting operation: %w", newReferencedRowError("managedenvironment", id, "application"))
+		}
+	}
+
+	delete(tables.managedEnvironments, id)
+
+	return 1, nil
+}
+
+// listManagedEnvironments returns the ManagedEnvironments that match 'filter', ordered by seq_id
+func (dbq *InMemoryDatabaseQueries) listManagedEnvironments(filter func(ManagedEnvironment) bool) []ManagedEnvironment {
+
+	res := []ManagedEnvironment{}
+
+	for _, managedEnv := range dbq.tables().managedEnvironments {
+		if filter(managedEnv) {
+			res = append(res, managedEnv)
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool { return res[i].SeqID < res[j].SeqID })
+
+	return res
+}
+
+// joinManagedEnvironmentsWithClusterAccess is the equivalent of joining ManagedEnvironment with the ClusterAccess rows
+// of 'ownerId': as with the SQL join, an environment is returned once for each ClusterAccess row that references it.
+func (dbq *InMemoryDatabaseQueries) joinManagedEnvironmentsWithClusterAccess(ownerId string, filter func(ManagedEnvironment) bool) []ManagedEnvironment {
+
+	res := []ManagedEnvironment{}
+
+	for _, clusterAccess := range dbq.listClusterAccess() {
+
+		if clusterAccess.Clusteraccess_user_id != ownerId {
+			continue
+		}
+
+		managedEnv, exists := dbq.tables().managedEnvironments[clusterAccess.Clusteraccess_managed_environment_id]
+		if exists && filter(managedEnv) {
+			res = append(res, managedEnv)
+		}
+	}
+
+	sort.SliceStable(res, func(i, j int) bool { return res[i].SeqID < res[j].SeqID })
+
+	return res
+}
+
+// Operation
+
+func (dbq *InMemoryDatabaseQueries) UnsafeListAllOperations(ctx context.Context, operations *[]Operation) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := dbq.validateUnsafe(); err != nil {
+		return err
+	}
+
+	*operations = dbq.listOperations(func(Operation) bool { return true })
+
+	return nil
+}
+
+func (dbq *InMemoryDatabaseQueries) CreateOperation(ctx context.Context, obj *Operation, ownerId string) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParamsEntity(obj); err != nil {
+		return err
+	}
+
+	if err := dbq.generatePrimaryKey(&obj.Operation_id); err != nil {
+		return err
+	}
+
+	if err := isEmptyValues("CreateOperation",
+		"Instance_id", obj.Instance_id,
+		"Operation_id", obj.Operation_id,
+		"Operation_owner_user_id", obj.Operation_owner_user_id,
+		"Resource_id", obj.Resource_id,
+		"Resource_type", obj.Resource_type,
+		"State", obj.State); err != nil {
+		return err
+	}
+
+	// Verify the instance exists
+	gei := GitopsEngineInstance{Gitopsengineinstance_id: obj.Instance_id}
+	if err := dbq.GetGitopsEngineInstanceById(ctx, &gei); err != nil {
+		return fmt.Errorf("unable to retrieve operation's gitops engine instance ID: '%v' %w", obj.Instance_id, err)
+	}
+
+	obj.Created_on = time.Now()
+	obj.Last_state_update = obj.Created_on
+
+	if obj.Deadline.IsZero() {
+		obj.Deadline = obj.Created_on.Add(GetOperationTimeout(obj.Resource_type))
+	}
+
+	// Initial state is waiting
+	obj.State = OperationState_Waiting
+
+	if err := dbq.checkOperation(obj); err != nil {
+		return fmt.Errorf("error on inserting operation: %w", err)
+	}
+
+	if _, exists := dbq.tables().operations[obj.Operation_id]; exists {
+		return fmt.Errorf("error on inserting operation: %w", newDuplicateKeyError("operation", obj.Operation_id))
+	}
+
+	if obj.SeqID == 0 {
+		obj.SeqID = dbq.nextSeqID()
+	}
+
+	dbq.tables().operations[obj.Operation_id] = *obj
+
+	return nil
+}
+
+func (dbq *InMemoryDatabaseQueries) UpdateOperation(ctx context.Context, obj *Operation) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParamsEntity(obj); err != nil {
+		return err
+	}
+
+	if err := isEmptyValues("UpdateOperation",
+		"Instance_id", obj.Instance_id,
+		"Operation_id", obj.Operation_id,
+		"Operation_owner_user_id", obj.Operation_owner_user_id,
+		"Resource_id", obj.Resource_id,
+		"Resource_type", obj.Resource_type,
+		"State", obj.State); err != nil {
+		return err
+	}
+
+	existing, exists := dbq.tables().operations[obj.Operation_id]
+	if !exists {
+		return fmt.Errorf("unexpected number of rows affected: %d, %v", 0, obj.Operation_id)
+	}
+
+	row := *obj
+	row.SeqID = existing.SeqID
+
+	// cancel_requested is only set via RequestOperationCancellation
+	row.Cancel_requested = existing.Cancel_requested
+
+	if err := dbq.checkOperation(&row); err != nil {
+		return fmt.Errorf("error on updating operation: %w, %v", err, obj.Operation_id)
+	}
+
+	dbq.tables().operations[row.Operation_id] = row
+
+	return nil
+}
+
+func (dbq *InMemoryDatabaseQueries) GetOperationById(ctx context.Context, operation *Operation) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParamsEntity(operation); err != nil {
+		return err
+	}
+
+	if isEmpty(operation.Operation_id) {
+		return fmt.Errorf("invalid pk")
+	}
+
+	result, exists := dbq.tables().operations[operation.Operation_id]
+	if !exists {
+		return NewResultNotFoundError(fmt.Sprintf("unable to locate operation '%v'", operation.Operation_id))
+	}
+
+	*operation = result
+
+	return nil
+}
+
+func (dbq *InMemoryDatabaseQueries) CheckedGetOperationById(ctx context.Context, operation *Operation, ownerId string) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParamsEntity(operation); err != nil {
+		return err
+	}
+
+	if isEmpty(operation.Operation_id) {
+		return fmt.Errorf("invalid pk")
+	}
+
+	if isEmpty(ownerId) {
+		return fmt.Errorf("owner id is empty")
+	}
+
+	result, exists := dbq.tables().operations[operation.Operation_id]
+	if !exists || result.Operation_owner_user_id != ownerId {
+		return NewResultNotFoundError(fmt.Sprintf("unable to locate operation '%v'", operation.Operation_id))
+	}
+
+	*operation = result
+
+	return nil
+}
+
+func (dbq *InMemoryDatabaseQueries) DeleteOperationById(ctx context.Context, id string) (int, error) {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParams(id); err != nil {
+		return 0, err
+	}
+
+	if _, exists := dbq.tables().operations[id]; !exists {
+		return 0, nil
+	}
+
+	delete(dbq.tables().operations, id)
+
+	return 1, nil
+}
+
+func (dbq *InMemoryDatabaseQueries) CheckedDeleteOperationById(ctx context.Context, id string, ownerId string) (int, error) {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParams(id); err != nil {
+		return 0, err
+	}
+
+	if isEmpty(ownerId) {
+		return 0, fmt.Errorf("owner id is empty")
+	}
+
+	operation, exists := dbq.tables().operations[id]
+	if !exists || operation.Operation_owner_user_id != ownerId {
+		return 0, nil
+	}
+
+	delete(dbq.tables().operations, id)
+
+	return 1, nil
+}
+
+func (dbq *InMemoryDatabaseQueries) ListOperationsByResourceIdAndTypeAndOwnerId(ctx context.Context, resourceID string, resourceType string, operations *[]Operation, ownerId string) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParamsEntity(operations); err != nil {
+		return err
+	}
+
+	if err := isEmptyValues("ListOperationsByResourceIdAndTypeAndOwnerId",
+		"ownerId", ownerId,
+		"resourceId", resourceID,
+		"resourceType", resourceType); err != nil {
+		return err
+	}
+
+	*operations = dbq.listOperations(func(operation Operation) bool {
+		return operation.Resource_id == resourceID && operation.Resource_type == resourceType &&
+			operation.Operation_owner_user_id == ownerId
+	})
+
+	return nil
+}
+
+func (dbq *InMemoryDatabaseQueries) ListOperationsByOwnerId(ctx context.Context, ownerId string, operations *[]Operation) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParamsEntity(operations); err != nil {
+		return err
+	}
+
+	if err := isEmptyValues("ListOperationsByOwnerId", "ownerId", ownerId); err != nil {
+		return err
+	}
+
+	*operations = dbq.listOperations(func(operation Operation) bool {
+		return operation.Operation_owner_user_id == ownerId
+	})
+
+	return nil
+}
+
+func (dbq *InMemoryDatabaseQueries) ListApplicationsByManagedEnvironmentId(ctx context.Context, managedEnvironmentId string, applications *[]Application) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParamsEntity(applications); err != nil {
+		return err
+	}
+
+	if err := isEmptyValues("ListApplicationsByManagedEnvironmentId", "managedEnvironmentId", managedEnvironmentId); err != nil {
+		return err
+	}
+
+	*applications = dbq.listApplications(func(application Application) bool {
+		return application.Managed_environment_id == managedEnvironmentId
+	})
+
+	return nil
+}
+
+func (dbq *InMemoryDatabaseQueries) ListAPICRToDatabaseMappingsByWorkspaceUID(ctx context.Context, workspaceUID string, apiCRToDBMappings *[]APICRToDatabaseMapping) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParamsEntity(apiCRToDBMappings); err != nil {
+		return err
+	}
+
+	if err := isEmptyValues("ListAPICRToDatabaseMappingsByWorkspaceUID", "workspaceUID", workspaceUID); err != nil {
+		return err
+	}
+
+	*apiCRToDBMappings = dbq.listAPICRToDatabaseMappings(func(apiCRToDBMapping APICRToDatabaseMapping) bool {
+		return apiCRToDBMapping.WorkspaceUID == workspaceUID
+	})
+
+	return nil
+}
+
+func (dbq *InMemoryDatabaseQueries) RequestOperationCancellation(ctx context.Context, obj *Operation) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParamsEntity(obj); err != nil {
+		return err
+	}
+
+	if isEmpty(obj.Operation_id) {
+		return fmt.Errorf("invalid pk")
+	}
+
+	existing, exists := dbq.tables().operations[obj.Operation_id]
+	if !exists {
+		return NewResultNotFoundError(fmt.Sprintf("unable to locate operation '%v'", obj.Operation_id))
+	}
+
+	existing.Cancel_requested = true
+	dbq.tables().operations[obj.Operation_id] = existing
+
+	obj.Cancel_requested = true
+
+	return nil
+}
+
+func (dbq *InMemoryDatabaseQueries) TimeoutExpiredOperations(ctx context.Context, now time.Time, operations *[]Operation) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParamsEntity(operations); err != nil {
+		return err
+	}
+
+	if now.IsZero() {
+		return fmt.Errorf("time must not be zero")
+	}
+
+	timedOut := []Operation{}
+
+	for id, operation := range dbq.tables().operations {
+
+		// A zero deadline is equivalent to a NULL deadline, which never expires
+		if operation.Deadline.IsZero() || !operation.Deadline.Before(now) {
+			continue
+		}
+
+		if operation.State != OperationState_Waiting && operation.State != OperationState_In_Progress {
+			continue
+		}
+
+		operation.State = OperationState_Timeout
+		operation.Last_state_update = now
+		operation.Human_readable_state = "operation did not complete before its deadline"
+
+		dbq.tables().operations[id] = operation
+		timedOut = append(timedOut, operation)
+	}
+
+	*operations = timedOut
+
+	return nil
+}
+
+func (dbq *InMemoryDatabaseQueries) ListIncompleteOperations(ctx context.Context, operations *[]Operation) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParamsEntity(operations); err != nil {
+		return err
+	}
+
+	*operations = dbq.listOperations(func(operation Operation) bool {
+		return operation.State == OperationState_Waiting || operation.State == OperationState_In_Progress
+	})
+
+	return nil
+}
+
+func (dbq *InMemoryDatabaseQueries) DeleteExpiredOperations(ctx context.Context, keepLastPerResource int, createdBefore time.Time) (int, error) {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if keepLastPerResource < 0 {
+		return 0, fmt.Errorf("number of operations to keep per resource must not be negative")
+	}
+
+	if createdBefore.IsZero() {
+		return 0, fmt.Errorf("time must not be zero")
+	}
+
+	type resourceKey struct {
+		resourceID   string
+		resourceType string
+	}
+
+	operationsByResource := map[resourceKey][]Operation{}
+	for _, operation := range dbq.tables().operations {
+		key := resourceKey{resourceID: operation.Resource_id, resourceType: operation.Resource_type}
+		operationsByResource[key] = append(operationsByResource[key], operation)
+	}
+
+	rowsAffected := 0
+
+	for _, operations := range operationsByResource {
+
+		// Rank the operations of the resource by 'created_on DESC, seq_id DESC': as with PostgreSQL, a zero (NULL)
+		// created_on is ranked first.
+		sort.Slice(operations, func(i, j int) bool {
+			if !operations[i].Created_on.Equal(operations[j].Created_on) {
+				if operations[i].Created_on.IsZero() || operations[j].Created_on.IsZero() {
+					return operations[i].Created_on.IsZero()
+				}
+				return operations[i].Created_on.After(operations[j].Created_on)
+			}
+			return operations[i].SeqID > operations[j].SeqID
+		})
+
+		for idx, operation := range operations {
+
+			rank := idx + 1
+
+			if rank <= keepLastPerResource || operation.Created_on.IsZero() || !operation.Created_on.Before(createdBefore) {
+				continue
+			}
+
+			if !IsOperationStateComplete(operation.State) {
+				continue
+			}
+
+			delete(dbq.tables().operations, operation.Operation_id)
+			rowsAffected++
+		}
+	}
+
+	return rowsAffected, nil
+}
+
+// checkOperation verifies the column lengths and foreign keys of an inserted or updated row
+func (dbq *InMemoryDatabaseQueries) checkOperation(obj *Operation) error {
+
+	if err := checkColumnLengths("operation", obj); err != nil {
+		return err
+	}
+
+	if _, exists := dbq.tables().gitopsEngineInstances[obj.Instance_id]; !exists {
+		return newMissingReferenceError("operation", "instance_id", obj.Instance_id)
+	}
+
+	if _, exists := dbq.tables().clusterUsers[obj.Operation_owner_user_id]; !exists {
+		return newMissingReferenceError("operation", "operation_owner_user_id", obj.Operation_owner_user_id)
+	}
+
+	return nil
+}
+
+// listOperations returns the Operations that match 'filter', ordered by seq_id
+func (dbq *InMemoryDatabaseQueries) listOperations(filter func(Operation) bool) []Operation {
+
+	res := []Operation{}
+
+	for _, operation := range dbq.tables().operations {
+		if filter(operation) {
+			res = append(res, operation)
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool { return res[i].SeqID < res[j].SeqID })
+
+	return res
+}
+
+// SyncOperation
+
+func (dbq *InMemoryDatabaseQueries) GetSyncOperationById(ctx context.Context, syncOperation *SyncOperation) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParamsEntity(syncOperation); err != nil {
+		return err
+	}
+
+	if isEmpty(syncOperation.SyncOperation_id) {
+		return fmt.Errorf("sync operation id is empty")
+	}
+
+	result, exists := dbq.tables().syncOperations[syncOperation.SyncOperation_id]
+	if !exists {
+		return NewResultNotFoundError("no results found for GetSyncOperationById")
+	}
+
+	*syncOperation = result
+
+	return nil
+}
+
+func (dbq *InMemoryDatabaseQueries) CreateSyncOperation(ctx context.Context, obj *SyncOperation) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParamsEntity(obj); err != nil {
+		return err
+	}
+
+	if err := dbq.generatePrimaryKey(&obj.SyncOperation_id); err != nil {
+		return err
+	}
+
+	if err := isEmptyValues("CreateSyncOperation",
+		"Application_id", obj.Application_id,
+		"DeploymentNameField", obj.DeploymentNameField,
+		"Revision", obj.Revision,
+		"DesiredState", obj.DesiredState); err != nil {
+		return err
+	}
+
+	obj.Created_on = time.Now()
+
+	if err := dbq.checkSyncOperation(obj); err != nil {
+		return fmt.Errorf("error on inserting application: %w", err)
+	}
+
+	if _, exists := dbq.tables().syncOperations[obj.SyncOperation_id]; exists {
+		return fmt.Errorf("error on inserting application: %w", newDuplicateKeyError("syncoperation", obj.SyncOperation_id))
+	}
+
+	dbq.tables().syncOperations[obj.SyncOperation_id] = *obj
+
+	return nil
+}
+
+func (dbq *InMemoryDatabaseQueries) UpdateSyncOperation(ctx context.Context, obj *SyncOperation) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParamsEntity(obj); err != nil {
+		return err
+	}
+
+	if err := isEmptyValues("UpdateSyncOperation",
+		"SyncOperation_id", obj.SyncOperation_id,
+		"DeploymentNameField", obj.DeploymentNameField,
+		"Revision", obj.Revision,
+		"DesiredState", obj.DesiredState); err != nil {
+		return err
+	}
+
+	existing, exists := dbq.tables().syncOperations[obj.SyncOperation_id]
+	if !exists {
+		return fmt.Errorf("unexpected number of rows affected: %d, %v", 0, obj.SyncOperation_id)
+	}
+
+	row := *obj
+
+	// created_on is only set on creation
+	row.Created_on = existing.Created_on
+
+	if err := dbq.checkSyncOperation(&row); err != nil {
+		return fmt.Errorf("error on updating syncoperation: %w, %v", err, obj.SyncOperation_id)
+	}
+
+	dbq.tables().syncOperations[row.SyncOperation_id] = row
+
+	return nil
+}
+
+func (dbq *InMemoryDatabaseQueries) DeleteSyncOperationById(ctx context.Context, id string) (int, error) {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParams(id); err != nil {
+		return 0, err
+	}
+
+	if _, exists := dbq.tables().syncOperations[id]; !exists {
+		return 0, nil
+	}
+
+	delete(dbq.tables().syncOperations, id)
+
+	return 1, nil
+}
+
+func (dbq *InMemoryDatabaseQueries) DeleteOrphanedSyncOperations(ctx context.Context, createdBefore time.Time) (int, error) {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if createdBefore.IsZero() {
+		return 0, fmt.Errorf("time must not be zero")
+	}
+
+	tables := dbq.tables()
+
+	rowsAffected := 0
+
+	for id, syncOperation := range tables.syncOperations {
+
+		if !syncOperation.Created_on.IsZero() && !syncOperation.Created_on.Before(createdBefore) {
+			continue
+		}
+
+		referenced := false
+
+		for _, mapping := range tables.apiCRToDatabaseMappings {
+			if mapping.DBRelationType == APICRToDatabaseMapping_DBRelationType_SyncOperation && mapping.DBRelationKey == id {
+				referenced = true
+				break
+			}
+		}
+
+		for _, operation := range tables.operations {
+			if operation.Resource_type == OperationResourceType_SyncOperation && operation.Resource_id == id &&
+				(operation.State == OperationState_Waiting || operation.State == OperationState_In_Progress) {
+				referenced = true
+				break
+			}
+		}
+
+		if referenced {
+			continue
+		}
+
+		delete(tables.syncOperations, id)
+		rowsAffected++
+	}
+
+	return rowsAffected, nil
+}
+
+func (dbq *InMemoryDatabaseQueries) UpdateSyncOperationRemoveApplicationField(ctx context.Context, applicationId string) (int, error) {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := isEmptyValues("UpdateOperationRemoveApplicationField",
+		"applicationId", applicationId); err != nil {
+		return 0, err
+	}
+
+	rowsAffected := 0
+
+	for id, syncOperation := range dbq.tables().syncOperations {
+
+		if syncOperation.Application_id != applicationId {
+			continue
+		}
+
+		syncOperation.Application_id = ""
+		dbq.tables().syncOperations[id] = syncOperation
+		rowsAffected++
+	}
+
+	return rowsAffected, nil
+}
+
+// checkSyncOperation verifies the column lengths and foreign keys of an inserted or updated row
+func (dbq *InMemoryDatabaseQueries) checkSyncOperation(obj *SyncOperation) error {
+
+	if err := checkColumnLengths("syncoperation", obj); err != nil {
+		return err
+	}
+
+	// An empty application_id is NULL, which does not reference an Application
+	if obj.Application_id != "" {
+		if _, exists := dbq.tables().applications[obj.Application_id]; !exists {
+			return newMissingReferenceError("syncoperation", "application_id", obj.Application_id)
+		}
+	}
+
+	return nil
+}
+
+// DeploymentToApplicationMapping
+
+func (dbq *InMemoryDatabaseQueries) ListDeploymentToApplicationMappingByWorkspaceUID(ctx context.Context, workspaceUID string,
+	deplToAppMappingParam *[]DeploymentToApplicationMapping) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParamsEntity(deplToAppMappingParam); err != nil {
+		return err
+	}
+
+	if err := isEmptyValues("ListDeploymentToApplicationMappingByWorkspaceUID",
+		"WorkspaceUID", workspaceUID,
+	); err != nil {
+		return err
+	}
+
+	*deplToAppMappingParam = dbq.listDeploymentToApplicationMappings(func(dtam DeploymentToApplicationMapping) bool {
+		return dtam.WorkspaceUID == workspaceUID
+	})
+
+	return nil
+}
+
+func (dbq *InMemoryDatabaseQueries) ListDeploymentToApplicationMappingByNamespaceAndName(ctx context.Context, deploymentName string,
+	deploymentNamespace string, workspaceUID string, deplToAppMappingParam *[]DeploymentToApplicationMapping) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParamsEntity(deplToAppMappingParam); err != nil {
+		return err
+	}
+
+	if err := isEmptyValues("ListDeploymentToApplicationMappingByNamespaceAndName",
+		"DeploymentName", deploymentName,
+		"DeploymentNamespace", deploymentNamespace,
+		"WorkspaceUID", workspaceUID,
+	); err != nil {
+		return err
+	}
+
+	*deplToAppMappingParam = dbq.listDeploymentToApplicationMappings(func(dtam DeploymentToApplicationMapping) bool {
+		return dtam.DeploymentName == deploymentName && dtam.DeploymentNamespace == deploymentNamespace &&
+			dtam.WorkspaceUID == workspaceUID
+	})
+
+	return nil
+}
+
+func (dbq *InMemoryDatabaseQueries) DeleteDeploymentToApplicationMappingByNamespaceAndName(ctx context.Context, deploymentName string, deploymentNamespace string, workspaceUID string) (int, error) {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := isEmptyValues("DeleteDeploymentToApplicationMappingByNamespaceAndName",
+		"deploymentName", deploymentName,
+		"deploymentNamespace", deploymentNamespace,
+		"workspaceUID", workspaceUID); err != nil {
+
+		return 0, err
+	}
+
+	rowsAffected := 0
+
+	for id, dtam := range dbq.tables().deploymentToApplicationMapping {
+		if dtam.DeploymentName == deploymentName && dtam.DeploymentNamespace == deploymentNamespace &&
+			dtam.WorkspaceUID == workspaceUID {
+
+			delete(dbq.tables().deploymentToApplicationMapping, id)
+			rowsAffected++
+		}
+	}
+
+	return rowsAffected, nil
+}
+
+func (dbq *InMemoryDatabaseQueries) GetDeploymentToApplicationMappingByDeplId(ctx context.Context, deplToAppMappingParam *DeploymentToApplicationMapping) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParamsEntity(deplToAppMappingParam); err != nil {
+		return err
+	}
+
+	if err := isEmptyValues("GetDeploymentToApplicationMappingByDeplId",
+		"Deploymenttoapplicationmapping_uid_id", deplToAppMappingParam.Deploymenttoapplicationmapping_uid_id,
+	); err != nil {
+		return err
+	}
+
+	result, exists := dbq.tables().deploymentToApplicationMapping[deplToAppMappingParam.Deploymenttoapplicationmapping_uid_id]
+	if !exists {
+		return NewResultNotFoundError("GetDeploymentToApplicationMappingById")
+	}
+
+	*deplToAppMappingParam = result
+
+	return nil
+}
+
+func (dbq *InMemoryDatabaseQueries) GetDeploymentToApplicationMappingByApplicationId(ctx context.Context, deplToAppMappingParam *DeploymentToApplicationMapping) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParamsEntity(deplToAppMappingParam); err != nil {
+		return err
+	}
+
+	if isEmpty(deplToAppMappingParam.Application_id) {
+		return fmt.Errorf("GetDeploymentToApplicationMappingByApplicationId: param is nil")
+	}
+
+	for _, result := range dbq.tables().deploymentToApplicationMapping {
+		if result.Application_id == deplToAppMappingParam.Application_id {
+			*deplToAppMappingParam = result
+			return nil
+		}
+	}
+
+	return NewResultNotFoundError("GetDeploymentToApplicationMappingByApplicationId")
+}
+
+func (dbq *InMemoryDatabaseQueries) CheckedGetDeploymentToApplicationMappingByDeplId(ctx context.Context, deplToAppMappingParam *DeploymentToApplicationMapping, ownerId string) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParamsEntity(deplToAppMappingParam); err != nil {
+		return err
+	}
+
+	if isEmpty(deplToAppMappingParam.Deploymenttoapplicationmapping_uid_id) {
+		return fmt.Errorf("GetDeploymentToApplicationMappingByDeplId: param is nil")
+	}
+
+	if isEmpty(ownerId) {
+		return fmt.Errorf("ownerid is empty")
+	}
+
+	result, exists := dbq.tables().deploymentToApplicationMapping[deplToAppMappingParam.Deploymenttoapplicationmapping_uid_id]
+	if !exists {
+		return NewResultNotFoundError("GetDeploymentToApplicationMappingById")
+	}
+
+	// Check that the user has access to retrieve the referenced Application
+	deplApplication := Application{Application_id: result.Application_id}
+	if err := dbq.CheckedGetApplicationById(ctx, &deplApplication, ownerId); err != nil {
+
+		if IsResultNotFoundError(err) {
+			return NewResultNotFoundError(fmt.Sprintf("unable to retrieve deployment mapping for Application: %v", err))
+		}
+
+		return fmt.Errorf("unable to retrieve application of deployment mapping: %w", err)
+	}
+
+	*deplToAppMappingParam = result
+
+	return nil
+}
+
+func (dbq *InMemoryDatabaseQueries) CheckedDeleteDeploymentToApplicationMappingByDeplId(ctx context.Context, id string, ownerId string) (int, error) {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParams(id); err != nil {
+		return 0, err
+	}
+
+	entity := &DeploymentToApplicationMapping{
+		Deploymenttoapplicationmapping_uid_id: id,
+	}
+
+	// Verify that the user can delete the mapping, by checking that they can access it.
+	if err := dbq.CheckedGetDeploymentToApplicationMappingByDeplId(ctx, entity, ownerId); err != nil {
+
+		if IsResultNotFoundError(err) {
+			return 0, nil
+		}
+
+		return 0, err
+	}
+
+	delete(dbq.tables().deploymentToApplicationMapping, id)
+
+	return 1, nil
+}
+
+func (dbq *InMemoryDatabaseQueries) DeleteDeploymentToApplicationMappingByDeplId(ctx context.Context, id string) (int, error) {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParams(id); err != nil {
+		return 0, err
+	}
+
+	if _, exists := dbq.tables().deploymentToApplicationMapping[id]; !exists {
+		return 0, nil
+	}
+
+	delete(dbq.tables().deploymentToApplicationMapping, id)
+
+	return 1, nil
+}
+
+func (dbq *InMemoryDatabaseQueries) CreateDeploymentToApplicationMapping(ctx context.Context, obj *DeploymentToApplicationMapping) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParamsEntity(obj); err != nil {
+		return err
+	}
+
+	if err := isEmptyValues("CreateDeploymentToApplicationMapping",
+		"Application_id", obj.Application_id,
+		"Deploymenttoapplicationmapping_uid_id", obj.Deploymenttoapplicationmapping_uid_id,
+		"DeploymentName", obj.DeploymentName,
+		"DeploymentNamespace", obj.DeploymentNamespace,
+		"WorkspaceUID", obj.WorkspaceUID,
+	); err != nil {
+		return err
+	}
+
+	tables := dbq.tables()
+
+	if err := checkColumnLengths("deploymenttoapplicationmapping", obj); err != nil {
+		return fmt.Errorf("error on inserting DeploymentToApplicationMapping %w", err)
+	}
+
+	if _, exists := tables.deploymentToApplicationMapping[obj.Deploymenttoapplicationmapping_uid_id]; exists {
+		return fmt.Errorf("error on inserting DeploymentToApplicationMapping %w",
+			newDuplicateKeyError("deploymenttoapplicationmapping", obj.Deploymenttoapplicationmapping_uid_id))
+	}
+
+	for _, dtam := range tables.deploymentToApplicationMapping {
+		if dtam.Application_id == obj.Application_id {
+			return fmt.Errorf("error on inserting DeploymentToApplicationMapping %w",
+				newDuplicateKeyError("deploymenttoapplicationmapping", obj.Application_id))
+		}
+	}
+
+	if _, exists := tables.applications[obj.Application_id]; !exists {
+		return fmt.Errorf("error on inserting DeploymentToApplicationMapping %w",
+			newMissingReferenceError("deploymenttoapplicationmapping", "application_id", obj.Application_id))
+	}
+
+	if obj.SeqID == 0 {
+		obj.SeqID = dbq.nextSeqID()
+	}
+
+	tables.deploymentToApplicationMapping[obj.Deploymenttoapplicationmapping_uid_id] = *obj
+
+	return nil
+}
+
+// listDeploymentToApplicationMappings returns the DeploymentToApplicationMappings that match 'filter', ordered by seq_id
+func (dbq *InMemoryDatabaseQueries) listDeploymentToApplicationMappings(filter func(DeploymentToApplicationMapping) bool) []DeploymentToApplicationMapping {
+
+	res := []DeploymentToApplicationMapping{}
+
+	for _, dtam := range dbq.tables().deploymentToApplicationMapping {
+		if filter(dtam) {
+			res = append(res, dtam)
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool { return res[i].SeqID < res[j].SeqID })
+
+	return res
+}
+
+// KubernetesToDBResourceMapping
+
+func (dbq *InMemoryDatabaseQueries) DeleteKubernetesResourceToDBResourceMapping(ctx context.Context, obj *KubernetesToDBResourceMapping) (int, error) {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParamsEntity(obj); err != nil {
+		return 0, err
+	}
+
+	if err := isEmptyValues("DeleteKubernetesResourceToDBResourceMapping",
+		"KubernetesResourceType", obj.KubernetesResourceType,
+		"KubernetesResourceUID", obj.KubernetesResourceUID,
+		"DBRelationKey", obj.DBRelationKey,
+		"DBRelationType", obj.DBRelationType); err != nil {
+		return 0, err
+	}
+
+	key := newKubernetesToDBResourceMappingKey(obj)
+
+	if _, exists := dbq.tables().kubernetesToDBResourceMappings[key]; !exists {
+		return 0, nil
+	}
+
+	delete(dbq.tables().kubernetesToDBResourceMappings, key)
+
+	return 1, nil
+}
+
+func (dbq *InMemoryDatabaseQueries) GetDBResourceMappingForKubernetesResource(ctx context.Context, obj *KubernetesToDBResourceMapping) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParamsEntity(obj); err != nil {
+		return err
+	}
+
+	if err := isEmptyValues("GetDBResourceMappingForKubernetesResource",
+		"KubernetesResourceType", obj.KubernetesResourceType,
+		"KubernetesResourceUID", obj.KubernetesResourceUID,
+		"DBRelationType", obj.DBRelationType); err != nil {
+		return err
+	}
+
+	var result []KubernetesToDBResourceMapping
+
+	for _, mapping := range dbq.tables().kubernetesToDBResourceMappings {
+		if mapping.KubernetesResourceType == obj.KubernetesResourceType && mapping.KubernetesResourceUID == obj.KubernetesResourceUID &&
+			mapping.DBRelationType == obj.DBRelationType {
+
+			result = append(result, mapping)
+		}
+	}
+
+	if len(result) == 0 {
+		return NewResultNotFoundError(fmt.Sprintf("unable to retrieve mapping for %s:%s", obj.KubernetesResourceType, obj.KubernetesResourceUID))
+	}
+
+	if len(result) > 1 {
+		return fmt.Errorf("unexpected number of results when retrieving mapping for %s:%s", obj.KubernetesResourceType, obj.KubernetesResourceUID)
+	}
+
+	*obj = result[0]
+
+	return nil
+}
+
+func (dbq *InMemoryDatabaseQueries) ListKubernetesToDBResourceMappingsByType(ctx context.Context, kubernetesResourceType string,
+	dbRelationType string, kubernetesToDBMappings *[]KubernetesToDBResourceMapping) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParamsEntity(kubernetesToDBMappings); err != nil {
+		return err
+	}
+
+	if err := isEmptyValues("ListKubernetesToDBResourceMappingsByType",
+		"KubernetesResourceType", kubernetesResourceType,
+		"DBRelationType", dbRelationType); err != nil {
+		return err
+	}
+
+	res := []KubernetesToDBResourceMapping{}
+
+	for _, mapping := range dbq.tables().kubernetesToDBResourceMappings {
+		if mapping.KubernetesResourceType == kubernetesResourceType && mapping.DBRelationType == dbRelationType {
+			res = append(res, mapping)
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool { return res[i].SeqID < res[j].SeqID })
+
+	*kubernetesToDBMappings = res
+
+	return nil
+}
+
+func (dbq *InMemoryDatabaseQueries) CreateKubernetesResourceToDBResourceMapping(ctx context.Context, obj *KubernetesToDBResourceMapping) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParamsEntity(obj); err != nil {
+		return err
+	}
+
+	if err := isEmptyValues("CreateKubernetesResourceToDBResourceMapping",
+		"DBRelationKey", obj.DBRelationKey,
+		"DBRelationType", obj.DBRelationType,
+		"KubernetesResourceType", obj.KubernetesResourceType,
+		"KubernetesResourceUID", obj.KubernetesResourceUID); err != nil {
+		return err
+	}
+
+	if err := checkColumnLengths("kubernetestodbresourcemapping", obj); err != nil {
+		return fmt.Errorf("error on inserting managed environment: %w", err)
+	}
+
+	key := newKubernetesToDBResourceMappingKey(obj)
+	if _, exists := dbq.tables().kubernetesToDBResourceMappings[key]; exists {
+		return fmt.Errorf("error on inserting managed environment: %w", newDuplicateKeyError("kubernetestodbresourcemapping", key))
+	}
+
+	if obj.SeqID == 0 {
+		obj.SeqID = dbq.nextSeqID()
+	}
+
+	dbq.tables().kubernetesToDBResourceMappings[key] = *obj
+
+	return nil
+}
+
+func newKubernetesToDBResourceMappingKey(obj *KubernetesToDBResourceMapping) resourceMappingKey {
+	return resourceMappingKey{
+		resourceType:   obj.KubernetesResourceType,
+		resourceUID:    obj.KubernetesResourceUID,
+		dbRelationType: obj.DBRelationType,
+		dbRelationKey:  obj.DBRelationKey,
+	}
+}
+
+// APICRToDatabaseMapping
+
+func (dbq *InMemoryDatabaseQueries) DeleteAPICRToDatabaseMapping(ctx context.Context, obj *APICRToDatabaseMapping) (int, error) {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParamsEntity(obj); err != nil {
+		return 0, err
+	}
+
+	if err := isEmptyValues("DeleteAPICRToDatabaseMapping",
+		"APIResourceType", obj.APIResourceType,
+		"APIResourceUID", obj.APIResourceUID,
+		"DBRelationKey", obj.DBRelationKey,
+		"DBRelationType", obj.DBRelationType,
+	); err != nil {
+		return 0, err
+	}
+
+	key := newAPICRToDatabaseMappingKey(obj)
+
+	if _, exists := dbq.tables().apiCRToDatabaseMappings[key]; !exists {
+		return 0, nil
+	}
+
+	delete(dbq.tables().apiCRToDatabaseMappings, key)
+
+	return 1, nil
+}
+
+func (dbq *InMemoryDatabaseQueries) CreateAPICRToDatabaseMapping(ctx context.Context, obj *APICRToDatabaseMapping) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParamsEntity(obj); err != nil {
+		return err
+	}
+
+	if err := isEmptyValues("CreateAPICRToDatabaseMapping",
+		"APIResourceName", obj.APIResourceName,
+		"APIResourceNamespace", obj.APIResourceNamespace,
+		"APIResourceType", obj.APIResourceType,
+		"APIResourceUID", obj.APIResourceUID,
+		"DBRelationKey", obj.DBRelationKey,
+		"DBRelationType", obj.DBRelationType,
+	); err != nil {
+		return err
+	}
+
+	if err := checkColumnLengths("apicrtodatabasemapping", obj); err != nil {
+		return fmt.Errorf("error on inserting APICRToDatabaseMapping %w", err)
+	}
+
+	key := newAPICRToDatabaseMappingKey(obj)
+	if _, exists := dbq.tables().apiCRToDatabaseMappings[key]; exists {
+		return fmt.Errorf("error on inserting APICRToDatabaseMapping %w", newDuplicateKeyError("apicrtodatabasemapping", key))
+	}
+
+	if obj.SeqID == 0 {
+		obj.SeqID = dbq.nextSeqID()
+	}
+
+	dbq.tables().apiCRToDatabaseMappings[key] = *obj
+
+	return nil
+}
+
+func (dbq *InMemoryDatabaseQueries) GetDatabaseMappingForAPICR(ctx context.Context, obj *APICRToDatabaseMapping) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParamsEntity(obj); err != nil {
+		return err
+	}
+
+	if err := isEmptyValues("GetDatabaseMappingForAPICR",
+		"APIResourceType", obj.APIResourceType,
+		"APIResourceUID", obj.APIResourceUID,
+		"DBRelationType", obj.DBRelationType); err != nil {
+		return err
+	}
+
+	result := dbq.listAPICRToDatabaseMappings(func(mapping APICRToDatabaseMapping) bool {
+		return mapping.APIResourceType == obj.APIResourceType && mapping.APIResourceUID == obj.APIResourceUID &&
+			mapping.DBRelationType == obj.DBRelationType
+	})
+
+	if len(result) == 0 {
+		return NewResultNotFoundError(fmt.Sprintf("unable to retrieve APICRToDatabase mapping for %s:%s", obj.APIResourceType, obj.APIResourceUID))
+	}
+
+	if len(result) > 1 {
+		return fmt.Errorf("unexpected number of results when retrieving APICRToDatabase mapping for %s:%s", obj.APIResourceType, obj.APIResourceUID)
+	}
+
+	*obj = result[0]
+
+	return nil
+}
+
+func (dbq *InMemoryDatabaseQueries) ListAPICRToDatabaseMappingByAPINamespaceAndName(ctx context.Context, apiCRResourceType string, crName string, crNamespace string, crWorkspaceUID string, dbRelationType string, apiCRToDBMappingParam *[]APICRToDatabaseMapping) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParamsEntity(apiCRToDBMappingParam); err != nil {
+		return err
+	}
+
+	if err := isEmptyValues("ListAPICRToDatabaseMappingByAPINamespaceAndName",
+		"apiCRResourceType", apiCRResourceType,
+		"crName", crName,
+		"crNamespace", crNamespace,
+		"crWorkspaceUID", crWorkspaceUID,
+		"dbRelationType", dbRelationType,
+	); err != nil {
+		return err
+	}
+
+	*apiCRToDBMappingParam = dbq.listAPICRToDatabaseMappings(func(mapping APICRToDatabaseMapping) bool {
+		return mapping.APIResourceType == apiCRResourceType && mapping.APIResourceName == crName &&
+			mapping.APIResourceNamespace == crNamespace && mapping.WorkspaceUID == crWorkspaceUID &&
+			mapping.DBRelationType == dbRelationType
+	})
+
+	return nil
+}
+
+func newAPICRToDatabaseMappingKey(obj *APICRToDatabaseMapping) resourceMappingKey {
+	return resourceMappingKey{
+		resourceType:   obj.APIResourceType,
+		resourceUID:    obj.APIResourceUID,
+		dbRelationType: obj.DBRelationType,
+		dbRelationKey:  obj.DBRelationKey,
+	}
+}
+
+// listAPICRToDatabaseMappings returns the APICRToDatabaseMappings that match 'filter', ordered by seq_id
+func (dbq *InMemoryDatabaseQueries) listAPICRToDatabaseMappings(filter func(APICRToDatabaseMapping) bool) []APICRToDatabaseMapping {
+
+	res := []APICRToDatabaseMapping{}
+
+	for _, mapping := range dbq.tables().apiCRToDatabaseMappings {
+		if filter(mapping) {
+			res = append(res, mapping)
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool { return res[i].SeqID < res[j].SeqID })
+
+	return res
+}
+
+// Consistency checks: see 'consistency.go'
+
+func (dbq *InMemoryDatabaseQueries) ListApplicationsWithoutDeploymentToApplicationMapping(ctx context.Context, applications *[]Application) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParamsEntity(applications); err != nil {
+		return err
+	}
+
+	mappedApplications := map[string]bool{}
+	for _, dtam := range dbq.tables().deploymentToApplicationMapping {
+		mappedApplications[dtam.Application_id] = true
+	}
+
+	*applications = dbq.listApplications(func(application Application) bool {
+		return !mappedApplications[application.Application_id]
+	})
+
+	return nil
+}
+
+func (dbq *InMemoryDatabaseQueries) ListApplicationsForGitopsEngineInstance(ctx context.Context, gitopsEngineInstanceId string, applications *[]Application) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParamsEntity(applications); err != nil {
+		return err
+	}
+
+	if isEmpty(gitopsEngineInstanceId) {
+		return fmt.Errorf("gitops engine instance id is empty")
+	}
+
+	*applications = dbq.listApplications(func(application Application) bool {
+		return application.Engine_instance_inst_id == gitopsEngineInstanceId
+	})
+
+	return nil
+}
+
+func (dbq *InMemoryDatabaseQueries) ListDeploymentToApplicationMappings(ctx context.Context, deplToAppMappings *[]DeploymentToApplicationMapping) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParamsEntity(deplToAppMappings); err != nil {
+		return err
+	}
+
+	*deplToAppMappings = dbq.listDeploymentToApplicationMappings(func(DeploymentToApplicationMapping) bool { return true })
+
+	return nil
+}
+
+func (dbq *InMemoryDatabaseQueries) ListAPICRToDatabaseMappings(ctx context.Context, apiCRToDBMappings *[]APICRToDatabaseMapping) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParamsEntity(apiCRToDBMappings); err != nil {
+		return err
+	}
+
+	*apiCRToDBMappings = dbq.listAPICRToDatabaseMappings(func(APICRToDatabaseMapping) bool { return true })
+
+	return nil
+}
+
+func (dbq *InMemoryDatabaseQueries) ListAPICRToDatabaseMappingsWithMissingDBRelation(ctx context.Context, apiCRToDBMappings *[]APICRToDatabaseMapping) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParamsEntity(apiCRToDBMappings); err != nil {
+		return err
+	}
+
+	*apiCRToDBMappings = dbq.listAPICRToDatabaseMappings(func(mapping APICRToDatabaseMapping) bool {
+
+		if mapping.DBRelationType != APICRToDatabaseMapping_DBRelationType_SyncOperation {
+			return false
+		}
+
+		_, exists := dbq.tables().syncOperations[mapping.DBRelationKey]
+		return !exists
+	})
+
+	return nil
+}
+
+func (dbq *InMemoryDatabaseQueries) ListKubernetesToDBResourceMappingsWithMissingDBRelation(ctx context.Context, kubernetesToDBMappings *[]KubernetesToDBResourceMapping) error {
+
+	dbq, unlock := dbq.lock()
+	defer unlock()
+
+	if err := validateInMemoryQueryParamsEntity(kubernetesToDBMappings); err != nil {
+		return err
+	}
+
+	tables := dbq.tables()
+
+	res := []KubernetesToDBResourceMapping{}
+
+	for _, mapping := range tables.kubernetesToDBResourceMappings {
+
+		missing := false
+
+		switch mapping.DBRelationType {
+		case K8sToDBMapping_ManagedEnvironment:
+			_, exists := tables.managedEnvironments[mapping.DBRelationKey]
+			missing = !exists
+		case K8sToDBMapping_GitopsEngineCluster:
+			_, exists := tables.gitopsEngineClusters[mapping.DBRelationKey]
+			missing = !exists
+		case K8sToDBMapping_GitopsEngineInstance:
+			_, exists := tables.gitopsEngineInstances[mapping.DBRelationKey]
+			missing = !exists
+		}
+
+		if missing {
+			res = append(res, mapping)
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool { return res[i].SeqID < res[j].SeqID })
+
+	*kubernetesToDBMappings = res
+
+	return nil
+}
@@ -32,7 +32,7 @@ func (dbq *PostgreSQLDatabaseQueries) DeleteKubernetesResourceToDBResourceMappin
 
 	deleteResult, err := dbq.dbConnection.Model(&obj).WherePK().Context(ctx).Delete()
 	if err != nil {
-		return 0, fmt.Errorf("error on deleting operation: %v", err)
+		return 0, fmt.Errorf("error on deleting operation: %w", mapDBError(err))
 	}
 
 	return deleteResult.RowsAffected(), nil
@@ -60,7 +60,7 @@ func (dbq *PostgreSQLDatabaseQueries) GetDBResourceMappingForKubernetesResource(
 		Context(ctx).
 		Select(); err != nil {
 
-		return fmt.Errorf("error on retrieving db resource mapping: %v", err)
+		return fmt.Errorf("error on retrieving db resource mapping: %w", mapDBError(err))
 	}
 
 	if len(result) == 0 {
@@ -77,6 +77,38 @@ func (dbq *PostgreSQLDatabaseQueries) GetDBResourceMappingForKubernetesResource(
 
 }
 
+// ListKubernetesToDBResourceMappingsByType returns the mappings between the given K8s resource type and database
+// table, for all K8s resources: for example, the ManagedEnvironments of all workspace Namespaces.
+func (dbq *PostgreSQLDatabaseQueries) ListKubernetesToDBResourceMappingsByType(ctx context.Context, kubernetesResourceType string,
+	dbRelationType string, kubernetesToDBMappings *[]KubernetesToDBResourceMapping) error {
+
+	if err := validateQueryParamsEntity(kubernetesToDBMappings, dbq); err != nil {
+		return err
+	}
+
+	if err := isEmptyValues("ListKubernetesToDBResourceMappingsByType",
+		"KubernetesResourceType", kubernetesResourceType,
+		"DBRelationType", dbRelationType); err != nil {
+		return err
+	}
+
+	var dbResults []KubernetesToDBResourceMapping
+
+	if err := dbq.dbConnection.Model(&dbResults).
+		Where("ktdbrm.kubernetes_resource_type = ?", kubernetesResourceType).
+		Where("ktdbrm.db_relation_type = ?", dbRelationType).
+		Order("seq_id ASC").
+		Context(ctx).
+		Select(); err != nil {
+
+		return fmt.Errorf("error on retrieving ListKubernetesToDBResourceMappingsByType: %w", mapDBError(err))
+	}
+
+	*kubernetesToDBMappings = dbResults
+
+	return nil
+}
+
 func (dbq *PostgreSQLDatabaseQueries) CreateKubernetesResourceToDBResourceMapping(ctx context.Context, obj *KubernetesToDBResourceMapping) error {
 
 	if err := validateQueryParamsEntity(obj, dbq); err != nil {
@@ -93,7 +125,7 @@ func (dbq *PostgreSQLDatabaseQueries) CreateKubernetesResourceToDBResourceMappin
 
 	result, err := dbq.dbConnection.Model(obj).Context(ctx).Insert()
 	if err != nil {
-		return fmt.Errorf("error on inserting managed environment: %v", err)
+		return fmt.Errorf("error on inserting managed environment: %w", mapDBError(err))
 	}
 
 	if result.RowsAffected() != 1 {
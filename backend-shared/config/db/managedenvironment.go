@@ -28,7 +28,7 @@ func (dbq *PostgreSQLDatabaseQueries) CreateManagedEnvironment(ctx context.Conte
 
 	result, err := dbq.dbConnection.Model(obj).Context(ctx).Insert()
 	if err != nil {
-		return fmt.Errorf("error on inserting managed environment: %v", err)
+		return fmt.Errorf("error on inserting managed environment: %w", mapDBError(err))
 	}
 
 	if result.RowsAffected() != 1 {
@@ -45,7 +45,7 @@ func (dbq *PostgreSQLDatabaseQueries) UnsafeListAllManagedEnvironments(ctx conte
 	}
 
 	if err := dbq.dbConnection.Model(managedEnvironments).Context(ctx).Select(); err != nil {
-		return err
+		return mapDBError(err)
 	}
 
 	return nil
@@ -70,7 +70,7 @@ func (dbq *PostgreSQLDatabaseQueries) ListManagedEnvironmentForClusterCredential
 		Context(ctx).
 		Select(); err != nil {
 
-		return fmt.Errorf("error on retrieving ManagedEnvironment: %v", err)
+		return fmt.Errorf("error on retrieving ManagedEnvironment: %w", mapDBError(err))
 	}
 
 	*managedEnvironments = result
@@ -95,7 +95,7 @@ func (dbq *PostgreSQLDatabaseQueries) GetManagedEnvironmentById(ctx context.Cont
 		Context(ctx).
 		Select(); err != nil {
 
-		return fmt.Errorf("error on retrieving ManagedEnvironment by id '%s': %v", managedEnvironment.Managedenvironment_id, err)
+		return fmt.Errorf("error on retrieving ManagedEnvironment by id '%s': %w", managedEnvironment.Managedenvironment_id, mapDBError(err))
 	}
 
 	if len(dbResults) >= 2 {
@@ -134,7 +134,7 @@ func (dbq *PostgreSQLDatabaseQueries) CheckedGetManagedEnvironmentById(ctx conte
 		Context(ctx).
 		Select(); err != nil {
 
-		return fmt.Errorf("error on retrieving ManagedEnvironment by id '%s': %v", managedEnvironment.Managedenvironment_id, err)
+		return fmt.Errorf("error on retrieving ManagedEnvironment by id '%s': %w", managedEnvironment.Managedenvironment_id, mapDBError(err))
 	}
 
 	if len(dbResults) >= 2 {
@@ -163,12 +163,12 @@ func (dbq *PostgreSQLDatabaseQueries) CheckedDeleteManagedEnvironmentById(ctx co
 	existingValue := ManagedEnvironment{Managedenvironment_id: id}
 	err := dbq.CheckedGetManagedEnvironmentById(ctx, &existingValue, ownerId)
 	if err != nil || existingValue.Managedenvironment_id != id {
-		return 0, fmt.Errorf("unable to locate managed environment id, or access denied: %s", id)
+		return 0, fmt.Errorf("unable to locate managed environment id, or access denied: '%s', %w", id, mapDBError(err))
 	}
 
 	deleteResult, err := dbq.dbConnection.Model(&existingValue).WherePK().Context(ctx).Delete()
 	if err != nil {
-		return 0, fmt.Errorf("error on deleting operation: %v", err)
+		return 0, fmt.Errorf("error on deleting operation: %w", mapDBError(err))
 	}
 
 	return deleteResult.RowsAffected(), nil
@@ -187,7 +187,29 @@ func (dbq *PostgreSQLDatabaseQueries) DeleteManagedEnvironmentById(ctx context.C
 
 	deleteResult, err := dbq.dbConnection.Model(result).WherePK().Context(ctx).Delete()
 	if err != nil {
-		return 0, fmt.Errorf("error on deleting operation: %v", err)
+		return 0, fmt.Errorf("error on deleting operation: %w", mapDBError(err))
+	}
+
+	return deleteResult.RowsAffected(), nil
+}
+
+// DeleteManagedEnvironmentWithoutClusterAccessById deletes the ManagedEnvironment only if no ClusterAccess references
+// it, that is, once no user has access to it: 0 rows are affected otherwise.
+func (dbq *PostgreSQLDatabaseQueries) DeleteManagedEnvironmentWithoutClusterAccessById(ctx context.Context, id string) (int, error) {
+
+	if err := validateQueryParams(id, dbq); err != nil {
+		return 0, err
+	}
+
+	result := &ManagedEnvironment{
+		Managedenvironment_id: id,
+	}
+
+	deleteResult, err := dbq.dbConnection.Model(result).WherePK().
+		Where("NOT EXISTS (SELECT 1 FROM clusteraccess AS ca WHERE ca.clusteraccess_managed_environment_id = me.managedenvironment_id)").
+		Context(ctx).Delete()
+	if err != nil {
+		return 0, fmt.Errorf("error on deleting managed environment: %w", mapDBError(err))
 	}
 
 	return deleteResult.RowsAffected(), nil
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"time"
+
+	"github.com/go-pg/pg/v10"
 )
 
 // Unsafe: Should only be used in test code.
@@ -14,7 +16,7 @@ func (dbq *PostgreSQLDatabaseQueries) UnsafeListAllOperations(ctx context.Contex
 	}
 
 	if err := dbq.dbConnection.Model(operations).Context(ctx).Select(); err != nil {
-		return err
+		return mapDBError(err)
 	}
 
 	return nil
@@ -55,18 +57,22 @@ func (dbq *PostgreSQLDatabaseQueries) CreateOperation(ctx context.Context, obj *
 	// Verify the instance exists
 	gei := GitopsEngineInstance{Gitopsengineinstance_id: obj.Instance_id}
 	if err := dbq.GetGitopsEngineInstanceById(ctx, &gei); err != nil {
-		return fmt.Errorf("unable to retrieve operation's gitops engine instance ID: '%v' %v", obj.Instance_id, err)
+		return fmt.Errorf("unable to retrieve operation's gitops engine instance ID: '%v' %w", obj.Instance_id, mapDBError(err))
 	}
 
 	obj.Created_on = time.Now()
 	obj.Last_state_update = obj.Created_on
 
+	if obj.Deadline.IsZero() {
+		obj.Deadline = obj.Created_on.Add(GetOperationTimeout(obj.Resource_type))
+	}
+
 	// Initial state is waiting
 	obj.State = OperationState_Waiting
 
 	result, err := dbq.dbConnection.Model(obj).Context(ctx).Insert()
 	if err != nil {
-		return fmt.Errorf("error on inserting operation: %v", err)
+		return fmt.Errorf("error on inserting operation: %w", mapDBError(err))
 	}
 
 	if result.RowsAffected() != 1 {
@@ -92,9 +98,11 @@ func (dbq *PostgreSQLDatabaseQueries) UpdateOperation(ctx context.Context, obj *
 		return err
 	}
 
-	result, err := dbq.dbConnection.Model(obj).WherePK().Context(ctx).Update()
+	// cancel_requested is only set via RequestOperationCancellation: this prevents a cancellation request from being
+	// overwritten by an update from a stale copy of the operation.
+	result, err := dbq.dbConnection.Model(obj).WherePK().ExcludeColumn("cancel_requested").Context(ctx).Update()
 	if err != nil {
-		return fmt.Errorf("error on updating operation: %v, %v", err, obj.Operation_id)
+		return fmt.Errorf("error on updating operation: %w, %v", mapDBError(err), obj.Operation_id)
 	}
 
 	if result.RowsAffected() != 1 {
@@ -122,7 +130,7 @@ func (dbq *PostgreSQLDatabaseQueries) GetOperationById(ctx context.Context, oper
 		Context(ctx).
 		Select(); err != nil {
 
-		return fmt.Errorf("error on retrieving operation: %v", err)
+		return fmt.Errorf("error on retrieving operation: %w", mapDBError(err))
 	}
 
 	if len(dbResult) == 0 {
@@ -161,7 +169,7 @@ func (dbq *PostgreSQLDatabaseQueries) CheckedGetOperationById(ctx context.Contex
 		Context(ctx).
 		Select(); err != nil {
 
-		return fmt.Errorf("error on retrieving operation %v", err)
+		return fmt.Errorf("error on retrieving operation %w", mapDBError(err))
 	}
 
 	if len(dbResult) == 0 {
@@ -192,7 +200,7 @@ func (dbq *PostgreSQLDatabaseQueries) DeleteOperationById(ctx context.Context, i
 		Context(ctx).
 		Delete()
 	if err != nil {
-		return 0, fmt.Errorf("error on deleting operation: %v", err)
+		return 0, fmt.Errorf("error on deleting operation: %w", mapDBError(err))
 	}
 
 	return deleteResult.RowsAffected(), nil
@@ -217,7 +225,7 @@ func (dbq *PostgreSQLDatabaseQueries) CheckedDeleteOperationById(ctx context.Con
 		Context(ctx).
 		Delete()
 	if err != nil {
-		return 0, fmt.Errorf("error on deleting operation: %v", err)
+		return 0, fmt.Errorf("error on deleting operation: %w", mapDBError(err))
 	}
 
 	return deleteResult.RowsAffected(), nil
@@ -247,10 +255,154 @@ func (dbq *PostgreSQLDatabaseQueries) ListOperationsByResourceIdAndTypeAndOwnerI
 		Context(ctx).
 		Select(); err != nil {
 
-		return fmt.Errorf("error on retrieving ListOperationsByResourceIdAndTypeAndOwnerId: %v", err)
+		return fmt.Errorf("error on retrieving ListOperationsByResourceIdAndTypeAndOwnerId: %w", mapDBError(err))
 	}
 
 	*operations = dbResults
 
 	return nil
 }
+
+// ListOperationsByOwnerId returns the Operations owned by the ClusterUser, across all resources.
+func (dbq *PostgreSQLDatabaseQueries) ListOperationsByOwnerId(ctx context.Context, ownerId string, operations *[]Operation) error {
+
+	if err := validateQueryParamsEntity(operations, dbq); err != nil {
+		return err
+	}
+
+	if err := isEmptyValues("ListOperationsByOwnerId", "ownerId", ownerId); err != nil {
+		return err
+	}
+
+	var dbResults []Operation
+
+	if err := dbq.dbConnection.Model(&dbResults).
+		Where("op.operation_owner_user_id = ?", ownerId).
+		Order("seq_id ASC").
+		Context(ctx).
+		Select(); err != nil {
+
+		return fmt.Errorf("error on retrieving ListOperationsByOwnerId: %w", mapDBError(err))
+	}
+
+	*operations = dbResults
+
+	return nil
+}
+
+// RequestOperationCancellation requests that the cluster-agent stop processing the operation: the cluster-agent will
+// then move the operation to the Cancelled state. Operations that have already completed are not affected.
+func (dbq *PostgreSQLDatabaseQueries) RequestOperationCancellation(ctx context.Context, obj *Operation) error {
+
+	if err := validateQueryParamsEntity(obj, dbq); err != nil {
+		return err
+	}
+
+	if isEmpty(obj.Operation_id) {
+		return fmt.Errorf("invalid pk")
+	}
+
+	result, err := dbq.dbConnection.Model(obj).
+		Set("cancel_requested = ?", true).
+		WherePK().
+		Context(ctx).
+		Update()
+	if err != nil {
+		return fmt.Errorf("error on requesting cancellation of operation: %w, %v", mapDBError(err), obj.Operation_id)
+	}
+
+	if result.RowsAffected() != 1 {
+		return NewResultNotFoundError(fmt.Sprintf("unable to locate operation '%v'", obj.Operation_id))
+	}
+
+	obj.Cancel_requested = true
+
+	return nil
+}
+
+// TimeoutExpiredOperations moves all operations that have not completed by their deadline to the Timeout state, and
+// returns the operations that were updated (with their new state).
+func (dbq *PostgreSQLDatabaseQueries) TimeoutExpiredOperations(ctx context.Context, now time.Time, operations *[]Operation) error {
+
+	if err := validateQueryParamsEntity(operations, dbq); err != nil {
+		return err
+	}
+
+	if now.IsZero() {
+		return fmt.Errorf("time must not be zero")
+	}
+
+	var dbResults []Operation
+
+	if _, err := dbq.dbConnection.Model(&dbResults).
+		Set("state = ?", OperationState_Timeout).
+		Set("last_state_update = ?", now).
+		Set("human_readable_state = ?", "operation did not complete before its deadline").
+		Where("op.deadline < ?", now).
+		WhereIn("op.state IN (?)", []string{OperationState_Waiting, OperationState_In_Progress}).
+		Returning("*").
+		Context(ctx).
+		Update(); err != nil {
+		return fmt.Errorf("error on updating expired operations: %w", mapDBError(err))
+	}
+
+	*operations = dbResults
+
+	return nil
+}
+
+// ListIncompleteOperations returns the operations of all users that are in the Waiting or In_Progress state.
+func (dbq *PostgreSQLDatabaseQueries) ListIncompleteOperations(ctx context.Context, operations *[]Operation) error {
+
+	if err := validateQueryParamsEntity(operations, dbq); err != nil {
+		return err
+	}
+
+	var dbResults []Operation
+
+	if err := dbq.dbConnection.Model(&dbResults).
+		WhereIn("op.state IN (?)", []string{OperationState_Waiting, OperationState_In_Progress}).
+		Order("seq_id ASC").
+		Context(ctx).
+		Select(); err != nil {
+
+		return fmt.Errorf("error on retrieving ListIncompleteOperations: %w", mapDBError(err))
+	}
+
+	*operations = dbResults
+
+	return nil
+}
+
+// DeleteExpiredOperations deletes completed operations (see 'IsOperationStateComplete') that are no longer retained:
+// an operation is retained if it is one of the 'keepLastPerResource' most recent operations of its resource, or if it
+// was created after 'createdBefore'. Returns the number of operations that were deleted.
+func (dbq *PostgreSQLDatabaseQueries) DeleteExpiredOperations(ctx context.Context, keepLastPerResource int, createdBefore time.Time) (int, error) {
+
+	if err := validateQueryParamsNoPK(dbq); err != nil {
+		return 0, err
+	}
+
+	if keepLastPerResource < 0 {
+		return 0, fmt.Errorf("number of operations to keep per resource must not be negative")
+	}
+
+	if createdBefore.IsZero() {
+		return 0, fmt.Errorf("time must not be zero")
+	}
+
+	result, err := dbq.dbConnection.ExecContext(ctx, `DELETE FROM operation WHERE operation_id IN (
+		SELECT ranked.operation_id FROM (
+			SELECT operation_id, created_on, state,
+				ROW_NUMBER() OVER (PARTITION BY resource_id, resource_type ORDER BY created_on DESC, seq_id DESC) AS resource_rank
+			FROM operation
+		) AS ranked
+		WHERE ranked.resource_rank > ? AND ranked.created_on < ? AND ranked.state IN (?)
+	)`, keepLastPerResource, createdBefore,
+		pg.In([]string{OperationState_Completed, OperationState_Failed, OperationState_Timeout, OperationState_Cancelled}))
+	if err != nil {
+		return 0, fmt.Errorf("error on deleting expired operations: %w", mapDBError(err))
+	}
+
+	return result.RowsAffected(), nil
+}
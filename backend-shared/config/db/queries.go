@@ -3,10 +3,10 @@ package db
 import (
 	"context"
 	"fmt"
-	"strings"
 	"time"
 
 	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
 
 	sharedutil "github.com/redhat-appstudio/managed-gitops/backend-shared/util"
 	"sigs.k8s.io/controller-runtime/pkg/log"
@@ -69,6 +69,12 @@ type DatabaseQueries interface {
 	CreateManagedEnvironment(ctx context.Context, obj *ManagedEnvironment) error
 	CreateKubernetesResourceToDBResourceMapping(ctx context.Context, obj *KubernetesToDBResourceMapping) error
 
+	UpdateGitopsEngineClusterHeartbeat(ctx context.Context, obj *GitopsEngineCluster) error
+	TimeoutExpiredOperations(ctx context.Context, now time.Time, operations *[]Operation) error
+	ListIncompleteOperations(ctx context.Context, operations *[]Operation) error
+	DeleteExpiredOperations(ctx context.Context, keepLastPerResource int, createdBefore time.Time) (int, error)
+	DeleteOrphanedSyncOperations(ctx context.Context, createdBefore time.Time) (int, error)
+
 	CheckedDeleteDeploymentToApplicationMappingByDeplId(ctx context.Context, id string, ownerId string) (int, error)
 
 	// TODO: GITOPS-1678 - DEBT - I think this should still have an owner, even if it presumed that it is user id:
@@ -88,6 +94,7 @@ type DatabaseQueries interface {
 	CheckedGetOperationById(ctx context.Context, operation *Operation, ownerId string) error
 	CheckedGetDeploymentToApplicationMappingByDeplId(ctx context.Context, deplToAppMappingParam *DeploymentToApplicationMapping, ownerId string) error
 	GetClusterAccessByPrimaryKey(ctx context.Context, obj *ClusterAccess) error
+	ListClusterAccessByClusterUserId(ctx context.Context, clusterUserId string, clusterAccess *[]ClusterAccess) error
 	GetDBResourceMappingForKubernetesResource(ctx context.Context, obj *KubernetesToDBResourceMapping) error
 
 	GetGitopsEngineInstanceById(ctx context.Context, engineInstanceParam *GitopsEngineInstance) error
@@ -101,6 +108,13 @@ type DatabaseQueries interface {
 
 	GetClusterCredentialsById(ctx context.Context, clusterCreds *ClusterCredentials) error
 
+	// ClusterCredentials are returned with their secret fields (kube_config, serviceaccount_bearer_token) encrypted:
+	// DecryptClusterCredentials should only be called by code that needs to connect to the cluster.
+	DecryptClusterCredentials(clusterCreds *ClusterCredentials) error
+	// ReencryptAllClusterCredentials encrypts the secret fields of all ClusterCredentials with the active encryption
+	// key, and returns the number of ClusterCredentials that were updated: see 'gitops-credentials-reencrypt'.
+	ReencryptAllClusterCredentials(ctx context.Context) (int, error)
+
 	GetDeploymentToApplicationMappingByApplicationId(ctx context.Context, deplToAppMappingParam *DeploymentToApplicationMapping) error
 
 	DeleteGitopsEngineInstanceById(ctx context.Context, id string) (int, error)
@@ -112,6 +126,23 @@ type DatabaseQueries interface {
 	CheckedListClusterCredentialsByHost(ctx context.Context, hostName string, clusterCredentials *[]ClusterCredentials, ownerId string) error
 	ListManagedEnvironmentForClusterCredentialsAndOwnerId(ctx context.Context, clusterCredentialId string, ownerId string, managedEnvironments *[]ManagedEnvironment) error
 	CheckedListGitopsEngineClusterByCredentialId(ctx context.Context, credentialId string, engineClustersParam *[]GitopsEngineCluster, ownerId string) error
+	ListGitopsEngineClusterHeartbeats(ctx context.Context, gitopsEngineClusters *[]GitopsEngineCluster) error
+	ListGitopsEngineInstances(ctx context.Context, gitopsEngineInstances *[]GitopsEngineInstance) error
+
+	// Consistency check functions return rows across all workspaces: see 'gitops-db-check'.
+	ListApplicationsWithoutDeploymentToApplicationMapping(ctx context.Context, applications *[]Application) error
+	ListApplicationsForGitopsEngineInstance(ctx context.Context, gitopsEngineInstanceId string, applications *[]Application) error
+	ListDeploymentToApplicationMappings(ctx context.Context, deplToAppMappings *[]DeploymentToApplicationMapping) error
+	ListAPICRToDatabaseMappings(ctx context.Context, apiCRToDBMappings *[]APICRToDatabaseMapping) error
+	ListAPICRToDatabaseMappingsWithMissingDBRelation(ctx context.Context, apiCRToDBMappings *[]APICRToDatabaseMapping) error
+	ListKubernetesToDBResourceMappingsWithMissingDBRelation(ctx context.Context, kubernetesToDBMappings *[]KubernetesToDBResourceMapping) error
+
+	// Workspace teardown functions return rows across all workspaces, or owned by a workspace's ClusterUser: see 'WorkspaceTeardownRunner'.
+	ListKubernetesToDBResourceMappingsByType(ctx context.Context, kubernetesResourceType string, dbRelationType string, kubernetesToDBMappings *[]KubernetesToDBResourceMapping) error
+	ListOperationsByOwnerId(ctx context.Context, ownerId string, operations *[]Operation) error
+	ListApplicationsByManagedEnvironmentId(ctx context.Context, managedEnvironmentId string, applications *[]Application) error
+	ListAPICRToDatabaseMappingsByWorkspaceUID(ctx context.Context, workspaceUID string, apiCRToDBMappings *[]APICRToDatabaseMapping) error
+	DeleteManagedEnvironmentWithoutClusterAccessById(ctx context.Context, id string) (int, error)
 }
 
 // ApplicationScopedQueries are the set of database queries that act on application DB resources:
@@ -139,7 +170,16 @@ type DatabaseQueries interface {
 type ApplicationScopedQueries interface {
 	CloseableQueries
 
+	// RunInTransaction runs 'fn' within a database transaction: the queries made via the 'tx' parameter are committed
+	// if 'fn' returns nil, and are rolled back if it returns an error (or panics). If called within a transaction,
+	// 'fn' is run as part of the existing transaction.
+	//
+	// Note: Rows written within the transaction are not visible to other processes (for example, the cluster-agent)
+	// until it is committed, so Operations should be created after the transaction.
+	RunInTransaction(ctx context.Context, fn func(tx ApplicationScopedQueries) error) error
+
 	UpdateOperation(ctx context.Context, obj *Operation) error
+	RequestOperationCancellation(ctx context.Context, obj *Operation) error
 
 	CreateOperation(ctx context.Context, obj *Operation, ownerId string) error
 	GetOperationById(ctx context.Context, operation *Operation) error
@@ -149,11 +189,17 @@ type ApplicationScopedQueries interface {
 
 	CreateSyncOperation(ctx context.Context, obj *SyncOperation) error
 	GetSyncOperationById(ctx context.Context, syncOperation *SyncOperation) error
+	UpdateSyncOperation(ctx context.Context, obj *SyncOperation) error
 	DeleteSyncOperationById(ctx context.Context, id string) (int, error)
 
 	CreateApplication(ctx context.Context, obj *Application) error
 	CheckedCreateApplication(ctx context.Context, obj *Application, ownerId string) error
 	GetApplicationById(ctx context.Context, application *Application) error
+	// LockApplicationById retrieves the Application, and locks its row until the end of the transaction: it must be
+	// called within RunInTransaction. Rows that reference the Application cannot be created until the lock is released.
+	LockApplicationById(ctx context.Context, application *Application) error
+	// UpdateApplication and UpdateApplicationState only update the row if it has not been updated since 'obj' was read,
+	// based on its Version: otherwise a version conflict error is returned (see 'IsVersionConflictError', and 'util.RetryOnConflict').
 	UpdateApplication(ctx context.Context, obj *Application) error
 	DeleteApplicationById(ctx context.Context, id string) (int, error)
 	CheckedDeleteApplicationById(ctx context.Context, id string, ownerId string) (int, error)
@@ -186,7 +232,8 @@ var _ UnsafeDatabaseQueries = &PostgreSQLDatabaseQueries{}
 var _ DatabaseQueries = &PostgreSQLDatabaseQueries{}
 
 type PostgreSQLDatabaseQueries struct {
-	dbConnection *pg.DB
+	// dbConnection is the database (*pg.DB), or the transaction (*pg.Tx) if the queries were created by RunInTransaction
+	dbConnection orm.DB
 
 	// allowTestUuids, if true, will allow callers to pass an id value into the db create methods.
 	// This is useful for test cases, and this setting must only be enabled for unit tests.
@@ -198,6 +245,10 @@ type PostgreSQLDatabaseQueries struct {
 	//
 	// This should be false in all cases, with the only exception being test code.
 	allowUnsafe bool
+
+	// clusterCredentialsKeyring is used to encrypt the secret fields of ClusterCredentials: if nil, they are stored
+	// unencrypted. See 'clustercredentials_encryption.go'.
+	clusterCredentialsKeyring *ClusterCredentialsKeyring
 }
 
 func NewProductionPostgresDBQueries(verbose bool) (DatabaseQueries, error) {
@@ -232,10 +283,21 @@ func NewProductionPostgresDBQueriesWithPort(verbose bool, port int) (DatabaseQue
 		return nil, fmt.Errorf("unable to acquire database: %v", taskError)
 	}
 
+	keyring, err := loadClusterCredentialsKeyringFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	if keyring == nil {
+		log.FromContext(context.Background()).Info("WARNING: " + EnvClusterCredentialsEncryptionKeysDir +
+			" is not set, or " + EnvClusterCredentialsEncryptionDisabled + " is 'true': cluster credentials will be stored unencrypted")
+	}
+
 	dbq := &PostgreSQLDatabaseQueries{
-		dbConnection:   db,
-		allowTestUuids: false,
-		allowUnsafe:    false,
+		dbConnection:              db,
+		allowTestUuids:            false,
+		allowUnsafe:               false,
+		clusterCredentialsKeyring: keyring,
 	}
 
 	return dbq, nil
@@ -256,10 +318,16 @@ func NewUnsafePostgresDBQueriesWithPort(verbose bool, allowTestUuids bool, port
 		return nil, err
 	}
 
+	keyring, err := loadClusterCredentialsKeyringFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
 	dbq := &PostgreSQLDatabaseQueries{
-		dbConnection:   db,
-		allowTestUuids: allowTestUuids,
-		allowUnsafe:    true,
+		dbConnection:              db,
+		allowTestUuids:            allowTestUuids,
+		allowUnsafe:               true,
+		clusterCredentialsKeyring: keyring,
 	}
 
 	fmt.Printf("* WARNING: Unsafe PostgreSQLDB object was created. You should never see this outside of test suites, or personal development.\n")
@@ -271,32 +339,45 @@ func (dbq *PostgreSQLDatabaseQueries) CloseDatabase() {
 
 	log := log.FromContext(context.Background())
 
-	if dbq.dbConnection != nil {
+	// Only the database may be closed: a transaction is closed when RunInTransaction returns.
+	if database, ok := dbq.dbConnection.(*pg.DB); ok && database != nil {
 		// Close closes the database client, releasing any open resources.
 		//
 		// It is rare to Close a DB, as the DB handle is meant to be
 		// long-lived and shared between many goroutines.
-		err := dbq.dbConnection.Close()
+		err := database.Close()
 		if err != nil {
 			log.Error(err, "Error occurred on CloseDatabase()")
 		}
 	}
 }
 
-// NewResultNotFoundError returns an error that will be matched by IsAccessDeniedError
-func NewAccessDeniedError(errString string) error {
-	return fmt.Errorf("%s: results found, but access denied", errString)
-}
+func (dbq *PostgreSQLDatabaseQueries) RunInTransaction(ctx context.Context, fn func(tx ApplicationScopedQueries) error) error {
 
-func IsAccessDeniedError(errorParam error) bool {
-	return strings.Contains(errorParam.Error(), "results found, but access denied")
-}
+	if dbq.dbConnection == nil {
+		return fmt.Errorf("database connection is nil")
+	}
 
-// NewResultNotFoundError returns an error that will be matched by IsResultNotFoundError
-func NewResultNotFoundError(errString string) error {
-	return fmt.Errorf("%s: no rows in result set", errString)
-}
+	// Already within a transaction
+	if _, ok := dbq.dbConnection.(*pg.Tx); ok {
+		return fn(dbq)
+	}
+
+	database, ok := dbq.dbConnection.(*pg.DB)
+	if !ok {
+		return fmt.Errorf("SEVERE: unexpected database connection type: %T", dbq.dbConnection)
+	}
+
+	// The error is mapped, as the commit may fail (for example, on a serialization failure)
+	return mapDBError(database.RunInTransaction(ctx, func(pgTx *pg.Tx) error {
+
+		txQueries := &PostgreSQLDatabaseQueries{
+			dbConnection:              pgTx,
+			allowTestUuids:            dbq.allowTestUuids,
+			allowUnsafe:               dbq.allowUnsafe,
+			clusterCredentialsKeyring: dbq.clusterCredentialsKeyring,
+		}
 
-func IsResultNotFoundError(errorParam error) bool {
-	return strings.Contains(errorParam.Error(), "no rows in result set")
+		return fn(txQueries)
+	}))
 }
@@ -3,6 +3,9 @@ package db
 import (
 	"context"
 	"fmt"
+	"time"
+
+	"github.com/go-pg/pg/v10"
 )
 
 const (
@@ -10,6 +13,22 @@ const (
 	SyncOperation_DesiredState_Terminated = "Terminated"
 )
 
+// The phases of a sync, as stored in SyncResult_phase by the cluster-agent: these match the operation phases of Argo CD.
+const (
+	SyncOperation_SyncResultPhase_Running     = "Running"
+	SyncOperation_SyncResultPhase_Terminating = "Terminating"
+	SyncOperation_SyncResultPhase_Failed      = "Failed"
+	SyncOperation_SyncResultPhase_Error       = "Error"
+	SyncOperation_SyncResultPhase_Succeeded   = "Succeeded"
+)
+
+// IsSyncResultPhaseComplete returns true if the sync result phase is a terminal phase: an empty phase means that no sync
+// result has yet been stored.
+func IsSyncResultPhaseComplete(phase string) bool {
+	return phase == SyncOperation_SyncResultPhase_Failed || phase == SyncOperation_SyncResultPhase_Error ||
+		phase == SyncOperation_SyncResultPhase_Succeeded
+}
+
 func (dbq *PostgreSQLDatabaseQueries) GetSyncOperationById(ctx context.Context, syncOperation *SyncOperation) error {
 
 	if err := validateQueryParamsEntity(syncOperation, dbq); err != nil {
@@ -27,7 +46,7 @@ func (dbq *PostgreSQLDatabaseQueries) GetSyncOperationById(ctx context.Context,
 		Context(ctx).
 		Select(); err != nil {
 
-		return fmt.Errorf("error on retrieving GetSyncOperationById: %v", err)
+		return fmt.Errorf("error on retrieving GetSyncOperationById: %w", mapDBError(err))
 	}
 
 	if len(dbResults) >= 2 {
@@ -69,9 +88,11 @@ func (dbq *PostgreSQLDatabaseQueries) CreateSyncOperation(ctx context.Context, o
 		return err
 	}
 
+	obj.Created_on = time.Now()
+
 	result, err := dbq.dbConnection.Model(obj).Context(ctx).Insert()
 	if err != nil {
-		return fmt.Errorf("error on inserting application: %v", err)
+		return fmt.Errorf("error on inserting application: %w", mapDBError(err))
 	}
 
 	if result.RowsAffected() != 1 {
@@ -82,6 +103,33 @@ func (dbq *PostgreSQLDatabaseQueries) CreateSyncOperation(ctx context.Context, o
 
 }
 
+func (dbq *PostgreSQLDatabaseQueries) UpdateSyncOperation(ctx context.Context, obj *SyncOperation) error {
+
+	if err := validateQueryParamsEntity(obj, dbq); err != nil {
+		return err
+	}
+
+	if err := isEmptyValues("UpdateSyncOperation",
+		"SyncOperation_id", obj.SyncOperation_id,
+		"DeploymentNameField", obj.DeploymentNameField,
+		"Revision", obj.Revision,
+		"DesiredState", obj.DesiredState); err != nil {
+		return err
+	}
+
+	// created_on is only set on creation
+	result, err := dbq.dbConnection.Model(obj).WherePK().ExcludeColumn("created_on").Context(ctx).Update()
+	if err != nil {
+		return fmt.Errorf("error on updating syncoperation: %w, %v", mapDBError(err), obj.SyncOperation_id)
+	}
+
+	if result.RowsAffected() != 1 {
+		return fmt.Errorf("unexpected number of rows affected: %d, %v", result.RowsAffected(), obj.SyncOperation_id)
+	}
+
+	return nil
+}
+
 func (dbq *PostgreSQLDatabaseQueries) DeleteSyncOperationById(ctx context.Context, id string) (int, error) {
 
 	if err := validateQueryParams(id, dbq); err != nil {
@@ -100,12 +148,41 @@ func (dbq *PostgreSQLDatabaseQueries) DeleteSyncOperationById(ctx context.Contex
 		Delete()
 
 	if err != nil {
-		return 0, fmt.Errorf("error on deleting syncoperation: %v", err)
+		return 0, fmt.Errorf("error on deleting syncoperation: %w", mapDBError(err))
 	}
 
 	return deleteResult.RowsAffected(), nil
 }
 
+// DeleteOrphanedSyncOperations deletes SyncOperations that are no longer referenced by a GitOpsDeploymentSyncRun (via
+// an APICRToDatabaseMapping), and which have no operations in progress. Only SyncOperations created before 'createdBefore'
+// are deleted: a new SyncOperation is created before its mapping. Returns the number of SyncOperations that were deleted.
+func (dbq *PostgreSQLDatabaseQueries) DeleteOrphanedSyncOperations(ctx context.Context, createdBefore time.Time) (int, error) {
+
+	if err := validateQueryParamsNoPK(dbq); err != nil {
+		return 0, err
+	}
+
+	if createdBefore.IsZero() {
+		return 0, fmt.Errorf("time must not be zero")
+	}
+
+	result, err := dbq.dbConnection.ExecContext(ctx, `DELETE FROM syncoperation AS so
+		WHERE (so.created_on IS NULL OR so.created_on < ?)
+		AND NOT EXISTS (
+			SELECT 1 FROM apicrtodatabasemapping AS m WHERE m.db_relation_type = ? AND m.db_relation_key = so.syncoperation_id
+		)
+		AND NOT EXISTS (
+			SELECT 1 FROM operation AS op WHERE op.resource_type = ? AND op.resource_id = so.syncoperation_id AND op.state IN (?)
+		)`, createdBefore, APICRToDatabaseMapping_DBRelationType_SyncOperation, OperationResourceType_SyncOperation,
+		pg.In([]string{OperationState_Waiting, OperationState_In_Progress}))
+	if err != nil {
+		return 0, fmt.Errorf("error on deleting orphaned sync operations: %w", mapDBError(err))
+	}
+
+	return result.RowsAffected(), nil
+}
+
 func (dbq *PostgreSQLDatabaseQueries) UpdateSyncOperationRemoveApplicationField(ctx context.Context, applicationId string) (int, error) {
 
 	if err := validateQueryParamsNoPK(dbq); err != nil {
@@ -124,7 +201,7 @@ func (dbq *PostgreSQLDatabaseQueries) UpdateSyncOperationRemoveApplicationField(
 	res, err := dbq.dbConnection.Model(&operation).Set("application_id = ?", nil).Where("application_id = ?", applicationId).Update()
 
 	if err != nil {
-		return 0, err
+		return 0, mapDBError(err)
 	}
 
 	return res.RowsAffected(), err
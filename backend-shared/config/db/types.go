@@ -23,6 +23,13 @@ type GitopsEngineCluster struct {
 	// -- pointer to credentials for the cluster
 	// -- Foreign key to: ClusterCredentials.clustercredentials_cred_id
 	Clustercredentials_id string `pg:"clustercredentials_id"`
+
+	// -- The heartbeat fields are periodically updated by the cluster-agent running on the cluster; they are
+	// -- empty if the cluster-agent has never sent a heartbeat. See 'UpdateGitopsEngineClusterHeartbeat'.
+	Heartbeat_last_seen      time.Time `pg:"heartbeat_last_seen"`
+	Heartbeat_agent_version  string    `pg:"heartbeat_agent_version"`
+	Heartbeat_argocd_version string    `pg:"heartbeat_argocd_version"`
+	Heartbeat_capacity       int       `pg:"heartbeat_capacity"`
 }
 
 // GitopsEngineInstance is an Argo CD instance on a Argo CD cluster
@@ -142,13 +149,44 @@ const (
 	OperationState_In_Progress = "In_Progress"
 	OperationState_Completed   = "Completed"
 	OperationState_Failed      = "Failed"
+	OperationState_Timeout     = "Timeout"
+	OperationState_Cancelled   = "Cancelled"
 )
 
+// IsOperationStateComplete returns true if an operation in the given state will not be processed further: either it
+// has completed (successfully or not), it has timed out, or it has been cancelled.
+func IsOperationStateComplete(state string) bool {
+	return state == OperationState_Completed || state == OperationState_Failed ||
+		state == OperationState_Timeout || state == OperationState_Cancelled
+}
+
 const (
 	OperationResourceType_SyncOperation = "SyncOperation"
 	OperationResourceType_Application   = "Application"
 )
 
+const (
+	// DefaultOperationTimeout is the time an operation has to complete, if its resource type does not have a timeout
+	// in OperationTimeoutByResourceType.
+	DefaultOperationTimeout = 10 * time.Minute
+)
+
+// OperationTimeoutByResourceType is the time an operation of a given resource type has to complete, before it is moved
+// to the Timeout state.
+var OperationTimeoutByResourceType = map[string]time.Duration{
+	OperationResourceType_Application: 10 * time.Minute,
+	// Sync operations wait on the Argo CD sync to complete, which may take a while for large applications
+	OperationResourceType_SyncOperation: 30 * time.Minute,
+}
+
+// GetOperationTimeout returns the time an operation of the given resource type has to complete.
+func GetOperationTimeout(resourceType string) time.Duration {
+	if timeout, exists := OperationTimeoutByResourceType[resourceType]; exists {
+		return timeout
+	}
+	return DefaultOperationTimeout
+}
+
 type Operation struct {
 
 	//lint:ignore U1000 used by go-pg
@@ -188,11 +226,20 @@ type Operation struct {
 	// -- * In_Progress
 	// -- * Completed
 	// -- * Failed
+	// -- * Timeout
+	// -- * Cancelled
 	State string `pg:"state"`
 
 	// -- If there is an error message from the operation, it is passed via this field.
 	Human_readable_state string `pg:"human_readable_state"`
 
+	// -- The time by which the operation must complete, otherwise it will be moved to the Timeout state
+	// -- (set by CreateOperation, based on the resource type)
+	Deadline time.Time `pg:"deadline"`
+
+	// -- Whether the cancellation of the operation has been requested
+	Cancel_requested bool `pg:"cancel_requested"`
+
 	SeqID int64 `pg:"seq_id"`
 }
 
@@ -219,6 +266,12 @@ type Application struct {
 	// -- Which managed environment it is targetting
 	// -- Foreign key to ManagedEnvironment.Managedenvironment_id
 	Managed_environment_id string `pg:"managed_environment_id"`
+
+	// -- Incremented on every update: see 'UpdateApplication'
+	Version int `pg:"version"`
+
+	// -- When the Application was created: used by the consistency checker to skip Applications that were created recently.
+	Created_on time.Time `pg:"created_on"`
 }
 
 type ApplicationState struct {
@@ -248,6 +301,16 @@ type ApplicationState struct {
 
 	Revision string `pg:"revision"`
 
+	// -- JSON list of the Argo CD Application's .status.conditions, see 'ApplicationStateCondition'
+	Conditions string `pg:"conditions"`
+
+	// -- Phase and message of the Argo CD Application's .status.operationState
+	Operation_phase   string `pg:"operation_phase"`
+	Operation_message string `pg:"operation_message"`
+
+	// -- Incremented on every update: see 'UpdateApplicationState'
+	Version int `pg:"version"`
+
 	// -- human_readable_health ( 512 ) NOT NULL,
 	// -- human_readable_sync ( 512 ) NOT NULL,
 	// -- human_readable_state ( 512 ) NOT NULL,
@@ -350,6 +413,16 @@ type SyncOperation struct {
 	Revision string `pg:"revision"`
 
 	DesiredState string `pg:"desired_state"`
+
+	// -- When the SyncOperation was created: used for garbage collection of SyncOperations that are no longer referenced.
+	Created_on time.Time `pg:"created_on"`
+
+	// -- The result of the most recent sync, as reported by the cluster-agent. These fields are empty until a sync has completed.
+	SyncResult_phase            string `pg:"sync_result_phase"`
+	SyncResult_message          string `pg:"sync_result_message"`
+	SyncResult_pruning_required int    `pg:"sync_result_pruning_required"`
+	// -- JSON list containing the sync result of each individual resource
+	SyncResult_resources string `pg:"sync_result_resources"`
 }
 
 // TODO: GITOPS-1678 - DEBT - Add comment.
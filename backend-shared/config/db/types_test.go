@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -255,6 +256,86 @@ func TestCreateApplication(t *testing.T) {
 
 }
 
+func TestSyncOperation(t *testing.T) {
+	testSetup(t)
+	defer testTeardown(t)
+
+	dbq, err := NewUnsafePostgresDBQueries(true, true)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer dbq.CloseDatabase()
+
+	ctx := context.Background()
+	_, managedEnvironment, _, gitopsEngineInstance, clusterAccess, err := createSampleData(t, dbq)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	application := &Application{
+		Application_id:          "test-my-application-sync",
+		Name:                    "my-application",
+		Spec_field:              "{}",
+		Engine_instance_inst_id: gitopsEngineInstance.Gitopsengineinstance_id,
+		Managed_environment_id:  managedEnvironment.Managedenvironment_id,
+	}
+	err = dbq.CheckedCreateApplication(ctx, application, clusterAccess.Clusteraccess_user_id)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	syncOperation := &SyncOperation{
+		SyncOperation_id:    "test-sync-operation",
+		Application_id:      application.Application_id,
+		Operation_id:        "test-operation",
+		DeploymentNameField: "my-deployment",
+		Revision:            "master",
+		DesiredState:        SyncOperation_DesiredState_Running,
+	}
+	err = dbq.CreateSyncOperation(ctx, syncOperation)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	syncOperation.SyncResult_phase = "Failed"
+	syncOperation.SyncResult_message = "one or more objects failed to apply"
+	syncOperation.SyncResult_pruning_required = 2
+	syncOperation.SyncResult_resources = "[]"
+	err = dbq.UpdateSyncOperation(ctx, syncOperation)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	retrievedSyncOperation := &SyncOperation{SyncOperation_id: syncOperation.SyncOperation_id}
+	err = dbq.GetSyncOperationById(ctx, retrievedSyncOperation)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, syncOperation.SyncResult_phase, retrievedSyncOperation.SyncResult_phase)
+	assert.Equal(t, syncOperation.SyncResult_message, retrievedSyncOperation.SyncResult_message)
+	assert.Equal(t, syncOperation.SyncResult_pruning_required, retrievedSyncOperation.SyncResult_pruning_required)
+	assert.Equal(t, syncOperation.SyncResult_resources, retrievedSyncOperation.SyncResult_resources)
+
+	assert.False(t, retrievedSyncOperation.Created_on.IsZero())
+
+	// The sync operation is not referenced by a GitOpsDeploymentSyncRun, but it is not deleted until it is old enough
+	rowsAffected, err := dbq.DeleteOrphanedSyncOperations(ctx, retrievedSyncOperation.Created_on.Add(-time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, rowsAffected)
+
+	rowsAffected, err = dbq.DeleteOrphanedSyncOperations(ctx, retrievedSyncOperation.Created_on.Add(time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, rowsAffected)
+
+	rowsAffected, err = dbq.DeleteSyncOperationById(ctx, syncOperation.SyncOperation_id)
+	assert.NoError(t, err)
+	assert.Equal(t, rowsAffected, 0)
+
+	rowsAffected, err = dbq.CheckedDeleteApplicationById(ctx, application.Application_id, clusterAccess.Clusteraccess_user_id)
+	assert.NoError(t, err)
+	assert.Equal(t, rowsAffected, 1)
+}
+
 func TestDeploymentToApplicationMapping(t *testing.T) {
 
 	// TODO: GITOPS-1678 - DEBT - Finish filling this in
@@ -300,6 +381,35 @@ func TestGitopsEngineInstanceAndCluster(t *testing.T) {
 		return
 	}
 
+	// Update the heartbeat, and verify it is returned
+	heartbeat := &GitopsEngineCluster{
+		Gitopsenginecluster_id:   gitopsEngineCluster.Gitopsenginecluster_id,
+		Heartbeat_last_seen:      time.Now().Truncate(time.Millisecond),
+		Heartbeat_agent_version:  "v0.0.1",
+		Heartbeat_argocd_version: "v2.2.5",
+		Heartbeat_capacity:       20,
+	}
+	if err = dbq.UpdateGitopsEngineClusterHeartbeat(ctx, heartbeat); !assert.NoError(t, err) {
+		return
+	}
+
+	var heartbeats []GitopsEngineCluster
+	if err = dbq.ListGitopsEngineClusterHeartbeats(ctx, &heartbeats); !assert.NoError(t, err) {
+		return
+	}
+	found := false
+	for _, retrievedHeartbeat := range heartbeats {
+		if retrievedHeartbeat.Gitopsenginecluster_id == heartbeat.Gitopsenginecluster_id {
+			found = true
+			assert.True(t, heartbeat.Heartbeat_last_seen.Equal(retrievedHeartbeat.Heartbeat_last_seen))
+			assert.Equal(t, heartbeat.Heartbeat_agent_version, retrievedHeartbeat.Heartbeat_agent_version)
+			assert.Equal(t, heartbeat.Heartbeat_argocd_version, retrievedHeartbeat.Heartbeat_argocd_version)
+			assert.Equal(t, heartbeat.Heartbeat_capacity, retrievedHeartbeat.Heartbeat_capacity)
+			assert.Empty(t, retrievedHeartbeat.Clustercredentials_id, "credentials should not be returned")
+		}
+	}
+	assert.True(t, found)
+
 	rowsAffected, err := dbq.DeleteClusterAccessById(ctx, clusterAccess.Clusteraccess_user_id, clusterAccess.Clusteraccess_managed_environment_id, clusterAccess.Clusteraccess_gitops_engine_instance_id)
 	assert.NoError(t, err)
 	assert.Equal(t, rowsAffected, 1)
@@ -407,6 +517,42 @@ func TestOperation(t *testing.T) {
 		return
 	}
 	assert.True(t, IsResultNotFoundError(err))
+	// The deadline is based on the resource type of the operation
+	result = Operation{Operation_id: operation.Operation_id}
+	err = dbq.GetOperationById(ctx, &result)
+	assert.NoError(t, err)
+	assert.WithinDuration(t, result.Created_on.Add(GetOperationTimeout(operation.Resource_type)), result.Deadline, time.Second)
+	assert.False(t, result.Cancel_requested)
+
+	// A cancellation request should not be overwritten by an update of the operation
+	err = dbq.RequestOperationCancellation(ctx, &Operation{Operation_id: operation.Operation_id})
+	assert.NoError(t, err)
+	result.State = OperationState_In_Progress
+	err = dbq.UpdateOperation(ctx, &result)
+	assert.NoError(t, err)
+	result = Operation{Operation_id: operation.Operation_id}
+	err = dbq.GetOperationById(ctx, &result)
+	assert.NoError(t, err)
+	assert.True(t, result.Cancel_requested)
+	assert.Equal(t, OperationState_In_Progress, result.State)
+
+	// Operations should only be timed out once their deadline has passed
+	var timedOutOperations []Operation
+	err = dbq.TimeoutExpiredOperations(ctx, result.Deadline.Add(-time.Minute), &timedOutOperations)
+	assert.NoError(t, err)
+	assert.Len(t, timedOutOperations, 0)
+	err = dbq.TimeoutExpiredOperations(ctx, result.Deadline.Add(time.Minute), &timedOutOperations)
+	assert.NoError(t, err)
+	if assert.Len(t, timedOutOperations, 1) {
+		assert.Equal(t, operation.Operation_id, timedOutOperations[0].Operation_id)
+		assert.Equal(t, OperationState_Timeout, timedOutOperations[0].State)
+	}
+	result = Operation{Operation_id: operation.Operation_id}
+	err = dbq.GetOperationById(ctx, &result)
+	assert.NoError(t, err)
+	assert.Equal(t, OperationState_Timeout, result.State)
+	assert.True(t, IsOperationStateComplete(result.State))
+
 	rowsAffected, _ := dbq.CheckedDeleteOperationById(ctx, operation.Operation_id, "another-user")
 	assert.Equal(t, rowsAffected, 0)
 
@@ -415,6 +561,308 @@ func TestOperation(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestDeleteExpiredOperations(t *testing.T) {
+	testSetup(t)
+	defer testTeardown(t)
+
+	dbq, err := NewUnsafePostgresDBQueries(true, true)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer dbq.CloseDatabase()
+	ctx := context.Background()
+	_, _, _, gitopsEngineInstance, _, err := createSampleData(t, dbq)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// Create 3 operations for the same resource, with the oldest still in progress
+	var operations []*Operation
+	for idx := 0; idx < 3; idx++ {
+		operation := &Operation{
+			Operation_id:            fmt.Sprintf("test-operation-%d", idx),
+			Instance_id:             gitopsEngineInstance.Gitopsengineinstance_id,
+			Resource_id:             "test-resource",
+			Resource_type:           OperationResourceType_Application,
+			State:                   OperationState_Waiting,
+			Operation_owner_user_id: testClusterUser.Clusteruser_id,
+		}
+		if err := dbq.CreateOperation(ctx, operation, operation.Operation_owner_user_id); !assert.NoError(t, err) {
+			return
+		}
+
+		if idx > 0 {
+			operation.State = OperationState_Completed
+			if err := dbq.UpdateOperation(ctx, operation); !assert.NoError(t, err) {
+				return
+			}
+		}
+		operations = append(operations, operation)
+	}
+
+	// Operations newer than the given time are retained
+	rowsAffected, err := dbq.DeleteExpiredOperations(ctx, 0, operations[0].Created_on.Add(-time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, rowsAffected)
+
+	// The most recent operation is retained, as is the operation that is still in progress
+	rowsAffected, err = dbq.DeleteExpiredOperations(ctx, 1, time.Now().Add(time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, rowsAffected)
+
+	err = dbq.GetOperationById(ctx, &Operation{Operation_id: operations[1].Operation_id})
+	assert.True(t, IsResultNotFoundError(err))
+
+	for _, operation := range []*Operation{operations[0], operations[2]} {
+		err = dbq.GetOperationById(ctx, &Operation{Operation_id: operation.Operation_id})
+		assert.NoError(t, err)
+
+		_, err = dbq.DeleteOperationById(ctx, operation.Operation_id)
+		assert.NoError(t, err)
+	}
+}
+
+func TestRunInTransaction(t *testing.T) {
+	testSetup(t)
+	defer testTeardown(t)
+
+	dbq, err := NewUnsafePostgresDBQueries(true, true)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer dbq.CloseDatabase()
+	ctx := context.Background()
+	_, managedEnvironment, _, gitopsEngineInstance, _, err := createSampleData(t, dbq)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	newApplication := func(id string) *Application {
+		return &Application{
+			Application_id:          id,
+			Name:                    id,
+			Spec_field:              "{}",
+			Engine_instance_inst_id: gitopsEngineInstance.Gitopsengineinstance_id,
+			Managed_environment_id:  managedEnvironment.Managedenvironment_id,
+		}
+	}
+
+	// An error rolls back all the rows written in the transaction
+	rolledBackApplication := newApplication("test-rolled-back-application")
+	err = dbq.RunInTransaction(ctx, func(tx ApplicationScopedQueries) error {
+		if err := tx.CreateApplication(ctx, rolledBackApplication); err != nil {
+			return err
+		}
+
+		// The row is visible within the transaction
+		if err := tx.GetApplicationById(ctx, &Application{Application_id: rolledBackApplication.Application_id}); err != nil {
+			return err
+		}
+
+		return fmt.Errorf("expected error")
+	})
+	assert.EqualError(t, err, "expected error")
+
+	err = dbq.GetApplicationById(ctx, &Application{Application_id: rolledBackApplication.Application_id})
+	assert.True(t, IsResultNotFoundError(err))
+
+	// Success commits all the rows written in the transaction, including those of a nested transaction
+	committedApplication := newApplication("test-committed-application")
+	err = dbq.RunInTransaction(ctx, func(tx ApplicationScopedQueries) error {
+		if err := tx.CreateApplication(ctx, committedApplication); err != nil {
+			return err
+		}
+
+		return tx.RunInTransaction(ctx, func(nestedTx ApplicationScopedQueries) error {
+			return nestedTx.CreateApplicationState(ctx, &ApplicationState{
+				Applicationstate_application_id: committedApplication.Application_id,
+				Health:                          "Healthy",
+				Sync_Status:                     "Synced",
+			})
+		})
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = dbq.GetApplicationStateById(ctx, &ApplicationState{Applicationstate_application_id: committedApplication.Application_id})
+	assert.NoError(t, err)
+
+	_, err = dbq.DeleteApplicationStateById(ctx, committedApplication.Application_id)
+	assert.NoError(t, err)
+	_, err = dbq.DeleteApplicationById(ctx, committedApplication.Application_id)
+	assert.NoError(t, err)
+}
+
+func TestOptimisticConcurrency(t *testing.T) {
+	testSetup(t)
+	defer testTeardown(t)
+
+	dbq, err := NewUnsafePostgresDBQueries(true, true)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer dbq.CloseDatabase()
+	ctx := context.Background()
+	_, managedEnvironment, _, gitopsEngineInstance, _, err := createSampleData(t, dbq)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	application := &Application{
+		Application_id:          "test-concurrent-application",
+		Name:                    "test-concurrent-application",
+		Spec_field:              "{}",
+		Engine_instance_inst_id: gitopsEngineInstance.Gitopsengineinstance_id,
+		Managed_environment_id:  managedEnvironment.Managedenvironment_id,
+	}
+	err = dbq.CreateApplication(ctx, application)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, 1, application.Version)
+
+	// Two writers read the same version of the Application
+	firstRead := &Application{Application_id: application.Application_id}
+	secondRead := &Application{Application_id: application.Application_id}
+	assert.NoError(t, dbq.GetApplicationById(ctx, firstRead))
+	assert.NoError(t, dbq.GetApplicationById(ctx, secondRead))
+
+	// The first update succeeds, and increments the version
+	firstRead.Spec_field = "{ \"first\": true }"
+	assert.NoError(t, dbq.UpdateApplication(ctx, firstRead))
+	assert.Equal(t, 2, firstRead.Version)
+
+	// The second update is based on a stale version, and so returns a conflict error
+	secondRead.Spec_field = "{ \"second\": true }"
+	err = dbq.UpdateApplication(ctx, secondRead)
+	assert.True(t, IsVersionConflictError(err))
+	assert.Equal(t, 1, secondRead.Version)
+
+	result := &Application{Application_id: application.Application_id}
+	assert.NoError(t, dbq.GetApplicationById(ctx, result))
+	assert.Equal(t, firstRead.Spec_field, result.Spec_field)
+
+	// The same applies to ApplicationState
+	applicationState := &ApplicationState{
+		Applicationstate_application_id: application.Application_id,
+		Health:                          "Healthy",
+		Sync_Status:                     "Synced",
+	}
+	assert.NoError(t, dbq.CreateApplicationState(ctx, applicationState))
+
+	staleApplicationState := *applicationState
+
+	applicationState.Health = "Degraded"
+	assert.NoError(t, dbq.UpdateApplicationState(ctx, applicationState))
+
+	staleApplicationState.Health = "Progressing"
+	err = dbq.UpdateApplicationState(ctx, &staleApplicationState)
+	assert.True(t, IsVersionConflictError(err))
+
+	_, err = dbq.DeleteApplicationStateById(ctx, application.Application_id)
+	assert.NoError(t, err)
+	_, err = dbq.DeleteApplicationById(ctx, application.Application_id)
+	assert.NoError(t, err)
+}
+
+func TestConsistencyQueries(t *testing.T) {
+	testSetup(t)
+	defer testTeardown(t)
+
+	dbq, err := NewUnsafePostgresDBQueries(true, true)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer dbq.CloseDatabase()
+	ctx := context.Background()
+	_, managedEnvironment, _, gitopsEngineInstance, _, err := createSampleData(t, dbq)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// An Application without a DeploymentToApplicationMapping
+	application := &Application{
+		Application_id:          "test-orphaned-application",
+		Name:                    "orphaned-application",
+		Spec_field:              "{}",
+		Engine_instance_inst_id: gitopsEngineInstance.Gitopsengineinstance_id,
+		Managed_environment_id:  managedEnvironment.Managedenvironment_id,
+	}
+	if err := dbq.CreateApplication(ctx, application); !assert.NoError(t, err) {
+		return
+	}
+	defer func() {
+		_, err := dbq.DeleteApplicationById(ctx, application.Application_id)
+		assert.NoError(t, err)
+	}()
+
+	var applications []Application
+	if err := dbq.ListApplicationsWithoutDeploymentToApplicationMapping(ctx, &applications); assert.NoError(t, err) {
+		assert.Contains(t, applications, *application)
+	}
+
+	if err := dbq.ListApplicationsForGitopsEngineInstance(ctx, gitopsEngineInstance.Gitopsengineinstance_id, &applications); assert.NoError(t, err) {
+		assert.Contains(t, applications, *application)
+	}
+
+	// An APICRToDatabaseMapping whose SyncOperation does not exist
+	apiCRToDBMapping := &APICRToDatabaseMapping{
+		APIResourceType:      APICRToDatabaseMapping_ResourceType_GitOpsDeploymentSyncRun,
+		APIResourceUID:       "test-syncrun-uid",
+		APIResourceName:      "test-syncrun",
+		APIResourceNamespace: "test-namespace",
+		WorkspaceUID:         "test-workspace-uid",
+		DBRelationType:       APICRToDatabaseMapping_DBRelationType_SyncOperation,
+		DBRelationKey:        "test-missing-syncoperation",
+	}
+	if err := dbq.CreateAPICRToDatabaseMapping(ctx, apiCRToDBMapping); !assert.NoError(t, err) {
+		return
+	}
+	defer func() {
+		_, err := dbq.DeleteAPICRToDatabaseMapping(ctx, apiCRToDBMapping)
+		assert.NoError(t, err)
+	}()
+
+	var apiCRToDBMappings []APICRToDatabaseMapping
+	if err := dbq.ListAPICRToDatabaseMappingsWithMissingDBRelation(ctx, &apiCRToDBMappings); assert.NoError(t, err) {
+		assert.Contains(t, apiCRToDBMappings, *apiCRToDBMapping)
+	}
+	if err := dbq.ListAPICRToDatabaseMappings(ctx, &apiCRToDBMappings); assert.NoError(t, err) {
+		assert.Contains(t, apiCRToDBMappings, *apiCRToDBMapping)
+	}
+
+	// A KubernetesToDBResourceMapping whose GitopsEngineInstance does not exist, and one whose instance exists
+	missingMapping := &KubernetesToDBResourceMapping{
+		KubernetesResourceType: K8sToDBMapping_Namespace,
+		KubernetesResourceUID:  "test-namespace-uid",
+		DBRelationType:         K8sToDBMapping_GitopsEngineInstance,
+		DBRelationKey:          "test-missing-instance",
+	}
+	validMapping := &KubernetesToDBResourceMapping{
+		KubernetesResourceType: K8sToDBMapping_Namespace,
+		KubernetesResourceUID:  "test-namespace-uid",
+		DBRelationType:         K8sToDBMapping_GitopsEngineInstance,
+		DBRelationKey:          gitopsEngineInstance.Gitopsengineinstance_id,
+	}
+	for _, mapping := range []*KubernetesToDBResourceMapping{missingMapping, validMapping} {
+		mapping := mapping
+		if err := dbq.CreateKubernetesResourceToDBResourceMapping(ctx, mapping); !assert.NoError(t, err) {
+			return
+		}
+		defer func() {
+			_, err := dbq.DeleteKubernetesResourceToDBResourceMapping(ctx, mapping)
+			assert.NoError(t, err)
+		}()
+	}
+
+	var kubernetesToDBMappings []KubernetesToDBResourceMapping
+	if err := dbq.ListKubernetesToDBResourceMappingsWithMissingDBRelation(ctx, &kubernetesToDBMappings); assert.NoError(t, err) {
+		assert.Contains(t, kubernetesToDBMappings, *missingMapping)
+		assert.NotContains(t, kubernetesToDBMappings, *validMapping)
+	}
+}
+
 func TestClusterUser(t *testing.T) {
 
 	testSetup(t)
@@ -0,0 +1,83 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redhat-appstudio/managed-gitops/backend-shared/config/db"
+)
+
+const (
+	// GitopsEngineClusterHeartbeatInterval is how often the cluster-agent updates the heartbeat of its GitopsEngineCluster.
+	GitopsEngineClusterHeartbeatInterval = 30 * time.Second
+
+	// GitopsEngineClusterHeartbeatTimeout is how long after the last heartbeat a GitopsEngineCluster is considered unavailable:
+	// this allows for a few heartbeats to be missed (for example, due to a restart of the cluster-agent).
+	GitopsEngineClusterHeartbeatTimeout = 4 * GitopsEngineClusterHeartbeatInterval
+)
+
+// GitopsEngineClusterAvailability indicates whether the cluster-agent of a GitopsEngineCluster is running, based on its heartbeat.
+type GitopsEngineClusterAvailability string
+
+const (
+	// GitopsEngineClusterAvailability_Available: the cluster-agent has sent a heartbeat recently
+	GitopsEngineClusterAvailability_Available GitopsEngineClusterAvailability = "Available"
+
+	// GitopsEngineClusterAvailability_Unavailable: the cluster-agent has not sent a heartbeat within the heartbeat timeout
+	GitopsEngineClusterAvailability_Unavailable GitopsEngineClusterAvailability = "Unavailable"
+
+	// GitopsEngineClusterAvailability_Unknown: the cluster-agent has never sent a heartbeat (for example, the cluster was
+	// only just created, or the cluster-agent predates heartbeats)
+	GitopsEngineClusterAvailability_Unknown GitopsEngineClusterAvailability = "Unknown"
+)
+
+// GetGitopsEngineClusterAvailability returns the availability of the GitopsEngineCluster, at the given time, based on its heartbeat fields.
+func GetGitopsEngineClusterAvailability(gitopsEngineCluster db.GitopsEngineCluster, now time.Time) GitopsEngineClusterAvailability {
+
+	if gitopsEngineCluster.Heartbeat_last_seen.IsZero() {
+		return GitopsEngineClusterAvailability_Unknown
+	}
+
+	if now.Sub(gitopsEngineCluster.Heartbeat_last_seen) > GitopsEngineClusterHeartbeatTimeout {
+		return GitopsEngineClusterAvailability_Unavailable
+	}
+
+	return GitopsEngineClusterAvailability_Available
+}
+
+// CheckGitopsEngineInstanceAvailable returns an error if the cluster-agent of the GitopsEngineCluster hosting the given
+// GitopsEngineInstance has stopped sending heartbeats. Operations that target the instance will not be processed until
+// the cluster-agent is running again.
+func CheckGitopsEngineInstanceAvailable(ctx context.Context, gitopsEngineInstanceID string, dbq db.DatabaseQueries) error {
+
+	gitopsEngineInstance := &db.GitopsEngineInstance{Gitopsengineinstance_id: gitopsEngineInstanceID}
+	if err := dbq.GetGitopsEngineInstanceById(ctx, gitopsEngineInstance); err != nil {
+		return fmt.Errorf("unable to retrieve gitops engine instance '%s': %w", gitopsEngineInstanceID, err)
+	}
+
+	gitopsEngineCluster := &db.GitopsEngineCluster{Gitopsenginecluster_id: gitopsEngineInstance.EngineCluster_id}
+	if err := dbq.GetGitopsEngineClusterById(ctx, gitopsEngineCluster); err != nil {
+		return fmt.Errorf("unable to retrieve gitops engine cluster '%s': %w", gitopsEngineInstance.EngineCluster_id, err)
+	}
+
+	if GetGitopsEngineClusterAvailability(*gitopsEngineCluster, time.Now()) == GitopsEngineClusterAvailability_Unavailable {
+		return NewGitopsEngineClusterUnavailableError(*gitopsEngineCluster)
+	}
+
+	return nil
+}
+
+// ErrGitopsEngineClusterUnavailable is wrapped by the errors returned by NewGitopsEngineClusterUnavailableError
+var ErrGitopsEngineClusterUnavailable = errors.New("no cluster-agent heartbeat")
+
+// NewGitopsEngineClusterUnavailableError returns an error that will be matched by IsGitopsEngineClusterUnavailableError
+func NewGitopsEngineClusterUnavailableError(gitopsEngineCluster db.GitopsEngineCluster) error {
+	return fmt.Errorf("gitops engine cluster '%s' is unavailable: %w since %s",
+		gitopsEngineCluster.Gitopsenginecluster_id, ErrGitopsEngineClusterUnavailable, gitopsEngineCluster.Heartbeat_last_seen.Format(time.RFC3339))
+}
+
+func IsGitopsEngineClusterUnavailableError(err error) bool {
+	return errors.Is(err, ErrGitopsEngineClusterUnavailable)
+}
@@ -0,0 +1,179 @@
+package util
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redhat-appstudio/managed-gitops/backend-shared/config/db"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// Operation lifecycle metrics are reported by both the backend and the cluster-agent:
+// - The cluster-agent reports the operations that it processes to completion, and the operations it retries.
+// - The backend reports the operations that it moves to the Timeout state (see 'OperationReaper'), which may never
+//   have been processed by a cluster-agent, and the outstanding operations of each GitopsEngineInstance.
+
+// operationDurationBuckets are the buckets of the operation duration histograms: operations should usually complete
+// within seconds, but may take up to their deadline (see 'db.GetOperationTimeout').
+var operationDurationBuckets = []float64{0.1, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300, 600, 1800, 3600}
+
+var (
+	// operationWaitingDuration is the time from the creation of an operation, until a cluster-agent started processing it.
+	operationWaitingDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "gitops_operation_waiting_duration_seconds",
+		Help: "Time that completed operations spent in the Waiting state, by resource type and final state. Operations " +
+			"whose processing start time is not known (such as those timed out by the backend) are not included",
+		Buckets: operationDurationBuckets,
+	}, []string{"resource_type", "state"})
+
+	// operationInProgressDuration is the time from when a cluster-agent started processing an operation, until it completed.
+	operationInProgressDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "gitops_operation_in_progress_duration_seconds",
+		Help:    "Time that completed operations spent being processed by the cluster-agent, by resource type and final state",
+		Buckets: operationDurationBuckets,
+	}, []string{"resource_type", "state"})
+
+	// operationDuration is the time from the creation of an operation, until it completed.
+	operationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "gitops_operation_duration_seconds",
+		Help:    "Time from the creation of an operation until it completed, by resource type and final state",
+		Buckets: operationDurationBuckets,
+	}, []string{"resource_type", "state"})
+
+	// operationFailures is the number of operations that completed in the Failed or Timeout state.
+	operationFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gitops_operation_failures_total",
+		Help: "Number of operations that completed in the Failed or Timeout state, by resource type and state",
+	}, []string{"resource_type", "state"})
+
+	// operationRetries is the number of times the processing of an operation failed, and was retried.
+	operationRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gitops_operation_retries_total",
+		Help: "Number of times the processing of an operation by the cluster-agent failed and was retried, by resource type",
+	}, []string{"resource_type"})
+
+	// outstandingOperations is the number of operations that have not completed, by GitopsEngineInstance and state.
+	outstandingOperations = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "gitops_operations_outstanding",
+		Help: "Number of operations in the Waiting or In_Progress state, by GitopsEngineInstance and state",
+	}, []string{"gitopsengineinstance", "state"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(operationWaitingDuration, operationInProgressDuration, operationDuration,
+		operationFailures, operationRetries, outstandingOperations)
+}
+
+// ReportOperationCompleted reports the lifecycle of an operation that has moved to a completed state (see
+// 'db.IsOperationStateComplete'): 'operation' should be the operation with its final state, and Last_state_update set
+// to when it completed.
+//
+// 'startedProcessing' is when a cluster-agent started processing the operation, or zero if it is not known: for example,
+// the OperationReaper cannot tell whether an operation it timed out was ever processed. Only the total duration of the
+// operation is then reported, as the time it spent in each of the Waiting and In_Progress states is not known.
+func ReportOperationCompleted(operation db.Operation, startedProcessing time.Time) {
+
+	if !db.IsOperationStateComplete(operation.State) || operation.Created_on.IsZero() {
+		return
+	}
+
+	completed := operation.Last_state_update
+	if completed.IsZero() {
+		completed = time.Now()
+	}
+
+	if !startedProcessing.IsZero() {
+
+		waitingEnded := completed
+		if startedProcessing.Before(completed) {
+			waitingEnded = startedProcessing
+		}
+
+		operationWaitingDuration.WithLabelValues(operation.Resource_type, operation.State).
+			Observe(nonNegativeSeconds(waitingEnded.Sub(operation.Created_on)))
+
+		operationInProgressDuration.WithLabelValues(operation.Resource_type, operation.State).
+			Observe(nonNegativeSeconds(completed.Sub(waitingEnded)))
+	}
+
+	operationDuration.WithLabelValues(operation.Resource_type, operation.State).
+		Observe(nonNegativeSeconds(completed.Sub(operation.Created_on)))
+
+	if operation.State == db.OperationState_Failed || operation.State == db.OperationState_Timeout {
+		operationFailures.WithLabelValues(operation.Resource_type, operation.State).Inc()
+	}
+}
+
+// ReportOperationRetried reports that the processing of an operation failed, and will be retried.
+func ReportOperationRetried(operation db.Operation) {
+	operationRetries.WithLabelValues(operation.Resource_type).Inc()
+}
+
+// ReportOutstandingOperations replaces the number of outstanding operations of each GitopsEngineInstance with the
+// number of operations of 'incompleteOperations' (see 'ListIncompleteOperations').
+func ReportOutstandingOperations(incompleteOperations []db.Operation) {
+
+	counts := map[[2]string]int{}
+	for _, operation := range incompleteOperations {
+		counts[[2]string{operation.Instance_id, operation.State}]++
+	}
+
+	// GitopsEngineInstances that no longer have outstanding operations are removed
+	outstandingOperations.Reset()
+	for key, count := range counts {
+		outstandingOperations.WithLabelValues(key[0], key[1]).Set(float64(count))
+	}
+}
+
+func nonNegativeSeconds(duration time.Duration) float64 {
+	if duration < 0 {
+		return 0
+	}
+	return duration.Seconds()
+}
+
+// OperationTimings records when the processing of each operation started, so that the time an operation spent in the
+// Waiting state can be distinguished from the time it spent being processed: this is not recorded in the Operation
+// table, as an operation may complete without ever moving to the In_Progress state.
+//
+// The timings are kept in memory, so after a restart, an operation that was being processed is reported as having
+// started processing when it was first processed by the new process.
+type OperationTimings struct {
+	mutex sync.Mutex
+
+	// started contains the time at which the processing of each operation started, by operation id
+	started map[string]time.Time
+}
+
+func NewOperationTimings() *OperationTimings {
+	return &OperationTimings{started: map[string]time.Time{}}
+}
+
+// StartedProcessing records that the processing of the operation started at 'now', unless it was previously recorded,
+// and returns the time at which processing started.
+func (timings *OperationTimings) StartedProcessing(operationID string, now time.Time) time.Time {
+
+	timings.mutex.Lock()
+	defer timings.mutex.Unlock()
+
+	if started, exists := timings.started[operationID]; exists {
+		return started
+	}
+
+	timings.started[operationID] = now
+	return now
+}
+
+// Completed removes the timings of the operation, and returns the time at which processing started (or zero, if the
+// processing of the operation was not recorded).
+func (timings *OperationTimings) Completed(operationID string) time.Time {
+
+	timings.mutex.Lock()
+	defer timings.mutex.Unlock()
+
+	started := timings.started[operationID]
+	delete(timings.started, operationID)
+
+	return started
+}
@@ -0,0 +1,101 @@
+package util
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/redhat-appstudio/managed-gitops/backend-shared/config/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReportOperationCompleted(t *testing.T) {
+
+	now := time.Now()
+
+	t.Run("Only the total duration of an operation whose processing start time is not known is reported", func(t *testing.T) {
+
+		waitingSeries := testutil.CollectAndCount(operationWaitingDuration)
+		inProgressSeries := testutil.CollectAndCount(operationInProgressDuration)
+		durationSeries := testutil.CollectAndCount(operationDuration)
+
+		ReportOperationCompleted(db.Operation{
+			Resource_type:     "test-start-unknown",
+			State:             db.OperationState_Timeout,
+			Created_on:        now.Add(-time.Hour),
+			Last_state_update: now,
+		}, time.Time{})
+
+		assert.Equal(t, waitingSeries, testutil.CollectAndCount(operationWaitingDuration))
+		assert.Equal(t, inProgressSeries, testutil.CollectAndCount(operationInProgressDuration))
+		assert.Equal(t, durationSeries+1, testutil.CollectAndCount(operationDuration))
+		assert.Equal(t, float64(1), testutil.ToFloat64(operationFailures.WithLabelValues("test-start-unknown", db.OperationState_Timeout)))
+	})
+
+	t.Run("A processed operation is reported in each state, and only failures are counted as such", func(t *testing.T) {
+
+		waitingSeries := testutil.CollectAndCount(operationWaitingDuration)
+		inProgressSeries := testutil.CollectAndCount(operationInProgressDuration)
+		durationSeries := testutil.CollectAndCount(operationDuration)
+
+		ReportOperationCompleted(db.Operation{
+			Resource_type:     "test-processed",
+			State:             db.OperationState_Completed,
+			Created_on:        now.Add(-time.Minute),
+			Last_state_update: now,
+		}, now.Add(-time.Second))
+
+		assert.Equal(t, waitingSeries+1, testutil.CollectAndCount(operationWaitingDuration))
+		assert.Equal(t, inProgressSeries+1, testutil.CollectAndCount(operationInProgressDuration))
+		assert.Equal(t, durationSeries+1, testutil.CollectAndCount(operationDuration))
+		assert.Equal(t, float64(0), testutil.ToFloat64(operationFailures.WithLabelValues("test-processed", db.OperationState_Completed)))
+	})
+
+	t.Run("An operation that has not completed is not reported", func(t *testing.T) {
+
+		durationSeries := testutil.CollectAndCount(operationDuration)
+
+		ReportOperationCompleted(db.Operation{
+			Resource_type: "test-incomplete",
+			State:         db.OperationState_In_Progress,
+			Created_on:    now.Add(-time.Minute),
+		}, now.Add(-time.Second))
+
+		assert.Equal(t, durationSeries, testutil.CollectAndCount(operationDuration))
+	})
+}
+
+func TestReportOutstandingOperations(t *testing.T) {
+
+	ReportOutstandingOperations([]db.Operation{
+		{Instance_id: "test-instance", State: db.OperationState_Waiting},
+		{Instance_id: "test-instance", State: db.OperationState_Waiting},
+		{Instance_id: "test-instance", State: db.OperationState_In_Progress},
+		{Instance_id: "test-other-instance", State: db.OperationState_Waiting},
+	})
+
+	assert.Equal(t, 3, testutil.CollectAndCount(outstandingOperations))
+	assert.Equal(t, float64(2), testutil.ToFloat64(outstandingOperations.WithLabelValues("test-instance", db.OperationState_Waiting)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(outstandingOperations.WithLabelValues("test-instance", db.OperationState_In_Progress)))
+
+	// A GitopsEngineInstance that no longer has outstanding operations is no longer reported
+	ReportOutstandingOperations([]db.Operation{
+		{Instance_id: "test-instance", State: db.OperationState_In_Progress},
+	})
+
+	assert.Equal(t, 1, testutil.CollectAndCount(outstandingOperations))
+	assert.Equal(t, float64(1), testutil.ToFloat64(outstandingOperations.WithLabelValues("test-instance", db.OperationState_In_Progress)))
+}
+
+func TestOperationTimings(t *testing.T) {
+
+	timings := NewOperationTimings()
+	now := time.Now()
+
+	// The first attempt at processing the operation is when its processing started
+	assert.Equal(t, now, timings.StartedProcessing("test-operation", now))
+	assert.Equal(t, now, timings.StartedProcessing("test-operation", now.Add(time.Minute)))
+
+	assert.Equal(t, now, timings.Completed("test-operation"))
+	assert.True(t, timings.Completed("test-operation").IsZero())
+}
@@ -0,0 +1,35 @@
+package util
+
+import (
+	"context"
+
+	"github.com/redhat-appstudio/managed-gitops/backend-shared/config/db"
+)
+
+// ConflictRetries is the number of times RetryOnConflict retries a function that returned a conflict error.
+const ConflictRetries = 5
+
+// RetryOnConflict calls 'fn' until it returns an error which is not a version conflict or serialization failure error
+// (see 'db.IsVersionConflictError' and 'db.IsSerializationFailureError'), or until the retries are exhausted (in which
+// case the conflict error is returned).
+//
+// These errors are returned when a database row was concurrently updated (for example, by another backend replica
+// or cluster-agent) since it was read: 'fn' should thus re-read the row before updating it, on each call. Other
+// conflicts, such as unique or foreign key constraint violations, would not be resolved by retrying, and so are returned.
+func RetryOnConflict(ctx context.Context, fn func() error) error {
+
+	var err error
+
+	for attempt := 0; attempt <= ConflictRetries; attempt++ {
+
+		if err = fn(); err == nil || !(db.IsVersionConflictError(err) || db.IsSerializationFailureError(err)) {
+			return err
+		}
+
+		if ctx.Err() != nil {
+			return err
+		}
+	}
+
+	return err
+}
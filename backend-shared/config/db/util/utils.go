@@ -33,6 +33,21 @@ func GetGitOpsEngineSingleInstanceNamespace() string {
 	return DefaultGitOpsEngineSingleInstanceNamespace
 }
 
+// TODO: GITOPS-1722 - Until we create a service account on the target cluster, the ClusterCredentials created by this
+// package contain placeholder values, rather than actual credentials.
+const (
+	placeholderClusterCredentialsHost       = "host"
+	placeholderClusterCredentialsKubeConfig = "kube_config"
+)
+
+// IsPlaceholderClusterCredentials returns true if the ClusterCredentials contain placeholder values, rather than
+// actual credentials. The placeholder credentials of a GitopsEngineCluster refer to the cluster that the backend is
+// running on.
+func IsPlaceholderClusterCredentials(clusterCredentials db.ClusterCredentials) bool {
+	return clusterCredentials.Host == placeholderClusterCredentialsHost &&
+		clusterCredentials.Kube_config == placeholderClusterCredentialsKubeConfig
+}
+
 func GetOrCreateManagedEnvironmentByNamespaceUID(ctx context.Context, workspaceNamespace v1.Namespace,
 	dbq db.DatabaseQueries, log logr.Logger) (*db.ManagedEnvironment, error) {
 
@@ -62,7 +77,7 @@ func GetOrCreateManagedEnvironmentByNamespaceUID(ctx context.Context, workspaceN
 
 		if !db.IsResultNotFoundError(err) {
 			// Failure: A generic error occurred.
-			return nil, fmt.Errorf("unable to retrieve resource mapping: %v", err)
+			return nil, fmt.Errorf("unable to retrieve resource mapping: %w", err)
 		}
 
 		// At this point, we found the mapping, but didn't find the ManagedEnvironment that the
@@ -74,7 +89,7 @@ func GetOrCreateManagedEnvironmentByNamespaceUID(ctx context.Context, workspaceN
 		}
 
 	} else if !db.IsResultNotFoundError(err) {
-		return nil, fmt.Errorf("unable to retrieve resource mapping: %v", err)
+		return nil, fmt.Errorf("unable to retrieve resource mapping: %w", err)
 	}
 
 	// At this point in the function, both the managed environment and mapping necessarily don't exist
@@ -83,14 +98,14 @@ func GetOrCreateManagedEnvironmentByNamespaceUID(ctx context.Context, workspaceN
 	// TODO: GITOPS-1722 - Cluster credentials placeholder values - we will need to create a service account on the target cluster, which we can store in the database.
 
 	clusterCreds := db.ClusterCredentials{
-		Host:                        "host",
-		Kube_config:                 "kube_config",
+		Host:                        placeholderClusterCredentialsHost,
+		Kube_config:                 placeholderClusterCredentialsKubeConfig,
 		Kube_config_context:         "kube_config_context",
 		Serviceaccount_bearer_token: "serviceaccount_bearer_token",
 		Serviceaccount_ns:           "serviceaccount_ns",
 	}
 	if err := dbq.CreateClusterCredentials(ctx, &clusterCreds); err != nil {
-		return nil, fmt.Errorf("unable to create cluster creds for managed env: %v", err)
+		return nil, fmt.Errorf("unable to create cluster creds for managed env: %w", err)
 	}
 
 	managedEnvironment := db.ManagedEnvironment{
@@ -98,7 +113,7 @@ func GetOrCreateManagedEnvironmentByNamespaceUID(ctx context.Context, workspaceN
 		Clustercredentials_id: clusterCreds.Clustercredentials_cred_id,
 	}
 	if err := dbq.CreateManagedEnvironment(ctx, &managedEnvironment); err != nil {
-		return nil, fmt.Errorf("unable to create managed env: %v", err)
+		return nil, fmt.Errorf("unable to create managed env: %w", err)
 	}
 
 	dbResourceMapping = &db.KubernetesToDBResourceMapping{
@@ -109,7 +124,7 @@ func GetOrCreateManagedEnvironmentByNamespaceUID(ctx context.Context, workspaceN
 	}
 
 	if err := dbq.CreateKubernetesResourceToDBResourceMapping(ctx, dbResourceMapping); err != nil {
-		return nil, fmt.Errorf("unable to create KubernetesResourceToDBResourceMapping: %v", err)
+		return nil, fmt.Errorf("unable to create KubernetesResourceToDBResourceMapping: %w", err)
 	}
 
 	return &managedEnvironment, nil
@@ -124,7 +139,7 @@ func GetOrCreateGitopsEngineInstanceByInstanceNamespaceUID(ctx context.Context,
 	// First create the GitOpsEngine cluster if needed; this will be used to create the instance.
 	gitopsEngineCluster, err := GetOrCreateGitopsEngineClusterByKubeSystemNamespaceUID(ctx, kubesystemNamespaceUID, dbq, log)
 	if err != nil {
-		return nil, nil, fmt.Errorf("unable to create GitOpsEngineCluster for '%v', error: '%v'", kubesystemNamespaceUID, err)
+		return nil, nil, fmt.Errorf("unable to create GitOpsEngineCluster for '%v', error: '%w'", kubesystemNamespaceUID, err)
 	}
 
 	var gitopsEngineInstance *db.GitopsEngineInstance
@@ -144,7 +159,7 @@ func GetOrCreateGitopsEngineInstanceByInstanceNamespaceUID(ctx context.Context,
 	if err := dbq.GetDBResourceMappingForKubernetesResource(ctx, dbResourceMapping); err != nil {
 
 		if !db.IsResultNotFoundError(err) {
-			return nil, nil, fmt.Errorf("unable to get DBResourceMapping for getOrCreateGitopsEngineInstanceByInstanceNamespaceUID: %v", err)
+			return nil, nil, fmt.Errorf("unable to get DBResourceMapping for getOrCreateGitopsEngineInstanceByInstanceNamespaceUID: %w", err)
 		}
 
 		dbResourceMapping = nil
@@ -192,12 +207,12 @@ func GetOrCreateGitopsEngineInstanceByInstanceNamespaceUID(ctx context.Context,
 		}
 
 		if err := dbq.CreateGitopsEngineInstance(ctx, gitopsEngineInstance); err != nil {
-			return nil, nil, fmt.Errorf("unable to create engine instance, when neither existed: %v", err)
+			return nil, nil, fmt.Errorf("unable to create engine instance, when neither existed: %w", err)
 		}
 
 		expectedDBResourceMapping.DBRelationKey = gitopsEngineInstance.Gitopsengineinstance_id
 		if err := dbq.CreateKubernetesResourceToDBResourceMapping(ctx, &expectedDBResourceMapping); err != nil {
-			return nil, nil, fmt.Errorf("unable to create mapping when neither existed: %v", err)
+			return nil, nil, fmt.Errorf("unable to create mapping when neither existed: %w", err)
 		}
 
 		return gitopsEngineInstance, gitopsEngineCluster, nil
@@ -211,7 +226,7 @@ func GetOrCreateGitopsEngineInstanceByInstanceNamespaceUID(ctx context.Context,
 
 		expectedDBResourceMapping.DBRelationKey = gitopsEngineInstance.Gitopsengineinstance_id
 		if err := dbq.CreateKubernetesResourceToDBResourceMapping(ctx, &expectedDBResourceMapping); err != nil {
-			return nil, nil, fmt.Errorf("unable to create mapping when dbResourceMapping didn't exist: %v", err)
+			return nil, nil, fmt.Errorf("unable to create mapping when dbResourceMapping didn't exist: %w", err)
 		}
 
 		return gitopsEngineInstance, gitopsEngineCluster, nil
@@ -300,7 +315,7 @@ func GetOrCreateGitopsEngineClusterByKubeSystemNamespaceUID(ctx context.Context,
 	if err := dbq.GetDBResourceMappingForKubernetesResource(ctx, dbResourceMapping); err != nil {
 
 		if !db.IsResultNotFoundError(err) {
-			return nil, fmt.Errorf("unable to get DBResourceMapping: %v", err)
+			return nil, fmt.Errorf("unable to get DBResourceMapping: %w", err)
 		}
 
 		dbResourceMapping = nil
@@ -338,14 +353,14 @@ func GetOrCreateGitopsEngineClusterByKubeSystemNamespaceUID(ctx context.Context,
 		// Create cluster credentials for the managed env
 		// TODO: GITOPS-1722 - Cluster credentials placeholder values - we will need to create a service account on the target cluster, which we can store in the database.
 		clusterCreds := db.ClusterCredentials{
-			Host:                        "host",
-			Kube_config:                 "kube_config",
+			Host:                        placeholderClusterCredentialsHost,
+			Kube_config:                 placeholderClusterCredentialsKubeConfig,
 			Kube_config_context:         "kube_config_context",
 			Serviceaccount_bearer_token: "serviceaccount_bearer_token",
 			Serviceaccount_ns:           "serviceaccount_ns",
 		}
 		if err := dbq.CreateClusterCredentials(ctx, &clusterCreds); err != nil {
-			return nil, fmt.Errorf("unable to create cluster creds for managed env: %v", err)
+			return nil, fmt.Errorf("unable to create cluster creds for managed env: %w", err)
 		}
 
 		gitopsEngineCluster = &db.GitopsEngineCluster{
@@ -353,12 +368,12 @@ func GetOrCreateGitopsEngineClusterByKubeSystemNamespaceUID(ctx context.Context,
 		}
 
 		if err := dbq.CreateGitopsEngineCluster(ctx, gitopsEngineCluster); err != nil {
-			return nil, fmt.Errorf("unable to create engine cluster, when neither existed: %v", err)
+			return nil, fmt.Errorf("unable to create engine cluster, when neither existed: %w", err)
 		}
 
 		expectedDBResourceMapping.DBRelationKey = gitopsEngineCluster.Gitopsenginecluster_id
 		if err := dbq.CreateKubernetesResourceToDBResourceMapping(ctx, &expectedDBResourceMapping); err != nil {
-			return nil, fmt.Errorf("unable to create mapping when neither existed: %v", err)
+			return nil, fmt.Errorf("unable to create mapping when neither existed: %w", err)
 		}
 
 		return gitopsEngineCluster, nil
@@ -377,7 +392,7 @@ func GetOrCreateGitopsEngineClusterByKubeSystemNamespaceUID(ctx context.Context,
 
 		expectedDBResourceMapping.DBRelationKey = gitopsEngineCluster.Gitopsenginecluster_id
 		if err := dbq.CreateKubernetesResourceToDBResourceMapping(ctx, &expectedDBResourceMapping); err != nil {
-			return nil, fmt.Errorf("unable to create mapping when dbResourceMapping didn't exist: %v", err)
+			return nil, fmt.Errorf("unable to create mapping when dbResourceMapping didn't exist: %w", err)
 		}
 
 		return gitopsEngineCluster, nil
@@ -395,7 +410,7 @@ func GetOrCreateDeploymentToApplicationMapping(ctx context.Context, createDeplTo
 
 	if err := dbq.GetDeploymentToApplicationMappingByDeplId(ctx, createDeplToAppMapping); err != nil {
 		if !db.IsResultNotFoundError(err) {
-			logErr := fmt.Errorf("unable to get obj in GetOrDeploymentToApplicationMapping: %v", err)
+			logErr := fmt.Errorf("unable to get obj in GetOrDeploymentToApplicationMapping: %w", err)
 			log.Error(logErr, "unable to get deplToApp mapping", "createDeplToAppMapping", createDeplToAppMapping)
 			return logErr
 		}
@@ -1,7 +1,10 @@
 package util
 
 import (
+	"context"
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/redhat-appstudio/managed-gitops/backend-shared/config/db"
 	"github.com/stretchr/testify/assert"
@@ -40,3 +43,70 @@ func TestGetOrCreateGitopsEngineInstanceByInstanceNamespaceUID(t *testing.T) {
 
 	// assert.Nil(t, err)
 }
+
+func TestGetGitopsEngineClusterAvailability(t *testing.T) {
+
+	now := time.Now()
+
+	assert.Equal(t, GitopsEngineClusterAvailability_Unknown,
+		GetGitopsEngineClusterAvailability(db.GitopsEngineCluster{}, now))
+
+	assert.Equal(t, GitopsEngineClusterAvailability_Available,
+		GetGitopsEngineClusterAvailability(db.GitopsEngineCluster{Heartbeat_last_seen: now.Add(-GitopsEngineClusterHeartbeatInterval)}, now))
+
+	unavailableCluster := db.GitopsEngineCluster{Gitopsenginecluster_id: "test-cluster", Heartbeat_last_seen: now.Add(-2 * GitopsEngineClusterHeartbeatTimeout)}
+	assert.Equal(t, GitopsEngineClusterAvailability_Unavailable, GetGitopsEngineClusterAvailability(unavailableCluster, now))
+
+	assert.True(t, IsGitopsEngineClusterUnavailableError(NewGitopsEngineClusterUnavailableError(unavailableCluster)))
+	assert.False(t, IsGitopsEngineClusterUnavailableError(fmt.Errorf("some other error")))
+}
+
+func TestRetryOnConflict(t *testing.T) {
+
+	ctx := context.Background()
+
+	t.Run("version conflict errors are retried", func(t *testing.T) {
+		calls := 0
+		err := RetryOnConflict(ctx, func() error {
+			calls++
+			if calls < 3 {
+				return db.NewVersionConflictError("application")
+			}
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("other errors are not retried", func(t *testing.T) {
+		calls := 0
+		err := RetryOnConflict(ctx, func() error {
+			calls++
+			return db.NewResultNotFoundError("application")
+		})
+		assert.True(t, db.IsResultNotFoundError(err))
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("constraint violations are not retried", func(t *testing.T) {
+		for _, violationErr := range []error{db.NewUniqueViolationError("application"), db.NewForeignKeyViolationError("application")} {
+			calls := 0
+			err := RetryOnConflict(ctx, func() error {
+				calls++
+				return violationErr
+			})
+			assert.Equal(t, violationErr, err)
+			assert.Equal(t, 1, calls)
+		}
+	})
+
+	t.Run("the conflict error is returned when the retries are exhausted", func(t *testing.T) {
+		calls := 0
+		err := RetryOnConflict(ctx, func() error {
+			calls++
+			return db.NewVersionConflictError("application")
+		})
+		assert.True(t, db.IsVersionConflictError(err))
+		assert.Equal(t, ConflictRetries+1, calls)
+	})
+}
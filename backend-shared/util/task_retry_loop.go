@@ -6,9 +6,35 @@ import (
 	"time"
 
 	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
 )
 
+var (
+	// taskRetryLoopActiveTasks is the number of tasks currently running, by task retry loop name.
+	taskRetryLoopActiveTasks = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "gitops_task_retry_loop_active_tasks",
+		Help: "Number of tasks currently running, by task retry loop",
+	}, []string{"name"})
+
+	// taskRetryLoopWaitingTasks is the number of tasks waiting to (re)run, by task retry loop name.
+	taskRetryLoopWaitingTasks = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "gitops_task_retry_loop_waiting_tasks",
+		Help: "Number of tasks waiting to run, or to be retried, by task retry loop",
+	}, []string{"name"})
+
+	// taskRetryLoopRetries is the number of times a task requested to be retried, by task retry loop name.
+	taskRetryLoopRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gitops_task_retry_loop_retries_total",
+		Help: "Number of times a task was queued to be retried, by task retry loop",
+	}, []string{"name"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(taskRetryLoopActiveTasks, taskRetryLoopWaitingTasks, taskRetryLoopRetries)
+}
+
 type TaskRetryLoop struct {
 	inputChan chan taskRetryLoopMessage
 
@@ -33,15 +59,20 @@ func (loop *TaskRetryLoop) AddTaskIfNotPresent(name string, task RetryableTask,
 	}
 }
 
-// func (loop *taskRetryLoop) removeTask(name string) {
+// CancelTaskIfPresent cancels the context of the task with the given name, if it is running, and removes it from the
+// wait queue, if it is waiting to (re)run. A cancelled task is not retried, even if it requests a retry.
+//
+// A new task with the same name may be added after cancellation: it will not be started until the cancelled task has
+// returned from PerformTask.
+func (loop *TaskRetryLoop) CancelTaskIfPresent(name string) {
 
-// 	loop.inputChan <- taskRetryLoopMessage{
-// 		msgType: taskRetryLoop_removeTask,
-// 		payload: taskRetryMessage_removeTask{
-// 			name: name,
-// 		},
-// 	}
-// }
+	loop.inputChan <- taskRetryLoopMessage{
+		msgType: taskRetryLoop_removeTask,
+		payload: taskRetryMessage_removeTask{
+			name: name,
+		},
+	}
+}
 
 type taskRetryMessageType string
 
@@ -115,10 +146,16 @@ type internalTaskEntry struct {
 	taskContext  context.Context
 	cancelFunc   context.CancelFunc
 	creationTime time.Time
+
+	// cancelled is true if the task was cancelled while running: it will not be retried
+	cancelled bool
 }
 
 const (
 	ReportActiveTasksEveryXMinutes = 10 * time.Minute
+
+	// MaxActiveTaskRunners is the maximum number of tasks that a task retry loop will run concurrently
+	MaxActiveTaskRunners = 20
 )
 
 func internalTaskRetryLoop(inputChan chan taskRetryLoopMessage, debugName string) {
@@ -133,10 +170,12 @@ func internalTaskRetryLoop(inputChan chan taskRetryLoopMessage, debugName string
 	waitingTasksByName := map[string]interface{}{}
 	waitingTasks := []waitingTaskEntry{}
 
-	const maxActiveRunners = 20
-
 	nextReportActiveTasks := time.Now().Add(ReportActiveTasksEveryXMinutes)
 
+	activeTasksGauge := taskRetryLoopActiveTasks.WithLabelValues(debugName)
+	waitingTasksGauge := taskRetryLoopWaitingTasks.WithLabelValues(debugName)
+	retriesCounter := taskRetryLoopRetries.WithLabelValues(debugName)
+
 	for {
 
 		// Every X minutes, report how many tasks are in progress, and how many are waiting
@@ -146,7 +185,7 @@ func internalTaskRetryLoop(inputChan chan taskRetryLoopMessage, debugName string
 		}
 
 		// Queue more running tasks if we have resources
-		if len(waitingTasks) > 0 && len(activeTaskMap) < maxActiveRunners {
+		if len(waitingTasks) > 0 && len(activeTaskMap) < MaxActiveTaskRunners {
 
 			updatedWaitingTasks := []waitingTaskEntry{}
 
@@ -164,7 +203,12 @@ func internalTaskRetryLoop(inputChan chan taskRetryLoopMessage, debugName string
 					startTask = true
 				}
 
-				if startTask && len(activeTaskMap) < 20 {
+				// Don't start a task while a previous task with the same name is still running (for example, a cancelled task)
+				if _, running := activeTaskMap[task.name]; running {
+					startTask = false
+				}
+
+				if startTask && len(activeTaskMap) < MaxActiveTaskRunners {
 					startNewTask(task, waitingTasksByName, activeTaskMap, inputChan, log)
 				} else {
 					updatedWaitingTasks = append(updatedWaitingTasks, task)
@@ -176,6 +220,9 @@ func internalTaskRetryLoop(inputChan chan taskRetryLoopMessage, debugName string
 
 		}
 
+		activeTasksGauge.Set(float64(len(activeTaskMap)))
+		waitingTasksGauge.Set(float64(len(waitingTasks)))
+
 		msg := <-inputChan
 
 		if msg.msgType == taskRetryLoop_addTask {
@@ -191,6 +238,7 @@ func internalTaskRetryLoop(inputChan chan taskRetryLoopMessage, debugName string
 				continue
 			}
 
+			waitingTasksByName[addTaskMsg.name] = nil
 			waitingTasks = append(waitingTasks, waitingTaskEntry{
 				name:    addTaskMsg.name,
 				task:    addTaskMsg.task,
@@ -198,22 +246,34 @@ func internalTaskRetryLoop(inputChan chan taskRetryLoopMessage, debugName string
 
 		} else if msg.msgType == taskRetryLoop_removeTask {
 
-			// NOTE: this only removes from the active task map
-
 			removeTaskMsg, ok := (msg.payload).(taskRetryMessage_removeTask)
 			if !ok {
 				log.Error(nil, "SEVERE: unexpected message payload for removeTask")
 				continue
 			}
 
+			// Remove the task from the wait queue, if present
+			if _, exists := waitingTasksByName[removeTaskMsg.name]; exists {
+				delete(waitingTasksByName, removeTaskMsg.name)
+
+				updatedWaitingTasks := []waitingTaskEntry{}
+				for idx := range waitingTasks {
+					if waitingTasks[idx].name != removeTaskMsg.name {
+						updatedWaitingTasks = append(updatedWaitingTasks, waitingTasks[idx])
+					}
+				}
+				waitingTasks = updatedWaitingTasks
+			}
+
+			// Cancel the task, if it is running: it remains in the active task map until it has returned.
 			taskEntry, ok := activeTaskMap[removeTaskMsg.name]
 			if !ok {
-				log.Error(nil, "task not found in map: "+removeTaskMsg.name)
 				continue
 			}
 
-			log.V(LogLevel_Debug).Info("Removing task from retry loop: " + removeTaskMsg.name)
-			delete(activeTaskMap, removeTaskMsg.name)
+			log.V(LogLevel_Debug).Info("Cancelling task in retry loop: " + removeTaskMsg.name)
+			taskEntry.cancelled = true
+			activeTaskMap[removeTaskMsg.name] = taskEntry
 
 			if taskEntry.cancelFunc != nil {
 				go taskEntry.cancelFunc()
@@ -236,11 +296,26 @@ func internalTaskRetryLoop(inputChan chan taskRetryLoopMessage, debugName string
 			// Now that the task is complete, remove it from the active map
 			delete(activeTaskMap, workCompletedMsg.name)
 
-			if workCompletedMsg.shouldRetry {
+			// The context of a task is no longer needed once it has completed
+			if taskEntry.cancelFunc != nil {
+				taskEntry.cancelFunc()
+			}
+
+			if taskEntry.cancelled {
+				log.V(LogLevel_Debug).Info("Cancelled task '" + taskEntry.name + "' has completed, and will not be retried")
+
+			} else if workCompletedMsg.shouldRetry {
 				log.V(LogLevel_Debug).Info("Adding failed task '" + taskEntry.name + "' to retry list")
+				retriesCounter.Inc()
 
 				nextScheduledRetryTime := time.Now().Add(taskEntry.backoff.IncreaseAndReturnNewDuration())
 
+				// A task with the same name may have been added while this task was running
+				if _, exists := waitingTasksByName[workCompletedMsg.name]; exists {
+					continue
+				}
+
+				waitingTasksByName[workCompletedMsg.name] = nil
 				waitingTasks = append(waitingTasks, waitingTaskEntry{
 					name:                   workCompletedMsg.name,
 					task:                   taskEntry.task,
@@ -271,12 +346,13 @@ func startNewTask(taskToStart waitingTaskEntry, waitingTasksByName map[string]in
 		creationTime: time.Now(),
 	}
 
-	activeTaskMap[taskToStart.name] = newTaskEntry
-
 	taskContext, taskCancelFunc := internalStartTaskRunner(&newTaskEntry, inputChan, log)
 	newTaskEntry.taskContext = taskContext
 	newTaskEntry.cancelFunc = taskCancelFunc
 
+	// The entry is added to the map after the context is created, so that the task may be cancelled via the map entry
+	activeTaskMap[taskToStart.name] = newTaskEntry
+
 }
 
 func internalStartTaskRunner(taskEntry *internalTaskEntry, workComplete chan taskRetryLoopMessage, log logr.Logger) (context.Context, context.CancelFunc) {
@@ -0,0 +1,145 @@
+package util
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTaskRetryLoopCancelTask(t *testing.T) {
+
+	loop := NewTaskRetryLoop("test")
+	backoff := ExponentialBackoff{Factor: 2, Min: time.Millisecond * 10, Max: time.Millisecond * 50, Jitter: true}
+
+	task := &blockingTestTask{started: make(chan struct{}, 10)}
+	loop.AddTaskIfNotPresent("task", task, backoff)
+
+	select {
+	case <-task.started:
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "task was not started")
+		return
+	}
+
+	loop.CancelTaskIfPresent("task")
+
+	// The task is blocked until its context is cancelled, and requests a retry: a cancelled task should not be retried.
+	assert.Eventually(t, func() bool { return task.getCompletedRuns() == 1 }, 5*time.Second, 10*time.Millisecond)
+	time.Sleep(200 * time.Millisecond)
+	assert.Equal(t, 1, task.getStartedRuns())
+
+	// Cancelling a task that is not present has no effect
+	loop.CancelTaskIfPresent("does-not-exist")
+
+	// A task with the same name may be added after it was cancelled
+	newTask := &blockingTestTask{started: make(chan struct{}, 10)}
+	loop.AddTaskIfNotPresent("task", newTask, backoff)
+
+	select {
+	case <-newTask.started:
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "new task was not started")
+		return
+	}
+
+	loop.CancelTaskIfPresent("task")
+	assert.Eventually(t, func() bool { return newTask.getCompletedRuns() == 1 }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestTaskRetryLoopMetrics(t *testing.T) {
+
+	loop := NewTaskRetryLoop("test-metrics")
+	backoff := ExponentialBackoff{Factor: 2, Min: time.Millisecond * 10, Max: time.Millisecond * 50, Jitter: true}
+
+	activeTasks := taskRetryLoopActiveTasks.WithLabelValues("test-metrics")
+	waitingTasks := taskRetryLoopWaitingTasks.WithLabelValues("test-metrics")
+	retries := taskRetryLoopRetries.WithLabelValues("test-metrics")
+
+	task := &blockingTestTask{started: make(chan struct{}, 10)}
+	loop.AddTaskIfNotPresent("task", task, backoff)
+
+	select {
+	case <-task.started:
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "task was not started")
+		return
+	}
+
+	assert.Eventually(t, func() bool { return testutil.ToFloat64(activeTasks) == 1 }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, float64(0), testutil.ToFloat64(waitingTasks))
+
+	// A task that fails once is retried once
+	failingTask := &failOnceTestTask{}
+	loop.AddTaskIfNotPresent("failing-task", failingTask, backoff)
+
+	assert.Eventually(t, func() bool { return failingTask.getRuns() == 2 }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, float64(1), testutil.ToFloat64(retries))
+
+	// A cancelled task is not retried
+	loop.CancelTaskIfPresent("task")
+	assert.Eventually(t, func() bool {
+		return testutil.ToFloat64(activeTasks) == 0 && testutil.ToFloat64(waitingTasks) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, float64(1), testutil.ToFloat64(retries))
+}
+
+// failOnceTestTask requests a retry on its first run, and succeeds on subsequent runs
+type failOnceTestTask struct {
+	mutex sync.Mutex
+	runs  int
+}
+
+func (task *failOnceTestTask) PerformTask(taskContext context.Context) (bool, error) {
+	task.mutex.Lock()
+	defer task.mutex.Unlock()
+	task.runs++
+	return task.runs == 1, nil
+}
+
+func (task *failOnceTestTask) getRuns() int {
+	task.mutex.Lock()
+	defer task.mutex.Unlock()
+	return task.runs
+}
+
+// blockingTestTask blocks until its context is cancelled, then requests a retry
+type blockingTestTask struct {
+	mutex         sync.Mutex
+	startedRuns   int
+	completedRuns int
+
+	started chan struct{}
+}
+
+func (task *blockingTestTask) PerformTask(taskContext context.Context) (bool, error) {
+
+	task.mutex.Lock()
+	task.startedRuns++
+	task.mutex.Unlock()
+
+	task.started <- struct{}{}
+
+	<-taskContext.Done()
+
+	task.mutex.Lock()
+	task.completedRuns++
+	task.mutex.Unlock()
+
+	return true, nil
+}
+
+func (task *blockingTestTask) getStartedRuns() int {
+	task.mutex.Lock()
+	defer task.mutex.Unlock()
+	return task.startedRuns
+}
+
+func (task *blockingTestTask) getCompletedRuns() int {
+	task.mutex.Lock()
+	defer task.mutex.Unlock()
+	return task.completedRuns
+}
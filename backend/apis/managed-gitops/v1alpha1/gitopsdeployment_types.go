@@ -125,6 +125,16 @@ type GitOpsDeploymentConditionType string
 
 const (
 	GitOpsDeploymentConditionErrorOccurred GitOpsDeploymentConditionType = "ErrorOccurred"
+
+	// The following condition types correspond to the Argo CD Application condition of the same name
+
+	// GitOpsDeploymentConditionComparisonError indicates the desired state could not be compared with the live state, for
+	// example, because the repository could not be accessed, or the manifests could not be generated.
+	GitOpsDeploymentConditionComparisonError GitOpsDeploymentConditionType = "ComparisonError"
+	// GitOpsDeploymentConditionInvalidSpecError indicates the GitOpsDeployment (or its Argo CD Application) is invalid
+	GitOpsDeploymentConditionInvalidSpecError GitOpsDeploymentConditionType = "InvalidSpecError"
+	// GitOpsDeploymentConditionSyncError indicates the most recent sync of the GitOpsDeployment failed
+	GitOpsDeploymentConditionSyncError GitOpsDeploymentConditionType = "SyncError"
 )
 
 // SyncStatusCode is a type which represents possible comparison results
@@ -144,6 +154,19 @@ type GitOpsDeploymentReasonType string
 
 const (
 	GitopsDeploymentReasonErrorOccurred GitOpsDeploymentReasonType = "ErrorOccurred"
+
+	// GitopsDeploymentReasonEventProcessingFailed is the reason of the ErrorOccurred condition when an event for the
+	// GitOpsDeployment could not be processed, after all retries.
+	GitopsDeploymentReasonEventProcessingFailed   GitOpsDeploymentReasonType = "EventProcessingFailed"
+	GitopsDeploymentReasonEventProcessingResolved GitOpsDeploymentReasonType = "EventProcessingResolved"
+
+	GitopsDeploymentReasonComparisonError          GitOpsDeploymentReasonType = "ComparisonError"
+	GitopsDeploymentReasonComparisonErrorResolved  GitOpsDeploymentReasonType = "ComparisonErrorResolved"
+	GitopsDeploymentReasonInvalidSpecError         GitOpsDeploymentReasonType = "InvalidSpecError"
+	GitopsDeploymentReasonInvalidSpecErrorResolved GitOpsDeploymentReasonType = "InvalidSpecErrorResolved"
+	GitopsDeploymentReasonSyncError                GitOpsDeploymentReasonType = "SyncError"
+	GitopsDeploymentReasonSyncOperationFailed      GitOpsDeploymentReasonType = "SyncOperationFailed"
+	GitopsDeploymentReasonSyncErrorResolved        GitOpsDeploymentReasonType = "SyncErrorResolved"
 )
 
 //+kubebuilder:object:root=true
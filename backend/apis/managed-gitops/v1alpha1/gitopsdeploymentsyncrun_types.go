@@ -29,6 +29,15 @@ type GitOpsDeploymentSyncRunSpec struct {
 // GitOpsDeploymentSyncRunStatus defines the observed state of GitOpsDeploymentSyncRun
 type GitOpsDeploymentSyncRunStatus struct {
 	Conditions []GitOpsDeploymentSyncRunCondition `json:"conditions,omitempty"`
+
+	// Phase is the phase of the sync, once it has completed: one of Succeeded, Failed, or Error
+	Phase string `json:"phase,omitempty"`
+
+	// Message is a human-readable description of the result of the sync
+	Message string `json:"message,omitempty"`
+
+	// PruningRequired is the number of resources that must be pruned before the GitOpsDeployment can be considered synced
+	PruningRequired int `json:"pruningRequired,omitempty"`
 }
 
 //+kubebuilder:object:root=true
@@ -65,6 +74,11 @@ type SyncRunReasonType string
 
 const (
 	SyncRunReasonErrorOccurred GitOpsDeploymentReasonType = "ErrorOccurred"
+
+	// SyncRunReasonEventProcessingFailed is the reason of the ErrorOccurred condition when an event for the
+	// GitOpsDeploymentSyncRun could not be processed, after all retries.
+	SyncRunReasonEventProcessingFailed   SyncRunReasonType = "EventProcessingFailed"
+	SyncRunReasonEventProcessingResolved SyncRunReasonType = "EventProcessingResolved"
 )
 
 // GitOpsDeploymentConditionType represents type of GitOpsDeployment condition.
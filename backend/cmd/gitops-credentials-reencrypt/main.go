@@ -0,0 +1,56 @@
+package main
+
+// gitops-credentials-reencrypt encrypts the secret fields of all ClusterCredentials with the active encryption key
+// (see 'db.ClusterCredentialsKeyring'). This is used after the active key is rotated, and after encryption is first
+// enabled, to encrypt the values that were stored unencrypted.
+//
+// The keys are read from the directory in the CLUSTER_CREDENTIALS_ENCRYPTION_KEYS_DIR environment variable, which
+// must contain both the active key and the keys that the existing values were encrypted with.
+
+import (
+	"context"
+	"flag"
+	"os"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	"github.com/redhat-appstudio/managed-gitops/backend-shared/config/db"
+)
+
+var log = ctrl.Log.WithName("gitops-credentials-reencrypt")
+
+func main() {
+	opts := zap.Options{}
+	opts.BindFlags(flag.CommandLine)
+	flag.Parse()
+
+	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
+
+	os.Exit(run(context.Background()))
+}
+
+func run(ctx context.Context) int {
+
+	if os.Getenv(db.EnvClusterCredentialsEncryptionKeysDir) == "" {
+		log.Error(nil, db.EnvClusterCredentialsEncryptionKeysDir+" must be set")
+		return 1
+	}
+
+	dbQueries, err := db.NewProductionPostgresDBQueries(false)
+	if err != nil {
+		log.Error(err, "unable to connect to database")
+		return 1
+	}
+	defer dbQueries.CloseDatabase()
+
+	updated, err := dbQueries.ReencryptAllClusterCredentials(ctx)
+	if err != nil {
+		log.Error(err, "unable to re-encrypt cluster credentials", "updated", updated)
+		return 1
+	}
+
+	log.Info("re-encrypted cluster credentials", "updated", updated)
+
+	return 0
+}
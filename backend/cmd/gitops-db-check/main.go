@@ -0,0 +1,103 @@
+package main
+
+// gitops-db-check detects (and optionally repairs) inconsistencies between the database, the workspace CRs, and the
+// Argo CD Applications of the GitOps engine instances: see 'eventloop.ConsistencyChecker'.
+//
+// The report is written to stdout as JSON. By default, this is a dry run: use '--repair' to repair the inconsistencies.
+//
+// Exit status:
+// - 0: no inconsistencies remain
+// - 1: the check could not be performed
+// - 2: inconsistencies were found, and not repaired
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
+	_ "k8s.io/client-go/plugin/pkg/client/auth"
+
+	managedgitopsv1alpha1operation "github.com/redhat-appstudio/managed-gitops/backend-shared/apis/managed-gitops/v1alpha1"
+	"github.com/redhat-appstudio/managed-gitops/backend-shared/config/db"
+	sharedutil "github.com/redhat-appstudio/managed-gitops/backend-shared/util"
+	managedgitopsv1alpha1 "github.com/redhat-appstudio/managed-gitops/backend/apis/managed-gitops/v1alpha1"
+	"github.com/redhat-appstudio/managed-gitops/backend/eventloop"
+)
+
+var (
+	scheme = runtime.NewScheme()
+	log    = ctrl.Log.WithName("gitops-db-check")
+)
+
+func init() {
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(managedgitopsv1alpha1operation.AddToScheme(scheme))
+	utilruntime.Must(managedgitopsv1alpha1.AddToScheme(scheme))
+}
+
+func main() {
+	var repair bool
+	flag.BoolVar(&repair, "repair", false, "Repair the inconsistencies that were found. If false, no changes are made (dry run).")
+	opts := zap.Options{}
+	opts.BindFlags(flag.CommandLine)
+	flag.Parse()
+
+	// Logs are written to stderr, so that stdout contains only the report
+	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
+
+	os.Exit(run(context.Background(), repair))
+}
+
+func run(ctx context.Context, repair bool) int {
+
+	restConfig, err := sharedutil.GetRESTConfig()
+	if err != nil {
+		log.Error(err, "unable to get kubeconfig")
+		return 1
+	}
+
+	workspaceClient, err := client.New(restConfig, client.Options{Scheme: scheme})
+	if err != nil {
+		log.Error(err, "unable to create client")
+		return 1
+	}
+
+	dbQueries, err := db.NewProductionPostgresDBQueries(false)
+	if err != nil {
+		log.Error(err, "unable to connect to database")
+		return 1
+	}
+	defer dbQueries.CloseDatabase()
+
+	report, err := eventloop.NewConsistencyChecker(dbQueries, workspaceClient).Check(ctx, repair, log)
+	if err != nil {
+		log.Error(err, "unable to check database consistency")
+		return 1
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		log.Error(err, "unable to write report")
+		return 1
+	}
+
+	if len(report.Errors) > 0 {
+		return 1
+	}
+
+	if report.UnrepairedFindings() > 0 {
+		return 2
+	}
+
+	return 0
+}
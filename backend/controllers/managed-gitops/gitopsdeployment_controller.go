@@ -56,6 +56,11 @@ func (r *GitOpsDeploymentReconciler) Reconcile(ctx context.Context, req ctrl.Req
 		return ctrl.Result{}, err
 	}
 
+	// In sharding mode, the events of the workspace are processed by the replica that owns it
+	if !r.PreprocessEventLoop.OwnsWorkspace(string(namespace.UID)) {
+		return ctrl.Result{}, nil
+	}
+
 	r.PreprocessEventLoop.EventReceived(req, managedgitopsv1alpha1.GitOpsDeploymentTypeName, r.Client, eventloop.DeploymentModified, string(namespace.UID))
 
 	return ctrl.Result{}, nil
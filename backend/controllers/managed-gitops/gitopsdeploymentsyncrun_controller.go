@@ -58,6 +58,11 @@ func (r *GitOpsDeploymentSyncRunReconciler) Reconcile(ctx context.Context, req c
 		return ctrl.Result{}, err
 	}
 
+	// In sharding mode, the events of the workspace are processed by the replica that owns it
+	if !r.PreprocessEventLoop.OwnsWorkspace(string(namespace.UID)) {
+		return ctrl.Result{}, nil
+	}
+
 	r.PreprocessEventLoop.EventReceived(req, managedgitopsv1alpha1.GitOpsDeploymentSyncRunTypeName, r.Client, eventloop.SyncRunModified, string(namespace.UID))
 
 	return ctrl.Result{}, nil
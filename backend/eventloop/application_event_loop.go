@@ -7,6 +7,7 @@ import (
 
 	"github.com/go-logr/logr"
 	operation "github.com/redhat-appstudio/managed-gitops/backend-shared/apis/managed-gitops/v1alpha1"
+	db "github.com/redhat-appstudio/managed-gitops/backend-shared/config/db"
 	sharedutil "github.com/redhat-appstudio/managed-gitops/backend-shared/util"
 	managedgitopsv1alpha1 "github.com/redhat-appstudio/managed-gitops/backend/apis/managed-gitops/v1alpha1"
 	corev1 "k8s.io/api/core/v1"
@@ -22,10 +23,41 @@ var (
 	deploymentStatusTickRate = 15 * time.Second
 )
 
+// startApplicationEventQueueLoop starts the goroutine that handles all events for a single GitOpsDeployment: the
+// goroutine terminates (closing the 'terminated' channel of the returned applicationEventLoop) once the GitOpsDeployment
+// no longer exists, or once the workspace is no longer owned by this replica (see 'ownsWorkspace').
+func startApplicationEventQueueLoop(gitopsDeplID string, workspaceID string, sharedResourceEventLoop *sharedResourceEventLoop,
+	dbQueries db.DatabaseQueries, ownsWorkspace workspaceOwnershipCheck) applicationEventLoop {
+
+	res := applicationEventLoop{
+		input:      make(chan applicationEventLoopMessage),
+		terminated: make(chan struct{}),
+	}
+
+	go func() {
+		defer close(res.terminated)
+
+		eventLoopGoroutines.WithLabelValues(eventLoopGoroutine_Application).Inc()
+		defer eventLoopGoroutines.WithLabelValues(eventLoopGoroutine_Application).Dec()
+
+		applicationEventQueueLoop(res.input, gitopsDeplID, workspaceID, sharedResourceEventLoop, dbQueries, ownsWorkspace)
+	}()
+
+	return res
+}
+
+// applicationEventQueueLoop processes the events of the GitOpsDeployment, until it no longer exists.
+//
+// In sharding mode, the loop also ends once the workspace is no longer owned by this replica: it is checked on every
+// message, which includes the periodic status ticks. From then on, new events are dropped, the waiting events are
+// discarded, and the active events are superseded (so that they are not retried), as the events of the workspace are
+// now processed by the new owner. The loop ends once the runners have completed their active events.
 func applicationEventQueueLoop(input chan applicationEventLoopMessage, gitopsDeplID string, workspaceID string,
-	sharedResourceEventLoop *sharedResourceEventLoop) {
+	sharedResourceEventLoop *sharedResourceEventLoop, dbQueries db.DatabaseQueries, ownsWorkspace workspaceOwnershipCheck) {
 
-	ctx := context.Background()
+	// The context is cancelled when the loop ends, which stops the status update timer
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
 	log := log.FromContext(ctx).WithValues("workspaceID", workspaceID).WithValues("gitOpsDeplID", gitopsDeplID)
 
@@ -34,22 +66,33 @@ func applicationEventQueueLoop(input chan applicationEventLoopMessage, gitopsDep
 
 	// Only one deployment event is processed at a time
 	var activeDeploymentEvent *eventLoopEvent
-	waitingDeploymentEvents := []*eventLoopEvent{}
+	waitingDeploymentEvents := newApplicationEventQueue("deployment")
+	defer waitingDeploymentEvents.clear()
 
 	// Only one sync operation event is processed at a time
 	// For example: if the user created multiple GitOpsDeploymentSyncRun CRs, they will be processed to completion, one at a time.
 	var activeSyncOperationEvent *eventLoopEvent
-	waitingSyncOperationEvents := []*eventLoopEvent{}
+	waitingSyncOperationEvents := newApplicationEventQueue("sync-operation")
+	defer waitingSyncOperationEvents.clear()
 
-	deploymentEventRunner := newApplicationEventLoopRunner(input, sharedResourceEventLoop, gitopsDeplID, workspaceID, "deployment")
+	deploymentEventRunner := newApplicationEventLoopRunner(input, sharedResourceEventLoop, dbQueries, gitopsDeplID, workspaceID, "deployment")
 	deploymentEventRunnerShutdown := false
 
-	syncOperationEventRunner := newApplicationEventLoopRunner(input, sharedResourceEventLoop, gitopsDeplID, workspaceID, "sync-operation")
+	syncOperationEventRunner := newApplicationEventLoopRunner(input, sharedResourceEventLoop, dbQueries, gitopsDeplID, workspaceID, "sync-operation")
 	syncOperationEventRunnerShutdown := false
 
+	// When the loop ends, neither runner has active work: closing the channels stops any runner that is still waiting
+	// for work (the sync operation runner is not informed of the shutdown by the deployment runner).
+	defer close(deploymentEventRunner)
+	defer close(syncOperationEventRunner)
+
 	// Start the ticker, which will -- every X seconds -- instruct the GitOpsDeployment CR fields to update
 	startNewStatusUpdateTimer(ctx, input, gitopsDeplID, log)
 
+	// statusUpdateTimerStopped is true if a status tick was dropped while the workspace was not owned, so that the
+	// timer was not restarted: it is restarted if the workspace is owned again, before the loop has ended.
+	statusUpdateTimerStopped := false
+
 	for {
 
 		// If both the runner signal that they have shutdown, then
@@ -72,17 +115,53 @@ func applicationEventQueueLoop(input chan applicationEventLoopMessage, gitopsDep
 			continue
 		}
 
-		// If we've received a new event from the workspace event loop
-		if newEvent.messageType == applicationEventLoopMessageType_Event {
+		workspaceOwned := ownsWorkspace(workspaceID)
+
+		if !workspaceOwned {
+
+			// The events are now processed by the new owner of the workspace
+			for _, activeEvent := range []*eventLoopEvent{activeDeploymentEvent, activeSyncOperationEvent} {
+				if activeEvent != nil {
+					activeEvent.markSuperseded()
+				}
+			}
+			waitingDeploymentEvents.clear()
+			waitingSyncOperationEvents.clear()
+
+		} else if statusUpdateTimerStopped {
+			statusUpdateTimerStopped = false
+			startNewStatusUpdateTimer(ctx, input, gitopsDeplID, log)
+		}
+
+		if newEvent.messageType == applicationEventLoopMessageType_Event && !workspaceOwned {
+
+			log.V(sharedutil.LogLevel_Debug).Info("Dropped event, as the workspace is not owned by this replica", "event", stringEventLoopEvent(newEvent.event))
+			if newEvent.event.eventType == UpdateDeploymentStatusTick {
+				statusUpdateTimerStopped = true
+			}
+
+			// If we've received a new event from the workspace event loop
+		} else if newEvent.messageType == applicationEventLoopMessageType_Event {
 
 			log := log.WithValues("event", stringEventLoopEvent(newEvent.event))
 
 			log.V(sharedutil.LogLevel_Debug).Info("applicationEventQueueLoop received event")
 
+			reportEventReceived(eventLoopStage_Application, newEvent.event)
+
+			// A runner that is retrying a failing event stops doing so, if the new event supersedes it
+			for _, activeEvent := range []*eventLoopEvent{activeDeploymentEvent, activeSyncOperationEvent} {
+				if activeEvent != nil && supersedesEvent(newEvent.event, activeEvent) {
+					activeEvent.markSuperseded()
+				}
+			}
+
 			if newEvent.event.reqResource == managedgitopsv1alpha1.GitOpsDeploymentTypeName {
 
 				if !deploymentEventRunnerShutdown {
-					waitingDeploymentEvents = append(waitingDeploymentEvents, newEvent.event)
+					if !waitingDeploymentEvents.add(newEvent.event) {
+						log.V(sharedutil.LogLevel_Debug).Info("Coalesced deployment event with waiting event")
+					}
 				} else {
 					log.V(sharedutil.LogLevel_Debug).Info("Ignoring post-shutdown deployment event")
 				}
@@ -90,14 +169,21 @@ func applicationEventQueueLoop(input chan applicationEventLoopMessage, gitopsDep
 			} else if newEvent.event.reqResource == managedgitopsv1alpha1.GitOpsDeploymentSyncRunTypeName {
 
 				if !syncOperationEventRunnerShutdown {
-					waitingSyncOperationEvents = append(waitingSyncOperationEvents, newEvent.event)
+					if !waitingSyncOperationEvents.add(newEvent.event) {
+						log.V(sharedutil.LogLevel_Debug).Info("Coalesced sync operation event with waiting event")
+					}
 				} else {
 					log.V(sharedutil.LogLevel_Debug).Info("Ignoring post-shutdown sync operation event")
 				}
 			} else if newEvent.event.eventType == UpdateDeploymentStatusTick {
 
 				if !deploymentEventRunnerShutdown {
-					waitingDeploymentEvents = append(waitingDeploymentEvents, newEvent.event)
+					if !waitingDeploymentEvents.add(newEvent.event) {
+						// The tick will not be processed, so it will not be followed by a work complete message:
+						// start the timer for the next tick now, instead.
+						log.V(sharedutil.LogLevel_Debug).Info("Dropped status tick, as a deployment event is waiting")
+						startNewStatusUpdateTimer(ctx, input, gitopsDeplID, log)
+					}
 				} else {
 					log.V(sharedutil.LogLevel_Debug).Info("Ignoring post-shutdown deployment event")
 				}
@@ -113,11 +199,19 @@ func applicationEventQueueLoop(input chan applicationEventLoopMessage, gitopsDep
 
 			log.V(sharedutil.LogLevel_Debug).Info("applicationEventQueueLoop received work complete event")
 
+			if newEvent.deadLettered && newEvent.event.eventType != UpdateDeploymentStatusTick && workspaceOwned {
+				startDeadLetterRequeueTimer(ctx, input, newEvent.event, log)
+			}
+
 			if newEvent.event.eventType == UpdateDeploymentStatusTick {
 				// After we finish processing a previous status tick, start the timer to queue up a new one.
 				// This ensures we are always reminded to do a status update.
 				activeDeploymentEvent = nil
-				startNewStatusUpdateTimer(ctx, input, gitopsDeplID, log)
+				if workspaceOwned {
+					startNewStatusUpdateTimer(ctx, input, gitopsDeplID, log)
+				} else {
+					statusUpdateTimerStopped = true
+				}
 
 			} else if newEvent.event.reqResource == managedgitopsv1alpha1.GitOpsDeploymentTypeName {
 
@@ -151,10 +245,10 @@ func applicationEventQueueLoop(input chan applicationEventLoopMessage, gitopsDep
 		}
 
 		// If we are not currently doing any deployment work, and there are events waiting, then send the next event to the runner
-		if len(waitingDeploymentEvents) > 0 && activeDeploymentEvent == nil && !deploymentEventRunnerShutdown {
+		if waitingDeploymentEvents.len() > 0 && activeDeploymentEvent == nil && !deploymentEventRunnerShutdown {
 
-			activeDeploymentEvent = waitingDeploymentEvents[0]
-			waitingDeploymentEvents = waitingDeploymentEvents[1:]
+			activeDeploymentEvent = waitingDeploymentEvents.pop()
+			activeDeploymentEvent.superseded = make(chan struct{})
 
 			// Send the work to the runner
 			log.V(sharedutil.LogLevel_Debug).Info("About to send work to depl event runner", "event", stringEventLoopEvent(activeDeploymentEvent))
@@ -164,10 +258,10 @@ func applicationEventQueueLoop(input chan applicationEventLoopMessage, gitopsDep
 		}
 
 		// If we are not currently doing any sync operation work, and there are events waiting, then send the next event to the runner
-		if len(waitingSyncOperationEvents) > 0 && activeSyncOperationEvent == nil && !syncOperationEventRunnerShutdown {
+		if waitingSyncOperationEvents.len() > 0 && activeSyncOperationEvent == nil && !syncOperationEventRunnerShutdown {
 
-			activeSyncOperationEvent = waitingSyncOperationEvents[0]
-			waitingSyncOperationEvents = waitingSyncOperationEvents[1:]
+			activeSyncOperationEvent = waitingSyncOperationEvents.pop()
+			activeSyncOperationEvent.superseded = make(chan struct{})
 
 			// Send the work to the runner
 			syncOperationEventRunner <- activeSyncOperationEvent
@@ -175,9 +269,16 @@ func applicationEventQueueLoop(input chan applicationEventLoopMessage, gitopsDep
 
 		}
 
+		// If the workspace is no longer owned, and the runners have completed their active events, then the loop ends
+		if !workspaceOwned && activeDeploymentEvent == nil && activeSyncOperationEvent == nil {
+			log.Info("Workspace is no longer owned by this replica: stopping the runners")
+			deploymentEventRunnerShutdown = true
+			syncOperationEventRunnerShutdown = true
+		}
+
 		// If the deployment runner has shutdown, and there are no active or waiting sync operation events,
 		// then it is safe to shut down the sync runner too.
-		if deploymentEventRunnerShutdown && len(waitingSyncOperationEvents) == 0 &&
+		if deploymentEventRunnerShutdown && waitingSyncOperationEvents.len() == 0 &&
 			activeSyncOperationEvent == nil && !syncOperationEventRunnerShutdown {
 
 			syncOperationEventRunnerShutdown = true
@@ -222,7 +323,14 @@ func startNewStatusUpdateTimer(ctx context.Context, input chan applicationEventL
 			}
 		}
 
-		<-statusUpdateTimer.C
+		select {
+		case <-statusUpdateTimer.C:
+		case <-ctx.Done():
+			statusUpdateTimer.Stop()
+			log.V(sharedutil.LogLevel_Debug).Info("Deployment status ticker cancelled, for " + gitopsDeplID)
+			return
+		}
+
 		tickMessage := applicationEventLoopMessage{
 			event: &eventLoopEvent{
 				eventType:               UpdateDeploymentStatusTick,
@@ -233,10 +341,72 @@ func startNewStatusUpdateTimer(ctx context.Context, input chan applicationEventL
 			messageType: applicationEventLoopMessageType_Event,
 		}
 		log.V(sharedutil.LogLevel_Debug).Info("Sending tick message for " + tickMessage.event.associatedGitopsDeplUID)
-		input <- tickMessage
+
+		// The application event loop may have ended while we were waiting, in which case nobody will read the message
+		select {
+		case input <- tickMessage:
+		case <-ctx.Done():
+			log.V(sharedutil.LogLevel_Debug).Info("Deployment status ticker cancelled, for " + gitopsDeplID)
+		}
+	}()
+}
+
+// startDeadLetterRequeueTimer sends a dead-lettered event back to the application event loop, after
+// 'applicationEventDeadLetterRequeueInterval' (doubled for each previous requeue of the event, up to
+// 'applicationEventDeadLetterRequeueMaxInterval'): the event is then processed again, so that its CR is eventually
+// reconciled once the cause of the failure is resolved, even if no newer event is received for the CR.
+//
+// Once the event has been requeued 'applicationEventDeadLetterMaxRequeues' times, it is no longer requeued: the
+// ErrorOccurred condition of the CR remains set, until a newer event for the CR is processed.
+func startDeadLetterRequeueTimer(ctx context.Context, input chan applicationEventLoopMessage, event *eventLoopEvent, log logr.Logger) {
+
+	if event.deadLetterRequeues >= applicationEventDeadLetterMaxRequeues {
+		applicationEventsDeadLetterRequeuesExhausted.WithLabelValues(string(event.eventType)).Inc()
+		log.Error(nil, "Dead-lettered event has reached the maximum number of requeues, and will not be requeued again",
+			"event", stringEventLoopEvent(event), "requeues", event.deadLetterRequeues)
+		return
+	}
+
+	requeuedEvent := *event
+	requeuedEvent.superseded = nil
+	requeuedEvent.deadLetterRequeues++
+
+	requeueTimer := time.NewTimer(deadLetterRequeueInterval(event.deadLetterRequeues))
+	go func() {
+
+		select {
+		case <-requeueTimer.C:
+		case <-ctx.Done():
+			requeueTimer.Stop()
+			return
+		}
+
+		log.Info("Requeuing dead-lettered event", "event", stringEventLoopEvent(&requeuedEvent), "requeue", requeuedEvent.deadLetterRequeues)
+
+		// The application event loop may have ended while we were waiting, in which case nobody will read the message
+		select {
+		case input <- applicationEventLoopMessage{event: &requeuedEvent, messageType: applicationEventLoopMessageType_Event}:
+		case <-ctx.Done():
+		}
 	}()
 }
 
+// deadLetterRequeueInterval returns how long to wait before requeuing a dead-lettered event, which has previously been
+// requeued 'previousRequeues' times.
+func deadLetterRequeueInterval(previousRequeues int) time.Duration {
+
+	interval := applicationEventDeadLetterRequeueInterval
+	for i := 0; i < previousRequeues && interval < applicationEventDeadLetterRequeueMaxInterval; i++ {
+		interval *= 2
+	}
+
+	if interval > applicationEventDeadLetterRequeueMaxInterval {
+		interval = applicationEventDeadLetterRequeueMaxInterval
+	}
+
+	return interval
+}
+
 func getK8sClientForWorkspace() (client.Client, error) {
 
 	config, err := sharedutil.GetRESTConfig()
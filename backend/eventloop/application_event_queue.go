@@ -0,0 +1,105 @@
+package eventloop
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	// applicationEventQueueDepth is the number of events waiting to be processed, across all the application event loops.
+	applicationEventQueueDepth = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "gitops_application_event_queue_depth",
+		Help: "Number of events waiting to be processed by the application event runners",
+	}, []string{"queue"})
+
+	// applicationEventsCoalesced is the number of events that were not queued, because an equivalent event was already waiting.
+	applicationEventsCoalesced = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gitops_application_events_coalesced_total",
+		Help: "Number of events that were coalesced with an equivalent event that was already waiting to be processed",
+	}, []string{"queue"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(applicationEventQueueDepth, applicationEventsCoalesced)
+}
+
+// applicationEventQueue contains the events that are waiting to be processed by an application event runner, in the
+// order they were received.
+//
+// Events are coalesced: each event handler acts on the current state of the resource (rather than on the contents
+// of the event), so an event is not queued if an event of the same type for the same resource is already waiting.
+// Likewise, a status tick is not queued if a deployment event is already waiting.
+//
+// Note that the event that is currently being processed is not part of the queue: a new event is still queued, as the
+// resource may have changed since its processing began.
+type applicationEventQueue struct {
+	// name is the name of the queue in metrics, for example 'deployment' or 'sync-operation'.
+	name string
+
+	events []*eventLoopEvent
+}
+
+func newApplicationEventQueue(name string) *applicationEventQueue {
+	return &applicationEventQueue{
+		name:   name,
+		events: []*eventLoopEvent{},
+	}
+}
+
+// add queues the event, and returns false if it was instead coalesced with an event that is already waiting.
+func (queue *applicationEventQueue) add(event *eventLoopEvent) bool {
+
+	for _, waitingEvent := range queue.events {
+
+		if isEquivalentEvent(waitingEvent, event) ||
+			(event.eventType == UpdateDeploymentStatusTick && waitingEvent.eventType == DeploymentModified) {
+
+			applicationEventsCoalesced.WithLabelValues(queue.name).Inc()
+			return false
+		}
+	}
+
+	queue.events = append(queue.events, event)
+	applicationEventQueueDepth.WithLabelValues(queue.name).Inc()
+
+	return true
+}
+
+// pop removes and returns the oldest event of the queue, or nil if the queue is empty.
+func (queue *applicationEventQueue) pop() *eventLoopEvent {
+
+	if len(queue.events) == 0 {
+		return nil
+	}
+
+	event := queue.events[0]
+	queue.events = queue.events[1:]
+	applicationEventQueueDepth.WithLabelValues(queue.name).Dec()
+
+	return event
+}
+
+func (queue *applicationEventQueue) len() int {
+	return len(queue.events)
+}
+
+// clear removes all the events of the queue: for example, when the application event loop ends.
+func (queue *applicationEventQueue) clear() {
+	applicationEventQueueDepth.WithLabelValues(queue.name).Sub(float64(len(queue.events)))
+	queue.events = []*eventLoopEvent{}
+}
+
+// isEquivalentEvent returns true if both events are of the same type, for the same resource.
+func isEquivalentEvent(a *eventLoopEvent, b *eventLoopEvent) bool {
+	return a.eventType == b.eventType &&
+		a.reqResource == b.reqResource &&
+		a.request.NamespacedName == b.request.NamespacedName &&
+		a.associatedGitopsDeplUID == b.associatedGitopsDeplUID
+}
+
+// supersedesEvent returns true if the newer event makes the retrying of the active (failing) event unnecessary: each event
+// handler acts on the current state of the resource, so the newer event applies any change the active event would have.
+func supersedesEvent(newer *eventLoopEvent, active *eventLoopEvent) bool {
+	return isEquivalentEvent(newer, active) ||
+		(active.eventType == UpdateDeploymentStatusTick && newer.eventType == DeploymentModified)
+}
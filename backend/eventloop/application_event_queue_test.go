@@ -0,0 +1,95 @@
+package eventloop
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	managedgitopsv1alpha1 "github.com/redhat-appstudio/managed-gitops/backend/apis/managed-gitops/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestApplicationEventQueue(t *testing.T) {
+
+	newEvent := func(eventType EventLoopEventType, reqResource managedgitopsv1alpha1.GitOpsResourceType, name string) *eventLoopEvent {
+		return &eventLoopEvent{
+			eventType:               eventType,
+			request:                 reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "my-namespace", Name: name}},
+			reqResource:             reqResource,
+			associatedGitopsDeplUID: "my-gitops-depl-uid",
+		}
+	}
+
+	newTick := func() *eventLoopEvent {
+		return &eventLoopEvent{eventType: UpdateDeploymentStatusTick, associatedGitopsDeplUID: "my-gitops-depl-uid"}
+	}
+
+	t.Run("Equivalent events are coalesced, and other events are queued in order", func(t *testing.T) {
+
+		queue := newApplicationEventQueue("test-equivalent")
+
+		first := newEvent(SyncRunModified, managedgitopsv1alpha1.GitOpsDeploymentSyncRunTypeName, "first-sync-run")
+		second := newEvent(SyncRunModified, managedgitopsv1alpha1.GitOpsDeploymentSyncRunTypeName, "second-sync-run")
+
+		assert.True(t, queue.add(first))
+		assert.True(t, queue.add(second))
+		assert.False(t, queue.add(newEvent(SyncRunModified, managedgitopsv1alpha1.GitOpsDeploymentSyncRunTypeName, "first-sync-run")))
+		assert.False(t, queue.add(newEvent(SyncRunModified, managedgitopsv1alpha1.GitOpsDeploymentSyncRunTypeName, "second-sync-run")))
+
+		assert.Equal(t, 2, queue.len())
+		assert.Equal(t, float64(2), testutil.ToFloat64(applicationEventQueueDepth.WithLabelValues("test-equivalent")))
+		assert.Equal(t, float64(2), testutil.ToFloat64(applicationEventsCoalesced.WithLabelValues("test-equivalent")))
+
+		assert.Same(t, first, queue.pop())
+
+		// The event being processed is no longer in the queue, so an equivalent event is queued
+		assert.True(t, queue.add(newEvent(SyncRunModified, managedgitopsv1alpha1.GitOpsDeploymentSyncRunTypeName, "first-sync-run")))
+
+		assert.Same(t, second, queue.pop())
+		assert.Equal(t, "first-sync-run", queue.pop().request.Name)
+		assert.Nil(t, queue.pop())
+		assert.Equal(t, float64(0), testutil.ToFloat64(applicationEventQueueDepth.WithLabelValues("test-equivalent")))
+	})
+
+	t.Run("Status ticks are dropped while a deployment event is waiting", func(t *testing.T) {
+
+		queue := newApplicationEventQueue("test-ticks")
+
+		assert.True(t, queue.add(newTick()))
+		assert.False(t, queue.add(newTick()))
+
+		deploymentEvent := newEvent(DeploymentModified, managedgitopsv1alpha1.GitOpsDeploymentTypeName, "my-gitops-depl")
+		assert.True(t, queue.add(deploymentEvent))
+		assert.Equal(t, UpdateDeploymentStatusTick, queue.pop().eventType)
+
+		assert.False(t, queue.add(newTick()))
+
+		assert.Same(t, deploymentEvent, queue.pop())
+		assert.True(t, queue.add(newTick()))
+
+		queue.clear()
+		assert.Equal(t, 0, queue.len())
+		assert.Equal(t, float64(0), testutil.ToFloat64(applicationEventQueueDepth.WithLabelValues("test-ticks")))
+	})
+
+	t.Run("Newer events supersede an active event for the same resource", func(t *testing.T) {
+
+		active := newEvent(DeploymentModified, managedgitopsv1alpha1.GitOpsDeploymentTypeName, "my-gitops-depl")
+		assert.True(t, supersedesEvent(newEvent(DeploymentModified, managedgitopsv1alpha1.GitOpsDeploymentTypeName, "my-gitops-depl"), active))
+		assert.False(t, supersedesEvent(newEvent(DeploymentModified, managedgitopsv1alpha1.GitOpsDeploymentTypeName, "other-gitops-depl"), active))
+		assert.False(t, supersedesEvent(newTick(), active))
+
+		assert.True(t, supersedesEvent(active, newTick()))
+
+		// Only the event loop that sent the event to a runner can mark it as superseded
+		assert.False(t, active.isSuperseded())
+		active.markSuperseded()
+		assert.False(t, active.isSuperseded())
+
+		active.superseded = make(chan struct{})
+		active.markSuperseded()
+		active.markSuperseded()
+		assert.True(t, active.isSuperseded())
+	})
+}
@@ -2,11 +2,13 @@ package eventloop
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
 
 	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
 	operation "github.com/redhat-appstudio/managed-gitops/backend-shared/apis/managed-gitops/v1alpha1"
 	db "github.com/redhat-appstudio/managed-gitops/backend-shared/config/db"
 	dbutil "github.com/redhat-appstudio/managed-gitops/backend-shared/config/db/util"
@@ -17,22 +19,70 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	apierr "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
 )
 
 // For more information on how events are distributed between goroutines by event loop, see:
 // https://miro.com/app/board/o9J_lgiqJAs=/?moveToWidget=3458764514216218600&cot=14
 
+var (
+	// applicationEventDeadline is the maximum amount of time spent processing a single event, including all retries:
+	// once it has passed, the event is dead-lettered.
+	applicationEventDeadline = 10 * time.Minute
+
+	// applicationEventMaxAttempts is the number of times a failing event is processed, before it is dead-lettered.
+	applicationEventMaxAttempts = 10
+
+	// applicationEventDeadLetterRequeueInterval is how long after a GitOpsDeployment or GitOpsDeploymentSyncRun event is
+	// first dead-lettered, before it is processed again: the interval is doubled each time the event is dead-lettered
+	// again, up to 'applicationEventDeadLetterRequeueMaxInterval'. See 'startDeadLetterRequeueTimer'.
+	applicationEventDeadLetterRequeueInterval = 5 * time.Minute
+
+	// applicationEventDeadLetterRequeueMaxInterval is the maximum interval between the requeues of a dead-lettered event.
+	applicationEventDeadLetterRequeueMaxInterval = 1 * time.Hour
+
+	// applicationEventDeadLetterMaxRequeues is the number of times a dead-lettered event is requeued: after that, the
+	// event is only processed again once a newer event is received for its CR.
+	applicationEventDeadLetterMaxRequeues = 10
+)
+
+var (
+	// applicationEventsDeadLettered is the number of events that could not be processed, after all retries.
+	applicationEventsDeadLettered = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gitops_application_events_dead_lettered_total",
+		Help: "Number of events that could not be processed by the application event runners, after all retries",
+	}, []string{"event_type"})
+
+	// applicationEventsDeadLetterRequeuesExhausted is the number of dead-lettered events that are no longer requeued,
+	// as they have been requeued 'applicationEventDeadLetterMaxRequeues' times.
+	applicationEventsDeadLetterRequeuesExhausted = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gitops_application_events_dead_letter_requeues_exhausted_total",
+		Help: "Number of dead-lettered events that are no longer requeued, as they reached the maximum number of requeues",
+	}, []string{"event_type"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(applicationEventsDeadLettered, applicationEventsDeadLetterRequeuesExhausted)
+}
+
+// applicationEventHandler makes a single attempt at processing an event, and returns true if the runner should shut down
+// (usually because the API CR no longer exists).
+type applicationEventHandler func(ctx context.Context, event *eventLoopEvent, log logr.Logger) (bool, error)
+
 func newApplicationEventLoopRunner(informWorkCompleteChan chan applicationEventLoopMessage, sharedResourceEventLoop *sharedResourceEventLoop,
-	gitopsDeplUID string, workspaceID string, debugContext string) chan *eventLoopEvent {
+	dbQueries db.DatabaseQueries, gitopsDeplUID string, workspaceID string, debugContext string) chan *eventLoopEvent {
 
 	inputChannel := make(chan *eventLoopEvent)
 
+	handleEvent := func(ctx context.Context, event *eventLoopEvent, log logr.Logger) (bool, error) {
+		return handleApplicationEvent(ctx, event, sharedResourceEventLoop, dbQueries, workspaceID, log)
+	}
+
 	go func() {
-		applicationEventLoopRunner(inputChannel, informWorkCompleteChan, sharedResourceEventLoop, gitopsDeplUID, workspaceID, debugContext)
+		applicationEventLoopRunner(inputChannel, informWorkCompleteChan, handleEvent, gitopsDeplUID, workspaceID, debugContext)
 	}()
 
 	return inputChannel
@@ -40,7 +90,7 @@ func newApplicationEventLoopRunner(informWorkCompleteChan chan applicationEventL
 }
 
 func applicationEventLoopRunner(inputChannel chan *eventLoopEvent, informWorkCompleteChan chan applicationEventLoopMessage,
-	sharedResourceEventLoop *sharedResourceEventLoop, gitopsDeplUID string, workspaceID string, debugContext string) {
+	handleEvent applicationEventHandler, gitopsDeplUID string, workspaceID string, debugContext string) {
 
 	outerContext := context.Background()
 	log := log.FromContext(outerContext)
@@ -51,99 +101,277 @@ func applicationEventLoopRunner(inputChannel chan *eventLoopEvent, informWorkCom
 
 	signalledShutdown := false
 
+	failureConditions := applicationEventFailureConditions{}
+
 	for {
 		// Read from input channel: wait for an event on this application
-		newEvent := <-inputChannel
-
-		ctx, cancel := context.WithCancel(outerContext)
-
-		defer cancel()
+		newEvent, ok := <-inputChannel
+		if !ok {
+			// The application event loop has ended
+			break
+		}
 
 		// Process the event
 
 		log.V(sharedutil.LogLevel_Debug).Info("applicationEventLoopRunner - event received", "event", stringEventLoopEvent(newEvent))
 
-		attempts := 1
-		backoff := sharedutil.ExponentialBackoff{Min: time.Duration(100 * time.Millisecond), Max: time.Duration(15 * time.Second), Factor: 2, Jitter: true}
-	inner_for:
-		for {
+		var deadLettered bool
+		signalledShutdown, deadLettered = processApplicationEvent(outerContext, newEvent, handleEvent, failureConditions, log)
+
+		// Inform the caller that we have completed a single unit of work
+		informWorkCompleteChan <- applicationEventLoopMessage{messageType: applicationEventLoopMessageType_WorkComplete, event: newEvent,
+			shutdownSignalled: signalledShutdown, deadLettered: deadLettered}
+
+		// If the event processing logic concluded that the goroutine should shutdown, then break out of the outer for loop.
+		// This is usually because the API CR no longer exists.
+		if signalledShutdown {
+			break
+		}
+	}
 
-			log.V(sharedutil.LogLevel_Debug).Info("applicationEventLoopRunner - processing event", "event", stringEventLoopEvent(newEvent), "attempt", attempts)
+	log.Info("ApplicationEventLoopRunner goroutine terminated.", "signalledShutdown", signalledShutdown)
+}
 
-			// Break if the request is cancelled, or the timeout expires
+// processApplicationEvent processes an event, retrying it on failure, and returns true if the runner should shut down,
+// and whether the event was dead-lettered.
+//
+// A failing event is retried until it is superseded by a newer event for the same resource (which is then processed
+// next, instead), or until it has failed 'applicationEventMaxAttempts' times or 'applicationEventDeadline' has passed:
+// the event is then dead-lettered, so that the events that follow it are not starved. The application event loop
+// processes a dead-lettered event again later, see 'startDeadLetterRequeueTimer'.
+func processApplicationEvent(outerContext context.Context, event *eventLoopEvent, handleEvent applicationEventHandler,
+	failureConditions applicationEventFailureConditions, log logr.Logger) (bool, bool) {
+
+	ctx, cancel := context.WithTimeout(outerContext, applicationEventDeadline)
+	defer cancel()
+
+	start := time.Now()
+	defer reportEventProcessed(eventLoopStage_Application, event, start)
+
+	backoff := sharedutil.ExponentialBackoff{Min: time.Duration(100 * time.Millisecond), Max: time.Duration(15 * time.Second), Factor: 2, Jitter: true}
+
+	for attempts := 1; ; attempts++ {
+
+		log.V(sharedutil.LogLevel_Debug).Info("applicationEventLoopRunner - processing event", "event", stringEventLoopEvent(event), "attempt", attempts)
+
+		signalledShutdown := false
+		_, err := sharedutil.CatchPanic(func() error {
+			var err error
+			signalledShutdown, err = handleEvent(ctx, event, log)
+			return err
+		})
+
+		if err == nil {
+			if signalledShutdown {
+				failureConditions.forget(event)
+
+			} else if failureConditions.mayBeSet(event) {
+				// A previous event for the resource may have been dead-lettered: its failure is no longer relevant
+				if reportApplicationEventFailure(outerContext, event, nil, log) {
+					failureConditions.set(event, false)
+				}
+			}
+			return signalledShutdown, false
+		}
+
+		log.Error(err, "error from inner event handler in applicationEventLoopRunner", "event", stringEventLoopEvent(event), "attempt", attempts)
+
+		if attempts >= applicationEventMaxAttempts {
+			deadLetterApplicationEvent(outerContext, event, attempts, err, log)
+			failureConditions.set(event, true)
+			return false, true
+		}
+
+		// Wait before the next attempt, unless the event is superseded (or the deadline passes) in the meantime
+		waitContext, waitCancel := context.WithCancel(ctx)
+		go func() {
 			select {
-			case <-ctx.Done():
-				break inner_for
-			default:
+			case <-event.superseded:
+				waitCancel()
+			case <-waitContext.Done():
 			}
+		}()
+		backoff.DelayOnFail(waitContext)
+		waitCancel()
 
-			_, err := sharedutil.CatchPanic(func() error {
+		if event.isSuperseded() {
+			log.Info("Failing event was superseded by a newer event, and will not be retried", "event", stringEventLoopEvent(event), "attempt", attempts)
+			return false, false
+		}
 
-				action := applicationEventLoopRunner_Action{
-					getK8sClientForGitOpsEngineInstance: actionGetK8sClientForGitOpsEngineInstance,
-					eventResourceName:                   newEvent.request.Name,
-					eventResourceNamespace:              newEvent.request.Namespace,
-					workspaceClient:                     newEvent.client,
-					sharedResourceEventLoop:             sharedResourceEventLoop,
-					log:                                 log,
-					workspaceID:                         workspaceID,
-				}
+		if ctx.Err() != nil {
+			deadLetterApplicationEvent(outerContext, event, attempts,
+				fmt.Errorf("deadline of %v exceeded, last error: %v", applicationEventDeadline, err), log)
+			failureConditions.set(event, true)
+			return false, true
+		}
 
-				var err error
+		reportEventRetried(eventLoopStage_Application, event)
+	}
+}
 
-				dbQueriesUnscoped, err := db.NewProductionPostgresDBQueries(false)
-				if err != nil {
-					return fmt.Errorf("unable to access database in workspaceEventLoopRunner: %v", err)
-				}
+// handleApplicationEvent makes a single attempt at processing an event, by calling the handler for the event type.
+func handleApplicationEvent(ctx context.Context, newEvent *eventLoopEvent, sharedResourceEventLoop *sharedResourceEventLoop,
+	dbQueries db.DatabaseQueries, workspaceID string, log logr.Logger) (bool, error) {
 
-				scopedDBQueries, ok := dbQueriesUnscoped.(db.ApplicationScopedQueries)
-				if !ok {
-					return fmt.Errorf("SEVERE: unexpected cast failure")
-				}
+	action := applicationEventLoopRunner_Action{
+		getK8sClientForGitOpsEngineInstance: actionGetK8sClientForGitOpsEngineInstance,
+		eventResourceName:                   newEvent.request.Name,
+		eventResourceNamespace:              newEvent.request.Namespace,
+		workspaceClient:                     newEvent.client,
+		sharedResourceEventLoop:             sharedResourceEventLoop,
+		log:                                 log,
+		workspaceID:                         workspaceID,
+	}
 
-				if newEvent.eventType == DeploymentModified {
-					// Handle all GitOpsDeployment related events
-					signalledShutdown, _, _, err = action.applicationEventRunner_handleDeploymentModified(ctx, scopedDBQueries)
+	scopedDBQueries, ok := dbQueries.(db.ApplicationScopedQueries)
+	if !ok {
+		return false, fmt.Errorf("SEVERE: unexpected cast failure")
+	}
 
-					// TODO: GITOPS-1719 - Update the GitOpsDeployment CR's status conditions, based on err.
+	var err error
+	signalledShutdown := false
 
-				} else if newEvent.eventType == SyncRunModified {
-					// Handle all SyncRun related events
-					signalledShutdown, err = action.applicationEventRunner_handleSyncRunModified(ctx, scopedDBQueries)
+	if newEvent.eventType == DeploymentModified {
+		// Handle all GitOpsDeployment related events
+		signalledShutdown, _, _, err = action.applicationEventRunner_handleDeploymentModified(ctx, scopedDBQueries)
 
-				} else if newEvent.eventType == UpdateDeploymentStatusTick {
-					err = action.applicationEventRunner_handleUpdateDeploymentStatusTick(ctx, newEvent.associatedGitopsDeplUID, scopedDBQueries)
+	} else if newEvent.eventType == SyncRunModified {
+		// Handle all SyncRun related events
+		signalledShutdown, err = action.applicationEventRunner_handleSyncRunModified(ctx, scopedDBQueries)
 
-				} else {
-					log.Error(nil, "SEVERE: Unrecognized event type", "event type", newEvent.eventType)
-				}
+	} else if newEvent.eventType == UpdateDeploymentStatusTick {
+		err = action.applicationEventRunner_handleUpdateDeploymentStatusTick(ctx, newEvent.associatedGitopsDeplUID, scopedDBQueries)
 
-				// TODO: GITOPS-1582: Implement detection of workspace/api proxy delete, here, and handle cleanup
+	} else {
+		log.Error(nil, "SEVERE: Unrecognized event type", "event type", newEvent.eventType)
+	}
 
-				return err
+	// Workspace deletion is not detected here: see 'WorkspaceTeardownRunner', which tears down the resources of the
+	// workspace once its namespace has been deleted.
 
-			})
+	return signalledShutdown, err
+}
 
-			if err == nil {
-				break inner_for
-			} else {
-				log.Error(err, "error from inner event handler in applicationEventLoopRunner", "event", stringEventLoopEvent(newEvent))
-				backoff.DelayOnFail(ctx)
-				attempts++
+// deadLetterApplicationEvent records an event that could not be processed after all retries: the event is logged and
+// counted, and the failure is reported in the status conditions of the event's CR. The event is processed again after
+// 'applicationEventDeadLetterRequeueInterval', unless it is a status tick.
+func deadLetterApplicationEvent(ctx context.Context, event *eventLoopEvent, attempts int, err error, log logr.Logger) {
+
+	applicationEventsDeadLettered.WithLabelValues(string(event.eventType)).Inc()
+
+	log.Error(err, "Event could not be processed, and was dead-lettered", "event", stringEventLoopEvent(event), "attempts", attempts)
+
+	reportApplicationEventFailure(ctx, event, fmt.Errorf("unable to process event after %d attempts: %v", attempts, err), log)
+}
+
+// applicationEventFailureConditions tracks whether the ErrorOccurred condition of the CR of an event may have been set
+// by a dead-lettered event, so that the CR is only retrieved after a successful event if the condition needs to be
+// resolved. The CRs that are not tracked yet (for example, after a restart) are assumed to have the condition set.
+//
+// It is only accessed by the goroutine of a single application event runner, so it is not guarded by a mutex.
+type applicationEventFailureConditions map[string]bool
+
+func (conditions applicationEventFailureConditions) key(event *eventLoopEvent) string {
+	return string(event.eventType) + "/" + event.request.NamespacedName.String()
+}
+
+// mayBeSet returns true if the ErrorOccurred condition of the CR of the event is, or may be, set.
+func (conditions applicationEventFailureConditions) mayBeSet(event *eventLoopEvent) bool {
+	set, tracked := conditions[conditions.key(event)]
+	return !tracked || set
+}
+
+func (conditions applicationEventFailureConditions) set(event *eventLoopEvent, set bool) {
+	conditions[conditions.key(event)] = set
+}
+
+// forget stops tracking the CR of the event: for example, once the CR has been deleted.
+func (conditions applicationEventFailureConditions) forget(event *eventLoopEvent) {
+	delete(conditions, conditions.key(event))
+}
+
+// reportApplicationEventFailure sets the ErrorOccurred condition of the CR of the event, if the event failure is non-nil,
+// or otherwise resolves an ErrorOccurred condition that was previously set by a dead-lettered event. It returns false
+// if the status of the CR could not be retrieved or updated.
+//
+// Status ticks are not reported: they are not associated with a specific CR, and are retried on the next tick regardless.
+func reportApplicationEventFailure(outerContext context.Context, event *eventLoopEvent, failure error, log logr.Logger) bool {
+
+	if event.client == nil || (event.eventType != DeploymentModified && event.eventType != SyncRunModified) {
+		return true
+	}
+
+	// The deadline of the event may have passed, so the status is updated with its own timeout
+	ctx, cancel := context.WithTimeout(outerContext, 30*time.Second)
+	defer cancel()
+
+	now := metav1.Now()
+
+	var obj client.Object
+	var updateConditions func() bool
+
+	if event.eventType == DeploymentModified {
+		gitopsDepl := &managedgitopsv1alpha1.GitOpsDeployment{}
+		obj = gitopsDepl
+
+		updateConditions = func() bool {
+			if failure != nil {
+				setGitOpsDeploymentCondition(&gitopsDepl.Status.Conditions, managedgitopsv1alpha1.GitOpsDeploymentConditionErrorOccurred,
+					managedgitopsv1alpha1.GitOpsConditionStatusTrue, managedgitopsv1alpha1.GitopsDeploymentReasonEventProcessingFailed, failure.Error(), now)
+				return true
+			}
+
+			existing := findGitOpsDeploymentCondition(gitopsDepl.Status.Conditions, managedgitopsv1alpha1.GitOpsDeploymentConditionErrorOccurred)
+			if existing == nil || existing.Status != managedgitopsv1alpha1.GitOpsConditionStatusTrue ||
+				existing.Reason != managedgitopsv1alpha1.GitopsDeploymentReasonEventProcessingFailed {
+				return false
 			}
+			setGitOpsDeploymentCondition(&gitopsDepl.Status.Conditions, managedgitopsv1alpha1.GitOpsDeploymentConditionErrorOccurred,
+				managedgitopsv1alpha1.GitOpsConditionStatusFalse, managedgitopsv1alpha1.GitopsDeploymentReasonEventProcessingResolved, "", now)
+			return true
 		}
 
-		// Inform the caller that we have completed a single unit of work
-		informWorkCompleteChan <- applicationEventLoopMessage{messageType: applicationEventLoopMessageType_WorkComplete, event: newEvent, shutdownSignalled: signalledShutdown}
+	} else {
+		syncRun := &managedgitopsv1alpha1.GitOpsDeploymentSyncRun{}
+		obj = syncRun
+
+		updateConditions = func() bool {
+			if failure != nil {
+				setGitOpsDeploymentSyncRunCondition(&syncRun.Status.Conditions, managedgitopsv1alpha1.GitOpsDeploymentSyncRunConditionErrorOccurred,
+					managedgitopsv1alpha1.GitOpsConditionStatusTrue, managedgitopsv1alpha1.SyncRunReasonEventProcessingFailed, failure.Error(), now)
+				return true
+			}
 
-		// If the event processing logic concluded that the goroutine should shutdown, then break out of the outer for loop.
-		// This is usually because the API CR no longer exists.
-		if signalledShutdown {
-			break
+			existing := findGitOpsDeploymentSyncRunCondition(syncRun.Status.Conditions, managedgitopsv1alpha1.GitOpsDeploymentSyncRunConditionErrorOccurred)
+			if existing == nil || existing.Status != managedgitopsv1alpha1.GitOpsConditionStatusTrue ||
+				existing.Reason != managedgitopsv1alpha1.SyncRunReasonEventProcessingFailed {
+				return false
+			}
+			setGitOpsDeploymentSyncRunCondition(&syncRun.Status.Conditions, managedgitopsv1alpha1.GitOpsDeploymentSyncRunConditionErrorOccurred,
+				managedgitopsv1alpha1.GitOpsConditionStatusFalse, managedgitopsv1alpha1.SyncRunReasonEventProcessingResolved, "", now)
+			return true
 		}
 	}
 
-	log.Info("ApplicationEventLoopRunner goroutine terminated.", "signalledShutdown", signalledShutdown)
+	if err := event.client.Get(ctx, event.request.NamespacedName, obj); err != nil {
+		if !apierr.IsNotFound(err) {
+			log.Error(err, "unable to retrieve CR, to report the result of the event", "event", stringEventLoopEvent(event))
+			return false
+		}
+		return true
+	}
+
+	if !updateConditions() {
+		return true
+	}
+
+	if err := event.client.Status().Update(ctx, obj); err != nil {
+		log.Error(err, "unable to update status conditions of CR, to report the result of the event", "event", stringEventLoopEvent(event))
+		return false
+	}
+
+	return true
 }
 
 func (a *applicationEventLoopRunner_Action) applicationEventRunner_handleSyncRunModified(ctx context.Context, dbQueries db.ApplicationScopedQueries) (bool, error) {
@@ -157,7 +385,7 @@ func (a *applicationEventLoopRunner_Action) applicationEventRunner_handleSyncRun
 
 	clusterUser, err := a.sharedResourceEventLoop.getOrCreateClusterUserByNamespaceUID(ctx, a.workspaceClient, namespace)
 	if err != nil {
-		return false, fmt.Errorf("unable to retrieve cluster user in handleDeploymentModified, '%s': %v", string(namespace.UID), err)
+		return false, fmt.Errorf("unable to retrieve cluster user in handleDeploymentModified, '%s': %w", string(namespace.UID), err)
 	}
 
 	// Retrieve the GitOpsDeploymentSyncRun from the namespace
@@ -294,11 +522,13 @@ func (a *applicationEventLoopRunner_Action) applicationEventRunner_handleSyncRun
 			return false, err
 		}
 
-		// createdResources is a list of database entries created in this function; if an error occurs, we delete them
-		// in reverse order.
-		var createdResources []db.AppScopedDisposableResource
+		operationClient, err := a.getK8sClientForGitOpsEngineInstance(ctx, gitopsEngineInstance)
+		if err != nil {
+			log.Error(err, "unable to retrieve gitopsengine instance from handleSyncRunModified")
+			return false, err
+		}
 
-		// Create sync operation
+		// Create the sync operation, and the mapping from the CR to it, in a single transaction
 		syncOperation := &db.SyncOperation{
 			Application_id:      application.Application_id,
 			Operation_id:        "delme", // TODO: GITOPS-1678 - DEBT - This field can probably be removed from the database
@@ -306,68 +536,70 @@ func (a *applicationEventLoopRunner_Action) applicationEventRunner_handleSyncRun
 			Revision:            syncRunCR.Spec.RevisionID,
 			DesiredState:        db.SyncOperation_DesiredState_Running,
 		}
-		if err := dbQueries.CreateSyncOperation(ctx, syncOperation); err != nil {
-			log.Error(err, "unable to create sync operation in database")
-			return false, err
-		}
-		createdResources = append(createdResources, syncOperation)
+		var newApiCRToDBMapping db.APICRToDatabaseMapping
 
-		newApiCRToDBMapping := db.APICRToDatabaseMapping{
-			APIResourceType: db.APICRToDatabaseMapping_ResourceType_GitOpsDeploymentSyncRun,
-			APIResourceUID:  string(syncRunCR.UID),
-			DBRelationType:  db.APICRToDatabaseMapping_DBRelationType_SyncOperation,
-			DBRelationKey:   syncOperation.SyncOperation_id,
-
-			APIResourceName:      syncRunCR.Name,
-			APIResourceNamespace: syncRunCR.Namespace,
-			WorkspaceUID:         getWorkspaceIDFromNamespaceID(namespace),
-		}
-		if err := dbQueries.CreateAPICRToDatabaseMapping(ctx, &newApiCRToDBMapping); err != nil {
-			log.Error(err, "unable to create api to db mapping in database")
+		if err := dbQueries.RunInTransaction(ctx, func(tx db.ApplicationScopedQueries) error {
 
-			// If we were unable to retrieve the client, delete the resources we created in the previous steps
-			dbutil.DisposeApplicationScopedResources(ctx, createdResources, dbQueries, log)
+			if err := tx.CreateSyncOperation(ctx, syncOperation); err != nil {
+				log.Error(err, "unable to create sync operation in database")
+				return err
+			}
 
-			return false, err
-		}
-		createdResources = append(createdResources, &newApiCRToDBMapping)
+			newApiCRToDBMapping = db.APICRToDatabaseMapping{
+				APIResourceType: db.APICRToDatabaseMapping_ResourceType_GitOpsDeploymentSyncRun,
+				APIResourceUID:  string(syncRunCR.UID),
+				DBRelationType:  db.APICRToDatabaseMapping_DBRelationType_SyncOperation,
+				DBRelationKey:   syncOperation.SyncOperation_id,
 
-		operationClient, err := a.getK8sClientForGitOpsEngineInstance(gitopsEngineInstance)
-		if err != nil {
-			log.Error(err, "unable to retrieve gitopsengine instance from handleSyncRunModified")
+				APIResourceName:      syncRunCR.Name,
+				APIResourceNamespace: syncRunCR.Namespace,
+				WorkspaceUID:         getWorkspaceIDFromNamespaceID(namespace),
+			}
+			if err := tx.CreateAPICRToDatabaseMapping(ctx, &newApiCRToDBMapping); err != nil {
+				log.Error(err, "unable to create api to db mapping in database")
+				return err
+			}
 
-			// If we were unable to retrieve the client, delete the resources we created in the previous steps
-			dbutil.DisposeApplicationScopedResources(ctx, createdResources, dbQueries, log)
+			return nil
 
-			// Return the original error
+		}); err != nil {
 			return false, err
 		}
 
+		// The operation cannot be created within the transaction, as the cluster-agent would not be able to see the
+		// sync operation until the transaction was committed.
+		createdResources := []db.AppScopedDisposableResource{syncOperation, &newApiCRToDBMapping}
+
 		dbOperationInput := db.Operation{
 			Instance_id:   gitopsEngineInstance.Gitopsengineinstance_id,
 			Resource_id:   syncOperation.SyncOperation_id,
 			Resource_type: db.OperationResourceType_SyncOperation,
 		}
 
-		k8sOperation, dbOperation, err := CreateOperation(ctx, false && !a.testOnlySkipCreateOperation, dbOperationInput, clusterUser.Clusteruser_id,
+		k8sOperation, dbOperation, err := CreateOperation(ctx, !a.testOnlySkipCreateOperation, dbOperationInput, clusterUser.Clusteruser_id,
 			dbutil.GetGitOpsEngineSingleInstanceNamespace(), dbQueries, operationClient, log)
-		if err != nil {
+		if IsOperationNotCompletedError(err) {
+			// The cluster-agent processed the operation, but it did not complete successfully: whether it should be
+			// retried depends on the sync result, which is reported below.
+			log.Info("sync run operation did not complete successfully: " + err.Error())
+
+		} else if err != nil {
 			log.Error(err, "could not create operation", "namespace", dbutil.GetGitOpsEngineSingleInstanceNamespace())
 
 			// If we were unable to create the operation, delete the resources we created in the previous steps
 			dbutil.DisposeApplicationScopedResources(ctx, createdResources, dbQueries, log)
 
 			return false, err
-		}
 
-		// TODO: GITOPS-1466 - STUB - Remove the 'false' in createOperation above, once cluster agent handling of operation is implemented.
-		log.Info("STUB: Not waiting for create Sync Run operation to complete, in handleNewSyncRunModified")
-
-		if err := cleanupOperation(ctx, *dbOperation, *k8sOperation, dbutil.GetGitOpsEngineSingleInstanceNamespace(), dbQueries, operationClient, log); err != nil {
+		} else if err := cleanupOperation(ctx, *dbOperation, *k8sOperation, dbutil.GetGitOpsEngineSingleInstanceNamespace(), dbQueries, operationClient, log); err != nil {
 			return false, err
 		}
 
-		return false, nil
+		if a.testOnlySkipCreateOperation {
+			return false, nil
+		}
+
+		return false, a.reportSyncOperationResult(ctx, syncOperation.SyncOperation_id, syncRunCR, dbQueries)
 	}
 
 	if !syncRunCRExists && dbEntryExists {
@@ -408,7 +640,7 @@ func (a *applicationEventLoopRunner_Action) applicationEventRunner_handleSyncRun
 		}
 
 		// 3) Create the operation, in order to inform the cluster agent it needs to cancel the sync operation
-		operationClient, err := a.getK8sClientForGitOpsEngineInstance(gitopsEngineInstance)
+		operationClient, err := a.getK8sClientForGitOpsEngineInstance(ctx, gitopsEngineInstance)
 		if err != nil {
 			log.Error(err, "unable to retrieve gitopsengine instance from handleSyncRunModified, when resource was deleted")
 			return false, err
@@ -426,33 +658,28 @@ func (a *applicationEventLoopRunner_Action) applicationEventRunner_handleSyncRun
 			return false, err
 		}
 
-		// TODO: GITOPS-1466 - STUB - need to implement support for sync operation in cluster agent
-		log.Info("STUB: need to implement sync on cluster side")
-
-		if _, err := dbQueries.DeleteSyncOperationById(ctx, syncOperation.SyncOperation_id); err != nil {
-			log.Error(err, "could not delete sync operation, when resource was deleted", "namespace", dbutil.GetGitOpsEngineSingleInstanceNamespace())
-			return false, err
-		}
+		// 5) Delete the sync operation, and the mappings to it, in a single transaction
+		if err := dbQueries.RunInTransaction(ctx, func(tx db.ApplicationScopedQueries) error {
 
-		var allErrors error
+			if _, err := tx.DeleteSyncOperationById(ctx, syncOperation.SyncOperation_id); err != nil {
+				log.Error(err, "could not delete sync operation, when resource was deleted", "namespace", dbutil.GetGitOpsEngineSingleInstanceNamespace())
+				return err
+			}
 
-		// Remove the mappings
-		for idx := range apiCRToDBList {
+			// Remove the mappings: an error aborts the transaction, so there is no need to continue after the first error.
+			for idx := range apiCRToDBList {
 
-			apiCRToDB := apiCRToDBList[idx]
+				apiCRToDB := apiCRToDBList[idx]
 
-			err := a.cleanupOldSyncDBEntry(ctx, &apiCRToDB, *clusterUser, dbQueries)
-			if err != nil {
-				if allErrors == nil {
-					allErrors = err
-				} else {
-					allErrors = fmt.Errorf("error: %v error: %v", err, allErrors)
+				if err := a.cleanupOldSyncDBEntry(ctx, &apiCRToDB, *clusterUser, tx); err != nil {
+					return err
 				}
 			}
-		}
 
-		if allErrors != nil {
-			return false, allErrors
+			return nil
+
+		}); err != nil {
+			return false, err
 		}
 
 		// Success: the CR no longer exists, and we have completed cleanup, so signal that the goroutine may be terminated.
@@ -503,15 +730,91 @@ func (a *applicationEventLoopRunner_Action) applicationEventRunner_handleSyncRun
 			return false, err
 		}
 
+		// The retry decision is based on the result of the sync, rather than on the state of the Operation: if no
+		// completed sync result was stored (for example, the Operation timed out while Argo CD was unavailable), then
+		// ask the cluster-agent to sync again.
+		if !db.IsSyncResultPhaseComplete(syncOperation.SyncResult_phase) && !a.testOnlySkipCreateOperation {
+
+			operationClient, err := a.getK8sClientForGitOpsEngineInstance(ctx, gitopsEngineInstance)
+			if err != nil {
+				log.Error(err, "unable to retrieve gitopsengine instance from handleSyncRunModified, on modified")
+				return false, err
+			}
+
+			dbOperationInput := db.Operation{
+				Instance_id:   gitopsEngineInstance.Gitopsengineinstance_id,
+				Resource_id:   syncOperation.SyncOperation_id,
+				Resource_type: db.OperationResourceType_SyncOperation,
+			}
+
+			k8sOperation, dbOperation, err := CreateOperation(ctx, true, dbOperationInput, clusterUser.Clusteruser_id,
+				dbutil.GetGitOpsEngineSingleInstanceNamespace(), dbQueries, operationClient, log)
+			if IsOperationNotCompletedError(err) {
+				log.Info("sync run operation did not complete successfully: " + err.Error())
+
+			} else if err != nil {
+				log.Error(err, "could not create operation, on modified", "namespace", dbutil.GetGitOpsEngineSingleInstanceNamespace())
+				return false, err
+
+			} else if err := cleanupOperation(ctx, *dbOperation, *k8sOperation, dbutil.GetGitOpsEngineSingleInstanceNamespace(), dbQueries, operationClient, log); err != nil {
+				return false, err
+			}
+		}
+
 		// TODO: GITOPS-1678 - DEBT - Include test case to check that the various goroutines are terminated when the CR is deleted.
 
-		return false, nil
+		if a.testOnlySkipCreateOperation {
+			return false, nil
+		}
+
+		return false, a.reportSyncOperationResult(ctx, syncOperation.SyncOperation_id, syncRunCR, dbQueries)
 	}
 
 	return false, nil
 
 }
 
+// reportSyncOperationResult reports the result of the sync, as stored in the SyncOperation row by the cluster-agent, in the
+// status of the GitOpsDeploymentSyncRun.
+//
+// An error is returned if the sync has not completed, so that the event is retried. A sync that completed, but failed or
+// left resources to prune, is reported to the user rather than retried: Argo CD has its own retry strategy for failing syncs.
+func (a *applicationEventLoopRunner_Action) reportSyncOperationResult(ctx context.Context, syncOperationID string,
+	syncRunCR *managedgitopsv1alpha1.GitOpsDeploymentSyncRun, dbQueries db.ApplicationScopedQueries) error {
+
+	log := a.log
+
+	syncOperation := db.SyncOperation{SyncOperation_id: syncOperationID}
+	if err := dbQueries.GetSyncOperationById(ctx, &syncOperation); err != nil {
+		log.Error(err, "unable to retrieve sync operation, to report the sync result", "syncOperationID", syncOperationID)
+		return err
+	}
+
+	if !db.IsSyncResultPhaseComplete(syncOperation.SyncResult_phase) {
+		return fmt.Errorf("sync operation '%s' did not complete: phase: '%s'", syncOperationID, syncOperation.SyncResult_phase)
+	}
+
+	if syncRunCR.Status.Phase != syncOperation.SyncResult_phase || syncRunCR.Status.Message != syncOperation.SyncResult_message ||
+		syncRunCR.Status.PruningRequired != syncOperation.SyncResult_pruning_required {
+
+		syncRunCR.Status.Phase = syncOperation.SyncResult_phase
+		syncRunCR.Status.Message = syncOperation.SyncResult_message
+		syncRunCR.Status.PruningRequired = syncOperation.SyncResult_pruning_required
+
+		if err := a.workspaceClient.Status().Update(ctx, syncRunCR); err != nil {
+			log.Error(err, "unable to update the status of the sync run, with the sync result", "syncRun", syncRunCR.Name)
+			return err
+		}
+	}
+
+	if syncOperation.SyncResult_phase != db.SyncOperation_SyncResultPhase_Succeeded || syncOperation.SyncResult_pruning_required > 0 {
+		log.Info("sync operation completed, but was not successful", "phase", syncOperation.SyncResult_phase,
+			"pruningRequired", syncOperation.SyncResult_pruning_required)
+	}
+
+	return nil
+}
+
 func (a *applicationEventLoopRunner_Action) cleanupOldSyncDBEntry(ctx context.Context, apiCRToDB *db.APICRToDatabaseMapping,
 	clusterUser db.ClusterUser, dbQueries db.ApplicationScopedQueries) error {
 
@@ -627,13 +930,15 @@ func (a *applicationEventLoopRunner_Action) applicationEventRunner_handleUpdateD
 	gitopsDeployment.Status.Sync.Status = managedgitopsv1alpha1.SyncStatusCode(applicationState.Sync_Status)
 	gitopsDeployment.Status.Sync.Revision = applicationState.Revision
 
+	// Update the conditions of the GitOpsDeployment, based on the Argo CD conditions/operation state: the existing
+	// conditions fields that are in the status field of the CR are preserved.
+	updateGitOpsDeploymentConditions(gitopsDeployment, applicationState, metav1.Now(), a.log)
+
 	// Update the actual object in Kubernetes
 	if err := a.workspaceClient.Status().Update(ctx, gitopsDeployment, &client.UpdateOptions{}); err != nil {
 		return err
 	}
 
-	// NOTE: make sure to preserve the existing conditions fields that are in the status field of the CR, when updating the status!
-
 	return nil
 
 }
@@ -654,7 +959,7 @@ func (a *applicationEventLoopRunner_Action) applicationEventRunner_handleDeploym
 
 	clusterUser, err := a.sharedResourceEventLoop.getOrCreateClusterUserByNamespaceUID(ctx, workspaceClient, gitopsDeplNamespace)
 	if err != nil {
-		return false, nil, nil, fmt.Errorf("unable to retrieve cluster user in handleDeploymentModified, '%s': %v", string(gitopsDeplNamespace.UID), err)
+		return false, nil, nil, fmt.Errorf("unable to retrieve cluster user in handleDeploymentModified, '%s': %w", string(gitopsDeplNamespace.UID), err)
 	}
 
 	// Retrieve the GitOpsDeployment from the namespace
@@ -818,36 +1123,72 @@ func (a applicationEventLoopRunner_Action) cleanOldGitOpsDeploymentEntry(ctx con
 
 	log := a.log.WithValues("id", dbApplication.Application_id)
 
-	// Remove the ApplicationState from the database
-	rowsDeleted, err := dbQueries.DeleteApplicationStateById(ctx, deplToAppMapping.Application_id)
-	if err != nil {
+	// Whether the Application was deleted by another goroutine (the workspace teardown) after it was retrieved above:
+	// the Operation that deletes the Argo CD Application is then created by that goroutine, instead. See 'teardownApplication'.
+	applicationDeletedConcurrently := false
 
-		log.V(sharedutil.LogLevel_Warn).Error(err, "unable to delete application state by id")
-		return false, err
+	// Remove the database entries in a single transaction
+	if err := dbQueries.RunInTransaction(ctx, func(tx db.ApplicationScopedQueries) error {
 
-	} else if rowsDeleted == 0 {
-		// Log the warning, but continue
-		log.Info("no application rows deleted for application state", "rowsDeleted", rowsDeleted)
-	}
+		applicationDeletedConcurrently = false
 
-	// Remove DeplToAppMapping
-	rowsDeleted, err = dbQueries.DeleteDeploymentToApplicationMappingByDeplId(ctx, deplToAppMapping.Deploymenttoapplicationmapping_uid_id)
-	if err != nil {
-		log.Error(err, "unable to delete deplToAppMapping by id", "deplToAppMapUid", deplToAppMapping.Deploymenttoapplicationmapping_uid_id)
-		return false, err
+		// Remove the ApplicationState from the database
+		rowsDeleted, err := tx.DeleteApplicationStateById(ctx, deplToAppMapping.Application_id)
+		if err != nil {
 
-	} else if rowsDeleted == 0 {
-		// Log the warning, but continue
-		log.V(sharedutil.LogLevel_Warn).Error(nil, "unexpected number of rows deleted for deplToAppMapping", "rowsDeleted", rowsDeleted)
-	}
+			log.V(sharedutil.LogLevel_Warn).Error(err, "unable to delete application state by id")
+			return err
 
-	rowsUpdated, err := dbQueries.UpdateSyncOperationRemoveApplicationField(ctx, deplToAppMapping.Application_id)
-	if err != nil {
-		log.Error(err, "unable to update old sync operations", "applicationId", deplToAppMapping.Application_id)
+		} else if rowsDeleted == 0 {
+			// Log the warning, but continue
+			log.Info("no application rows deleted for application state", "rowsDeleted", rowsDeleted)
+		}
+
+		// Remove DeplToAppMapping
+		rowsDeleted, err = tx.DeleteDeploymentToApplicationMappingByDeplId(ctx, deplToAppMapping.Deploymenttoapplicationmapping_uid_id)
+		if err != nil {
+			log.Error(err, "unable to delete deplToAppMapping by id", "deplToAppMapUid", deplToAppMapping.Deploymenttoapplicationmapping_uid_id)
+			return err
+
+		} else if rowsDeleted == 0 {
+			// Log the warning, but continue
+			log.V(sharedutil.LogLevel_Warn).Error(nil, "unexpected number of rows deleted for deplToAppMapping", "rowsDeleted", rowsDeleted)
+		}
+
+		rowsUpdated, err := tx.UpdateSyncOperationRemoveApplicationField(ctx, deplToAppMapping.Application_id)
+		if err != nil {
+			log.Error(err, "unable to update old sync operations", "applicationId", deplToAppMapping.Application_id)
+			return err
+
+		} else if rowsUpdated == 0 {
+			log.Info("no sync operation rows updated, for updating old syncoperations on gitopsdepl deletion")
+		}
+
+		if !dbApplicationFound {
+			return nil
+		}
+
+		// Remove the Application from the database
+		log.Info("deleting database Application, id: " + deplToAppMapping.Application_id)
+		rowsDeleted, err = tx.DeleteApplicationById(ctx, deplToAppMapping.Application_id)
+		if err != nil {
+			// A failed statement aborts the transaction, so the error cannot be ignored
+			log.Error(err, "unable to delete application by id", "appId", deplToAppMapping.Application_id)
+			return err
+
+		} else if rowsDeleted == 0 {
+			log.Info("application was deleted concurrently, so its Argo CD Application is already being deleted", "appId", deplToAppMapping.Application_id)
+			applicationDeletedConcurrently = true
+		}
+
+		return nil
+
+	}); err != nil {
 		return false, err
+	}
 
-	} else if rowsUpdated == 0 {
-		log.Info("no sync operation rows updated, for updating old syncoperations on gitopsdepl deletion")
+	if applicationDeletedConcurrently {
+		return true, nil
 	}
 
 	if !dbApplicationFound {
@@ -856,18 +1197,7 @@ func (a applicationEventLoopRunner_Action) cleanOldGitOpsDeploymentEntry(ctx con
 		return true, nil
 	}
 
-	// If the Application table entry still exists, finish the cleanup...
-
-	// Remove the Application from the database
-	log.Info("deleting database Application, id: " + deplToAppMapping.Application_id)
-	rowsDeleted, err = dbQueries.DeleteApplicationById(ctx, deplToAppMapping.Application_id)
-	if err != nil {
-		// Log the error, but continue
-		log.Error(err, "unable to delete application by id", "appId", deplToAppMapping.Application_id)
-	} else if rowsDeleted == 0 {
-		// Log the error, but continue
-		log.V(sharedutil.LogLevel_Warn).Error(nil, "unexpected number of rows deleted for application", "rowsDeleted", rowsDeleted, "appId", deplToAppMapping.Application_id)
-	}
+	// If the Application table entry existed, finish the cleanup...
 
 	gitopsEngineInstance, err := a.sharedResourceEventLoop.getGitopsEngineInstanceById(ctx, dbApplication.Engine_instance_inst_id, a.workspaceClient, workspaceNamespace)
 	if err != nil {
@@ -883,7 +1213,7 @@ func (a applicationEventLoopRunner_Action) cleanOldGitOpsDeploymentEntry(ctx con
 	}
 
 	// Create the operation that will delete the Argo CD application
-	gitopsEngineClient, err := a.getK8sClientForGitOpsEngineInstance(gitopsEngineInstance)
+	gitopsEngineClient, err := a.getK8sClientForGitOpsEngineInstance(ctx, gitopsEngineInstance)
 	if err != nil {
 		log.Error(err, "could not retrieve client for gitops engine instance", "instance", gitopsEngineInstance.Gitopsengineinstance_id)
 		return false, err
@@ -954,45 +1284,49 @@ func (a applicationEventLoopRunner_Action) handleUpdatedGitOpsDeplEvent(ctx cont
 
 	// TODO: GITOPS-1678 - Sanity check that the application.name matches the expected value set in handleCreateGitOpsEvent
 
-	destinationNamespace := gitopsDeployment.Spec.Destination.Namespace
-	if destinationNamespace == "" {
-		destinationNamespace = a.eventResourceNamespace
-	}
-
-	specFieldInput := argoCDSpecInput{
-		crName:               application.Name,
-		crNamespace:          engineInstanceParam.Namespace_name,
-		destinationNamespace: destinationNamespace,
-		// TODO: GITOPS-1722 - Fill this in with cluster credentials
-		destinationName:      "in-cluster",
-		sourceRepoURL:        gitopsDeployment.Spec.Source.RepoURL,
-		sourcePath:           gitopsDeployment.Spec.Source.Path,
-		sourceTargetRevision: gitopsDeployment.Spec.Source.TargetRevision,
-		automated:            strings.EqualFold(gitopsDeployment.Spec.Type, managedgitopsv1alpha1.GitOpsDeploymentSpecType_Automated),
-	}
-
-	specFieldResult, err := createSpecField(specFieldInput)
+	specFieldResult, err := createSpecField(newArgoCDSpecInput(gitopsDeployment, application.Name, engineInstanceParam.Namespace_name))
 	if err != nil {
 		log.Error(err, "SEVERE: Unable to parse generated spec field")
 		return false, nil, nil, err
 	}
 
-	if specFieldResult == application.Spec_field {
-		log.Info("No spec change detected between Application DB entry and GitOpsDeployment CR")
-		// No change required: the application database entry is consistent with the gitopsdepl CR
-		return false, application, engineInstanceParam, nil
-	}
+	// The Application row may be concurrently updated (for example, by another backend replica): on conflict, re-read
+	// the row, and compare it against the GitOpsDeployment again.
+	specChanged := false
+	firstAttempt := true
+	if err := dbutil.RetryOnConflict(ctx, func() error {
 
-	log.Info("Spec change detected between Application DB entry and GitOpsDeployment CR")
+		if !firstAttempt {
+			if err := dbQueries.GetApplicationById(ctx, application); err != nil {
+				return err
+			}
+		}
+		firstAttempt = false
 
-	application.Spec_field = specFieldResult
+		if specFieldResult == application.Spec_field {
+			specChanged = false
+			return nil
+		}
+
+		specChanged = true
+		application.Spec_field = specFieldResult
 
-	if err := dbQueries.UpdateApplication(ctx, application); err != nil {
+		return dbQueries.UpdateApplication(ctx, application)
+
+	}); err != nil {
 		log.Error(err, "Unable to update application, after mismatch detected")
 		return false, nil, nil, err
 	}
+
+	if !specChanged {
+		log.Info("No spec change detected between Application DB entry and GitOpsDeployment CR")
+		// No change required: the application database entry is consistent with the gitopsdepl CR
+		return false, application, engineInstanceParam, nil
+	}
+
+	log.Info("Spec change detected between Application DB entry and GitOpsDeployment CR, and the Application DB entry was updated")
 	// Create the operation
-	gitopsEngineClient, err := a.getK8sClientForGitOpsEngineInstance(engineInstanceParam)
+	gitopsEngineClient, err := a.getK8sClientForGitOpsEngineInstance(ctx, engineInstanceParam)
 	if err != nil {
 		log.Error(err, "unable to retrieve gitopsengineinstance for updated gitopsdepl", "gitopsEngineIstance", engineInstanceParam.EngineCluster_id)
 		return false, nil, nil, err
@@ -1020,35 +1354,8 @@ func (a applicationEventLoopRunner_Action) handleUpdatedGitOpsDeplEvent(ctx cont
 }
 
 // Don't call this directly: call it via workspaceEventLoopRunner_Action
-func actionGetK8sClientForGitOpsEngineInstance(gitopsEngineInstance *db.GitopsEngineInstance) (client.Client, error) {
-
-	// TODO: GITOPS-1455: When we support multiple Argo CD instances (and multiple instances on separate clusters), this logic should be updated.
-
-	config, err := sharedutil.GetRESTConfig()
-	if err != nil {
-		return nil, err
-	}
-
-	scheme := runtime.NewScheme()
-	err = managedgitopsv1alpha1.AddToScheme(scheme)
-	if err != nil {
-		return nil, err
-	}
-	err = operation.AddToScheme(scheme)
-	if err != nil {
-		return nil, err
-	}
-	err = corev1.AddToScheme(scheme)
-	if err != nil {
-		return nil, err
-	}
-	k8sClient, err := client.New(config, client.Options{Scheme: scheme})
-	if err != nil {
-		return nil, err
-	}
-
-	return k8sClient, nil
-
+func actionGetK8sClientForGitOpsEngineInstance(ctx context.Context, gitopsEngineInstance *db.GitopsEngineInstance) (client.Client, error) {
+	return gitopsEngineClients.getClient(ctx, gitopsEngineInstance)
 }
 
 func (a applicationEventLoopRunner_Action) handleNewGitOpsDeplEvent(ctx context.Context, gitopsDeployment *managedgitopsv1alpha1.GitOpsDeployment,
@@ -1068,24 +1375,7 @@ func (a applicationEventLoopRunner_Action) handleNewGitOpsDeplEvent(ctx context.
 
 	appName := "gitopsdepl-" + string(gitopsDeployment.UID)
 
-	destinationNamespace := gitopsDeployment.Spec.Destination.Namespace
-	if destinationNamespace == "" {
-		destinationNamespace = a.eventResourceNamespace
-	}
-
-	specFieldInput := argoCDSpecInput{
-		crName:               appName,
-		crNamespace:          engineInstance.Namespace_name,
-		destinationNamespace: destinationNamespace,
-		// TODO: GITOPS-1722 - Fill this in with cluster credentials
-		destinationName:      "in-cluster",
-		sourceRepoURL:        gitopsDeployment.Spec.Source.RepoURL,
-		sourcePath:           gitopsDeployment.Spec.Source.Path,
-		sourceTargetRevision: gitopsDeployment.Spec.Source.TargetRevision,
-		automated:            strings.EqualFold(gitopsDeployment.Spec.Type, managedgitopsv1alpha1.GitOpsDeploymentSpecType_Automated),
-	}
-
-	specFieldText, err := createSpecField(specFieldInput)
+	specFieldText, err := createSpecField(newArgoCDSpecInput(gitopsDeployment, appName, engineInstance.Namespace_name))
 	if err != nil {
 		a.log.Error(err, "SEVERE: unable to marshal generated YAML")
 		return false, nil, nil, err
@@ -1098,25 +1388,35 @@ func (a applicationEventLoopRunner_Action) handleNewGitOpsDeplEvent(ctx context.
 		Spec_field:              specFieldText,
 	}
 
-	a.log.Info("Creating new Application in DB: " + application.Application_id)
+	// The Application and the DeploymentToApplicationMapping are created in a single transaction: the Application
+	// should not exist without the mapping.
+	if err := dbQueries.RunInTransaction(ctx, func(tx db.ApplicationScopedQueries) error {
 
-	if err := dbQueries.CreateApplication(ctx, &application); err != nil {
-		a.log.Error(err, "unable to create application", "application", application, "ownerId", clusterUser.Clusteruser_id)
-		return false, nil, nil, err
-	}
+		a.log.Info("Creating new Application in DB: " + application.Application_id)
 
-	requiredDeplToAppMapping := &db.DeploymentToApplicationMapping{
-		Deploymenttoapplicationmapping_uid_id: string(gitopsDeployment.UID),
-		Application_id:                        application.Application_id,
-		DeploymentName:                        gitopsDeployment.Name,
-		DeploymentNamespace:                   gitopsDeployment.Namespace,
-		WorkspaceUID:                          getWorkspaceIDFromNamespaceID(gitopsDeplNamespace),
-	}
+		if err := tx.CreateApplication(ctx, &application); err != nil {
+			a.log.Error(err, "unable to create application", "application", application, "ownerId", clusterUser.Clusteruser_id)
+			return err
+		}
+
+		requiredDeplToAppMapping := &db.DeploymentToApplicationMapping{
+			Deploymenttoapplicationmapping_uid_id: string(gitopsDeployment.UID),
+			Application_id:                        application.Application_id,
+			DeploymentName:                        gitopsDeployment.Name,
+			DeploymentNamespace:                   gitopsDeployment.Namespace,
+			WorkspaceUID:                          getWorkspaceIDFromNamespaceID(gitopsDeplNamespace),
+		}
 
-	a.log.Info("Upserting new DeploymentToApplicationMapping in DB: " + requiredDeplToAppMapping.Deploymenttoapplicationmapping_uid_id)
+		a.log.Info("Upserting new DeploymentToApplicationMapping in DB: " + requiredDeplToAppMapping.Deploymenttoapplicationmapping_uid_id)
 
-	if err := dbutil.GetOrCreateDeploymentToApplicationMapping(ctx, requiredDeplToAppMapping, dbQueries, a.log); err != nil {
-		a.log.Error(err, "unable to create deplToApp mapping", "deplToAppMapping", requiredDeplToAppMapping)
+		if err := dbutil.GetOrCreateDeploymentToApplicationMapping(ctx, requiredDeplToAppMapping, tx, a.log); err != nil {
+			a.log.Error(err, "unable to create deplToApp mapping", "deplToAppMapping", requiredDeplToAppMapping)
+			return err
+		}
+
+		return nil
+
+	}); err != nil {
 		return false, nil, nil, err
 	}
 
@@ -1126,7 +1426,7 @@ func (a applicationEventLoopRunner_Action) handleNewGitOpsDeplEvent(ctx context.
 		Resource_type: db.OperationResourceType_Application,
 	}
 
-	gitopsEngineClient, err := a.getK8sClientForGitOpsEngineInstance(engineInstance)
+	gitopsEngineClient, err := a.getK8sClientForGitOpsEngineInstance(ctx, engineInstance)
 	if err != nil {
 		return false, nil, nil, err
 	}
@@ -1165,9 +1465,8 @@ type applicationEventLoopRunner_Action struct {
 	workspaceID string
 
 	// getK8sClientForGitOpsEngineInstance returns the K8s client that corresponds to the gitops engine instance.
-	// As of this writing, only one Argo CD instance is supported, so this is trivial, but should have
-	// more complex logic in the future.
-	getK8sClientForGitOpsEngineInstance func(gitopsEngineInstance *db.GitopsEngineInstance) (client.Client, error)
+	// The instance may be on a different cluster from the backend: see 'gitopsEngineClientCache'.
+	getK8sClientForGitOpsEngineInstance func(ctx context.Context, gitopsEngineInstance *db.GitopsEngineInstance) (client.Client, error)
 
 	sharedResourceEventLoop *sharedResourceEventLoop
 
@@ -1183,6 +1482,10 @@ func cleanupOperation(ctx context.Context, dbOperation db.Operation, k8sOperatio
 
 	log = log.WithValues("operation", dbOperation.Operation_id, "namespace", operationNamespace)
 
+	// The database entry is not deleted here: it is retained for the user (and for debugging), and is later purged by
+	// the OperationCollector, based on the OperationRetentionPolicy. The OperationCollector will also delete the
+	// Operation CR, if the delete below fails.
+
 	// // Delete the database entry
 	// rowsDeleted, err := dbQueries.DeleteOperationById(ctx, dbOperation.Operation_id)
 	// if err != nil {
@@ -1210,7 +1513,62 @@ func CreateOperation(ctx context.Context, waitForOperation bool, dbOperationPara
 	operationNamespace string, dbQueries db.ApplicationScopedQueries, gitopsEngineClient client.Client, log logr.Logger) (*operation.Operation, *db.Operation, error) {
 
 	var err error
-	dbOperation := db.Operation{
+	dbOperation := newDBOperation(dbOperationParam, clusterUserID)
+
+	// If the cluster-agent of the GitOps engine cluster has stopped sending heartbeats, the operation will not be processed
+	// until it is running again:
+	// - If the caller would wait on the operation, fail fast (the caller will retry).
+	// - Otherwise, the operation is created, and will be processed once the cluster-agent is running again.
+	if err := checkGitopsEngineInstanceAvailable(ctx, dbOperation.Instance_id, dbQueries); err != nil {
+		if waitForOperation {
+			log.Error(err, "unable to create operation, as the gitops engine is unavailable", "operation", dbOperation.ShortString())
+			return nil, nil, err
+		}
+		log.V(sharedutil.LogLevel_Warn).Info("gitops engine may be unavailable, so operation will be processed once it is available: "+err.Error(),
+			"operation", dbOperation.ShortString())
+	}
+
+	log.Info("Creating database operation", "operation", dbOperation.ShortString())
+
+	if err := dbQueries.CreateOperation(ctx, &dbOperation, clusterUserID); err != nil {
+		log.Error(err, "unable to create operation", "operation", dbOperation.LongString())
+		return nil, nil, err
+	}
+
+	// Create K8s operation
+	operation, err := createOperationCR(ctx, dbOperation, operationNamespace, gitopsEngineClient, log)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Wait for operation to complete.
+
+	if waitForOperation {
+		log.Info("Waiting for Operation to complete", "operation", fmt.Sprintf("%v", operation.Spec.OperationID))
+
+		if err = WaitForOperationToComplete(ctx, &dbOperation, operation, gitopsEngineClient, dbQueries, log); err != nil {
+			log.Error(err, "operation did not complete", "operation", dbOperation.Operation_id, "namespace", operation.Namespace)
+
+			// The cluster-agent will no longer process the operation, so its CR may be deleted
+			if IsOperationNotCompletedError(err) {
+				if cleanupErr := cleanupOperation(ctx, dbOperation, *operation, operationNamespace, dbQueries, gitopsEngineClient, log); cleanupErr != nil {
+					log.Error(cleanupErr, "unable to cleanup operation that did not complete", "operation", dbOperation.Operation_id)
+				}
+			}
+
+			return nil, nil, err
+		}
+
+		log.Info("Operation completed", "operation", fmt.Sprintf("%v", operation.Spec.OperationID))
+	}
+
+	return operation, &dbOperation, nil
+
+}
+
+// newDBOperation returns the row of a new Operation, in the Waiting state, for the resource of 'dbOperationParam'.
+func newDBOperation(dbOperationParam db.Operation, clusterUserID string) db.Operation {
+	return db.Operation{
 		Instance_id:             dbOperationParam.Instance_id,
 		Resource_id:             dbOperationParam.Resource_id,
 		Resource_type:           dbOperationParam.Resource_type,
@@ -1220,15 +1578,13 @@ func CreateOperation(ctx context.Context, waitForOperation bool, dbOperationPara
 		State:                   db.OperationState_Waiting,
 		Human_readable_state:    "",
 	}
+}
 
-	log.Info("Creating database operation", "operation", dbOperation.ShortString())
-
-	if err := dbQueries.CreateOperation(ctx, &dbOperation, clusterUserID); err != nil {
-		log.Error(err, "unable to create operation", "operation", dbOperation.LongString())
-		return nil, nil, err
-	}
+// createOperationCR creates the Operation CR of an Operation row, in the namespace of its GitOps engine instance: the
+// cluster-agent processes the Operation once the CR is created.
+func createOperationCR(ctx context.Context, dbOperation db.Operation, operationNamespace string, gitopsEngineClient client.Client,
+	log logr.Logger) (*operation.Operation, error) {
 
-	// Create K8s operation
 	operation := operation.Operation{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "operation-" + dbOperation.Operation_id,
@@ -1243,42 +1599,71 @@ func CreateOperation(ctx context.Context, waitForOperation bool, dbOperationPara
 
 	if err := gitopsEngineClient.Create(ctx, &operation, &client.CreateOptions{}); err != nil {
 		log.Error(err, "unable to create K8s Operation in namespace", "operation", dbOperation.Operation_id, "namespace", operation.Namespace)
-		return nil, nil, err
+		return nil, err
 	}
 
-	// Wait for operation to complete.
-
-	if waitForOperation {
-		log.Info("Waiting for Operation to complete", "operation", fmt.Sprintf("%v", operation.Spec.OperationID))
-
-		if err = WaitForOperationToComplete(ctx, &dbOperation, dbQueries, log); err != nil {
-			log.Error(err, "operation did not complete", "operation", dbOperation.Operation_id, "namespace", operation.Namespace)
-			return nil, nil, err
-		}
+	return &operation, nil
+}
 
-		log.Info("Operation completed", "operation", fmt.Sprintf("%v", operation.Spec.OperationID))
-	}
+// OperationNotCompletedError is returned by WaitForOperationToComplete when the operation reached a terminal state other
+// than Completed: the operation failed, timed out, or was cancelled. Callers should not treat the operation as successful.
+type OperationNotCompletedError struct {
+	OperationID        string
+	State              string
+	HumanReadableState string
+}
 
-	return &operation, &dbOperation, nil
+func (e *OperationNotCompletedError) Error() string {
+	return fmt.Sprintf("operation '%s' did not complete successfully: state: %s, message: %s", e.OperationID, e.State,
+		e.HumanReadableState)
+}
 
+// IsOperationNotCompletedError returns true if the error is (or wraps) an OperationNotCompletedError.
+func IsOperationNotCompletedError(err error) bool {
+	var notCompletedErr *OperationNotCompletedError
+	return errors.As(err, &notCompletedErr)
 }
 
-func WaitForOperationToComplete(ctx context.Context, dbOperation *db.Operation, dbQueries db.ApplicationScopedQueries, log logr.Logger) error {
+// WaitForOperationToComplete waits for the cluster-agent to complete the operation. An OperationNotCompletedError is
+// returned if the operation reached a terminal state other than Completed.
+//
+// The cluster-agent mirrors the state of the Operation database row into the status of the Operation CR, so the CR is
+// checked first, and the database (the source of truth) is only read once the CR reports that the operation has completed.
+// If the status of the CR is not available (for example, it has not yet been processed), the database is read instead.
+func WaitForOperationToComplete(ctx context.Context, dbOperation *db.Operation, operationCR *operation.Operation, gitopsEngineClient client.Client,
+	dbQueries db.ApplicationScopedQueries, log logr.Logger) error {
 
 	backoff := sharedutil.ExponentialBackoff{Factor: 2, Min: time.Duration(100 * time.Millisecond), Max: time.Duration(10 * time.Second), Jitter: true}
 
 	for {
 
-		err := dbQueries.GetOperationById(ctx, dbOperation)
-		if err != nil {
+		if isOperationCRStatusIncomplete(ctx, operationCR, gitopsEngineClient, log) {
+			// The CR reports that the operation is still running, so there is no need to read the database
+
+		} else if err := dbQueries.GetOperationById(ctx, dbOperation); err != nil {
 			// Either the operation couldn't be found (which shouldn't happen here), or some other issue, so return it
 			return err
-		}
 
-		if err == nil && (dbOperation.State == db.OperationState_Completed || dbOperation.State == db.OperationState_Failed) {
+		} else if db.IsOperationStateComplete(dbOperation.State) {
 			break
 		}
 
+		// Stop waiting once the deadline of the operation has passed: the cluster-agent is asked to stop processing it.
+		if !dbOperation.Deadline.IsZero() && time.Now().After(dbOperation.Deadline) {
+			if err := RequestOperationCancellation(ctx, dbOperation, operationCR, gitopsEngineClient, dbQueries, log); err != nil {
+				log.Error(err, "unable to request cancellation of operation, after its deadline passed", "operation", dbOperation.Operation_id)
+			}
+			return fmt.Errorf("operation '%s' did not complete before its deadline", dbOperation.Operation_id)
+		}
+
+		// Stop waiting if the cluster-agent has stopped sending heartbeats: otherwise we would wait forever.
+		if err := checkGitopsEngineInstanceAvailable(ctx, dbOperation.Instance_id, dbQueries); err != nil {
+			if dbutil.IsGitopsEngineClusterUnavailableError(err) {
+				return err
+			}
+			log.V(sharedutil.LogLevel_Warn).Info("unable to check gitops engine availability: " + err.Error())
+		}
+
 		backoff.DelayOnFail(ctx)
 
 		// Break if the request is cancelled, or the timeout expires
@@ -1290,9 +1675,90 @@ func WaitForOperationToComplete(ctx context.Context, dbOperation *db.Operation,
 
 	}
 
+	if dbOperation.State != db.OperationState_Completed {
+		return &OperationNotCompletedError{
+			OperationID:        dbOperation.Operation_id,
+			State:              dbOperation.State,
+			HumanReadableState: dbOperation.Human_readable_state,
+		}
+	}
+
+	return nil
+}
+
+// RequestOperationCancellation requests that the cluster-agent stop processing the operation. The request is recorded in
+// the database, and the Operation CR is annotated to inform the cluster-agent: the cluster-agent will then cancel the
+// operation (if it is running), and move it to the Cancelled state.
+func RequestOperationCancellation(ctx context.Context, dbOperation *db.Operation, operationCR *operation.Operation, gitopsEngineClient client.Client,
+	dbQueries db.ApplicationScopedQueries, log logr.Logger) error {
+
+	if err := dbQueries.RequestOperationCancellation(ctx, dbOperation); err != nil {
+		return fmt.Errorf("unable to request cancellation of operation '%s': %w", dbOperation.Operation_id, err)
+	}
+
+	if operationCR == nil || gitopsEngineClient == nil {
+		return nil
+	}
+
+	// The operation will still be cancelled if the CR can't be updated, but only the next time it is processed.
+	latestOperationCR := &operation.Operation{}
+	if err := gitopsEngineClient.Get(ctx, client.ObjectKeyFromObject(operationCR), latestOperationCR); err != nil {
+		if apierr.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("unable to retrieve Operation CR of operation '%s': %v", dbOperation.Operation_id, err)
+	}
+
+	if latestOperationCR.Annotations == nil {
+		latestOperationCR.Annotations = map[string]string{}
+	}
+	latestOperationCR.Annotations[operation.OperationCancellationRequestedAnnotation] = "true"
+
+	if err := gitopsEngineClient.Update(ctx, latestOperationCR); err != nil {
+		return fmt.Errorf("unable to annotate Operation CR of operation '%s': %v", dbOperation.Operation_id, err)
+	}
+
+	log.Info("Requested cancellation of operation", "operation", dbOperation.Operation_id)
+
 	return nil
 }
 
+// isOperationCRStatusIncomplete returns true if the status of the Operation CR reports that the operation has not yet
+// completed, and false if the operation has completed, or the status is not available.
+func isOperationCRStatusIncomplete(ctx context.Context, operationCR *operation.Operation, gitopsEngineClient client.Client, log logr.Logger) bool {
+
+	if operationCR == nil || gitopsEngineClient == nil {
+		return false
+	}
+
+	latestOperationCR := &operation.Operation{}
+	if err := gitopsEngineClient.Get(ctx, client.ObjectKeyFromObject(operationCR), latestOperationCR); err != nil {
+		log.V(sharedutil.LogLevel_Debug).Info("unable to retrieve Operation CR, while waiting for operation to complete: "+err.Error(),
+			"operation", operationCR.Spec.OperationID)
+		return false
+	}
+
+	// Ignore the status of a CR that has been replaced by a CR for another operation
+	if latestOperationCR.Spec.OperationID != operationCR.Spec.OperationID {
+		return false
+	}
+
+	return latestOperationCR.Status.State != "" && !latestOperationCR.Status.IsComplete()
+}
+
+// checkGitopsEngineInstanceAvailable returns an error if the cluster-agent of the GitOps engine cluster, that hosts the
+// given GitOps engine instance, has stopped sending heartbeats.
+func checkGitopsEngineInstanceAvailable(ctx context.Context, gitopsEngineInstanceID string, dbQueries db.ApplicationScopedQueries) error {
+
+	// The GitOps engine instance/cluster are not application-scoped resources, but they are only read here.
+	dbq, ok := dbQueries.(db.DatabaseQueries)
+	if !ok {
+		return fmt.Errorf("SEVERE: unexpected cast failure")
+	}
+
+	return dbutil.CheckGitopsEngineInstanceAvailable(ctx, gitopsEngineInstanceID, dbq)
+}
+
 func getWorkspaceIDFromNamespaceID(namespace corev1.Namespace) string {
 	// Here we assume that the namespace UID is the same as the workspace UID. If/when that changes, this should be updated.
 	return string(namespace.UID)
@@ -1315,6 +1781,28 @@ type argoCDSpecInput struct {
 	// Hopefully you are getting the message, here :)
 }
 
+// newArgoCDSpecInput returns the fields of the Argo CD Application of the GitOpsDeployment, for the Application row
+// with the given name, on the GitOps engine instance with the given namespace.
+func newArgoCDSpecInput(gitopsDeployment *managedgitopsv1alpha1.GitOpsDeployment, applicationName string, engineInstanceNamespace string) argoCDSpecInput {
+
+	destinationNamespace := gitopsDeployment.Spec.Destination.Namespace
+	if destinationNamespace == "" {
+		destinationNamespace = gitopsDeployment.Namespace
+	}
+
+	return argoCDSpecInput{
+		crName:               applicationName,
+		crNamespace:          engineInstanceNamespace,
+		destinationNamespace: destinationNamespace,
+		// TODO: GITOPS-1722 - Fill this in with cluster credentials
+		destinationName:      "in-cluster",
+		sourceRepoURL:        gitopsDeployment.Spec.Source.RepoURL,
+		sourcePath:           gitopsDeployment.Spec.Source.Path,
+		sourceTargetRevision: gitopsDeployment.Spec.Source.TargetRevision,
+		automated:            strings.EqualFold(gitopsDeployment.Spec.Type, managedgitopsv1alpha1.GitOpsDeploymentSpecType_Automated),
+	}
+}
+
 func createSpecField(fieldsParam argoCDSpecInput) (string, error) {
 
 	sanitize := func(input string) string {
@@ -1422,3 +1910,146 @@ func stringEventLoopEvent(obj *eventLoopEvent) string {
 		obj.request.Name, string(obj.reqResource), obj.workspaceID, obj.associatedGitopsDeplUID)
 
 }
+
+// argoCDConditionMappings are the Argo CD Application conditions that are surfaced as conditions of the GitOpsDeployment,
+// with the reasons used when the condition is present, and once it has been resolved.
+var argoCDConditionMappings = []struct {
+	conditionType  managedgitopsv1alpha1.GitOpsDeploymentConditionType
+	reason         managedgitopsv1alpha1.GitOpsDeploymentReasonType
+	resolvedReason managedgitopsv1alpha1.GitOpsDeploymentReasonType
+}{
+	{
+		conditionType:  managedgitopsv1alpha1.GitOpsDeploymentConditionComparisonError,
+		reason:         managedgitopsv1alpha1.GitopsDeploymentReasonComparisonError,
+		resolvedReason: managedgitopsv1alpha1.GitopsDeploymentReasonComparisonErrorResolved,
+	},
+	{
+		conditionType:  managedgitopsv1alpha1.GitOpsDeploymentConditionInvalidSpecError,
+		reason:         managedgitopsv1alpha1.GitopsDeploymentReasonInvalidSpecError,
+		resolvedReason: managedgitopsv1alpha1.GitopsDeploymentReasonInvalidSpecErrorResolved,
+	},
+	{
+		conditionType:  managedgitopsv1alpha1.GitOpsDeploymentConditionSyncError,
+		reason:         managedgitopsv1alpha1.GitopsDeploymentReasonSyncError,
+		resolvedReason: managedgitopsv1alpha1.GitopsDeploymentReasonSyncErrorResolved,
+	},
+}
+
+// updateGitOpsDeploymentConditions maps the Argo CD Application conditions, and the operation state, from the ApplicationState onto
+// the conditions of the GitOpsDeployment:
+// - A condition that is reported by Argo CD is set to 'True', with the Argo CD message.
+// - A condition that was previously set, but is no longer reported by Argo CD, is set to 'False'.
+// - A failed sync operation is reported as a SyncError, if Argo CD has not already reported one.
+// - Conditions of any other type are preserved.
+func updateGitOpsDeploymentConditions(gitopsDeployment *managedgitopsv1alpha1.GitOpsDeployment, applicationState db.ApplicationState,
+	now metav1.Time, log logr.Logger) {
+
+	argoCDConditions, err := db.DecodeApplicationStateConditions(applicationState.Conditions)
+	if err != nil {
+		// Don't prevent the remainder of the status from being updated
+		log.Error(err, "unable to decode conditions of ApplicationState: "+applicationState.Applicationstate_application_id)
+		argoCDConditions = []db.ApplicationStateCondition{}
+	}
+
+	for _, mapping := range argoCDConditionMappings {
+
+		reason := mapping.reason
+		messages := []string{}
+		for _, argoCDCondition := range argoCDConditions {
+			if argoCDCondition.Type == string(mapping.conditionType) {
+				messages = append(messages, argoCDCondition.Message)
+			}
+		}
+
+		if mapping.conditionType == managedgitopsv1alpha1.GitOpsDeploymentConditionSyncError && len(messages) == 0 &&
+			(applicationState.Operation_phase == "Failed" || applicationState.Operation_phase == "Error") {
+
+			reason = managedgitopsv1alpha1.GitopsDeploymentReasonSyncOperationFailed
+			messages = append(messages, applicationState.Operation_message)
+		}
+
+		if len(messages) > 0 {
+			setGitOpsDeploymentCondition(&gitopsDeployment.Status.Conditions, mapping.conditionType,
+				managedgitopsv1alpha1.GitOpsConditionStatusTrue, reason, strings.Join(messages, "; "), now)
+
+		} else if findGitOpsDeploymentCondition(gitopsDeployment.Status.Conditions, mapping.conditionType) != nil {
+			// Only resolve conditions that were previously set
+			setGitOpsDeploymentCondition(&gitopsDeployment.Status.Conditions, mapping.conditionType,
+				managedgitopsv1alpha1.GitOpsConditionStatusFalse, mapping.resolvedReason, "", now)
+		}
+	}
+}
+
+func findGitOpsDeploymentCondition(conditions []managedgitopsv1alpha1.GitOpsDeploymentCondition,
+	conditionType managedgitopsv1alpha1.GitOpsDeploymentConditionType) *managedgitopsv1alpha1.GitOpsDeploymentCondition {
+
+	for idx := range conditions {
+		if conditions[idx].Type == conditionType {
+			return &conditions[idx]
+		}
+	}
+	return nil
+}
+
+func findGitOpsDeploymentSyncRunCondition(conditions []managedgitopsv1alpha1.GitOpsDeploymentSyncRunCondition,
+	conditionType managedgitopsv1alpha1.SyncRunConditionType) *managedgitopsv1alpha1.GitOpsDeploymentSyncRunCondition {
+
+	for idx := range conditions {
+		if conditions[idx].Type == conditionType {
+			return &conditions[idx]
+		}
+	}
+	return nil
+}
+
+// setGitOpsDeploymentSyncRunCondition adds or updates the condition of the given type, in the same way as
+// setGitOpsDeploymentCondition.
+func setGitOpsDeploymentSyncRunCondition(conditions *[]managedgitopsv1alpha1.GitOpsDeploymentSyncRunCondition,
+	conditionType managedgitopsv1alpha1.SyncRunConditionType, status managedgitopsv1alpha1.GitOpsConditionStatus,
+	reason managedgitopsv1alpha1.SyncRunReasonType, message string, now metav1.Time) {
+
+	existing := findGitOpsDeploymentSyncRunCondition(*conditions, conditionType)
+	if existing == nil {
+		*conditions = append(*conditions, managedgitopsv1alpha1.GitOpsDeploymentSyncRunCondition{
+			Type:               conditionType,
+			Status:             status,
+			Reason:             reason,
+			Message:            message,
+			LastTransitionTime: &now,
+		})
+		return
+	}
+
+	if existing.Status != status || existing.LastTransitionTime == nil {
+		existing.LastTransitionTime = &now
+	}
+	existing.Status = status
+	existing.Reason = reason
+	existing.Message = message
+}
+
+// setGitOpsDeploymentCondition adds or updates the condition of the given type. The LastTransitionTime is only updated when
+// the status of the condition changes.
+func setGitOpsDeploymentCondition(conditions *[]managedgitopsv1alpha1.GitOpsDeploymentCondition,
+	conditionType managedgitopsv1alpha1.GitOpsDeploymentConditionType, status managedgitopsv1alpha1.GitOpsConditionStatus,
+	reason managedgitopsv1alpha1.GitOpsDeploymentReasonType, message string, now metav1.Time) {
+
+	existing := findGitOpsDeploymentCondition(*conditions, conditionType)
+	if existing == nil {
+		*conditions = append(*conditions, managedgitopsv1alpha1.GitOpsDeploymentCondition{
+			Type:               conditionType,
+			Status:             status,
+			Reason:             reason,
+			Message:            message,
+			LastTransitionTime: &now,
+		})
+		return
+	}
+
+	if existing.Status != status || existing.LastTransitionTime == nil {
+		existing.LastTransitionTime = &now
+	}
+	existing.Status = status
+	existing.Reason = reason
+	existing.Message = message
+}
@@ -4,7 +4,10 @@ import (
 	"context"
 	"fmt"
 	"testing"
+	"time"
 
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	operation "github.com/redhat-appstudio/managed-gitops/backend-shared/apis/managed-gitops/v1alpha1"
 	db "github.com/redhat-appstudio/managed-gitops/backend-shared/config/db"
 	dbutil "github.com/redhat-appstudio/managed-gitops/backend-shared/config/db/util"
@@ -14,6 +17,7 @@ import (
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/uuid"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -97,7 +101,7 @@ func TestApplicationEventLoopRunner_handleDeploymentModified(t *testing.T) {
 
 	a := applicationEventLoopRunner_Action{
 		// When the code asks for a new k8s client, give it our fake client
-		getK8sClientForGitOpsEngineInstance: func(gitopsEngineInstance *db.GitopsEngineInstance) (client.Client, error) {
+		getK8sClientForGitOpsEngineInstance: func(ctx context.Context, gitopsEngineInstance *db.GitopsEngineInstance) (client.Client, error) {
 			return k8sClient, nil
 		},
 		eventResourceName:           gitopsDepl.Name,
@@ -257,7 +261,7 @@ func TestApplicationEventLoopRunner_handleSyncRunModified(t *testing.T) {
 
 	a := applicationEventLoopRunner_Action{
 		// When the code asks for a new k8s client, give it our fake client
-		getK8sClientForGitOpsEngineInstance: func(gitopsEngineInstance *db.GitopsEngineInstance) (client.Client, error) {
+		getK8sClientForGitOpsEngineInstance: func(ctx context.Context, gitopsEngineInstance *db.GitopsEngineInstance) (client.Client, error) {
 			return k8sClient, nil
 		},
 		eventResourceName:           gitopsDepl.Name,
@@ -274,18 +278,628 @@ func TestApplicationEventLoopRunner_handleSyncRunModified(t *testing.T) {
 
 	a = applicationEventLoopRunner_Action{
 		// When the code asks for a new k8s client, give it our fake client
-		getK8sClientForGitOpsEngineInstance: func(gitopsEngineInstance *db.GitopsEngineInstance) (client.Client, error) {
+		getK8sClientForGitOpsEngineInstance: func(ctx context.Context, gitopsEngineInstance *db.GitopsEngineInstance) (client.Client, error) {
 			return k8sClient, nil
 		},
-		eventResourceName:       gitopsDeplSyncRun.Name,
-		eventResourceNamespace:  gitopsDeplSyncRun.Namespace,
-		workspaceClient:         k8sClient,
-		log:                     log.FromContext(context.Background()),
-		sharedResourceEventLoop: sharedResourceLoop,
-		workspaceID:             a.workspaceID,
+		eventResourceName:           gitopsDeplSyncRun.Name,
+		eventResourceNamespace:      gitopsDeplSyncRun.Namespace,
+		workspaceClient:             k8sClient,
+		log:                         log.FromContext(context.Background()),
+		sharedResourceEventLoop:     sharedResourceLoop,
+		workspaceID:                 a.workspaceID,
+		testOnlySkipCreateOperation: true,
 	}
 
 	_, err = a.applicationEventRunner_handleSyncRunModified(ctx, dbQueries)
 	assert.Nil(t, err)
 
 }
+
+func TestReportSyncOperationResult(t *testing.T) {
+
+	ctx := context.Background()
+
+	scheme, _, _, workspace := genericTestSetup(t)
+
+	newAction := func(syncRun *managedgitopsv1alpha1.GitOpsDeploymentSyncRun) (applicationEventLoopRunner_Action, client.Client) {
+		k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(syncRun).Build()
+		return applicationEventLoopRunner_Action{workspaceClient: k8sClient, log: log.FromContext(ctx)}, k8sClient
+	}
+
+	newSyncRun := func() *managedgitopsv1alpha1.GitOpsDeploymentSyncRun {
+		return &managedgitopsv1alpha1.GitOpsDeploymentSyncRun{
+			ObjectMeta: metav1.ObjectMeta{Name: "my-sync-run", Namespace: workspace.Name},
+			Spec:       managedgitopsv1alpha1.GitOpsDeploymentSyncRunSpec{GitopsDeploymentName: "my-gitops-depl"},
+		}
+	}
+
+	t.Run("The result of a completed sync is reported in the status of the sync run", func(t *testing.T) {
+		syncRun := newSyncRun()
+		a, k8sClient := newAction(syncRun)
+
+		dbQueries := &syncOperationTestDatabaseQueries{syncOperation: db.SyncOperation{SyncOperation_id: "my-sync-operation",
+			SyncResult_phase: db.SyncOperation_SyncResultPhase_Failed, SyncResult_message: "one or more objects failed to apply",
+			SyncResult_pruning_required: 2}}
+
+		err := a.reportSyncOperationResult(ctx, "my-sync-operation", syncRun, dbQueries)
+		assert.NoError(t, err, "a completed sync that failed should be reported, rather than retried")
+
+		updatedSyncRun := &managedgitopsv1alpha1.GitOpsDeploymentSyncRun{}
+		if assert.NoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(syncRun), updatedSyncRun)) {
+			assert.Equal(t, db.SyncOperation_SyncResultPhase_Failed, updatedSyncRun.Status.Phase)
+			assert.Equal(t, "one or more objects failed to apply", updatedSyncRun.Status.Message)
+			assert.Equal(t, 2, updatedSyncRun.Status.PruningRequired)
+		}
+	})
+
+	t.Run("A sync that has not completed is returned as an error, so that it is retried", func(t *testing.T) {
+		for _, phase := range []string{"", db.SyncOperation_SyncResultPhase_Running, db.SyncOperation_SyncResultPhase_Terminating} {
+			syncRun := newSyncRun()
+			a, k8sClient := newAction(syncRun)
+
+			dbQueries := &syncOperationTestDatabaseQueries{syncOperation: db.SyncOperation{SyncOperation_id: "my-sync-operation",
+				SyncResult_phase: phase}}
+
+			err := a.reportSyncOperationResult(ctx, "my-sync-operation", syncRun, dbQueries)
+			assert.Error(t, err, "phase: '%s'", phase)
+
+			updatedSyncRun := &managedgitopsv1alpha1.GitOpsDeploymentSyncRun{}
+			if assert.NoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(syncRun), updatedSyncRun)) {
+				assert.Empty(t, updatedSyncRun.Status.Phase)
+			}
+		}
+	})
+}
+
+// syncOperationTestDatabaseQueries implements the subset of DatabaseQueries that is used to report the result of a sync
+// operation: calls to any other function will panic.
+type syncOperationTestDatabaseQueries struct {
+	db.DatabaseQueries
+
+	syncOperation db.SyncOperation
+}
+
+func (dbq *syncOperationTestDatabaseQueries) GetSyncOperationById(ctx context.Context, syncOperation *db.SyncOperation) error {
+	if syncOperation.SyncOperation_id != dbq.syncOperation.SyncOperation_id {
+		return fmt.Errorf("unexpected sync operation: %s", syncOperation.SyncOperation_id)
+	}
+	*syncOperation = dbq.syncOperation
+	return nil
+}
+
+func TestUpdateGitOpsDeploymentConditions(t *testing.T) {
+
+	encodeConditions := func(conditions ...db.ApplicationStateCondition) string {
+		res, err := db.EncodeApplicationStateConditions(conditions)
+		assert.NoError(t, err)
+		return res
+	}
+
+	firstTime := metav1.NewTime(time.Now().Add(-1 * time.Hour))
+	secondTime := metav1.Now()
+
+	gitopsDepl := &managedgitopsv1alpha1.GitOpsDeployment{
+		Status: managedgitopsv1alpha1.GitOpsDeploymentStatus{
+			Conditions: []managedgitopsv1alpha1.GitOpsDeploymentCondition{
+				{
+					Type:    managedgitopsv1alpha1.GitOpsDeploymentConditionErrorOccurred,
+					Status:  managedgitopsv1alpha1.GitOpsConditionStatusTrue,
+					Reason:  managedgitopsv1alpha1.GitopsDeploymentReasonErrorOccurred,
+					Message: "an unrelated condition",
+				},
+			},
+		},
+	}
+
+	// Argo CD conditions should be mapped onto the GitOpsDeployment
+	updateGitOpsDeploymentConditions(gitopsDepl, db.ApplicationState{
+		Conditions: encodeConditions(db.ApplicationStateCondition{Type: "ComparisonError", Message: "repository not found"},
+			db.ApplicationStateCondition{Type: "OrphanedResourceWarning", Message: "ignored"}),
+	}, firstTime, log.FromContext(context.Background()))
+
+	assert.Len(t, gitopsDepl.Status.Conditions, 2)
+	comparisonError := findGitOpsDeploymentCondition(gitopsDepl.Status.Conditions, managedgitopsv1alpha1.GitOpsDeploymentConditionComparisonError)
+	if assert.NotNil(t, comparisonError) {
+		assert.Equal(t, managedgitopsv1alpha1.GitOpsConditionStatusTrue, comparisonError.Status)
+		assert.Equal(t, managedgitopsv1alpha1.GitopsDeploymentReasonComparisonError, comparisonError.Reason)
+		assert.Equal(t, "repository not found", comparisonError.Message)
+		assert.Equal(t, firstTime, *comparisonError.LastTransitionTime)
+	}
+
+	// A failed operation should be reported as a SyncError, and the resolved ComparisonError should be set to False
+	updateGitOpsDeploymentConditions(gitopsDepl, db.ApplicationState{
+		Operation_phase:   "Failed",
+		Operation_message: "one or more objects failed to apply",
+	}, secondTime, log.FromContext(context.Background()))
+
+	assert.Len(t, gitopsDepl.Status.Conditions, 3)
+
+	comparisonError = findGitOpsDeploymentCondition(gitopsDepl.Status.Conditions, managedgitopsv1alpha1.GitOpsDeploymentConditionComparisonError)
+	if assert.NotNil(t, comparisonError) {
+		assert.Equal(t, managedgitopsv1alpha1.GitOpsConditionStatusFalse, comparisonError.Status)
+		assert.Equal(t, managedgitopsv1alpha1.GitopsDeploymentReasonComparisonErrorResolved, comparisonError.Reason)
+		assert.Equal(t, secondTime, *comparisonError.LastTransitionTime)
+	}
+
+	syncError := findGitOpsDeploymentCondition(gitopsDepl.Status.Conditions, managedgitopsv1alpha1.GitOpsDeploymentConditionSyncError)
+	if assert.NotNil(t, syncError) {
+		assert.Equal(t, managedgitopsv1alpha1.GitOpsConditionStatusTrue, syncError.Status)
+		assert.Equal(t, managedgitopsv1alpha1.GitopsDeploymentReasonSyncOperationFailed, syncError.Reason)
+		assert.Equal(t, "one or more objects failed to apply", syncError.Message)
+	}
+
+	// Conditions of other types should be preserved
+	errorOccurred := findGitOpsDeploymentCondition(gitopsDepl.Status.Conditions, managedgitopsv1alpha1.GitOpsDeploymentConditionErrorOccurred)
+	if assert.NotNil(t, errorOccurred) {
+		assert.Equal(t, "an unrelated condition", errorOccurred.Message)
+	}
+
+	// The LastTransitionTime should not change if the status is unchanged
+	updateGitOpsDeploymentConditions(gitopsDepl, db.ApplicationState{}, metav1.NewTime(time.Now().Add(time.Hour)), log.FromContext(context.Background()))
+	comparisonError = findGitOpsDeploymentCondition(gitopsDepl.Status.Conditions, managedgitopsv1alpha1.GitOpsDeploymentConditionComparisonError)
+	if assert.NotNil(t, comparisonError) {
+		assert.Equal(t, secondTime, *comparisonError.LastTransitionTime)
+	}
+}
+
+func TestIsOperationCRStatusIncomplete(t *testing.T) {
+
+	ctx := context.Background()
+
+	scheme, argocdNamespace, _, _ := genericTestSetup(t)
+
+	newOperationCR := func(operationID string, state operation.OperationState) *operation.Operation {
+		return &operation.Operation{
+			ObjectMeta: metav1.ObjectMeta{Name: "operation-test", Namespace: argocdNamespace.Name},
+			Spec:       operation.OperationSpec{OperationID: operationID},
+			Status:     operation.OperationStatus{State: state},
+		}
+	}
+
+	tests := []struct {
+		name       string
+		existingCR *operation.Operation
+		expected   bool
+	}{
+		{"operation is in progress", newOperationCR("test-op", operation.OperationState_In_Progress), true},
+		{"operation is waiting", newOperationCR("test-op", operation.OperationState_Waiting), true},
+		{"operation has completed", newOperationCR("test-op", operation.OperationState_Completed), false},
+		{"operation has failed", newOperationCR("test-op", operation.OperationState_Failed), false},
+		{"status has not yet been set", newOperationCR("test-op", ""), false},
+		{"CR is for another operation", newOperationCR("other-op", operation.OperationState_In_Progress), false},
+		{"CR does not exist", nil, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			clientBuilder := fake.NewClientBuilder().WithScheme(scheme)
+			if test.existingCR != nil {
+				clientBuilder = clientBuilder.WithObjects(test.existingCR)
+			}
+
+			res := isOperationCRStatusIncomplete(ctx, newOperationCR("test-op", ""), clientBuilder.Build(), log.FromContext(ctx))
+			assert.Equal(t, test.expected, res)
+		})
+	}
+}
+
+func TestRequestOperationCancellation(t *testing.T) {
+
+	ctx := context.Background()
+
+	scheme, argocdNamespace, _, _ := genericTestSetup(t)
+
+	operationCR := &operation.Operation{
+		ObjectMeta: metav1.ObjectMeta{Name: "operation-test-op", Namespace: argocdNamespace.Name},
+		Spec:       operation.OperationSpec{OperationID: "test-op"},
+	}
+
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(operationCR).Build()
+
+	dbQueries := &operationTestDatabaseQueries{}
+	dbOperation := &db.Operation{Operation_id: "test-op"}
+
+	err := RequestOperationCancellation(ctx, dbOperation, operationCR, k8sClient, dbQueries, log.FromContext(ctx))
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"test-op"}, dbQueries.cancellationRequested)
+	assert.True(t, dbOperation.Cancel_requested)
+
+	updatedOperationCR := &operation.Operation{}
+	if assert.NoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(operationCR), updatedOperationCR)) {
+		assert.Equal(t, "true", updatedOperationCR.Annotations[operation.OperationCancellationRequestedAnnotation])
+	}
+}
+
+func TestWaitForOperationToComplete(t *testing.T) {
+
+	ctx := context.Background()
+
+	tests := []struct {
+		state                 string
+		expectNotCompletedErr bool
+	}{
+		{db.OperationState_Completed, false},
+		{db.OperationState_Failed, true},
+		{db.OperationState_Timeout, true},
+		{db.OperationState_Cancelled, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.state, func(t *testing.T) {
+
+			dbQueries := &operationTestDatabaseQueries{
+				operation: &db.Operation{Operation_id: "test-op", State: test.state, Human_readable_state: "some message"},
+			}
+			dbOperation := &db.Operation{Operation_id: "test-op"}
+
+			err := WaitForOperationToComplete(ctx, dbOperation, nil, nil, dbQueries, log.FromContext(ctx))
+			if !test.expectNotCompletedErr {
+				assert.NoError(t, err)
+				return
+			}
+
+			assert.True(t, IsOperationNotCompletedError(err))
+			assert.True(t, IsOperationNotCompletedError(fmt.Errorf("wrapped: %w", err)))
+			assert.Contains(t, err.Error(), test.state)
+			assert.Contains(t, err.Error(), "some message")
+		})
+	}
+}
+
+func TestOperationReaper(t *testing.T) {
+
+	ctx := context.Background()
+
+	now := time.Now()
+
+	dbQueries := &operationTestDatabaseQueries{
+		timedOutOperations: []db.Operation{
+			{Operation_id: "first", Resource_type: db.OperationResourceType_Application, State: db.OperationState_Timeout,
+				Created_on: now.Add(-time.Hour), Last_state_update: now},
+			{Operation_id: "second", Resource_type: db.OperationResourceType_SyncOperation, State: db.OperationState_Timeout,
+				Created_on: now.Add(-time.Hour), Last_state_update: now},
+		},
+	}
+	reaper := &OperationReaper{dbQueries: dbQueries}
+
+	err := reaper.timeoutExpiredOperations(ctx, now, log.FromContext(ctx))
+	assert.NoError(t, err)
+	assert.Equal(t, []time.Time{now}, dbQueries.timeoutRequests)
+
+	err = reaper.reportOutstandingOperations(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, dbQueries.incompleteOperationsRequests)
+}
+
+// operationTestDatabaseQueries implements the subset of DatabaseQueries that is used to cancel and time out
+// operations: calls to any other function will panic.
+type operationTestDatabaseQueries struct {
+	db.DatabaseQueries
+
+	cancellationRequested []string
+
+	timedOutOperations []db.Operation
+	timeoutRequests    []time.Time
+
+	incompleteOperationsRequests int
+
+	operation *db.Operation
+}
+
+func (dbq *operationTestDatabaseQueries) GetOperationById(ctx context.Context, operation *db.Operation) error {
+	if dbq.operation == nil || operation.Operation_id != dbq.operation.Operation_id {
+		return db.NewResultNotFoundError("operation")
+	}
+	*operation = *dbq.operation
+	return nil
+}
+
+func (dbq *operationTestDatabaseQueries) RequestOperationCancellation(ctx context.Context, obj *db.Operation) error {
+	dbq.cancellationRequested = append(dbq.cancellationRequested, obj.Operation_id)
+	obj.Cancel_requested = true
+	return nil
+}
+
+func (dbq *operationTestDatabaseQueries) TimeoutExpiredOperations(ctx context.Context, now time.Time, operations *[]db.Operation) error {
+	dbq.timeoutRequests = append(dbq.timeoutRequests, now)
+	*operations = dbq.timedOutOperations
+	return nil
+}
+
+func (dbq *operationTestDatabaseQueries) ListIncompleteOperations(ctx context.Context, operations *[]db.Operation) error {
+	dbq.incompleteOperationsRequests++
+	*operations = []db.Operation{}
+	return nil
+}
+
+func TestProcessApplicationEvent(t *testing.T) {
+
+	scheme, _, _, workspace := genericTestSetup(t)
+
+	defaultMaxAttempts := applicationEventMaxAttempts
+	defaultDeadline := applicationEventDeadline
+	defer func() {
+		applicationEventMaxAttempts = defaultMaxAttempts
+		applicationEventDeadline = defaultDeadline
+	}()
+
+	newGitOpsDeploymentEvent := func() (*eventLoopEvent, *managedgitopsv1alpha1.GitOpsDeployment) {
+		gitopsDepl := &managedgitopsv1alpha1.GitOpsDeployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "my-gitops-depl", Namespace: workspace.Name, UID: uuid.NewUUID()},
+		}
+		event := &eventLoopEvent{
+			eventType:               DeploymentModified,
+			request:                 ctrl.Request{NamespacedName: client.ObjectKeyFromObject(gitopsDepl)},
+			client:                  fake.NewClientBuilder().WithScheme(scheme).WithObjects(gitopsDepl).Build(),
+			reqResource:             managedgitopsv1alpha1.GitOpsDeploymentTypeName,
+			associatedGitopsDeplUID: string(gitopsDepl.UID),
+			workspaceID:             string(workspace.UID),
+			superseded:              make(chan struct{}),
+		}
+		return event, gitopsDepl
+	}
+
+	// failingHandler returns a handler that fails the first 'failures' attempts, and counts the attempts
+	failingHandler := func(failures int, attempts *int) applicationEventHandler {
+		return func(ctx context.Context, event *eventLoopEvent, log logr.Logger) (bool, error) {
+			*attempts++
+			if *attempts <= failures {
+				return false, fmt.Errorf("attempt %d failed", *attempts)
+			}
+			return true, nil
+		}
+	}
+
+	logger := log.FromContext(context.Background())
+
+	t.Run("A failing event is retried until it succeeds", func(t *testing.T) {
+		applicationEventMaxAttempts = 5
+		applicationEventDeadline = defaultDeadline
+
+		retries := testutil.ToFloat64(eventLoopEventRetries.WithLabelValues(eventLoopStage_Application, string(DeploymentModified)))
+
+		event, _ := newGitOpsDeploymentEvent()
+		attempts := 0
+		signalledShutdown, deadLettered := processApplicationEvent(context.Background(), event, failingHandler(2, &attempts), applicationEventFailureConditions{}, logger)
+		assert.True(t, signalledShutdown)
+		assert.False(t, deadLettered)
+		assert.Equal(t, 3, attempts)
+		assert.Equal(t, retries+2, testutil.ToFloat64(eventLoopEventRetries.WithLabelValues(eventLoopStage_Application, string(DeploymentModified))))
+	})
+
+	t.Run("A failing event is dead-lettered after the maximum number of attempts, and the failure is reported on the CR", func(t *testing.T) {
+		applicationEventMaxAttempts = 3
+		applicationEventDeadline = defaultDeadline
+
+		deadLetteredEvents := testutil.ToFloat64(applicationEventsDeadLettered.WithLabelValues(string(DeploymentModified)))
+
+		event, gitopsDepl := newGitOpsDeploymentEvent()
+		failureConditions := applicationEventFailureConditions{}
+		attempts := 0
+		signalledShutdown, deadLettered := processApplicationEvent(context.Background(), event, failingHandler(100, &attempts), failureConditions, logger)
+		assert.False(t, signalledShutdown)
+		assert.True(t, deadLettered)
+		assert.Equal(t, 3, attempts)
+		assert.Equal(t, deadLetteredEvents+1, testutil.ToFloat64(applicationEventsDeadLettered.WithLabelValues(string(DeploymentModified))))
+
+		err := event.client.Get(context.Background(), client.ObjectKeyFromObject(gitopsDepl), gitopsDepl)
+		assert.NoError(t, err)
+		errorOccurred := findGitOpsDeploymentCondition(gitopsDepl.Status.Conditions, managedgitopsv1alpha1.GitOpsDeploymentConditionErrorOccurred)
+		if assert.NotNil(t, errorOccurred) {
+			assert.Equal(t, managedgitopsv1alpha1.GitOpsConditionStatusTrue, errorOccurred.Status)
+			assert.Equal(t, managedgitopsv1alpha1.GitopsDeploymentReasonEventProcessingFailed, errorOccurred.Reason)
+			assert.Contains(t, errorOccurred.Message, "attempt 3 failed")
+		}
+
+		// Once a later event for the CR succeeds, the condition is resolved
+		newEvent := *event
+		attempts = 0
+		succeedingHandler := func(ctx context.Context, event *eventLoopEvent, log logr.Logger) (bool, error) {
+			return false, nil
+		}
+		processApplicationEvent(context.Background(), &newEvent, succeedingHandler, failureConditions, logger)
+
+		err = event.client.Get(context.Background(), client.ObjectKeyFromObject(gitopsDepl), gitopsDepl)
+		assert.NoError(t, err)
+		errorOccurred = findGitOpsDeploymentCondition(gitopsDepl.Status.Conditions, managedgitopsv1alpha1.GitOpsDeploymentConditionErrorOccurred)
+		if assert.NotNil(t, errorOccurred) {
+			assert.Equal(t, managedgitopsv1alpha1.GitOpsConditionStatusFalse, errorOccurred.Status)
+			assert.Equal(t, managedgitopsv1alpha1.GitopsDeploymentReasonEventProcessingResolved, errorOccurred.Reason)
+		}
+
+		// Once the condition is resolved, the CR is no longer retrieved after each successful event
+		countingClient := &getCountingClient{Client: event.client}
+		newEvent.client = countingClient
+		processApplicationEvent(context.Background(), &newEvent, succeedingHandler, failureConditions, logger)
+		assert.Equal(t, 0, countingClient.gets)
+
+		// ... but it is, for a CR whose condition has not been checked yet
+		processApplicationEvent(context.Background(), &newEvent, succeedingHandler, applicationEventFailureConditions{}, logger)
+		assert.Equal(t, 1, countingClient.gets)
+	})
+
+	t.Run("A failing sync run event is reported on the GitOpsDeploymentSyncRun CR", func(t *testing.T) {
+		applicationEventMaxAttempts = 1
+		applicationEventDeadline = defaultDeadline
+
+		syncRun := &managedgitopsv1alpha1.GitOpsDeploymentSyncRun{
+			ObjectMeta: metav1.ObjectMeta{Name: "my-sync-run", Namespace: workspace.Name, UID: uuid.NewUUID()},
+		}
+		event := &eventLoopEvent{
+			eventType:               SyncRunModified,
+			request:                 ctrl.Request{NamespacedName: client.ObjectKeyFromObject(syncRun)},
+			client:                  fake.NewClientBuilder().WithScheme(scheme).WithObjects(syncRun).Build(),
+			reqResource:             managedgitopsv1alpha1.GitOpsDeploymentSyncRunTypeName,
+			associatedGitopsDeplUID: "my-gitops-depl-uid",
+		}
+
+		attempts := 0
+		_, deadLettered := processApplicationEvent(context.Background(), event, failingHandler(100, &attempts), applicationEventFailureConditions{}, logger)
+		assert.True(t, deadLettered)
+
+		err := event.client.Get(context.Background(), client.ObjectKeyFromObject(syncRun), syncRun)
+		assert.NoError(t, err)
+		errorOccurred := findGitOpsDeploymentSyncRunCondition(syncRun.Status.Conditions, managedgitopsv1alpha1.GitOpsDeploymentSyncRunConditionErrorOccurred)
+		if assert.NotNil(t, errorOccurred) {
+			assert.Equal(t, managedgitopsv1alpha1.GitOpsConditionStatusTrue, errorOccurred.Status)
+			assert.Equal(t, managedgitopsv1alpha1.SyncRunReasonEventProcessingFailed, errorOccurred.Reason)
+		}
+	})
+
+	t.Run("A failing event is dead-lettered once the deadline has passed", func(t *testing.T) {
+		applicationEventMaxAttempts = 1000
+		applicationEventDeadline = 500 * time.Millisecond
+
+		event, _ := newGitOpsDeploymentEvent()
+		attempts := 0
+		start := time.Now()
+		_, deadLettered := processApplicationEvent(context.Background(), event, failingHandler(100000, &attempts), applicationEventFailureConditions{}, logger)
+		assert.True(t, deadLettered)
+		assert.Less(t, time.Since(start), 10*time.Second)
+		assert.Less(t, attempts, 1000)
+	})
+
+	t.Run("A failing event is not retried once it is superseded by a newer event", func(t *testing.T) {
+		applicationEventMaxAttempts = 1000
+		applicationEventDeadline = defaultDeadline
+
+		event, gitopsDepl := newGitOpsDeploymentEvent()
+		attempts := 0
+		handler := func(ctx context.Context, event *eventLoopEvent, log logr.Logger) (bool, error) {
+			attempts++
+			event.markSuperseded()
+			return false, fmt.Errorf("attempt %d failed", attempts)
+		}
+		signalledShutdown, deadLettered := processApplicationEvent(context.Background(), event, handler, applicationEventFailureConditions{}, logger)
+		assert.False(t, signalledShutdown)
+		assert.Equal(t, 1, attempts)
+
+		// A superseded event is not dead-lettered
+		assert.False(t, deadLettered)
+		err := event.client.Get(context.Background(), client.ObjectKeyFromObject(gitopsDepl), gitopsDepl)
+		assert.NoError(t, err)
+		assert.Nil(t, findGitOpsDeploymentCondition(gitopsDepl.Status.Conditions, managedgitopsv1alpha1.GitOpsDeploymentConditionErrorOccurred))
+	})
+}
+
+// getCountingClient counts the calls to Get, of the client it wraps.
+type getCountingClient struct {
+	client.Client
+	gets int
+}
+
+func (c *getCountingClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	c.gets++
+	return c.Client.Get(ctx, key, obj)
+}
+
+func TestStartDeadLetterRequeueTimer(t *testing.T) {
+
+	defaultInterval := applicationEventDeadLetterRequeueInterval
+	applicationEventDeadLetterRequeueInterval = 10 * time.Millisecond
+	defer func() {
+		applicationEventDeadLetterRequeueInterval = defaultInterval
+	}()
+
+	logger := log.FromContext(context.Background())
+
+	event := &eventLoopEvent{
+		eventType:               DeploymentModified,
+		request:                 ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "my-namespace", Name: "my-gitops-depl"}},
+		reqResource:             managedgitopsv1alpha1.GitOpsDeploymentTypeName,
+		associatedGitopsDeplUID: "my-gitops-depl-uid",
+		superseded:              make(chan struct{}),
+	}
+
+	t.Run("A dead-lettered event is sent back to the application event loop", func(t *testing.T) {
+
+		input := make(chan applicationEventLoopMessage)
+		startDeadLetterRequeueTimer(context.Background(), input, event, logger)
+
+		select {
+		case msg := <-input:
+			assert.Equal(t, applicationEventLoopMessageType_Event, msg.messageType)
+			assert.Equal(t, event.request, msg.event.request)
+			assert.Equal(t, event.associatedGitopsDeplUID, msg.event.associatedGitopsDeplUID)
+			assert.NotSame(t, event, msg.event)
+			assert.Nil(t, msg.event.superseded, "the requeued event is a new work item")
+			assert.Equal(t, 1, msg.event.deadLetterRequeues)
+		case <-time.After(5 * time.Second):
+			t.Fatal("the event was not requeued")
+		}
+	})
+
+	t.Run("The event is no longer requeued once it reaches the maximum number of requeues", func(t *testing.T) {
+
+		exhausted := testutil.ToFloat64(applicationEventsDeadLetterRequeuesExhausted.WithLabelValues(string(DeploymentModified)))
+
+		exhaustedEvent := *event
+		exhaustedEvent.deadLetterRequeues = applicationEventDeadLetterMaxRequeues
+
+		input := make(chan applicationEventLoopMessage)
+		startDeadLetterRequeueTimer(context.Background(), input, &exhaustedEvent, logger)
+
+		select {
+		case <-input:
+			t.Fatal("the event should not be requeued")
+		case <-time.After(50 * time.Millisecond):
+		}
+		assert.Equal(t, exhausted+1, testutil.ToFloat64(applicationEventsDeadLetterRequeuesExhausted.WithLabelValues(string(DeploymentModified))))
+	})
+
+	t.Run("The requeue interval is doubled for each requeue, up to the maximum", func(t *testing.T) {
+
+		defaultMaxInterval := applicationEventDeadLetterRequeueMaxInterval
+		applicationEventDeadLetterRequeueMaxInterval = 50 * time.Millisecond
+		defer func() {
+			applicationEventDeadLetterRequeueMaxInterval = defaultMaxInterval
+		}()
+
+		assert.Equal(t, 10*time.Millisecond, deadLetterRequeueInterval(0))
+		assert.Equal(t, 20*time.Millisecond, deadLetterRequeueInterval(1))
+		assert.Equal(t, 40*time.Millisecond, deadLetterRequeueInterval(2))
+		assert.Equal(t, 50*time.Millisecond, deadLetterRequeueInterval(3))
+		assert.Equal(t, 50*time.Millisecond, deadLetterRequeueInterval(100))
+	})
+
+	t.Run("The event is not requeued once the application event loop has ended", func(t *testing.T) {
+
+		input := make(chan applicationEventLoopMessage)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		startDeadLetterRequeueTimer(ctx, input, event, logger)
+
+		select {
+		case <-input:
+			t.Fatal("the event should not be requeued")
+		case <-time.After(50 * time.Millisecond):
+		}
+	})
+}
+
+func TestApplicationEventQueueLoopStopsWhenWorkspaceIsNotOwned(t *testing.T) {
+
+	// The ownership is only checked when a message is received, so it is not modified concurrently
+	owned := true
+	ownsWorkspace := func(workspaceID string) bool {
+		return owned
+	}
+
+	loop := startApplicationEventQueueLoop("my-gitops-depl-uid", "my-workspace", nil, nil, ownsWorkspace)
+
+	// The workspace is lost: the next status tick is dropped, and the loop ends, as no events are active
+	owned = false
+
+	sendWithTimeout(t, loop.input, &eventLoopEvent{
+		eventType:               UpdateDeploymentStatusTick,
+		associatedGitopsDeplUID: "my-gitops-depl-uid",
+	})
+
+	select {
+	case <-loop.terminated:
+	case <-time.After(eventLoopTestTimeout):
+		t.Fatal("the application event loop did not stop, after the workspace was lost")
+	}
+}
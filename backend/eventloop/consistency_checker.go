@@ -0,0 +1,702 @@
+package eventloop
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	db "github.com/redhat-appstudio/managed-gitops/backend-shared/config/db"
+	sharedutil "github.com/redhat-appstudio/managed-gitops/backend-shared/util"
+	managedgitopsv1alpha1 "github.com/redhat-appstudio/managed-gitops/backend/apis/managed-gitops/v1alpha1"
+	apierr "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// consistencyCheckGracePeriod: K8s resources and Application rows that were created more recently than this are not
+// checked, as the backend may not yet have finished processing them.
+var consistencyCheckGracePeriod = 5 * time.Minute
+
+const (
+	// consistencyCheckClusterUserName is the name of the ClusterUser that owns the operations created by repairs,
+	// when there is no workspace to attribute the operation to.
+	consistencyCheckClusterUserName = "gitops-db-check"
+
+	// argoCDApplicationDatabaseIDLabel is the label that the cluster-agent adds to Argo CD Applications, containing the
+	// primary key of the corresponding Application row.
+	argoCDApplicationDatabaseIDLabel = "databaseID"
+)
+
+var argoCDApplicationListGVK = schema.GroupVersionKind{Group: "argoproj.io", Version: "v1alpha1", Kind: "ApplicationList"}
+
+// ConsistencyFindingType identifies an inconsistency between database rows, workspace CRs, and Argo CD Applications.
+//
+// Note: Some relationships (for example, DeploymentToApplicationMapping -> Application, ApplicationState -> Application,
+// and ClusterAccess -> ClusterUser/ManagedEnvironment/GitopsEngineInstance) are enforced by foreign key constraints,
+// and so are not checked.
+type ConsistencyFindingType string
+
+const (
+	// An Application row that is not referenced by any DeploymentToApplicationMapping
+	ConsistencyFinding_ApplicationWithoutDeploymentToApplicationMapping ConsistencyFindingType = "ApplicationWithoutDeploymentToApplicationMapping"
+
+	// A DeploymentToApplicationMapping row whose GitOpsDeployment CR no longer exists
+	ConsistencyFinding_GitOpsDeploymentMissing ConsistencyFindingType = "GitOpsDeploymentMissing"
+
+	// A GitOpsDeployment CR that has no DeploymentToApplicationMapping row
+	ConsistencyFinding_DeploymentToApplicationMappingMissing ConsistencyFindingType = "DeploymentToApplicationMappingMissing"
+
+	// An APICRToDatabaseMapping row whose SyncOperation row no longer exists
+	ConsistencyFinding_SyncOperationMissing ConsistencyFindingType = "SyncOperationMissing"
+
+	// An APICRToDatabaseMapping row whose GitOpsDeploymentSyncRun CR no longer exists
+	ConsistencyFinding_GitOpsDeploymentSyncRunMissing ConsistencyFindingType = "GitOpsDeploymentSyncRunMissing"
+
+	// A KubernetesToDBResourceMapping row whose database row no longer exists
+	ConsistencyFinding_KubernetesToDBResourceMappingTargetMissing ConsistencyFindingType = "KubernetesToDBResourceMappingTargetMissing"
+
+	// An Application row whose Argo CD Application does not exist
+	ConsistencyFinding_ArgoCDApplicationMissing ConsistencyFindingType = "ArgoCDApplicationMissing"
+
+	// An Argo CD Application (created by the cluster-agent) whose Application row no longer exists
+	ConsistencyFinding_ApplicationMissing ConsistencyFindingType = "ApplicationMissing"
+)
+
+// ConsistencyFinding is a single inconsistency found by the ConsistencyChecker.
+type ConsistencyFinding struct {
+	Type ConsistencyFindingType `json:"type"`
+
+	// Table and Key identify the database row of the finding (if applicable)
+	Table string `json:"table,omitempty"`
+	Key   string `json:"key,omitempty"`
+
+	// Resource identifies the K8s resource of the finding (if applicable), as 'kind namespace/name'
+	Resource string `json:"resource,omitempty"`
+
+	Message string `json:"message"`
+
+	// Repairable is true if the ConsistencyChecker is able to repair the inconsistency
+	Repairable bool `json:"repairable"`
+
+	// Repaired is true if the inconsistency was repaired, and RepairError contains the error if the repair failed
+	Repaired    bool   `json:"repaired"`
+	RepairError string `json:"repairError,omitempty"`
+
+	// repair repairs the inconsistency: nil if it is not repairable
+	repair func(ctx context.Context) error
+}
+
+// ConsistencyReport is the result of a consistency check, and is intended to be output as JSON.
+type ConsistencyReport struct {
+	StartTime time.Time `json:"startTime"`
+
+	// DryRun is true if repairs were not performed
+	DryRun bool `json:"dryRun"`
+
+	Findings []ConsistencyFinding `json:"findings"`
+
+	// Errors contains the checks that could not be completed (for example, because a cluster was unreachable)
+	Errors []string `json:"errors,omitempty"`
+}
+
+// UnrepairedFindings returns the number of findings that were not repaired.
+func (report ConsistencyReport) UnrepairedFindings() int {
+	res := 0
+	for _, finding := range report.Findings {
+		if !finding.Repaired {
+			res++
+		}
+	}
+	return res
+}
+
+// ConsistencyChecker detects orphaned database rows, and mismatches between database rows, workspace CRs (GitOpsDeployment,
+// GitOpsDeploymentSyncRun), and Argo CD Applications. These may be left behind by bugs, or by crashes during rollback
+// of partially created resources (see 'DisposeApplicationScopedResources').
+//
+// Repairs of Applications are performed by deleting the database rows, then creating an Operation: the cluster-agent
+// will then create (or delete) the Argo CD Application, to match the database.
+type ConsistencyChecker struct {
+	dbQueries db.DatabaseQueries
+
+	// workspaceClient is the client for the cluster containing the workspaces (the API namespaces)
+	workspaceClient client.Client
+
+	// getK8sClientForGitOpsEngineInstance returns the client for the cluster of the GitOps engine instance: see 'gitopsEngineClientCache'
+	getK8sClientForGitOpsEngineInstance func(ctx context.Context, gitopsEngineInstance *db.GitopsEngineInstance) (client.Client, error)
+}
+
+// NewConsistencyChecker creates a new ConsistencyChecker.
+func NewConsistencyChecker(dbQueries db.DatabaseQueries, workspaceClient client.Client) *ConsistencyChecker {
+	return &ConsistencyChecker{
+		dbQueries:                           dbQueries,
+		workspaceClient:                     workspaceClient,
+		getK8sClientForGitOpsEngineInstance: actionGetK8sClientForGitOpsEngineInstance,
+	}
+}
+
+// Check detects inconsistencies, and repairs them if 'repair' is true (otherwise, it is a dry run). An error is only
+// returned if the check could not be performed: errors from individual checks and repairs are included in the report.
+func (checker *ConsistencyChecker) Check(ctx context.Context, repair bool, log logr.Logger) (ConsistencyReport, error) {
+
+	report := ConsistencyReport{
+		StartTime: time.Now(),
+		DryRun:    !repair,
+		Findings:  []ConsistencyFinding{},
+	}
+
+	// Database checks: if these fail, the database is likely unavailable, so the check is not continued.
+	dbChecks := []func(ctx context.Context, report *ConsistencyReport) error{
+		checker.checkApplications,
+		checker.checkAPICRToDatabaseMappings,
+		checker.checkKubernetesToDBResourceMappings,
+	}
+	for _, dbCheck := range dbChecks {
+		if err := dbCheck(ctx, &report); err != nil {
+			return report, err
+		}
+	}
+
+	// Checks against workspace CRs and Argo CD Applications
+	if err := checker.checkDeploymentToApplicationMappings(ctx, &report); err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("unable to check GitOpsDeployments: %v", err))
+	}
+	if err := checker.checkGitOpsDeploymentSyncRuns(ctx, &report); err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("unable to check GitOpsDeploymentSyncRuns: %v", err))
+	}
+	if err := checker.checkArgoCDApplications(ctx, &report); err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("unable to check Argo CD Applications: %v", err))
+	}
+
+	if !repair {
+		return report, nil
+	}
+
+	for idx := range report.Findings {
+		finding := &report.Findings[idx]
+
+		if finding.repair == nil {
+			continue
+		}
+
+		if err := finding.repair(ctx); err != nil {
+			log.Error(err, "unable to repair inconsistency", "type", finding.Type, "table", finding.Table, "key", finding.Key,
+				"resource", finding.Resource)
+			finding.RepairError = err.Error()
+			continue
+		}
+
+		log.Info("Repaired inconsistency", "type", finding.Type, "table", finding.Table, "key", finding.Key, "resource", finding.Resource)
+		finding.Repaired = true
+	}
+
+	return report, nil
+}
+
+func (report *ConsistencyReport) addFinding(finding ConsistencyFinding) {
+	finding.Repairable = finding.repair != nil
+	report.Findings = append(report.Findings, finding)
+}
+
+func (report *ConsistencyReport) hasFinding(table string, key string) bool {
+	for _, finding := range report.Findings {
+		if finding.Table == table && finding.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// checkApplications checks for Applications that are not referenced by a GitOpsDeployment (via DeploymentToApplicationMapping)
+func (checker *ConsistencyChecker) checkApplications(ctx context.Context, report *ConsistencyReport) error {
+
+	var applications []db.Application
+	if err := checker.dbQueries.ListApplicationsWithoutDeploymentToApplicationMapping(ctx, &applications); err != nil {
+		return err
+	}
+
+	for idx := range applications {
+		application := applications[idx]
+
+		// The Application row is created before its mapping
+		if isApplicationWithinGracePeriod(application) {
+			continue
+		}
+
+		report.addFinding(ConsistencyFinding{
+			Type:    ConsistencyFinding_ApplicationWithoutDeploymentToApplicationMapping,
+			Table:   "Application",
+			Key:     application.Application_id,
+			Message: "Application is not referenced by any DeploymentToApplicationMapping",
+			repair: func(ctx context.Context) error {
+
+				err := checker.deleteApplication(ctx, application.Application_id, consistencyCheckClusterUserName, func(tx db.ApplicationScopedQueries) error {
+
+					dbq, ok := tx.(db.DatabaseQueries)
+					if !ok {
+						return fmt.Errorf("SEVERE: unexpected cast failure")
+					}
+
+					// Confirm the Application is still orphaned: the mapping cannot be created while the row is locked.
+					deplToAppMapping := db.DeploymentToApplicationMapping{Application_id: application.Application_id}
+					if err := dbq.GetDeploymentToApplicationMappingByApplicationId(ctx, &deplToAppMapping); err == nil {
+						return fmt.Errorf("application is now referenced by DeploymentToApplicationMapping '%s'", deplToAppMapping.Deploymenttoapplicationmapping_uid_id)
+					} else if !db.IsResultNotFoundError(err) {
+						return err
+					}
+
+					return nil
+				})
+
+				if db.IsResultNotFoundError(err) {
+					// The Application has since been deleted
+					return nil
+				}
+
+				return err
+			},
+		})
+	}
+
+	return nil
+}
+
+// checkDeploymentToApplicationMappings checks for DeploymentToApplicationMappings whose GitOpsDeployment no longer
+// exists, and for GitOpsDeployments that have no DeploymentToApplicationMapping.
+func (checker *ConsistencyChecker) checkDeploymentToApplicationMappings(ctx context.Context, report *ConsistencyReport) error {
+
+	var deplToAppMappings []db.DeploymentToApplicationMapping
+	if err := checker.dbQueries.ListDeploymentToApplicationMappings(ctx, &deplToAppMappings); err != nil {
+		return err
+	}
+
+	for idx := range deplToAppMappings {
+		deplToAppMapping := deplToAppMappings[idx]
+
+		gitopsDeployment := &managedgitopsv1alpha1.GitOpsDeployment{}
+		err := checker.workspaceClient.Get(ctx, types.NamespacedName{Namespace: deplToAppMapping.DeploymentNamespace,
+			Name: deplToAppMapping.DeploymentName}, gitopsDeployment)
+		if err != nil && !apierr.IsNotFound(err) {
+			return err
+		}
+
+		// The GitOpsDeployment exists, and is the one referenced by the mapping
+		if err == nil && string(gitopsDeployment.UID) == deplToAppMapping.Deploymenttoapplicationmapping_uid_id {
+			continue
+		}
+
+		report.addFinding(ConsistencyFinding{
+			Type:     ConsistencyFinding_GitOpsDeploymentMissing,
+			Table:    "DeploymentToApplicationMapping",
+			Key:      deplToAppMapping.Deploymenttoapplicationmapping_uid_id,
+			Resource: "GitOpsDeployment " + deplToAppMapping.DeploymentNamespace + "/" + deplToAppMapping.DeploymentName,
+			Message:  "GitOpsDeployment referenced by DeploymentToApplicationMapping no longer exists",
+			repair: func(ctx context.Context) error {
+
+				// TODO: GITOPS-1577 - KCP support: for now, we assume that the workspace UID is the user id.
+				err := checker.deleteApplication(ctx, deplToAppMapping.Application_id, deplToAppMapping.WorkspaceUID, func(tx db.ApplicationScopedQueries) error {
+					_, err := tx.DeleteDeploymentToApplicationMappingByDeplId(ctx, deplToAppMapping.Deploymenttoapplicationmapping_uid_id)
+					return err
+				})
+
+				if db.IsResultNotFoundError(err) {
+					// The Application no longer exists, so only the mapping remains
+					_, err = checker.dbQueries.DeleteDeploymentToApplicationMappingByDeplId(ctx, deplToAppMapping.Deploymenttoapplicationmapping_uid_id)
+				}
+
+				return err
+			},
+		})
+	}
+
+	var gitopsDeployments managedgitopsv1alpha1.GitOpsDeploymentList
+	if err := checker.workspaceClient.List(ctx, &gitopsDeployments); err != nil {
+		return err
+	}
+
+	for idx := range gitopsDeployments.Items {
+		gitopsDeployment := gitopsDeployments.Items[idx]
+
+		if time.Since(gitopsDeployment.CreationTimestamp.Time) < consistencyCheckGracePeriod || gitopsDeployment.DeletionTimestamp != nil {
+			continue
+		}
+
+		deplToAppMapping := db.DeploymentToApplicationMapping{Deploymenttoapplicationmapping_uid_id: string(gitopsDeployment.UID)}
+		if err := checker.dbQueries.GetDeploymentToApplicationMappingByDeplId(ctx, &deplToAppMapping); err == nil {
+			continue
+		} else if !db.IsResultNotFoundError(err) {
+			return err
+		}
+
+		// Not repairable here: the backend will create the mapping when the GitOpsDeployment is next reconciled.
+		report.addFinding(ConsistencyFinding{
+			Type:     ConsistencyFinding_DeploymentToApplicationMappingMissing,
+			Resource: "GitOpsDeployment " + gitopsDeployment.Namespace + "/" + gitopsDeployment.Name,
+			Message:  "GitOpsDeployment has no DeploymentToApplicationMapping",
+		})
+	}
+
+	return nil
+}
+
+// checkAPICRToDatabaseMappings checks for APICRToDatabaseMappings whose database row no longer exists
+func (checker *ConsistencyChecker) checkAPICRToDatabaseMappings(ctx context.Context, report *ConsistencyReport) error {
+
+	var apiCRToDBMappings []db.APICRToDatabaseMapping
+	if err := checker.dbQueries.ListAPICRToDatabaseMappingsWithMissingDBRelation(ctx, &apiCRToDBMappings); err != nil {
+		return err
+	}
+
+	for idx := range apiCRToDBMappings {
+		apiCRToDBMapping := apiCRToDBMappings[idx]
+
+		report.addFinding(ConsistencyFinding{
+			Type:     ConsistencyFinding_SyncOperationMissing,
+			Table:    "APICRToDatabaseMapping",
+			Key:      apiCRToDBMapping.APIResourceUID,
+			Resource: apiCRToDBMapping.APIResourceType + " " + apiCRToDBMapping.APIResourceNamespace + "/" + apiCRToDBMapping.APIResourceName,
+			Message:  fmt.Sprintf("%s '%s' referenced by APICRToDatabaseMapping no longer exists", apiCRToDBMapping.DBRelationType, apiCRToDBMapping.DBRelationKey),
+			repair: func(ctx context.Context) error {
+				_, err := checker.dbQueries.DeleteAPICRToDatabaseMapping(ctx, &apiCRToDBMapping)
+				return err
+			},
+		})
+	}
+
+	return nil
+}
+
+// checkGitOpsDeploymentSyncRuns checks for APICRToDatabaseMappings whose GitOpsDeploymentSyncRun no longer exists
+func (checker *ConsistencyChecker) checkGitOpsDeploymentSyncRuns(ctx context.Context, report *ConsistencyReport) error {
+
+	var apiCRToDBMappings []db.APICRToDatabaseMapping
+	if err := checker.dbQueries.ListAPICRToDatabaseMappings(ctx, &apiCRToDBMappings); err != nil {
+		return err
+	}
+
+	for idx := range apiCRToDBMappings {
+		apiCRToDBMapping := apiCRToDBMappings[idx]
+
+		if apiCRToDBMapping.APIResourceType != db.APICRToDatabaseMapping_ResourceType_GitOpsDeploymentSyncRun {
+			continue
+		}
+
+		// The mapping was already reported by 'checkAPICRToDatabaseMappings'
+		if report.hasFinding("APICRToDatabaseMapping", apiCRToDBMapping.APIResourceUID) {
+			continue
+		}
+
+		syncRun := &managedgitopsv1alpha1.GitOpsDeploymentSyncRun{}
+		err := checker.workspaceClient.Get(ctx, types.NamespacedName{Namespace: apiCRToDBMapping.APIResourceNamespace,
+			Name: apiCRToDBMapping.APIResourceName}, syncRun)
+		if err != nil && !apierr.IsNotFound(err) {
+			return err
+		}
+
+		if err == nil && string(syncRun.UID) == apiCRToDBMapping.APIResourceUID {
+			continue
+		}
+
+		// Once the mapping is deleted, the SyncOperation will be deleted by the OperationCollector.
+		report.addFinding(ConsistencyFinding{
+			Type:     ConsistencyFinding_GitOpsDeploymentSyncRunMissing,
+			Table:    "APICRToDatabaseMapping",
+			Key:      apiCRToDBMapping.APIResourceUID,
+			Resource: apiCRToDBMapping.APIResourceType + " " + apiCRToDBMapping.APIResourceNamespace + "/" + apiCRToDBMapping.APIResourceName,
+			Message:  "GitOpsDeploymentSyncRun referenced by APICRToDatabaseMapping no longer exists",
+			repair: func(ctx context.Context) error {
+				_, err := checker.dbQueries.DeleteAPICRToDatabaseMapping(ctx, &apiCRToDBMapping)
+				return err
+			},
+		})
+	}
+
+	return nil
+}
+
+// checkKubernetesToDBResourceMappings checks for KubernetesToDBResourceMappings whose database row no longer exists
+func (checker *ConsistencyChecker) checkKubernetesToDBResourceMappings(ctx context.Context, report *ConsistencyReport) error {
+
+	var kubernetesToDBMappings []db.KubernetesToDBResourceMapping
+	if err := checker.dbQueries.ListKubernetesToDBResourceMappingsWithMissingDBRelation(ctx, &kubernetesToDBMappings); err != nil {
+		return err
+	}
+
+	for idx := range kubernetesToDBMappings {
+		kubernetesToDBMapping := kubernetesToDBMappings[idx]
+
+		// The mapping is recreated (along with the database row) when it is next needed, see 'dbutil.GetOrCreate*'
+		report.addFinding(ConsistencyFinding{
+			Type:     ConsistencyFinding_KubernetesToDBResourceMappingTargetMissing,
+			Table:    "KubernetesToDBResourceMapping",
+			Key:      kubernetesToDBMapping.KubernetesResourceUID,
+			Resource: kubernetesToDBMapping.KubernetesResourceType + " " + kubernetesToDBMapping.KubernetesResourceUID,
+			Message: fmt.Sprintf("%s '%s' referenced by KubernetesToDBResourceMapping no longer exists", kubernetesToDBMapping.DBRelationType,
+				kubernetesToDBMapping.DBRelationKey),
+			repair: func(ctx context.Context) error {
+				_, err := checker.dbQueries.DeleteKubernetesResourceToDBResourceMapping(ctx, &kubernetesToDBMapping)
+				return err
+			},
+		})
+	}
+
+	return nil
+}
+
+// checkArgoCDApplications checks, for each GitOps engine instance, for Applications that have no Argo CD Application,
+// and for Argo CD Applications (created by the cluster-agent) that have no Application.
+func (checker *ConsistencyChecker) checkArgoCDApplications(ctx context.Context, report *ConsistencyReport) error {
+
+	var gitopsEngineInstances []db.GitopsEngineInstance
+	if err := checker.dbQueries.ListGitopsEngineInstances(ctx, &gitopsEngineInstances); err != nil {
+		return err
+	}
+
+	for idx := range gitopsEngineInstances {
+		gitopsEngineInstance := gitopsEngineInstances[idx]
+
+		// An error with one instance (for example, its cluster is unreachable) should not prevent the others from being checked
+		if err := checker.checkArgoCDApplicationsOfInstance(ctx, gitopsEngineInstance, report); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("unable to check Argo CD Applications of gitops engine instance '%s': %v",
+				gitopsEngineInstance.Gitopsengineinstance_id, err))
+		}
+	}
+
+	return nil
+}
+
+func (checker *ConsistencyChecker) checkArgoCDApplicationsOfInstance(ctx context.Context, gitopsEngineInstance db.GitopsEngineInstance,
+	report *ConsistencyReport) error {
+
+	gitopsEngineClient, err := checker.getK8sClientForGitOpsEngineInstance(ctx, &gitopsEngineInstance)
+	if err != nil {
+		return err
+	}
+
+	// The backend does not depend on the Argo CD API types, so the Applications are retrieved as unstructured
+	argoCDApplications := &unstructured.UnstructuredList{}
+	argoCDApplications.SetGroupVersionKind(argoCDApplicationListGVK)
+	if err := gitopsEngineClient.List(ctx, argoCDApplications, &client.ListOptions{Namespace: gitopsEngineInstance.Namespace_name}); err != nil {
+		return fmt.Errorf("unable to list Argo CD Applications in namespace '%s': %v", gitopsEngineInstance.Namespace_name, err)
+	}
+
+	var applications []db.Application
+	if err := checker.dbQueries.ListApplicationsForGitopsEngineInstance(ctx, gitopsEngineInstance.Gitopsengineinstance_id, &applications); err != nil {
+		return err
+	}
+
+	argoCDApplicationNames := map[string]bool{}
+	for _, argoCDApplication := range argoCDApplications.Items {
+		argoCDApplicationNames[argoCDApplication.GetName()] = true
+	}
+
+	applicationIDs := map[string]bool{}
+	for idx := range applications {
+		application := applications[idx]
+		applicationIDs[application.Application_id] = true
+
+		// The cluster-agent may not yet have created the Argo CD Application
+		if argoCDApplicationNames[application.Name] || isApplicationWithinGracePeriod(application) {
+			continue
+		}
+
+		report.addFinding(ConsistencyFinding{
+			Type:     ConsistencyFinding_ArgoCDApplicationMissing,
+			Table:    "Application",
+			Key:      application.Application_id,
+			Resource: "Application " + gitopsEngineInstance.Namespace_name + "/" + application.Name,
+			Message:  "Argo CD Application of Application does not exist",
+			repair: func(ctx context.Context) error {
+				// The cluster-agent will create the Argo CD Application from the Application row
+				return checker.createApplicationOperation(ctx, gitopsEngineInstance, application.Application_id, consistencyCheckClusterUserName)
+			},
+		})
+	}
+
+	for idx := range argoCDApplications.Items {
+		argoCDApplication := argoCDApplications.Items[idx]
+
+		// Argo CD Applications without the label were not created by the cluster-agent, and so are not checked.
+		databaseID, exists := argoCDApplication.GetLabels()[argoCDApplicationDatabaseIDLabel]
+		if !exists || databaseID == "" || applicationIDs[databaseID] {
+			continue
+		}
+
+		if time.Since(argoCDApplication.GetCreationTimestamp().Time) < consistencyCheckGracePeriod || argoCDApplication.GetDeletionTimestamp() != nil {
+			continue
+		}
+
+		// The Application may exist, but on a different instance
+		application := db.Application{Application_id: databaseID}
+		if err := checker.dbQueries.GetApplicationById(ctx, &application); err == nil {
+			continue
+		} else if !db.IsResultNotFoundError(err) {
+			return err
+		}
+
+		report.addFinding(ConsistencyFinding{
+			Type:     ConsistencyFinding_ApplicationMissing,
+			Table:    "Application",
+			Key:      databaseID,
+			Resource: "Application " + argoCDApplication.GetNamespace() + "/" + argoCDApplication.GetName(),
+			Message:  "Application referenced by Argo CD Application no longer exists",
+			repair: func(ctx context.Context) error {
+				// The cluster-agent will delete the Argo CD Application, as the Application row no longer exists
+				return checker.createApplicationOperation(ctx, gitopsEngineInstance, databaseID, consistencyCheckClusterUserName)
+			},
+		})
+	}
+
+	return nil
+}
+
+// deleteApplication deletes the Application row (and the rows that reference it), then creates an Operation so that
+// the cluster-agent deletes the corresponding Argo CD Application. See 'cleanOldGitOpsDeploymentEntry'.
+//
+// The Application row is locked, and 'beforeDelete' is called, in the same transaction as the delete: 'beforeDelete' may
+// confirm that the Application should still be deleted (returning an error otherwise), or delete the rows that reference it.
+// A not found error is returned if the Application no longer exists.
+func (checker *ConsistencyChecker) deleteApplication(ctx context.Context, applicationID string, clusterUserName string,
+	beforeDelete func(tx db.ApplicationScopedQueries) error) error {
+
+	application := db.Application{Application_id: applicationID}
+
+	if err := checker.dbQueries.RunInTransaction(ctx, func(tx db.ApplicationScopedQueries) error {
+
+		if err := tx.LockApplicationById(ctx, &application); err != nil {
+			return err
+		}
+
+		if err := beforeDelete(tx); err != nil {
+			return err
+		}
+
+		if _, err := tx.DeleteApplicationStateById(ctx, application.Application_id); err != nil {
+			return err
+		}
+
+		if _, err := tx.UpdateSyncOperationRemoveApplicationField(ctx, application.Application_id); err != nil {
+			return err
+		}
+
+		_, err := tx.DeleteApplicationById(ctx, application.Application_id)
+		return err
+
+	}); err != nil {
+		return err
+	}
+
+	gitopsEngineInstance := db.GitopsEngineInstance{Gitopsengineinstance_id: application.Engine_instance_inst_id}
+	if err := checker.dbQueries.GetGitopsEngineInstanceById(ctx, &gitopsEngineInstance); err != nil {
+		return err
+	}
+
+	return checker.createApplicationOperation(ctx, gitopsEngineInstance, application.Application_id, clusterUserName)
+}
+
+// createApplicationOperation creates an Operation for the Application, without waiting for it to complete: the
+// cluster-agent will then ensure that the Argo CD Application matches the Application row.
+func (checker *ConsistencyChecker) createApplicationOperation(ctx context.Context, gitopsEngineInstance db.GitopsEngineInstance,
+	applicationID string, clusterUserName string) error {
+
+	log := log.FromContext(ctx)
+
+	clusterUser, err := internalGetOrCreateClusterUserByNamespaceUID(ctx, clusterUserName, checker.dbQueries)
+	if err != nil {
+		return err
+	}
+
+	gitopsEngineClient, err := checker.getK8sClientForGitOpsEngineInstance(ctx, &gitopsEngineInstance)
+	if err != nil {
+		return err
+	}
+
+	dbOperationInput := db.Operation{
+		Instance_id:   gitopsEngineInstance.Gitopsengineinstance_id,
+		Resource_id:   applicationID,
+		Resource_type: db.OperationResourceType_Application,
+	}
+
+	// The Operation row and CR are purged by the OperationCollector once the operation has completed.
+	if _, _, err := CreateOperation(ctx, false, dbOperationInput, clusterUser.Clusteruser_id, gitopsEngineInstance.Namespace_name,
+		checker.dbQueries, gitopsEngineClient, log); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// isApplicationWithinGracePeriod returns true if the Application row was created within consistencyCheckGracePeriod.
+// Rows created before the creation time was recorded have a zero creation time, and are always checked.
+func isApplicationWithinGracePeriod(application db.Application) bool {
+	return !application.Created_on.IsZero() && time.Since(application.Created_on) < consistencyCheckGracePeriod
+}
+
+// ConsistencyCheckRunner periodically runs the ConsistencyChecker, and logs the report.
+//
+// ConsistencyCheckRunner implements the controller-runtime manager.Runnable interface.
+type ConsistencyCheckRunner struct {
+	interval time.Duration
+	repair   bool
+
+	checker *ConsistencyChecker
+}
+
+// NewConsistencyCheckRunner creates a new ConsistencyCheckRunner; it should be added to the manager, which will start it.
+func NewConsistencyCheckRunner(interval time.Duration, repair bool, dbQueries db.DatabaseQueries, workspaceClient client.Client) *ConsistencyCheckRunner {
+	return &ConsistencyCheckRunner{
+		interval: interval,
+		repair:   repair,
+		checker:  NewConsistencyChecker(dbQueries, workspaceClient),
+	}
+}
+
+// Start runs the consistency check every interval, until the context is cancelled.
+func (runner *ConsistencyCheckRunner) Start(ctx context.Context) error {
+
+	log := log.FromContext(ctx).WithName("consistency-checker")
+
+	ticker := time.NewTicker(runner.interval)
+	defer ticker.Stop()
+
+	for {
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		if err := runner.check(ctx, log); err != nil {
+			log.Error(err, "unable to check database consistency")
+		}
+	}
+}
+
+func (runner *ConsistencyCheckRunner) check(ctx context.Context, log logr.Logger) error {
+
+	report, err := runner.checker.Check(ctx, runner.repair, log)
+	if err != nil {
+		return err
+	}
+
+	if len(report.Findings) == 0 && len(report.Errors) == 0 {
+		log.V(sharedutil.LogLevel_Debug).Info("Database consistency check found no inconsistencies")
+		return nil
+	}
+
+	reportJSON, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+
+	log.Info("Database consistency check found inconsistencies", "findings", len(report.Findings),
+		"unrepaired", report.UnrepairedFindings(), "report", string(reportJSON))
+
+	return nil
+}
@@ -0,0 +1,378 @@
+package eventloop
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	db "github.com/redhat-appstudio/managed-gitops/backend-shared/config/db"
+	managedgitopsv1alpha1 "github.com/redhat-appstudio/managed-gitops/backend/apis/managed-gitops/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestConsistencyChecker(t *testing.T) {
+
+	ctx := context.Background()
+
+	scheme, argocdNamespace, _, workspace := genericTestSetup(t)
+
+	created := metav1.NewTime(time.Now().Add(-2 * consistencyCheckGracePeriod))
+
+	// A GitOpsDeployment that is consistent with the database, and one that has no DeploymentToApplicationMapping
+	gitopsDeployment := &managedgitopsv1alpha1.GitOpsDeployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-deployment", Namespace: workspace.Name, UID: "deployment-uid", CreationTimestamp: created},
+	}
+	unmappedGitopsDeployment := &managedgitopsv1alpha1.GitOpsDeployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "unmapped-deployment", Namespace: workspace.Name, UID: "unmapped-deployment-uid", CreationTimestamp: created},
+	}
+
+	newArgoCDApplication := func(name string, databaseID string) *unstructured.Unstructured {
+		res := &unstructured.Unstructured{}
+		res.SetAPIVersion("argoproj.io/v1alpha1")
+		res.SetKind("Application")
+		res.SetName(name)
+		res.SetNamespace(argocdNamespace.Name)
+		res.SetCreationTimestamp(created)
+		res.SetLabels(map[string]string{argoCDApplicationDatabaseIDLabel: databaseID})
+		return res
+	}
+
+	workspaceClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(gitopsDeployment, unmappedGitopsDeployment).Build()
+
+	gitopsEngineClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		newArgoCDApplication("gitopsdepl-deployment-uid", "application"),
+		newArgoCDApplication("gitopsdepl-deleted", "deleted-application")).Build()
+
+	dbQueries := &consistencyTestDatabaseQueries{
+		applications: map[string]db.Application{
+			"application":          {Application_id: "application", Name: "gitopsdepl-deployment-uid", Engine_instance_inst_id: "test-instance"},
+			"orphaned-application": {Application_id: "orphaned-application", Name: "gitopsdepl-orphaned", Engine_instance_inst_id: "test-instance"},
+		},
+		applicationsWithoutDeplToAppMapping: []db.Application{
+			{Application_id: "orphaned-application", Name: "gitopsdepl-orphaned", Engine_instance_inst_id: "test-instance"},
+		},
+		deplToAppMappings: []db.DeploymentToApplicationMapping{
+			{Deploymenttoapplicationmapping_uid_id: "deployment-uid", DeploymentName: "my-deployment", DeploymentNamespace: workspace.Name,
+				Application_id: "application"},
+			{Deploymenttoapplicationmapping_uid_id: "deleted-deployment-uid", DeploymentName: "deleted-deployment", DeploymentNamespace: workspace.Name,
+				Application_id: "application-of-deleted-deployment"},
+		},
+		apiCRToDBMappings: []db.APICRToDatabaseMapping{
+			{APIResourceType: db.APICRToDatabaseMapping_ResourceType_GitOpsDeploymentSyncRun, APIResourceUID: "syncrun-uid",
+				APIResourceName: "deleted-syncrun", APIResourceNamespace: workspace.Name,
+				DBRelationType: db.APICRToDatabaseMapping_DBRelationType_SyncOperation, DBRelationKey: "sync-operation"},
+		},
+		apiCRToDBMappingsWithMissingDBRelation: []db.APICRToDatabaseMapping{
+			{APIResourceType: db.APICRToDatabaseMapping_ResourceType_GitOpsDeploymentSyncRun, APIResourceUID: "other-syncrun-uid",
+				APIResourceName: "other-syncrun", APIResourceNamespace: workspace.Name,
+				DBRelationType: db.APICRToDatabaseMapping_DBRelationType_SyncOperation, DBRelationKey: "deleted-sync-operation"},
+		},
+		kubernetesToDBMappingsWithMissingDBRelation: []db.KubernetesToDBResourceMapping{
+			{KubernetesResourceType: db.K8sToDBMapping_Namespace, KubernetesResourceUID: "namespace-uid",
+				DBRelationType: db.K8sToDBMapping_ManagedEnvironment, DBRelationKey: "deleted-managed-env"},
+		},
+		gitopsEngineInstances: []db.GitopsEngineInstance{
+			{Gitopsengineinstance_id: "test-instance", Namespace_name: argocdNamespace.Name},
+		},
+	}
+
+	checker := &ConsistencyChecker{
+		dbQueries:       dbQueries,
+		workspaceClient: workspaceClient,
+		getK8sClientForGitOpsEngineInstance: func(ctx context.Context, gitopsEngineInstance *db.GitopsEngineInstance) (client.Client, error) {
+			return gitopsEngineClient, nil
+		},
+	}
+
+	findingsByType := func(report ConsistencyReport) map[ConsistencyFindingType]ConsistencyFinding {
+		res := map[ConsistencyFindingType]ConsistencyFinding{}
+		for _, finding := range report.Findings {
+			assert.NotContains(t, res, finding.Type, "each inconsistency should be reported once")
+			res[finding.Type] = finding
+		}
+		return res
+	}
+
+	t.Run("dry run reports inconsistencies, without repairing them", func(t *testing.T) {
+
+		report, err := checker.Check(ctx, false, log.FromContext(ctx))
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.True(t, report.DryRun)
+		assert.Empty(t, report.Errors)
+
+		findings := findingsByType(report)
+		assert.Len(t, findings, 8)
+
+		expectedKeys := map[ConsistencyFindingType]string{
+			ConsistencyFinding_ApplicationWithoutDeploymentToApplicationMapping: "orphaned-application",
+			ConsistencyFinding_GitOpsDeploymentMissing:                          "deleted-deployment-uid",
+			ConsistencyFinding_SyncOperationMissing:                             "other-syncrun-uid",
+			ConsistencyFinding_GitOpsDeploymentSyncRunMissing:                   "syncrun-uid",
+			ConsistencyFinding_KubernetesToDBResourceMappingTargetMissing:       "namespace-uid",
+			ConsistencyFinding_ArgoCDApplicationMissing:                         "orphaned-application",
+			ConsistencyFinding_ApplicationMissing:                               "deleted-application",
+		}
+		for findingType, key := range expectedKeys {
+			if assert.Contains(t, findings, findingType) {
+				assert.Equal(t, key, findings[findingType].Key)
+				assert.True(t, findings[findingType].Repairable)
+				assert.False(t, findings[findingType].Repaired)
+			}
+		}
+
+		if assert.Contains(t, findings, ConsistencyFinding_DeploymentToApplicationMappingMissing) {
+			finding := findings[ConsistencyFinding_DeploymentToApplicationMappingMissing]
+			assert.Equal(t, "GitOpsDeployment "+workspace.Name+"/"+unmappedGitopsDeployment.Name, finding.Resource)
+			assert.False(t, finding.Repairable)
+		}
+
+		assert.Empty(t, dbQueries.deletedAPICRToDBMappings)
+		assert.Empty(t, dbQueries.deletedKubernetesToDBMappings)
+		assert.Equal(t, 8, report.UnrepairedFindings())
+	})
+
+	t.Run("repair deletes mappings with missing rows", func(t *testing.T) {
+
+		// Only the mapping inconsistencies remain
+		dbQueries.applicationsWithoutDeplToAppMapping = nil
+		dbQueries.deplToAppMappings = dbQueries.deplToAppMappings[0:1]
+		dbQueries.applications = map[string]db.Application{"application": dbQueries.applications["application"]}
+		if !assert.NoError(t, gitopsEngineClient.DeleteAllOf(ctx, newArgoCDApplication("", ""), client.InNamespace(argocdNamespace.Name),
+			client.MatchingLabels{argoCDApplicationDatabaseIDLabel: "deleted-application"})) {
+			return
+		}
+
+		report, err := checker.Check(ctx, true, log.FromContext(ctx))
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.False(t, report.DryRun)
+
+		findings := findingsByType(report)
+		assert.Len(t, findings, 4)
+		for findingType, finding := range findings {
+			assert.Equal(t, finding.Repairable, finding.Repaired, "finding should be repaired if repairable: "+string(findingType))
+			assert.Empty(t, finding.RepairError)
+		}
+		assert.Equal(t, 1, report.UnrepairedFindings())
+
+		assert.ElementsMatch(t, []string{"syncrun-uid", "other-syncrun-uid"}, dbQueries.deletedAPICRToDBMappings)
+		assert.ElementsMatch(t, []string{"namespace-uid"}, dbQueries.deletedKubernetesToDBMappings)
+	})
+}
+
+func TestConsistencyCheckerApplications(t *testing.T) {
+
+	ctx := context.Background()
+
+	scheme, argocdNamespace, kubesystemNamespace, workspace := genericTestSetup(t)
+
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(workspace, argocdNamespace, kubesystemNamespace).Build()
+
+	dbQueries := db.NewInMemoryDBQueries()
+
+	_, managedEnv, engineInstance, _, err := internalProcessMessage_GetOrCreateSharedResources(ctx, k8sClient, *workspace, dbQueries, log.FromContext(ctx))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// An Application without a DeploymentToApplicationMapping, and one with a mapping, whose Argo CD Application exists
+	orphanedApplication := db.Application{
+		Name:                    "gitopsdepl-orphaned",
+		Spec_field:              "{}",
+		Engine_instance_inst_id: engineInstance.Gitopsengineinstance_id,
+		Managed_environment_id:  managedEnv.Managedenvironment_id,
+	}
+	assert.NoError(t, dbQueries.CreateApplication(ctx, &orphanedApplication))
+
+	application := db.Application{
+		Name:                    "gitopsdepl-deployment-uid",
+		Spec_field:              "{}",
+		Engine_instance_inst_id: engineInstance.Gitopsengineinstance_id,
+		Managed_environment_id:  managedEnv.Managedenvironment_id,
+	}
+	assert.NoError(t, dbQueries.CreateApplication(ctx, &application))
+
+	gitopsDeployment := &managedgitopsv1alpha1.GitOpsDeployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-deployment", Namespace: workspace.Name, UID: "deployment-uid",
+			CreationTimestamp: metav1.NewTime(time.Now().Add(-time.Hour))},
+	}
+	assert.NoError(t, dbQueries.CreateDeploymentToApplicationMapping(ctx, &db.DeploymentToApplicationMapping{
+		Deploymenttoapplicationmapping_uid_id: string(gitopsDeployment.UID),
+		DeploymentName:                        gitopsDeployment.Name,
+		DeploymentNamespace:                   gitopsDeployment.Namespace,
+		WorkspaceUID:                          string(workspace.UID),
+		Application_id:                        application.Application_id,
+	}))
+
+	argoCDApplication := &unstructured.Unstructured{}
+	argoCDApplication.SetAPIVersion("argoproj.io/v1alpha1")
+	argoCDApplication.SetKind("Application")
+	argoCDApplication.SetName(application.Name)
+	argoCDApplication.SetNamespace(argocdNamespace.Name)
+	argoCDApplication.SetLabels(map[string]string{argoCDApplicationDatabaseIDLabel: application.Application_id})
+
+	gitopsEngineClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(argoCDApplication).Build()
+
+	checker := &ConsistencyChecker{
+		dbQueries:       dbQueries,
+		workspaceClient: fake.NewClientBuilder().WithScheme(scheme).WithObjects(gitopsDeployment).Build(),
+		getK8sClientForGitOpsEngineInstance: func(ctx context.Context, gitopsEngineInstance *db.GitopsEngineInstance) (client.Client, error) {
+			return gitopsEngineClient, nil
+		},
+	}
+
+	findApplicationFinding := func(report ConsistencyReport, findingType ConsistencyFindingType) *ConsistencyFinding {
+		for idx := range report.Findings {
+			if report.Findings[idx].Type == findingType && report.Findings[idx].Key == orphanedApplication.Application_id {
+				return &report.Findings[idx]
+			}
+		}
+		return nil
+	}
+
+	t.Run("Applications created within the grace period are not reported", func(t *testing.T) {
+
+		report, err := checker.Check(ctx, false, log.FromContext(ctx))
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Empty(t, report.Findings)
+	})
+
+	t.Run("Orphaned Applications are only deleted if they are still orphaned, once locked", func(t *testing.T) {
+
+		defer func(gracePeriod time.Duration) { consistencyCheckGracePeriod = gracePeriod }(consistencyCheckGracePeriod)
+		consistencyCheckGracePeriod = 0
+
+		report, err := checker.Check(ctx, false, log.FromContext(ctx))
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.NotNil(t, findApplicationFinding(report, ConsistencyFinding_ArgoCDApplicationMissing))
+
+		finding := findApplicationFinding(report, ConsistencyFinding_ApplicationWithoutDeploymentToApplicationMapping)
+		if !assert.NotNil(t, finding) {
+			return
+		}
+
+		// The mapping is created after the check, but before the repair
+		orphanedMapping := db.DeploymentToApplicationMapping{
+			Deploymenttoapplicationmapping_uid_id: "late-deployment-uid",
+			DeploymentName:                        "late-deployment",
+			DeploymentNamespace:                   workspace.Name,
+			WorkspaceUID:                          string(workspace.UID),
+			Application_id:                        orphanedApplication.Application_id,
+		}
+		assert.NoError(t, dbQueries.CreateDeploymentToApplicationMapping(ctx, &orphanedMapping))
+
+		err = finding.repair(ctx)
+		assert.Error(t, err)
+		assert.NoError(t, dbQueries.GetApplicationById(ctx, &db.Application{Application_id: orphanedApplication.Application_id}),
+			"the Application should not be deleted, as it is referenced")
+
+		_, err = dbQueries.DeleteDeploymentToApplicationMappingByDeplId(ctx, orphanedMapping.Deploymenttoapplicationmapping_uid_id)
+		assert.NoError(t, err)
+
+		err = finding.repair(ctx)
+		assert.NoError(t, err)
+		err = dbQueries.GetApplicationById(ctx, &db.Application{Application_id: orphanedApplication.Application_id})
+		assert.True(t, db.IsResultNotFoundError(err))
+
+		// Repairing an Application that has already been deleted does nothing
+		assert.NoError(t, finding.repair(ctx))
+	})
+}
+
+// consistencyTestDatabaseQueries implements the subset of DatabaseQueries that is used by ConsistencyChecker: calls
+// to any other function will panic.
+type consistencyTestDatabaseQueries struct {
+	db.DatabaseQueries
+
+	applications                                map[string]db.Application
+	applicationsWithoutDeplToAppMapping         []db.Application
+	deplToAppMappings                           []db.DeploymentToApplicationMapping
+	apiCRToDBMappings                           []db.APICRToDatabaseMapping
+	apiCRToDBMappingsWithMissingDBRelation      []db.APICRToDatabaseMapping
+	kubernetesToDBMappingsWithMissingDBRelation []db.KubernetesToDBResourceMapping
+	gitopsEngineInstances                       []db.GitopsEngineInstance
+
+	deletedAPICRToDBMappings      []string
+	deletedKubernetesToDBMappings []string
+}
+
+func (dbq *consistencyTestDatabaseQueries) ListApplicationsWithoutDeploymentToApplicationMapping(ctx context.Context, applications *[]db.Application) error {
+	*applications = dbq.applicationsWithoutDeplToAppMapping
+	return nil
+}
+
+func (dbq *consistencyTestDatabaseQueries) ListApplicationsForGitopsEngineInstance(ctx context.Context, gitopsEngineInstanceId string, applications *[]db.Application) error {
+	*applications = []db.Application{}
+	for _, application := range dbq.applications {
+		if application.Engine_instance_inst_id == gitopsEngineInstanceId {
+			*applications = append(*applications, application)
+		}
+	}
+	return nil
+}
+
+func (dbq *consistencyTestDatabaseQueries) GetApplicationById(ctx context.Context, application *db.Application) error {
+	res, exists := dbq.applications[application.Application_id]
+	if !exists {
+		return db.NewResultNotFoundError("application")
+	}
+	*application = res
+	return nil
+}
+
+func (dbq *consistencyTestDatabaseQueries) ListDeploymentToApplicationMappings(ctx context.Context, deplToAppMappings *[]db.DeploymentToApplicationMapping) error {
+	*deplToAppMappings = dbq.deplToAppMappings
+	return nil
+}
+
+func (dbq *consistencyTestDatabaseQueries) GetDeploymentToApplicationMappingByDeplId(ctx context.Context, deplToAppMapping *db.DeploymentToApplicationMapping) error {
+	for _, res := range dbq.deplToAppMappings {
+		if res.Deploymenttoapplicationmapping_uid_id == deplToAppMapping.Deploymenttoapplicationmapping_uid_id {
+			*deplToAppMapping = res
+			return nil
+		}
+	}
+	return db.NewResultNotFoundError("deploymenttoapplicationmapping")
+}
+
+func (dbq *consistencyTestDatabaseQueries) ListAPICRToDatabaseMappings(ctx context.Context, apiCRToDBMappings *[]db.APICRToDatabaseMapping) error {
+	*apiCRToDBMappings = append(append([]db.APICRToDatabaseMapping{}, dbq.apiCRToDBMappings...), dbq.apiCRToDBMappingsWithMissingDBRelation...)
+	return nil
+}
+
+func (dbq *consistencyTestDatabaseQueries) ListAPICRToDatabaseMappingsWithMissingDBRelation(ctx context.Context, apiCRToDBMappings *[]db.APICRToDatabaseMapping) error {
+	*apiCRToDBMappings = dbq.apiCRToDBMappingsWithMissingDBRelation
+	return nil
+}
+
+func (dbq *consistencyTestDatabaseQueries) DeleteAPICRToDatabaseMapping(ctx context.Context, obj *db.APICRToDatabaseMapping) (int, error) {
+	dbq.deletedAPICRToDBMappings = append(dbq.deletedAPICRToDBMappings, obj.APIResourceUID)
+	return 1, nil
+}
+
+func (dbq *consistencyTestDatabaseQueries) ListKubernetesToDBResourceMappingsWithMissingDBRelation(ctx context.Context, kubernetesToDBMappings *[]db.KubernetesToDBResourceMapping) error {
+	*kubernetesToDBMappings = dbq.kubernetesToDBMappingsWithMissingDBRelation
+	return nil
+}
+
+func (dbq *consistencyTestDatabaseQueries) DeleteKubernetesResourceToDBResourceMapping(ctx context.Context, obj *db.KubernetesToDBResourceMapping) (int, error) {
+	dbq.deletedKubernetesToDBMappings = append(dbq.deletedKubernetesToDBMappings, obj.KubernetesResourceUID)
+	return 1, nil
+}
+
+func (dbq *consistencyTestDatabaseQueries) ListGitopsEngineInstances(ctx context.Context, gitopsEngineInstances *[]db.GitopsEngineInstance) error {
+	*gitopsEngineInstances = dbq.gitopsEngineInstances
+	return nil
+}
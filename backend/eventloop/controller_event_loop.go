@@ -2,7 +2,9 @@ package eventloop
 
 import (
 	"context"
+	"time"
 
+	db "github.com/redhat-appstudio/managed-gitops/backend-shared/config/db"
 	sharedutil "github.com/redhat-appstudio/managed-gitops/backend-shared/util"
 	managedgitopsv1alpha1 "github.com/redhat-appstudio/managed-gitops/backend/apis/managed-gitops/v1alpha1"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -14,10 +16,19 @@ type controllerEventLoop struct {
 	eventLoopInputChannel chan eventLoopEvent
 }
 
-func newControllerEventLoop() *controllerEventLoop {
+// newControllerEventLoop starts the controller event loop: 'dbQueries' are shared by the application event runners of
+// all workspaces. The events of the workspaces that are not owned by this replica (see 'ownsWorkspace') are dropped, and
+// their application event loops are stopped.
+func newControllerEventLoop(dbQueries db.DatabaseQueries, ownsWorkspace workspaceOwnershipCheck) *controllerEventLoop {
+
+	startApplicationEventLoop := func(gitopsDeplID string, workspaceID string, sharedResourceEventLoop *sharedResourceEventLoop) applicationEventLoop {
+		return startApplicationEventQueueLoop(gitopsDeplID, workspaceID, sharedResourceEventLoop, dbQueries, ownsWorkspace)
+	}
 
 	channel := make(chan eventLoopEvent)
-	go controllerEventLoopRouter(channel)
+	go controllerEventLoopRouter(channel, func(workspaceID string) workspaceEventLoop {
+		return startWorkspaceEventLoopRouter(workspaceID, startApplicationEventLoop)
+	}, ownsWorkspace)
 
 	res := &controllerEventLoop{}
 	res.eventLoopInputChannel = channel
@@ -33,48 +44,79 @@ func (evl *controllerEventLoop) EventReceived(req ctrl.Request, reqResource mana
 	evl.eventLoopInputChannel <- event
 }
 
-// workspaceEntry maintains individual state for each workspace
-type workspaceEntry struct {
-	workspaceID               string
-	workspaceEventLoopChannel chan applicationEventLoopMessage
-	// This struct should only be used from within the controller event loop
-}
+// workspaceEventLoopStarter starts the goroutine for a workspace: see 'startWorkspaceEventLoopRouter'.
+type workspaceEventLoopStarter func(workspaceID string) workspaceEventLoop
+
+// workspaceOwnershipCheck returns true if the events of the workspace should be processed by this replica: see
+// 'PreprocessEventLoop.OwnsWorkspace'.
+type workspaceOwnershipCheck func(workspaceID string) bool
 
 // controllerEventLoopRouter routes messages to the channel/go routine responsible for handling a particular workspace's events
 // This channel is non-blocking.
-func controllerEventLoopRouter(input chan eventLoopEvent) {
+//
+// In sharding mode, the events of a workspace may still be received after this replica has lost it (for example, the
+// events of a reconcile that was in progress when the workspace was lost): these are dropped, as they are processed by
+// the new owner.
+func controllerEventLoopRouter(input chan eventLoopEvent, startWorkspaceEventLoop workspaceEventLoopStarter, ownsWorkspace workspaceOwnershipCheck) {
 
 	eventLoopRouterLog := log.FromContext(context.Background())
 
 	eventLoopRouterLog.Info("controllerEventLoopRouter started.")
 	defer eventLoopRouterLog.Error(nil, "SEVERE: controllerEventLoopRouter ended.")
 
-	workspaceEntries := map[string] /* workspace id -> */ workspaceEntry{}
+	// workspaceEntries should only be used from within the controller event loop
+	workspaceEntries := map[string] /* workspace id -> */ workspaceEventLoop{}
+
+	// Periodically remove the entries of workspace event loops that were stopped (as idle)
+	sweepTicker := time.NewTicker(workspaceEventLoopIdleTimeout)
+	defer sweepTicker.Stop()
 
 	for {
 
-		event := <-input
+		var event eventLoopEvent
+
+		select {
+		case event = <-input:
+		case <-sweepTicker.C:
+			for workspaceID, workspaceEntryVal := range workspaceEntries {
+				if workspaceEntryVal.isTerminated() {
+					delete(workspaceEntries, workspaceID)
+				}
+			}
+			continue
+		}
+
+		reportEventReceived(eventLoopStage_Controller, &event)
 
 		eventLoopRouterLog.V(sharedutil.LogLevel_Debug).Info("eventLoop received event", "event", stringEventLoopEvent(&event), "workspace", event.workspaceID)
 
-		workspaceEntryVal, ok := workspaceEntries[event.workspaceID]
-		if !ok {
-			// Start the workspace's event loop go-routine, if it's not already started.
-			workspaceEntryVal = workspaceEntry{
-				workspaceID:               event.workspaceID,
-				workspaceEventLoopChannel: make(chan applicationEventLoopMessage),
+		if !ownsWorkspace(event.workspaceID) {
+			eventLoopRouterLog.V(sharedutil.LogLevel_Debug).Info("Dropped event of a workspace that is not owned by this replica", "event", stringEventLoopEvent(&event), "workspace", event.workspaceID)
+			continue
+		}
+
+		for {
+			workspaceEntryVal, ok := workspaceEntries[event.workspaceID]
+			if !ok || workspaceEntryVal.isTerminated() {
+				// Start the workspace's event loop go-routine, if it's not already started (or if it was stopped as idle).
+				workspaceEntryVal = startWorkspaceEventLoop(event.workspaceID)
+				workspaceEntries[event.workspaceID] = workspaceEntryVal
 			}
-			workspaceEntries[event.workspaceID] = workspaceEntryVal
 
-			startWorkspaceEventLoopRouter(workspaceEntryVal.workspaceEventLoopChannel, event.workspaceID)
-		}
+			// Send the event to the channel/go routine that handles all events for this workspace (non-blocking)
+			select {
+			case workspaceEntryVal.input <- applicationEventLoopMessage{
+				messageType: applicationEventLoopMessageType_Event,
+				event:       &event,
+			}:
+			case <-workspaceEntryVal.terminated:
+				// The workspace event loop stopped before it received the event: send it to a new one
+				delete(workspaceEntries, event.workspaceID)
+				continue
+			}
 
-		// Send the event to the channel/go routine that handles all events for this workspace (non-blocking)
-		workspaceEntryVal.workspaceEventLoopChannel <- applicationEventLoopMessage{
-			messageType: applicationEventLoopMessageType_Event,
-			event:       &event,
+			break
 		}
-
 	}
 
 }
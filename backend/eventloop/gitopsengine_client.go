@@ -0,0 +1,223 @@
+package eventloop
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	operation "github.com/redhat-appstudio/managed-gitops/backend-shared/apis/managed-gitops/v1alpha1"
+	db "github.com/redhat-appstudio/managed-gitops/backend-shared/config/db"
+	dbutil "github.com/redhat-appstudio/managed-gitops/backend-shared/config/db/util"
+	sharedutil "github.com/redhat-appstudio/managed-gitops/backend-shared/util"
+	managedgitopsv1alpha1 "github.com/redhat-appstudio/managed-gitops/backend/apis/managed-gitops/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// gitopsEngineClients is shared by all application event loop runners: the K8s client of each GitOps engine cluster
+// is created once, and then reused by every runner that creates Operations on that cluster.
+//
+// It must be initialized with the database queries of the backend, see 'InitializeGitOpsEngineClients'.
+var gitopsEngineClients = newGitOpsEngineClientCache(nil)
+
+var (
+	// gitopsEngineClientVerifyInterval is how often the cluster credentials of a cached client are compared with the
+	// database: a client may thus use rotated credentials for up to this long.
+	gitopsEngineClientVerifyInterval = 1 * time.Minute
+
+	// gitopsEngineClientIdleTimeout is how long a client may go unused, before it is evicted from the cache.
+	gitopsEngineClientIdleTimeout = 30 * time.Minute
+)
+
+// InitializeGitOpsEngineClients sets the database queries that are used to retrieve the cluster credentials of the
+// GitOps engine clusters: it should be called once on startup, before the event loops are started.
+func InitializeGitOpsEngineClients(dbQueries db.DatabaseQueries) {
+	gitopsEngineClients.mutex.Lock()
+	defer gitopsEngineClients.mutex.Unlock()
+
+	gitopsEngineClients.dbQueries = dbQueries
+}
+
+// gitopsEngineClientCache caches a K8s client for each GitOps engine cluster, keyed by GitopsEngineCluster id.
+//
+// The backend creates Operation CRs in the namespace of the Argo CD instance (GitopsEngineInstance) that the Operation
+// targets. That instance may be on a different cluster from the backend: in that case, the client is created from the
+// ClusterCredentials of the GitopsEngineCluster, and the cluster-agent running on that cluster will process the Operation.
+type gitopsEngineClientCache struct {
+	mutex sync.Mutex
+
+	// clients is a map from GitopsEngineCluster id -> client for that cluster
+	clients map[string]gitopsEngineClientCacheEntry
+
+	dbQueries db.DatabaseQueries
+
+	// newClient creates a K8s client from a REST config; this may be replaced by unit tests.
+	newClient func(config *rest.Config) (client.Client, error)
+}
+
+type gitopsEngineClientCacheEntry struct {
+	// clusterCredentials are the credentials that were used to create the client: if the credentials of the
+	// cluster change in the database, the client is recreated.
+	clusterCredentials db.ClusterCredentials
+
+	client client.Client
+
+	// verified is when the credentials were last compared with the database, see 'gitopsEngineClientVerifyInterval'
+	verified time.Time
+
+	// lastUsed is when the client was last returned, see 'gitopsEngineClientIdleTimeout'
+	lastUsed time.Time
+}
+
+func newGitOpsEngineClientCache(dbQueries db.DatabaseQueries) *gitopsEngineClientCache {
+	return &gitopsEngineClientCache{
+		clients:   map[string]gitopsEngineClientCacheEntry{},
+		dbQueries: dbQueries,
+		newClient: newGitOpsEngineK8sClient,
+	}
+}
+
+// getClient returns a K8s client for the cluster that hosts the given GitOps engine instance.
+func (cache *gitopsEngineClientCache) getClient(ctx context.Context, gitopsEngineInstance *db.GitopsEngineInstance) (client.Client, error) {
+
+	if gitopsEngineInstance == nil {
+		return nil, fmt.Errorf("SEVERE: gitops engine instance is nil")
+	}
+
+	now := time.Now()
+
+	cache.mutex.Lock()
+	cache.evictIdleClients(now)
+	dbQueries := cache.dbQueries
+	if entry, exists := cache.clients[gitopsEngineInstance.EngineCluster_id]; exists && now.Sub(entry.verified) < gitopsEngineClientVerifyInterval {
+		entry.lastUsed = now
+		cache.clients[gitopsEngineInstance.EngineCluster_id] = entry
+		cache.mutex.Unlock()
+		return entry.client, nil
+	}
+	cache.mutex.Unlock()
+
+	if dbQueries == nil {
+		return nil, fmt.Errorf("SEVERE: gitops engine client cache was not initialized with database queries")
+	}
+
+	gitopsEngineCluster := &db.GitopsEngineCluster{Gitopsenginecluster_id: gitopsEngineInstance.EngineCluster_id}
+	if err := dbQueries.GetGitopsEngineClusterById(ctx, gitopsEngineCluster); err != nil {
+		return nil, fmt.Errorf("unable to retrieve gitops engine cluster '%s': %w", gitopsEngineInstance.EngineCluster_id, err)
+	}
+
+	clusterCredentials := &db.ClusterCredentials{Clustercredentials_cred_id: gitopsEngineCluster.Clustercredentials_id}
+	if err := dbQueries.GetClusterCredentialsById(ctx, clusterCredentials); err != nil {
+		return nil, fmt.Errorf("unable to retrieve cluster credentials '%s' of gitops engine cluster '%s': %v",
+			gitopsEngineCluster.Clustercredentials_id, gitopsEngineCluster.Gitopsenginecluster_id, err)
+	}
+
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	if entry, exists := cache.clients[gitopsEngineCluster.Gitopsenginecluster_id]; exists && entry.clusterCredentials == *clusterCredentials {
+		entry.verified = now
+		entry.lastUsed = now
+		cache.clients[gitopsEngineCluster.Gitopsenginecluster_id] = entry
+		return entry.client, nil
+	}
+
+	// The credentials are only decrypted when a client needs to be created: the cache entry retains the encrypted copy.
+	decryptedCredentials := *clusterCredentials
+	if err := dbQueries.DecryptClusterCredentials(&decryptedCredentials); err != nil {
+		return nil, fmt.Errorf("unable to decrypt cluster credentials of gitops engine cluster '%s': %v", gitopsEngineCluster.Gitopsenginecluster_id, err)
+	}
+
+	config, err := getRESTConfigForClusterCredentials(decryptedCredentials)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create REST config for gitops engine cluster '%s': %v", gitopsEngineCluster.Gitopsenginecluster_id, err)
+	}
+
+	k8sClient, err := cache.newClient(config)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create client for gitops engine cluster '%s': %v", gitopsEngineCluster.Gitopsenginecluster_id, err)
+	}
+
+	cache.clients[gitopsEngineCluster.Gitopsenginecluster_id] = gitopsEngineClientCacheEntry{
+		clusterCredentials: *clusterCredentials,
+		client:             k8sClient,
+		verified:           now,
+		lastUsed:           now,
+	}
+
+	return k8sClient, nil
+}
+
+// evictIdleClients removes the clients that have not been used within 'gitopsEngineClientIdleTimeout', for example
+// because their GitOps engine cluster was removed. The caller must hold the mutex.
+func (cache *gitopsEngineClientCache) evictIdleClients(now time.Time) {
+	for clusterID, entry := range cache.clients {
+		if now.Sub(entry.lastUsed) >= gitopsEngineClientIdleTimeout {
+			delete(cache.clients, clusterID)
+		}
+	}
+}
+
+// getRESTConfigForClusterCredentials returns the REST config for the cluster described by the given credentials:
+// - Placeholder credentials (see 'dbutil.IsPlaceholderClusterCredentials') refer to the cluster the backend is running on.
+// - Otherwise, a kube_config (and optional context) is used if present, falling back to the host and service account bearer token.
+func getRESTConfigForClusterCredentials(clusterCredentials db.ClusterCredentials) (*rest.Config, error) {
+
+	if dbutil.IsPlaceholderClusterCredentials(clusterCredentials) {
+		return sharedutil.GetRESTConfig()
+	}
+
+	var config *rest.Config
+
+	if clusterCredentials.Kube_config != "" {
+
+		kubeConfig, err := clientcmd.Load([]byte(clusterCredentials.Kube_config))
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse kube_config of cluster credentials '%s': %v", clusterCredentials.Clustercredentials_cred_id, err)
+		}
+
+		config, err = clientcmd.NewNonInteractiveClientConfig(*kubeConfig, clusterCredentials.Kube_config_context,
+			&clientcmd.ConfigOverrides{}, nil).ClientConfig()
+		if err != nil {
+			return nil, fmt.Errorf("unable to create client config from kube_config of cluster credentials '%s': %v",
+				clusterCredentials.Clustercredentials_cred_id, err)
+		}
+
+	} else if clusterCredentials.Host != "" && clusterCredentials.Serviceaccount_bearer_token != "" {
+
+		config = &rest.Config{
+			Host:        clusterCredentials.Host,
+			BearerToken: clusterCredentials.Serviceaccount_bearer_token,
+		}
+
+	} else {
+		return nil, fmt.Errorf("cluster credentials '%s' contain neither a kube_config nor a host and bearer token",
+			clusterCredentials.Clustercredentials_cred_id)
+	}
+
+	// Use the same rate limiting values as the local cluster, see 'sharedutil.GetRESTConfig'
+	config.QPS = 100
+	config.Burst = 250
+
+	return config, nil
+}
+
+func newGitOpsEngineK8sClient(config *rest.Config) (client.Client, error) {
+
+	scheme := runtime.NewScheme()
+	if err := managedgitopsv1alpha1.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+	if err := operation.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+	if err := corev1.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+
+	return client.New(config, client.Options{Scheme: scheme})
+}
@@ -0,0 +1,191 @@
+package eventloop
+
+import (
+	"context"
+	"testing"
+
+	db "github.com/redhat-appstudio/managed-gitops/backend-shared/config/db"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const testKubeConfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: remote-cluster
+  cluster:
+    server: https://api.remote-cluster:6443
+contexts:
+- name: remote-context
+  context:
+    cluster: remote-cluster
+    user: remote-user
+current-context: remote-context
+users:
+- name: remote-user
+  user:
+    token: remote-token
+`
+
+func TestGetRESTConfigForClusterCredentials(t *testing.T) {
+
+	t.Run("kube_config is used, when present", func(t *testing.T) {
+		config, err := getRESTConfigForClusterCredentials(db.ClusterCredentials{
+			Clustercredentials_cred_id: "creds",
+			Kube_config:                testKubeConfig,
+			Kube_config_context:        "remote-context",
+		})
+		if assert.NoError(t, err) {
+			assert.Equal(t, "https://api.remote-cluster:6443", config.Host)
+			assert.Equal(t, "remote-token", config.BearerToken)
+		}
+	})
+
+	t.Run("host and bearer token are used, when there is no kube_config", func(t *testing.T) {
+		config, err := getRESTConfigForClusterCredentials(db.ClusterCredentials{
+			Clustercredentials_cred_id:  "creds",
+			Host:                        "https://api.other-cluster:6443",
+			Serviceaccount_bearer_token: "other-token",
+		})
+		if assert.NoError(t, err) {
+			assert.Equal(t, "https://api.other-cluster:6443", config.Host)
+			assert.Equal(t, "other-token", config.BearerToken)
+		}
+	})
+
+	t.Run("invalid kube_config returns an error", func(t *testing.T) {
+		_, err := getRESTConfigForClusterCredentials(db.ClusterCredentials{
+			Clustercredentials_cred_id: "creds",
+			Kube_config:                "not: [valid",
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("credentials without kube_config or bearer token return an error", func(t *testing.T) {
+		_, err := getRESTConfigForClusterCredentials(db.ClusterCredentials{
+			Clustercredentials_cred_id: "creds",
+			Host:                       "https://api.other-cluster:6443",
+		})
+		assert.Error(t, err)
+	})
+}
+
+func TestGitOpsEngineClientCache(t *testing.T) {
+
+	ctx := context.Background()
+
+	dbQueries := &engineClientTestDatabaseQueries{
+		gitopsEngineClusters: map[string]db.GitopsEngineCluster{
+			"cluster-a": {Gitopsenginecluster_id: "cluster-a", Clustercredentials_id: "creds-a"},
+			"cluster-b": {Gitopsenginecluster_id: "cluster-b", Clustercredentials_id: "creds-b"},
+		},
+		clusterCredentials: map[string]db.ClusterCredentials{
+			"creds-a": {Clustercredentials_cred_id: "creds-a", Host: "https://api.cluster-a:6443", Serviceaccount_bearer_token: "token-a"},
+			"creds-b": {Clustercredentials_cred_id: "creds-b", Host: "https://api.cluster-b:6443", Serviceaccount_bearer_token: "token-b"},
+		},
+	}
+
+	hostsCreated := []string{}
+
+	cache := newGitOpsEngineClientCache(dbQueries)
+	cache.newClient = func(config *rest.Config) (client.Client, error) {
+		hostsCreated = append(hostsCreated, config.Host)
+		return fake.NewClientBuilder().Build(), nil
+	}
+
+	instanceA := &db.GitopsEngineInstance{Gitopsengineinstance_id: "instance-a", EngineCluster_id: "cluster-a"}
+	instanceA2 := &db.GitopsEngineInstance{Gitopsengineinstance_id: "instance-a2", EngineCluster_id: "cluster-a"}
+	instanceB := &db.GitopsEngineInstance{Gitopsengineinstance_id: "instance-b", EngineCluster_id: "cluster-b"}
+
+	clientA, err := cache.getClient(ctx, instanceA)
+	assert.NoError(t, err)
+
+	msg := "instances on the same cluster should share a client"
+	clientA2, err := cache.getClient(ctx, instanceA2)
+	assert.NoError(t, err, msg)
+	assert.Same(t, clientA, clientA2, msg)
+
+	msg = "instances on a different cluster should use a different client"
+	clientB, err := cache.getClient(ctx, instanceB)
+	assert.NoError(t, err, msg)
+	assert.NotSame(t, clientA, clientB, msg)
+	assert.Equal(t, []string{"https://api.cluster-a:6443", "https://api.cluster-b:6443"}, hostsCreated, msg)
+
+	msg = "the credentials of a recently verified client should not be read from the database"
+	queries := dbQueries.queries
+	_, err = cache.getClient(ctx, instanceA)
+	assert.NoError(t, err, msg)
+	assert.Equal(t, queries, dbQueries.queries, msg)
+
+	msg = "the client should be recreated if the cluster credentials change"
+	creds := dbQueries.clusterCredentials["creds-a"]
+	creds.Serviceaccount_bearer_token = "token-a-rotated"
+	dbQueries.clusterCredentials["creds-a"] = creds
+	clientA3, err := cache.getClient(ctx, instanceA)
+	assert.NoError(t, err, msg)
+	assert.Same(t, clientA, clientA3, msg)
+	expireVerification(cache, "cluster-a")
+	clientA3, err = cache.getClient(ctx, instanceA)
+	assert.NoError(t, err, msg)
+	assert.NotSame(t, clientA, clientA3, msg)
+	assert.Len(t, hostsCreated, 3, msg)
+
+	msg = "clients that have not been used within the idle timeout should be evicted"
+	entry := cache.clients["cluster-b"]
+	entry.lastUsed = entry.lastUsed.Add(-gitopsEngineClientIdleTimeout)
+	cache.clients["cluster-b"] = entry
+	_, err = cache.getClient(ctx, instanceA)
+	assert.NoError(t, err, msg)
+	assert.NotContains(t, cache.clients, "cluster-b", msg)
+	assert.Contains(t, cache.clients, "cluster-a", msg)
+
+	msg = "an instance on an unknown cluster should return an error"
+	_, err = cache.getClient(ctx, &db.GitopsEngineInstance{Gitopsengineinstance_id: "instance-c", EngineCluster_id: "cluster-c"})
+	assert.Error(t, err, msg)
+}
+
+// expireVerification causes the credentials of the cached client to be compared with the database on next use.
+func expireVerification(cache *gitopsEngineClientCache, clusterID string) {
+	entry := cache.clients[clusterID]
+	entry.verified = entry.verified.Add(-gitopsEngineClientVerifyInterval)
+	cache.clients[clusterID] = entry
+}
+
+// engineClientTestDatabaseQueries implements the subset of DatabaseQueries that is used by gitopsEngineClientCache:
+// calls to any other function will panic.
+type engineClientTestDatabaseQueries struct {
+	db.DatabaseQueries
+
+	gitopsEngineClusters map[string]db.GitopsEngineCluster
+	clusterCredentials   map[string]db.ClusterCredentials
+
+	// queries is the number of queries that were made
+	queries int
+}
+
+func (dbq *engineClientTestDatabaseQueries) GetGitopsEngineClusterById(ctx context.Context, gitopsEngineCluster *db.GitopsEngineCluster) error {
+	dbq.queries++
+	res, exists := dbq.gitopsEngineClusters[gitopsEngineCluster.Gitopsenginecluster_id]
+	if !exists {
+		return db.NewResultNotFoundError("gitops engine cluster")
+	}
+	*gitopsEngineCluster = res
+	return nil
+}
+
+func (dbq *engineClientTestDatabaseQueries) GetClusterCredentialsById(ctx context.Context, clusterCreds *db.ClusterCredentials) error {
+	dbq.queries++
+	res, exists := dbq.clusterCredentials[clusterCreds.Clustercredentials_cred_id]
+	if !exists {
+		return db.NewResultNotFoundError("cluster credentials")
+	}
+	*clusterCreds = res
+	return nil
+}
+
+// DecryptClusterCredentials leaves the credentials unchanged, as they are stored unencrypted by the test.
+func (dbq *engineClientTestDatabaseQueries) DecryptClusterCredentials(clusterCreds *db.ClusterCredentials) error {
+	return nil
+}
@@ -0,0 +1,85 @@
+package eventloop
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// Stages of the event loop pipeline, in the order in which events are processed
+const (
+	eventLoopStage_Preprocess  = "preprocess"
+	eventLoopStage_Controller  = "controller"
+	eventLoopStage_Workspace   = "workspace"
+	eventLoopStage_Application = "application"
+)
+
+// Types of the goroutines started by the event loops, which run until they are idle (workspace) or until the
+// GitOpsDeployment no longer exists (application)
+const (
+	eventLoopGoroutine_Workspace   = "workspace"
+	eventLoopGoroutine_Application = "application"
+)
+
+var (
+	// eventLoopEventsReceived is the number of events received by each stage of the event loop pipeline.
+	eventLoopEventsReceived = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gitops_event_loop_events_received_total",
+		Help: "Number of events received by each stage of the event loop pipeline, by event type",
+	}, []string{"stage", "event_type"})
+
+	// eventLoopEventDuration is the time spent processing an event, including retries: in the preprocess stage, each
+	// attempt is instead observed separately, as retries are scheduled by the task retry loop.
+	eventLoopEventDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "gitops_event_loop_event_processing_duration_seconds",
+		Help:    "Time spent processing an event, by event loop stage and event type",
+		Buckets: []float64{0.005, 0.025, 0.1, 0.5, 1, 2.5, 5, 10, 30, 60, 300, 600},
+	}, []string{"stage", "event_type"})
+
+	// eventLoopEventRetries is the number of times processing an event failed, and was retried.
+	eventLoopEventRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gitops_event_loop_event_retries_total",
+		Help: "Number of times processing an event failed and was retried, by event loop stage and event type",
+	}, []string{"stage", "event_type"})
+
+	// eventLoopGoroutines is the number of live workspace and application event loop goroutines.
+	eventLoopGoroutines = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "gitops_event_loop_goroutines",
+		Help: "Number of live event loop goroutines, by type (workspace or application)",
+	}, []string{"type"})
+
+	// workspaceEventLoopOrphanedEvents is the number of GitOpsDeploymentSyncRun events that are waiting for the
+	// GitOpsDeployment they reference to be created, across all the workspace event loops.
+	workspaceEventLoopOrphanedEvents = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "gitops_workspace_event_loop_orphaned_events",
+		Help: "Number of events waiting in the workspace event loops for the GitOpsDeployment they reference to be created",
+	})
+
+	// eventLoopsStarted is 1 once the event loops of this replica have started processing events: that is, on the
+	// leader, or in sharding mode, once the workspaces owned by the replica are known. See 'PreprocessEventLoop'.
+	eventLoopsStarted = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "gitops_event_loops_started",
+		Help: "1 if the event loops of this replica have started processing events (on the leader, or in sharding mode, once the owned workspaces are known), 0 otherwise",
+	})
+)
+
+func init() {
+	metrics.Registry.MustRegister(eventLoopEventsReceived, eventLoopEventDuration, eventLoopEventRetries,
+		eventLoopGoroutines, workspaceEventLoopOrphanedEvents, eventLoopsStarted)
+}
+
+// reportEventReceived counts an event received by a stage of the event loop pipeline.
+func reportEventReceived(stage string, event *eventLoopEvent) {
+	eventLoopEventsReceived.WithLabelValues(stage, string(event.eventType)).Inc()
+}
+
+// reportEventProcessed observes the time spent processing an event by a stage, since 'start'.
+func reportEventProcessed(stage string, event *eventLoopEvent, start time.Time) {
+	eventLoopEventDuration.WithLabelValues(stage, string(event.eventType)).Observe(time.Since(start).Seconds())
+}
+
+// reportEventRetried counts a failed attempt at processing an event, which will be retried.
+func reportEventRetried(stage string, event *eventLoopEvent) {
+	eventLoopEventRetries.WithLabelValues(stage, string(event.eventType)).Inc()
+}
@@ -0,0 +1,209 @@
+package eventloop
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	operation "github.com/redhat-appstudio/managed-gitops/backend-shared/apis/managed-gitops/v1alpha1"
+	db "github.com/redhat-appstudio/managed-gitops/backend-shared/config/db"
+	sharedutil "github.com/redhat-appstudio/managed-gitops/backend-shared/util"
+	apierr "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// operationCollectorInterval is how often the OperationCollector purges operations that are no longer retained
+	operationCollectorInterval = 10 * time.Minute
+
+	// completedOperationCRRetention is how long the Operation CR of a completed operation is kept: the CR is usually
+	// deleted by the backend once the operation completes, but may be left behind (for example, if the delete failed).
+	completedOperationCRRetention = 1 * time.Hour
+
+	// orphanedSyncOperationRetention is how long a SyncOperation is kept after it was created, if it is not referenced
+	// by a GitOpsDeploymentSyncRun.
+	orphanedSyncOperationRetention = 1 * time.Hour
+)
+
+// Environment variables that may be used to configure the OperationRetentionPolicy
+const (
+	envOperationRetentionKeepLast = "OPERATION_RETENTION_KEEP_LAST"
+	envOperationRetentionDays     = "OPERATION_RETENTION_DAYS"
+)
+
+// OperationRetentionPolicy determines which completed Operation database rows are kept: an operation is kept if it is
+// one of the last 'KeepLastPerResource' operations of its resource, or if it is newer than 'MaxAge'.
+type OperationRetentionPolicy struct {
+	KeepLastPerResource int
+	MaxAge              time.Duration
+}
+
+// DefaultOperationRetentionPolicy is used when the retention policy is not configured via environment variables
+var DefaultOperationRetentionPolicy = OperationRetentionPolicy{
+	KeepLastPerResource: 5,
+	MaxAge:              7 * 24 * time.Hour,
+}
+
+// GetOperationRetentionPolicy returns the retention policy configured by the OPERATION_RETENTION_KEEP_LAST and
+// OPERATION_RETENTION_DAYS environment variables, using DefaultOperationRetentionPolicy for any that are not set.
+func GetOperationRetentionPolicy() (OperationRetentionPolicy, error) {
+
+	res := DefaultOperationRetentionPolicy
+
+	if keepLastEnv := strings.TrimSpace(os.Getenv(envOperationRetentionKeepLast)); keepLastEnv != "" {
+		keepLast, err := strconv.Atoi(keepLastEnv)
+		if err != nil || keepLast < 0 {
+			return res, fmt.Errorf("invalid value for %s: '%s'", envOperationRetentionKeepLast, keepLastEnv)
+		}
+		res.KeepLastPerResource = keepLast
+	}
+
+	if daysEnv := strings.TrimSpace(os.Getenv(envOperationRetentionDays)); daysEnv != "" {
+		days, err := strconv.Atoi(daysEnv)
+		if err != nil || days < 0 {
+			return res, fmt.Errorf("invalid value for %s: '%s'", envOperationRetentionDays, daysEnv)
+		}
+		res.MaxAge = time.Duration(days) * 24 * time.Hour
+	}
+
+	return res, nil
+}
+
+// OperationCollector periodically purges:
+// - Operation database rows that are no longer retained by the OperationRetentionPolicy
+// - Operation CRs in GitOps engine namespaces whose operation no longer exists, or has completed
+// - SyncOperation database rows that are no longer referenced by a GitOpsDeploymentSyncRun
+//
+// OperationCollector implements the controller-runtime manager.Runnable interface.
+type OperationCollector struct {
+	retentionPolicy OperationRetentionPolicy
+
+	dbQueries db.DatabaseQueries
+
+	// getK8sClientForGitOpsEngineInstance returns the client for the cluster of the GitOps engine instance: see 'gitopsEngineClientCache'
+	getK8sClientForGitOpsEngineInstance func(ctx context.Context, gitopsEngineInstance *db.GitopsEngineInstance) (client.Client, error)
+}
+
+// NewOperationCollector creates a new OperationCollector; it should be added to the manager, which will start it.
+func NewOperationCollector(retentionPolicy OperationRetentionPolicy, dbQueries db.DatabaseQueries) *OperationCollector {
+	return &OperationCollector{
+		retentionPolicy:                     retentionPolicy,
+		dbQueries:                           dbQueries,
+		getK8sClientForGitOpsEngineInstance: actionGetK8sClientForGitOpsEngineInstance,
+	}
+}
+
+// Start purges operations every operationCollectorInterval, until the context is cancelled.
+func (collector *OperationCollector) Start(ctx context.Context) error {
+
+	log := log.FromContext(ctx).WithName("operation-collector")
+
+	ticker := time.NewTicker(operationCollectorInterval)
+	defer ticker.Stop()
+
+	for {
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		if err := collector.collect(ctx, time.Now(), log); err != nil {
+			log.Error(err, "unable to purge operations")
+		}
+	}
+}
+
+func (collector *OperationCollector) collect(ctx context.Context, now time.Time, log logr.Logger) error {
+
+	// 1) Purge the Operation rows that are no longer retained
+	rowsAffected, err := collector.dbQueries.DeleteExpiredOperations(ctx, collector.retentionPolicy.KeepLastPerResource, now.Add(-collector.retentionPolicy.MaxAge))
+	if err != nil {
+		return err
+	}
+	if rowsAffected > 0 {
+		log.Info("Deleted operations that are no longer retained", "operations", rowsAffected)
+	}
+
+	// 2) Purge the SyncOperation rows that are no longer referenced
+	rowsAffected, err = collector.dbQueries.DeleteOrphanedSyncOperations(ctx, now.Add(-orphanedSyncOperationRetention))
+	if err != nil {
+		return err
+	}
+	if rowsAffected > 0 {
+		log.Info("Deleted sync operations that are no longer referenced", "syncOperations", rowsAffected)
+	}
+
+	// 3) Purge the Operation CRs of each GitOps engine instance
+	var gitopsEngineInstances []db.GitopsEngineInstance
+	if err := collector.dbQueries.ListGitopsEngineInstances(ctx, &gitopsEngineInstances); err != nil {
+		return err
+	}
+
+	for idx := range gitopsEngineInstances {
+		gitopsEngineInstance := gitopsEngineInstances[idx]
+
+		// An error with one instance (for example, its cluster is unreachable) should not prevent the others from being purged
+		if err := collector.collectOperationCRs(ctx, gitopsEngineInstance, now, log); err != nil {
+			log.Error(err, "unable to purge Operation CRs of gitops engine instance", "instance", gitopsEngineInstance.Gitopsengineinstance_id)
+		}
+	}
+
+	return nil
+}
+
+// collectOperationCRs deletes the Operation CRs in the namespace of the GitOps engine instance whose operation no
+// longer exists, or has completed (and the CR is older than completedOperationCRRetention).
+func (collector *OperationCollector) collectOperationCRs(ctx context.Context, gitopsEngineInstance db.GitopsEngineInstance, now time.Time, log logr.Logger) error {
+
+	gitopsEngineClient, err := collector.getK8sClientForGitOpsEngineInstance(ctx, &gitopsEngineInstance)
+	if err != nil {
+		return err
+	}
+
+	var operationList operation.OperationList
+	if err := gitopsEngineClient.List(ctx, &operationList, &client.ListOptions{Namespace: gitopsEngineInstance.Namespace_name}); err != nil {
+		return fmt.Errorf("unable to list Operation CRs in namespace '%s': %v", gitopsEngineInstance.Namespace_name, err)
+	}
+
+	for idx := range operationList.Items {
+		operationCR := operationList.Items[idx]
+
+		shouldDelete := false
+
+		dbOperation := db.Operation{Operation_id: operationCR.Spec.OperationID}
+		if operationCR.Spec.OperationID == "" {
+			shouldDelete = true
+
+		} else if err := collector.dbQueries.GetOperationById(ctx, &dbOperation); err != nil {
+			if !db.IsResultNotFoundError(err) {
+				return err
+			}
+			// The operation no longer exists
+			shouldDelete = true
+
+		} else if db.IsOperationStateComplete(dbOperation.State) && now.Sub(operationCR.CreationTimestamp.Time) > completedOperationCRRetention {
+			shouldDelete = true
+		}
+
+		if !shouldDelete {
+			continue
+		}
+
+		if err := gitopsEngineClient.Delete(ctx, &operationCR); err != nil && !apierr.IsNotFound(err) {
+			log.Error(err, "unable to delete Operation CR", "name", operationCR.Name, "namespace", operationCR.Namespace)
+			continue
+		}
+
+		log.V(sharedutil.LogLevel_Debug).Info("Deleted Operation CR", "name", operationCR.Name, "namespace", operationCR.Namespace,
+			"operation", operationCR.Spec.OperationID)
+	}
+
+	return nil
+}
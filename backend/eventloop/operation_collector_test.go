@@ -0,0 +1,153 @@
+package eventloop
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	operation "github.com/redhat-appstudio/managed-gitops/backend-shared/apis/managed-gitops/v1alpha1"
+	db "github.com/redhat-appstudio/managed-gitops/backend-shared/config/db"
+	"github.com/stretchr/testify/assert"
+	apierr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestGetOperationRetentionPolicy(t *testing.T) {
+
+	t.Run("default policy is used when not configured", func(t *testing.T) {
+		t.Setenv(envOperationRetentionKeepLast, "")
+		t.Setenv(envOperationRetentionDays, "")
+
+		policy, err := GetOperationRetentionPolicy()
+		assert.NoError(t, err)
+		assert.Equal(t, DefaultOperationRetentionPolicy, policy)
+	})
+
+	t.Run("policy is configured via environment variables", func(t *testing.T) {
+		t.Setenv(envOperationRetentionKeepLast, "3")
+		t.Setenv(envOperationRetentionDays, "2")
+
+		policy, err := GetOperationRetentionPolicy()
+		assert.NoError(t, err)
+		assert.Equal(t, OperationRetentionPolicy{KeepLastPerResource: 3, MaxAge: 48 * time.Hour}, policy)
+	})
+
+	t.Run("invalid values return an error", func(t *testing.T) {
+		t.Setenv(envOperationRetentionKeepLast, "-1")
+		t.Setenv(envOperationRetentionDays, "")
+
+		_, err := GetOperationRetentionPolicy()
+		assert.Error(t, err)
+
+		t.Setenv(envOperationRetentionKeepLast, "")
+		t.Setenv(envOperationRetentionDays, "a week")
+
+		_, err = GetOperationRetentionPolicy()
+		assert.Error(t, err)
+	})
+}
+
+func TestOperationCollector(t *testing.T) {
+
+	ctx := context.Background()
+
+	scheme, argocdNamespace, _, _ := genericTestSetup(t)
+
+	now := time.Now()
+
+	newOperationCR := func(name string, operationID string, created time.Time) *operation.Operation {
+		return &operation.Operation{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				Namespace:         argocdNamespace.Name,
+				CreationTimestamp: metav1.NewTime(created),
+			},
+			Spec: operation.OperationSpec{OperationID: operationID},
+		}
+	}
+
+	missingOperationCR := newOperationCR("operation-missing", "missing-op", now)
+	oldCompletedOperationCR := newOperationCR("operation-old-completed", "old-completed-op", now.Add(-2*completedOperationCRRetention))
+	newCompletedOperationCR := newOperationCR("operation-new-completed", "new-completed-op", now)
+	inProgressOperationCR := newOperationCR("operation-in-progress", "in-progress-op", now.Add(-2*completedOperationCRRetention))
+
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).
+		WithObjects(missingOperationCR, oldCompletedOperationCR, newCompletedOperationCR, inProgressOperationCR).Build()
+
+	dbQueries := &collectorTestDatabaseQueries{
+		operations: map[string]db.Operation{
+			"old-completed-op": {Operation_id: "old-completed-op", State: db.OperationState_Completed},
+			"new-completed-op": {Operation_id: "new-completed-op", State: db.OperationState_Failed},
+			"in-progress-op":   {Operation_id: "in-progress-op", State: db.OperationState_In_Progress},
+		},
+		gitopsEngineInstances: []db.GitopsEngineInstance{
+			{Gitopsengineinstance_id: "test-instance", Namespace_name: argocdNamespace.Name},
+		},
+	}
+
+	collector := &OperationCollector{
+		retentionPolicy: OperationRetentionPolicy{KeepLastPerResource: 2, MaxAge: 24 * time.Hour},
+		dbQueries:       dbQueries,
+		getK8sClientForGitOpsEngineInstance: func(ctx context.Context, gitopsEngineInstance *db.GitopsEngineInstance) (client.Client, error) {
+			return k8sClient, nil
+		},
+	}
+
+	err := collector.collect(ctx, now, log.FromContext(ctx))
+	assert.NoError(t, err)
+
+	assert.Equal(t, 2, dbQueries.deleteExpiredOperationsKeepLast)
+	assert.Equal(t, now.Add(-24*time.Hour), dbQueries.deleteExpiredOperationsCreatedBefore)
+	assert.Equal(t, now.Add(-orphanedSyncOperationRetention), dbQueries.deleteOrphanedSyncOperationsCreatedBefore)
+
+	for _, deletedCR := range []*operation.Operation{missingOperationCR, oldCompletedOperationCR} {
+		err := k8sClient.Get(ctx, client.ObjectKeyFromObject(deletedCR), &operation.Operation{})
+		assert.True(t, apierr.IsNotFound(err), "Operation CR should be deleted: "+deletedCR.Name)
+	}
+
+	for _, retainedCR := range []*operation.Operation{newCompletedOperationCR, inProgressOperationCR} {
+		err := k8sClient.Get(ctx, client.ObjectKeyFromObject(retainedCR), &operation.Operation{})
+		assert.NoError(t, err, "Operation CR should be retained: "+retainedCR.Name)
+	}
+}
+
+// collectorTestDatabaseQueries implements the subset of DatabaseQueries that is used by OperationCollector: calls to
+// any other function will panic.
+type collectorTestDatabaseQueries struct {
+	db.DatabaseQueries
+
+	operations            map[string]db.Operation
+	gitopsEngineInstances []db.GitopsEngineInstance
+
+	deleteExpiredOperationsKeepLast           int
+	deleteExpiredOperationsCreatedBefore      time.Time
+	deleteOrphanedSyncOperationsCreatedBefore time.Time
+}
+
+func (dbq *collectorTestDatabaseQueries) DeleteExpiredOperations(ctx context.Context, keepLastPerResource int, createdBefore time.Time) (int, error) {
+	dbq.deleteExpiredOperationsKeepLast = keepLastPerResource
+	dbq.deleteExpiredOperationsCreatedBefore = createdBefore
+	return 0, nil
+}
+
+func (dbq *collectorTestDatabaseQueries) DeleteOrphanedSyncOperations(ctx context.Context, createdBefore time.Time) (int, error) {
+	dbq.deleteOrphanedSyncOperationsCreatedBefore = createdBefore
+	return 0, nil
+}
+
+func (dbq *collectorTestDatabaseQueries) ListGitopsEngineInstances(ctx context.Context, gitopsEngineInstances *[]db.GitopsEngineInstance) error {
+	*gitopsEngineInstances = dbq.gitopsEngineInstances
+	return nil
+}
+
+func (dbq *collectorTestDatabaseQueries) GetOperationById(ctx context.Context, operation *db.Operation) error {
+	res, exists := dbq.operations[operation.Operation_id]
+	if !exists {
+		return db.NewResultNotFoundError("operation")
+	}
+	*operation = res
+	return nil
+}
@@ -0,0 +1,93 @@
+package eventloop
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+	db "github.com/redhat-appstudio/managed-gitops/backend-shared/config/db"
+	dbutil "github.com/redhat-appstudio/managed-gitops/backend-shared/config/db/util"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// operationReaperInterval is how often the OperationReaper checks for operations that have passed their deadline
+	operationReaperInterval = 1 * time.Minute
+)
+
+// OperationReaper periodically moves operations that have not completed by their deadline to the Timeout state: for
+// example, an operation that was never processed because no cluster-agent was running, or an operation that is
+// continually retried by the cluster-agent. The deadline of an operation is based on its resource type, see
+// 'db.GetOperationTimeout'.
+//
+// The OperationReaper also reports the operations it times out, and the outstanding operations of each
+// GitopsEngineInstance, as operation lifecycle metrics (see 'dbutil.ReportOperationCompleted').
+//
+// OperationReaper implements the controller-runtime manager.Runnable interface.
+type OperationReaper struct {
+	dbQueries db.DatabaseQueries
+}
+
+// NewOperationReaper creates a new OperationReaper; it should be added to the manager, which will start it.
+func NewOperationReaper(dbQueries db.DatabaseQueries) *OperationReaper {
+	return &OperationReaper{dbQueries: dbQueries}
+}
+
+// Start checks for expired operations every operationReaperInterval, until the context is cancelled.
+func (reaper *OperationReaper) Start(ctx context.Context) error {
+
+	log := log.FromContext(ctx).WithName("operation-reaper")
+
+	ticker := time.NewTicker(operationReaperInterval)
+	defer ticker.Stop()
+
+	for {
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		if err := reaper.timeoutExpiredOperations(ctx, time.Now(), log); err != nil {
+			log.Error(err, "unable to time out expired operations")
+		}
+
+		if err := reaper.reportOutstandingOperations(ctx); err != nil {
+			log.Error(err, "unable to report outstanding operations")
+		}
+	}
+}
+
+func (reaper *OperationReaper) timeoutExpiredOperations(ctx context.Context, now time.Time, log logr.Logger) error {
+
+	var timedOutOperations []db.Operation
+	if err := reaper.dbQueries.TimeoutExpiredOperations(ctx, now, &timedOutOperations); err != nil {
+		return err
+	}
+
+	if len(timedOutOperations) > 0 {
+		log.Info("Operations did not complete before their deadline, and were moved to the Timeout state", "operations", len(timedOutOperations))
+	}
+
+	// The time at which a cluster-agent started processing the operations (if it did) is not known here, so only their
+	// total duration is reported.
+	for _, operation := range timedOutOperations {
+		dbutil.ReportOperationCompleted(operation, time.Time{})
+	}
+
+	return nil
+}
+
+// reportOutstandingOperations updates the number of outstanding operations of each GitopsEngineInstance.
+func (reaper *OperationReaper) reportOutstandingOperations(ctx context.Context) error {
+
+	var incompleteOperations []db.Operation
+	if err := reaper.dbQueries.ListIncompleteOperations(ctx, &incompleteOperations); err != nil {
+		return err
+	}
+
+	dbutil.ReportOutstandingOperations(incompleteOperations)
+
+	return nil
+}
@@ -3,6 +3,7 @@ package eventloop
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"sync"
 	"time"
 
@@ -10,8 +11,11 @@ import (
 	db "github.com/redhat-appstudio/managed-gitops/backend-shared/config/db"
 	sharedutil "github.com/redhat-appstudio/managed-gitops/backend-shared/util"
 	managedgitopsv1alpha1 "github.com/redhat-appstudio/managed-gitops/backend/apis/managed-gitops/v1alpha1"
+	"github.com/redhat-appstudio/managed-gitops/backend/sharding"
+	corev1 "k8s.io/api/core/v1"
 	apierr "k8s.io/apimachinery/pkg/api/errors"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
@@ -28,66 +32,311 @@ import (
 // Invariants:
 // - The cache should only ever use values from the database. It should be eventually consistent with the database.
 
-// EventReceived is called by controllers to inform of it changes to API CRs
+// EventReceived is called by controllers to inform of it changes to API CRs. In sharding mode, controllers should only
+// call EventReceived for the workspaces owned by this replica, see OwnsWorkspace.
+//
+// EventReceived blocks until the event loops have been started, see Start.
 func (evl *PreprocessEventLoop) EventReceived(req ctrl.Request, reqResource managedgitopsv1alpha1.GitOpsResourceType, client client.Client, eventType EventLoopEventType, workspaceID string) {
 
 	event := eventLoopEvent{request: req, eventType: eventType, workspaceID: workspaceID, client: client, reqResource: reqResource}
 	evl.eventLoopInputChannel <- event
 }
 
+// PreprocessEventLoop implements the controller-runtime manager.Runnable interface: the event loops are started by the
+// manager, and only once it has been elected leader (if leader election is enabled). The event loops keep their state
+// in memory, so only a single replica of the backend may process the events of a workspace at a time.
+//
+// A replica that loses leadership exits (the manager stops with an error), so the in-memory state of the event loops
+// never outlives the leadership of the replica. A newly elected leader starts with empty event loops, and rebuilds their
+// state: the controllers re-list all the CRs on startup, and the remaining state is rehydrated from the database (see
+// 'rehydrate').
+//
+// In sharding mode, the event loops run on every replica, and each replica processes the events of the workspaces it
+// owns (see the sharding package). When the owned workspaces change, the state of the owned workspaces is rehydrated
+// from the CRs and the database.
 type PreprocessEventLoop struct {
 	eventLoopInputChannel chan eventLoopEvent
 	nextStep              *controllerEventLoop
+
+	// k8sClient is the client passed with the events that are sent by 'rehydrate'
+	k8sClient client.Client
+
+	// workspaceSharding is nil, unless sharding mode is enabled
+	workspaceSharding *sharding.WorkspaceSharding
+
+	// reconciliationInterval is how often the reconciliation sweep runs, in addition to when the event loops are
+	// rehydrated: 0 disables the periodic sweep.
+	reconciliationInterval time.Duration
+
+	dbQueries db.DatabaseQueries
+
+	// resourcesSeen is the cache of the UIDs of the CRs seen by the router, which is warmed by 'rehydrate'
+	resourcesSeen *resourcesSeenCache
+
+	// started is closed once the event loops have started, and their state has been rehydrated
+	started     chan struct{}
+	startedOnce sync.Once
 }
 
-func NewPreprocessEventLoop() *PreprocessEventLoop {
-	channel := make(chan eventLoopEvent)
+// NewPreprocessEventLoop creates a new PreprocessEventLoop; it should be added to the manager, which will start it.
+// 'workspaceSharding' should be nil, unless sharding mode is enabled. See 'reconciliationSweep' for 'reconciliationInterval'.
+func NewPreprocessEventLoop(k8sClient client.Client, dbQueries db.DatabaseQueries, workspaceSharding *sharding.WorkspaceSharding,
+	reconciliationInterval time.Duration) *PreprocessEventLoop {
+
+	evl := &PreprocessEventLoop{
+		eventLoopInputChannel:  make(chan eventLoopEvent),
+		k8sClient:              k8sClient,
+		dbQueries:              dbQueries,
+		workspaceSharding:      workspaceSharding,
+		reconciliationInterval: reconciliationInterval,
+		resourcesSeen:          newResourcesSeenCache(resourcesSeenCacheMaxEntries, resourcesSeenCacheTTL),
+		started:                make(chan struct{}),
+	}
 
-	res := &PreprocessEventLoop{}
-	res.eventLoopInputChannel = channel
-	res.nextStep = newControllerEventLoop()
+	if workspaceSharding != nil {
+		workspaceSharding.OnOwnershipChanged(func(ctx context.Context) {
+			evl.rehydrateUntilSuccessful(ctx, log.FromContext(ctx).WithName("preprocess-event-loop"))
+		})
+	}
 
-	go preprocessEventLoopRouter(channel, res.nextStep)
+	return evl
+}
 
-	return res
+// NeedLeaderElection returns true, unless sharding mode is enabled: the event loops should then run on every replica.
+func (evl *PreprocessEventLoop) NeedLeaderElection() bool {
+	return evl.workspaceSharding == nil
+}
 
+// OwnsWorkspace returns true if the events of the workspace should be processed by this replica. This is always true,
+// unless sharding mode is enabled.
+func (evl *PreprocessEventLoop) OwnsWorkspace(workspaceID string) bool {
+	return evl.workspaceSharding == nil || evl.workspaceSharding.OwnsWorkspace(workspaceID)
 }
 
-func preprocessEventLoopRouter(input chan eventLoopEvent, nextStep *controllerEventLoop /*, workspaceID string*/) {
+// Start starts the event loops, rehydrates their state from the database, then periodically runs the reconciliation
+// sweep. The event loops run until the process exits: they are not stopped when the context is cancelled, as the manager
+// is then shutting down.
+//
+// In sharding mode, the state is instead rehydrated once the owned workspaces are known, and each time they change.
+func (evl *PreprocessEventLoop) Start(ctx context.Context) error {
 
-	ctx := context.Background()
+	log := log.FromContext(ctx).WithName("preprocess-event-loop")
 
-	log := log.FromContext(ctx)
+	evl.nextStep = newControllerEventLoop(evl.dbQueries, evl.OwnsWorkspace)
 
-	taskRetryLoop := sharedutil.NewTaskRetryLoop("event-loop-router-retry-loop")
+	go preprocessEventLoopRouter(evl.eventLoopInputChannel, evl.nextStep, evl.resourcesSeen, evl.dbQueries)
 
-	// (cache key) -> (uid of the sync/syncrun resource, the last time it was seen)
-	resourcesSeen := map[string]string{}
-	var resourcesSeenMutex sync.RWMutex // Acquire this mutex whenever resourcesSeen is read/modified
-	// TODO: GITOPS-1702 - PERF - Add a size limit to this: evict LRU if over a certain size, to keep from hitting memory limit.
+	if evl.workspaceSharding == nil {
+		evl.rehydrateUntilSuccessful(ctx, log)
+	}
 
-	dbQueries, err := db.NewProductionPostgresDBQueries(false)
-	if err != nil {
-		log.Error(err, "SEVERE: preProcessEventLoopRouter exiting before startup")
+	if evl.reconciliationInterval > 0 {
+		evl.runReconciliationSweeps(ctx, log)
+	}
+
+	<-ctx.Done()
+	return nil
+}
+
+// rehydrateUntilSuccessful rehydrates the state of the event loops, retrying until it succeeds (or the context is
+// cancelled). The event loops are then reported as ready (see 'ReadyzCheck'), and as started by the
+// 'eventLoopsStarted' metric.
+func (evl *PreprocessEventLoop) rehydrateUntilSuccessful(ctx context.Context, log logr.Logger) {
+
+	backoff := sharedutil.ExponentialBackoff{Factor: 2, Min: time.Millisecond * 200, Max: time.Second * 10, Jitter: true}
+	if err := sharedutil.RunTaskUntilTrue(ctx, &backoff, "rehydrate event loops from database", log, func() (bool, error) {
+		err := evl.rehydrate(ctx, log)
+		return err == nil, err
+	}); err != nil {
 		return
 	}
 
+	evl.startedOnce.Do(func() {
+		log.Info("Event loops started")
+		close(evl.started)
+		eventLoopsStarted.Set(1)
+	})
+}
+
+// ReadyzCheck is a controller-runtime healthz.Checker, which reports ready once the event loops have started: that is,
+// only on the leader (or, in sharding mode, once the owned workspaces are known).
+func (evl *PreprocessEventLoop) ReadyzCheck(_ *http.Request) error {
+	select {
+	case <-evl.started:
+		return nil
+	default:
+		if evl.workspaceSharding != nil {
+			return fmt.Errorf("event loops have not started: the workspaces owned by this replica are not yet known")
+		}
+		return fmt.Errorf("event loops have not started: this replica is not the leader")
+	}
+}
+
+// rehydrate sends an event for every GitOpsDeployment and GitOpsDeploymentSyncRun that is referenced by a row of the
+// database, but whose CR no longer exists or has drifted (see 'reconciliationSweep'). The controllers re-list the CRs
+// that exist when they start, but a CR that was deleted while no replica was processing events would otherwise never be
+// cleaned up: the event for the deleted CR allows the event loops to (re)discover the corresponding database state, and
+// act on it.
+//
+// In sharding mode, only the events of the owned workspaces are sent. All the CRs are also re-listed, as the
+// controllers of this replica ignored the events of the workspaces it has just gained.
+//
+// The cache of the resources seen by the router is also warmed from the database, before the events are sent.
+func (evl *PreprocessEventLoop) rehydrate(ctx context.Context, log logr.Logger) error {
+
+	events, err := evl.reconciliationSweep(ctx, log)
+	if err != nil {
+		return err
+	}
+
+	cacheEntries, err := evl.resourcesSeen.warm(ctx, evl.dbQueries, evl.OwnsWorkspace)
+	if err != nil {
+		return err
+	}
+
+	if evl.workspaceSharding != nil {
+		listedEvents, err := evl.listEventsForCRs(ctx)
+		if err != nil {
+			return err
+		}
+		events = append(events, listedEvents...)
+	}
+
+	eventsSent := evl.sendEvents(events)
+
+	log.Info("Rehydrated event loops from database", "events", eventsSent, "cacheEntriesAdded", cacheEntries)
+
+	return nil
+}
+
+// runReconciliationSweeps runs the reconciliation sweep every reconciliationInterval, until the context is cancelled.
+// The sweep is skipped until the event loops have been rehydrated, as rehydrating includes a sweep.
+func (evl *PreprocessEventLoop) runReconciliationSweeps(ctx context.Context, log logr.Logger) {
+
+	ticker := time.NewTicker(evl.reconciliationInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		select {
+		case <-evl.started:
+		default:
+			continue
+		}
+
+		events, err := evl.reconciliationSweep(ctx, log)
+		if err != nil {
+			log.Error(err, "unable to run reconciliation sweep")
+			continue
+		}
+		evl.sendEvents(events)
+	}
+}
+
+// sendEvents sends the events of the owned workspaces to the event loops, and returns the number of events sent.
+func (evl *PreprocessEventLoop) sendEvents(events []eventLoopEvent) int {
+
+	// The same CR may be referenced by multiple rows, and may also have been listed
+	eventsSent := map[eventLoopEvent]bool{}
+	for _, event := range events {
+		if eventsSent[event] || !evl.OwnsWorkspace(event.workspaceID) {
+			continue
+		}
+		eventsSent[event] = true
+		evl.EventReceived(event.request, event.reqResource, evl.k8sClient, event.eventType, event.workspaceID)
+	}
+
+	return len(eventsSent)
+}
+
+// listEventsForCRs returns an event for every GitOpsDeployment and GitOpsDeploymentSyncRun.
+func (evl *PreprocessEventLoop) listEventsForCRs(ctx context.Context) ([]eventLoopEvent, error) {
+
+	// The workspace ID is the UID of the namespace of the CR
+	workspaceIDs := map[string]string{}
+	getWorkspaceID := func(namespaceName string) (string, error) {
+		if workspaceID, exists := workspaceIDs[namespaceName]; exists {
+			return workspaceID, nil
+		}
+		namespace := corev1.Namespace{}
+		if err := evl.k8sClient.Get(ctx, types.NamespacedName{Name: namespaceName}, &namespace); err != nil {
+			return "", fmt.Errorf("unable to retrieve namespace '%s': %v", namespaceName, err)
+		}
+		workspaceIDs[namespaceName] = string(namespace.UID)
+		return string(namespace.UID), nil
+	}
+
+	events := []eventLoopEvent{}
+
+	var gitopsDeplList managedgitopsv1alpha1.GitOpsDeploymentList
+	if err := evl.k8sClient.List(ctx, &gitopsDeplList); err != nil {
+		return nil, fmt.Errorf("unable to list GitOpsDeployments: %v", err)
+	}
+	for _, gitopsDepl := range gitopsDeplList.Items {
+		workspaceID, err := getWorkspaceID(gitopsDepl.Namespace)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, eventLoopEvent{
+			eventType:   DeploymentModified,
+			request:     ctrl.Request{NamespacedName: client.ObjectKeyFromObject(&gitopsDepl)},
+			reqResource: managedgitopsv1alpha1.GitOpsDeploymentTypeName,
+			workspaceID: workspaceID,
+		})
+	}
+
+	var syncRunList managedgitopsv1alpha1.GitOpsDeploymentSyncRunList
+	if err := evl.k8sClient.List(ctx, &syncRunList); err != nil {
+		return nil, fmt.Errorf("unable to list GitOpsDeploymentSyncRuns: %v", err)
+	}
+	for _, syncRun := range syncRunList.Items {
+		workspaceID, err := getWorkspaceID(syncRun.Namespace)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, eventLoopEvent{
+			eventType:   SyncRunModified,
+			request:     ctrl.Request{NamespacedName: client.ObjectKeyFromObject(&syncRun)},
+			reqResource: managedgitopsv1alpha1.GitOpsDeploymentSyncRunTypeName,
+			workspaceID: workspaceID,
+		})
+	}
+
+	return events, nil
+}
+
+// preprocessEventLoopRouter processes the events received on 'input', using 'resourcesSeen' to determine the UID of
+// the CRs that have been deleted.
+func preprocessEventLoopRouter(input chan eventLoopEvent, nextStep *controllerEventLoop, resourcesSeen *resourcesSeenCache,
+	dbQueries db.DatabaseQueries /*, workspaceID string*/) {
+
+	ctx := context.Background()
+
+	log := log.FromContext(ctx)
+
+	taskRetryLoop := sharedutil.NewTaskRetryLoop("event-loop-router-retry-loop")
+
 	for {
 
 		// Block on waiting for more events
 		newEvent := <-input
-		mapKey := string(newEvent.reqResource) + "-" + newEvent.request.Name + "-" + newEvent.request.Namespace + "-" + newEvent.workspaceID
-		// TODO: GITOPS-1702 - PERF - Use a more memory efficient key
+		reportEventReceived(eventLoopStage_Preprocess, &newEvent)
+
+		mapKey := resourcesSeenCacheKey(newEvent.reqResource, newEvent.request.Name, newEvent.request.Namespace, newEvent.workspaceID)
 
 		// Pass the event to the retry loop, for processing
 		task := &processEventTask{
-			newEvent:           newEvent,
-			mapKey:             mapKey,
-			nextStep:           nextStep,
-			dbQueries:          dbQueries,
-			log:                log,
-			resourcesSeen:      resourcesSeen,
-			resourcesSeenMutex: &resourcesSeenMutex,
+			newEvent:      newEvent,
+			mapKey:        mapKey,
+			nextStep:      nextStep,
+			dbQueries:     dbQueries,
+			log:           log,
+			resourcesSeen: resourcesSeen,
 		}
 
 		taskRetryLoop.AddTaskIfNotPresent(mapKey, task, sharedutil.ExponentialBackoff{Factor: 2, Min: time.Millisecond * 200, Max: time.Second * 10, Jitter: true})
@@ -102,15 +351,22 @@ type processEventTask struct {
 	dbQueries db.DatabaseQueries
 	log       logr.Logger
 
-	// resourcesSeen should only be accessed while holding resourcesSeenMutex
-	resourcesSeen      map[string]string
-	resourcesSeenMutex *sync.RWMutex
+	// resourcesSeen maps (cache key) -> (uid of the sync/syncrun resource, when it was last seen)
+	resourcesSeen *resourcesSeenCache
 }
 
 func (task *processEventTask) PerformTask(taskContext context.Context) (bool, error) {
 
-	return task.processEvent(taskContext, task.newEvent, task.mapKey, task.nextStep, task.dbQueries, task.log), nil
+	start := time.Now()
+
+	shouldRetry := task.processEvent(taskContext, task.newEvent, task.mapKey, task.nextStep, task.dbQueries, task.log)
+
+	reportEventProcessed(eventLoopStage_Preprocess, &task.newEvent, start)
+	if shouldRetry {
+		reportEventRetried(eventLoopStage_Preprocess, &task.newEvent)
+	}
 
+	return shouldRetry, nil
 }
 
 func (task *processEventTask) processEvent(ctx context.Context, newEvent eventLoopEvent, mapKey string,
@@ -155,7 +411,7 @@ func (task *processEventTask) processEvent(ctx context.Context, newEvent eventLo
 		// Check the local cache, to see if we have seen this resource before
 		{
 
-			gitopsDeplUID, err := lookInCacheForAssociatedGitOpsDeplId(ctx, newEvent.reqResource, mapKey, task.resourcesSeen, task.resourcesSeenMutex, dbQueries, log)
+			gitopsDeplUID, err := lookInCacheForAssociatedGitOpsDeplId(ctx, newEvent.reqResource, mapKey, task.resourcesSeen, dbQueries, log)
 			if err != nil {
 				// If a generic error occurred (database or client connection issue), then log the error
 				// and return true, so that we can retry.
@@ -164,9 +420,7 @@ func (task *processEventTask) processEvent(ctx context.Context, newEvent eventLo
 			}
 
 			// Clear the cache after retrieving it, because the resource has necessarily been deleted from the namespace.
-			task.resourcesSeenMutex.Lock()
-			delete(task.resourcesSeen, mapKey)
-			task.resourcesSeenMutex.Unlock()
+			task.resourcesSeen.delete(mapKey)
 
 			// If the GitOpsDeployment CR UID was found in the cache, then tag the event and emit it.
 			if gitopsDeplUID != "" {
@@ -297,16 +551,14 @@ func (task *processEventTask) processEvent(ctx context.Context, newEvent eventLo
 		// In this else block, the event we received is for a GitOpsDeployment/SyncRun that exists in the namespace.
 
 		// The UID that this CR had when we previously saw it
-		previousGitopsDeplUID, err := lookInCacheForAssociatedGitOpsDeplId(ctx, newEvent.reqResource, mapKey, task.resourcesSeen, task.resourcesSeenMutex, dbQueries, log)
+		previousGitopsDeplUID, err := lookInCacheForAssociatedGitOpsDeplId(ctx, newEvent.reqResource, mapKey, task.resourcesSeen, dbQueries, log)
 		if err != nil {
 			log.Error(err, "unexpected error when checking database contents with local map", "mapKey", mapKey)
 			return true
 		}
 
 		// Update the cache with the latest UID for this resource
-		task.resourcesSeenMutex.Lock()
-		task.resourcesSeen[mapKey] = string(resource.GetUID())
-		task.resourcesSeenMutex.Unlock()
+		task.resourcesSeen.set(mapKey, string(resource.GetUID()))
 
 		if previousGitopsDeplUID != "" {
 			emitEventForExistingResource(previousGitopsDeplUID, newEvent, resource, nextStep, log)
@@ -514,11 +766,9 @@ func getGitOpsDeplIdFromDatabaseUsingNameAndNamespace(ctx context.Context, newEv
 // lookInCacheForAssociatedGitOpsDeplId looks in 'resourcesSeen' cache for the UID of the GitOpsDeployment that corresponds
 // to the given resource.
 func lookInCacheForAssociatedGitOpsDeplId(ctx context.Context, resourceType managedgitopsv1alpha1.GitOpsResourceType, key string,
-	resourcesSeen map[string]string, resourcesSeenMutex *sync.RWMutex, dbQueries db.DatabaseQueries, log logr.Logger) (string, error) {
+	resourcesSeen *resourcesSeenCache, dbQueries db.DatabaseQueries, log logr.Logger) (string, error) {
 
-	resourcesSeenMutex.RLock()
-	mapUID, exists := resourcesSeen[key]
-	resourcesSeenMutex.RUnlock()
+	mapUID, exists := resourcesSeen.get(key)
 
 	// Check the local cache
 	if exists {
@@ -556,7 +806,7 @@ func getGitOpsDeplIdFromSyncRunCR(ctx context.Context, gitopsSyncRunUID string,
 			return "", nil
 		}
 
-		return "", fmt.Errorf("unable to retrieve db mapping for sync run CR: %v", err)
+		return "", fmt.Errorf("unable to retrieve db mapping for sync run CR: %w", err)
 	}
 
 	// 2) We now have the SyncOperation table entry primary key, so retrieve it.
@@ -569,7 +819,7 @@ func getGitOpsDeplIdFromSyncRunCR(ctx context.Context, gitopsSyncRunUID string,
 			return "", nil
 		}
 
-		return "", fmt.Errorf("unable to retrieve sync operation db entry '%v' for sync run CR: %v", syncOperation.SyncOperation_id, err)
+		return "", fmt.Errorf("unable to retrieve sync operation db entry '%v' for sync run CR: %w", syncOperation.SyncOperation_id, err)
 	}
 
 	// It possible the Application (that this sync operation is targetting) is already deleted, if so just return.
@@ -588,7 +838,7 @@ func getGitOpsDeplIdFromSyncRunCR(ctx context.Context, gitopsSyncRunUID string,
 			return "", nil
 		}
 
-		return "", fmt.Errorf("unable to retrieve depltoappmapping '%v' for sync run CR: %v", dtam.Application_id, err)
+		return "", fmt.Errorf("unable to retrieve depltoappmapping '%v' for sync run CR: %w", dtam.Application_id, err)
 	}
 
 	return dtam.Deploymenttoapplicationmapping_uid_id, nil
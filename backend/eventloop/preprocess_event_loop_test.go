@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"testing"
 
+	db "github.com/redhat-appstudio/managed-gitops/backend-shared/config/db"
 	sharedutil "github.com/redhat-appstudio/managed-gitops/backend-shared/util"
 	managedgitopsv1alpha1 "github.com/redhat-appstudio/managed-gitops/backend/apis/managed-gitops/v1alpha1"
 	"github.com/stretchr/testify/assert"
@@ -12,6 +13,7 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/uuid"
 	fake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
@@ -44,7 +46,12 @@ func TestPreprocessEventLoop(t *testing.T) {
 
 	channel := make(chan eventLoopEvent)
 
-	go preprocessEventLoopRouter(channel, &fakeEventLoop)
+	dbQueries, err := db.NewProductionPostgresDBQueries(false)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	go preprocessEventLoopRouter(channel, &fakeEventLoop, newResourcesSeenCache(resourcesSeenCacheMaxEntries, resourcesSeenCacheTTL), dbQueries)
 
 	event := eventLoopEvent{
 		eventType: DeploymentModified,
@@ -88,3 +95,126 @@ func TestPreprocessEventLoop(t *testing.T) {
 	fmt.Printf("response: %v\n", <-fakeEventLoop.eventLoopInputChannel)
 
 }
+
+// rehydrateTestQueries returns fixed DeploymentToApplicationMappings, APICRToDatabaseMappings and Applications
+type rehydrateTestQueries struct {
+	db.DatabaseQueries
+
+	deplToAppMappings []db.DeploymentToApplicationMapping
+	apiCRToDBMappings []db.APICRToDatabaseMapping
+	applications      []db.Application
+}
+
+func (queries *rehydrateTestQueries) ListDeploymentToApplicationMappings(ctx context.Context, deplToAppMappings *[]db.DeploymentToApplicationMapping) error {
+	*deplToAppMappings = queries.deplToAppMappings
+	return nil
+}
+
+func (queries *rehydrateTestQueries) ListAPICRToDatabaseMappings(ctx context.Context, apiCRToDBMappings *[]db.APICRToDatabaseMapping) error {
+	*apiCRToDBMappings = queries.apiCRToDBMappings
+	return nil
+}
+
+func (queries *rehydrateTestQueries) ListGitopsEngineInstances(ctx context.Context, gitopsEngineInstances *[]db.GitopsEngineInstance) error {
+	*gitopsEngineInstances = []db.GitopsEngineInstance{{Gitopsengineinstance_id: "my-engine-instance", Namespace_name: "argocd"}}
+	return nil
+}
+
+func (queries *rehydrateTestQueries) ListApplicationsForGitopsEngineInstance(ctx context.Context, gitopsEngineInstanceId string, applications *[]db.Application) error {
+	*applications = queries.applications
+	return nil
+}
+
+func TestPreprocessEventLoopRehydrate(t *testing.T) {
+
+	scheme, _, _, _ := genericTestSetup(t)
+
+	newGitOpsDeployment := func(name string, repoURL string) *managedgitopsv1alpha1.GitOpsDeployment {
+		return &managedgitopsv1alpha1.GitOpsDeployment{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "my-namespace", UID: uuid.NewUUID()},
+			Spec: managedgitopsv1alpha1.GitOpsDeploymentSpec{
+				Source: managedgitopsv1alpha1.ApplicationSource{RepoURL: repoURL, Path: "path"},
+			},
+		}
+	}
+
+	// The Application of 'unchanged-gitops-depl' matches its spec, but the Application of 'drifted-gitops-depl' does not
+	unchangedGitOpsDepl := newGitOpsDeployment("unchanged-gitops-depl", "https://github.com/unchanged")
+	unchangedSpecField, err := createSpecField(newArgoCDSpecInput(unchangedGitOpsDepl, "unchanged-app", "argocd"))
+	assert.NoError(t, err)
+
+	driftedGitOpsDepl := newGitOpsDeployment("drifted-gitops-depl", "https://github.com/changed")
+	driftedSpecField, err := createSpecField(newArgoCDSpecInput(newGitOpsDeployment("drifted-gitops-depl", "https://github.com/original"),
+		"drifted-app", "argocd"))
+	assert.NoError(t, err)
+
+	unchangedSyncRun := &managedgitopsv1alpha1.GitOpsDeploymentSyncRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "unchanged-sync-run", Namespace: "my-namespace", UID: uuid.NewUUID()},
+	}
+
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(unchangedGitOpsDepl, driftedGitOpsDepl, unchangedSyncRun).Build()
+
+	dbQueries := &rehydrateTestQueries{
+		deplToAppMappings: []db.DeploymentToApplicationMapping{
+			{DeploymentName: "first-gitops-depl", DeploymentNamespace: "my-namespace", WorkspaceUID: "my-workspace", Application_id: "first-app"},
+			{DeploymentName: "first-gitops-depl", DeploymentNamespace: "my-namespace", WorkspaceUID: "my-workspace", Application_id: "second-app"},
+			{DeploymentName: "second-gitops-depl", DeploymentNamespace: "my-namespace", WorkspaceUID: "my-workspace", Application_id: "third-app"},
+			{Deploymenttoapplicationmapping_uid_id: string(unchangedGitOpsDepl.UID), DeploymentName: unchangedGitOpsDepl.Name,
+				DeploymentNamespace: "my-namespace", WorkspaceUID: "my-workspace", Application_id: "unchanged-app"},
+			{Deploymenttoapplicationmapping_uid_id: string(driftedGitOpsDepl.UID), DeploymentName: driftedGitOpsDepl.Name,
+				DeploymentNamespace: "my-namespace", WorkspaceUID: "my-workspace", Application_id: "drifted-app"},
+		},
+		apiCRToDBMappings: []db.APICRToDatabaseMapping{
+			{APIResourceType: db.APICRToDatabaseMapping_ResourceType_GitOpsDeploymentSyncRun, APIResourceName: "my-sync-run",
+				APIResourceNamespace: "my-namespace", WorkspaceUID: "my-workspace"},
+			{APIResourceType: db.APICRToDatabaseMapping_ResourceType_GitOpsDeploymentSyncRun, APIResourceUID: string(unchangedSyncRun.UID),
+				APIResourceName: unchangedSyncRun.Name, APIResourceNamespace: "my-namespace", WorkspaceUID: "my-workspace"},
+			{APIResourceType: "SomeOtherResource", APIResourceName: "other", APIResourceNamespace: "my-namespace", WorkspaceUID: "my-workspace"},
+		},
+		applications: []db.Application{
+			{Application_id: "unchanged-app", Name: "unchanged-app", Engine_instance_inst_id: "my-engine-instance", Spec_field: unchangedSpecField},
+			{Application_id: "drifted-app", Name: "drifted-app", Engine_instance_inst_id: "my-engine-instance", Spec_field: driftedSpecField},
+		},
+	}
+
+	evl := NewPreprocessEventLoop(k8sClient, dbQueries, nil, 0)
+
+	assert.Error(t, evl.ReadyzCheck(nil), "the event loops have not started")
+
+	received := make(chan []eventLoopEvent)
+	go func() {
+		events := []eventLoopEvent{}
+		for i := 0; i < 4; i++ {
+			events = append(events, <-evl.eventLoopInputChannel)
+		}
+		received <- events
+	}()
+
+	err = evl.rehydrate(context.Background(), log.FromContext(context.Background()))
+	assert.NoError(t, err)
+
+	// Only the missing and drifted CRs have events
+	events := <-received
+	assert.Equal(t, DeploymentModified, events[0].eventType)
+	assert.Equal(t, "first-gitops-depl", events[0].request.Name)
+	assert.Equal(t, "my-workspace", events[0].workspaceID)
+	assert.Equal(t, DeploymentModified, events[1].eventType)
+	assert.Equal(t, "second-gitops-depl", events[1].request.Name)
+	assert.Equal(t, DeploymentModified, events[2].eventType)
+	assert.Equal(t, "drifted-gitops-depl", events[2].request.Name)
+	assert.Equal(t, SyncRunModified, events[3].eventType)
+	assert.Equal(t, managedgitopsv1alpha1.GitOpsDeploymentSyncRunTypeName, events[3].reqResource)
+	assert.Equal(t, types.NamespacedName{Namespace: "my-namespace", Name: "my-sync-run"}, events[3].request.NamespacedName)
+
+	// Once the drifted GitOpsDeployment has been processed, the sweep finds nothing
+	dbQueries.applications[1].Spec_field, err = createSpecField(newArgoCDSpecInput(driftedGitOpsDepl, "drifted-app", "argocd"))
+	assert.NoError(t, err)
+	assert.NoError(t, k8sClient.Create(context.Background(), newGitOpsDeployment("first-gitops-depl", "https://github.com/recreated")))
+
+	sweepEvents, err := evl.reconciliationSweep(context.Background(), log.FromContext(context.Background()))
+	assert.NoError(t, err)
+	assert.Len(t, sweepEvents, 4, "the rows of the recreated GitOpsDeployment reference the UID of the deleted CR, so still have events")
+	for _, event := range sweepEvents {
+		assert.NotEqual(t, "drifted-gitops-depl", event.request.Name)
+	}
+}
@@ -0,0 +1,219 @@
+package eventloop
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	db "github.com/redhat-appstudio/managed-gitops/backend-shared/config/db"
+	managedgitopsv1alpha1 "github.com/redhat-appstudio/managed-gitops/backend/apis/managed-gitops/v1alpha1"
+	apierr "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	// reconciliationReasonMissing: the CR referenced by the database row no longer exists (or was recreated)
+	reconciliationReasonMissing = "missing"
+
+	// reconciliationReasonDrifted: the CR exists, but no longer matches the database row
+	reconciliationReasonDrifted = "drifted"
+)
+
+// reconciliationSweepEvents is the number of events synthesized by the reconciliation sweep.
+var reconciliationSweepEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "gitops_reconciliation_sweep_events_total",
+	Help: "Number of events synthesized by the reconciliation sweep, for CRs that are missing or have drifted from the database",
+}, []string{"resource", "reason"})
+
+func init() {
+	metrics.Registry.MustRegister(reconciliationSweepEvents)
+}
+
+// reconciliationSweep walks the DeploymentToApplicationMapping and APICRToDatabaseMapping rows, and returns an event for
+// each GitOpsDeployment and GitOpsDeploymentSyncRun whose CR no longer exists, or has drifted from the database.
+//
+// The controllers only send events for the CRs that exist: a CR that was deleted (or modified, if the event was lost)
+// while the backend was down would otherwise never be processed, leaking its database rows and Argo CD Application.
+//
+// A GitOpsDeployment has drifted if the Application row no longer matches its spec. The spec of a
+// GitOpsDeploymentSyncRun cannot be changed, so only missing GitOpsDeploymentSyncRuns are detected.
+func (evl *PreprocessEventLoop) reconciliationSweep(ctx context.Context, log logr.Logger) ([]eventLoopEvent, error) {
+
+	deplEvents, err := evl.sweepDeploymentToApplicationMappings(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	syncRunEvents, err := evl.sweepAPICRToDatabaseMappings(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	events := append(deplEvents, syncRunEvents...)
+
+	if len(events) > 0 {
+		log.Info("Reconciliation sweep found CRs that are missing, or have drifted from the database", "events", len(events))
+	}
+
+	return events, nil
+}
+
+func (evl *PreprocessEventLoop) sweepDeploymentToApplicationMappings(ctx context.Context) ([]eventLoopEvent, error) {
+
+	var deplToAppMappings []db.DeploymentToApplicationMapping
+	if err := evl.dbQueries.ListDeploymentToApplicationMappings(ctx, &deplToAppMappings); err != nil {
+		return nil, fmt.Errorf("unable to list DeploymentToApplicationMappings: %v", err)
+	}
+
+	// The Applications (and the namespaces of their GitOps engine instances) are retrieved on first use, as there is
+	// nothing to compare them to if every GitOpsDeployment is missing.
+	var applications map[string]db.Application
+	var engineInstanceNamespaces map[string]string
+
+	events := []eventLoopEvent{}
+
+	for idx := range deplToAppMappings {
+		deplToAppMapping := deplToAppMappings[idx]
+
+		if !evl.OwnsWorkspace(deplToAppMapping.WorkspaceUID) {
+			continue
+		}
+
+		reason := ""
+
+		gitopsDepl := &managedgitopsv1alpha1.GitOpsDeployment{}
+		err := evl.k8sClient.Get(ctx, types.NamespacedName{Namespace: deplToAppMapping.DeploymentNamespace, Name: deplToAppMapping.DeploymentName}, gitopsDepl)
+
+		if apierr.IsNotFound(err) || (err == nil && string(gitopsDepl.UID) != deplToAppMapping.Deploymenttoapplicationmapping_uid_id) {
+			reason = reconciliationReasonMissing
+
+		} else if err != nil {
+			return nil, fmt.Errorf("unable to retrieve GitOpsDeployment '%s/%s': %v", deplToAppMapping.DeploymentNamespace, deplToAppMapping.DeploymentName, err)
+
+		} else {
+
+			if applications == nil {
+				if applications, engineInstanceNamespaces, err = evl.listApplications(ctx); err != nil {
+					return nil, err
+				}
+			}
+
+			drifted, err := isGitOpsDeploymentDrifted(gitopsDepl, deplToAppMapping, applications, engineInstanceNamespaces)
+			if err != nil {
+				return nil, err
+			}
+			if drifted {
+				reason = reconciliationReasonDrifted
+			}
+		}
+
+		if reason == "" {
+			continue
+		}
+
+		reconciliationSweepEvents.WithLabelValues(string(managedgitopsv1alpha1.GitOpsDeploymentTypeName), reason).Inc()
+
+		events = append(events, eventLoopEvent{
+			eventType:   DeploymentModified,
+			request:     ctrl.Request{NamespacedName: types.NamespacedName{Namespace: deplToAppMapping.DeploymentNamespace, Name: deplToAppMapping.DeploymentName}},
+			reqResource: managedgitopsv1alpha1.GitOpsDeploymentTypeName,
+			workspaceID: deplToAppMapping.WorkspaceUID,
+		})
+	}
+
+	return events, nil
+}
+
+func (evl *PreprocessEventLoop) sweepAPICRToDatabaseMappings(ctx context.Context) ([]eventLoopEvent, error) {
+
+	var apiCRToDBMappings []db.APICRToDatabaseMapping
+	if err := evl.dbQueries.ListAPICRToDatabaseMappings(ctx, &apiCRToDBMappings); err != nil {
+		return nil, fmt.Errorf("unable to list APICRToDatabaseMappings: %v", err)
+	}
+
+	events := []eventLoopEvent{}
+
+	for idx := range apiCRToDBMappings {
+		apiCRToDBMapping := apiCRToDBMappings[idx]
+
+		if apiCRToDBMapping.APIResourceType != db.APICRToDatabaseMapping_ResourceType_GitOpsDeploymentSyncRun ||
+			!evl.OwnsWorkspace(apiCRToDBMapping.WorkspaceUID) {
+			continue
+		}
+
+		syncRun := &managedgitopsv1alpha1.GitOpsDeploymentSyncRun{}
+		err := evl.k8sClient.Get(ctx, types.NamespacedName{Namespace: apiCRToDBMapping.APIResourceNamespace, Name: apiCRToDBMapping.APIResourceName}, syncRun)
+
+		if err != nil && !apierr.IsNotFound(err) {
+			return nil, fmt.Errorf("unable to retrieve GitOpsDeploymentSyncRun '%s/%s': %v", apiCRToDBMapping.APIResourceNamespace, apiCRToDBMapping.APIResourceName, err)
+		}
+
+		if err == nil && string(syncRun.UID) == apiCRToDBMapping.APIResourceUID {
+			continue
+		}
+
+		reconciliationSweepEvents.WithLabelValues(string(managedgitopsv1alpha1.GitOpsDeploymentSyncRunTypeName), reconciliationReasonMissing).Inc()
+
+		events = append(events, eventLoopEvent{
+			eventType:   SyncRunModified,
+			request:     ctrl.Request{NamespacedName: types.NamespacedName{Namespace: apiCRToDBMapping.APIResourceNamespace, Name: apiCRToDBMapping.APIResourceName}},
+			reqResource: managedgitopsv1alpha1.GitOpsDeploymentSyncRunTypeName,
+			workspaceID: apiCRToDBMapping.WorkspaceUID,
+		})
+	}
+
+	return events, nil
+}
+
+// listApplications returns the Applications by ID, and the namespaces of the GitOps engine instances by ID.
+func (evl *PreprocessEventLoop) listApplications(ctx context.Context) (map[string]db.Application, map[string]string, error) {
+
+	var gitopsEngineInstances []db.GitopsEngineInstance
+	if err := evl.dbQueries.ListGitopsEngineInstances(ctx, &gitopsEngineInstances); err != nil {
+		return nil, nil, fmt.Errorf("unable to list GitopsEngineInstances: %v", err)
+	}
+
+	applications := map[string]db.Application{}
+	engineInstanceNamespaces := map[string]string{}
+
+	for _, gitopsEngineInstance := range gitopsEngineInstances {
+		engineInstanceNamespaces[gitopsEngineInstance.Gitopsengineinstance_id] = gitopsEngineInstance.Namespace_name
+
+		var instanceApplications []db.Application
+		if err := evl.dbQueries.ListApplicationsForGitopsEngineInstance(ctx, gitopsEngineInstance.Gitopsengineinstance_id, &instanceApplications); err != nil {
+			return nil, nil, fmt.Errorf("unable to list Applications of GitopsEngineInstance '%s': %v", gitopsEngineInstance.Gitopsengineinstance_id, err)
+		}
+
+		for _, application := range instanceApplications {
+			applications[application.Application_id] = application
+		}
+	}
+
+	return applications, engineInstanceNamespaces, nil
+}
+
+// isGitOpsDeploymentDrifted returns true if the Application row of the GitOpsDeployment no longer matches its spec, or
+// no longer exists. See 'handleUpdatedGitOpsDeplEvent', which updates the Application row to match.
+func isGitOpsDeploymentDrifted(gitopsDepl *managedgitopsv1alpha1.GitOpsDeployment, deplToAppMapping db.DeploymentToApplicationMapping,
+	applications map[string]db.Application, engineInstanceNamespaces map[string]string) (bool, error) {
+
+	application, exists := applications[deplToAppMapping.Application_id]
+	if !exists {
+		return true, nil
+	}
+
+	engineInstanceNamespace, exists := engineInstanceNamespaces[application.Engine_instance_inst_id]
+	if !exists {
+		return true, nil
+	}
+
+	specField, err := createSpecField(newArgoCDSpecInput(gitopsDepl, application.Name, engineInstanceNamespace))
+	if err != nil {
+		return false, err
+	}
+
+	return specField != application.Spec_field, nil
+}
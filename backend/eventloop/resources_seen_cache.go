@@ -0,0 +1,233 @@
+package eventloop
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	db "github.com/redhat-appstudio/managed-gitops/backend-shared/config/db"
+	managedgitopsv1alpha1 "github.com/redhat-appstudio/managed-gitops/backend/apis/managed-gitops/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	// resourcesSeenCacheMaxEntries is the maximum number of entries of the resourcesSeenCache: once it is reached, the
+	// least recently used entry is evicted.
+	resourcesSeenCacheMaxEntries = 10000
+
+	// resourcesSeenCacheTTL is how long an entry of the resourcesSeenCache is used, after it was last set: an expired
+	// entry is a cache miss, so the UID is then retrieved from the database.
+	resourcesSeenCacheTTL = 1 * time.Hour
+)
+
+// Results of a resourcesSeenCache lookup
+const (
+	resourcesSeenCacheResult_Hit  = "hit"
+	resourcesSeenCacheResult_Miss = "miss"
+)
+
+// Reasons for a resourcesSeenCache eviction
+const (
+	resourcesSeenCacheEviction_Capacity = "capacity"
+	resourcesSeenCacheEviction_Expired  = "expired"
+)
+
+var (
+	// resourcesSeenCacheLookups is the number of lookups of the resourcesSeenCache, by result: the hit ratio is the
+	// number of hits over the total number of lookups.
+	resourcesSeenCacheLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gitops_preprocess_cache_lookups_total",
+		Help: "Number of lookups of the preprocess event loop cache of resources seen, by result (hit or miss)",
+	}, []string{"result"})
+
+	// resourcesSeenCacheEvictions is the number of entries evicted from the resourcesSeenCache, by reason.
+	resourcesSeenCacheEvictions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gitops_preprocess_cache_evictions_total",
+		Help: "Number of entries evicted from the preprocess event loop cache of resources seen, by reason (capacity or expired)",
+	}, []string{"reason"})
+
+	// resourcesSeenCacheEntries is the number of entries of the resourcesSeenCache.
+	resourcesSeenCacheEntries = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "gitops_preprocess_cache_entries",
+		Help: "Number of entries of the preprocess event loop cache of resources seen",
+	})
+)
+
+func init() {
+	metrics.Registry.MustRegister(resourcesSeenCacheLookups, resourcesSeenCacheEvictions, resourcesSeenCacheEntries)
+}
+
+// resourcesSeenCache maps the key of a GitOpsDeployment or GitOpsDeploymentSyncRun (see 'resourcesSeenCacheKey') to
+// the UID of the CR, when it was last seen. It is used by the preprocess event loop to determine the UID of a CR that
+// has been deleted, without a database lookup.
+//
+// The cache is bounded: entries are evicted once they are older than resourcesSeenCacheTTL, or when the cache is full
+// (least recently used first). An evicted entry is retrieved from the database on the next lookup, as on a cache miss.
+type resourcesSeenCache struct {
+	maxEntries int
+	ttl        time.Duration
+
+	// now returns the current time, and may be replaced by tests
+	now func() time.Time
+
+	// mutex should be acquired whenever 'entries' or 'lru' are read/modified
+	mutex sync.Mutex
+
+	// entries contains the list element of each key: the front of 'lru' is the most recently used entry
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+type resourcesSeenCacheEntry struct {
+	key     string
+	uid     string
+	expires time.Time
+}
+
+func newResourcesSeenCache(maxEntries int, ttl time.Duration) *resourcesSeenCache {
+	return &resourcesSeenCache{
+		maxEntries: maxEntries,
+		ttl:        ttl,
+		now:        time.Now,
+		entries:    map[string]*list.Element{},
+		lru:        list.New(),
+	}
+}
+
+// resourcesSeenCacheKey returns the cache key of a GitOpsDeployment or GitOpsDeploymentSyncRun.
+func resourcesSeenCacheKey(resourceType managedgitopsv1alpha1.GitOpsResourceType, name string, namespace string, workspaceID string) string {
+	// TODO: GITOPS-1702 - PERF - Use a more memory efficient key
+	return string(resourceType) + "-" + name + "-" + namespace + "-" + workspaceID
+}
+
+// get returns the UID of the resource, if it is in the cache and has not expired.
+func (cache *resourcesSeenCache) get(key string) (string, bool) {
+
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	element, exists := cache.entries[key]
+	if exists && cache.now().After(element.Value.(*resourcesSeenCacheEntry).expires) {
+		cache.removeElement(element)
+		resourcesSeenCacheEvictions.WithLabelValues(resourcesSeenCacheEviction_Expired).Inc()
+		exists = false
+	}
+
+	if !exists {
+		resourcesSeenCacheLookups.WithLabelValues(resourcesSeenCacheResult_Miss).Inc()
+		return "", false
+	}
+
+	resourcesSeenCacheLookups.WithLabelValues(resourcesSeenCacheResult_Hit).Inc()
+	cache.lru.MoveToFront(element)
+
+	return element.Value.(*resourcesSeenCacheEntry).uid, true
+}
+
+// set sets the UID of the resource, evicting the least recently used entry if the cache is full.
+func (cache *resourcesSeenCache) set(key string, uid string) {
+
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	cache.setLocked(key, uid)
+}
+
+// setIfAbsent sets the UID of the resource, unless the cache already contains the resource, and returns true if it was
+// set: see 'warm'.
+func (cache *resourcesSeenCache) setIfAbsent(key string, uid string) bool {
+
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	if _, exists := cache.entries[key]; exists {
+		return false
+	}
+
+	cache.setLocked(key, uid)
+	return true
+}
+
+func (cache *resourcesSeenCache) setLocked(key string, uid string) {
+
+	expires := cache.now().Add(cache.ttl)
+
+	if element, exists := cache.entries[key]; exists {
+		entry := element.Value.(*resourcesSeenCacheEntry)
+		entry.uid = uid
+		entry.expires = expires
+		cache.lru.MoveToFront(element)
+		return
+	}
+
+	cache.entries[key] = cache.lru.PushFront(&resourcesSeenCacheEntry{key: key, uid: uid, expires: expires})
+
+	for cache.lru.Len() > cache.maxEntries {
+		cache.removeElement(cache.lru.Back())
+		resourcesSeenCacheEvictions.WithLabelValues(resourcesSeenCacheEviction_Capacity).Inc()
+	}
+
+	resourcesSeenCacheEntries.Set(float64(cache.lru.Len()))
+}
+
+// delete removes the resource from the cache: it should be called once the resource has been deleted.
+func (cache *resourcesSeenCache) delete(key string) {
+
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	if element, exists := cache.entries[key]; exists {
+		cache.removeElement(element)
+	}
+}
+
+func (cache *resourcesSeenCache) removeElement(element *list.Element) {
+	cache.lru.Remove(element)
+	delete(cache.entries, element.Value.(*resourcesSeenCacheEntry).key)
+	resourcesSeenCacheEntries.Set(float64(cache.lru.Len()))
+}
+
+// warm adds the GitOpsDeployments and GitOpsDeploymentSyncRuns that are referenced by the database to the cache, so
+// that events for CRs that were seen before a restart do not require a database lookup. Only the resources of the
+// workspaces for which 'ownsWorkspace' returns true are added, and the resources that are already in the cache are
+// left unchanged, as they were seen more recently. The number of resources added is returned.
+func (cache *resourcesSeenCache) warm(ctx context.Context, dbQueries db.DatabaseQueries, ownsWorkspace func(workspaceID string) bool) (int, error) {
+
+	added := 0
+
+	var deplToAppMappings []db.DeploymentToApplicationMapping
+	if err := dbQueries.ListDeploymentToApplicationMappings(ctx, &deplToAppMappings); err != nil {
+		return added, fmt.Errorf("unable to list DeploymentToApplicationMappings: %v", err)
+	}
+
+	for _, deplToAppMapping := range deplToAppMappings {
+		if deplToAppMapping.Deploymenttoapplicationmapping_uid_id == "" || !ownsWorkspace(deplToAppMapping.WorkspaceUID) {
+			continue
+		}
+		if cache.setIfAbsent(resourcesSeenCacheKey(managedgitopsv1alpha1.GitOpsDeploymentTypeName, deplToAppMapping.DeploymentName,
+			deplToAppMapping.DeploymentNamespace, deplToAppMapping.WorkspaceUID), deplToAppMapping.Deploymenttoapplicationmapping_uid_id) {
+			added++
+		}
+	}
+
+	var apiCRToDBMappings []db.APICRToDatabaseMapping
+	if err := dbQueries.ListAPICRToDatabaseMappings(ctx, &apiCRToDBMappings); err != nil {
+		return added, fmt.Errorf("unable to list APICRToDatabaseMappings: %v", err)
+	}
+
+	for _, apiCRToDBMapping := range apiCRToDBMappings {
+		if apiCRToDBMapping.APIResourceType != db.APICRToDatabaseMapping_ResourceType_GitOpsDeploymentSyncRun ||
+			apiCRToDBMapping.APIResourceUID == "" || !ownsWorkspace(apiCRToDBMapping.WorkspaceUID) {
+			continue
+		}
+		if cache.setIfAbsent(resourcesSeenCacheKey(managedgitopsv1alpha1.GitOpsDeploymentSyncRunTypeName, apiCRToDBMapping.APIResourceName,
+			apiCRToDBMapping.APIResourceNamespace, apiCRToDBMapping.WorkspaceUID), apiCRToDBMapping.APIResourceUID) {
+			added++
+		}
+	}
+
+	return added, nil
+}
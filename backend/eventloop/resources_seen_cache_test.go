@@ -0,0 +1,125 @@
+package eventloop
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	db "github.com/redhat-appstudio/managed-gitops/backend-shared/config/db"
+	managedgitopsv1alpha1 "github.com/redhat-appstudio/managed-gitops/backend/apis/managed-gitops/v1alpha1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResourcesSeenCache(t *testing.T) {
+
+	now := time.Now()
+	newTestCache := func(maxEntries int) *resourcesSeenCache {
+		cache := newResourcesSeenCache(maxEntries, time.Hour)
+		cache.now = func() time.Time { return now }
+		return cache
+	}
+
+	t.Run("The least recently used entry is evicted once the cache is full", func(t *testing.T) {
+
+		cache := newTestCache(2)
+		evictionsBefore := testutil.ToFloat64(resourcesSeenCacheEvictions.WithLabelValues(resourcesSeenCacheEviction_Capacity))
+
+		cache.set("first", "first-uid")
+		cache.set("second", "second-uid")
+
+		// Using 'first' makes 'second' the least recently used entry
+		uid, exists := cache.get("first")
+		assert.True(t, exists)
+		assert.Equal(t, "first-uid", uid)
+
+		cache.set("third", "third-uid")
+
+		_, exists = cache.get("second")
+		assert.False(t, exists)
+		_, exists = cache.get("first")
+		assert.True(t, exists)
+		_, exists = cache.get("third")
+		assert.True(t, exists)
+
+		assert.Equal(t, evictionsBefore+1, testutil.ToFloat64(resourcesSeenCacheEvictions.WithLabelValues(resourcesSeenCacheEviction_Capacity)))
+	})
+
+	t.Run("Entries expire after the TTL, unless they are set again", func(t *testing.T) {
+
+		cache := newTestCache(10)
+
+		cache.set("expired", "expired-uid")
+		cache.set("refreshed", "old-uid")
+
+		now = now.Add(30 * time.Minute)
+		cache.set("refreshed", "new-uid")
+
+		now = now.Add(31 * time.Minute)
+
+		_, exists := cache.get("expired")
+		assert.False(t, exists)
+
+		uid, exists := cache.get("refreshed")
+		assert.True(t, exists)
+		assert.Equal(t, "new-uid", uid)
+	})
+
+	t.Run("Deleted entries are misses", func(t *testing.T) {
+
+		cache := newTestCache(10)
+		hitsBefore := testutil.ToFloat64(resourcesSeenCacheLookups.WithLabelValues(resourcesSeenCacheResult_Hit))
+		missesBefore := testutil.ToFloat64(resourcesSeenCacheLookups.WithLabelValues(resourcesSeenCacheResult_Miss))
+
+		cache.set("deleted", "deleted-uid")
+		_, exists := cache.get("deleted")
+		assert.True(t, exists)
+
+		cache.delete("deleted")
+		_, exists = cache.get("deleted")
+		assert.False(t, exists)
+
+		assert.Equal(t, hitsBefore+1, testutil.ToFloat64(resourcesSeenCacheLookups.WithLabelValues(resourcesSeenCacheResult_Hit)))
+		assert.Equal(t, missesBefore+1, testutil.ToFloat64(resourcesSeenCacheLookups.WithLabelValues(resourcesSeenCacheResult_Miss)))
+	})
+
+	t.Run("The cache is warmed from the database, for the owned workspaces", func(t *testing.T) {
+
+		cache := newTestCache(10)
+
+		dbQueries := &rehydrateTestQueries{
+			deplToAppMappings: []db.DeploymentToApplicationMapping{
+				{Deploymenttoapplicationmapping_uid_id: "gitops-depl-uid", DeploymentName: "my-gitops-depl", DeploymentNamespace: "my-namespace", WorkspaceUID: "my-workspace"},
+				{Deploymenttoapplicationmapping_uid_id: "seen-gitops-depl-uid", DeploymentName: "seen-gitops-depl", DeploymentNamespace: "my-namespace", WorkspaceUID: "my-workspace"},
+				{Deploymenttoapplicationmapping_uid_id: "other-gitops-depl-uid", DeploymentName: "other-gitops-depl", DeploymentNamespace: "other-namespace", WorkspaceUID: "other-workspace"},
+			},
+			apiCRToDBMappings: []db.APICRToDatabaseMapping{
+				{APIResourceType: db.APICRToDatabaseMapping_ResourceType_GitOpsDeploymentSyncRun, APIResourceUID: "sync-run-uid",
+					APIResourceName: "my-sync-run", APIResourceNamespace: "my-namespace", WorkspaceUID: "my-workspace"},
+			},
+		}
+
+		// A resource that was seen more recently than the database row is left unchanged
+		seenKey := resourcesSeenCacheKey(managedgitopsv1alpha1.GitOpsDeploymentTypeName, "seen-gitops-depl", "my-namespace", "my-workspace")
+		cache.set(seenKey, "recreated-gitops-depl-uid")
+
+		added, err := cache.warm(context.Background(), dbQueries, func(workspaceID string) bool { return workspaceID == "my-workspace" })
+		assert.NoError(t, err)
+		assert.Equal(t, 2, added)
+
+		uid, exists := cache.get(resourcesSeenCacheKey(managedgitopsv1alpha1.GitOpsDeploymentTypeName, "my-gitops-depl", "my-namespace", "my-workspace"))
+		assert.True(t, exists)
+		assert.Equal(t, "gitops-depl-uid", uid)
+
+		uid, exists = cache.get(resourcesSeenCacheKey(managedgitopsv1alpha1.GitOpsDeploymentSyncRunTypeName, "my-sync-run", "my-namespace", "my-workspace"))
+		assert.True(t, exists)
+		assert.Equal(t, "sync-run-uid", uid)
+
+		uid, exists = cache.get(seenKey)
+		assert.True(t, exists)
+		assert.Equal(t, "recreated-gitops-depl-uid", uid)
+
+		_, exists = cache.get(resourcesSeenCacheKey(managedgitopsv1alpha1.GitOpsDeploymentTypeName, "other-gitops-depl", "other-namespace", "other-workspace"))
+		assert.False(t, exists)
+	})
+}
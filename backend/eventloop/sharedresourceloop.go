@@ -138,6 +138,13 @@ func newSharedResourceLoop() *sharedResourceEventLoop {
 	return sharedResourceEventLoop
 }
 
+// stop stops the shared resource event loop goroutine, and closes its database connection. It must only be called
+// once no more messages will be sent to the loop: for example, once all the application event loops of the workspace
+// have terminated.
+func (srEventLoop *sharedResourceEventLoop) stop() {
+	close(srEventLoop.inputChannel)
+}
+
 type sharedResourceLoopMessageType string
 
 const (
@@ -188,9 +195,14 @@ func internalSharedResourceEventLoop(inputChan chan sharedResourceLoopMessage) {
 		log.Error(err, "SEVERE: internalSharedResourceEventLoop exiting before startup")
 		return
 	}
+	defer dbQueries.CloseDatabase()
 
 	for {
-		msg := <-inputChan
+		msg, ok := <-inputChan
+		if !ok {
+			log.V(sharedutil.LogLevel_Debug).Info("internalSharedResourceEventLoop stopped")
+			return
+		}
 
 		_, err = sharedutil.CatchPanic(func() error {
 			processSharedResourceMessage(ctx, msg, dbQueries, log)
@@ -305,7 +317,7 @@ func internalProcessMessage_GetOrCreateSharedResources(ctx context.Context, work
 
 	clusterUser, err := internalGetOrCreateClusterUserByNamespaceUID(ctx, string(workspaceNamespace.UID), dbQueries)
 	if err != nil {
-		return nil, nil, nil, nil, fmt.Errorf("unable to retrieve cluster user in processMessage, '%s': %v", string(workspaceNamespace.UID), err)
+		return nil, nil, nil, nil, fmt.Errorf("unable to retrieve cluster user in processMessage, '%s': %w", string(workspaceNamespace.UID), err)
 	}
 
 	managedEnv, err := dbutil.GetOrCreateManagedEnvironmentByNamespaceUID(ctx, workspaceNamespace, dbQueries, log)
@@ -360,7 +372,7 @@ func internalDetermineGitOpsEngineInstanceForNewApplication(ctx context.Context,
 
 	gitopsEngineInstance, _, err := dbutil.GetOrCreateGitopsEngineInstanceByInstanceNamespaceUID(ctx, *namespace, string(kubeSystemNamespace.UID), dbq, log)
 	if err != nil {
-		return nil, fmt.Errorf("unable to get or create engine instance for new application: %v", err)
+		return nil, fmt.Errorf("unable to get or create engine instance for new application: %w", err)
 	}
 
 	// When we support multiple Argo CD instance, the algorithm would be:
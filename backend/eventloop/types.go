@@ -37,6 +37,26 @@ type eventLoopEvent struct {
 
 	// workspaceID is the UID of the namespace that contains the request
 	workspaceID string
+
+	// superseded is closed by the application event loop when a newer event supersedes this event, while this event is
+	// being processed by a runner: if this event is failing, the runner stops retrying it. See supersedesEvent.
+	superseded chan struct{}
+
+	// deadLetterRequeues is the number of times the event has been requeued, after it was dead-lettered: see
+	// 'startDeadLetterRequeueTimer'.
+	deadLetterRequeues int
+}
+
+// markSuperseded informs the runner that is processing the event that it has been superseded. This should only be
+// called by the application event loop that sent the event to the runner.
+func (event *eventLoopEvent) markSuperseded() {
+	if event.superseded != nil && !isChannelClosed(event.superseded) {
+		close(event.superseded)
+	}
+}
+
+func (event *eventLoopEvent) isSuperseded() bool {
+	return event.superseded != nil && isChannelClosed(event.superseded)
 }
 
 type applicationEventLoopMessageType int
@@ -52,4 +72,8 @@ type applicationEventLoopMessage struct {
 
 	// shutdownSignalled is included as part of workComplete message, to indicate that the goroutine has succesfully shut down.
 	shutdownSignalled bool
+
+	// deadLettered is included as part of workComplete message, to indicate that the event could not be processed,
+	// and should be requeued: see 'startDeadLetterRequeueTimer'.
+	deadLettered bool
 }
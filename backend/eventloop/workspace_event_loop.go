@@ -13,16 +13,67 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/log"
 )
 
+var (
+	// workspaceEventLoopIdleTimeout is the amount of time after which a workspace event loop is stopped, if it has not
+	// received any events, and has no running application event loops (and no orphaned resources): it is started again
+	// on the next event for the workspace.
+	workspaceEventLoopIdleTimeout = 10 * time.Minute
+)
+
+// applicationEventLoop is the entry of the workspace event loop for the goroutine handling a single GitOpsDeployment.
 type applicationEventLoop struct {
 	input chan applicationEventLoopMessage
+
+	// terminated is closed when the goroutine has ended: events must then be sent to a new goroutine.
+	terminated chan struct{}
+}
+
+// workspaceEventLoop is the entry of the controller event loop for the goroutine handling a single workspace.
+type workspaceEventLoop struct {
+	input chan applicationEventLoopMessage
+
+	// terminated is closed when the goroutine has ended (for example, because it was idle): events must then be sent
+	// to a new goroutine.
+	terminated chan struct{}
+}
+
+// isTerminated returns true if the goroutine of the application event loop has ended.
+func (loop applicationEventLoop) isTerminated() bool {
+	return isChannelClosed(loop.terminated)
+}
+
+// isTerminated returns true if the goroutine of the workspace event loop has ended.
+func (loop workspaceEventLoop) isTerminated() bool {
+	return isChannelClosed(loop.terminated)
+}
+
+func isChannelClosed(channel chan struct{}) bool {
+	select {
+	case <-channel:
+		return true
+	default:
+		return false
+	}
 }
 
 // TODO: GITOPS-1678 - DEBT - Set log to info, and make sure you can still figure out what's going on.
 
-func startWorkspaceEventLoopRouter(input chan applicationEventLoopMessage, workspaceID string) {
+// startWorkspaceEventLoopRouter starts the goroutine that handles all the events of a workspace, using
+// 'startApplicationEventLoop' to start the goroutines for the individual GitOpsDeployments of the workspace.
+func startWorkspaceEventLoopRouter(workspaceID string, startApplicationEventLoop applicationEventLoopStarter) workspaceEventLoop {
+
+	res := workspaceEventLoop{
+		input:      make(chan applicationEventLoopMessage),
+		terminated: make(chan struct{}),
+	}
 
 	go func() {
 
+		defer close(res.terminated)
+
+		eventLoopGoroutines.WithLabelValues(eventLoopGoroutine_Workspace).Inc()
+		defer eventLoopGoroutines.WithLabelValues(eventLoopGoroutine_Workspace).Dec()
+
 		log := log.FromContext(context.Background())
 
 		backoff := sharedutil.ExponentialBackoff{Min: time.Duration(500 * time.Millisecond), Max: time.Duration(15 * time.Second), Factor: 2, Jitter: true}
@@ -31,10 +82,15 @@ func startWorkspaceEventLoopRouter(input chan applicationEventLoopMessage, works
 
 		for {
 			isPanic, _ := sharedutil.CatchPanic(func() error {
-				workspaceEventLoopRouter(input, workspaceID)
+				workspaceEventLoopRouter(res.input, workspaceID, startApplicationEventLoop)
 				return nil
 			})
 
+			// The router returns when the workspace is idle: the controller event loop will start a new one when needed.
+			if !isPanic {
+				return
+			}
+
 			// This really shouldn't happen, so we log it as severe.
 			log.Error(nil, "SEVERE: the applicationEventLoopRouter function exited unexpectedly.", "isPanic", isPanic)
 
@@ -56,8 +112,12 @@ func startWorkspaceEventLoopRouter(input chan applicationEventLoopMessage, works
 		}
 	}()
 
+	return res
 }
 
+// applicationEventLoopStarter starts the goroutine for a GitOpsDeployment: see 'startApplicationEventQueueLoop'.
+type applicationEventLoopStarter func(gitopsDeplID string, workspaceID string, sharedResourceEventLoop *sharedResourceEventLoop) applicationEventLoop
+
 const (
 	// orphanedResourceGitopsDeplUID indicates that a GitOpsDeploymentSyncRunCR is orphaned, which means
 	// we do not know which GitOpsDeployment it should belong to. This is usually because the deployment name
@@ -67,7 +127,10 @@ const (
 
 // workspaceEventLoopRouter receives all events for the workspace, and passes them to specific goroutine responsible
 // for handling events for individual applications.
-func workspaceEventLoopRouter(input chan applicationEventLoopMessage, workspaceID string) {
+//
+// The function returns once the workspace is idle (see 'workspaceEventLoopIdleTimeout'): the caller must then no
+// longer send events to 'input'.
+func workspaceEventLoopRouter(input chan applicationEventLoopMessage, workspaceID string, startApplicationEventLoop applicationEventLoopStarter) {
 
 	ctx := context.Background()
 
@@ -81,10 +144,57 @@ func workspaceEventLoopRouter(input chan applicationEventLoopMessage, workspaceI
 	// orphanedResources: gitops depl name -> (name field of CR -> event depending on it)
 	orphanedResources := map[string]map[string]eventLoopEvent{}
 
+	// The orphaned events are lost if the router panics, so they are no longer reported as waiting
+	defer func() {
+		for _, gitopsDeplMap := range orphanedResources {
+			workspaceEventLoopOrphanedEvents.Sub(float64(len(gitopsDeplMap)))
+		}
+	}()
+
 	// applicationMap: gitopsDepl UID -> channel for go routine responsible for handling it
 	applicationMap := map[string]applicationEventLoop{}
+
+	// requeuedEvents are orphaned events whose parent gitopsdepl now exists: they are processed before any new events.
+	requeuedEvents := []applicationEventLoopMessage{}
+
+	idleTicker := time.NewTicker(workspaceEventLoopIdleTimeout / 4)
+	defer idleTicker.Stop()
+
+	lastEvent := time.Now()
+
 	for {
-		event := <-input
+		var event applicationEventLoopMessage
+
+		if len(requeuedEvents) > 0 {
+			event = requeuedEvents[0]
+			requeuedEvents = requeuedEvents[1:]
+
+		} else {
+
+			select {
+			case event = <-input:
+				lastEvent = time.Now()
+				if event.event != nil {
+					reportEventReceived(eventLoopStage_Workspace, event.event)
+				}
+
+			case <-idleTicker.C:
+				// Remove the application event loops that have terminated
+				for gitopsDeplUID, applicationEntryVal := range applicationMap {
+					if applicationEntryVal.isTerminated() {
+						delete(applicationMap, gitopsDeplUID)
+					}
+				}
+
+				if len(applicationMap) == 0 && len(orphanedResources) == 0 && time.Since(lastEvent) >= workspaceEventLoopIdleTimeout {
+					// No application event loops are running, so no more messages will be sent to the shared resource loop
+					sharedResourceEventLoop.stop()
+					log.V(sharedutil.LogLevel_Debug).Info("workspaceEventLoopRouter is idle, and will be stopped")
+					return
+				}
+				continue
+			}
+		}
 
 		// First, sanity check the event
 
@@ -135,6 +245,9 @@ func workspaceEventLoopRouter(input chan applicationEventLoopMessage, workspaceI
 				}
 
 				log.V(sharedutil.LogLevel_Debug).Info("Adding syncrun CR to orphaned resources list, name: " + syncRunCR.Name + ", missing gitopsdepl name: " + syncRunCR.Spec.GitopsDeploymentName)
+				if _, exists := gitopsDeplMap[syncRunCR.Name]; !exists {
+					workspaceEventLoopOrphanedEvents.Inc()
+				}
 				gitopsDeplMap[syncRunCR.Name] = *event.event
 			} else {
 				log.Error(nil, "SEVERE: unexpected event resource type in applicationEventLoopRouter")
@@ -178,34 +291,38 @@ func workspaceEventLoopRouter(input chan applicationEventLoopMessage, workspaceI
 						log.V(sharedutil.LogLevel_Debug).Info("found parent: " + gitopsDeplCR.Name + " (" + string(gitopsDeplCR.UID) + "), of orphaned resource: " + orphanedResourceEvent.request.Name)
 					}
 					delete(orphanedResources, event.event.request.Name)
+					workspaceEventLoopOrphanedEvents.Sub(float64(len(gitopsDeplMap)))
 
-					// Requeue the orphaned events from a separate goroutine (to prevent us from blocking this goroutine).
-					// The orphaned events will be reprocessed after the gitopsdepl is processed.
-					go func() {
-						for _, eventToRequeue := range requeueEvents {
-							log.V(sharedutil.LogLevel_Debug).Info("requeueing orphaned resource: " + eventToRequeue.event.request.Name + ", for parent: " + eventToRequeue.event.associatedGitopsDeplUID)
-							input <- eventToRequeue
-						}
-					}()
+					// Requeue the orphaned events: they will be reprocessed after the gitopsdepl is processed.
+					for _, eventToRequeue := range requeueEvents {
+						log.V(sharedutil.LogLevel_Debug).Info("requeueing orphaned resource: " + eventToRequeue.event.request.Name + ", for parent: " + eventToRequeue.event.associatedGitopsDeplUID)
+					}
+					requeuedEvents = append(requeuedEvents, requeueEvents...)
 				}
 			}
 		}
 
-		applicationEntryVal, ok := applicationMap[event.event.associatedGitopsDeplUID]
-		if !ok {
-			// Start the application event queue go-routine, if it's not already started.
-			applicationEntryVal = applicationEventLoop{
-				input: make(chan applicationEventLoopMessage),
+		for {
+			applicationEntryVal, ok := applicationMap[event.event.associatedGitopsDeplUID]
+			if !ok || applicationEntryVal.isTerminated() {
+				// Start the application event queue go-routine, if it's not already started (or if it has terminated).
+				applicationEntryVal = startApplicationEventLoop(event.event.associatedGitopsDeplUID, event.event.workspaceID, sharedResourceEventLoop)
+				applicationMap[event.event.associatedGitopsDeplUID] = applicationEntryVal
 			}
-			applicationMap[event.event.associatedGitopsDeplUID] = applicationEntryVal
 
-			go applicationEventQueueLoop(applicationEntryVal.input, event.event.associatedGitopsDeplUID, event.event.workspaceID, sharedResourceEventLoop)
-		}
+			// Send the event to the channel/go routine that handles all events for this application/gitopsdepl (non-blocking)
+			select {
+			case applicationEntryVal.input <- applicationEventLoopMessage{
+				messageType: applicationEventLoopMessageType_Event,
+				event:       event.event,
+			}:
+			case <-applicationEntryVal.terminated:
+				// The goroutine terminated before it received the event: send it to a new goroutine
+				log.V(sharedutil.LogLevel_Debug).Info("application event loop terminated before receiving event, restarting it", "event", stringEventLoopEvent(event.event))
+				continue
+			}
 
-		// Send the event to the channel/go routine that handles all events for this application/gitopsdepl (non-blocking)
-		applicationEntryVal.input <- applicationEventLoopMessage{
-			messageType: applicationEventLoopMessageType_Event,
-			event:       event.event,
+			break
 		}
 	}
 }
@@ -0,0 +1,253 @@
+package eventloop
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	managedgitopsv1alpha1 "github.com/redhat-appstudio/managed-gitops/backend/apis/managed-gitops/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const eventLoopTestTimeout = 10 * time.Second
+
+// receivedEvent is an event received by a fake event loop, and the (1-based) number of the loop that received it.
+type receivedEvent struct {
+	loopNumber int
+	event      *eventLoopEvent
+}
+
+// fakeEventLoops starts fake event loops that each receive 'eventsPerLoop' events, and then terminate. The first
+// 'loopsTerminatingEarly' loops terminate without receiving any events, as if they had terminated at the same time as
+// the event was sent to them.
+type fakeEventLoops struct {
+	mutex        sync.Mutex
+	loopsStarted int
+
+	eventsPerLoop         int
+	loopsTerminatingEarly int
+
+	received chan receivedEvent
+}
+
+func newFakeEventLoops(eventsPerLoop int, loopsTerminatingEarly int) *fakeEventLoops {
+	return &fakeEventLoops{
+		eventsPerLoop:         eventsPerLoop,
+		loopsTerminatingEarly: loopsTerminatingEarly,
+		received:              make(chan receivedEvent, 100),
+	}
+}
+
+func (loops *fakeEventLoops) start() (chan applicationEventLoopMessage, chan struct{}) {
+
+	loops.mutex.Lock()
+	loops.loopsStarted++
+	loopNumber := loops.loopsStarted
+	loops.mutex.Unlock()
+
+	input := make(chan applicationEventLoopMessage)
+	terminated := make(chan struct{})
+
+	go func() {
+		defer close(terminated)
+
+		if loopNumber <= loops.loopsTerminatingEarly {
+			return
+		}
+
+		for i := 0; i < loops.eventsPerLoop; i++ {
+			msg := <-input
+			loops.received <- receivedEvent{loopNumber: loopNumber, event: msg.event}
+		}
+	}()
+
+	return input, terminated
+}
+
+func (loops *fakeEventLoops) startApplicationEventLoop(gitopsDeplID string, workspaceID string, sharedResourceEventLoop *sharedResourceEventLoop) applicationEventLoop {
+	input, terminated := loops.start()
+	return applicationEventLoop{input: input, terminated: terminated}
+}
+
+func (loops *fakeEventLoops) startWorkspaceEventLoop(workspaceID string) workspaceEventLoop {
+	input, terminated := loops.start()
+	return workspaceEventLoop{input: input, terminated: terminated}
+}
+
+func (loops *fakeEventLoops) expectEvent(t *testing.T, expectedLoopNumber int, expectedEvent *eventLoopEvent) {
+
+	select {
+	case received := <-loops.received:
+		assert.Equal(t, expectedLoopNumber, received.loopNumber)
+		assert.Equal(t, expectedEvent.request, received.event.request)
+	case <-time.After(eventLoopTestTimeout):
+		t.Fatalf("timed out waiting for event %s", stringEventLoopEvent(expectedEvent))
+	}
+}
+
+func newTestGitOpsDeploymentEvent(name string) *eventLoopEvent {
+	return &eventLoopEvent{
+		eventType:               DeploymentModified,
+		request:                 reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "my-namespace", Name: name}},
+		reqResource:             managedgitopsv1alpha1.GitOpsDeploymentTypeName,
+		associatedGitopsDeplUID: "my-gitops-depl-uid",
+		workspaceID:             "my-workspace",
+	}
+}
+
+// ownsAllWorkspaces is the ownership check of a replica that is not in sharding mode
+func ownsAllWorkspaces(workspaceID string) bool {
+	return true
+}
+
+func sendWithTimeout(t *testing.T, input chan applicationEventLoopMessage, event *eventLoopEvent) {
+
+	select {
+	case input <- applicationEventLoopMessage{messageType: applicationEventLoopMessageType_Event, event: event}:
+	case <-time.After(eventLoopTestTimeout):
+		t.Fatalf("timed out sending event %s: the router is blocked", stringEventLoopEvent(event))
+	}
+}
+
+func TestWorkspaceEventLoopRouter(t *testing.T) {
+
+	t.Run("Events are sent to a new application event loop, after the previous loop terminated", func(t *testing.T) {
+
+		applicationLoops := newFakeEventLoops(1, 0)
+		workspaceLoop := startWorkspaceEventLoopRouter("my-workspace", applicationLoops.startApplicationEventLoop)
+
+		for i := 1; i <= 3; i++ {
+			event := newTestGitOpsDeploymentEvent("my-gitops-depl")
+			sendWithTimeout(t, workspaceLoop.input, event)
+			applicationLoops.expectEvent(t, i, event)
+		}
+	})
+
+	t.Run("Events are not lost if the application event loop terminates while the event is sent", func(t *testing.T) {
+
+		applicationLoops := newFakeEventLoops(2, 2)
+		workspaceLoop := startWorkspaceEventLoopRouter("my-workspace", applicationLoops.startApplicationEventLoop)
+
+		first := newTestGitOpsDeploymentEvent("first")
+		second := newTestGitOpsDeploymentEvent("second")
+		sendWithTimeout(t, workspaceLoop.input, first)
+		sendWithTimeout(t, workspaceLoop.input, second)
+
+		// The first two loops terminated without receiving the event, so both events are received by the third
+		applicationLoops.expectEvent(t, 3, first)
+		applicationLoops.expectEvent(t, 3, second)
+	})
+
+	t.Run("The workspace event loop is stopped once it is idle, and not before", func(t *testing.T) {
+
+		defaultIdleTimeout := workspaceEventLoopIdleTimeout
+		workspaceEventLoopIdleTimeout = 200 * time.Millisecond
+		defer func() {
+			workspaceEventLoopIdleTimeout = defaultIdleTimeout
+		}()
+
+		// An application event loop that has not terminated prevents the workspace event loop from stopping
+		runningApplicationLoops := newFakeEventLoops(2, 0)
+		runningWorkspaceLoop := startWorkspaceEventLoopRouter("my-workspace", runningApplicationLoops.startApplicationEventLoop)
+
+		event := newTestGitOpsDeploymentEvent("my-gitops-depl")
+		sendWithTimeout(t, runningWorkspaceLoop.input, event)
+		runningApplicationLoops.expectEvent(t, 1, event)
+
+		terminatedApplicationLoops := newFakeEventLoops(1, 0)
+		terminatedWorkspaceLoop := startWorkspaceEventLoopRouter("my-workspace", terminatedApplicationLoops.startApplicationEventLoop)
+
+		workspaceGoroutines := eventLoopGoroutines.WithLabelValues(eventLoopGoroutine_Workspace)
+		assert.Eventually(t, func() bool { return testutil.ToFloat64(workspaceGoroutines) >= 2 }, eventLoopTestTimeout, 10*time.Millisecond)
+		liveGoroutines := testutil.ToFloat64(workspaceGoroutines)
+
+		event = newTestGitOpsDeploymentEvent("my-gitops-depl")
+		sendWithTimeout(t, terminatedWorkspaceLoop.input, event)
+		terminatedApplicationLoops.expectEvent(t, 1, event)
+
+		select {
+		case <-terminatedWorkspaceLoop.terminated:
+		case <-time.After(eventLoopTestTimeout):
+			t.Fatalf("timed out waiting for the idle workspace event loop to stop")
+		}
+
+		assert.False(t, runningWorkspaceLoop.isTerminated())
+		assert.Equal(t, liveGoroutines-1, testutil.ToFloat64(workspaceGoroutines))
+
+		// Once the remaining application event loop terminates, the workspace event loop is stopped too
+		event = newTestGitOpsDeploymentEvent("my-gitops-depl")
+		sendWithTimeout(t, runningWorkspaceLoop.input, event)
+		runningApplicationLoops.expectEvent(t, 1, event)
+
+		select {
+		case <-runningWorkspaceLoop.terminated:
+		case <-time.After(eventLoopTestTimeout):
+			t.Fatalf("timed out waiting for the idle workspace event loop to stop")
+		}
+	})
+}
+
+func TestControllerEventLoopRouter(t *testing.T) {
+
+	sendEvent := func(t *testing.T, input chan eventLoopEvent, event *eventLoopEvent) {
+		select {
+		case input <- *event:
+		case <-time.After(eventLoopTestTimeout):
+			t.Fatalf("timed out sending event %s: the router is blocked", stringEventLoopEvent(event))
+		}
+	}
+
+	t.Run("Events are sent to a new workspace event loop, after the previous loop was stopped", func(t *testing.T) {
+
+		workspaceLoops := newFakeEventLoops(1, 0)
+
+		input := make(chan eventLoopEvent)
+		go controllerEventLoopRouter(input, workspaceLoops.startWorkspaceEventLoop, ownsAllWorkspaces)
+
+		eventsReceived := eventLoopEventsReceived.WithLabelValues(eventLoopStage_Controller, string(DeploymentModified))
+		eventsReceivedBefore := testutil.ToFloat64(eventsReceived)
+
+		for i := 1; i <= 3; i++ {
+			event := newTestGitOpsDeploymentEvent("my-gitops-depl")
+			sendEvent(t, input, event)
+			workspaceLoops.expectEvent(t, i, event)
+		}
+
+		assert.Equal(t, eventsReceivedBefore+3, testutil.ToFloat64(eventsReceived))
+	})
+
+	t.Run("Events are not lost if the workspace event loop is stopped while the event is sent", func(t *testing.T) {
+
+		workspaceLoops := newFakeEventLoops(1, 1)
+
+		input := make(chan eventLoopEvent)
+		go controllerEventLoopRouter(input, workspaceLoops.startWorkspaceEventLoop, ownsAllWorkspaces)
+
+		event := newTestGitOpsDeploymentEvent("my-gitops-depl")
+		sendEvent(t, input, event)
+		workspaceLoops.expectEvent(t, 2, event)
+	})
+
+	t.Run("Events of the workspaces that are not owned are dropped", func(t *testing.T) {
+
+		workspaceLoops := newFakeEventLoops(1, 0)
+
+		input := make(chan eventLoopEvent)
+		go controllerEventLoopRouter(input, workspaceLoops.startWorkspaceEventLoop, func(workspaceID string) bool {
+			return workspaceID == "my-workspace"
+		})
+
+		notOwnedEvent := newTestGitOpsDeploymentEvent("not-owned-gitops-depl")
+		notOwnedEvent.workspaceID = "other-workspace"
+		sendEvent(t, input, notOwnedEvent)
+
+		event := newTestGitOpsDeploymentEvent("my-gitops-depl")
+		sendEvent(t, input, event)
+
+		// The first workspace event loop receives the event of the owned workspace
+		workspaceLoops.expectEvent(t, 1, event)
+	})
+}
@@ -0,0 +1,662 @@
+package eventloop
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	operation "github.com/redhat-appstudio/managed-gitops/backend-shared/apis/managed-gitops/v1alpha1"
+	db "github.com/redhat-appstudio/managed-gitops/backend-shared/config/db"
+	sharedutil "github.com/redhat-appstudio/managed-gitops/backend-shared/util"
+	corev1 "k8s.io/api/core/v1"
+	apierr "k8s.io/apimachinery/pkg/api/errors"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// Results of a workspace teardown step, see 'workspaceTeardownSteps'
+const (
+	workspaceTeardownStepResult_Completed = "completed"
+	workspaceTeardownStepResult_Waiting   = "waiting"
+	workspaceTeardownStepResult_Failed    = "failed"
+)
+
+var (
+	// workspaceTeardownsInProgress is the number of deleted workspaces that still have database rows, as of the last sweep.
+	workspaceTeardownsInProgress = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "gitops_workspace_teardowns_in_progress",
+		Help: "Number of deleted workspaces whose resources have not yet been fully torn down, as of the last teardown sweep",
+	})
+
+	// workspaceTeardownsCompleted is the number of deleted workspaces whose resources have been fully torn down.
+	workspaceTeardownsCompleted = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "gitops_workspace_teardowns_completed_total",
+		Help: "Number of deleted workspaces whose resources have been fully torn down",
+	})
+
+	// workspaceTeardownStepRuns is the number of times each teardown step was run, by result.
+	workspaceTeardownStepRuns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gitops_workspace_teardown_steps_total",
+		Help: "Number of workspace teardown steps that were run, by step and result (completed, waiting, or failed)",
+	}, []string{"step", "result"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(workspaceTeardownsInProgress, workspaceTeardownsCompleted, workspaceTeardownStepRuns)
+}
+
+// WorkspaceTeardownRunner deletes the database rows, and the Argo CD Applications, of the workspaces whose namespace
+// has been deleted: the event loops only clean up after the GitOpsDeployments and GitOpsDeploymentSyncRuns that they
+// process, which leaves the ClusterUser, ManagedEnvironment, ClusterAccess and KubernetesToDBResourceMapping rows of
+// the workspace behind.
+//
+// A teardown sweep is run when a namespace is deleted, on startup, and every interval. Each step of the teardown of a
+// workspace is derived from the rows that remain in the database, so a teardown that was interrupted (or is waiting on
+// an Operation) is resumed by the next sweep.
+//
+// WorkspaceTeardownRunner implements the controller-runtime manager.Runnable interface.
+type WorkspaceTeardownRunner struct {
+	// interval is how often a sweep is run, in addition to on startup and on namespace deletion: 0 disables the
+	// periodic sweep.
+	interval time.Duration
+
+	// namespaceReader is used to list the namespaces that still exist: it should not be a caching reader, as a stale
+	// cache could cause the workspace of a namespace that was just created to be torn down.
+	namespaceReader client.Reader
+
+	// informers notifies the runner of namespace deletion, if non-nil
+	informers cache.Informers
+
+	dbQueries db.DatabaseQueries
+
+	// getK8sClientForGitOpsEngineInstance returns the client for the cluster of the GitOps engine instance: see 'gitopsEngineClientCache'
+	getK8sClientForGitOpsEngineInstance func(ctx context.Context, gitopsEngineInstance *db.GitopsEngineInstance) (client.Client, error)
+}
+
+// NewWorkspaceTeardownRunner creates a new WorkspaceTeardownRunner; it should be added to the manager, which will start it.
+func NewWorkspaceTeardownRunner(interval time.Duration, dbQueries db.DatabaseQueries, namespaceReader client.Reader, informers cache.Informers) *WorkspaceTeardownRunner {
+	return &WorkspaceTeardownRunner{
+		interval:                            interval,
+		dbQueries:                           dbQueries,
+		namespaceReader:                     namespaceReader,
+		informers:                           informers,
+		getK8sClientForGitOpsEngineInstance: actionGetK8sClientForGitOpsEngineInstance,
+	}
+}
+
+// Start runs a teardown sweep on startup, when a namespace is deleted, and every interval, until the context is cancelled.
+func (runner *WorkspaceTeardownRunner) Start(ctx context.Context) error {
+
+	log := log.FromContext(ctx).WithName("workspace-teardown")
+
+	// A single pending sweep is enough, no matter how many namespaces were deleted in the meantime
+	namespaceDeleted := make(chan struct{}, 1)
+
+	if runner.informers != nil {
+		informer, err := runner.informers.GetInformer(ctx, &corev1.Namespace{})
+		if err != nil {
+			return fmt.Errorf("unable to watch namespaces: %v", err)
+		}
+
+		informer.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+			DeleteFunc: func(obj interface{}) {
+				select {
+				case namespaceDeleted <- struct{}{}:
+				default:
+				}
+			},
+		})
+	}
+
+	// A nil channel is never ready, so sweeps are only run on namespace deletion if the interval is 0
+	var tick <-chan time.Time
+	if runner.interval > 0 {
+		ticker := time.NewTicker(runner.interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+
+		if err := runner.sweep(ctx, log); err != nil {
+			log.Error(err, "unable to tear down deleted workspaces")
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-tick:
+		case <-namespaceDeleted:
+		}
+	}
+}
+
+// sweep tears down each workspace that has database rows, but whose namespace no longer exists.
+func (runner *WorkspaceTeardownRunner) sweep(ctx context.Context, log logr.Logger) error {
+
+	workspaceIDs, err := runner.listDeletedWorkspaces(ctx)
+	if err != nil {
+		return err
+	}
+
+	inProgress := 0
+
+	for _, workspaceID := range workspaceIDs {
+
+		workspaceLog := log.WithValues("workspaceID", workspaceID)
+
+		complete, err := runner.teardownWorkspace(ctx, workspaceID, workspaceLog)
+		if err != nil {
+			workspaceLog.Error(err, "unable to tear down workspace, it will be retried on the next sweep")
+		}
+
+		if complete {
+			workspaceTeardownsCompleted.Inc()
+			workspaceLog.Info("Workspace teardown complete")
+		} else {
+			inProgress++
+		}
+	}
+
+	workspaceTeardownsInProgress.Set(float64(inProgress))
+
+	return nil
+}
+
+// listDeletedWorkspaces returns the IDs (namespace UIDs) of the workspaces that are referenced by the database, but
+// whose namespace no longer exists.
+func (runner *WorkspaceTeardownRunner) listDeletedWorkspaces(ctx context.Context) ([]string, error) {
+
+	var namespaceList corev1.NamespaceList
+	if err := runner.namespaceReader.List(ctx, &namespaceList); err != nil {
+		return nil, fmt.Errorf("unable to list namespaces: %v", err)
+	}
+
+	existingNamespaces := map[string]bool{}
+	for _, namespace := range namespaceList.Items {
+		existingNamespaces[string(namespace.UID)] = true
+	}
+
+	workspaceIDs := []string{}
+	seen := map[string]bool{}
+
+	addWorkspace := func(workspaceID string) {
+		if workspaceID == "" || existingNamespaces[workspaceID] || seen[workspaceID] {
+			return
+		}
+		seen[workspaceID] = true
+		workspaceIDs = append(workspaceIDs, workspaceID)
+	}
+
+	var managedEnvMappings []db.KubernetesToDBResourceMapping
+	if err := runner.dbQueries.ListKubernetesToDBResourceMappingsByType(ctx, db.K8sToDBMapping_Namespace,
+		db.K8sToDBMapping_ManagedEnvironment, &managedEnvMappings); err != nil {
+		return nil, fmt.Errorf("unable to list KubernetesToDBResourceMappings: %v", err)
+	}
+	for _, mapping := range managedEnvMappings {
+		addWorkspace(mapping.KubernetesResourceUID)
+	}
+
+	var deplToAppMappings []db.DeploymentToApplicationMapping
+	if err := runner.dbQueries.ListDeploymentToApplicationMappings(ctx, &deplToAppMappings); err != nil {
+		return nil, fmt.Errorf("unable to list DeploymentToApplicationMappings: %v", err)
+	}
+	for _, deplToAppMapping := range deplToAppMappings {
+		addWorkspace(deplToAppMapping.WorkspaceUID)
+	}
+
+	var apiCRToDBMappings []db.APICRToDatabaseMapping
+	if err := runner.dbQueries.ListAPICRToDatabaseMappings(ctx, &apiCRToDBMappings); err != nil {
+		return nil, fmt.Errorf("unable to list APICRToDatabaseMappings: %v", err)
+	}
+	for _, apiCRToDBMapping := range apiCRToDBMappings {
+		addWorkspace(apiCRToDBMapping.WorkspaceUID)
+	}
+
+	return workspaceIDs, nil
+}
+
+// workspaceTeardown is the state of the teardown of a single workspace, during a sweep.
+type workspaceTeardown struct {
+	workspaceID string
+
+	// clusterUser is the ClusterUser of the workspace, or nil if it has already been deleted
+	clusterUser *db.ClusterUser
+
+	log logr.Logger
+}
+
+// workspaceTeardownStep deletes a set of rows of the workspace. It returns false if the step cannot complete yet (for
+// example, as it is waiting on an Operation), in which case the following steps are not run.
+type workspaceTeardownStep struct {
+	name string
+	run  func(runner *WorkspaceTeardownRunner, ctx context.Context, teardown *workspaceTeardown) (bool, error)
+}
+
+// workspaceTeardownSteps are run in order: each step deletes the rows that reference the rows deleted by the steps that
+// follow it.
+var workspaceTeardownSteps = []workspaceTeardownStep{
+	{name: "applications", run: (*WorkspaceTeardownRunner).teardownApplications},
+	{name: "apicr-mappings", run: (*WorkspaceTeardownRunner).teardownAPICRToDatabaseMappings},
+	{name: "cluster-access", run: (*WorkspaceTeardownRunner).teardownClusterAccess},
+	{name: "operations", run: (*WorkspaceTeardownRunner).teardownOperations},
+	{name: "managed-environment", run: (*WorkspaceTeardownRunner).teardownManagedEnvironment},
+	{name: "cluster-user", run: (*WorkspaceTeardownRunner).teardownClusterUser},
+}
+
+// teardownWorkspace runs the teardown steps of the workspace, and returns true once every step has completed.
+func (runner *WorkspaceTeardownRunner) teardownWorkspace(ctx context.Context, workspaceID string, log logr.Logger) (bool, error) {
+
+	teardown := &workspaceTeardown{
+		workspaceID: workspaceID,
+		log:         log,
+	}
+
+	// TODO: GITOPS-1577 - KCP support: for now, we assume that the namespace UID of the workspace is the user name.
+	clusterUser := db.ClusterUser{User_name: workspaceID}
+	if err := runner.dbQueries.GetClusterUserByUsername(ctx, &clusterUser); err != nil {
+		if !db.IsResultNotFoundError(err) {
+			return false, fmt.Errorf("unable to retrieve ClusterUser of workspace: %v", err)
+		}
+	} else {
+		teardown.clusterUser = &clusterUser
+	}
+
+	for _, step := range workspaceTeardownSteps {
+
+		completed, err := step.run(runner, ctx, teardown)
+
+		if err != nil {
+			workspaceTeardownStepRuns.WithLabelValues(step.name, workspaceTeardownStepResult_Failed).Inc()
+			return false, fmt.Errorf("unable to tear down %s: %v", step.name, err)
+		}
+
+		if !completed {
+			workspaceTeardownStepRuns.WithLabelValues(step.name, workspaceTeardownStepResult_Waiting).Inc()
+			log.Info("Workspace teardown is waiting", "step", step.name)
+			return false, nil
+		}
+
+		workspaceTeardownStepRuns.WithLabelValues(step.name, workspaceTeardownStepResult_Completed).Inc()
+		log.V(sharedutil.LogLevel_Debug).Info("Workspace teardown step completed", "step", step.name)
+	}
+
+	return true, nil
+}
+
+// teardownApplications deletes the Application (and the rows that reference it) of each GitOpsDeployment of the
+// workspace, then creates an Operation so that the cluster-agent deletes the Argo CD Application. See
+// 'cleanOldGitOpsDeploymentEntry'. The Applications of the ManagedEnvironment of the workspace that are no longer
+// referenced by a DeploymentToApplicationMapping are also deleted, as they would otherwise prevent the deletion of the
+// ManagedEnvironment.
+func (runner *WorkspaceTeardownRunner) teardownApplications(ctx context.Context, teardown *workspaceTeardown) (bool, error) {
+
+	var deplToAppMappings []db.DeploymentToApplicationMapping
+	if err := runner.dbQueries.ListDeploymentToApplicationMappingByWorkspaceUID(ctx, teardown.workspaceID, &deplToAppMappings); err != nil {
+		return false, err
+	}
+
+	for idx := range deplToAppMappings {
+		deplToAppMapping := deplToAppMappings[idx]
+
+		if err := runner.teardownApplication(ctx, teardown, deplToAppMapping.Application_id, &deplToAppMapping); err != nil {
+			return false, err
+		}
+	}
+
+	mapping := db.KubernetesToDBResourceMapping{
+		KubernetesResourceType: db.K8sToDBMapping_Namespace,
+		KubernetesResourceUID:  teardown.workspaceID,
+		DBRelationType:         db.K8sToDBMapping_ManagedEnvironment,
+	}
+	if err := runner.dbQueries.GetDBResourceMappingForKubernetesResource(ctx, &mapping); err != nil {
+		if db.IsResultNotFoundError(err) {
+			return true, nil
+		}
+		return false, err
+	}
+
+	var applications []db.Application
+	if err := runner.dbQueries.ListApplicationsByManagedEnvironmentId(ctx, mapping.DBRelationKey, &applications); err != nil {
+		return false, err
+	}
+
+	for _, application := range applications {
+		if err := runner.teardownApplication(ctx, teardown, application.Application_id, nil); err != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// teardownApplication deletes the Application, the rows that reference it, and its DeploymentToApplicationMapping (if
+// non-nil), then creates an Operation so that the cluster-agent deletes the Argo CD Application.
+//
+// The Operation row is created in the same transaction as the rows are deleted, as it is then the only record of the
+// GitOps engine instance of the Argo CD Application. If the backend stops (or the Operation CR cannot be created) once
+// the transaction has committed, the Operation CR is created by 'teardownOperations'.
+//
+// The Application row is locked by the transaction, and the Operation is only created if the Application still exists:
+// the application event loop may be deleting the Application concurrently (see 'cleanOldGitOpsDeploymentEntry'), in
+// which case only one of them creates the Operation.
+func (runner *WorkspaceTeardownRunner) teardownApplication(ctx context.Context, teardown *workspaceTeardown, applicationID string,
+	deplToAppMapping *db.DeploymentToApplicationMapping) error {
+
+	log := teardown.log.WithValues("applicationID", applicationID)
+
+	dbApplicationFound := true
+	dbApplication := db.Application{Application_id: applicationID}
+	if err := runner.dbQueries.GetApplicationById(ctx, &dbApplication); err != nil {
+		if !db.IsResultNotFoundError(err) {
+			return err
+		}
+		dbApplicationFound = false
+	}
+
+	// The Operation that deletes the Argo CD Application, if the Application row still exists
+	var dbOperation *db.Operation
+	var gitopsEngineInstance db.GitopsEngineInstance
+
+	if dbApplicationFound {
+		var err error
+		if dbOperation, gitopsEngineInstance, err = runner.newApplicationOperation(ctx, teardown, dbApplication); err != nil {
+			return err
+		}
+	}
+
+	applicationLocked := false
+
+	if err := runner.dbQueries.RunInTransaction(ctx, func(tx db.ApplicationScopedQueries) error {
+
+		applicationLocked = false
+
+		if dbOperation != nil {
+			if err := tx.LockApplicationById(ctx, &db.Application{Application_id: applicationID}); err != nil {
+				if !db.IsResultNotFoundError(err) {
+					return err
+				}
+				log.Info("Application was deleted concurrently, so its Argo CD Application is already being deleted")
+			} else {
+				applicationLocked = true
+			}
+		}
+
+		if _, err := tx.DeleteApplicationStateById(ctx, applicationID); err != nil {
+			return err
+		}
+
+		if deplToAppMapping != nil {
+			if _, err := tx.DeleteDeploymentToApplicationMappingByDeplId(ctx, deplToAppMapping.Deploymenttoapplicationmapping_uid_id); err != nil {
+				return err
+			}
+		}
+
+		if _, err := tx.UpdateSyncOperationRemoveApplicationField(ctx, applicationID); err != nil {
+			return err
+		}
+
+		if !applicationLocked {
+			return nil
+		}
+
+		if _, err := tx.DeleteApplicationById(ctx, applicationID); err != nil {
+			return err
+		}
+
+		return tx.CreateOperation(ctx, dbOperation, teardown.clusterUser.Clusteruser_id)
+
+	}); err != nil {
+		return err
+	}
+
+	if deplToAppMapping != nil {
+		log.Info("Deleted Application of deleted workspace", "gitopsDeployment", deplToAppMapping.DeploymentNamespace+"/"+deplToAppMapping.DeploymentName)
+	} else {
+		log.Info("Deleted Application of the ManagedEnvironment of deleted workspace, which was not referenced by a GitOpsDeployment")
+	}
+
+	if !applicationLocked {
+		return nil
+	}
+
+	return runner.createOperationCR(ctx, *dbOperation, gitopsEngineInstance, log)
+}
+
+// newApplicationOperation returns the row of the Operation that deletes the Argo CD Application of the deleted
+// Application, and its GitOps engine instance. The Operation is owned by the ClusterUser of the workspace, so that
+// 'teardownOperations' waits for it to complete.
+func (runner *WorkspaceTeardownRunner) newApplicationOperation(ctx context.Context, teardown *workspaceTeardown,
+	dbApplication db.Application) (*db.Operation, db.GitopsEngineInstance, error) {
+
+	if teardown.clusterUser == nil {
+		clusterUser, err := internalGetOrCreateClusterUserByNamespaceUID(ctx, teardown.workspaceID, runner.dbQueries)
+		if err != nil {
+			return nil, db.GitopsEngineInstance{}, err
+		}
+		teardown.clusterUser = clusterUser
+	}
+
+	gitopsEngineInstance := db.GitopsEngineInstance{Gitopsengineinstance_id: dbApplication.Engine_instance_inst_id}
+	if err := runner.dbQueries.GetGitopsEngineInstanceById(ctx, &gitopsEngineInstance); err != nil {
+		return nil, db.GitopsEngineInstance{}, err
+	}
+
+	dbOperation := newDBOperation(db.Operation{
+		Instance_id:   gitopsEngineInstance.Gitopsengineinstance_id,
+		Resource_id:   dbApplication.Application_id,
+		Resource_type: db.OperationResourceType_Application,
+	}, teardown.clusterUser.Clusteruser_id)
+
+	return &dbOperation, gitopsEngineInstance, nil
+}
+
+// createOperationCR creates the Operation CR of the Operation row, without waiting for the Operation to complete: the
+// cluster-agent will then delete the Argo CD Application.
+func (runner *WorkspaceTeardownRunner) createOperationCR(ctx context.Context, dbOperation db.Operation,
+	gitopsEngineInstance db.GitopsEngineInstance, log logr.Logger) error {
+
+	gitopsEngineClient, err := runner.getK8sClientForGitOpsEngineInstance(ctx, &gitopsEngineInstance)
+	if err != nil {
+		return err
+	}
+
+	// The Operation CR is purged by the OperationCollector, once the Operation row is deleted by 'teardownOperations'.
+	_, err = createOperationCR(ctx, dbOperation, gitopsEngineInstance.Namespace_name, gitopsEngineClient, log)
+
+	return err
+}
+
+// teardownAPICRToDatabaseMappings deletes the APICRToDatabaseMappings of the workspace: the SyncOperations that they
+// referenced are then purged by the OperationCollector.
+func (runner *WorkspaceTeardownRunner) teardownAPICRToDatabaseMappings(ctx context.Context, teardown *workspaceTeardown) (bool, error) {
+
+	var apiCRToDBMappings []db.APICRToDatabaseMapping
+	if err := runner.dbQueries.ListAPICRToDatabaseMappingsByWorkspaceUID(ctx, teardown.workspaceID, &apiCRToDBMappings); err != nil {
+		return false, err
+	}
+
+	for idx := range apiCRToDBMappings {
+		apiCRToDBMapping := apiCRToDBMappings[idx]
+
+		if _, err := runner.dbQueries.DeleteAPICRToDatabaseMapping(ctx, &apiCRToDBMapping); err != nil {
+			return false, err
+		}
+
+		teardown.log.Info("Deleted APICRToDatabaseMapping of deleted workspace", "resource",
+			apiCRToDBMapping.APIResourceType+" "+apiCRToDBMapping.APIResourceNamespace+"/"+apiCRToDBMapping.APIResourceName)
+	}
+
+	return true, nil
+}
+
+// teardownClusterAccess deletes the ClusterAccess rows of the ClusterUser of the workspace.
+func (runner *WorkspaceTeardownRunner) teardownClusterAccess(ctx context.Context, teardown *workspaceTeardown) (bool, error) {
+
+	if teardown.clusterUser == nil {
+		return true, nil
+	}
+
+	var clusterAccessList []db.ClusterAccess
+	if err := runner.dbQueries.ListClusterAccessByClusterUserId(ctx, teardown.clusterUser.Clusteruser_id, &clusterAccessList); err != nil {
+		return false, err
+	}
+
+	for _, clusterAccess := range clusterAccessList {
+
+		if _, err := runner.dbQueries.DeleteClusterAccessById(ctx, clusterAccess.Clusteraccess_user_id,
+			clusterAccess.Clusteraccess_managed_environment_id, clusterAccess.Clusteraccess_gitops_engine_instance_id); err != nil {
+			return false, err
+		}
+
+		teardown.log.Info("Deleted ClusterAccess of deleted workspace", "managedEnvironmentID", clusterAccess.Clusteraccess_managed_environment_id,
+			"gitopsEngineInstanceID", clusterAccess.Clusteraccess_gitops_engine_instance_id)
+	}
+
+	return true, nil
+}
+
+// teardownOperations deletes the Operations owned by the ClusterUser of the workspace, once they have all completed:
+// this includes the Operations that delete the Argo CD Applications of the workspace, see 'teardownApplications'. The
+// Operation CR of an incomplete Application Operation is created, if it does not exist.
+func (runner *WorkspaceTeardownRunner) teardownOperations(ctx context.Context, teardown *workspaceTeardown) (bool, error) {
+
+	if teardown.clusterUser == nil {
+		return true, nil
+	}
+
+	var operations []db.Operation
+	if err := runner.dbQueries.ListOperationsByOwnerId(ctx, teardown.clusterUser.Clusteruser_id, &operations); err != nil {
+		return false, err
+	}
+
+	incomplete := 0
+	for _, operation := range operations {
+		if db.IsOperationStateComplete(operation.State) {
+			continue
+		}
+		incomplete++
+
+		if operation.Resource_type == db.OperationResourceType_Application {
+			if err := runner.ensureOperationCRExists(ctx, operation, teardown.log); err != nil {
+				return false, err
+			}
+		}
+	}
+
+	if incomplete > 0 {
+		// Operations that never complete are eventually timed out by the OperationReaper
+		teardown.log.Info("Waiting for the Operations of deleted workspace to complete", "operations", incomplete)
+		return false, nil
+	}
+
+	for _, operation := range operations {
+		if _, err := runner.dbQueries.DeleteOperationById(ctx, operation.Operation_id); err != nil {
+			return false, err
+		}
+	}
+
+	if len(operations) > 0 {
+		teardown.log.Info("Deleted Operations of deleted workspace", "operations", len(operations))
+	}
+
+	return true, nil
+}
+
+// ensureOperationCRExists creates the Operation CR of the Operation row, if it does not exist: the CR of an Operation
+// that was created by 'teardownApplications' is not created if the backend stopped before it could be.
+func (runner *WorkspaceTeardownRunner) ensureOperationCRExists(ctx context.Context, dbOperation db.Operation, log logr.Logger) error {
+
+	gitopsEngineInstance := db.GitopsEngineInstance{Gitopsengineinstance_id: dbOperation.Instance_id}
+	if err := runner.dbQueries.GetGitopsEngineInstanceById(ctx, &gitopsEngineInstance); err != nil {
+		return err
+	}
+
+	gitopsEngineClient, err := runner.getK8sClientForGitOpsEngineInstance(ctx, &gitopsEngineInstance)
+	if err != nil {
+		return err
+	}
+
+	operationCR := &operation.Operation{}
+	err = gitopsEngineClient.Get(ctx, client.ObjectKey{Namespace: gitopsEngineInstance.Namespace_name, Name: "operation-" + dbOperation.Operation_id}, operationCR)
+	if err == nil || !apierr.IsNotFound(err) {
+		return err
+	}
+
+	log.Info("Operation CR of deleted workspace does not exist, so it is created", "operation", dbOperation.Operation_id)
+
+	_, err = createOperationCR(ctx, dbOperation, gitopsEngineInstance.Namespace_name, gitopsEngineClient, log)
+	return err
+}
+
+// teardownManagedEnvironment deletes the ManagedEnvironment of the workspace, and its ClusterCredentials. The
+// KubernetesToDBResourceMapping of the namespace is deleted last, as it is the only reference to the ManagedEnvironment.
+//
+// Note: if the backend stops after the ManagedEnvironment is deleted, but before its ClusterCredentials are, the
+// ClusterCredentials row is left behind, as it is no longer referenced.
+func (runner *WorkspaceTeardownRunner) teardownManagedEnvironment(ctx context.Context, teardown *workspaceTeardown) (bool, error) {
+
+	mapping := db.KubernetesToDBResourceMapping{
+		KubernetesResourceType: db.K8sToDBMapping_Namespace,
+		KubernetesResourceUID:  teardown.workspaceID,
+		DBRelationType:         db.K8sToDBMapping_ManagedEnvironment,
+	}
+	if err := runner.dbQueries.GetDBResourceMappingForKubernetesResource(ctx, &mapping); err != nil {
+		if db.IsResultNotFoundError(err) {
+			return true, nil
+		}
+		return false, err
+	}
+
+	managedEnvironment := db.ManagedEnvironment{Managedenvironment_id: mapping.DBRelationKey}
+	if err := runner.dbQueries.GetManagedEnvironmentById(ctx, &managedEnvironment); err != nil {
+
+		if !db.IsResultNotFoundError(err) {
+			return false, err
+		}
+
+	} else {
+
+		rowsDeleted, err := runner.dbQueries.DeleteManagedEnvironmentWithoutClusterAccessById(ctx, managedEnvironment.Managedenvironment_id)
+		if err != nil {
+			return false, err
+		} else if rowsDeleted == 0 {
+			// Another user still has access to the ManagedEnvironment: this is unexpected, as each workspace has its own
+			return false, fmt.Errorf("ManagedEnvironment '%s' is still referenced by a ClusterAccess", managedEnvironment.Managedenvironment_id)
+		}
+
+		if _, err := runner.dbQueries.DeleteClusterCredentialsById(ctx, managedEnvironment.Clustercredentials_id); err != nil {
+			return false, err
+		}
+
+		teardown.log.Info("Deleted ManagedEnvironment of deleted workspace", "managedEnvironmentID", managedEnvironment.Managedenvironment_id)
+	}
+
+	if _, err := runner.dbQueries.DeleteKubernetesResourceToDBResourceMapping(ctx, &mapping); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// teardownClusterUser deletes the ClusterUser of the workspace, once nothing else references it.
+func (runner *WorkspaceTeardownRunner) teardownClusterUser(ctx context.Context, teardown *workspaceTeardown) (bool, error) {
+
+	if teardown.clusterUser == nil {
+		return true, nil
+	}
+
+	if _, err := runner.dbQueries.DeleteClusterUserById(ctx, teardown.clusterUser.Clusteruser_id); err != nil {
+		return false, err
+	}
+
+	teardown.log.Info("Deleted ClusterUser of deleted workspace", "clusterUserID", teardown.clusterUser.Clusteruser_id)
+	teardown.clusterUser = nil
+
+	return true, nil
+}
@@ -0,0 +1,271 @@
+package eventloop
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	operation "github.com/redhat-appstudio/managed-gitops/backend-shared/apis/managed-gitops/v1alpha1"
+	db "github.com/redhat-appstudio/managed-gitops/backend-shared/config/db"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	apierr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestWorkspaceTeardownRunner(t *testing.T) {
+
+	ctx := context.Background()
+	log := log.FromContext(ctx)
+
+	scheme, argocdNamespace, kubesystemNamespace, workspace := genericTestSetup(t)
+
+	otherWorkspace := &v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "other-user",
+			UID:  uuid.NewUUID(),
+		},
+	}
+
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(workspace, otherWorkspace, argocdNamespace, kubesystemNamespace).Build()
+
+	dbQueries := db.NewInMemoryDBQueries()
+
+	clusterUser, managedEnv, engineInstance, _, err := internalProcessMessage_GetOrCreateSharedResources(ctx, k8sClient, *workspace, dbQueries, log)
+	if !assert.NoError(t, err) {
+		return
+	}
+	otherClusterUser, _, _, _, err := internalProcessMessage_GetOrCreateSharedResources(ctx, k8sClient, *otherWorkspace, dbQueries, log)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// The workspace has a GitOpsDeployment, and a GitOpsDeploymentSyncRun
+	application := db.Application{
+		Name:                    "my-application",
+		Spec_field:              "{}",
+		Engine_instance_inst_id: engineInstance.Gitopsengineinstance_id,
+		Managed_environment_id:  managedEnv.Managedenvironment_id,
+	}
+	assert.NoError(t, dbQueries.CreateApplication(ctx, &application))
+	assert.NoError(t, dbQueries.CreateApplicationState(ctx, &db.ApplicationState{
+		Applicationstate_application_id: application.Application_id,
+		Health:                          "Healthy",
+		Sync_Status:                     "Synced",
+	}))
+	assert.NoError(t, dbQueries.CreateDeploymentToApplicationMapping(ctx, &db.DeploymentToApplicationMapping{
+		Deploymenttoapplicationmapping_uid_id: string(uuid.NewUUID()),
+		DeploymentName:                        "my-gitops-depl",
+		DeploymentNamespace:                   workspace.Name,
+		WorkspaceUID:                          string(workspace.UID),
+		Application_id:                        application.Application_id,
+	}))
+	assert.NoError(t, dbQueries.CreateAPICRToDatabaseMapping(ctx, &db.APICRToDatabaseMapping{
+		APIResourceType:      db.APICRToDatabaseMapping_ResourceType_GitOpsDeploymentSyncRun,
+		APIResourceUID:       string(uuid.NewUUID()),
+		APIResourceName:      "my-sync-run",
+		APIResourceNamespace: workspace.Name,
+		WorkspaceUID:         string(workspace.UID),
+		DBRelationType:       db.APICRToDatabaseMapping_DBRelationType_SyncOperation,
+		DBRelationKey:        "my-sync-operation",
+	}))
+
+	// An Application of the ManagedEnvironment of the workspace, which is no longer referenced by a GitOpsDeployment
+	orphanedApplication := db.Application{
+		Name:                    "my-orphaned-application",
+		Spec_field:              "{}",
+		Engine_instance_inst_id: engineInstance.Gitopsengineinstance_id,
+		Managed_environment_id:  managedEnv.Managedenvironment_id,
+	}
+	assert.NoError(t, dbQueries.CreateApplication(ctx, &orphanedApplication))
+
+	// Delete the namespace of the workspace
+	assert.NoError(t, k8sClient.Delete(ctx, workspace))
+
+	// The GitOps engine cluster is unavailable until the first sweep has run
+	gitopsEngineClusterAvailable := false
+
+	runner := NewWorkspaceTeardownRunner(0, dbQueries, k8sClient, nil)
+	runner.getK8sClientForGitOpsEngineInstance = func(ctx context.Context, gitopsEngineInstance *db.GitopsEngineInstance) (client.Client, error) {
+		if !gitopsEngineClusterAvailable {
+			return nil, fmt.Errorf("the GitOps engine cluster is unavailable")
+		}
+		return k8sClient, nil
+	}
+
+	completedBefore := testutil.ToFloat64(workspaceTeardownsCompleted)
+
+	t.Run("The Operation that deletes the Argo CD Application is created with the deletion of the Application", func(t *testing.T) {
+
+		assert.NoError(t, runner.sweep(ctx, log))
+		assert.Equal(t, float64(1), testutil.ToFloat64(workspaceTeardownsInProgress))
+
+		err := dbQueries.GetApplicationById(ctx, &db.Application{Application_id: application.Application_id})
+		assert.True(t, db.IsResultNotFoundError(err))
+		// The Operation CR could not be created, so the teardown stopped before the orphaned Application was deleted
+		assert.NoError(t, dbQueries.GetApplicationById(ctx, &db.Application{Application_id: orphanedApplication.Application_id}))
+
+		var operations []db.Operation
+		assert.NoError(t, dbQueries.ListOperationsByOwnerId(ctx, clusterUser.Clusteruser_id, &operations))
+		if assert.Len(t, operations, 1) {
+			assert.Equal(t, application.Application_id, operations[0].Resource_id)
+
+			err := k8sClient.Get(ctx, client.ObjectKey{Namespace: argocdNamespace.Name, Name: "operation-" + operations[0].Operation_id}, &operation.Operation{})
+			assert.True(t, apierr.IsNotFound(err))
+		}
+	})
+
+	t.Run("The teardown waits for the Operation that deletes the Argo CD Application", func(t *testing.T) {
+
+		gitopsEngineClusterAvailable = true
+
+		assert.NoError(t, runner.sweep(ctx, log))
+		assert.Equal(t, float64(1), testutil.ToFloat64(workspaceTeardownsInProgress))
+
+		err := dbQueries.GetApplicationById(ctx, &db.Application{Application_id: application.Application_id})
+		assert.True(t, db.IsResultNotFoundError(err))
+
+		err = dbQueries.GetApplicationById(ctx, &db.Application{Application_id: orphanedApplication.Application_id})
+		assert.True(t, db.IsResultNotFoundError(err))
+
+		var deplToAppMappings []db.DeploymentToApplicationMapping
+		assert.NoError(t, dbQueries.ListDeploymentToApplicationMappingByWorkspaceUID(ctx, string(workspace.UID), &deplToAppMappings))
+		assert.Len(t, deplToAppMappings, 0)
+
+		var apiCRToDBMappings []db.APICRToDatabaseMapping
+		assert.NoError(t, dbQueries.ListAPICRToDatabaseMappings(ctx, &apiCRToDBMappings))
+		assert.Len(t, apiCRToDBMappings, 0)
+
+		var clusterAccess []db.ClusterAccess
+		assert.NoError(t, dbQueries.ListClusterAccessByClusterUserId(ctx, clusterUser.Clusteruser_id, &clusterAccess))
+		assert.Len(t, clusterAccess, 0)
+
+		// The Operation CRs of the deleted Applications were created, and the remaining steps wait for them to complete
+		var operations []db.Operation
+		assert.NoError(t, dbQueries.ListOperationsByOwnerId(ctx, clusterUser.Clusteruser_id, &operations))
+		if assert.Len(t, operations, 2) {
+			for _, dbOperation := range operations {
+				operationCR := &operation.Operation{}
+				assert.NoError(t, k8sClient.Get(ctx, client.ObjectKey{Namespace: argocdNamespace.Name, Name: "operation-" + dbOperation.Operation_id}, operationCR))
+			}
+		}
+
+		assert.NoError(t, dbQueries.GetManagedEnvironmentById(ctx, &db.ManagedEnvironment{Managedenvironment_id: managedEnv.Managedenvironment_id}))
+		assert.NoError(t, dbQueries.GetClusterUserById(ctx, &db.ClusterUser{Clusteruser_id: clusterUser.Clusteruser_id}))
+
+		// Sweeping again while the Operation is in progress does not create another Operation
+		assert.NoError(t, runner.sweep(ctx, log))
+		assert.NoError(t, dbQueries.ListOperationsByOwnerId(ctx, clusterUser.Clusteruser_id, &operations))
+		assert.Len(t, operations, 2)
+	})
+
+	t.Run("The teardown completes once the Operation has completed", func(t *testing.T) {
+
+		var operations []db.Operation
+		assert.NoError(t, dbQueries.ListOperationsByOwnerId(ctx, clusterUser.Clusteruser_id, &operations))
+		for idx := range operations {
+			operations[idx].State = db.OperationState_Completed
+			assert.NoError(t, dbQueries.UpdateOperation(ctx, &operations[idx]))
+		}
+
+		assert.NoError(t, runner.sweep(ctx, log))
+		assert.Equal(t, float64(0), testutil.ToFloat64(workspaceTeardownsInProgress))
+		assert.Equal(t, completedBefore+1, testutil.ToFloat64(workspaceTeardownsCompleted))
+
+		assert.NoError(t, dbQueries.ListOperationsByOwnerId(ctx, clusterUser.Clusteruser_id, &operations))
+		assert.Len(t, operations, 0)
+
+		err := dbQueries.GetManagedEnvironmentById(ctx, &db.ManagedEnvironment{Managedenvironment_id: managedEnv.Managedenvironment_id})
+		assert.True(t, db.IsResultNotFoundError(err))
+
+		// ClusterCredentials can only be retrieved by their owner, so check that there is nothing left to delete
+		rowsDeleted, err := dbQueries.DeleteClusterCredentialsById(ctx, managedEnv.Clustercredentials_id)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, rowsDeleted)
+
+		err = dbQueries.GetDBResourceMappingForKubernetesResource(ctx, &db.KubernetesToDBResourceMapping{
+			KubernetesResourceType: db.K8sToDBMapping_Namespace,
+			KubernetesResourceUID:  string(workspace.UID),
+			DBRelationType:         db.K8sToDBMapping_ManagedEnvironment,
+		})
+		assert.True(t, db.IsResultNotFoundError(err))
+
+		err = dbQueries.GetClusterUserById(ctx, &db.ClusterUser{Clusteruser_id: clusterUser.Clusteruser_id})
+		assert.True(t, db.IsResultNotFoundError(err))
+
+		// The workspace of the namespace that still exists is untouched
+		assert.NoError(t, dbQueries.GetClusterUserById(ctx, &db.ClusterUser{Clusteruser_id: otherClusterUser.Clusteruser_id}))
+
+		var clusterAccess []db.ClusterAccess
+		assert.NoError(t, dbQueries.ListClusterAccessByClusterUserId(ctx, otherClusterUser.Clusteruser_id, &clusterAccess))
+		assert.Len(t, clusterAccess, 1)
+	})
+
+	t.Run("Nothing remains to be torn down", func(t *testing.T) {
+
+		workspaceIDs, err := runner.listDeletedWorkspaces(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, workspaceIDs, 0)
+
+		assert.NoError(t, runner.sweep(ctx, log))
+		assert.Equal(t, completedBefore+1, testutil.ToFloat64(workspaceTeardownsCompleted))
+	})
+}
+
+// concurrentDeleteDatabaseQueries deletes the Application once it has been retrieved, as the application event loop may
+// do concurrently with the workspace teardown: see 'cleanOldGitOpsDeploymentEntry'.
+type concurrentDeleteDatabaseQueries struct {
+	db.DatabaseQueries
+}
+
+func (dbq *concurrentDeleteDatabaseQueries) GetApplicationById(ctx context.Context, application *db.Application) error {
+	if err := dbq.DatabaseQueries.GetApplicationById(ctx, application); err != nil {
+		return err
+	}
+	_, err := dbq.DatabaseQueries.DeleteApplicationById(ctx, application.Application_id)
+	return err
+}
+
+func TestWorkspaceTeardownApplicationDeletedConcurrently(t *testing.T) {
+
+	ctx := context.Background()
+	log := log.FromContext(ctx)
+
+	scheme, argocdNamespace, kubesystemNamespace, workspace := genericTestSetup(t)
+
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(workspace, argocdNamespace, kubesystemNamespace).Build()
+
+	dbQueries := db.NewInMemoryDBQueries()
+
+	clusterUser, managedEnv, engineInstance, _, err := internalProcessMessage_GetOrCreateSharedResources(ctx, k8sClient, *workspace, dbQueries, log)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	application := db.Application{
+		Name:                    "my-application",
+		Spec_field:              "{}",
+		Engine_instance_inst_id: engineInstance.Gitopsengineinstance_id,
+		Managed_environment_id:  managedEnv.Managedenvironment_id,
+	}
+	assert.NoError(t, dbQueries.CreateApplication(ctx, &application))
+
+	runner := NewWorkspaceTeardownRunner(0, &concurrentDeleteDatabaseQueries{DatabaseQueries: dbQueries}, k8sClient, nil)
+	runner.getK8sClientForGitOpsEngineInstance = func(ctx context.Context, gitopsEngineInstance *db.GitopsEngineInstance) (client.Client, error) {
+		return k8sClient, nil
+	}
+
+	teardown := &workspaceTeardown{workspaceID: string(workspace.UID), clusterUser: clusterUser, log: log}
+	assert.NoError(t, runner.teardownApplication(ctx, teardown, application.Application_id, nil))
+
+	// The Argo CD Application is deleted by the Operation of the goroutine that deleted the Application, so the
+	// teardown does not create another
+	var operations []db.Operation
+	assert.NoError(t, dbQueries.ListOperationsByOwnerId(ctx, clusterUser.Clusteruser_id, &operations))
+	assert.Len(t, operations, 0)
+}
@@ -23,6 +23,7 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"time"
 
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -34,10 +35,12 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
 
 	"github.com/go-logr/logr"
 	sharedutil "github.com/redhat-appstudio/managed-gitops/backend-shared/util"
@@ -49,6 +52,7 @@ import (
 	managedgitopscontrollers "github.com/redhat-appstudio/managed-gitops/backend/controllers/managed-gitops"
 	"github.com/redhat-appstudio/managed-gitops/backend/eventloop"
 	"github.com/redhat-appstudio/managed-gitops/backend/routes"
+	"github.com/redhat-appstudio/managed-gitops/backend/sharding"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -69,11 +73,32 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var dbConsistencyCheckInterval time.Duration
+	var dbConsistencyCheckRepair bool
+	var shardWorkspaces bool
+	var reconciliationInterval time.Duration
+	var workspaceTeardownInterval time.Duration
+	var shardNamespace string
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":18080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":18081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.DurationVar(&dbConsistencyCheckInterval, "db-consistency-check-interval", 0,
+		"How often to check the database for inconsistencies (see 'gitops-db-check'). A value of 0 disables the check.")
+	flag.BoolVar(&dbConsistencyCheckRepair, "db-consistency-check-repair", false,
+		"Repair the inconsistencies found by the periodic database consistency check. If false, they are only logged.")
+	flag.BoolVar(&shardWorkspaces, "shard-workspaces", false,
+		"Shard the workspaces between the replicas of the backend: each replica processes the events of a subset of the workspaces. "+
+			"This replaces leader election, so it cannot be combined with '--leader-elect'.")
+	flag.StringVar(&shardNamespace, "shard-namespace", "gitops",
+		"The namespace of the membership Leases of the replicas, in sharding mode.")
+	flag.DurationVar(&reconciliationInterval, "reconciliation-interval", 10*time.Minute,
+		"How often to check for GitOpsDeployments and GitOpsDeploymentSyncRuns that were deleted, or have drifted from the database, "+
+			"without an event being processed. They are always checked on startup. A value of 0 disables the periodic check.")
+	flag.DurationVar(&workspaceTeardownInterval, "workspace-teardown-interval", 10*time.Minute,
+		"How often to tear down the resources of workspaces whose namespace was deleted. Workspaces are also torn down on startup, "+
+			"and when a namespace is deleted. A value of 0 disables the periodic teardown.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -82,7 +107,20 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
-	go initializeRoutes()
+	if shardWorkspaces && enableLeaderElection {
+		setupLog.Error(fmt.Errorf("'--shard-workspaces' and '--leader-elect' cannot be combined"), "invalid flags")
+		os.Exit(1)
+	}
+
+	// A single database connection pool is shared by the REST endpoints, the event loops, and the background tasks
+	dbQueries, err := db.NewProductionPostgresDBQueries(false)
+	if err != nil {
+		setupLog.Error(err, "unable to connect to database")
+		os.Exit(1)
+	}
+	eventloop.InitializeGitOpsEngineClients(dbQueries)
+
+	go initializeRoutes(dbQueries)
 
 	restConfig, err := sharedutil.GetRESTConfig()
 	if err != nil {
@@ -104,7 +142,34 @@ func main() {
 		os.Exit(1)
 	}
 
-	preprocessEventLoop := eventloop.NewPreprocessEventLoop()
+	// In sharding mode, the controllers and event loops run on every replica, and each replica processes the events of
+	// the workspaces it owns. Otherwise, the event loops are only started on the leader, see 'PreprocessEventLoop'.
+	var workspaceSharding *sharding.WorkspaceSharding
+	if shardWorkspaces {
+		workspaceSharding, err = newWorkspaceSharding(restConfig, shardNamespace)
+		if err != nil {
+			setupLog.Error(err, "unable to set up workspace sharding")
+			os.Exit(1)
+		}
+		if err := mgr.Add(workspaceSharding); err != nil {
+			setupLog.Error(err, "unable to add workspace sharding")
+			os.Exit(1)
+		}
+	}
+
+	// addSingleton adds a task that should only run on a single replica: the leader, or in sharding mode, the coordinator
+	addSingleton := func(runnable manager.Runnable) error {
+		if workspaceSharding != nil {
+			runnable = workspaceSharding.OnlyOnCoordinator(runnable)
+		}
+		return mgr.Add(runnable)
+	}
+
+	preprocessEventLoop := eventloop.NewPreprocessEventLoop(mgr.GetClient(), dbQueries, workspaceSharding, reconciliationInterval)
+	if err := mgr.Add(preprocessEventLoop); err != nil {
+		setupLog.Error(err, "unable to add preprocess event loop")
+		os.Exit(1)
+	}
 
 	if err = (&managedgitopscontrollers.GitOpsDeploymentReconciler{
 		PreprocessEventLoop: preprocessEventLoop,
@@ -124,11 +189,41 @@ func main() {
 	}
 	//+kubebuilder:scaffold:builder
 
+	if err := addSingleton(eventloop.NewOperationReaper(dbQueries)); err != nil {
+		setupLog.Error(err, "unable to add operation reaper")
+		os.Exit(1)
+	}
+
+	operationRetentionPolicy, err := eventloop.GetOperationRetentionPolicy()
+	if err != nil {
+		setupLog.Error(err, "invalid operation retention policy")
+		os.Exit(1)
+	}
+	if err := addSingleton(eventloop.NewOperationCollector(operationRetentionPolicy, dbQueries)); err != nil {
+		setupLog.Error(err, "unable to add operation collector")
+		os.Exit(1)
+	}
+
+	if dbConsistencyCheckInterval > 0 {
+		if err := addSingleton(eventloop.NewConsistencyCheckRunner(dbConsistencyCheckInterval, dbConsistencyCheckRepair, dbQueries, mgr.GetClient())); err != nil {
+			setupLog.Error(err, "unable to add database consistency checker")
+			os.Exit(1)
+		}
+	}
+
+	if err := addSingleton(eventloop.NewWorkspaceTeardownRunner(workspaceTeardownInterval, dbQueries, mgr.GetAPIReader(), mgr.GetCache())); err != nil {
+		setupLog.Error(err, "unable to add workspace teardown runner")
+		os.Exit(1)
+	}
+
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up health check")
 		os.Exit(1)
 	}
-	if err := mgr.AddReadyzCheck("readyz", healthz.Ping); err != nil {
+	// Only the leader is ready, as only the leader processes events (in sharding mode, every replica is ready once the
+	// workspaces it owns are known). The deployment manifest's rollout strategy doesn't wait for the new replicas to be
+	// ready before the old ones are terminated, as a new replica can't be elected until the old leader has terminated.
+	if err := mgr.AddReadyzCheck("readyz", preprocessEventLoop.ReadyzCheck); err != nil {
 		setupLog.Error(err, "unable to set up ready check")
 		os.Exit(1)
 	}
@@ -146,10 +241,31 @@ func main() {
 
 }
 
-func initializeRoutes() {
+// newWorkspaceSharding creates the WorkspaceSharding of this replica. The identity of the replica is the pod name.
+func newWorkspaceSharding(restConfig *rest.Config, namespace string) (*sharding.WorkspaceSharding, error) {
+
+	identity := os.Getenv("POD_NAME")
+	if identity == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("unable to determine the identity of the replica: %v", err)
+		}
+		identity = hostname
+	}
+
+	// A non-caching client is used, as the membership Leases are only read from a single namespace
+	k8sClient, err := client.New(restConfig, client.Options{Scheme: scheme})
+	if err != nil {
+		return nil, fmt.Errorf("unable to create client: %v", err)
+	}
+
+	return sharding.NewWorkspaceSharding(k8sClient, namespace, identity), nil
+}
+
+func initializeRoutes(dbQueries db.DatabaseQueries) {
 
 	// Intializing the server for routing endpoints
-	router := routes.RouteInit()
+	router := routes.RouteInit(dbQueries)
 	err := router.ListenAndServe()
 	if err != http.ErrServerClosed {
 		log.Println("Error on ListenAndServe:", err)
@@ -8,13 +8,14 @@ import (
 	"net/http"
 	"testing"
 
+	"github.com/redhat-appstudio/managed-gitops/backend-shared/config/db"
 	"github.com/redhat-appstudio/managed-gitops/backend/util"
 )
 
 func TestApplication(t *testing.T) {
 	serverURL := "http://localhost:8090"
 
-	server := RouteInit()
+	server := RouteInit(db.NewInMemoryDBQueries())
 	go func() {
 		err := server.ListenAndServe()
 		if err != http.ErrServerClosed {
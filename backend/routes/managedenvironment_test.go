@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"testing"
 
+	"github.com/redhat-appstudio/managed-gitops/backend-shared/config/db"
 	"github.com/redhat-appstudio/managed-gitops/backend/util"
 	"github.com/stretchr/testify/assert"
 )
@@ -15,7 +16,7 @@ import (
 func TestManagedEnvironment(t *testing.T) {
 	serverURL := "http://localhost:8090"
 
-	server := RouteInit()
+	server := RouteInit(db.NewInMemoryDBQueries())
 	go func() {
 		err := server.ListenAndServe()
 		if err != http.ErrServerClosed {
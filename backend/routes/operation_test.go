@@ -12,13 +12,14 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	restful "github.com/emicklei/go-restful/v3"
+	"github.com/redhat-appstudio/managed-gitops/backend-shared/config/db"
 	util "github.com/redhat-appstudio/managed-gitops/backend/util"
 )
 
 func TestServer(t *testing.T) {
 	serverURL := "http://localhost:8090"
 
-	server := RouteInit()
+	server := RouteInit(db.NewInMemoryDBQueries())
 	go func() {
 		err := server.ListenAndServe()
 		if err != http.ErrServerClosed {
@@ -5,11 +5,14 @@ import (
 	"net/http"
 
 	restful "github.com/emicklei/go-restful/v3"
+	"github.com/redhat-appstudio/managed-gitops/backend-shared/config/db"
 
+	status "github.com/redhat-appstudio/managed-gitops/backend/routes/status"
 	webhooks "github.com/redhat-appstudio/managed-gitops/backend/routes/webhooks"
 )
 
-func RouteInit() *http.Server {
+// RouteInit creates the server for the REST endpoints of the backend, which read from the given database.
+func RouteInit(dbQueries db.DatabaseQueries) *http.Server {
 	wsContainer := restful.NewContainer()
 	wsContainer.Router(restful.CurlyRouter{})
 
@@ -46,6 +49,17 @@ func RouteInit() *http.Server {
 	webhookR.Route(webhookR.POST("").To(webhooks.ParseWebhookInfo))
 	wsContainer.Add(webhookR)
 
+	// Registering the status resource, which reports the health of the GitOps engine clusters
+	statusResource := status.NewStatusResource(dbQueries)
+	statusR := new(restful.WebService)
+	statusR.
+		Path("/api/v1/status").
+		Produces(restful.MIME_JSON)
+	statusR.Route(statusR.GET("").To(statusResource.HandleGetStatus).
+		Returns(200, "OK", status.StatusResponse{}).
+		Returns(500, "Error Occured, unable to retrieve status", nil))
+	wsContainer.Add(statusR)
+
 	log.Print("Main: the server is up, and listening to port 8090 on your host.")
 	server := &http.Server{Addr: ":8090", Handler: wsContainer}
 
@@ -0,0 +1,92 @@
+package routes
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	restful "github.com/emicklei/go-restful/v3"
+	"github.com/redhat-appstudio/managed-gitops/backend-shared/config/db"
+	dbutil "github.com/redhat-appstudio/managed-gitops/backend-shared/config/db/util"
+)
+
+/*
+/api/v1/status
+GET: Retrieve the health of the GitOps engine clusters, based on the heartbeats of their cluster-agents
+200 = Success, will return the status of each GitOps engine cluster
+500 = An error occurred on retrieving the status from the database
+*/
+
+// GitOpsEngineClusterStatus is the availability of a single GitOps engine cluster, based on the heartbeat of its cluster-agent
+type GitOpsEngineClusterStatus struct {
+	ID string `json:"id"`
+
+	// Availability is one of: Available, Unavailable, Unknown (the cluster-agent has never sent a heartbeat)
+	Availability string `json:"availability"`
+
+	LastHeartbeat *time.Time `json:"lastHeartbeat,omitempty"`
+	AgentVersion  string     `json:"agentVersion,omitempty"`
+	ArgoCDVersion string     `json:"argoCDVersion,omitempty"`
+	Capacity      int        `json:"capacity,omitempty"`
+}
+
+type StatusResponse struct {
+	GitOpsEngineClusters []GitOpsEngineClusterStatus `json:"gitopsEngineClusters"`
+}
+
+// StatusResource handles requests to /api/v1/status
+type StatusResource struct {
+	dbQueries db.DatabaseQueries
+}
+
+// NewStatusResource creates a StatusResource that reads the heartbeats of the cluster-agents from the database.
+func NewStatusResource(dbQueries db.DatabaseQueries) *StatusResource {
+	return &StatusResource{dbQueries: dbQueries}
+}
+
+// HandleGetStatus handles the GET to /api/v1/status
+func (sr *StatusResource) HandleGetStatus(request *restful.Request, response *restful.Response) {
+
+	var gitopsEngineClusters []db.GitopsEngineCluster
+	if err := sr.dbQueries.ListGitopsEngineClusterHeartbeats(request.Request.Context(), &gitopsEngineClusters); err != nil {
+		log.Printf("unable to list gitops engine cluster heartbeats: %v", err)
+		writeError(response, http.StatusInternalServerError, "unable to retrieve gitops engine cluster status")
+		return
+	}
+
+	if err := response.WriteEntity(newStatusResponse(gitopsEngineClusters, time.Now())); err != nil {
+		log.Printf("unable to write status response: %v", err)
+	}
+}
+
+func newStatusResponse(gitopsEngineClusters []db.GitopsEngineCluster, now time.Time) StatusResponse {
+
+	res := StatusResponse{GitOpsEngineClusters: []GitOpsEngineClusterStatus{}}
+
+	for idx := range gitopsEngineClusters {
+
+		gitopsEngineCluster := gitopsEngineClusters[idx]
+
+		clusterStatus := GitOpsEngineClusterStatus{
+			ID:            gitopsEngineCluster.Gitopsenginecluster_id,
+			Availability:  string(dbutil.GetGitopsEngineClusterAvailability(gitopsEngineCluster, now)),
+			AgentVersion:  gitopsEngineCluster.Heartbeat_agent_version,
+			ArgoCDVersion: gitopsEngineCluster.Heartbeat_argocd_version,
+			Capacity:      gitopsEngineCluster.Heartbeat_capacity,
+		}
+
+		if !gitopsEngineCluster.Heartbeat_last_seen.IsZero() {
+			clusterStatus.LastHeartbeat = &gitopsEngineCluster.Heartbeat_last_seen
+		}
+
+		res.GitOpsEngineClusters = append(res.GitOpsEngineClusters, clusterStatus)
+	}
+
+	return res
+}
+
+func writeError(response *restful.Response, status int, errString string) {
+	if err := response.WriteErrorString(status, errString); err != nil {
+		log.Printf("unable to write error response: %v", err)
+	}
+}
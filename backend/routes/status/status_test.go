@@ -0,0 +1,51 @@
+package routes
+
+import (
+	"testing"
+	"time"
+
+	"github.com/redhat-appstudio/managed-gitops/backend-shared/config/db"
+	dbutil "github.com/redhat-appstudio/managed-gitops/backend-shared/config/db/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewStatusResponse(t *testing.T) {
+
+	now := time.Now()
+	lastHeartbeat := now.Add(-dbutil.GitopsEngineClusterHeartbeatInterval)
+
+	res := newStatusResponse([]db.GitopsEngineCluster{
+		{
+			Gitopsenginecluster_id:   "available-cluster",
+			Heartbeat_last_seen:      lastHeartbeat,
+			Heartbeat_agent_version:  "v0.0.1",
+			Heartbeat_argocd_version: "v2.2.5",
+			Heartbeat_capacity:       20,
+		},
+		{
+			Gitopsenginecluster_id: "unavailable-cluster",
+			Heartbeat_last_seen:    now.Add(-2 * dbutil.GitopsEngineClusterHeartbeatTimeout),
+		},
+		{
+			Gitopsenginecluster_id: "unknown-cluster",
+		},
+	}, now)
+
+	if !assert.Len(t, res.GitOpsEngineClusters, 3) {
+		return
+	}
+
+	assert.Equal(t, GitOpsEngineClusterStatus{
+		ID:            "available-cluster",
+		Availability:  string(dbutil.GitopsEngineClusterAvailability_Available),
+		LastHeartbeat: &lastHeartbeat,
+		AgentVersion:  "v0.0.1",
+		ArgoCDVersion: "v2.2.5",
+		Capacity:      20,
+	}, res.GitOpsEngineClusters[0])
+
+	assert.Equal(t, string(dbutil.GitopsEngineClusterAvailability_Unavailable), res.GitOpsEngineClusters[1].Availability)
+
+	assert.Equal(t, string(dbutil.GitopsEngineClusterAvailability_Unknown), res.GitOpsEngineClusters[2].Availability)
+	assert.Nil(t, res.GitOpsEngineClusters[2].LastHeartbeat)
+}
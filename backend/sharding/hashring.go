@@ -0,0 +1,105 @@
+package sharding
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+// virtualNodesPerMember is the number of points that each member has on the hash ring: the more points, the more evenly
+// the workspaces are distributed between the members.
+const virtualNodesPerMember = 100
+
+// hashRing assigns keys (workspace IDs) to members (backend replicas) by consistent hashing: when a member joins or
+// leaves, only the keys of that member are reassigned, and the remaining keys keep their owner.
+type hashRing struct {
+	// members is the sorted list of members of the ring
+	members []string
+
+	// points is the sorted list of the hashes of the virtual nodes of the members, and owners[i] is the member of points[i]
+	points []uint64
+	owners []string
+}
+
+func newHashRing(members []string) *hashRing {
+
+	ring := &hashRing{
+		members: append([]string{}, members...),
+	}
+	sort.Strings(ring.members)
+
+	type point struct {
+		hash  uint64
+		owner string
+	}
+
+	points := make([]point, 0, len(ring.members)*virtualNodesPerMember)
+	for _, member := range ring.members {
+		for i := 0; i < virtualNodesPerMember; i++ {
+			points = append(points, point{hash: hashKey(fmt.Sprintf("%s#%d", member, i)), owner: member})
+		}
+	}
+
+	sort.Slice(points, func(i, j int) bool {
+		if points[i].hash == points[j].hash {
+			return points[i].owner < points[j].owner
+		}
+		return points[i].hash < points[j].hash
+	})
+
+	for _, point := range points {
+		ring.points = append(ring.points, point.hash)
+		ring.owners = append(ring.owners, point.owner)
+	}
+
+	return ring
+}
+
+// owner returns the member that owns the key: the member of the first point of the ring at, or after, the hash of the
+// key. An empty string is returned if the ring has no members.
+func (ring *hashRing) owner(key string) string {
+
+	if len(ring.points) == 0 {
+		return ""
+	}
+
+	hash := hashKey(key)
+	idx := sort.Search(len(ring.points), func(i int) bool { return ring.points[i] >= hash })
+	if idx == len(ring.points) {
+		// Wrap around to the start of the ring
+		idx = 0
+	}
+
+	return ring.owners[idx]
+}
+
+// coordinator returns the member that runs the tasks that should only run on a single replica, or an empty string if
+// the ring has no members.
+func (ring *hashRing) coordinator() string {
+	if len(ring.members) == 0 {
+		return ""
+	}
+	return ring.members[0]
+}
+
+func (ring *hashRing) hasSameMembers(members []string) bool {
+
+	sortedMembers := append([]string{}, members...)
+	sort.Strings(sortedMembers)
+
+	if len(sortedMembers) != len(ring.members) {
+		return false
+	}
+	for idx := range sortedMembers {
+		if sortedMembers[idx] != ring.members[idx] {
+			return false
+		}
+	}
+	return true
+}
+
+func hashKey(key string) uint64 {
+	sum := sha256.Sum256([]byte(key))
+	return binary.BigEndian.Uint64(sum[:8])
+}
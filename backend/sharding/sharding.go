@@ -0,0 +1,396 @@
+package sharding
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	sharedutil "github.com/redhat-appstudio/managed-gitops/backend-shared/util"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+// Workspace sharding
+//
+// In sharding mode, every replica of the backend runs the controllers and the event loops, and each replica processes
+// the events of a subset of the workspaces:
+// - Each replica is a member of the shard group for as long as it renews its membership Lease.
+// - The workspaces are assigned to the members by consistent hashing of the workspace ID (see hashRing), so when a
+//   member joins or leaves, only the workspaces of that member change owner.
+// - A replica immediately stops processing the workspaces it has lost, but waits for 'ownershipHandoffDelay' before it
+//   starts processing the workspaces it has gained: by then, the previous owners have observed the same membership
+//   change, and stopped processing them.
+// - Once the gained workspaces are owned, the ownership changed handlers are called: the event loops rehydrate the state
+//   of the workspaces from the database.
+// - Tasks that should run on a single replica only (such as the operation reaper) run on the coordinator, which is the
+//   member with the lowest identity.
+
+var (
+	// memberLeaseDuration is how long the membership Lease of a replica remains valid, after it was last renewed
+	memberLeaseDuration = 30 * time.Second
+
+	// memberLeaseRenewInterval is how often each replica renews its membership Lease, and refreshes the membership
+	memberLeaseRenewInterval = 10 * time.Second
+
+	// ownershipHandoffDelay is how long a replica waits before processing the workspaces it has gained: this should be
+	// longer than memberLeaseRenewInterval, so that the previous owner has observed the membership change.
+	ownershipHandoffDelay = 2 * memberLeaseRenewInterval
+)
+
+const (
+	// memberLeaseLabel is the label of the membership Leases of the shard group
+	memberLeaseLabel = "managed-gitops.redhat.com/backend-shard-member"
+
+	memberLeaseNamePrefix = "gitops-backend-shard-"
+)
+
+// WorkspaceSharding determines which workspaces are owned by this replica of the backend.
+//
+// WorkspaceSharding implements the controller-runtime manager.Runnable interface, and should be added to the manager,
+// which will start it.
+type WorkspaceSharding struct {
+	// k8sClient should not be a caching client: Leases are only read from 'namespace'
+	k8sClient client.Client
+	namespace string
+	identity  string
+
+	// ownershipChangedHandlers are called (one at a time) after the workspaces owned by this replica have changed
+	ownershipChangedHandlers []func(ctx context.Context)
+
+	// now returns the current time; it is replaced by unit tests
+	now func() time.Time
+
+	// mutex should be held when reading or writing any of the fields below
+	mutex sync.RWMutex
+
+	// current is the ring of the current members, or nil if the membership has not yet been retrieved
+	current *hashRing
+
+	// previous is the ring that was in effect before 'changedAt': this replica continues to own the workspaces it owned
+	// in 'previous', during the handoff delay.
+	previous  *hashRing
+	changedAt time.Time
+
+	// lastRenewed is the time the membership Lease of this replica was last renewed: if the Lease may have expired,
+	// this replica no longer owns any workspace.
+	lastRenewed time.Time
+}
+
+// NewWorkspaceSharding creates a new WorkspaceSharding, for the replica with the given identity (which should be unique
+// between replicas, for example the pod name). The membership Leases are created in the given namespace.
+func NewWorkspaceSharding(k8sClient client.Client, namespace string, identity string) *WorkspaceSharding {
+	return &WorkspaceSharding{
+		k8sClient: k8sClient,
+		namespace: namespace,
+		identity:  identity,
+		now:       time.Now,
+	}
+}
+
+// OnOwnershipChanged registers a handler that is called after the workspaces owned by this replica have changed,
+// including once the workspaces are first owned. Handlers should be registered before the manager is started.
+func (sharding *WorkspaceSharding) OnOwnershipChanged(handler func(ctx context.Context)) {
+	sharding.ownershipChangedHandlers = append(sharding.ownershipChangedHandlers, handler)
+}
+
+// OwnsWorkspace returns true if the events of the workspace should be processed by this replica.
+func (sharding *WorkspaceSharding) OwnsWorkspace(workspaceID string) bool {
+	return sharding.isOwner(func(ring *hashRing) string {
+		return ring.owner(workspaceID)
+	})
+}
+
+// IsCoordinator returns true if the tasks that should only run on a single replica should run on this replica.
+func (sharding *WorkspaceSharding) IsCoordinator() bool {
+	return sharding.isOwner(func(ring *hashRing) string {
+		return ring.coordinator()
+	})
+}
+
+func (sharding *WorkspaceSharding) isOwner(getOwner func(ring *hashRing) string) bool {
+
+	sharding.mutex.RLock()
+	defer sharding.mutex.RUnlock()
+
+	now := sharding.now()
+
+	if sharding.current == nil || now.After(sharding.lastRenewed.Add(memberLeaseDuration)) {
+		return false
+	}
+
+	if getOwner(sharding.current) != sharding.identity {
+		return false
+	}
+
+	if sharding.previous != nil && getOwner(sharding.previous) == sharding.identity {
+		return true
+	}
+
+	return !now.Before(sharding.changedAt.Add(ownershipHandoffDelay))
+}
+
+// NeedLeaderElection returns false: sharding runs on every replica.
+func (sharding *WorkspaceSharding) NeedLeaderElection() bool {
+	return false
+}
+
+// Start renews the membership Lease of this replica, and refreshes the membership, until the context is cancelled.
+func (sharding *WorkspaceSharding) Start(ctx context.Context) error {
+
+	log := log.FromContext(ctx).WithName("workspace-sharding").WithValues("identity", sharding.identity)
+
+	log.Info("Workspace sharding started")
+
+	handlersTrigger := make(chan struct{}, 1)
+	go sharding.runOwnershipChangedHandlers(ctx, handlersTrigger)
+
+	ticker := time.NewTicker(memberLeaseRenewInterval)
+	defer ticker.Stop()
+
+	handoffTimer := time.NewTimer(ownershipHandoffDelay)
+	handoffTimer.Stop()
+	defer handoffTimer.Stop()
+
+	for {
+
+		if err := sharding.renewMemberLease(ctx); err != nil {
+			log.Error(err, "unable to renew membership Lease")
+		}
+
+		if changed, err := sharding.refreshMembership(ctx, log); err != nil {
+			log.Error(err, "unable to refresh membership")
+
+		} else if changed {
+			// Once the handoff delay has passed, the gained workspaces are owned
+			handoffTimer.Stop()
+			handoffTimer = time.NewTimer(ownershipHandoffDelay)
+		}
+
+		select {
+		case <-ctx.Done():
+			// Leave the shard group, so that the workspaces of this replica are reassigned without waiting for the Lease
+			// to expire
+			if err := sharding.deleteMemberLease(context.Background()); err != nil {
+				log.Error(err, "unable to delete membership Lease")
+			}
+			log.Info("Workspace sharding stopped")
+			return nil
+
+		case <-ticker.C:
+
+		case <-handoffTimer.C:
+			select {
+			case handlersTrigger <- struct{}{}:
+			default:
+				// The handlers are already due to be called
+			}
+		}
+	}
+}
+
+// runOwnershipChangedHandlers calls the ownership changed handlers each time the trigger channel is signalled.
+func (sharding *WorkspaceSharding) runOwnershipChangedHandlers(ctx context.Context, trigger chan struct{}) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-trigger:
+		}
+
+		for _, handler := range sharding.ownershipChangedHandlers {
+			handler(ctx)
+		}
+	}
+}
+
+func (sharding *WorkspaceSharding) memberLeaseName() string {
+	return memberLeaseNamePrefix + sharding.identity
+}
+
+// renewMemberLease creates or renews the membership Lease of this replica.
+func (sharding *WorkspaceSharding) renewMemberLease(ctx context.Context) error {
+
+	now := sharding.now()
+	renewTime := metav1.NewMicroTime(now)
+	leaseDurationSeconds := int32(memberLeaseDuration.Seconds())
+
+	lease := &coordinationv1.Lease{}
+	err := sharding.k8sClient.Get(ctx, client.ObjectKey{Namespace: sharding.namespace, Name: sharding.memberLeaseName()}, lease)
+
+	if apierr.IsNotFound(err) {
+		lease = &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      sharding.memberLeaseName(),
+				Namespace: sharding.namespace,
+				Labels:    map[string]string{memberLeaseLabel: "true"},
+			},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       &sharding.identity,
+				LeaseDurationSeconds: &leaseDurationSeconds,
+				AcquireTime:          &renewTime,
+				RenewTime:            &renewTime,
+			},
+		}
+		if err := sharding.k8sClient.Create(ctx, lease); err != nil {
+			return fmt.Errorf("unable to create membership Lease: %v", err)
+		}
+
+	} else if err != nil {
+		return fmt.Errorf("unable to retrieve membership Lease: %v", err)
+
+	} else {
+		lease.Spec.HolderIdentity = &sharding.identity
+		lease.Spec.LeaseDurationSeconds = &leaseDurationSeconds
+		lease.Spec.RenewTime = &renewTime
+		if err := sharding.k8sClient.Update(ctx, lease); err != nil {
+			return fmt.Errorf("unable to update membership Lease: %v", err)
+		}
+	}
+
+	sharding.mutex.Lock()
+	defer sharding.mutex.Unlock()
+	sharding.lastRenewed = now
+
+	return nil
+}
+
+func (sharding *WorkspaceSharding) deleteMemberLease(ctx context.Context) error {
+
+	lease := &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      sharding.memberLeaseName(),
+			Namespace: sharding.namespace,
+		},
+	}
+
+	return client.IgnoreNotFound(sharding.k8sClient.Delete(ctx, lease))
+}
+
+// refreshMembership retrieves the members of the shard group (the replicas whose membership Lease has not expired), and
+// returns true if the members have changed.
+func (sharding *WorkspaceSharding) refreshMembership(ctx context.Context, log logr.Logger) (bool, error) {
+
+	var leaseList coordinationv1.LeaseList
+	if err := sharding.k8sClient.List(ctx, &leaseList, client.InNamespace(sharding.namespace),
+		client.MatchingLabels{memberLeaseLabel: "true"}); err != nil {
+		return false, fmt.Errorf("unable to list membership Leases: %v", err)
+	}
+
+	now := sharding.now()
+
+	// This replica is always a member: if its Lease has expired, it owns nothing regardless (see 'isOwner')
+	members := []string{sharding.identity}
+	for _, lease := range leaseList.Items {
+		if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity == sharding.identity ||
+			lease.Spec.RenewTime == nil || lease.Spec.LeaseDurationSeconds == nil {
+			continue
+		}
+
+		expiry := lease.Spec.RenewTime.Add(time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second)
+		if now.After(expiry) {
+			continue
+		}
+
+		members = append(members, *lease.Spec.HolderIdentity)
+	}
+
+	sharding.mutex.Lock()
+	defer sharding.mutex.Unlock()
+
+	if sharding.current != nil && sharding.current.hasSameMembers(members) {
+		return false, nil
+	}
+
+	// If a previous change is still being handed off, then the workspaces gained by that change are not yet owned:
+	// keep the ring that was in effect before it.
+	if sharding.current != nil && !now.Before(sharding.changedAt.Add(ownershipHandoffDelay)) {
+		sharding.previous = sharding.current
+	}
+	sharding.current = newHashRing(members)
+	sharding.changedAt = now
+
+	log.Info("Shard group membership changed", "members", sharding.current.members)
+
+	return true, nil
+}
+
+// OnlyOnCoordinator returns a Runnable that runs the given Runnable only while this replica is the coordinator of the
+// shard group. In sharding mode, this replaces leader election, for the tasks that should only run on a single replica:
+// the Runnable is started when this replica becomes the coordinator, and stopped (by cancelling its context) when
+// it no longer is.
+func (sharding *WorkspaceSharding) OnlyOnCoordinator(runnable manager.Runnable) manager.Runnable {
+	return &coordinatorRunnable{sharding: sharding, runnable: runnable}
+}
+
+type coordinatorRunnable struct {
+	sharding *WorkspaceSharding
+	runnable manager.Runnable
+}
+
+// NeedLeaderElection returns false: the runnable is started on every replica, but only runs on the coordinator.
+func (coordinator *coordinatorRunnable) NeedLeaderElection() bool {
+	return false
+}
+
+func (coordinator *coordinatorRunnable) Start(ctx context.Context) error {
+
+	log := log.FromContext(ctx).WithName("workspace-sharding")
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	var cancelRunnable context.CancelFunc
+	var runnableDone chan error
+
+	stopRunnable := func() error {
+		cancelRunnable()
+		err := <-runnableDone
+		cancelRunnable = nil
+		runnableDone = nil
+		return err
+	}
+
+	for {
+
+		isCoordinator := coordinator.sharding.IsCoordinator()
+
+		if isCoordinator && cancelRunnable == nil {
+			log.V(sharedutil.LogLevel_Debug).Info("Starting coordinator task", "task", fmt.Sprintf("%T", coordinator.runnable))
+
+			var runnableContext context.Context
+			runnableContext, cancelRunnable = context.WithCancel(ctx)
+			runnableDone = make(chan error, 1)
+			go func(done chan error) {
+				done <- coordinator.runnable.Start(runnableContext)
+			}(runnableDone)
+
+		} else if !isCoordinator && cancelRunnable != nil {
+			log.V(sharedutil.LogLevel_Debug).Info("Stopping coordinator task", "task", fmt.Sprintf("%T", coordinator.runnable))
+
+			if err := stopRunnable(); err != nil {
+				return err
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			if cancelRunnable != nil {
+				return stopRunnable()
+			}
+			return nil
+
+		case err := <-runnableDone:
+			// The task has ended of its own accord
+			cancelRunnable()
+			return err
+
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package sharding
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestHashRing(t *testing.T) {
+
+	workspaceIDs := []string{}
+	for i := 0; i < 1000; i++ {
+		workspaceIDs = append(workspaceIDs, fmt.Sprintf("workspace-%d", i))
+	}
+
+	t.Run("Workspaces are distributed between all the members", func(t *testing.T) {
+
+		ring := newHashRing([]string{"replica-c", "replica-a", "replica-b"})
+		assert.Equal(t, "replica-a", ring.coordinator())
+		assert.True(t, ring.hasSameMembers([]string{"replica-b", "replica-c", "replica-a"}))
+		assert.False(t, ring.hasSameMembers([]string{"replica-a", "replica-b"}))
+
+		owned := map[string]int{}
+		for _, workspaceID := range workspaceIDs {
+			owned[ring.owner(workspaceID)]++
+		}
+
+		assert.Len(t, owned, 3)
+		for member, count := range owned {
+			assert.Greater(t, count, 200, "member '%s' should own a fair share of the workspaces", member)
+		}
+	})
+
+	t.Run("Only the workspaces of a member that leaves are reassigned", func(t *testing.T) {
+
+		before := newHashRing([]string{"replica-a", "replica-b", "replica-c"})
+		after := newHashRing([]string{"replica-a", "replica-c"})
+
+		for _, workspaceID := range workspaceIDs {
+			if before.owner(workspaceID) != "replica-b" {
+				assert.Equal(t, before.owner(workspaceID), after.owner(workspaceID))
+			}
+		}
+	})
+
+	t.Run("A ring without members owns nothing", func(t *testing.T) {
+		ring := newHashRing([]string{})
+		assert.Equal(t, "", ring.owner("workspace"))
+		assert.Equal(t, "", ring.coordinator())
+	})
+}
+
+func newTestWorkspaceSharding(t *testing.T, identity string, now *time.Time, objs ...client.Object) *WorkspaceSharding {
+
+	scheme := runtime.NewScheme()
+	assert.NoError(t, clientgoscheme.AddToScheme(scheme))
+
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build()
+
+	sharding := NewWorkspaceSharding(k8sClient, "gitops", identity)
+	sharding.now = func() time.Time { return *now }
+
+	return sharding
+}
+
+func newTestMemberLease(identity string, renewTime time.Time) *coordinationv1.Lease {
+
+	leaseDurationSeconds := int32(memberLeaseDuration.Seconds())
+	microRenewTime := metav1.NewMicroTime(renewTime)
+
+	return &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      memberLeaseNamePrefix + identity,
+			Namespace: "gitops",
+			Labels:    map[string]string{memberLeaseLabel: "true"},
+		},
+		Spec: coordinationv1.LeaseSpec{
+			HolderIdentity:       &identity,
+			LeaseDurationSeconds: &leaseDurationSeconds,
+			RenewTime:            &microRenewTime,
+		},
+	}
+}
+
+// findWorkspace returns a workspace ID that is owned by the given member, in a ring of the given members
+func findWorkspace(t *testing.T, members []string, owner string) string {
+	ring := newHashRing(members)
+	for i := 0; ; i++ {
+		workspaceID := fmt.Sprintf("workspace-%d", i)
+		if ring.owner(workspaceID) == owner {
+			return workspaceID
+		}
+		if i > 1000 {
+			t.Fatalf("no workspace is owned by '%s'", owner)
+		}
+	}
+}
+
+func TestWorkspaceSharding(t *testing.T) {
+
+	ctx := context.Background()
+	log := log.FromContext(ctx)
+
+	t.Run("Ownership follows the membership Leases, after the handoff delay", func(t *testing.T) {
+
+		now := time.Now()
+		sharding := newTestWorkspaceSharding(t, "replica-a", &now,
+			newTestMemberLease("replica-b", now),
+			newTestMemberLease("replica-expired", now.Add(-2*memberLeaseDuration)))
+
+		ownedByA := findWorkspace(t, []string{"replica-a", "replica-b"}, "replica-a")
+		ownedByB := findWorkspace(t, []string{"replica-a", "replica-b"}, "replica-b")
+
+		// Nothing is owned before the membership is known
+		assert.False(t, sharding.OwnsWorkspace(ownedByA))
+
+		assert.NoError(t, sharding.renewMemberLease(ctx))
+		changed, err := sharding.refreshMembership(ctx, log)
+		assert.NoError(t, err)
+		assert.True(t, changed)
+		assert.Equal(t, []string{"replica-a", "replica-b"}, sharding.current.members, "expired Leases are not members")
+
+		// The gained workspaces are only owned once the handoff delay has passed
+		assert.False(t, sharding.OwnsWorkspace(ownedByA))
+		now = now.Add(ownershipHandoffDelay)
+		assert.NoError(t, sharding.renewMemberLease(ctx))
+		assert.True(t, sharding.OwnsWorkspace(ownedByA))
+		assert.False(t, sharding.OwnsWorkspace(ownedByB))
+		assert.True(t, sharding.IsCoordinator())
+
+		changed, err = sharding.refreshMembership(ctx, log)
+		assert.NoError(t, err)
+		assert.False(t, changed)
+
+		// Once the Lease of replica-b expires, its workspaces are gained after the handoff delay, and the workspaces of
+		// replica-a remain owned in the meantime.
+		now = now.Add(2 * memberLeaseDuration)
+		assert.NoError(t, sharding.renewMemberLease(ctx))
+		changed, err = sharding.refreshMembership(ctx, log)
+		assert.NoError(t, err)
+		assert.True(t, changed)
+
+		assert.True(t, sharding.OwnsWorkspace(ownedByA))
+		assert.False(t, sharding.OwnsWorkspace(ownedByB))
+		now = now.Add(ownershipHandoffDelay)
+		assert.NoError(t, sharding.renewMemberLease(ctx))
+		assert.True(t, sharding.OwnsWorkspace(ownedByA))
+		assert.True(t, sharding.OwnsWorkspace(ownedByB))
+
+		// Nothing is owned once the membership Lease of this replica may have expired
+		now = now.Add(2 * memberLeaseDuration)
+		assert.False(t, sharding.OwnsWorkspace(ownedByA))
+		assert.False(t, sharding.IsCoordinator())
+	})
+
+	t.Run("Lost workspaces are no longer owned immediately", func(t *testing.T) {
+
+		now := time.Now()
+		sharding := newTestWorkspaceSharding(t, "replica-a", &now)
+
+		ownedByB := findWorkspace(t, []string{"replica-a", "replica-b"}, "replica-b")
+
+		assert.NoError(t, sharding.renewMemberLease(ctx))
+		_, err := sharding.refreshMembership(ctx, log)
+		assert.NoError(t, err)
+		now = now.Add(ownershipHandoffDelay)
+		assert.NoError(t, sharding.renewMemberLease(ctx))
+		assert.True(t, sharding.OwnsWorkspace(ownedByB))
+
+		assert.NoError(t, sharding.k8sClient.Create(ctx, newTestMemberLease("replica-b", now)))
+		changed, err := sharding.refreshMembership(ctx, log)
+		assert.NoError(t, err)
+		assert.True(t, changed)
+		assert.False(t, sharding.OwnsWorkspace(ownedByB))
+
+		// The membership Lease is deleted when leaving the shard group
+		assert.NoError(t, sharding.deleteMemberLease(ctx))
+		var leaseList coordinationv1.LeaseList
+		assert.NoError(t, sharding.k8sClient.List(ctx, &leaseList))
+		assert.Len(t, leaseList.Items, 1)
+		assert.Equal(t, "replica-b", *leaseList.Items[0].Spec.HolderIdentity)
+	})
+}
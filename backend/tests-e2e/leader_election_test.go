@@ -0,0 +1,241 @@
+//go:build e2e
+// +build e2e
+
+package e2e
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	sharedutil "github.com/redhat-appstudio/managed-gitops/backend-shared/util"
+	managedgitopsv1alpha1 "github.com/redhat-appstudio/managed-gitops/backend/apis/managed-gitops/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// These tests run against a cluster on which the GitOps Service has been installed (for example, with
+// 'make install-all-k8s'), using the current kubeconfig context. They are excluded from 'go test ./...' by the 'e2e'
+// build tag: run them with 'make test-e2e'.
+
+const (
+	// backendLeaderElectionID is the name of the Lease used by the backend for leader election, see backend/main.go
+	backendLeaderElectionID = "5a3f596c.redhat.com"
+
+	backendDeploymentName = "managed-gitops-backend-service"
+
+	e2eTestNamespace = "gitops-e2e-leader-election"
+
+	e2eTimeout = 5 * time.Minute
+)
+
+func getGitOpsNamespace() string {
+	if namespace := os.Getenv("GITOPS_NAMESPACE"); namespace != "" {
+		return namespace
+	}
+	return "gitops"
+}
+
+func getE2EClient(t *testing.T) client.Client {
+
+	config, err := sharedutil.GetRESTConfig()
+	if err != nil {
+		t.Fatalf("unable to get kubeconfig: %v", err)
+	}
+
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatalf("unable to add scheme: %v", err)
+	}
+	if err := managedgitopsv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatalf("unable to add scheme: %v", err)
+	}
+
+	k8sClient, err := client.New(config, client.Options{Scheme: scheme})
+	if err != nil {
+		t.Fatalf("unable to create client: %v", err)
+	}
+
+	return k8sClient
+}
+
+// getBackendPods returns the running backend pods, and the subset of those that are ready.
+func getBackendPods(ctx context.Context, k8sClient client.Client, deployment *appsv1.Deployment) ([]corev1.Pod, []corev1.Pod, error) {
+
+	var podList corev1.PodList
+	if err := k8sClient.List(ctx, &podList, client.InNamespace(deployment.Namespace),
+		client.MatchingLabels(deployment.Spec.Selector.MatchLabels)); err != nil {
+		return nil, nil, err
+	}
+
+	running := []corev1.Pod{}
+	ready := []corev1.Pod{}
+	for _, pod := range podList.Items {
+		if pod.DeletionTimestamp != nil || pod.Status.Phase != corev1.PodRunning {
+			continue
+		}
+		running = append(running, pod)
+
+		for _, condition := range pod.Status.Conditions {
+			if condition.Type == corev1.PodReady && condition.Status == corev1.ConditionTrue {
+				ready = append(ready, pod)
+			}
+		}
+	}
+
+	return running, ready, nil
+}
+
+// waitForSingleLeader waits until two backend pods are running, exactly one of which is ready and holds the leader
+// election Lease, and returns the name of that pod.
+func waitForSingleLeader(t *testing.T, ctx context.Context, k8sClient client.Client, deployment *appsv1.Deployment, previousLeader string) string {
+
+	leader := ""
+
+	err := wait.PollImmediate(2*time.Second, e2eTimeout, func() (bool, error) {
+
+		running, ready, err := getBackendPods(ctx, k8sClient, deployment)
+		if err != nil {
+			return false, err
+		}
+		if len(running) != 2 || len(ready) != 1 || ready[0].Name == previousLeader {
+			return false, nil
+		}
+
+		lease := coordinationv1.Lease{}
+		if err := k8sClient.Get(ctx, client.ObjectKey{Namespace: deployment.Namespace, Name: backendLeaderElectionID}, &lease); err != nil {
+			if apierr.IsNotFound(err) {
+				return false, nil
+			}
+			return false, err
+		}
+
+		// The identity of the leader is the pod name, followed by a unique suffix
+		if lease.Spec.HolderIdentity == nil || !strings.HasPrefix(*lease.Spec.HolderIdentity, ready[0].Name+"_") {
+			return false, nil
+		}
+
+		leader = ready[0].Name
+		return true, nil
+	})
+	if err != nil {
+		t.Fatalf("timed out waiting for a single ready backend pod, holding the leader election Lease: %v", err)
+	}
+
+	return leader
+}
+
+// waitForGitOpsDeploymentProcessed waits until the status of the GitOpsDeployment has been updated by the backend.
+func waitForGitOpsDeploymentProcessed(t *testing.T, ctx context.Context, k8sClient client.Client, gitopsDepl *managedgitopsv1alpha1.GitOpsDeployment) {
+
+	err := wait.PollImmediate(2*time.Second, e2eTimeout, func() (bool, error) {
+		if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(gitopsDepl), gitopsDepl); err != nil {
+			return false, err
+		}
+		return gitopsDepl.Status.Sync.Status != "", nil
+	})
+	if err != nil {
+		t.Fatalf("timed out waiting for GitOpsDeployment '%s' to be processed: %v", gitopsDepl.Name, err)
+	}
+}
+
+func newE2EGitOpsDeployment(name string) *managedgitopsv1alpha1.GitOpsDeployment {
+	return &managedgitopsv1alpha1.GitOpsDeployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: e2eTestNamespace,
+		},
+		Spec: managedgitopsv1alpha1.GitOpsDeploymentSpec{
+			Source: managedgitopsv1alpha1.ApplicationSource{
+				RepoURL: "https://github.com/redhat-appstudio/gitops-repository-template",
+				Path:    "environments/overlays/dev",
+			},
+			Type: managedgitopsv1alpha1.GitOpsDeploymentSpecType_Automated,
+		},
+	}
+}
+
+// TestBackendLeaderElection runs two replicas of the backend, and verifies that only the leader is ready, and that the
+// standby replica takes over processing events once the leader is deleted.
+func TestBackendLeaderElection(t *testing.T) {
+
+	ctx := context.Background()
+	k8sClient := getE2EClient(t)
+
+	deployment := &appsv1.Deployment{}
+	if err := k8sClient.Get(ctx, client.ObjectKey{Namespace: getGitOpsNamespace(), Name: backendDeploymentName}, deployment); err != nil {
+		t.Fatalf("unable to retrieve backend deployment: %v", err)
+	}
+
+	// Scale the backend to two replicas, and restore the original number of replicas afterwards
+	originalReplicas := deployment.Spec.Replicas
+	setReplicas := func(replicas *int32) error {
+		patch := client.MergeFrom(deployment.DeepCopy())
+		deployment.Spec.Replicas = replicas
+		return k8sClient.Patch(ctx, deployment, patch)
+	}
+	twoReplicas := int32(2)
+	if err := setReplicas(&twoReplicas); err != nil {
+		t.Fatalf("unable to scale backend deployment: %v", err)
+	}
+	defer func() {
+		if err := setReplicas(originalReplicas); err != nil {
+			t.Errorf("unable to restore backend deployment replicas: %v", err)
+		}
+	}()
+
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: e2eTestNamespace}}
+	if err := k8sClient.Create(ctx, namespace); err != nil && !apierr.IsAlreadyExists(err) {
+		t.Fatalf("unable to create test namespace: %v", err)
+	}
+	defer func() {
+		if err := k8sClient.Delete(ctx, namespace); err != nil && !apierr.IsNotFound(err) {
+			t.Errorf("unable to delete test namespace: %v", err)
+		}
+	}()
+
+	leader := waitForSingleLeader(t, ctx, k8sClient, deployment, "")
+	t.Logf("backend leader is '%s'", leader)
+
+	// The leader processes events
+	firstGitOpsDepl := newE2EGitOpsDeployment("first-gitops-depl")
+	assert.NoError(t, k8sClient.Create(ctx, firstGitOpsDepl))
+	defer func() {
+		assert.NoError(t, client.IgnoreNotFound(k8sClient.Delete(ctx, firstGitOpsDepl)))
+	}()
+	waitForGitOpsDeploymentProcessed(t, ctx, k8sClient, firstGitOpsDepl)
+
+	// Delete the leader: the standby replica should become the leader (and be the only ready replica), once the
+	// replacement pod of the deleted leader is running.
+	if err := k8sClient.Delete(ctx, &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: deployment.Namespace, Name: leader}}); err != nil {
+		t.Fatalf("unable to delete leader pod: %v", err)
+	}
+
+	newLeader := waitForSingleLeader(t, ctx, k8sClient, deployment, leader)
+	t.Logf("backend leader is now '%s'", newLeader)
+
+	// The new leader processes events for new resources...
+	secondGitOpsDepl := newE2EGitOpsDeployment("second-gitops-depl")
+	assert.NoError(t, k8sClient.Create(ctx, secondGitOpsDepl))
+	defer func() {
+		assert.NoError(t, client.IgnoreNotFound(k8sClient.Delete(ctx, secondGitOpsDepl)))
+	}()
+	waitForGitOpsDeploymentProcessed(t, ctx, k8sClient, secondGitOpsDepl)
+
+	// ... and for resources that were processed by the previous leader: once the status is cleared, it is updated again
+	// by the new leader.
+	assert.NoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(firstGitOpsDepl), firstGitOpsDepl))
+	firstGitOpsDepl.Status = managedgitopsv1alpha1.GitOpsDeploymentStatus{}
+	assert.NoError(t, k8sClient.Status().Update(ctx, firstGitOpsDepl))
+	waitForGitOpsDeploymentProcessed(t, ctx, k8sClient, firstGitOpsDepl)
+}
@@ -18,6 +18,7 @@ package argoprojio
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	apierr "k8s.io/apimachinery/pkg/api/errors"
@@ -25,6 +26,7 @@ import (
 	appv1 "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
 	"github.com/go-logr/logr"
 	"github.com/redhat-appstudio/managed-gitops/backend-shared/config/db"
+	dbutil "github.com/redhat-appstudio/managed-gitops/backend-shared/config/db/util"
 	sharedutil "github.com/redhat-appstudio/managed-gitops/backend-shared/util"
 	"github.com/redhat-appstudio/managed-gitops/cluster-agent/controllers"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -111,6 +113,10 @@ func (r *ApplicationReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 			applicationState.Sync_Status = db.TruncateVarchar(string(app.Status.Sync.Status), db.ApplicationstateSyncstatusLength)
 			applicationState.Revision = db.TruncateVarchar(app.Status.Sync.Revision, db.ApplicationstateRevisionLength)
 			sanitizeHealthAndStatus(applicationState)
+			if err := setConditionsAndOperationState(applicationState, app); err != nil {
+				log.Error(err, "unable to convert Application conditions for application state")
+				return ctrl.Result{}, err
+			}
 
 			if err := r.DB.CreateApplicationState(ctx, applicationState); err != nil {
 				log.Error(err, "unexpected error on writing new application state")
@@ -126,14 +132,31 @@ func (r *ApplicationReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 	}
 
 	// 4) ApplicationState already exists, so just update it.
+	// - The ApplicationState may be concurrently updated (for example, by another cluster-agent replica): on conflict,
+	//   re-read the ApplicationState, and update it again.
 
-	applicationState.Health = db.TruncateVarchar(string(app.Status.Health.Status), db.ApplicationstateHealthLength)
-	applicationState.Message = db.TruncateVarchar(app.Status.Health.Message, db.ApplicationstateMessageLength)
-	applicationState.Sync_Status = db.TruncateVarchar(string(app.Status.Sync.Status), db.ApplicationstateSyncstatusLength)
-	applicationState.Revision = db.TruncateVarchar(app.Status.Sync.Revision, db.ApplicationstateRevisionLength)
-	sanitizeHealthAndStatus(applicationState)
+	firstAttempt := true
+	if err := dbutil.RetryOnConflict(ctx, func() error {
 
-	if err := r.DB.UpdateApplicationState(ctx, applicationState); err != nil {
+		if !firstAttempt {
+			if err := r.DB.GetApplicationStateById(ctx, applicationState); err != nil {
+				return err
+			}
+		}
+		firstAttempt = false
+
+		applicationState.Health = db.TruncateVarchar(string(app.Status.Health.Status), db.ApplicationstateHealthLength)
+		applicationState.Message = db.TruncateVarchar(app.Status.Health.Message, db.ApplicationstateMessageLength)
+		applicationState.Sync_Status = db.TruncateVarchar(string(app.Status.Sync.Status), db.ApplicationstateSyncstatusLength)
+		applicationState.Revision = db.TruncateVarchar(app.Status.Sync.Revision, db.ApplicationstateRevisionLength)
+		sanitizeHealthAndStatus(applicationState)
+		if err := setConditionsAndOperationState(applicationState, app); err != nil {
+			return fmt.Errorf("unable to convert Application conditions for application state: %w", err)
+		}
+
+		return r.DB.UpdateApplicationState(ctx, applicationState)
+
+	}); err != nil {
 		log.Error(err, "unexpected error on updating existing application state")
 		return ctrl.Result{}, err
 	}
@@ -154,6 +177,34 @@ func sanitizeHealthAndStatus(applicationState *db.ApplicationState) {
 
 }
 
+// setConditionsAndOperationState copies the conditions (for example, ComparisonError, InvalidSpecError, SyncError) and the
+// operation state of the Argo CD Application into the ApplicationState: these are what users need to know when a deployment is stuck.
+func setConditionsAndOperationState(applicationState *db.ApplicationState, app appv1.Application) error {
+
+	conditions := []db.ApplicationStateCondition{}
+	for _, condition := range app.Status.Conditions {
+		conditions = append(conditions, db.ApplicationStateCondition{
+			Type:    condition.Type,
+			Message: condition.Message,
+		})
+	}
+
+	conditionsJSON, err := db.EncodeApplicationStateConditions(conditions)
+	if err != nil {
+		return err
+	}
+	applicationState.Conditions = conditionsJSON
+
+	applicationState.Operation_phase = ""
+	applicationState.Operation_message = ""
+	if app.Status.OperationState != nil {
+		applicationState.Operation_phase = db.TruncateVarchar(string(app.Status.OperationState.Phase), db.ApplicationstateOperationPhaseLength)
+		applicationState.Operation_message = db.TruncateVarchar(app.Status.OperationState.Message, db.ApplicationstateOperationMessageLength)
+	}
+
+	return nil
+}
+
 type applicationDeleteTask struct {
 	applicationCR appv1.Application
 	client        client.Client
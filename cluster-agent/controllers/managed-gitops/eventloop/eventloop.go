@@ -13,17 +13,29 @@ import (
 	dbutil "github.com/redhat-appstudio/managed-gitops/backend-shared/config/db/util"
 	sharedutil "github.com/redhat-appstudio/managed-gitops/backend-shared/util"
 	"github.com/redhat-appstudio/managed-gitops/cluster-agent/controllers"
+	"github.com/redhat-appstudio/managed-gitops/cluster-agent/utils"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	apierr "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/yaml"
 )
 
+const (
+	// syncOperationTerminateTimeout is the amount of time to wait for a running sync operation to terminate, after termination is requested.
+	syncOperationTerminateTimeout = 2 * time.Minute
+)
+
+// operationStatePollInterval is how often the database row of an operation is checked while the operation is being
+// processed, to cancel the processing once the operation is cancelled or timed out, see 'cancelTaskWhenOperationStops'.
+var operationStatePollInterval = 5 * time.Second
+
 type ControllerEventLoop struct {
 	eventLoopInputChannel chan controllerEventLoopEvent
 }
@@ -60,6 +72,13 @@ func controllerEventLoopRouter(input chan controllerEventLoopEvent) {
 
 	taskRetryLoop := sharedutil.NewTaskRetryLoop("cluster-agent")
 
+	// The client pool (and the credential service it uses) is shared between all tasks
+	argoCDClientPool := utils.NewArgoCDClientPool(utils.NewCredentialService(nil, false))
+
+	// The timings of the operations being processed are shared between all tasks, as an operation may be processed by
+	// more than one task (for example, after cancellation)
+	operationTimings := dbutil.NewOperationTimings()
+
 	log.Info("controllerEventLoopRouter started")
 
 	for {
@@ -73,6 +92,11 @@ func controllerEventLoopRouter(input chan controllerEventLoopEvent) {
 				request: newEvent.request,
 				client:  newEvent.client,
 			},
+			argoCDClientPool: argoCDClientPool,
+			operationTimings: operationTimings,
+			cancelTask: func() {
+				taskRetryLoop.CancelTaskIfPresent(mapKey)
+			},
 			log: log,
 		}
 		taskRetryLoop.AddTaskIfNotPresent(mapKey, task, sharedutil.ExponentialBackoff{Factor: 2, Min: time.Millisecond * 200, Max: time.Second * 10, Jitter: true})
@@ -82,11 +106,22 @@ func controllerEventLoopRouter(input chan controllerEventLoopEvent) {
 }
 
 type processEventTask struct {
-	event controllerEventLoopEvent
-	log   logr.Logger
+	event            controllerEventLoopEvent
+	argoCDClientPool *utils.ArgoCDClientPool
+	operationTimings *dbutil.OperationTimings
+
+	// cancelTask cancels the task in the task retry loop: the task is not retried, and PerformTask then moves the
+	// operation to its final state.
+	cancelTask func()
+
+	log logr.Logger
 }
 
 func (task *processEventTask) PerformTask(taskContext context.Context) (bool, error) {
+
+	// If this is the first attempt at processing the operation, its processing started now
+	attemptStarted := time.Now()
+
 	dbQueries, err := db.NewProductionPostgresDBQueries(true)
 	if err != nil {
 		task.log.Error(err, "unable to instantiate database")
@@ -96,14 +131,43 @@ func (task *processEventTask) PerformTask(taskContext context.Context) (bool, er
 	// Process the event
 	dbOperation, shouldRetry, err := task.internalPerformTask(taskContext, dbQueries)
 
+	// If the task was cancelled (see 'controllerEventLoopRouter'), the task context can no longer be used to update the operation
+	updateContext := taskContext
+	if taskContext.Err() != nil {
+		updateContext = context.Background()
+	}
+
 	if dbOperation != nil {
 
+		// The operation may have timed out, or its cancellation may have been requested, while it was being processed,
+		// so the latest copy of the operation is used to determine the new state.
+		latestOperation := db.Operation{Operation_id: dbOperation.Operation_id}
+		if err := dbQueries.GetOperationById(updateContext, &latestOperation); err != nil {
+			task.log.Error(err, "unable to retrieve latest operation state", "operation", dbOperation.Operation_id)
+			return true, err
+		}
+		dbOperation = &latestOperation
+
 		// Don't update the status of operations that have previously completed.
-		if dbOperation.State == db.OperationState_Completed || dbOperation.State == db.OperationState_Failed {
+		if db.IsOperationStateComplete(dbOperation.State) {
+			// The operation was completed elsewhere (for example, it was timed out by the backend), which reports it
+			task.operationTimings.Completed(dbOperation.Operation_id)
+
+			// The status of the CR may not yet reflect the completed operation (for example, the previous update failed)
+			updateOperationCRStatus(updateContext, task.event.client, task.event.request.NamespacedName, *dbOperation, task.log)
 			return false, err
 		}
 
-		if shouldRetry {
+		task.operationTimings.StartedProcessing(dbOperation.Operation_id, attemptStarted)
+
+		if dbOperation.Cancel_requested {
+			// Cancelled: the result of the task (if it ran) is ignored.
+			dbOperation.State = db.OperationState_Cancelled
+			dbOperation.Human_readable_state = "operation was cancelled"
+			shouldRetry = false
+			err = nil
+
+		} else if shouldRetry {
 			// Not complete, still (re)trying.
 			dbOperation.State = db.OperationState_In_Progress
 		} else {
@@ -119,19 +183,114 @@ func (task *processEventTask) PerformTask(taskContext context.Context) (bool, er
 
 		if err != nil {
 			// TODO: GITOPS-1717 - SECURITY - At some point, we will likely want to sanitize the error value for users
-			dbOperation.Human_readable_state = err.Error()
+			dbOperation.Human_readable_state = db.TruncateVarchar(err.Error(), db.OperationHumanReadableStateLength)
 		}
 
-		if err := dbQueries.UpdateOperation(taskContext, dbOperation); err != nil {
+		if err := dbQueries.UpdateOperation(updateContext, dbOperation); err != nil {
 			task.log.Error(err, "unable to update operation state", "operation", dbOperation.Operation_id)
 			return true, err
 		}
+
+		if db.IsOperationStateComplete(dbOperation.State) {
+			dbutil.ReportOperationCompleted(*dbOperation, task.operationTimings.Completed(dbOperation.Operation_id))
+		} else if shouldRetry && err != nil {
+			// The operation moves to (or remains in) the In_Progress state, as its processing failed and will be retried
+			dbutil.ReportOperationRetried(*dbOperation)
+		}
+
+		updateOperationCRStatus(updateContext, task.event.client, task.event.request.NamespacedName, *dbOperation, task.log)
 	}
 
 	return shouldRetry, err
 
 }
 
+// cancelTaskWhenOperationStops polls the database row of the operation until the context is cancelled, and cancels the
+// task once cancellation of the operation is requested, or the operation is completed elsewhere (for example, it was
+// timed out by the backend). PerformTask then updates the state of the operation from the database row.
+func (task *processEventTask) cancelTaskWhenOperationStops(ctx context.Context, operationID string, dbQueries db.DatabaseQueries) {
+
+	ticker := time.NewTicker(operationStatePollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		latestOperation := db.Operation{Operation_id: operationID}
+		if err := dbQueries.GetOperationById(ctx, &latestOperation); err != nil {
+			// The task itself handles an operation that no longer exists, and other errors are retried on the next poll
+			continue
+		}
+
+		if latestOperation.Cancel_requested || db.IsOperationStateComplete(latestOperation.State) {
+			task.log.Info("Operation was cancelled or completed while it was being processed, so its processing is cancelled",
+				"operation", operationID, "state", latestOperation.State)
+			task.cancelTask()
+			return
+		}
+	}
+}
+
+// updateOperationCRStatus mirrors the state of the Operation database row into the status of the Operation CR.
+//
+// The database row is the source of truth for the state of the operation, so a failure to update the CR is only logged,
+// and the status will instead be updated the next time the operation is processed.
+func updateOperationCRStatus(ctx context.Context, k8sClient client.Client, operationCRName types.NamespacedName, dbOperation db.Operation, log logr.Logger) {
+
+	operationCR := &operation.Operation{}
+	if err := k8sClient.Get(ctx, operationCRName, operationCR); err != nil {
+		if !apierr.IsNotFound(err) {
+			log.Error(err, "unable to retrieve Operation CR, to update its status", "operation", dbOperation.Operation_id)
+		}
+		return
+	}
+
+	// Only update the status of the CR that corresponds to the database row
+	if operationCR.Spec.OperationID != dbOperation.Operation_id {
+		return
+	}
+
+	newStatus := newOperationStatus(dbOperation, operationCR.Generation)
+	if equality.Semantic.DeepEqual(operationCR.Status, newStatus) {
+		return
+	}
+
+	operationCR.Status = newStatus
+	if err := k8sClient.Status().Update(ctx, operationCR); err != nil {
+		log.Error(err, "unable to update status of Operation CR", "operation", dbOperation.Operation_id)
+		return
+	}
+
+	log.V(sharedutil.LogLevel_Debug).Info("updated status of Operation CR", "operation", dbOperation.Operation_id, "state", newStatus.State)
+}
+
+// newOperationStatus returns the Operation CR status that corresponds to the given Operation database row.
+func newOperationStatus(dbOperation db.Operation, observedGeneration int64) operation.OperationStatus {
+
+	res := operation.OperationStatus{
+		State:              operation.OperationState(dbOperation.State),
+		Message:            dbOperation.Human_readable_state,
+		ObservedGeneration: observedGeneration,
+	}
+
+	// Times are truncated to the precision that is stored by the K8s API, so that they are comparable with the existing status
+	if !dbOperation.Created_on.IsZero() {
+		createdAt := metav1.NewTime(dbOperation.Created_on.Truncate(time.Second))
+		res.CreatedAt = &createdAt
+	}
+
+	if !dbOperation.Last_state_update.IsZero() {
+		lastStateUpdate := metav1.NewTime(dbOperation.Last_state_update.Truncate(time.Second))
+		res.LastStateUpdate = &lastStateUpdate
+	}
+
+	return res
+}
+
 func (task *processEventTask) internalPerformTask(taskContext context.Context, dbQueries db.DatabaseQueries) (*db.Operation, bool, error) {
 	log := log.FromContext(taskContext)
 
@@ -175,10 +334,22 @@ func (task *processEventTask) internalPerformTask(taskContext context.Context, d
 	}
 
 	// If the operation has already completed (we previously ran it), then just ignore it and return
-	if dbOperation.State == db.OperationState_Completed || dbOperation.State == db.OperationState_Failed {
+	if db.IsOperationStateComplete(dbOperation.State) {
 		return &dbOperation, false, nil
 	}
 
+	// If cancellation of the operation was requested, don't process it: the caller will move it to the Cancelled state.
+	if dbOperation.Cancel_requested {
+		log.Info("Cancellation of operation was requested, so it will not be processed", "operation", dbOperation.Operation_id)
+		return &dbOperation, false, nil
+	}
+
+	// Processing may take a while (for example, waiting for a sync to complete), so stop if the operation is cancelled
+	// or timed out in the meantime.
+	watchContext, stopWatching := context.WithCancel(taskContext)
+	defer stopWatching()
+	go task.cancelTaskWhenOperationStops(watchContext, dbOperation.Operation_id, dbQueries)
+
 	// 3) Find the Argo CD instance that is targeted by this operation.
 	dbGitopsEngineInstance := &db.GitopsEngineInstance{
 		Gitopsengineinstance_id: dbOperation.Instance_id,
@@ -200,6 +371,9 @@ func (task *processEventTask) internalPerformTask(taskContext context.Context, d
 
 	// Sanity test: find the gitops engine cluster, by kube-system, and ensure that the
 	// gitopsengineinstance matches the gitops engine cluster we are running on.
+	// - The backend creates Operation CRs on the cluster of the targeted Argo CD instance, but multiple engine clusters
+	//   share the same database: if the operation targets another cluster, it is left for the cluster-agent of that
+	//   cluster to process, and thus the database entry is not updated.
 	kubeSystemNamespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-system", Namespace: "kube-system"}}
 	if err := eventClient.Get(taskContext, client.ObjectKeyFromObject(kubeSystemNamespace), kubeSystemNamespace); err != nil {
 		log.Error(err, "SEVERE: Unable to retrieve kube-system namespace")
@@ -209,11 +383,13 @@ func (task *processEventTask) internalPerformTask(taskContext context.Context, d
 		log.Error(err, "Unable to retrieve GitOpsEngineCluster when processing Operation")
 		return &dbOperation, true, err
 	} else if thisCluster == nil {
-		log.Error(err, "GitOpsEngineCluster could not be found when processing Operation")
-		return &dbOperation, true, nil
+		log.Error(nil, "GitOpsEngineCluster could not be found when processing Operation, so the operation is not processed by this cluster-agent",
+			"operation", dbOperation.Operation_id)
+		return nil, false, nil
 	} else if thisCluster.Gitopsenginecluster_id != dbGitopsEngineInstance.EngineCluster_id {
-		log.Error(nil, "SEVERE: The gitops engine cluster that the cluster-agent is running on did not match the operation's target argo cd instance id.")
-		return &dbOperation, true, nil
+		log.V(sharedutil.LogLevel_Warn).Info("The operation targets an Argo CD instance on a different gitops engine cluster, so it is not processed by this cluster-agent",
+			"operation", dbOperation.Operation_id, "thisCluster", thisCluster.Gitopsenginecluster_id, "targetCluster", dbGitopsEngineInstance.EngineCluster_id)
+		return nil, false, nil
 	}
 
 	// 4) Find the namespace for the targeted Argo CD instance
@@ -224,7 +400,7 @@ func (task *processEventTask) internalPerformTask(taskContext context.Context, d
 		},
 	}
 	if err := eventClient.Get(taskContext, client.ObjectKeyFromObject(argoCDNamespace), argoCDNamespace); err != nil {
-		if db.IsResultNotFoundError(err) {
+		if apierr.IsNotFound(err) {
 			log.Error(err, "Argo CD namespace doesn't exist")
 			// no corresponding db operation, so no work to do
 			return &dbOperation, false, nil
@@ -250,6 +426,15 @@ func (task *processEventTask) internalPerformTask(taskContext context.Context, d
 
 		return &dbOperation, shouldRetry, err
 
+	} else if dbOperation.Resource_type == db.OperationResourceType_SyncOperation {
+		shouldRetry, err := processOperation_SyncOperation(taskContext, dbOperation, dbQueries, *argoCDNamespace, eventClient, task.argoCDClientPool, log)
+
+		if err != nil {
+			log.Error(err, "error occurred on processing the sync operation")
+		}
+
+		return &dbOperation, shouldRetry, err
+
 	} else {
 		log.Error(nil, "SEVERE: unrecognized resource type: "+dbOperation.Resource_type)
 		return &dbOperation, false, nil
@@ -257,6 +442,101 @@ func (task *processEventTask) internalPerformTask(taskContext context.Context, d
 
 }
 
+// processOperation_SyncOperation handles an Operation that targets a SyncOperation: the Argo CD Application is synchronized
+// (or, the sync is terminated), and the result of the sync is stored in the SyncOperation row. Returns true if the task
+// should be retried (eg due to failure).
+func processOperation_SyncOperation(ctx context.Context, dbOperation db.Operation, dbQueries db.DatabaseQueries,
+	argoCDNamespace corev1.Namespace, eventClient client.Client, argoCDClientPool *utils.ArgoCDClientPool, log logr.Logger) (bool, error) {
+
+	// Sanity check
+	if dbOperation.Resource_id == "" {
+		return true, fmt.Errorf("resource id was nil while processing operation: " + dbOperation.Operation_id)
+	}
+
+	dbSyncOperation := &db.SyncOperation{
+		SyncOperation_id: dbOperation.Resource_id,
+	}
+	if err := dbQueries.GetSyncOperationById(ctx, dbSyncOperation); err != nil {
+		if db.IsResultNotFoundError(err) {
+			// The sync operation no longer exists, so there is no work to do
+			log.V(sharedutil.LogLevel_Warn).Info("Received operation for sync operation DB entry that doesn't exist: " + dbSyncOperation.SyncOperation_id)
+			return false, nil
+		}
+		log.Error(err, "unable to retrieve sync operation", "syncOperation", dbSyncOperation.SyncOperation_id)
+		return true, err
+	}
+
+	if dbSyncOperation.Application_id == "" {
+		// The application was deleted, so there is nothing to sync
+		log.Info("sync operation no longer references an application, so no work to do", "syncOperation", dbSyncOperation.SyncOperation_id)
+		return false, nil
+	}
+
+	dbApplication := &db.Application{
+		Application_id: dbSyncOperation.Application_id,
+	}
+	if err := dbQueries.GetApplicationById(ctx, dbApplication); err != nil {
+		if db.IsResultNotFoundError(err) {
+			log.Info("application referenced by sync operation no longer exists, so no work to do", "application", dbApplication.Application_id)
+			return false, nil
+		}
+		log.Error(err, "unable to retrieve application of sync operation", "application", dbApplication.Application_id)
+		return true, err
+	}
+
+	log = log.WithValues("app.Name", dbApplication.Name, "syncOperation", dbSyncOperation.SyncOperation_id)
+
+	if dbSyncOperation.DesiredState == db.SyncOperation_DesiredState_Terminated {
+
+		if err := utils.TerminateOperation(ctx, dbApplication.Name, argoCDNamespace, argoCDClientPool, eventClient,
+			syncOperationTerminateTimeout, log); err != nil {
+			log.Error(err, "unable to terminate sync operation")
+			return true, err
+		}
+
+		return false, nil
+	}
+
+	syncResult, err := utils.AppSync(ctx, dbApplication.Name, dbSyncOperation.Revision, argoCDNamespace.Name, eventClient, argoCDClientPool)
+	if err != nil {
+		// The sync could not be requested or observed (eg Argo CD is unavailable), so retry.
+		log.Error(err, "unable to sync application")
+		return true, err
+	}
+
+	// Store the result of the sync, so that it may be reported back to the user.
+	resourcesJSON, err := syncResult.ResourcesJSON()
+	if err != nil {
+		log.Error(err, "unable to convert sync resource results to JSON")
+		resourcesJSON = ""
+	}
+
+	dbSyncOperation.SyncResult_phase = db.TruncateVarchar(string(syncResult.Phase), db.SyncOperationSyncResultPhaseLength)
+	dbSyncOperation.SyncResult_message = db.TruncateVarchar(syncResult.Message, db.SyncOperationSyncResultMessageLength)
+	dbSyncOperation.SyncResult_pruning_required = syncResult.PruningRequired
+	if len(resourcesJSON) > db.SyncOperationSyncResultResourcesLength {
+		// A truncated JSON string would be invalid, so don't store the resources if they don't fit
+		log.Info("sync resource results were too large to store", "length", len(resourcesJSON))
+		resourcesJSON = ""
+	}
+	dbSyncOperation.SyncResult_resources = resourcesJSON
+
+	if err := dbQueries.UpdateSyncOperation(ctx, dbSyncOperation); err != nil {
+		log.Error(err, "unable to update sync operation with the sync result")
+		return true, err
+	}
+
+	if syncResult.Successful() {
+		log.Info("sync operation completed successfully")
+		return false, nil
+	}
+
+	// The sync completed, but was not successful: this is reported to the user via the Operation state
+	resultErr := fmt.Errorf("sync operation did not succeed: phase: %s, message: %s", syncResult.Phase, syncResult.Message)
+
+	return syncResult.ShouldRetry(), resultErr
+}
+
 // processOperation_Application handles an Operation that targets an Application. Returns true if the task should be retried (eg due to failure).
 func processOperation_Application(ctx context.Context, dbOperation db.Operation, crOperation operation.Operation, dbQueries db.DatabaseQueries,
 	argoCDNamespace corev1.Namespace, eventClient client.Client, log logr.Logger) (bool, error) {
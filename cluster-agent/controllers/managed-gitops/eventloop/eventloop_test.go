@@ -0,0 +1,147 @@
+package eventloop
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	operation "github.com/redhat-appstudio/managed-gitops/backend-shared/apis/managed-gitops/v1alpha1"
+	"github.com/redhat-appstudio/managed-gitops/backend-shared/config/db"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestUpdateOperationCRStatus(t *testing.T) {
+
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	if !assert.NoError(t, operation.AddToScheme(scheme)) {
+		return
+	}
+
+	operationCR := &operation.Operation{
+		ObjectMeta: metav1.ObjectMeta{Name: "operation-test-op", Namespace: "argocd", Generation: 2},
+		Spec:       operation.OperationSpec{OperationID: "test-op"},
+	}
+
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(operationCR).Build()
+
+	createdOn := time.Date(2022, time.March, 1, 10, 0, 0, 0, time.UTC)
+
+	dbOperation := db.Operation{
+		Operation_id:         "test-op",
+		Created_on:           createdOn,
+		Last_state_update:    createdOn.Add(5 * time.Second),
+		State:                db.OperationState_Failed,
+		Human_readable_state: "unable to sync application",
+	}
+
+	updateOperationCRStatus(ctx, k8sClient, client.ObjectKeyFromObject(operationCR), dbOperation, log.FromContext(ctx))
+
+	updatedCR := &operation.Operation{}
+	if !assert.NoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(operationCR), updatedCR)) {
+		return
+	}
+
+	assert.Equal(t, operation.OperationState_Failed, updatedCR.Status.State)
+	assert.True(t, updatedCR.Status.IsComplete())
+	assert.Equal(t, "unable to sync application", updatedCR.Status.Message)
+	assert.Equal(t, int64(2), updatedCR.Status.ObservedGeneration)
+	if assert.NotNil(t, updatedCR.Status.CreatedAt) && assert.NotNil(t, updatedCR.Status.LastStateUpdate) {
+		assert.True(t, createdOn.Equal(updatedCR.Status.CreatedAt.Time))
+		assert.True(t, createdOn.Add(5*time.Second).Equal(updatedCR.Status.LastStateUpdate.Time))
+	}
+
+	// The status of a CR for a different operation should not be updated
+	otherOperation := dbOperation
+	otherOperation.Operation_id = "other-op"
+	otherOperation.State = db.OperationState_Completed
+
+	updateOperationCRStatus(ctx, k8sClient, client.ObjectKeyFromObject(operationCR), otherOperation, log.FromContext(ctx))
+
+	if assert.NoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(operationCR), updatedCR)) {
+		assert.Equal(t, operation.OperationState_Failed, updatedCR.Status.State)
+	}
+}
+
+func TestCancelTaskWhenOperationStops(t *testing.T) {
+
+	defaultPollInterval := operationStatePollInterval
+	operationStatePollInterval = time.Millisecond
+	defer func() {
+		operationStatePollInterval = defaultPollInterval
+	}()
+
+	for _, test := range []struct {
+		name      string
+		operation db.Operation
+	}{
+		{name: "cancellation was requested", operation: db.Operation{State: db.OperationState_In_Progress, Cancel_requested: true}},
+		{name: "the operation was timed out by the backend", operation: db.Operation{State: db.OperationState_Timeout}},
+	} {
+		t.Run("The task is cancelled when "+test.name, func(t *testing.T) {
+
+			dbOperation := test.operation
+			dbOperation.Operation_id = "test-op"
+			dbQueries := &operationStateTestQueries{operation: dbOperation}
+
+			cancelled := make(chan struct{})
+			task := &processEventTask{
+				cancelTask: func() { close(cancelled) },
+				log:        log.FromContext(context.Background()),
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go task.cancelTaskWhenOperationStops(ctx, dbOperation.Operation_id, dbQueries)
+
+			select {
+			case <-cancelled:
+			case <-time.After(5 * time.Second):
+				t.Fatal("the task was not cancelled")
+			}
+		})
+	}
+
+	t.Run("The task is not cancelled while the operation is in progress", func(t *testing.T) {
+
+		dbQueries := &operationStateTestQueries{operation: db.Operation{Operation_id: "test-op", State: db.OperationState_In_Progress}}
+
+		task := &processEventTask{
+			cancelTask: func() { t.Error("the task should not be cancelled") },
+			log:        log.FromContext(context.Background()),
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		stopped := make(chan struct{})
+		go func() {
+			task.cancelTaskWhenOperationStops(ctx, "test-op", dbQueries)
+			close(stopped)
+		}()
+
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+		<-stopped
+	})
+}
+
+// operationStateTestQueries implements the subset of DatabaseQueries that is used by 'cancelTaskWhenOperationStops':
+// calls to any other function will panic.
+type operationStateTestQueries struct {
+	db.DatabaseQueries
+
+	operation db.Operation
+}
+
+func (dbq *operationStateTestQueries) GetOperationById(ctx context.Context, dbOperation *db.Operation) error {
+	if dbOperation.Operation_id != dbq.operation.Operation_id {
+		return db.NewResultNotFoundError("operation")
+	}
+	*dbOperation = dbq.operation
+	return nil
+}
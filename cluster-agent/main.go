@@ -38,6 +38,7 @@ import (
 	argoprojiocontrollers "github.com/redhat-appstudio/managed-gitops/cluster-agent/controllers/argoproj.io"
 	controllers "github.com/redhat-appstudio/managed-gitops/cluster-agent/controllers/managed-gitops"
 	"github.com/redhat-appstudio/managed-gitops/cluster-agent/controllers/managed-gitops/eventloop"
+	"github.com/redhat-appstudio/managed-gitops/cluster-agent/utils"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -118,6 +119,12 @@ func main() {
 	}
 	//+kubebuilder:scaffold:builder
 
+	// Periodically record a heartbeat in the database, so that the backend knows the cluster-agent is running
+	if err := mgr.Add(utils.NewHeartbeatRunner(mgr.GetClient(), applicationReconcileDB, utils.NewCredentialService(nil, false))); err != nil {
+		setupLog.Error(err, "unable to add heartbeat runner")
+		os.Exit(1)
+	}
+
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up health check")
 		os.Exit(1)
@@ -0,0 +1,419 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	argocdclient "github.com/argoproj/argo-cd/v2/pkg/apiclient"
+	applicationpkg "github.com/argoproj/argo-cd/v2/pkg/apiclient/application"
+	argoappv1 "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
+	argoio "github.com/argoproj/argo-cd/v2/util/io"
+	sharedutil "github.com/redhat-appstudio/managed-gitops/backend-shared/util"
+	"golang.org/x/sync/singleflight"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// ArgoCDClientPool maintains a single, long-lived, connection to the GRPC API of each Argo CD instance (GitOpsEngineInstance),
+// which is shared by all sync/terminate operations that target that instance. Previously, each operation would open (at least)
+// two new connections to argocd-server: one for the operation itself, and one to watch the Application.
+//
+// For each Argo CD instance, the pool also maintains a single Application watch (across all Applications in the instance),
+// the events of which are multiplexed to any callers that are waiting on the status of an individual Application.
+//
+// The pool is safe to call from multiple threads.
+type ArgoCDClientPool struct {
+	credentialService *CredentialService
+
+	// logins ensures that only a single login is in progress for each Argo CD instance: the login is not performed
+	// while holding the mutex, so that a slow Argo CD instance does not block the callers of other instances.
+	logins singleflight.Group
+
+	mutex sync.Mutex
+	// clients is the pooled client for each Argo CD instance, keyed by argoCDInstanceKey(...)
+	clients map[string]*PooledArgoCDClient
+}
+
+// NewArgoCDClientPool creates a new pool, which will use the given credential service to log in to Argo CD instances.
+func NewArgoCDClientPool(credentialService *CredentialService) *ArgoCDClientPool {
+	return &ArgoCDClientPool{
+		credentialService: credentialService,
+		clients:           map[string]*PooledArgoCDClient{},
+	}
+}
+
+func argoCDInstanceKey(argocdNamespace corev1.Namespace) string {
+	return argocdNamespace.Name + "-" + string(argocdNamespace.UID)
+}
+
+// GetClient returns the pooled client for the Argo CD instance in the given namespace, logging in and creating a new
+// connection if one does not already exist.
+func (pool *ArgoCDClientPool) GetClient(ctx context.Context, argocdNamespace corev1.Namespace, k8sClient client.Client) (*PooledArgoCDClient, error) {
+
+	key := argoCDInstanceKey(argocdNamespace)
+
+	if pooledClient := pool.getPooledClient(key); pooledClient != nil {
+		return pooledClient, nil
+	}
+
+	// Concurrent callers for the same Argo CD instance share the result of a single login
+	res, err, _ := pool.logins.Do(key, func() (interface{}, error) {
+
+		// The client may have been created by a login that completed since the check above
+		if pooledClient := pool.getPooledClient(key); pooledClient != nil {
+			return pooledClient, nil
+		}
+
+		_, acdClient, err := pool.credentialService.GetArgoCDLoginCredentials(ctx, argocdNamespace.Name, string(argocdNamespace.UID), false, k8sClient)
+		if err != nil {
+			return nil, err
+		}
+
+		pooledClient, err := newPooledArgoCDClient(acdClient)
+		if err != nil {
+			return nil, err
+		}
+
+		pool.mutex.Lock()
+		defer pool.mutex.Unlock()
+		pool.clients[key] = pooledClient
+
+		return pooledClient, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res.(*PooledArgoCDClient), nil
+}
+
+func (pool *ArgoCDClientPool) getPooledClient(key string) *PooledArgoCDClient {
+
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+
+	return pool.clients[key]
+}
+
+// InvalidateIfConnectionError will close and remove the pooled client of the Argo CD instance, if the given error indicates
+// that the connection (or the session token used by the connection) is no longer valid. The next call to GetClient will
+// create a new connection.
+func (pool *ArgoCDClientPool) InvalidateIfConnectionError(argocdNamespace corev1.Namespace, err error) {
+
+	if err == nil {
+		return
+	}
+
+	if code := status.Code(err); code != codes.Unavailable && code != codes.Unauthenticated {
+		return
+	}
+
+	pool.Invalidate(argocdNamespace)
+}
+
+// Invalidate closes and removes the pooled client of the Argo CD instance, if it exists.
+func (pool *ArgoCDClientPool) Invalidate(argocdNamespace corev1.Namespace) {
+
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+
+	key := argoCDInstanceKey(argocdNamespace)
+
+	if pooledClient, exists := pool.clients[key]; exists {
+		pooledClient.close()
+		delete(pool.clients, key)
+	}
+}
+
+// PooledArgoCDClient is a long-lived connection to the Application GRPC API of an Argo CD instance.
+type PooledArgoCDClient struct {
+	conn  io.Closer
+	appIf applicationpkg.ApplicationServiceClient
+
+	watcher *applicationEventWatcher
+}
+
+func newPooledArgoCDClient(acdClient argocdclient.Client) (*PooledArgoCDClient, error) {
+
+	conn, appIf, err := acdClient.NewApplicationClient()
+	if err != nil {
+		return nil, fmt.Errorf("unable to create application client: %v", err)
+	}
+
+	return &PooledArgoCDClient{
+		conn:    conn,
+		appIf:   appIf,
+		watcher: newApplicationEventWatcher(appIf),
+	}, nil
+}
+
+// ApplicationClient returns the Application GRPC API client; the underlying connection is shared, and should not be closed by the caller.
+func (pc *PooledArgoCDClient) ApplicationClient() applicationpkg.ApplicationServiceClient {
+	return pc.appIf
+}
+
+// SubscribeToApplication returns a subscription to the watch events of the given Argo CD Application. The caller must call
+// 'unsubscribe' on the subscription once it is no longer needed.
+//
+// The events channel of the subscription is closed if the watch ends before the subscriber unsubscribes (for example,
+// because the client was removed from the pool): see 'applicationEventSubscription.Err'.
+func (pc *PooledArgoCDClient) SubscribeToApplication(appName string) *applicationEventSubscription {
+	return pc.watcher.subscribe(appName)
+}
+
+func (pc *PooledArgoCDClient) close() {
+	pc.watcher.stop()
+	argoio.Close(pc.conn)
+}
+
+const (
+	// applicationEventSubscriptionBufferSize is the number of events that are buffered for a slow subscriber: once the
+	// buffer is full, the oldest event is dropped in favour of the newest. Subscribers only care about the most recent
+	// state of the Application, so this is safe, and ensures a slow subscriber does not block the shared watch.
+	applicationEventSubscriptionBufferSize = 10
+
+	// applicationWatchMaxConsecutiveFailures is the number of consecutive attempts to (re)establish the watch that may
+	// fail, before the subscriptions are ended: the subscribers would otherwise wait for events that never arrive.
+	applicationWatchMaxConsecutiveFailures = 5
+)
+
+// errApplicationWatchStopped is the error of the subscriptions that were ended because the watch was stopped
+var errApplicationWatchStopped = errors.New("the Argo CD application watch was stopped")
+
+// applicationEventWatcher multiplexes a single watch, across all the Applications of an Argo CD instance, to subscribers
+// that are each interested in a single Application.
+//
+// The watch is only active while there is at least one subscriber. If the watch stream fails, it is re-established
+// (with backoff): on reconnect, Argo CD re-sends the current state of every Application, so no updates are lost. If the
+// watch cannot be re-established, or is stopped, the subscriptions are ended (see 'endSubscriptions').
+type applicationEventWatcher struct {
+	appIf applicationpkg.ApplicationServiceClient
+
+	mutex sync.Mutex
+
+	// subscriptions is keyed by Application name
+	subscriptions map[string]map[*applicationEventSubscription]bool
+
+	// cancelWatch stops the watch goroutine; it is nil if the watch is not running.
+	cancelWatch context.CancelFunc
+
+	// stopped is true once the watcher has been stopped: it can then no longer be subscribed to.
+	stopped bool
+}
+
+type applicationEventSubscription struct {
+	appName string
+	events  chan *argoappv1.ApplicationWatchEvent
+	watcher *applicationEventWatcher
+
+	// err is the reason the subscription was ended: it is set before 'events' is closed.
+	err error
+}
+
+// Events returns the channel on which Application events are received. The channel is closed if the subscription is
+// ended by the watcher, see Err.
+func (sub *applicationEventSubscription) Events() <-chan *argoappv1.ApplicationWatchEvent {
+	return sub.events
+}
+
+// Err returns the reason the subscription was ended by the watcher: it should only be called once the events channel
+// has been closed.
+func (sub *applicationEventSubscription) Err() error {
+	return sub.err
+}
+
+func (sub *applicationEventSubscription) unsubscribe() {
+	sub.watcher.unsubscribe(sub)
+}
+
+func newApplicationEventWatcher(appIf applicationpkg.ApplicationServiceClient) *applicationEventWatcher {
+	return &applicationEventWatcher{
+		appIf:         appIf,
+		subscriptions: map[string]map[*applicationEventSubscription]bool{},
+	}
+}
+
+func (w *applicationEventWatcher) subscribe(appName string) *applicationEventSubscription {
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	sub := &applicationEventSubscription{
+		appName: appName,
+		events:  make(chan *argoappv1.ApplicationWatchEvent, applicationEventSubscriptionBufferSize),
+		watcher: w,
+	}
+
+	if w.stopped {
+		sub.err = errApplicationWatchStopped
+		close(sub.events)
+		return sub
+	}
+
+	appSubs, exists := w.subscriptions[appName]
+	if !exists {
+		appSubs = map[*applicationEventSubscription]bool{}
+		w.subscriptions[appName] = appSubs
+	}
+	appSubs[sub] = true
+
+	// Start the watch on first subscription
+	if w.cancelWatch == nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		w.cancelWatch = cancel
+		go w.runWatch(ctx)
+	}
+
+	return sub
+}
+
+func (w *applicationEventWatcher) unsubscribe(sub *applicationEventSubscription) {
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if appSubs, exists := w.subscriptions[sub.appName]; exists {
+		delete(appSubs, sub)
+		if len(appSubs) == 0 {
+			delete(w.subscriptions, sub.appName)
+		}
+	}
+
+	// Stop the watch once there are no more subscribers
+	if len(w.subscriptions) == 0 && w.cancelWatch != nil {
+		w.cancelWatch()
+		w.cancelWatch = nil
+	}
+}
+
+// stop stops the watch, regardless of whether there are any remaining subscribers: the remaining subscriptions are
+// ended, and no further subscriptions are accepted.
+func (w *applicationEventWatcher) stop() {
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.stopped = true
+	w.endSubscriptions(errApplicationWatchStopped)
+}
+
+// endSubscriptions stops the watch, and ends all the subscriptions with the given error, by closing their events
+// channel. New subscriptions restart the watch, unless the watcher has been stopped.
+//
+// The caller must hold the mutex.
+func (w *applicationEventWatcher) endSubscriptions(err error) {
+
+	if w.cancelWatch != nil {
+		w.cancelWatch()
+		w.cancelWatch = nil
+	}
+
+	for _, appSubs := range w.subscriptions {
+		for sub := range appSubs {
+			sub.err = err
+			close(sub.events)
+		}
+	}
+	w.subscriptions = map[string]map[*applicationEventSubscription]bool{}
+}
+
+// endSubscriptionsOfWatch ends the subscriptions with the given error, unless the watch of the given context has
+// already been stopped.
+func (w *applicationEventWatcher) endSubscriptionsOfWatch(ctx context.Context, err error) {
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	// The context is cancelled as soon as the watch is stopped, so the subscriptions may belong to a newer watch
+	if ctx.Err() != nil {
+		return
+	}
+
+	w.endSubscriptions(err)
+}
+
+func (w *applicationEventWatcher) runWatch(ctx context.Context) {
+
+	log := log.FromContext(ctx)
+
+	backoff := sharedutil.ExponentialBackoff{Factor: 2, Min: time.Duration(200 * time.Millisecond), Max: time.Duration(10 * time.Second), Jitter: true}
+
+	// consecutiveFailures is the number of attempts to (re)establish the watch that did not receive any event
+	consecutiveFailures := 0
+
+	for {
+
+		// An empty resource version ensures that Argo CD sends the current state of all Applications, on each (re)connect.
+		watchClient, err := w.appIf.Watch(ctx, &applicationpkg.ApplicationQuery{})
+		if err == nil {
+			for {
+				var appEvent *argoappv1.ApplicationWatchEvent
+				appEvent, err = watchClient.Recv()
+				if err != nil {
+					break
+				}
+				backoff.Reset()
+				consecutiveFailures = 0
+				w.dispatch(appEvent)
+			}
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err == io.EOF {
+			err = fmt.Errorf("the Argo CD application watch was closed by the server")
+		}
+
+		consecutiveFailures++
+		if consecutiveFailures >= applicationWatchMaxConsecutiveFailures {
+			log.Error(err, "Argo CD application watch could not be re-established, so its subscriptions are ended")
+			w.endSubscriptionsOfWatch(ctx, err)
+			return
+		}
+
+		log.Error(err, "Argo CD application watch failed, reconnecting")
+
+		backoff.DelayOnFail(ctx)
+	}
+}
+
+// dispatch sends the event to all subscribers of the Application: the send never blocks, instead the oldest buffered
+// event is dropped if a subscriber is not keeping up.
+func (w *applicationEventWatcher) dispatch(appEvent *argoappv1.ApplicationWatchEvent) {
+
+	if appEvent == nil {
+		return
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	for sub := range w.subscriptions[appEvent.Application.Name] {
+
+		select {
+		case sub.events <- appEvent:
+			continue
+		default:
+		}
+
+		// The buffer is full, so drop the oldest event, and try again
+		select {
+		case <-sub.events:
+		default:
+		}
+
+		select {
+		case sub.events <- appEvent:
+		default:
+		}
+	}
+}
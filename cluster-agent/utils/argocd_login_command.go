@@ -13,27 +13,15 @@ import (
 
 // This file is loosely based on the 'argocd login' CLI command (https://github.com/argoproj/argo-cd/blob/0a46d37fc6af9fe0aa963bdd845e3d799aa0320d/cmd/argocd/commands/login.go#L60)
 
-func generateDefaultClientForServerAddress(server string, optionalAuthToken string, skipTLSTest bool) (argocdclient.Client, error) {
-
-	globalClientOpts := argocdclient.ClientOptions{
-		ConfigPath:           "",
-		ServerAddr:           server,
-		Insecure:             true, // TODO: Create a generic security story
-		PlainText:            false,
-		ClientCertFile:       "",
-		ClientCertKeyFile:    "",
-		GRPCWeb:              false,
-		GRPCWebRootPath:      "",
-		PortForward:          false,
-		PortForwardNamespace: "",
-		Headers:              []string{},
+func generateDefaultClientForServerAddress(server string, optionalAuthToken string, tlsSettings argoCDTLSSettings, skipTLSTest bool) (argocdclient.Client, error) {
+
+	clientOpts, cleanup, err := tlsSettings.generateClientOptions(server, optionalAuthToken)
+	if err != nil {
+		return nil, err
 	}
+	defer cleanup()
 
-	if globalClientOpts.PortForward {
-		server = "port-forward"
-	} else if globalClientOpts.Core {
-		server = "kubernetes"
-	} else if skipTLSTest {
+	if skipTLSTest {
 		// skip test
 	} else {
 		tlsTestResult, err := grpc_util.TestTLS(server)
@@ -41,31 +29,19 @@ func generateDefaultClientForServerAddress(server string, optionalAuthToken stri
 			return nil, err
 		}
 		if !tlsTestResult.TLS {
-			if !globalClientOpts.PlainText {
+			if !clientOpts.PlainText {
 				return nil, fmt.Errorf("server is not configured with TLS")
 			}
 		} else if tlsTestResult.InsecureErr != nil {
-			if !globalClientOpts.Insecure {
+			// The TLS test only verifies against the system CA bundle: when a CA is provided, the server certificate
+			// is instead verified against that CA, when the client connects.
+			if !clientOpts.Insecure && clientOpts.CertFile == "" {
 				return nil, fmt.Errorf("WARNING: server certificate had error: %s", tlsTestResult.InsecureErr)
 			}
 		}
 	}
-	clientOpts := argocdclient.ClientOptions{
-		ConfigPath:           "",
-		ServerAddr:           server,
-		AuthToken:            optionalAuthToken,
-		Insecure:             globalClientOpts.Insecure,
-		PlainText:            globalClientOpts.PlainText,
-		ClientCertFile:       globalClientOpts.ClientCertFile,
-		ClientCertKeyFile:    globalClientOpts.ClientCertKeyFile,
-		GRPCWeb:              globalClientOpts.GRPCWeb,
-		GRPCWebRootPath:      globalClientOpts.GRPCWebRootPath,
-		PortForward:          globalClientOpts.PortForward,
-		PortForwardNamespace: globalClientOpts.PortForwardNamespace,
-		Headers:              globalClientOpts.Headers,
-	}
 
-	acdClient, err := argocdclient.NewClient(&clientOpts)
+	acdClient, err := argocdclient.NewClient(clientOpts)
 
 	return acdClient, err
 
@@ -24,6 +24,7 @@ import (
 //
 // Behind the scenes, the CredentialsService will:
 // - locate the Argo CD admin secret, and login using that secret
+// - read the TLS settings of the Argo CD instance (see 'argoCDTLSSettings'), which are used for all GRPC connections to that instance
 // - create a new session based on that secret, storing the session key in an cache
 // - returning credentials, and an Argo CD client instance, to the caller
 //
@@ -71,8 +72,8 @@ func (cs *CredentialService) GetArgoCDLoginCredentials(ctx context.Context, name
 type defaultClientGenerator struct {
 }
 
-func (dcg *defaultClientGenerator) generateClientForServerAddress(server string, optionalAuthToken string, skipTLSTest bool) (argocdclient.Client, error) {
-	return generateDefaultClientForServerAddress(server, optionalAuthToken, false)
+func (dcg *defaultClientGenerator) generateClientForServerAddress(server string, optionalAuthToken string, tlsSettings argoCDTLSSettings, skipTLSTest bool) (argocdclient.Client, error) {
+	return generateDefaultClientForServerAddress(server, optionalAuthToken, tlsSettings, skipTLSTest)
 }
 
 // NewCredentialService is used to create a new instance of the Credential service.
@@ -133,12 +134,24 @@ func (cs *CredentialService) credentialHandler(input chan credentialRequest) {
 
 		var resp credentialResponse
 
+		// The TLS settings are re-read on every request, so that changes to them take effect without a restart
+		tlsSettings, err := getArgoCDTLSSettings(req.ctx, req.namespaceName, req.k8sClient)
+		if err != nil {
+			req.output <- credentialResponse{err: err}
+			continue
+		}
+
 		// Return value from the cache, if it exists (as long as the cache is not invalidated)
 		cacheValue, exists := credentials[credentialsKey]
+		if exists && !cacheValue.tlsSettings.equals(tlsSettings) {
+			log.Info("Argo CD TLS settings have changed, so reacquiring login for " + req.namespaceName)
+			exists = false
+		}
+
 		if exists && !req.skipCache {
 			_, err := sharedutil.CatchPanic(func() error {
 				// Verify the cached login still works
-				acdClient, err := cs.testLogin(req.ctx, cacheValue.ServerAddress, cacheValue.Passsword)
+				acdClient, err := cs.testLogin(req.ctx, cacheValue.ServerAddress, cacheValue.Passsword, tlsSettings)
 				if err != nil {
 					log.Error(err, "cached login was invalid, so invalidating and reacquiring.")
 					exists = false
@@ -161,7 +174,7 @@ func (cs *CredentialService) credentialHandler(input chan credentialRequest) {
 
 			_, err := sharedutil.CatchPanic(func() error {
 
-				creds, acdClient, err := cs.getCredentialsFromNamespace(req, cs.skipTLSTest, log.WithValues("request namespace", req.namespaceName))
+				creds, acdClient, err := cs.getCredentialsFromNamespace(req, tlsSettings, cs.skipTLSTest, log.WithValues("request namespace", req.namespaceName))
 				if err != nil {
 					resp = credentialResponse{
 						err: err,
@@ -191,7 +204,7 @@ func (cs *CredentialService) credentialHandler(input chan credentialRequest) {
 
 }
 
-func (cs *CredentialService) getCredentialsFromNamespace(req credentialRequest, skipTLSTest bool, log logr.Logger) (*argoCDCredentials, argocdclient.Client, error) {
+func (cs *CredentialService) getCredentialsFromNamespace(req credentialRequest, tlsSettings argoCDTLSSettings, skipTLSTest bool, log logr.Logger) (*argoCDCredentials, argocdclient.Client, error) {
 
 	var err error
 	var argoCDAdminPasswords []string
@@ -205,9 +218,9 @@ func (cs *CredentialService) getCredentialsFromNamespace(req credentialRequest,
 		return nil, nil, fmt.Errorf("no Argo CD admin passwords found in " + req.namespaceName)
 	}
 
-	// Retrieve the Argo CD host name from the Route
-	serverHostName := ""
-	{
+	// Retrieve the Argo CD host name from the TLS settings, or from the Route if not specified there
+	serverHostName := tlsSettings.serverAddress
+	if serverHostName == "" {
 
 		routeList := &routev1.RouteList{}
 
@@ -237,7 +250,7 @@ func (cs *CredentialService) getCredentialsFromNamespace(req credentialRequest,
 		return nil, nil, fmt.Errorf("Unable to locate Route in " + req.namespaceName)
 	}
 
-	acdClient, err := cs.acdClientGenerator.generateClientForServerAddress(serverHostName, "", skipTLSTest)
+	acdClient, err := cs.acdClientGenerator.generateClientForServerAddress(serverHostName, "", tlsSettings, skipTLSTest)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -252,10 +265,19 @@ func (cs *CredentialService) getCredentialsFromNamespace(req credentialRequest,
 		userToken, err := argoCDLoginCommand("admin", password, acdClient)
 
 		if err == nil && len(userToken) > 0 {
+
+			// The client used to login has no session token, so create a new client that uses the token: this client
+			// may be long-lived (see ArgoCDClientPool).
+			acdClient, err = cs.acdClientGenerator.generateClientForServerAddress(serverHostName, userToken, tlsSettings, skipTLSTest)
+			if err != nil {
+				return nil, nil, err
+			}
+
 			return &argoCDCredentials{
 				ServerAddress: serverHostName,
 				Username:      "admin",
 				Passsword:     userToken,
+				tlsSettings:   tlsSettings,
 			}, acdClient, nil
 		}
 
@@ -304,9 +326,9 @@ func getArgoCDAdminPasswords(ctx context.Context, namespace string, k8sClient cl
 }
 
 // Attempt to login using the login, to verify it is correct. This is useful for verifying cached logins.
-func (cs *CredentialService) testLogin(ctx context.Context, serverAddr string, authToken string) (argocdclient.Client, error) {
+func (cs *CredentialService) testLogin(ctx context.Context, serverAddr string, authToken string, tlsSettings argoCDTLSSettings) (argocdclient.Client, error) {
 
-	acdClient, err := cs.acdClientGenerator.generateClientForServerAddress(serverAddr, authToken, false)
+	acdClient, err := cs.acdClientGenerator.generateClientForServerAddress(serverAddr, authToken, tlsSettings, cs.skipTLSTest)
 	if err != nil {
 		return nil, fmt.Errorf("unable to create argocdclient: %v", err)
 	}
@@ -330,8 +352,11 @@ type argoCDCredentials struct {
 	ServerAddress string
 	Username      string
 	Passsword     string
+
+	// tlsSettings are the TLS settings that were used to acquire the credentials
+	tlsSettings argoCDTLSSettings
 }
 
 type clientGenerator interface {
-	generateClientForServerAddress(server string, optionalAuthToken string, skipTLSTest bool) (argocdclient.Client, error)
+	generateClientForServerAddress(server string, optionalAuthToken string, tlsSettings argoCDTLSSettings, skipTLSTest bool) (argocdclient.Client, error)
 }
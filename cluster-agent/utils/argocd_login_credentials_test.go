@@ -112,6 +112,6 @@ type mockClientGenerator struct {
 	mockClient argocdclient.Client
 }
 
-func (mcg *mockClientGenerator) generateClientForServerAddress(server string, optionalAuthToken string, skipTLSTest bool) (argocdclient.Client, error) {
+func (mcg *mockClientGenerator) generateClientForServerAddress(server string, optionalAuthToken string, tlsSettings argoCDTLSSettings, skipTLSTest bool) (argocdclient.Client, error) {
 	return mcg.mockClient, nil
 }
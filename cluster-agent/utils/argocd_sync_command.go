@@ -3,16 +3,13 @@ package utils
 import (
 	"context"
 	"fmt"
-	"log"
 	"reflect"
 	"sort"
 	"time"
 
-	argocdclient "github.com/argoproj/argo-cd/v2/pkg/apiclient"
 	applicationpkg "github.com/argoproj/argo-cd/v2/pkg/apiclient/application"
 	argoappv1 "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
 	"github.com/argoproj/argo-cd/v2/util/argo"
-	argoio "github.com/argoproj/argo-cd/v2/util/io"
 	"github.com/argoproj/gitops-engine/pkg/health"
 	"github.com/argoproj/gitops-engine/pkg/sync/common"
 	"github.com/argoproj/gitops-engine/pkg/utils/kube"
@@ -27,8 +24,11 @@ import (
 // https://github.com/argoproj/argo-cd/blob/0a46d37fc6af9fe0aa963bdd845e3d799aa0320d/cmd/argocd/commands/app.go#L1333
 
 // AppSync will trigger a synchronize application on the given Argo CD appliatication, in the given namespace.
+//
+// The SyncResult describes the outcome of the sync (including whether it failed): an error is only returned if
+// the sync could not be requested from, or observed on, the Argo CD instance.
 func AppSync(ctx context.Context, appName string, revision string, namespaceName string, k8sClient client.Client,
-	credentialsService *CredentialService, skipTLSTest bool) error {
+	clientPool *ArgoCDClientPool) (*SyncResult, error) {
 
 	namespace := &corev1.Namespace{
 		ObjectMeta: metav1.ObjectMeta{
@@ -39,32 +39,29 @@ func AppSync(ctx context.Context, appName string, revision string, namespaceName
 
 	err := k8sClient.Get(ctx, client.ObjectKeyFromObject(namespace), namespace)
 	if err != nil {
-		return fmt.Errorf("unable to retrieve namespace in AppSync: %s, %v", namespaceName, err)
+		return nil, fmt.Errorf("unable to retrieve namespace in AppSync: %s, %v", namespaceName, err)
 	}
 
-	_, acdClient, err := credentialsService.GetArgoCDLoginCredentials(ctx, namespaceName, string(namespace.UID), false, k8sClient)
+	pooledClient, err := clientPool.GetClient(ctx, *namespace, k8sClient)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	err = appSync(ctx, acdClient, appName, false, false, revision, false, "", false, false, 0, 0, 0, 0, 0)
+	syncResult, err := appSync(ctx, pooledClient, appName, false, false, revision, false, "", false, false, 0, 0, 0, 0, 0)
 	if err != nil {
-		return err
+		clientPool.InvalidateIfConnectionError(*namespace, err)
+		return nil, err
 	}
 
-	return nil
+	return syncResult, nil
 
 }
 
-func appSync(ctx context.Context, acdClient argocdclient.Client, appName string, dryRun bool, replace bool, revision string, prune bool,
+func appSync(ctx context.Context, pooledClient *PooledArgoCDClient, appName string, dryRun bool, replace bool, revision string, prune bool,
 	strategy string, force bool, async bool, timeout uint, retryLimit int64, retryBackoffDuration time.Duration,
-	retryBackoffMaxDuration time.Duration, retryBackoffFactor int64) error {
+	retryBackoffMaxDuration time.Duration, retryBackoffFactor int64) (*SyncResult, error) {
 
-	conn, appIf, err := acdClient.NewApplicationClient()
-	if err != nil {
-		return fmt.Errorf("unable to retrieve acd client: %v", err)
-	}
-	defer argoio.Close(conn)
+	appIf := pooledClient.ApplicationClient()
 
 	syncOptionsFactory := func() *applicationpkg.SyncOptions {
 		syncOptions := applicationpkg.SyncOptions{}
@@ -100,7 +97,10 @@ func appSync(ctx context.Context, acdClient argocdclient.Client, appName string,
 		syncReq.Strategy = &argoappv1.SyncStrategy{Hook: &argoappv1.SyncStrategyHook{}}
 		syncReq.Strategy.Hook.Force = force
 	default:
-		log.Fatalf("Unknown sync strategy: '%s'", strategy)
+		return &SyncResult{
+			Phase:   common.OperationError,
+			Message: fmt.Sprintf("unknown sync strategy: '%s'", strategy),
+		}, nil
 	}
 	if retryLimit > 0 {
 		syncReq.RetryStrategy = &argoappv1.RetryStrategy{
@@ -112,31 +112,40 @@ func appSync(ctx context.Context, acdClient argocdclient.Client, appName string,
 			},
 		}
 	}
-	_, err = appIf.Sync(ctx, &syncReq)
+	_, err := appIf.Sync(ctx, &syncReq)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if !async {
-		app, err := waitOnApplicationStatus(ctx, acdClient, appName, timeout, false, false, true, false, []argoappv1.SyncOperationResource{})
-		if err != nil {
-			return err
-		}
+	if async {
+		return &SyncResult{
+			Phase:   common.OperationRunning,
+			Message: "sync operation was requested",
+		}, nil
+	}
 
-		if !dryRun {
-			if !app.Status.OperationState.Phase.Successful() {
-				log.Fatalf("Operation has completed with phase: %s", app.Status.OperationState.Phase)
-			} else if /*len(selectedResources) == 0 &&*/ app.Status.Sync.Status != argoappv1.SyncStatusCodeSynced {
-				// Only get resources to be pruned if sync was application-wide and final status is not synced
-				pruningRequired := app.Status.OperationState.SyncResult.Resources.PruningRequired()
-				if pruningRequired > 0 {
-					log.Fatalf("%d resources require pruning", pruningRequired)
-				}
+	app, err := waitOnApplicationStatus(ctx, pooledClient, appName, timeout, false, false, true, false, []argoappv1.SyncOperationResource{})
+	if err != nil {
+		return nil, err
+	}
+
+	syncResult := newSyncResultFromApplication(app)
+
+	if !dryRun {
+		if !syncResult.Phase.Successful() {
+			if syncResult.Message == "" {
+				syncResult.Message = fmt.Sprintf("operation has completed with phase: %s", syncResult.Phase)
+			}
+		} else if /*len(selectedResources) == 0 &&*/ app.Status.Sync.Status != argoappv1.SyncStatusCodeSynced && app.Status.OperationState.SyncResult != nil {
+			// Only get resources to be pruned if sync was application-wide and final status is not synced
+			syncResult.PruningRequired = app.Status.OperationState.SyncResult.Resources.PruningRequired()
+			if syncResult.PruningRequired > 0 {
+				syncResult.Message = fmt.Sprintf("%d resources require pruning", syncResult.PruningRequired)
 			}
 		}
 	}
 
-	return nil
+	return &syncResult, nil
 }
 
 // ResourceDiff tracks the state of a resource when waiting on an application status.
@@ -174,13 +183,15 @@ func (rs *resourceState) merge(newState *resourceState) bool {
 	return updated
 }
 
-func waitOnApplicationStatus(parentContext context.Context, acdClient argocdclient.Client, appName string, timeout uint, watchSync bool,
+func waitOnApplicationStatus(parentContext context.Context, pooledClient *PooledArgoCDClient, appName string, timeout uint, watchSync bool,
 	watchHealth bool, watchOperation bool, watchSuspended bool,
 	selectedResources []argoappv1.SyncOperationResource) (*argoappv1.Application, error) {
 
 	ctx, cancel := context.WithCancel(parentContext)
 	defer cancel()
 
+	appClient := pooledClient.ApplicationClient()
+
 	// refresh controls whether or not we refresh the app before printing the final status.
 	// We only want to do this when an operation is in progress, since operations are the only
 	// time when the sync status lags behind when an operation completes
@@ -189,17 +200,11 @@ func waitOnApplicationStatus(parentContext context.Context, acdClient argocdclie
 	printFinalStatus := func(app *argoappv1.Application) (*argoappv1.Application, error) {
 		if refresh {
 			var err error
-			conn, appClient, err := acdClient.NewApplicationClient()
-			if err != nil {
-				return nil, err
-			}
-
 			refreshType := string(argoappv1.RefreshTypeNormal)
 			app, err = appClient.Get(context.Background(), &applicationpkg.ApplicationQuery{Name: &appName, Refresh: &refreshType})
 			if err != nil {
 				return nil, err
 			}
-			_ = conn.Close()
 		}
 
 		return app, nil
@@ -212,18 +217,20 @@ func waitOnApplicationStatus(parentContext context.Context, acdClient argocdclie
 	}
 
 	prevStates := make(map[string]*resourceState)
-	conn, appClient, err := acdClient.NewApplicationClient()
-	if err != nil {
-		return nil, err
-	}
-	defer argoio.Close(conn)
+
+	// Subscribe to the shared watch before retrieving the Application, so that no updates are missed in between.
+	subscription := pooledClient.SubscribeToApplication(appName)
+	defer subscription.unsubscribe()
+
 	app, err := appClient.Get(ctx, &applicationpkg.ApplicationQuery{Name: &appName})
 	if err != nil {
 		return nil, err
 	}
 
-	appEventCh := acdClient.WatchApplicationWithRetry(ctx, appName, app.ResourceVersion)
-	for appEvent := range appEventCh {
+	// The current state of the Application is processed first, followed by the events from the watch
+	nextEvent := &argoappv1.ApplicationWatchEvent{Application: *app}
+	for {
+		appEvent := nextEvent
 		app = &appEvent.Application
 
 		operationInProgress := false
@@ -280,8 +287,20 @@ func waitOnApplicationStatus(parentContext context.Context, acdClient argocdclie
 				prevStates[stateKey] = newState
 			}
 		}
+
+		var ok bool
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("timed out (%ds) waiting for app %q match desired state", timeout, appName)
+		case nextEvent, ok = <-subscription.Events():
+		}
+
+		if !ok {
+			// The watch has ended (for example, the connection to Argo CD was lost): the error is returned as is, so
+			// that the pooled client is invalidated if it is a connection error.
+			return nil, subscription.Err()
+		}
 	}
-	return nil, fmt.Errorf("timed out (%ds) waiting for app %q match desired state", timeout, appName)
 }
 
 func groupResourceStates(app *argoappv1.Application, selectedResources []argoappv1.SyncOperationResource) map[string]*resourceState {
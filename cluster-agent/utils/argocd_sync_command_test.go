@@ -104,7 +104,7 @@ func TestAppSync(t *testing.T) {
 		return *asr.Name == appName && asr.Revision == "master" && !asr.Prune
 	})).Return(nil, nil)
 
-	// 3) After Sync, a Get occurs for the app, then a watch is setup to wait for the sync operation to finish.
+	// 3) After Sync, a shared watch is setup and a Get occurs for the app, to wait for the sync operation to finish.
 	// We provide the post-sync version of the app, to both
 	mockAppServiceClient.On("Get", mock.Anything, &applicationpkg.ApplicationQuery{Name: &appName}).Return(app, nil)
 	awe := make(chan *appv1.ApplicationWatchEvent)
@@ -113,16 +113,129 @@ func TestAppSync(t *testing.T) {
 		awe <- &appv1.ApplicationWatchEvent{
 			Application: *app,
 		}
-		// After the above event is sent, close the channel
-		close(awe)
 	}()
-	mockAppClient.On("WatchApplicationWithRetry", mock.Anything, app.Name, mock.Anything).Return(awe)
+	mockAppServiceClient.On("Watch", mock.Anything, &applicationpkg.ApplicationQuery{}).Return(mockWatchClientFromChannel(awe), nil)
 
 	clientGenerator := mockClientGenerator{
 		mockClient: mockAppClient,
 	}
 
 	cs := NewCredentialService(&clientGenerator, true)
-	err = AppSync(context.Background(), appName, "master", "openshift-gitops", k8sClient, cs, true)
+	syncResult, err := AppSync(context.Background(), appName, "master", "openshift-gitops", k8sClient, NewArgoCDClientPool(cs))
 	assert.NoError(t, err)
+	if assert.NotNil(t, syncResult) {
+		assert.True(t, syncResult.Successful())
+	}
+}
+
+func TestAppSyncResult(t *testing.T) {
+
+	t.Parallel()
+
+	nowTime := metav1.Now()
+
+	generateApp := func(phase common.OperationPhase, message string, syncStatus appv1.SyncStatusCode, resources appv1.ResourceResults) *appv1.Application {
+		return &appv1.Application{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "my-app",
+				Namespace: "openshift-gitops",
+			},
+			Status: appv1.ApplicationStatus{
+				ReconciledAt: &nowTime,
+				Sync:         appv1.SyncStatus{Status: syncStatus},
+				OperationState: &appv1.OperationState{
+					Phase:      phase,
+					Message:    message,
+					FinishedAt: &nowTime,
+					SyncResult: &appv1.SyncOperationResult{Resources: resources},
+				},
+			},
+		}
+	}
+
+	testCases := []struct {
+		name                    string
+		strategy                string
+		app                     *appv1.Application
+		expectedPhase           common.OperationPhase
+		expectedMessage         string
+		expectedPruningRequired int
+		expectedResources       int
+		expectedSuccessful      bool
+	}{
+		{
+			name:               "successful sync",
+			app:                generateApp(common.OperationSucceeded, "successfully synced", appv1.SyncStatusCodeSynced, nil),
+			expectedPhase:      common.OperationSucceeded,
+			expectedMessage:    "successfully synced",
+			expectedSuccessful: true,
+		},
+		{
+			name:     "failed sync should return the failure, rather than exiting",
+			strategy: "apply",
+			app: generateApp(common.OperationFailed, "one or more objects failed to apply", appv1.SyncStatusCodeOutOfSync, appv1.ResourceResults{
+				{Kind: "ConfigMap", Name: "my-config-map", Namespace: "my-namespace", Status: common.ResultCodeSyncFailed, Message: "invalid"},
+				{Kind: "Deployment", Name: "my-deployment", Namespace: "my-namespace", Status: common.ResultCodeSynced},
+			}),
+			expectedPhase:     common.OperationFailed,
+			expectedMessage:   "one or more objects failed to apply",
+			expectedResources: 2,
+		},
+		{
+			name: "successful sync that requires pruning",
+			app: generateApp(common.OperationSucceeded, "successfully synced", appv1.SyncStatusCodeOutOfSync, appv1.ResourceResults{
+				{Kind: "ConfigMap", Name: "my-config-map", Namespace: "my-namespace", Status: common.ResultCodePruneSkipped},
+			}),
+			expectedPhase:           common.OperationSucceeded,
+			expectedMessage:         "1 resources require pruning",
+			expectedPruningRequired: 1,
+			expectedResources:       1,
+		},
+		{
+			name:            "unknown sync strategy",
+			strategy:        "unknown",
+			expectedPhase:   common.OperationError,
+			expectedMessage: "unknown sync strategy: 'unknown'",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+
+			mockAppServiceClient := &mocks.ApplicationServiceClient{}
+			mockAppClient := &mocks.Client{}
+			mockAppClient.On("NewApplicationClient").Return(mockCloser{}, mockAppServiceClient, nil)
+
+			appName := "my-app"
+			mockAppServiceClient.On("Sync", mock.Anything, mock.Anything).Return(nil, nil)
+			if testCase.app != nil {
+				mockAppServiceClient.On("Get", mock.Anything, &applicationpkg.ApplicationQuery{Name: &appName}).Return(testCase.app, nil)
+			}
+			mockAppServiceClient.On("Watch", mock.Anything, &applicationpkg.ApplicationQuery{}).
+				Return(mockWatchClientFromChannel(make(chan *appv1.ApplicationWatchEvent)), nil)
+
+			pooledClient, err := newPooledArgoCDClient(mockAppClient)
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer pooledClient.close()
+
+			syncResult, err := appSync(context.Background(), pooledClient, appName, false, false, "master", false, testCase.strategy,
+				false, false, 0, 0, 0, 0, 0)
+			if !assert.NoError(t, err) || !assert.NotNil(t, syncResult) {
+				return
+			}
+
+			assert.Equal(t, testCase.expectedPhase, syncResult.Phase)
+			assert.Equal(t, testCase.expectedMessage, syncResult.Message)
+			assert.Equal(t, testCase.expectedPruningRequired, syncResult.PruningRequired)
+			assert.Len(t, syncResult.Resources, testCase.expectedResources)
+			assert.Equal(t, testCase.expectedSuccessful, syncResult.Successful())
+			assert.False(t, syncResult.ShouldRetry())
+
+			if testCase.app == nil {
+				mockAppServiceClient.AssertNotCalled(t, "Sync", mock.Anything, mock.Anything)
+			}
+		})
+	}
 }
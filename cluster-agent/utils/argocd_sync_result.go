@@ -0,0 +1,108 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+
+	argoappv1 "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
+	"github.com/argoproj/gitops-engine/pkg/sync/common"
+)
+
+// SyncResult describes the outcome of a sync operation triggered by AppSync.
+//
+// A SyncResult is returned when Argo CD was able to process the sync request (even if the sync itself failed), while an error is
+// returned by AppSync when the request could not be made or observed (for example, the Argo CD instance was unreachable).
+type SyncResult struct {
+	// Phase is the phase of the Argo CD sync operation, for example: Succeeded, Failed, Error, Running
+	Phase common.OperationPhase `json:"phase"`
+
+	// Message is a human-readable description of the result
+	Message string `json:"message,omitempty"`
+
+	// Resources contains the sync result of each individual resource
+	Resources []SyncResourceResult `json:"resources,omitempty"`
+
+	// PruningRequired is the number of resources that must be pruned before the Application can be considered synced
+	PruningRequired int `json:"pruningRequired,omitempty"`
+}
+
+// SyncResourceResult is the result of syncing an individual resource, as reported by Argo CD.
+type SyncResourceResult struct {
+	Group     string `json:"group,omitempty"`
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+
+	// Status is the result of applying the resource, for example: Synced, SyncFailed, Pruned, PruneSkipped
+	Status string `json:"status,omitempty"`
+
+	// HookPhase is the phase of the resource, if it is a hook
+	HookPhase string `json:"hookPhase,omitempty"`
+
+	Message string `json:"message,omitempty"`
+}
+
+// Successful returns true if the sync completed successfully, and no resources require pruning.
+func (sr SyncResult) Successful() bool {
+	return sr.Phase.Successful() && sr.PruningRequired == 0
+}
+
+// ShouldRetry returns true if the sync operation had not yet completed when the result was generated, and thus the
+// caller should retry (wait on) the sync. Completed syncs that failed are not retried: Argo CD has its own retry
+// strategy for failing syncs, and these failures are instead reported to the user.
+func (sr SyncResult) ShouldRetry() bool {
+	return !sr.Phase.Completed()
+}
+
+// ResourcesJSON returns the per-resource results as a JSON list, for storage in the database.
+func (sr SyncResult) ResourcesJSON() (string, error) {
+
+	resources := sr.Resources
+	if resources == nil {
+		resources = []SyncResourceResult{}
+	}
+
+	jsonBytes, err := json.Marshal(resources)
+	if err != nil {
+		return "", fmt.Errorf("unable to marshal sync resource results: %v", err)
+	}
+
+	return string(jsonBytes), nil
+}
+
+// newSyncResultFromApplication generates a SyncResult from the operation state of the given Application.
+func newSyncResultFromApplication(app *argoappv1.Application) SyncResult {
+
+	if app.Status.OperationState == nil {
+		return SyncResult{
+			Phase:   common.OperationRunning,
+			Message: "application has no operation state",
+		}
+	}
+
+	operationState := app.Status.OperationState
+
+	res := SyncResult{
+		Phase:   operationState.Phase,
+		Message: operationState.Message,
+	}
+
+	if operationState.SyncResult != nil {
+		for _, resource := range operationState.SyncResult.Resources {
+			if resource == nil {
+				continue
+			}
+			res.Resources = append(res.Resources, SyncResourceResult{
+				Group:     resource.Group,
+				Kind:      resource.Kind,
+				Namespace: resource.Namespace,
+				Name:      resource.Name,
+				Status:    string(resource.Status),
+				HookPhase: string(resource.HookPhase),
+				Message:   resource.Message,
+			})
+		}
+	}
+
+	return res
+}
@@ -5,10 +5,8 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/argoproj/argo-cd/v2/pkg/apiclient"
 	applicationpkg "github.com/argoproj/argo-cd/v2/pkg/apiclient/application"
 	appv1 "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
-	argoio "github.com/argoproj/argo-cd/v2/util/io"
 	"github.com/argoproj/gitops-engine/pkg/sync/common"
 	"github.com/go-logr/logr"
 	sharedutil "github.com/redhat-appstudio/managed-gitops/backend-shared/util"
@@ -22,29 +20,24 @@ import (
 
 // TerminateOperation calls the Argo CD GRPC API to terminates a synchronize operation on an Argo CD Application, if one is running.
 func TerminateOperation(ctx context.Context, appName string, argocdNamespace corev1.Namespace,
-	credentialService *CredentialService,
+	clientPool *ArgoCDClientPool,
 	k8sClient client.Client, expireDuration time.Duration, log logr.Logger) error {
 
-	_, acdClient, err := credentialService.GetArgoCDLoginCredentials(ctx, argocdNamespace.Name,
-		string(argocdNamespace.UID), false, k8sClient)
-
+	pooledClient, err := clientPool.GetClient(ctx, argocdNamespace, k8sClient)
 	if err != nil {
 		return err
 	}
 
-	return terminateOperation(ctx, appName, argocdNamespace, acdClient, k8sClient, expireDuration, log)
+	err = terminateOperation(ctx, appName, argocdNamespace, pooledClient.ApplicationClient(), k8sClient, expireDuration, log)
+	clientPool.InvalidateIfConnectionError(argocdNamespace, err)
+
+	return err
 }
 
 func terminateOperation(ctx context.Context, appName string, argocdNamespace corev1.Namespace,
-	acdClient apiclient.Client, k8sClient client.Client, expireDuration time.Duration, log logr.Logger) error {
-
-	conn, appIf, err := acdClient.NewApplicationClient()
-	if err != nil {
-		return fmt.Errorf("unable to create application client for terminate operation: %v", err)
-	}
+	appIf applicationpkg.ApplicationServiceClient, k8sClient client.Client, expireDuration time.Duration, log logr.Logger) error {
 
-	defer argoio.Close(conn)
-	_, err = appIf.TerminateOperation(ctx, &applicationpkg.OperationTerminateRequest{Name: &appName})
+	_, err := appIf.TerminateOperation(ctx, &applicationpkg.OperationTerminateRequest{Name: &appName})
 	if err != nil {
 		return err
 	}
@@ -51,14 +51,11 @@ func TestTerminateOperation(t *testing.T) {
 
 	mockAppServiceClient := &mocks.ApplicationServiceClient{}
 
-	mockAppClient := &mocks.Client{}
-	mockAppClient.On("NewApplicationClient").Return(mockCloser{}, mockAppServiceClient, nil)
-
 	mockAppServiceClient.On("TerminateOperation", mock.Anything, &applicationpkg.OperationTerminateRequest{Name: &application.Name}).Return(nil, nil)
 
 	startTime := time.Now()
 
-	err = terminateOperation(context.Background(), application.Name, argoCDNamespace, mockAppClient, k8sClient,
+	err = terminateOperation(context.Background(), application.Name, argoCDNamespace, mockAppServiceClient, k8sClient,
 		time.Duration(2*time.Second), log.FromContext(context.Background()))
 
 	elapsedTime := time.Since(startTime)
@@ -130,9 +127,6 @@ func TestTerminateOperation_OperationGoesToDone(t *testing.T) {
 
 			mockAppServiceClient := &mocks.ApplicationServiceClient{}
 
-			mockAppClient := &mocks.Client{}
-			mockAppClient.On("NewApplicationClient").Return(mockCloser{}, mockAppServiceClient, nil)
-
 			mockAppServiceClient.On("TerminateOperation", mock.Anything, &applicationpkg.OperationTerminateRequest{Name: &application.Name}).Return(nil, nil)
 
 			go func() {
@@ -149,7 +143,7 @@ func TestTerminateOperation_OperationGoesToDone(t *testing.T) {
 
 			startTime := time.Now()
 
-			err = terminateOperation(context.Background(), application.Name, argoCDNamespace, mockAppClient, k8sClient, time.Duration(5*time.Second), log.FromContext(context.Background()))
+			err = terminateOperation(context.Background(), application.Name, argoCDNamespace, mockAppServiceClient, k8sClient, time.Duration(5*time.Second), log.FromContext(context.Background()))
 			if err != nil {
 				t.Error(err)
 				return
@@ -199,9 +193,6 @@ func TestTerminateOperation_ExitIfAppDoesntExist(t *testing.T) {
 
 	mockAppServiceClient := &mocks.ApplicationServiceClient{}
 
-	mockAppClient := &mocks.Client{}
-	mockAppClient.On("NewApplicationClient").Return(mockCloser{}, mockAppServiceClient, nil)
-
 	mockAppServiceClient.On("TerminateOperation", mock.Anything,
 		&applicationpkg.OperationTerminateRequest{Name: &application.Name}).Return(nil, nil)
 
@@ -216,7 +207,7 @@ func TestTerminateOperation_ExitIfAppDoesntExist(t *testing.T) {
 
 	startTime := time.Now()
 
-	err = terminateOperation(context.Background(), application.Name, argoCDNamespace, mockAppClient, k8sClient,
+	err = terminateOperation(context.Background(), application.Name, argoCDNamespace, mockAppServiceClient, k8sClient,
 		time.Duration(5*time.Second), log.FromContext(context.Background()))
 	if err != nil {
 		t.Error(err)
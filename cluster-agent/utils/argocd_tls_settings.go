@@ -0,0 +1,218 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	argocdclient "github.com/argoproj/argo-cd/v2/pkg/apiclient"
+	corev1 "k8s.io/api/core/v1"
+	apierr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// The TLS settings used to connect to an Argo CD instance (GitOpsEngineInstance) are read from a ConfigMap and/or Secret,
+// with the name below, in the namespace of the Argo CD instance. Both resources are optional: if neither exists, the Argo CD
+// server is expected to present a certificate that is trusted by the system CA bundle.
+//
+// ConfigMap keys:
+// - 'ca.crt': PEM-encoded CA bundle used to verify the Argo CD server certificate
+// - 'server-address': the address of the Argo CD server, e.g. 'openshift-gitops-server.openshift-gitops.svc:80'
+//     (optional: if not specified, the address is taken from the Argo CD server Route)
+// - 'insecure': 'true' to skip verification of the Argo CD server certificate
+// - 'plaintext': 'true' to connect without TLS (should only be used for in-cluster connections)
+// - 'grpc-web': 'true' to use gRPC-web rather than HTTP/2 gRPC
+// - 'grpc-web-root-path': the root path of the gRPC-web endpoint, if any
+//
+// Secret keys:
+// - 'ca.crt': PEM-encoded CA bundle (takes precedence over the ConfigMap value)
+// - 'tls.crt' and 'tls.key': PEM-encoded client certificate and key, used to authenticate to the Argo CD server
+const (
+	ArgoCDTLSSettingsResourceName = "gitops-service-argocd-tls"
+
+	argoCDTLSSettingsKey_CA              = "ca.crt"
+	argoCDTLSSettingsKey_ServerAddress   = "server-address"
+	argoCDTLSSettingsKey_Insecure        = "insecure"
+	argoCDTLSSettingsKey_PlainText       = "plaintext"
+	argoCDTLSSettingsKey_GRPCWeb         = "grpc-web"
+	argoCDTLSSettingsKey_GRPCWebRootPath = "grpc-web-root-path"
+	argoCDTLSSettingsKey_ClientCert      = corev1.TLSCertKey
+	argoCDTLSSettingsKey_ClientKey       = corev1.TLSPrivateKeyKey
+)
+
+// argoCDTLSSettings describes how the cluster-agent should connect to the GRPC API of a specific Argo CD instance.
+type argoCDTLSSettings struct {
+	// caData is the PEM-encoded CA bundle used to verify the server certificate (optional)
+	caData []byte
+
+	// clientCertData and clientKeyData are the PEM-encoded client certificate/key (optional, but must be specified together)
+	clientCertData []byte
+	clientKeyData  []byte
+
+	// serverAddress, if non-empty, overrides the address retrieved from the Argo CD Route
+	serverAddress string
+
+	// insecure skips verification of the server certificate: this is only enabled if explicitly requested
+	insecure bool
+
+	// plainText disables TLS altogether, for example when connecting to argocd-server within the cluster
+	plainText bool
+
+	grpcWeb         bool
+	grpcWebRootPath string
+}
+
+// equals returns true if both settings would produce an equivalent Argo CD client
+func (s argoCDTLSSettings) equals(other argoCDTLSSettings) bool {
+	return string(s.caData) == string(other.caData) &&
+		string(s.clientCertData) == string(other.clientCertData) &&
+		string(s.clientKeyData) == string(other.clientKeyData) &&
+		s.serverAddress == other.serverAddress &&
+		s.insecure == other.insecure &&
+		s.plainText == other.plainText &&
+		s.grpcWeb == other.grpcWeb &&
+		s.grpcWebRootPath == other.grpcWebRootPath
+}
+
+// getArgoCDTLSSettings retrieves the TLS settings of the Argo CD instance in 'namespaceName', from the ConfigMap/Secret
+// described above.
+func getArgoCDTLSSettings(ctx context.Context, namespaceName string, k8sClient client.Client) (argoCDTLSSettings, error) {
+
+	res := argoCDTLSSettings{}
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ArgoCDTLSSettingsResourceName,
+			Namespace: namespaceName,
+		},
+	}
+	if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(configMap), configMap); err != nil {
+		if !apierr.IsNotFound(err) {
+			return argoCDTLSSettings{}, fmt.Errorf("unable to retrieve Argo CD TLS configmap in %s: %v", namespaceName, err)
+		}
+	} else {
+
+		if val, exists := configMap.Data[argoCDTLSSettingsKey_CA]; exists {
+			res.caData = []byte(val)
+		}
+
+		res.serverAddress = configMap.Data[argoCDTLSSettingsKey_ServerAddress]
+		res.grpcWebRootPath = configMap.Data[argoCDTLSSettingsKey_GRPCWebRootPath]
+
+		boolFields := []struct {
+			key   string
+			field *bool
+		}{
+			{argoCDTLSSettingsKey_Insecure, &res.insecure},
+			{argoCDTLSSettingsKey_PlainText, &res.plainText},
+			{argoCDTLSSettingsKey_GRPCWeb, &res.grpcWeb},
+		}
+
+		for _, boolField := range boolFields {
+			val, exists := configMap.Data[boolField.key]
+			if !exists || val == "" {
+				continue
+			}
+			parsedVal, err := strconv.ParseBool(val)
+			if err != nil {
+				return argoCDTLSSettings{}, fmt.Errorf("invalid value for '%s' in Argo CD TLS configmap in %s: %v", boolField.key, namespaceName, err)
+			}
+			*boolField.field = parsedVal
+		}
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ArgoCDTLSSettingsResourceName,
+			Namespace: namespaceName,
+		},
+	}
+	if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(secret), secret); err != nil {
+		if !apierr.IsNotFound(err) {
+			return argoCDTLSSettings{}, fmt.Errorf("unable to retrieve Argo CD TLS secret in %s: %v", namespaceName, err)
+		}
+	} else {
+
+		if val, exists := secret.Data[argoCDTLSSettingsKey_CA]; exists && len(val) > 0 {
+			res.caData = val
+		}
+
+		res.clientCertData = secret.Data[argoCDTLSSettingsKey_ClientCert]
+		res.clientKeyData = secret.Data[argoCDTLSSettingsKey_ClientKey]
+	}
+
+	if (len(res.clientCertData) == 0) != (len(res.clientKeyData) == 0) {
+		return argoCDTLSSettings{}, fmt.Errorf("Argo CD TLS secret in %s must specify both '%s' and '%s', or neither",
+			namespaceName, argoCDTLSSettingsKey_ClientCert, argoCDTLSSettingsKey_ClientKey)
+	}
+
+	return res, nil
+}
+
+// generateClientOptions converts the TLS settings into Argo CD client options. The Argo CD client only accepts certificates
+// as file paths, so the certificate data is written to a temporary directory: the Argo CD client reads these files when it
+// is created, so the caller should remove the directory (via the returned function) once the client has been created.
+func (s argoCDTLSSettings) generateClientOptions(server string, optionalAuthToken string) (*argocdclient.ClientOptions, func(), error) {
+
+	clientOpts := &argocdclient.ClientOptions{
+		ConfigPath:           "",
+		ServerAddr:           server,
+		AuthToken:            optionalAuthToken,
+		Insecure:             s.insecure,
+		PlainText:            s.plainText,
+		CertFile:             "",
+		ClientCertFile:       "",
+		ClientCertKeyFile:    "",
+		GRPCWeb:              s.grpcWeb,
+		GRPCWebRootPath:      s.grpcWebRootPath,
+		PortForward:          false,
+		PortForwardNamespace: "",
+		Headers:              []string{},
+	}
+
+	cleanup := func() {}
+
+	if len(s.caData) == 0 && len(s.clientCertData) == 0 {
+		return clientOpts, cleanup, nil
+	}
+
+	tempDir, err := ioutil.TempDir("", "argocd-tls-")
+	if err != nil {
+		return nil, cleanup, fmt.Errorf("unable to create temporary directory for Argo CD TLS files: %v", err)
+	}
+	cleanup = func() {
+		_ = os.RemoveAll(tempDir)
+	}
+
+	writeFile := func(name string, data []byte) (string, error) {
+		path := filepath.Join(tempDir, name)
+		if err := ioutil.WriteFile(path, data, 0600); err != nil {
+			return "", fmt.Errorf("unable to write Argo CD TLS file '%s': %v", name, err)
+		}
+		return path, nil
+	}
+
+	if len(s.caData) > 0 {
+		if clientOpts.CertFile, err = writeFile("ca.crt", s.caData); err != nil {
+			cleanup()
+			return nil, func() {}, err
+		}
+	}
+
+	if len(s.clientCertData) > 0 {
+		if clientOpts.ClientCertFile, err = writeFile("tls.crt", s.clientCertData); err != nil {
+			cleanup()
+			return nil, func() {}, err
+		}
+		if clientOpts.ClientCertKeyFile, err = writeFile("tls.key", s.clientKeyData); err != nil {
+			cleanup()
+			return nil, func() {}, err
+		}
+	}
+
+	return clientOpts, cleanup, nil
+}
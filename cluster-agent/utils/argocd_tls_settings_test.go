@@ -0,0 +1,154 @@
+package utils
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestGetArgoCDTLSSettings(t *testing.T) {
+
+	t.Parallel()
+
+	namespace := "openshift-gitops"
+
+	testCases := []struct {
+		name             string
+		objects          []client.Object
+		expectError      bool
+		expectedSettings argoCDTLSSettings
+	}{
+		{
+			name:             "no configmap or secret: verify against system CAs, with TLS",
+			objects:          []client.Object{},
+			expectedSettings: argoCDTLSSettings{},
+		},
+		{
+			name: "plaintext gRPC-web for in-cluster connections",
+			objects: []client.Object{
+				&corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Name: ArgoCDTLSSettingsResourceName, Namespace: namespace},
+					Data: map[string]string{
+						"server-address": "openshift-gitops-server.openshift-gitops.svc:80",
+						"plaintext":      "true",
+						"grpc-web":       "true",
+					},
+				},
+			},
+			expectedSettings: argoCDTLSSettings{
+				serverAddress: "openshift-gitops-server.openshift-gitops.svc:80",
+				plainText:     true,
+				grpcWeb:       true,
+			},
+		},
+		{
+			name: "CA from the secret takes precedence over the configmap, and client cert is read",
+			objects: []client.Object{
+				&corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Name: ArgoCDTLSSettingsResourceName, Namespace: namespace},
+					Data: map[string]string{
+						"ca.crt": "configmap-ca",
+					},
+				},
+				&corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Name: ArgoCDTLSSettingsResourceName, Namespace: namespace},
+					Data: map[string][]byte{
+						"ca.crt":  []byte("secret-ca"),
+						"tls.crt": []byte("cert"),
+						"tls.key": []byte("key"),
+					},
+				},
+			},
+			expectedSettings: argoCDTLSSettings{
+				caData:         []byte("secret-ca"),
+				clientCertData: []byte("cert"),
+				clientKeyData:  []byte("key"),
+			},
+		},
+		{
+			name: "invalid boolean value",
+			objects: []client.Object{
+				&corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Name: ArgoCDTLSSettingsResourceName, Namespace: namespace},
+					Data: map[string]string{
+						"insecure": "yes please",
+					},
+				},
+			},
+			expectError: true,
+		},
+		{
+			name: "client certificate without a key",
+			objects: []client.Object{
+				&corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Name: ArgoCDTLSSettingsResourceName, Namespace: namespace},
+					Data: map[string][]byte{
+						"tls.crt": []byte("cert"),
+					},
+				},
+			},
+			expectError: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+
+			k8sClient, err := generateFakeK8sClient(testCase.objects...)
+			assert.NoError(t, err)
+
+			settings, err := getArgoCDTLSSettings(context.Background(), namespace, k8sClient)
+			if testCase.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.True(t, settings.equals(testCase.expectedSettings), "settings should match: %v", settings)
+		})
+	}
+}
+
+func TestArgoCDTLSSettingsGenerateClientOptions(t *testing.T) {
+
+	t.Parallel()
+
+	// The insecure flag should never be set, unless explicitly requested
+	clientOpts, cleanup, err := argoCDTLSSettings{}.generateClientOptions("server", "token")
+	assert.NoError(t, err)
+	assert.False(t, clientOpts.Insecure)
+	assert.False(t, clientOpts.PlainText)
+	assert.Empty(t, clientOpts.CertFile)
+	cleanup()
+
+	settings := argoCDTLSSettings{
+		caData:         []byte("ca"),
+		clientCertData: []byte("cert"),
+		clientKeyData:  []byte("key"),
+	}
+
+	clientOpts, cleanup, err = settings.generateClientOptions("server", "token")
+	assert.NoError(t, err)
+	assert.Equal(t, "server", clientOpts.ServerAddr)
+	assert.Equal(t, "token", clientOpts.AuthToken)
+
+	for path, expected := range map[string]string{
+		clientOpts.CertFile:          "ca",
+		clientOpts.ClientCertFile:    "cert",
+		clientOpts.ClientCertKeyFile: "key",
+	} {
+		contents, err := ioutil.ReadFile(path)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, string(contents))
+	}
+
+	// The files should be removed on cleanup
+	cleanup()
+	_, err = os.Stat(clientOpts.CertFile)
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,169 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/golang/protobuf/ptypes/empty"
+	"github.com/redhat-appstudio/managed-gitops/backend-shared/config/db"
+	dbutil "github.com/redhat-appstudio/managed-gitops/backend-shared/config/db/util"
+	sharedutil "github.com/redhat-appstudio/managed-gitops/backend-shared/util"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// Version is the version of the cluster-agent, which is reported in its heartbeat. It may be set at build time, via:
+// -ldflags "-X github.com/redhat-appstudio/managed-gitops/cluster-agent/utils.Version=(version)"
+var Version = "dev"
+
+var (
+	// argoCDVersionRefreshInterval is how often the Argo CD version, which is reported in the heartbeat, is refreshed:
+	// retrieving the version requires a login to Argo CD, so it is not retrieved on every heartbeat.
+	argoCDVersionRefreshInterval = 30 * time.Minute
+
+	// argoCDVersionRetryInterval is how long to wait before retrying, if the Argo CD version could not be retrieved.
+	argoCDVersionRetryInterval = 5 * time.Minute
+)
+
+// HeartbeatRunner periodically records a heartbeat in the GitopsEngineCluster database row of the cluster that the
+// cluster-agent is running on. The backend uses the heartbeat to determine whether the cluster-agent is running,
+// before creating (and waiting on) operations that target the cluster: see 'dbutil.GetGitopsEngineClusterAvailability'.
+//
+// HeartbeatRunner implements the controller-runtime manager.Runnable interface.
+type HeartbeatRunner struct {
+	k8sClient client.Client
+	dbQueries db.DatabaseQueries
+
+	// getArgoCDVersion returns the version of the Argo CD instance that is used by the cluster-agent
+	getArgoCDVersion func(ctx context.Context) (string, error)
+
+	// argoCDVersion is the most recently retrieved Argo CD version, or empty if it could not be retrieved
+	argoCDVersion string
+	// nextArgoCDVersionRefresh is the time after which the Argo CD version should be retrieved again
+	nextArgoCDVersionRefresh time.Time
+}
+
+// NewHeartbeatRunner creates a new HeartbeatRunner; it should be added to the manager, which will start it.
+func NewHeartbeatRunner(k8sClient client.Client, dbQueries db.DatabaseQueries, credentialService *CredentialService) *HeartbeatRunner {
+
+	return &HeartbeatRunner{
+		k8sClient: k8sClient,
+		dbQueries: dbQueries,
+		getArgoCDVersion: func(ctx context.Context) (string, error) {
+			return getArgoCDVersion(ctx, k8sClient, credentialService)
+		},
+	}
+}
+
+// Start sends a heartbeat every GitopsEngineClusterHeartbeatInterval, until the context is cancelled.
+func (hr *HeartbeatRunner) Start(ctx context.Context) error {
+
+	log := log.FromContext(ctx).WithName("heartbeat")
+
+	ticker := time.NewTicker(dbutil.GitopsEngineClusterHeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+
+		if err := hr.sendHeartbeat(ctx, log); err != nil {
+			log.Error(err, "unable to send cluster-agent heartbeat")
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (hr *HeartbeatRunner) sendHeartbeat(ctx context.Context, log logr.Logger) error {
+
+	// The GitopsEngineCluster is identified by the UID of the 'kube-system' namespace of the cluster
+	kubeSystemNamespace := corev1.Namespace{}
+	if err := hr.k8sClient.Get(ctx, client.ObjectKey{Name: "kube-system"}, &kubeSystemNamespace); err != nil {
+		return fmt.Errorf("unable to retrieve kube-system namespace: %v", err)
+	}
+
+	gitopsEngineCluster, err := dbutil.GetGitopsEngineClusterByKubeSystemNamespaceUID(ctx, string(kubeSystemNamespace.UID), hr.dbQueries, log)
+	if err != nil {
+		return fmt.Errorf("unable to retrieve gitops engine cluster: %w", err)
+	}
+
+	if gitopsEngineCluster == nil {
+		// The backend will create the GitopsEngineCluster when it first deploys to this cluster
+		log.V(sharedutil.LogLevel_Debug).Info("no GitopsEngineCluster exists for this cluster, so skipping heartbeat")
+		return nil
+	}
+
+	argoCDVersion := hr.cachedArgoCDVersion(ctx, log)
+
+	gitopsEngineCluster.Heartbeat_last_seen = time.Now()
+	gitopsEngineCluster.Heartbeat_agent_version = db.TruncateVarchar(Version, db.GitopsEngineClusterHeartbeatAgentVersionLength)
+	gitopsEngineCluster.Heartbeat_argocd_version = db.TruncateVarchar(argoCDVersion, db.GitopsEngineClusterHeartbeatArgoCDVersionLength)
+	gitopsEngineCluster.Heartbeat_capacity = sharedutil.MaxActiveTaskRunners
+
+	if err := hr.dbQueries.UpdateGitopsEngineClusterHeartbeat(ctx, gitopsEngineCluster); err != nil {
+		return err
+	}
+
+	log.V(sharedutil.LogLevel_Debug).Info("sent cluster-agent heartbeat", "gitopsEngineCluster", gitopsEngineCluster.Gitopsenginecluster_id)
+
+	return nil
+}
+
+// cachedArgoCDVersion returns the Argo CD version, retrieving it only if the previously retrieved version is older than
+// argoCDVersionRefreshInterval.
+//
+// A heartbeat is still sent if Argo CD is unavailable: the cluster-agent itself is running, and the empty version
+// will be reported by the backend.
+func (hr *HeartbeatRunner) cachedArgoCDVersion(ctx context.Context, log logr.Logger) string {
+
+	now := time.Now()
+	if now.Before(hr.nextArgoCDVersionRefresh) {
+		return hr.argoCDVersion
+	}
+
+	argoCDVersion, err := hr.getArgoCDVersion(ctx)
+	if err != nil {
+		log.V(sharedutil.LogLevel_Warn).Info("unable to retrieve Argo CD version for heartbeat: " + err.Error())
+		hr.argoCDVersion = ""
+		hr.nextArgoCDVersionRefresh = now.Add(argoCDVersionRetryInterval)
+		return ""
+	}
+
+	hr.argoCDVersion = argoCDVersion
+	hr.nextArgoCDVersionRefresh = now.Add(argoCDVersionRefreshInterval)
+
+	return argoCDVersion
+}
+
+// getArgoCDVersion returns the version reported by the Argo CD API server of the GitOps engine instance.
+func getArgoCDVersion(ctx context.Context, k8sClient client.Client, credentialService *CredentialService) (string, error) {
+
+	argoCDNamespace := corev1.Namespace{}
+	if err := k8sClient.Get(ctx, client.ObjectKey{Name: dbutil.GetGitOpsEngineSingleInstanceNamespace()}, &argoCDNamespace); err != nil {
+		return "", fmt.Errorf("unable to retrieve Argo CD namespace: %v", err)
+	}
+
+	_, acdClient, err := credentialService.GetArgoCDLoginCredentials(ctx, argoCDNamespace.Name, string(argoCDNamespace.UID), false, k8sClient)
+	if err != nil {
+		return "", fmt.Errorf("unable to retrieve Argo CD credentials: %v", err)
+	}
+
+	conn, versionIf, err := acdClient.NewVersionClient()
+	if err != nil {
+		return "", fmt.Errorf("unable to create version client: %v", err)
+	}
+	defer conn.Close()
+
+	versionMessage, err := versionIf.Version(ctx, &empty.Empty{})
+	if err != nil {
+		return "", fmt.Errorf("unable to invoke version api: %v", err)
+	}
+
+	return versionMessage.Version, nil
+}
@@ -0,0 +1,166 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/redhat-appstudio/managed-gitops/backend-shared/config/db"
+	sharedutil "github.com/redhat-appstudio/managed-gitops/backend-shared/util"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestHeartbeatRunner(t *testing.T) {
+
+	t.Parallel()
+
+	kubeSystemNamespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-system", UID: uuid.NewUUID()}}
+
+	k8sClient, err := generateFakeK8sClient(kubeSystemNamespace)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	t.Run("heartbeat is written to the gitops engine cluster of the kube-system namespace", func(t *testing.T) {
+
+		dbQueries := &heartbeatTestDatabaseQueries{
+			kubeSystemNamespaceUID: string(kubeSystemNamespace.UID),
+			gitopsEngineCluster:    &db.GitopsEngineCluster{Gitopsenginecluster_id: "test-cluster", Clustercredentials_id: "test-creds"},
+		}
+
+		runner := &HeartbeatRunner{
+			k8sClient:        k8sClient,
+			dbQueries:        dbQueries,
+			getArgoCDVersion: func(ctx context.Context) (string, error) { return "v2.2.5", nil },
+		}
+
+		before := time.Now()
+		err := runner.sendHeartbeat(context.Background(), log.FromContext(context.Background()))
+		assert.NoError(t, err)
+
+		if assert.NotNil(t, dbQueries.updatedHeartbeat) {
+			assert.Equal(t, "test-cluster", dbQueries.updatedHeartbeat.Gitopsenginecluster_id)
+			assert.False(t, dbQueries.updatedHeartbeat.Heartbeat_last_seen.Before(before))
+			assert.Equal(t, Version, dbQueries.updatedHeartbeat.Heartbeat_agent_version)
+			assert.Equal(t, "v2.2.5", dbQueries.updatedHeartbeat.Heartbeat_argocd_version)
+			assert.Equal(t, sharedutil.MaxActiveTaskRunners, dbQueries.updatedHeartbeat.Heartbeat_capacity)
+		}
+	})
+
+	t.Run("heartbeat is still written if Argo CD is unavailable", func(t *testing.T) {
+
+		dbQueries := &heartbeatTestDatabaseQueries{
+			kubeSystemNamespaceUID: string(kubeSystemNamespace.UID),
+			gitopsEngineCluster:    &db.GitopsEngineCluster{Gitopsenginecluster_id: "test-cluster", Clustercredentials_id: "test-creds"},
+		}
+
+		runner := &HeartbeatRunner{
+			k8sClient:        k8sClient,
+			dbQueries:        dbQueries,
+			getArgoCDVersion: func(ctx context.Context) (string, error) { return "", fmt.Errorf("connection refused") },
+		}
+
+		err := runner.sendHeartbeat(context.Background(), log.FromContext(context.Background()))
+		assert.NoError(t, err)
+
+		if assert.NotNil(t, dbQueries.updatedHeartbeat) {
+			assert.Equal(t, "", dbQueries.updatedHeartbeat.Heartbeat_argocd_version)
+		}
+	})
+
+	t.Run("the Argo CD version is only retrieved once per refresh interval", func(t *testing.T) {
+
+		dbQueries := &heartbeatTestDatabaseQueries{
+			kubeSystemNamespaceUID: string(kubeSystemNamespace.UID),
+			gitopsEngineCluster:    &db.GitopsEngineCluster{Gitopsenginecluster_id: "test-cluster", Clustercredentials_id: "test-creds"},
+		}
+
+		versionRequests := 0
+		argoCDVersion := "v2.2.5"
+
+		runner := &HeartbeatRunner{
+			k8sClient: k8sClient,
+			dbQueries: dbQueries,
+			getArgoCDVersion: func(ctx context.Context) (string, error) {
+				versionRequests++
+				return argoCDVersion, nil
+			},
+		}
+
+		for i := 0; i < 3; i++ {
+			err := runner.sendHeartbeat(context.Background(), log.FromContext(context.Background()))
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, 1, versionRequests)
+		if assert.NotNil(t, dbQueries.updatedHeartbeat) {
+			assert.Equal(t, "v2.2.5", dbQueries.updatedHeartbeat.Heartbeat_argocd_version)
+		}
+
+		// Once the refresh interval has passed, the version is retrieved again
+		argoCDVersion = "v2.3.0"
+		runner.nextArgoCDVersionRefresh = time.Now().Add(-time.Second)
+
+		err := runner.sendHeartbeat(context.Background(), log.FromContext(context.Background()))
+		assert.NoError(t, err)
+		assert.Equal(t, 2, versionRequests)
+		assert.Equal(t, "v2.3.0", dbQueries.updatedHeartbeat.Heartbeat_argocd_version)
+	})
+
+	t.Run("no heartbeat is written if the gitops engine cluster does not exist", func(t *testing.T) {
+
+		dbQueries := &heartbeatTestDatabaseQueries{kubeSystemNamespaceUID: string(kubeSystemNamespace.UID)}
+
+		runner := &HeartbeatRunner{
+			k8sClient:        k8sClient,
+			dbQueries:        dbQueries,
+			getArgoCDVersion: func(ctx context.Context) (string, error) { return "v2.2.5", nil },
+		}
+
+		err := runner.sendHeartbeat(context.Background(), log.FromContext(context.Background()))
+		assert.NoError(t, err)
+		assert.Nil(t, dbQueries.updatedHeartbeat)
+	})
+}
+
+// heartbeatTestDatabaseQueries implements the subset of DatabaseQueries that is used by HeartbeatRunner: calls to
+// any other function will panic.
+type heartbeatTestDatabaseQueries struct {
+	db.DatabaseQueries
+
+	kubeSystemNamespaceUID string
+	gitopsEngineCluster    *db.GitopsEngineCluster
+
+	updatedHeartbeat *db.GitopsEngineCluster
+}
+
+func (dbq *heartbeatTestDatabaseQueries) GetDBResourceMappingForKubernetesResource(ctx context.Context, obj *db.KubernetesToDBResourceMapping) error {
+
+	if dbq.gitopsEngineCluster == nil || obj.KubernetesResourceUID != dbq.kubeSystemNamespaceUID ||
+		obj.DBRelationType != db.K8sToDBMapping_GitopsEngineCluster {
+		return db.NewResultNotFoundError("mapping")
+	}
+
+	obj.DBRelationKey = dbq.gitopsEngineCluster.Gitopsenginecluster_id
+	return nil
+}
+
+func (dbq *heartbeatTestDatabaseQueries) GetGitopsEngineClusterById(ctx context.Context, gitopsEngineCluster *db.GitopsEngineCluster) error {
+
+	if dbq.gitopsEngineCluster == nil || gitopsEngineCluster.Gitopsenginecluster_id != dbq.gitopsEngineCluster.Gitopsenginecluster_id {
+		return db.NewResultNotFoundError("gitops engine cluster")
+	}
+
+	*gitopsEngineCluster = *dbq.gitopsEngineCluster
+	return nil
+}
+
+func (dbq *heartbeatTestDatabaseQueries) UpdateGitopsEngineClusterHeartbeat(ctx context.Context, obj *db.GitopsEngineCluster) error {
+	updated := *obj
+	dbq.updatedHeartbeat = &updated
+	return nil
+}
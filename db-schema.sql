@@ -24,14 +24,18 @@ CREATE TABLE ClusterCredentials (
 	-- Example: https://api.ci-ln-dlfw0qk-f76d1.origin-ci-int-gce.dev.openshift.com:6443
 	host VARCHAR (512),
 
+	-- The secret fields (kube_config and serviceaccount_bearer_token) are encrypted by the db layer, if an encryption
+	-- key is configured: the value is then 'enc:v1:(key id):(wrapped data key):(ciphertext)'.
+	-- See 'backend-shared/config/db/clustercredentials_encryption.go'.
+
 	-- State 1) kube_config containing a token to a service account that has the permissions we need.
-	kube_config VARCHAR (65000),
+	kube_config VARCHAR (100000),
 
 	-- State 1) The name of a context within the kube_config 
 	kube_config_context VARCHAR (64),
 
 	-- State 2) ServiceAccount bearer token from the target manager cluster
-	serviceaccount_bearer_token VARCHAR (128),
+	serviceaccount_bearer_token VARCHAR (4096),
 
 	-- State 2) The namespace of the ServiceAccount
 	serviceaccount_ns VARCHAR (128),
@@ -52,7 +56,20 @@ CREATE TABLE GitopsEngineCluster (
 	-- pointer to credentials for the cluster
 	-- Foreign key to: ClusterCredentials.clustercredentials_cred_id
 	clustercredentials_id VARCHAR (48) NOT NULL,
-	CONSTRAINT fk_cluster_credential FOREIGN KEY(clustercredentials_id) REFERENCES ClusterCredentials(clustercredentials_cred_id) ON DELETE NO ACTION ON UPDATE NO ACTION
+	CONSTRAINT fk_cluster_credential FOREIGN KEY(clustercredentials_id) REFERENCES ClusterCredentials(clustercredentials_cred_id) ON DELETE NO ACTION ON UPDATE NO ACTION,
+
+	-- The heartbeat fields are periodically updated by the cluster-agent running on the cluster, and are used by the backend
+	-- to determine whether the cluster-agent is running. They are null if the cluster-agent has never sent a heartbeat.
+
+	-- The time of the most recent heartbeat from the cluster-agent
+	heartbeat_last_seen TIMESTAMP,
+
+	-- The version of the cluster-agent, and of the Argo CD instance, at the time of the most recent heartbeat
+	heartbeat_agent_version VARCHAR (256),
+	heartbeat_argocd_version VARCHAR (256),
+
+	-- The maximum number of operations that the cluster-agent will process concurrently
+	heartbeat_capacity INTEGER
 
 );
 
@@ -199,10 +216,20 @@ CREATE TABLE Operation (
 	-- * In_Progress
 	-- * Completed
 	-- * Failed
+	-- * Timeout (the operation did not complete before its deadline)
+	-- * Cancelled (cancellation of the operation was requested, before it completed)
 	state VARCHAR ( 30 ) NOT NULL,
 	
 	-- If there is an error message from the operation, it is passed via this field.
-	human_readable_state VARCHAR ( 1024 )
+	human_readable_state VARCHAR ( 1024 ),
+
+	-- The time by which the operation must complete: operations that are not complete by their deadline are moved to
+	-- the Timeout state. The deadline is based on the resource type of the operation.
+	deadline TIMESTAMP,
+
+	-- Whether the cancellation of the operation has been requested: the cluster-agent will stop processing the
+	-- operation, and move it to the Cancelled state.
+	cancel_requested BOOLEAN
 
 );
 
@@ -229,6 +256,13 @@ CREATE TABLE Application (
 	-- Foreign key to: ManagedEnvironment.managedenvironment_id
 	managed_environment_id VARCHAR(48) NOT NULL,
 	CONSTRAINT fk_managedenvironment_id FOREIGN KEY (managed_environment_id) REFERENCES ManagedEnvironment(managedenvironment_id) ON DELETE NO ACTION ON UPDATE NO ACTION,
+
+	-- version is incremented on every update of the row: an update only succeeds if the row has not been updated since
+	-- it was read (optimistic concurrency)
+	version INTEGER NOT NULL DEFAULT 1,
+
+	-- When the Application was created: used by the consistency checker to skip Applications that were created recently
+	created_on TIMESTAMP,
 	
 	seq_id serial
 
@@ -263,7 +297,19 @@ CREATE TABLE ApplicationState (
 	-- * Synced
 	-- * OutOfSync
 	-- * Unknown (this is used when Argo CD's status field is "")
-	sync_status VARCHAR (30) NOT NULL
+	sync_status VARCHAR (30) NOT NULL,
+
+	-- conditions field is a JSON list of the conditions from the Argo CD Application CR's .status.conditions field
+	-- (for example, ComparisonError, InvalidSpecError, SyncError), each with a type and a (truncated) message
+	conditions VARCHAR (4096),
+
+	-- operation_phase and operation_message fields come directly from the Argo CD Application CR's .status.operationState field
+	operation_phase VARCHAR (30),
+	operation_message VARCHAR (1024),
+
+	-- version is incremented on every update of the row: an update only succeeds if the row has not been updated since
+	-- it was read (optimistic concurrency)
+	version INTEGER NOT NULL DEFAULT 1
 
 );
 
@@ -398,6 +444,23 @@ CREATE TABLE SyncOperation (
 	-- values: Running, Terminated
 	desired_state VARCHAR(16) NOT NULL,	
 
+	-- The result of the most recent sync, as reported by the cluster-agent. These fields are null until a sync has completed.
+	-- Phase of the Argo CD sync operation, for example: Succeeded, Failed, Error, Running
+	sync_result_phase VARCHAR(30),
+
+	-- Human-readable message describing the result of the sync
+	sync_result_message VARCHAR(1024),
+
+	-- The number of resources which require pruning, before the Application can be considered synced
+	sync_result_pruning_required INTEGER,
+
+	-- JSON list containing the sync result of each individual resource
+	sync_result_resources VARCHAR(8192),
+
+	-- When the SyncOperation was created: used for garbage collection of SyncOperations that are no longer referenced
+	-- by a GitOpsDeploymentSyncRun.
+	created_on TIMESTAMP,
+
 	seq_id serial
 
 );